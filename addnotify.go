@@ -0,0 +1,60 @@
+package main
+
+import "strings"
+
+// clickedMagnet is set when the handler was launched with a magnet URI and
+// no command, as browsers do, rather than by the add command
+var clickedMagnet bool
+
+// storedSection returns the section of the database holding hash and its
+// entry, or "" when the database does not have it
+func storedSection(config Config, hash string) (string, MagnetEntry) {
+	if hash == "" || IndexRulesOut(config.JSONPath, hash) {
+		return "", MagnetEntry{}
+	}
+	db, err := LoadJSONDatabase(config.JSONPath)
+	if err != nil {
+		return "", MagnetEntry{}
+	}
+	section, entry, _ := entrySection(db, hash)
+	return section, entry
+}
+
+// notifyAddResult runs add and reports whether the magnet was added, was
+// already there, or failed, for users who are not watching a console
+func notifyAddResult(rawURI string, config Config, add func() error) error {
+	magnetURI, _, _ := resolveSource(rawURI)
+	hash := ExtractMagnetHash(magnetURI)
+	name := ExtractMagnetName(magnetURI)
+	before, _ := storedSection(config, hash)
+
+	if err := add(); err != nil {
+		if section, _ := storedSection(config, hash); section == SectionRetry && before != SectionRetry {
+			Notify(NotifyWarning, "Magnet queued for retry", name+"\n"+err.Error())
+		} else {
+			Notify(NotifyWarning, "Magnet not added", err.Error())
+		}
+		return err
+	}
+
+	section, entry := storedSection(config, hash)
+	last := HistoryEvent{}
+	if n := len(entry.History); n > 0 {
+		last = entry.History[n-1]
+	}
+	switch {
+	case before == SectionAdded:
+		Notify(NotifyInfo, "Magnet already added", withWebUILink(name, hash))
+	case before == SectionRetry:
+		Notify(NotifyWarning, "Magnet already waiting to retry", name+"\nRun the retry command to try it again")
+	case section == SectionRetry && last.Event == HistoryGaveUp:
+		Notify(NotifyWarning, "Magnet not added", name+"\n"+last.Detail)
+	case section == SectionRetry:
+		Notify(NotifyWarning, "Magnet queued for retry", strings.TrimSpace(name+"\n"+last.Detail))
+	case section == SectionAdded && last.Event == HistoryDuplicate:
+		Notify(NotifyInfo, "Magnet already in Deluge", withWebUILink(name, hash))
+	case section == SectionAdded:
+		Notify(NotifyInfo, "Magnet added", withWebUILink(name, hash))
+	}
+	return nil
+}
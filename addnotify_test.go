@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+)
+
+// Test notifyAddResult names each outcome of adding a clicked magnet
+func TestNotifyAddResult(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	t.Setenv("HOME", tmpDir)
+
+	client := fakeDeluge(t, func(method string, params []interface{}) (interface{}, interface{}) {
+		switch method {
+		case "auth.login", "web.connected":
+			return true, nil
+		case "daemon.info":
+			return "2.1.1", nil
+		case "core.add_torrent_magnet":
+			uri, _ := params[0].(string)
+			switch {
+			case strings.Contains(uri, "bbbb"):
+				return nil, map[string]interface{}{"message": "Torrent already in session", "code": 4}
+			case strings.Contains(uri, "cccc"):
+				return nil, map[string]interface{}{"message": "disk quota exceeded", "code": 4}
+			}
+			return "torrent-id", nil
+		}
+		return nil, nil
+	})
+	server, _ := url.Parse(client.BaseURL)
+	config := DefaultConfig()
+	config.DelugeHost = server.Hostname()
+	config.DelugePort = server.Port()
+	config.RemotePath = ""
+	config.MetadataTimeout = -1
+
+	var sent []Notification
+	original := notifiers
+	notifiers = []Notifier{recordingNotifier{&sent}}
+	defer func() { notifiers = original }()
+
+	tests := []struct {
+		name      string
+		uri       string
+		wantTitle string
+	}{
+		{"new magnet", "magnet:?xt=urn:btih:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa&dn=New", "Magnet added"},
+		{"clicked again", "magnet:?xt=urn:btih:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa&dn=New", "Magnet already added"},
+		{"already in Deluge", "magnet:?xt=urn:btih:bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb&dn=Dup", "Magnet already in Deluge"},
+		{"failed", "magnet:?xt=urn:btih:cccccccccccccccccccccccccccccccccccccccc&dn=Fail", "Magnet queued for retry"},
+		{"invalid", "magnet:?xt=urn:btih:nothex", "Magnet not added"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sent = nil
+			notifyAddResult(tt.uri, config, func() error { return AddMagnetToDeluge(tt.uri, config) })
+			if len(sent) != 1 || sent[0].Title != tt.wantTitle {
+				t.Errorf("Expected %q notification, got %+v", tt.wantTitle, sent)
+			}
+		})
+	}
+}
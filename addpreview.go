@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"log"
+)
+
+// AddPreview is what adding a magnet would do, worked out without adding it
+type AddPreview struct {
+	Hash          string
+	Name          string
+	Section       string // Database section already holding the hash ("" = new)
+	InDeluge      string // yes, no, or why it could not be checked
+	Label         string
+	LabelFrom     string // Tracker host tracker_labels chose the label for ("" = default)
+	SavePath      string // Download location sent to Deluge ("" = Deluge's default)
+	MoveCompleted string
+	Paused        bool
+}
+
+// previewAdd validates a magnet and reports where its hash already is and
+// the label and paths an add would use. Deluge, reached through connect, is
+// only asked about the hash; nothing is added and the database is not written.
+func previewAdd(rawURI string, config Config, connect func() (*DelugeClient, error)) (AddPreview, error) {
+	magnetURI, _, err := resolveSource(rawURI)
+	if err != nil {
+		return AddPreview{}, err
+	}
+	if !ValidateMagnetURI(magnetURI) {
+		return AddPreview{}, fmt.Errorf("invalid magnet URI format")
+	}
+	preview := AddPreview{Hash: ExtractMagnetHash(magnetURI), Name: ExtractMagnetName(magnetURI)}
+	if preview.Hash == "" {
+		return AddPreview{}, fmt.Errorf("could not extract hash from magnet URI")
+	}
+
+	if db, err := LoadJSONDatabase(config.JSONPath); err == nil {
+		_, preview.Section, _, _ = findEntry(db, preview.Hash)
+	}
+
+	routed := config
+	routeByTracker(&routed, magnetURI)
+	preview.Label = routed.DelugeLabel
+	if routed.DelugeLabel != config.DelugeLabel {
+		_, preview.LabelFrom = trackerLabel(config, magnetURI)
+	}
+	opts := AddOptionsFromConfig(routed)
+	preview.SavePath, preview.MoveCompleted, preview.Paused = opts.DownloadLocation, opts.MoveCompletedPath, opts.AddPaused
+
+	client, err := connect()
+	var status map[string]interface{}
+	if err == nil {
+		status, err = client.GetTorrentStatus(preview.Hash, []string{"name"})
+	}
+	switch {
+	case err != nil:
+		preview.InDeluge = fmt.Sprintf("unknown (%v)", err)
+	case len(status) == 0:
+		preview.InDeluge = "no"
+	default:
+		preview.InDeluge = "yes"
+	}
+	return preview, nil
+}
+
+// logAddPreview logs the outcome of an add dry run
+func logAddPreview(p AddPreview) {
+	log.Printf("Dry run, nothing will be added or saved")
+	log.Printf("  Name: %s", p.Name)
+	log.Printf("  Hash: %s", p.Hash)
+	section := "not tracked"
+	if p.Section != "" {
+		section = "in " + p.Section
+	}
+	log.Printf("  Database: %s", section)
+	log.Printf("  In Deluge: %s", p.InDeluge)
+	label := p.Label
+	if p.LabelFrom != "" {
+		label += " (from tracker " + p.LabelFrom + ")"
+	}
+	log.Printf("  Label: %s", label)
+	savePath := p.SavePath
+	if savePath == "" {
+		savePath = "(Deluge default)"
+	}
+	log.Printf("  Save path: %s", savePath)
+	if p.MoveCompleted != "" {
+		log.Printf("  Move completed to: %s", p.MoveCompleted)
+	}
+	if p.Paused {
+		log.Printf("  Added paused")
+	}
+	switch {
+	case p.Section == SectionAdded:
+		log.Printf("Result: already added, an add would be skipped")
+	case p.Section == SectionRetry:
+		log.Printf("Result: already queued, an add would be skipped (run the retry command)")
+	case p.InDeluge == "yes":
+		log.Printf("Result: already in Deluge, an add would record it as a duplicate")
+	default:
+		log.Printf("Result: would be added")
+	}
+}
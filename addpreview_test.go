@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Test an add dry run finds the hash in the database and Deluge, reports
+// the routed label and paths, and adds and saves nothing
+func TestPreviewAdd(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	t.Setenv("HOME", tmpDir)
+
+	config := DefaultConfig()
+	config.JSONPath = filepath.Join(tmpDir, "db.json")
+	config.DownloadLocation = "/downloads"
+	config.TrackerLabels = map[string]string{"music.example": "music"}
+	retryHash := "1123456789abcdef0123456789abcdef01234567"
+	db := &MagnetDatabase{
+		Added: map[string]MagnetEntry{},
+		Retry: map[string]MagnetEntry{retryHash: {Hash: retryHash, Title: "Queued"}},
+	}
+	if err := SaveDatabaseLocal(config.JSONPath, db); err != nil {
+		t.Fatalf("Failed to save database: %v", err)
+	}
+	before, _ := os.ReadFile(config.JSONPath)
+
+	var methods []string
+	client := fakeDeluge(t, func(method string, params []interface{}) (interface{}, interface{}) {
+		methods = append(methods, method)
+		if method == "core.get_torrent_status" && params[0] == "0123456789abcdef0123456789abcdef01234567" {
+			return map[string]interface{}{"name": "Album"}, nil
+		}
+		return map[string]interface{}{}, nil
+	})
+	connect := func() (*DelugeClient, error) { return client, nil }
+
+	preview, err := previewAdd("magnet:?xt=urn:btih:0123456789ABCDEF0123456789ABCDEF01234567&dn=Album&tr=http%3A%2F%2Ftracker.music.example%2Fannounce", config, connect)
+	if err != nil {
+		t.Fatalf("previewAdd failed: %v", err)
+	}
+	if preview.Section != "" || preview.InDeluge != "yes" || preview.Label != "music" || preview.LabelFrom != "tracker.music.example" || preview.SavePath != "/downloads" {
+		t.Errorf("Unexpected preview: %+v", preview)
+	}
+
+	preview, err = previewAdd("magnet:?xt=urn:btih:"+retryHash+"&dn=Queued", config, connect)
+	if err != nil || preview.Section != SectionRetry || preview.InDeluge != "no" || preview.Label != config.DelugeLabel {
+		t.Errorf("Expected the retry entry found, got %+v, %v", preview, err)
+	}
+
+	for _, method := range methods {
+		if method == "core.add_torrent_magnet" {
+			t.Error("A dry run must not add the magnet")
+		}
+	}
+	if after, _ := os.ReadFile(config.JSONPath); string(after) != string(before) {
+		t.Error("A dry run must not write the database")
+	}
+
+	preview, err = previewAdd("magnet:?xt=urn:btih:"+retryHash, config, func() (*DelugeClient, error) { return nil, errors.New("connection refused") })
+	if err != nil || !strings.Contains(preview.InDeluge, "connection refused") {
+		t.Errorf("Expected Deluge reported as unknown, got %+v, %v", preview, err)
+	}
+	if _, err := previewAdd("magnet:?dn=nohash", config, connect); err == nil {
+		t.Error("Expected an invalid magnet to be rejected")
+	}
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// adoptCandidate is a torrent in Deluge that the database does not track
+type adoptCandidate struct {
+	Hash    string
+	Name    string
+	Label   string
+	Torrent map[string]interface{}
+}
+
+// untrackedTorrents lists Deluge's torrents missing from the added and retry
+// sections, sorted by label and name
+func untrackedTorrents(torrents map[string]map[string]interface{}, db *MagnetDatabase) []adoptCandidate {
+	var candidates []adoptCandidate
+	for id, torrent := range torrents {
+		hash, _ := torrent["hash"].(string)
+		if hash == "" {
+			hash = id
+		}
+		hash = strings.ToLower(hash)
+		if _, ok := db.Added[hash]; ok {
+			continue
+		}
+		if _, ok := db.Retry[hash]; ok {
+			continue
+		}
+		name, _ := torrent["name"].(string)
+		label, _ := torrent["label"].(string)
+		candidates = append(candidates, adoptCandidate{Hash: hash, Name: name, Label: label, Torrent: torrent})
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].Label != candidates[j].Label {
+			return candidates[i].Label < candidates[j].Label
+		}
+		return strings.ToLower(candidates[i].Name) < strings.ToLower(candidates[j].Name)
+	})
+	return candidates
+}
+
+// filterCandidates keeps candidates with the given label (unless label is
+// nil) whose name contains match, ignoring case
+func filterCandidates(candidates []adoptCandidate, label *string, match string) []adoptCandidate {
+	match = strings.ToLower(match)
+	var kept []adoptCandidate
+	for _, c := range candidates {
+		if label != nil && c.Label != *label {
+			continue
+		}
+		if match != "" && !strings.Contains(strings.ToLower(c.Name), match) {
+			continue
+		}
+		kept = append(kept, c)
+	}
+	return kept
+}
+
+// pickHashes returns the candidates for the given hashes, which may be
+// abbreviated to any unique prefix
+func pickHashes(candidates []adoptCandidate, hashes []string) ([]adoptCandidate, error) {
+	var picked []adoptCandidate
+	for _, prefix := range hashes {
+		prefix = strings.ToLower(prefix)
+		var found []adoptCandidate
+		for _, c := range candidates {
+			if strings.HasPrefix(c.Hash, prefix) {
+				found = append(found, c)
+			}
+		}
+		switch len(found) {
+		case 0:
+			return nil, fmt.Errorf("no untracked torrent matches %q", prefix)
+		case 1:
+			picked = append(picked, found[0])
+		default:
+			return nil, fmt.Errorf("%q matches %d untracked torrents", prefix, len(found))
+		}
+	}
+	return picked, nil
+}
+
+// parseSelection turns input like "1 3, 5-7" or "all" into indexes into a
+// list of n items
+func parseSelection(input string, n int) ([]int, error) {
+	input = strings.TrimSpace(strings.ToLower(input))
+	if input == "all" {
+		all := make([]int, n)
+		for i := range all {
+			all[i] = i
+		}
+		return all, nil
+	}
+
+	chosen := make(map[int]bool)
+	for _, field := range strings.FieldsFunc(input, func(r rune) bool { return r == ' ' || r == ',' }) {
+		first, last, isRange := strings.Cut(field, "-")
+		from, err := strconv.Atoi(first)
+		if err != nil {
+			return nil, fmt.Errorf("invalid selection %q", field)
+		}
+		to := from
+		if isRange {
+			if to, err = strconv.Atoi(last); err != nil {
+				return nil, fmt.Errorf("invalid selection %q", field)
+			}
+		}
+		if from < 1 || to > n || from > to {
+			return nil, fmt.Errorf("selection %q is outside 1-%d", field, n)
+		}
+		for i := from; i <= to; i++ {
+			chosen[i-1] = true
+		}
+	}
+
+	indexes := make([]int, 0, len(chosen))
+	for i := range chosen {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+	return indexes, nil
+}
+
+// promptSelection lists the candidates on w and reads which to adopt from r
+func promptSelection(r io.Reader, w io.Writer, candidates []adoptCandidate) ([]adoptCandidate, error) {
+	for i, c := range candidates {
+		label := c.Label
+		if label == "" {
+			label = "(none)"
+		}
+		fmt.Fprintf(w, "%3d) %s  %-12s %s\n", i+1, c.Hash[:min(8, len(c.Hash))], label, c.Name)
+	}
+	fmt.Fprint(w, "Adopt which torrents? (e.g. 1 3 5-7, all, or empty to cancel): ")
+
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if strings.TrimSpace(line) == "" {
+		return nil, nil
+	}
+	indexes, err := parseSelection(line, len(candidates))
+	if err != nil {
+		return nil, err
+	}
+	selected := make([]adoptCandidate, len(indexes))
+	for i, index := range indexes {
+		selected[i] = candidates[index]
+	}
+	return selected, nil
+}
+
+// adoptUpdate builds the database update importing the selected torrents.
+// IDs are assigned when the update is applied.
+func adoptUpdate(selected []adoptCandidate) *MagnetDatabase {
+	update := &MagnetDatabase{
+		Added:   make(map[string]MagnetEntry),
+		Retry:   make(map[string]MagnetEntry),
+		Removed: make(map[string]MagnetEntry),
+	}
+	for _, c := range selected {
+		entry := torrentEntry(c.Hash, c.Torrent, c.Label)
+		entry.recordHistory(HistoryAdopted, "", "")
+		update.Added[c.Hash] = entry
+	}
+	return update
+}
+
+// runAdopt implements the adopt command
+func runAdopt(config Config, args []string) error {
+	fs := newCommandFlags(adoptCommand)
+	label := fs.String("label", "", "Only torrents with this Deluge label")
+	match := fs.String("match", "", "Only torrents whose name contains this text")
+	all := fs.Bool("all", false, "Adopt every matching torrent without asking")
+	dryRun := fs.Bool("dry-run", false, "List what would be adopted without saving")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	var labelFilter *string
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "label" {
+			labelFilter = label
+		}
+	})
+
+	client := NewDelugeClient(config.DelugeHost, config.DelugePort, config.DelugePassword)
+	if err := client.Authenticate(); err != nil {
+		return fmt.Errorf("authentication failed: %w", err)
+	}
+	if err := client.Connect(); err != nil {
+		return fmt.Errorf("connection failed: %w", err)
+	}
+	torrents, err := client.GetAllTorrents()
+	if err != nil {
+		return fmt.Errorf("failed to get torrents: %w", err)
+	}
+	db, err := LoadJSONDatabase(config.JSONPath)
+	if err != nil {
+		return fmt.Errorf("failed to load database: %w", err)
+	}
+
+	candidates := filterCandidates(untrackedTorrents(torrents, db), labelFilter, *match)
+	var selected []adoptCandidate
+	switch {
+	case fs.NArg() > 0:
+		if selected, err = pickHashes(candidates, fs.Args()); err != nil {
+			return err
+		}
+	case len(candidates) == 0:
+		log.Println("No untracked torrents match")
+		return nil
+	case *all:
+		selected = candidates
+	case term.IsTerminal(int(os.Stdin.Fd())):
+		if selected, err = promptSelection(os.Stdin, os.Stdout, candidates); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("%s match; pass hashes or --all when not running in a terminal",
+			plural(len(candidates), "untracked torrent", "untracked torrents"))
+	}
+	if len(selected) == 0 {
+		log.Println("Nothing adopted")
+		return nil
+	}
+
+	for _, c := range selected {
+		log.Printf("Adopting %s: %s", c.Hash[:min(8, len(c.Hash))], c.Name)
+	}
+	if *dryRun {
+		log.Printf("Dry run: %s would be adopted", plural(len(selected), "torrent", "torrents"))
+		return nil
+	}
+	if err := SaveJSONDatabase(config.JSONPath, adoptUpdate(selected), &config); err != nil {
+		return fmt.Errorf("failed to save database: %w", err)
+	}
+	log.Printf("✓ Adopted %s", plural(len(selected), "torrent", "torrents"))
+	return nil
+}
+
+var adoptCommand = &Command{
+	Name:    "adopt",
+	Usage:   "adopt [--label L] [--match TEXT] [--all] [--dry-run] [hash...]",
+	Summary: "Import chosen untracked Deluge torrents into the database, without a full backfill",
+}
+
+func init() {
+	adoptCommand.Run = runAdopt
+	registerCommand(adoptCommand)
+}
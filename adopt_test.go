@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Test untrackedTorrents skips tracked hashes and filterCandidates narrows by label and name
+func TestUntrackedTorrents(t *testing.T) {
+	torrents := map[string]map[string]interface{}{
+		"aaa": {"hash": "AAA", "name": "Tracked Book", "label": "audiobooks"},
+		"bbb": {"hash": "bbb", "name": "Web UI Book", "label": "audiobooks"},
+		"ccc": {"hash": "ccc", "name": "Queued Book", "label": "audiobooks"},
+		"ddd": {"hash": "ddd", "name": "Episode 2024", "label": "podcasts"},
+		"eee": {"hash": "eee", "name": "Unlabeled", "label": ""},
+	}
+	db := &MagnetDatabase{
+		Added: map[string]MagnetEntry{"aaa": {Hash: "aaa"}},
+		Retry: map[string]MagnetEntry{"ccc": {Hash: "ccc"}},
+	}
+
+	candidates := untrackedTorrents(torrents, db)
+	var hashes []string
+	for _, c := range candidates {
+		hashes = append(hashes, c.Hash)
+	}
+	if strings.Join(hashes, ",") != "eee,bbb,ddd" {
+		t.Errorf("Expected untracked eee,bbb,ddd sorted by label, got %v", hashes)
+	}
+
+	label := "audiobooks"
+	if kept := filterCandidates(candidates, &label, ""); len(kept) != 1 || kept[0].Hash != "bbb" {
+		t.Errorf("Label filter kept %+v", kept)
+	}
+	none := ""
+	if kept := filterCandidates(candidates, &none, ""); len(kept) != 1 || kept[0].Hash != "eee" {
+		t.Errorf("Empty label filter kept %+v", kept)
+	}
+	if kept := filterCandidates(candidates, nil, "episode"); len(kept) != 1 || kept[0].Hash != "ddd" {
+		t.Errorf("Match filter kept %+v", kept)
+	}
+
+	if picked, err := pickHashes(candidates, []string{"BB"}); err != nil || len(picked) != 1 || picked[0].Hash != "bbb" {
+		t.Errorf("pickHashes = %+v, %v", picked, err)
+	}
+	if _, err := pickHashes(candidates, []string{"aaa"}); err == nil {
+		t.Error("Expected error picking a tracked hash")
+	}
+}
+
+// Test parseSelection accepts numbers, ranges, and all
+func TestParseSelection(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    []int
+		wantErr bool
+	}{
+		{"1", []int{0}, false},
+		{"3 1, 2", []int{0, 1, 2}, false},
+		{"2-4 4", []int{1, 2, 3}, false},
+		{"all", []int{0, 1, 2, 3, 4}, false},
+		{"0", nil, true},
+		{"6", nil, true},
+		{"4-2", nil, true},
+		{"two", nil, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseSelection(tt.input, 5)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseSelection(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseSelection(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+// Test promptSelection lists candidates and adoptUpdate imports the chosen ones
+func TestPromptSelectionAdopt(t *testing.T) {
+	candidates := []adoptCandidate{
+		{Hash: "aaa", Name: "First", Label: "audiobooks", Torrent: map[string]interface{}{"name": "First", "save_path": "/books"}},
+		{Hash: "bbb", Name: "Second", Torrent: map[string]interface{}{"name": "Second"}},
+	}
+
+	var out bytes.Buffer
+	selected, err := promptSelection(strings.NewReader("2\n"), &out, candidates)
+	if err != nil {
+		t.Fatalf("promptSelection failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "  1) aaa  audiobooks") || !strings.Contains(out.String(), "(none)") {
+		t.Errorf("Unexpected listing:\n%s", out.String())
+	}
+	if len(selected) != 1 || selected[0].Hash != "bbb" {
+		t.Fatalf("Expected bbb selected, got %+v", selected)
+	}
+
+	if selected, err := promptSelection(strings.NewReader("\n"), &out, candidates); err != nil || len(selected) != 0 {
+		t.Errorf("Expected empty input to cancel, got %+v, %v", selected, err)
+	}
+
+	update := adoptUpdate(candidates[:1])
+	entry, ok := update.Added["aaa"]
+	if !ok {
+		t.Fatal("Expected aaa in the update's added section")
+	}
+	if entry.Title != "First" || entry.SavePath != "/books" || entry.Label != "audiobooks" || entry.ID != 0 {
+		t.Errorf("Unexpected entry: %+v", entry)
+	}
+	if len(entry.History) != 1 || entry.History[0].Event != HistoryAdopted {
+		t.Errorf("Expected adopted history event, got %+v", entry.History)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// publicPaths are served without a bearer token: the dashboard shell holds
+// no data, and the webhook and calendar feed check their own secrets
+var publicPaths = map[string]bool{
+	"GET /":             true,
+	"POST /api/webhook": true,
+	"POST /api/pair":    true, // Checks a one-time pairing code
+	"GET /calendar.ics": true, // Checks calendar_secret; calendar apps cannot send a token
+}
+
+// newAPIToken returns a random bearer token for the HTTP API
+func newAPIToken() string {
+	b := make([]byte, 24)
+	rand.Read(b)
+	return fmt.Sprintf("%x", b)
+}
+
+// bearerToken extracts the token from an Authorization header
+func bearerToken(r *http.Request) string {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// withToken requires a valid bearer token on every request except the public
+// paths: the configured token or, when paired is set, one it accepts. With no
+// token configured the API refuses all protected requests.
+func withToken(token string, paired func(token string) bool, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if publicPaths[r.Method+" "+r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if token == "" {
+			writeError(w, http.StatusServiceUnavailable, fmt.Errorf("no api_token configured"))
+			return
+		}
+		given := bearerToken(r)
+		valid := subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1
+		if !valid && given != "" && paired != nil {
+			valid = paired(given)
+		}
+		if !valid {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="magnet-handler"`)
+			writeError(w, http.StatusUnauthorized, fmt.Errorf("missing or invalid bearer token"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ensureAPIToken generates and stores an API token the first time the
+// daemon runs, so the HTTP API is never left open
+func ensureAPIToken(config *Config) error {
+	if config.APIToken != "" {
+		return nil
+	}
+
+	// Save into the stored config, not the one with command-line overrides
+	stored, err := LoadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if stored.APIToken == "" {
+		stored.APIToken = newAPIToken()
+		if err := SaveConfig(stored); err != nil {
+			return fmt.Errorf("failed to save API token: %w", err)
+		}
+		log.Printf("✓ Generated API token and saved it as api_token in the config")
+	}
+	config.APIToken = stored.APIToken
+	return nil
+}
+
+// tlsFiles returns the configured certificate and key, requiring both or neither
+func tlsFiles(config Config) (cert, key string, err error) {
+	if (config.TLSCert == "") != (config.TLSKey == "") {
+		return "", "", fmt.Errorf("tls_cert and tls_key must be set together")
+	}
+	return config.TLSCert, config.TLSKey, nil
+}
+
+// isLoopback reports whether addr only accepts local connections
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMaxQueueDepth    = 20
+	defaultMaxDelugeLatency = 5 * time.Second
+
+	// backpressureRetryAfter is the Retry-After sent with 429 responses
+	backpressureRetryAfter = 30 * time.Second
+
+	// latencySampleTTL is how long a Deluge latency sample counts; without
+	// fresh samples a throttled daemon would never see Deluge recover
+	latencySampleTTL = time.Minute
+)
+
+var (
+	latencyMu     sync.Mutex
+	latencyAvg    time.Duration
+	latencySample time.Time
+)
+
+// recordDelugeLatency folds one Deluge round trip into the moving average
+func recordDelugeLatency(d time.Duration) {
+	latencyMu.Lock()
+	defer latencyMu.Unlock()
+	if latencySample.IsZero() || time.Since(latencySample) > latencySampleTTL {
+		latencyAvg = d
+	} else {
+		latencyAvg = (latencyAvg*4 + d) / 5
+	}
+	latencySample = time.Now()
+}
+
+// delugeLatency returns the recent average Deluge round trip, or 0 when
+// there is no recent sample
+func delugeLatency() time.Duration {
+	latencyMu.Lock()
+	defer latencyMu.Unlock()
+	if latencySample.IsZero() || time.Since(latencySample) > latencySampleTTL {
+		return 0
+	}
+	return latencyAvg
+}
+
+// maxQueueDepth returns the pending job count at which submissions are refused
+func maxQueueDepth(config Config) int {
+	if config.MaxQueueDepth > 0 {
+		return config.MaxQueueDepth
+	}
+	return defaultMaxQueueDepth
+}
+
+// maxDelugeLatency returns the Deluge latency at which submissions are refused
+func maxDelugeLatency(config Config) time.Duration {
+	if config.MaxDelugeLatency > 0 {
+		return time.Duration(config.MaxDelugeLatency) * time.Millisecond
+	}
+	return defaultMaxDelugeLatency
+}
+
+// overloaded returns why new submissions should wait, or "" when they can
+// be accepted
+func (s *apiServer) overloaded() string {
+	if s.queue != nil {
+		if depth := s.queue.Pending(); depth >= maxQueueDepth(s.config) {
+			return fmt.Sprintf("%d jobs already queued", depth)
+		}
+	}
+	if latency := delugeLatency(); latency > maxDelugeLatency(s.config) {
+		return fmt.Sprintf("Deluge is taking %s per request", latency.Round(time.Millisecond))
+	}
+	return ""
+}
+
+// admit refuses a submission with 429 and Retry-After while the daemon is
+// overloaded, so automations back off instead of piling up work
+func (s *apiServer) admit(w http.ResponseWriter) bool {
+	reason := s.overloaded()
+	s.setThrottled(reason)
+	if reason == "" {
+		return true
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(int(backpressureRetryAfter.Seconds())))
+	writeError(w, http.StatusTooManyRequests, fmt.Errorf("busy: %s, retry later", reason))
+	return false
+}
+
+// setThrottled notifies once when backpressure starts and logs when it ends
+func (s *apiServer) setThrottled(reason string) {
+	s.throttleMu.Lock()
+	was := s.throttled
+	s.throttled = reason != ""
+	s.throttleMu.Unlock()
+
+	switch {
+	case s.throttled && !was:
+		Notify(NotifyWarning, "Daemon is refusing new submissions",
+			fmt.Sprintf("%s. Webhooks and API callers get 429 until it catches up.", reason))
+	case !s.throttled && was:
+		log.Println("✓ Backpressure cleared, accepting submissions again")
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// resetDelugeLatency clears recorded latency samples
+func resetDelugeLatency() {
+	latencyMu.Lock()
+	defer latencyMu.Unlock()
+	latencyAvg, latencySample = 0, time.Time{}
+}
+
+// Test submissions get 429 with Retry-After while the queue is full
+func TestBackpressureQueueDepth(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	t.Setenv("HOME", tmpDir)
+
+	var sent []Notification
+	original := notifiers
+	notifiers = []Notifier{recordingNotifier{&sent}}
+	defer func() { notifiers = original }()
+
+	config := DefaultConfig()
+	config.WebhookSecret = "s3cret"
+	config.MaxQueueDepth = 2
+	api := newAPIServer(config)
+
+	// A queue nobody is draining, holding two jobs and one scheduled for later
+	api.queue, err = openWorkQueue(filepath.Join(tmpDir, "queue.json"), nil)
+	if err != nil {
+		t.Fatalf("openWorkQueue failed: %v", err)
+	}
+	api.queue.jobs = []workJob{{ID: "1"}, {ID: "2"}, {ID: "3", NotBefore: time.Now().Add(time.Hour).Format(time.RFC3339)}}
+	server := httptest.NewServer(api.handler())
+	defer server.Close()
+
+	body := `{"release":{"magnetUrl":"magnet:?xt=urn:btih:0123456789abcdef0123456789abcdef01234567"}}`
+	for i := 0; i < 2; i++ {
+		resp := postWebhook(t, server.URL, "s3cret", body)
+		if resp.StatusCode != http.StatusTooManyRequests {
+			t.Fatalf("Expected 429, got %d", resp.StatusCode)
+		}
+		if resp.Header.Get("Retry-After") != "30" {
+			t.Errorf("Expected Retry-After 30, got %q", resp.Header.Get("Retry-After"))
+		}
+	}
+	if len(sent) != 1 {
+		t.Errorf("Expected one notification when backpressure starts, got %d", len(sent))
+	}
+
+	// Test events carry no links and are never refused
+	if resp := postWebhook(t, server.URL, "s3cret", `{"eventType":"Test"}`); resp.StatusCode != http.StatusOK {
+		t.Errorf("Test event: expected 200, got %d", resp.StatusCode)
+	}
+
+	api.queue.jobs = api.queue.jobs[2:]
+	if reason := api.overloaded(); reason != "" {
+		t.Errorf("Scheduled jobs should not count toward the limit, got %q", reason)
+	}
+}
+
+// Test slow Deluge responses trigger backpressure until the samples age out
+func TestBackpressureLatency(t *testing.T) {
+	defer resetDelugeLatency()
+	api := newAPIServer(Config{MaxDelugeLatency: 1000})
+
+	resetDelugeLatency()
+	recordDelugeLatency(200 * time.Millisecond)
+	if reason := api.overloaded(); reason != "" {
+		t.Errorf("Fast Deluge should not be overloaded, got %q", reason)
+	}
+
+	recordDelugeLatency(20 * time.Second)
+	if reason := api.overloaded(); reason == "" {
+		t.Error("Slow Deluge should trigger backpressure")
+	}
+
+	latencyMu.Lock()
+	latencySample = time.Now().Add(-2 * latencySampleTTL)
+	latencyMu.Unlock()
+	if reason := api.overloaded(); reason != "" {
+		t.Errorf("Stale samples should not keep the daemon throttled, got %q", reason)
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+)
+
+// decodeBencode parses one bencoded value. Byte strings become Go strings,
+// integers int64, lists []interface{}, and dictionaries map[string]interface{}.
+func decodeBencode(data []byte) (interface{}, error) {
+	value, rest, err := decodeBencodeValue(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		return nil, fmt.Errorf("bencode: %d trailing bytes", len(rest))
+	}
+	return value, nil
+}
+
+// decodeBencodeValue parses the value at the start of data, returning the
+// unparsed remainder
+func decodeBencodeValue(data []byte) (interface{}, []byte, error) {
+	if len(data) == 0 {
+		return nil, nil, fmt.Errorf("bencode: unexpected end of data")
+	}
+
+	switch c := data[0]; {
+	case c == 'i':
+		end := bytes.IndexByte(data, 'e')
+		if end < 0 {
+			return nil, nil, fmt.Errorf("bencode: unterminated integer")
+		}
+		n, err := strconv.ParseInt(string(data[1:end]), 10, 64)
+		if err != nil {
+			return nil, nil, fmt.Errorf("bencode: bad integer: %w", err)
+		}
+		return n, data[end+1:], nil
+
+	case c == 'l':
+		list := []interface{}{}
+		rest := data[1:]
+		for len(rest) > 0 && rest[0] != 'e' {
+			var item interface{}
+			var err error
+			if item, rest, err = decodeBencodeValue(rest); err != nil {
+				return nil, nil, err
+			}
+			list = append(list, item)
+		}
+		if len(rest) == 0 {
+			return nil, nil, fmt.Errorf("bencode: unterminated list")
+		}
+		return list, rest[1:], nil
+
+	case c == 'd':
+		dict := map[string]interface{}{}
+		rest := data[1:]
+		for len(rest) > 0 && rest[0] != 'e' {
+			key, afterKey, err := decodeBencodeValue(rest)
+			if err != nil {
+				return nil, nil, err
+			}
+			name, ok := key.(string)
+			if !ok {
+				return nil, nil, fmt.Errorf("bencode: dictionary key is not a string")
+			}
+			var value interface{}
+			if value, rest, err = decodeBencodeValue(afterKey); err != nil {
+				return nil, nil, err
+			}
+			dict[name] = value
+		}
+		if len(rest) == 0 {
+			return nil, nil, fmt.Errorf("bencode: unterminated dictionary")
+		}
+		return dict, rest[1:], nil
+
+	case c >= '0' && c <= '9':
+		colon := bytes.IndexByte(data, ':')
+		if colon < 0 {
+			return nil, nil, fmt.Errorf("bencode: unterminated string length")
+		}
+		length, err := strconv.Atoi(string(data[:colon]))
+		if err != nil || length < 0 || colon+1+length > len(data) {
+			return nil, nil, fmt.Errorf("bencode: bad string length %q", data[:colon])
+		}
+		start := colon + 1
+		return string(data[start : start+length]), data[start+length:], nil
+
+	default:
+		return nil, nil, fmt.Errorf("bencode: unexpected byte %q", c)
+	}
+}
+
+// bencodeDictRaw returns key's value in the dictionary at the start of data
+// exactly as encoded, for hashing the bytes a .torrent file holds
+func bencodeDictRaw(data []byte, key string) ([]byte, error) {
+	if len(data) == 0 || data[0] != 'd' {
+		return nil, fmt.Errorf("bencode: not a dictionary")
+	}
+	rest := data[1:]
+	for len(rest) > 0 && rest[0] != 'e' {
+		name, afterKey, err := decodeBencodeValue(rest)
+		if err != nil {
+			return nil, err
+		}
+		if _, rest, err = decodeBencodeValue(afterKey); err != nil {
+			return nil, err
+		}
+		if name == key {
+			return afterKey[:len(afterKey)-len(rest)], nil
+		}
+	}
+	return nil, fmt.Errorf("bencode: no %q key", key)
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Test bencoded values decode to Go types
+func TestDecodeBencode(t *testing.T) {
+	got, err := decodeBencode([]byte("d4:infod6:lengthi42e4:name4:book5:filesl3:abcee4:listli-1e0:ee"))
+	if err != nil {
+		t.Fatalf("decodeBencode failed: %v", err)
+	}
+	want := map[string]interface{}{
+		"info": map[string]interface{}{"length": int64(42), "name": "book", "files": []interface{}{"abc"}},
+		"list": []interface{}{int64(-1), ""},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %#v, got %#v", want, got)
+	}
+}
+
+// Test malformed input is rejected rather than misread
+func TestDecodeBencodeMalformed(t *testing.T) {
+	for _, input := range []string{"", "i42", "l", "d3:key", "5:abc", "di1ei2ee", "i1ei2e", "x"} {
+		if _, err := decodeBencode([]byte(input)); err == nil {
+			t.Errorf("Expected error for %q", input)
+		}
+	}
+}
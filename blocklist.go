@@ -0,0 +1,396 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sourceBlocklistSchedule marks the daemon's periodic blocklist imports
+const sourceBlocklistSchedule = "blocklist-schedule"
+
+// maxBlocklistSize caps how much of one source is read
+const maxBlocklistSize = 32 << 20
+
+var blocklistClient = &http.Client{Timeout: 60 * time.Second}
+
+// blocklistMu serializes changes to the blocklist file within the process
+var blocklistMu sync.Mutex
+
+// blocklistHashPattern matches the info hashes ExtractMagnetHash returns
+var blocklistHashPattern = regexp.MustCompile(`^([a-f0-9]{40}|[a-z0-9]{32})$`)
+
+var blocklistCommand = &Command{
+	Name:    "blocklist",
+	Usage:   "blocklist list | blocklist add <hash>... | blocklist remove <hash>... | blocklist import [source...]",
+	Summary: "Show or change the hashes that are never added, or import them from URLs and files",
+}
+
+func init() {
+	blocklistCommand.Run = runBlocklist
+	registerCommand(blocklistCommand)
+}
+
+// blocklistPath returns where the local blocklist is kept
+func blocklistPath() (string, error) {
+	homeDir, err := getHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, ".magnet-handler", "blocklist.txt"), nil
+}
+
+// parseBlocklistHash returns the lowercase info hash on a blocklist line,
+// ignoring anything after it, or "" for blank lines, comments, and lines
+// that do not start with a hash
+func parseBlocklistHash(line string) string {
+	fields := strings.Fields(line)
+	if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+		return ""
+	}
+	hash := strings.ToLower(fields[0])
+	if !blocklistHashPattern.MatchString(hash) {
+		return ""
+	}
+	return hash
+}
+
+// readBlocklist reads one hash per line. Blank lines and # comments are
+// skipped; other lines without a hash are counted as invalid.
+func readBlocklist(r io.Reader) (hashes []string, invalid int, err error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if hash := parseBlocklistHash(line); hash != "" {
+			hashes = append(hashes, hash)
+		} else {
+			invalid++
+		}
+	}
+	return hashes, invalid, scanner.Err()
+}
+
+// loadBlocklist reads the local blocklist; a missing file is an empty list
+func loadBlocklist() (map[string]bool, error) {
+	path, err := blocklistPath()
+	if err != nil {
+		return nil, err
+	}
+	blocked := make(map[string]bool)
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return blocked, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blocklist: %w", err)
+	}
+	defer f.Close()
+
+	hashes, _, err := readBlocklist(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blocklist: %w", err)
+	}
+	for _, hash := range hashes {
+		blocked[hash] = true
+	}
+	return blocked, nil
+}
+
+// saveBlocklist writes the local blocklist sorted, replacing the file
+// atomically so a concurrent add never sees it half written
+func saveBlocklist(blocked map[string]bool) error {
+	path, err := blocklistPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	hashes := make([]string, 0, len(blocked))
+	for hash := range blocked {
+		hashes = append(hashes, hash)
+	}
+	sort.Strings(hashes)
+
+	var b strings.Builder
+	b.WriteString("# Info hashes magnet-handler never adds, one per line\n")
+	for _, hash := range hashes {
+		b.WriteString(hash + "\n")
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(b.String()), 0600); err != nil {
+		return fmt.Errorf("failed to write blocklist: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write blocklist: %w", err)
+	}
+	return nil
+}
+
+// updateBlocklist loads the blocklist, applies change, and saves it if
+// change reports that it changed anything
+func updateBlocklist(change func(blocked map[string]bool) bool) error {
+	blocklistMu.Lock()
+	defer blocklistMu.Unlock()
+
+	blocked, err := loadBlocklist()
+	if err != nil {
+		return err
+	}
+	if !change(blocked) {
+		return nil
+	}
+	return saveBlocklist(blocked)
+}
+
+// isBlocked reports whether hash is on the local blocklist. A blocklist
+// that cannot be read blocks nothing, with a warning.
+func isBlocked(hash string) bool {
+	blocked, err := loadBlocklist()
+	if err != nil {
+		log.Printf("Warning: %v", err)
+		return false
+	}
+	return blocked[strings.ToLower(hash)]
+}
+
+// fetchBlocklist reads the hashes from a blocklist source: an http(s) URL
+// or a file path
+func fetchBlocklist(source string) ([]string, int, error) {
+	var body io.ReadCloser
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		resp, err := blocklistClient.Get(source)
+		if err != nil {
+			return nil, 0, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, 0, fmt.Errorf("GET returned %s", resp.Status)
+		}
+		body = resp.Body
+	} else {
+		f, err := os.Open(source)
+		if err != nil {
+			return nil, 0, err
+		}
+		body = f
+	}
+	defer body.Close()
+	return readBlocklist(io.LimitReader(body, maxBlocklistSize))
+}
+
+// blocklistImport reports what importing one source did
+type blocklistImport struct {
+	Source  string
+	Read    int   // Hashes the source listed
+	Added   int   // Hashes that were not already blocked
+	Invalid int   // Lines that were not hashes
+	Err     error // Why the source could not be read
+}
+
+// importBlocklists merges the hashes from each source into the local
+// blocklist. A source that fails is reported and the others still merge.
+func importBlocklists(sources []string) ([]blocklistImport, error) {
+	results := make([]blocklistImport, len(sources))
+	fetched := make([][]string, len(sources))
+	for i, source := range sources {
+		results[i].Source = source
+		fetched[i], results[i].Invalid, results[i].Err = fetchBlocklist(source)
+		results[i].Read = len(fetched[i])
+	}
+
+	err := updateBlocklist(func(blocked map[string]bool) bool {
+		changed := false
+		for i, hashes := range fetched {
+			if results[i].Err != nil {
+				continue
+			}
+			for _, hash := range hashes {
+				if !blocked[hash] {
+					blocked[hash] = true
+					results[i].Added++
+					changed = true
+				}
+			}
+		}
+		return changed
+	})
+	return results, err
+}
+
+// logBlocklistImports logs one line per imported source and returns an
+// error naming the sources that failed
+func logBlocklistImports(results []blocklistImport) error {
+	var failed []string
+	for _, result := range results {
+		if result.Err != nil {
+			log.Printf("✗ Failed to import blocklist %s: %v", result.Source, result.Err)
+			failed = append(failed, result.Source)
+			continue
+		}
+		line := fmt.Sprintf("✓ Imported %s from %s (%d new)", plural(result.Read, "hash", "hashes"), result.Source, result.Added)
+		if result.Invalid > 0 {
+			line += fmt.Sprintf("; skipped %s", plural(result.Invalid, "invalid line", "invalid lines"))
+		}
+		log.Print(line)
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to import %s: %s", plural(len(failed), "blocklist", "blocklists"), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// importBlocklistJob runs the daemon's periodic blocklist import
+func (s *apiServer) importBlocklistJob() error {
+	results, err := importBlocklists(s.config.BlocklistSources)
+	if err != nil {
+		return err
+	}
+	return logBlocklistImports(results)
+}
+
+// scheduleBlocklist queues the next blocklist import unless one is already
+// pending (other than the job identified by current). The first import runs
+// as soon as the daemon starts.
+func (s *apiServer) scheduleBlocklist(current string) {
+	if s.config.BlocklistInterval <= 0 || len(s.config.BlocklistSources) == 0 || s.queue.hasScheduled(sourceBlocklistSchedule, current) {
+		return
+	}
+	job := workJob{Kind: jobBlocklist, Source: sourceBlocklistSchedule, CorrelationID: newCorrelationID()}
+	if current != "" {
+		due := time.Now().Add(time.Duration(s.config.BlocklistInterval) * time.Hour)
+		job.NotBefore = due.Format(time.RFC3339)
+	}
+	if _, err := s.queue.Submit(job); err != nil {
+		log.Printf("Warning: Failed to schedule blocklist import: %v", err)
+	}
+}
+
+// runBlocklist implements the blocklist command
+func runBlocklist(config Config, args []string) error {
+	if len(args) == 0 {
+		newCommandFlags(blocklistCommand).Usage()
+		return fmt.Errorf("expected a blocklist subcommand")
+	}
+
+	switch args[0] {
+	case "list":
+		return runBlocklistList(args[1:])
+	case "add":
+		return runBlocklistChange(args[1:], true)
+	case "remove":
+		return runBlocklistChange(args[1:], false)
+	case "import":
+		return runBlocklistImport(config, args[1:])
+	default:
+		return fmt.Errorf("unknown blocklist subcommand %q", args[0])
+	}
+}
+
+// runBlocklistList prints the blocked hashes, sorted
+func runBlocklistList(args []string) error {
+	fs := newCommandFlags(blocklistCommand)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	blocked, err := loadBlocklist()
+	if err != nil {
+		return err
+	}
+	hashes := make([]string, 0, len(blocked))
+	for hash := range blocked {
+		hashes = append(hashes, hash)
+	}
+	sort.Strings(hashes)
+	for _, hash := range hashes {
+		fmt.Println(hash)
+	}
+	log.Printf("%s blocked", plural(len(hashes), "hash", "hashes"))
+	return nil
+}
+
+// runBlocklistChange adds hashes to the blocklist, or removes them
+func runBlocklistChange(args []string, block bool) error {
+	fs := newCommandFlags(blocklistCommand)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("expected at least one hash")
+	}
+
+	var hashes []string
+	for _, arg := range fs.Args() {
+		hash := parseBlocklistHash(arg)
+		if hash == "" {
+			hash = ExtractMagnetHash(arg)
+		}
+		if hash == "" {
+			return fmt.Errorf("%q is not an info hash or magnet URI", arg)
+		}
+		hashes = append(hashes, hash)
+	}
+
+	changed := 0
+	err := updateBlocklist(func(blocked map[string]bool) bool {
+		for _, hash := range hashes {
+			switch {
+			case block && !blocked[hash]:
+				blocked[hash] = true
+				changed++
+			case !block && blocked[hash]:
+				delete(blocked, hash)
+				changed++
+			}
+		}
+		return changed > 0
+	})
+	if err != nil {
+		return err
+	}
+
+	if block {
+		log.Printf("✓ Blocked %s (%d already blocked)", plural(changed, "hash", "hashes"), len(hashes)-changed)
+	} else {
+		log.Printf("✓ Unblocked %s (%d were not blocked)", plural(changed, "hash", "hashes"), len(hashes)-changed)
+	}
+	return nil
+}
+
+// runBlocklistImport merges the given sources, or blocklist_sources, into
+// the local blocklist
+func runBlocklistImport(config Config, args []string) error {
+	fs := newCommandFlags(blocklistCommand)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	sources := fs.Args()
+	if len(sources) == 0 {
+		sources = config.BlocklistSources
+	}
+	if len(sources) == 0 {
+		return fmt.Errorf("no sources given and blocklist_sources is not set")
+	}
+
+	results, err := importBlocklists(sources)
+	if err != nil {
+		return err
+	}
+	return logBlocklistImports(results)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Test sources from URLs and files merge into the local blocklist, skipping
+// comments and lines that are not hashes
+func TestImportBlocklists(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	t.Setenv("HOME", tmpDir)
+
+	hashA := strings.Repeat("a", 40)
+	hashB := strings.Repeat("b", 40)
+	hashC := strings.Repeat("c", 32)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/list.txt" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte("# known bad\n" + strings.ToUpper(hashA) + "  purged 2024\n\nnot-a-hash\n" + hashB + "\n"))
+	}))
+	defer server.Close()
+
+	file := filepath.Join(tmpDir, "local.txt")
+	if err := os.WriteFile(file, []byte(hashB+"\n"+hashC+"\n"), 0600); err != nil {
+		t.Fatalf("Failed to write source: %v", err)
+	}
+
+	results, err := importBlocklists([]string{server.URL + "/list.txt", file, server.URL + "/missing.txt"})
+	if err != nil {
+		t.Fatalf("importBlocklists failed: %v", err)
+	}
+	if r := results[0]; r.Err != nil || r.Read != 2 || r.Added != 2 || r.Invalid != 1 {
+		t.Errorf("Unexpected URL import: %+v", r)
+	}
+	if r := results[1]; r.Err != nil || r.Read != 2 || r.Added != 1 {
+		t.Errorf("Expected only the new hash from the file counted, got %+v", r)
+	}
+	if results[2].Err == nil {
+		t.Error("Expected an error for a missing source")
+	}
+	if err := logBlocklistImports(results); err == nil || !strings.Contains(err.Error(), "missing.txt") {
+		t.Errorf("Expected the failed source named, got %v", err)
+	}
+
+	for _, hash := range []string{hashA, strings.ToUpper(hashB), hashC} {
+		if !isBlocked(hash) {
+			t.Errorf("Expected %s blocked", hash)
+		}
+	}
+	if isBlocked(strings.Repeat("d", 40)) {
+		t.Error("Hashes not on any source should not be blocked")
+	}
+
+	results, err = importBlocklists([]string{file})
+	if err != nil || results[0].Added != 0 {
+		t.Errorf("Importing again should add nothing, got %+v, %v", results, err)
+	}
+}
+
+// Test the blocklist command adds and removes hashes, and blocked magnets
+// are refused before Deluge is contacted
+func TestBlocklistCommand(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	t.Setenv("HOME", tmpDir)
+
+	hash := strings.Repeat("e", 40)
+	uri := "magnet:?xt=urn:btih:" + hash + "&dn=Bad"
+	if err := runBlocklist(Config{}, []string{"add", uri}); err != nil {
+		t.Fatalf("blocklist add failed: %v", err)
+	}
+	if !isBlocked(hash) {
+		t.Fatal("Expected the magnet's hash blocked")
+	}
+
+	config := DefaultConfig()
+	config.JSONPath = filepath.Join(tmpDir, "db.json")
+	config.RemotePath = ""
+	config.DelugeHost = "127.0.0.1"
+	config.DelugePort = "1"
+	if err := AddMagnetToDeluge(uri, config); err == nil || !strings.Contains(err.Error(), "blocklist") {
+		t.Errorf("Expected the blocked magnet refused, got %v", err)
+	}
+	if _, err := os.Stat(config.JSONPath); !os.IsNotExist(err) {
+		t.Error("A blocked magnet should not be recorded")
+	}
+
+	if err := runBlocklist(Config{}, []string{"remove", hash}); err != nil {
+		t.Fatalf("blocklist remove failed: %v", err)
+	}
+	if isBlocked(hash) {
+		t.Error("Expected the hash unblocked")
+	}
+	if err := runBlocklist(Config{}, []string{"add", "nonsense"}); err == nil {
+		t.Error("Expected an error for an argument that is not a hash")
+	}
+	if err := runBlocklist(Config{}, []string{"import"}); err == nil {
+		t.Error("Expected an error without sources")
+	}
+}
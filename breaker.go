@@ -0,0 +1,141 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// errAuthRejected is returned when Deluge refuses the configured password,
+// as opposed to network errors reaching the server
+var errAuthRejected = errors.New("authentication failed")
+
+// defaultAuthFailureLimit is how many rejected logins in a row open the breaker
+const defaultAuthFailureLimit = 3
+
+// AuthBreaker tracks consecutive authentication failures across runs so a
+// wrong password stops hammering Deluge after a few attempts
+type AuthBreaker struct {
+	Failures    int    `json:"failures"`
+	Tripped     bool   `json:"tripped"`
+	TrippedAt   string `json:"tripped_at,omitempty"`
+	Fingerprint string `json:"fingerprint"` // Connection settings the failures apply to
+}
+
+// authBreakerPath returns where breaker state is kept
+func authBreakerPath() (string, error) {
+	homeDir, err := getHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, ".magnet-handler", "auth-breaker.json"), nil
+}
+
+// authFingerprint identifies the connection settings, so changing the host
+// or password in the config closes the breaker
+func authFingerprint(config Config) string {
+	sum := sha256.Sum256([]byte(config.DelugeHost + "\x00" + config.DelugePort + "\x00" + config.DelugePassword))
+	return fmt.Sprintf("%x", sum[:8])
+}
+
+// authFailureLimit returns the configured failure limit or the default
+func authFailureLimit(config Config) int {
+	if config.AuthFailureLimit > 0 {
+		return config.AuthFailureLimit
+	}
+	return defaultAuthFailureLimit
+}
+
+// loadAuthBreaker reads breaker state for the current connection settings,
+// starting fresh when the settings have changed since the failures
+func loadAuthBreaker(config Config) *AuthBreaker {
+	breaker := &AuthBreaker{Fingerprint: authFingerprint(config)}
+	path, err := authBreakerPath()
+	if err != nil {
+		return breaker
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return breaker
+	}
+
+	var saved AuthBreaker
+	if err := json.Unmarshal(data, &saved); err != nil || saved.Fingerprint != breaker.Fingerprint {
+		return breaker
+	}
+	return &saved
+}
+
+// save writes breaker state, removing the file once it is back to normal
+func (b *AuthBreaker) save() error {
+	path, err := authBreakerPath()
+	if err != nil {
+		return err
+	}
+	if b.Failures == 0 && !b.Tripped {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// AuthBreakerOpen reports whether adds should skip Deluge and go straight to
+// the retry queue because the password keeps being rejected
+func AuthBreakerOpen(config Config) bool {
+	return loadAuthBreaker(config).Tripped
+}
+
+// ResetAuthBreaker clears recorded authentication failures
+func ResetAuthBreaker() error {
+	breaker := &AuthBreaker{}
+	return breaker.save()
+}
+
+// authenticate logs into Deluge, recording the outcome in the breaker
+func authenticate(client *DelugeClient, config Config) error {
+	logger := config.logger()
+	breaker := loadAuthBreaker(config)
+
+	err := client.Authenticate()
+	if err == nil {
+		if breaker.Failures > 0 || breaker.Tripped {
+			breaker.Failures, breaker.Tripped, breaker.TrippedAt = 0, false, ""
+			if err := breaker.save(); err != nil {
+				logger.Printf("Warning: Failed to reset auth breaker: %v", err)
+			}
+		}
+		return nil
+	}
+	if !errors.Is(err, errAuthRejected) {
+		return err
+	}
+
+	breaker.Failures++
+	if breaker.Failures >= authFailureLimit(config) && !breaker.Tripped {
+		breaker.Tripped = true
+		breaker.TrippedAt = time.Now().Format(time.RFC3339)
+		Notify(NotifyCritical, "Deluge rejected the password",
+			fmt.Sprintf("Login to %s:%s failed %d times in a row - check your Deluge password.\n"+
+				"New magnets are queued for retry without contacting Deluge until the\n"+
+				"password changes or you run: magnet-handler reset-auth",
+				config.DelugeHost, config.DelugePort, breaker.Failures))
+	}
+	if saveErr := breaker.save(); saveErr != nil {
+		logger.Printf("Warning: Failed to record auth failure: %v", saveErr)
+	}
+	return err
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+// Test the auth breaker trips after repeated rejections and resets on config change
+func TestAuthBreaker(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	t.Setenv("HOME", tmpDir)
+
+	accept := false
+	logins := 0
+	client := fakeDeluge(t, func(method string, params []interface{}) (interface{}, interface{}) {
+		if method == "auth.login" {
+			logins++
+			return accept, nil
+		}
+		return nil, nil
+	})
+
+	config := DefaultConfig()
+	config.AuthFailureLimit = 2
+
+	for i := 0; i < 2; i++ {
+		if AuthBreakerOpen(config) {
+			t.Fatalf("Breaker should not be open after %d failures", i)
+		}
+		if err := authenticate(client, config); err != errAuthRejected {
+			t.Fatalf("Expected errAuthRejected, got %v", err)
+		}
+	}
+	if !AuthBreakerOpen(config) {
+		t.Fatal("Breaker should open after reaching the failure limit")
+	}
+
+	changed := config
+	changed.DelugePassword = "new-password"
+	if AuthBreakerOpen(changed) {
+		t.Error("Changing the password should close the breaker")
+	}
+
+	if err := ResetAuthBreaker(); err != nil {
+		t.Fatalf("ResetAuthBreaker failed: %v", err)
+	}
+	if AuthBreakerOpen(config) {
+		t.Error("Breaker should be closed after a manual reset")
+	}
+
+	// A successful login clears partial failures
+	authenticate(client, config)
+	accept = true
+	if err := authenticate(client, config); err != nil {
+		t.Fatalf("authenticate failed: %v", err)
+	}
+	if breaker := loadAuthBreaker(config); breaker.Failures != 0 {
+		t.Errorf("Successful login should clear failures, got %d", breaker.Failures)
+	}
+	if logins != 4 {
+		t.Errorf("Expected 4 logins, got %d", logins)
+	}
+}
+
+// Test AddMagnetToDeluge queues without contacting Deluge while the breaker is open
+func TestAddMagnetBreakerOpen(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	t.Setenv("HOME", tmpDir)
+
+	config := DefaultConfig()
+	config.DelugeHost = "192.0.2.1" // Unroutable, must never be contacted
+	config.RemotePath = ""
+	breaker := &AuthBreaker{Failures: 3, Tripped: true, Fingerprint: authFingerprint(config)}
+	if err := breaker.save(); err != nil {
+		t.Fatalf("Failed to save breaker: %v", err)
+	}
+
+	uri := "magnet:?xt=urn:btih:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa&dn=Queued"
+	if err := AddMagnetToDeluge(uri, config); err == nil {
+		t.Error("AddMagnetToDeluge should report the open breaker")
+	}
+
+	db, err := LoadJSONDatabase(config.JSONPath)
+	if err != nil {
+		t.Fatalf("Failed to load database: %v", err)
+	}
+	if _, ok := db.Retry["aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"]; !ok {
+		t.Error("Magnet should be queued for retry")
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Browsers that register --browser can configure
+const (
+	BrowserFirefox = "firefox"
+	BrowserChrome  = "chrome"
+	BrowserEdge    = "edge"
+)
+
+// firefoxUseHelperApp is the handlers.json action for "open with an application"
+const firefoxUseHelperApp = 2
+
+// ParseBrowsers splits a comma-separated --browser value and validates each name
+func ParseBrowsers(value string) ([]string, error) {
+	var browsers []string
+	for _, name := range strings.Split(value, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch name {
+		case "":
+			continue
+		case BrowserFirefox, BrowserChrome, BrowserEdge:
+			browsers = append(browsers, name)
+		default:
+			return nil, fmt.Errorf("unknown browser %q (expected firefox, chrome, or edge)", name)
+		}
+	}
+	return browsers, nil
+}
+
+// chromiumPolicyNames lists every policy chromiumPolicy can set, so
+// unregistering removes them all
+var chromiumPolicyNames = []string{"AutoLaunchProtocolsFromOrigins", "ExternalProtocolDialogShowAlwaysOpenCheckbox"}
+
+// ParseBrowserOrigins splits a comma-separated --origins value and validates
+// each origin. A wildcard is refused: it would let any website add torrents.
+func ParseBrowserOrigins(value string) ([]string, error) {
+	var origins []string
+	for _, origin := range strings.Split(value, ",") {
+		origin = strings.TrimSpace(origin)
+		switch {
+		case origin == "":
+			continue
+		case origin == "*" || strings.HasSuffix(origin, "://*"):
+			return nil, fmt.Errorf("%q would let any website add torrents; list the sites instead", origin)
+		case strings.ContainsAny(origin, " \t\"'"):
+			return nil, fmt.Errorf("invalid origin %q", origin)
+		}
+		origins = append(origins, origin)
+	}
+	return origins, nil
+}
+
+// chromiumPolicy returns the Chrome/Edge policies for magnet links: an
+// "always allow" checkbox on the external protocol prompt and, for the
+// given origins only, launching the handler without the prompt
+func chromiumPolicy(origins []string) map[string]interface{} {
+	policy := map[string]interface{}{
+		"ExternalProtocolDialogShowAlwaysOpenCheckbox": true,
+	}
+	if len(origins) > 0 {
+		policy["AutoLaunchProtocolsFromOrigins"] = []map[string][]string{
+			{"protocols": {"magnet"}, "allowed_origins": origins},
+		}
+	}
+	return policy
+}
+
+// RegisterBrowser configures a browser to hand magnet links to exePath
+// without asking every time. Chrome and Edge skip the prompt only on
+// origins; elsewhere they ask, with an option to always allow the site.
+func RegisterBrowser(browser, exePath string, origins []string) error {
+	if browser == BrowserFirefox {
+		return registerFirefox(exePath)
+	}
+	if len(origins) == 0 {
+		fmt.Printf("%s will still ask before opening magnet links; set browser_origins or --origins to skip it for trusted sites\n", browser)
+	}
+	return registerChromiumPolicy(browser, chromiumPolicy(origins))
+}
+
+// UnregisterBrowser removes what RegisterBrowser configured
+func UnregisterBrowser(browser string) error {
+	if browser == BrowserFirefox {
+		return unregisterFirefox()
+	}
+	return unregisterChromiumPolicy(browser)
+}
+
+// firefoxProfiles returns every Firefox profile directory under the known
+// install locations
+func firefoxProfiles() ([]string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	var profiles []string
+	for _, root := range firefoxProfileRoots(homeDir) {
+		matches, _ := filepath.Glob(filepath.Join(root, "*", "prefs.js"))
+		for _, prefs := range matches {
+			profiles = append(profiles, filepath.Dir(prefs))
+		}
+	}
+	return profiles, nil
+}
+
+// registerFirefox sets magnet links to open with exePath in every profile
+func registerFirefox(exePath string) error {
+	profiles, err := firefoxProfiles()
+	if err != nil {
+		return err
+	}
+	if len(profiles) == 0 {
+		return fmt.Errorf("no Firefox profiles found (start Firefox once, then register again)")
+	}
+	for _, profile := range profiles {
+		path := filepath.Join(profile, "handlers.json")
+		if err := updateFirefoxHandlers(path, exePath); err != nil {
+			return fmt.Errorf("failed to update %s: %w", path, err)
+		}
+		fmt.Printf("✓ Firefox profile configured: %s\n", profile)
+	}
+	fmt.Println("Close Firefox before registering; it rewrites handlers.json on exit")
+	return nil
+}
+
+// unregisterFirefox removes the magnet handler from every profile
+func unregisterFirefox() error {
+	profiles, err := firefoxProfiles()
+	if err != nil {
+		return err
+	}
+	for _, profile := range profiles {
+		path := filepath.Join(profile, "handlers.json")
+		if err := updateFirefoxHandlers(path, ""); err != nil {
+			return fmt.Errorf("failed to update %s: %w", path, err)
+		}
+	}
+	fmt.Println("✓ Firefox magnet handler removed")
+	return nil
+}
+
+// updateFirefoxHandlers points the magnet scheme in a handlers.json file at
+// exePath, or removes it when exePath is empty, keeping every other setting
+func updateFirefoxHandlers(path, exePath string) error {
+	handlers := map[string]interface{}{}
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &handlers); err != nil {
+			return fmt.Errorf("failed to parse handlers: %w", err)
+		}
+	} else if exePath == "" {
+		return nil
+	}
+
+	schemes, _ := handlers["schemes"].(map[string]interface{})
+	if schemes == nil {
+		schemes = map[string]interface{}{}
+	}
+	if exePath == "" {
+		delete(schemes, "magnet")
+	} else {
+		schemes["magnet"] = map[string]interface{}{
+			"action": firefoxUseHelperApp,
+			"ask":    false,
+			"handlers": []map[string]string{
+				{"name": "Magnet Handler", "path": exePath},
+			},
+		}
+	}
+	handlers["schemes"] = schemes
+
+	out, err := json.Marshal(handlers)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, out, 0644)
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// Test ParseBrowsers accepts comma-separated names and rejects unknown ones
+func TestParseBrowsers(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    []string
+		wantErr bool
+	}{
+		{"", nil, false},
+		{"firefox", []string{BrowserFirefox}, false},
+		{"Chrome, edge", []string{BrowserChrome, BrowserEdge}, false},
+		{"safari", nil, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseBrowsers(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseBrowsers(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("ParseBrowsers(%q) = %v, want %v", tt.value, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("ParseBrowsers(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		}
+	}
+}
+
+// Test updateFirefoxHandlers sets and removes the magnet scheme, keeping other settings
+func TestUpdateFirefoxHandlers(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	path := filepath.Join(tmpDir, "handlers.json")
+	existing := `{"defaultHandlersVersion":{"en-US":4},"schemes":{"mailto":{"action":4}}}`
+	if err := os.WriteFile(path, []byte(existing), 0644); err != nil {
+		t.Fatalf("Failed to write handlers: %v", err)
+	}
+
+	if err := updateFirefoxHandlers(path, "/usr/bin/magnet-handler"); err != nil {
+		t.Fatalf("updateFirefoxHandlers failed: %v", err)
+	}
+
+	var handlers struct {
+		Version map[string]int `json:"defaultHandlersVersion"`
+		Schemes map[string]struct {
+			Action   int  `json:"action"`
+			Ask      bool `json:"ask"`
+			Handlers []struct {
+				Path string `json:"path"`
+			} `json:"handlers"`
+		} `json:"schemes"`
+	}
+	data, _ := os.ReadFile(path)
+	if err := json.Unmarshal(data, &handlers); err != nil {
+		t.Fatalf("Failed to parse handlers: %v", err)
+	}
+	magnet, ok := handlers.Schemes["magnet"]
+	if !ok {
+		t.Fatal("magnet scheme should be configured")
+	}
+	if magnet.Action != firefoxUseHelperApp || magnet.Ask {
+		t.Errorf("magnet scheme should open the helper without asking, got %+v", magnet)
+	}
+	if len(magnet.Handlers) != 1 || magnet.Handlers[0].Path != "/usr/bin/magnet-handler" {
+		t.Errorf("Unexpected handlers: %+v", magnet.Handlers)
+	}
+	if _, ok := handlers.Schemes["mailto"]; !ok || handlers.Version["en-US"] != 4 {
+		t.Error("Existing settings should be preserved")
+	}
+
+	if err := updateFirefoxHandlers(path, ""); err != nil {
+		t.Fatalf("updateFirefoxHandlers removal failed: %v", err)
+	}
+	handlers.Schemes = nil
+	data, _ = os.ReadFile(path)
+	json.Unmarshal(data, &handlers)
+	if _, ok := handlers.Schemes["magnet"]; ok {
+		t.Error("magnet scheme should be removed")
+	}
+	if _, ok := handlers.Schemes["mailto"]; !ok {
+		t.Error("Other schemes should survive removal")
+	}
+}
+
+// Test ParseBrowserOrigins accepts listed sites and refuses wildcards
+func TestParseBrowserOrigins(t *testing.T) {
+	origins, err := ParseBrowserOrigins(" https://tracker.example, [*.]books.example ,")
+	if err != nil || !reflect.DeepEqual(origins, []string{"https://tracker.example", "[*.]books.example"}) {
+		t.Errorf("Unexpected origins %v, %v", origins, err)
+	}
+	if origins, err := ParseBrowserOrigins(""); err != nil || origins != nil {
+		t.Errorf("Expected no origins, got %v, %v", origins, err)
+	}
+	for _, value := range []string{"*", "https://tracker.example,*", "https://*", "bad origin"} {
+		if _, err := ParseBrowserOrigins(value); err == nil {
+			t.Errorf("Expected %q rejected", value)
+		}
+	}
+}
+
+// Test registerFirefox configures every profile and fails without any
+func TestRegisterFirefox(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	t.Setenv("HOME", tmpDir)
+	t.Setenv("APPDATA", filepath.Join(tmpDir, "AppData"))
+
+	if err := RegisterBrowser(BrowserFirefox, "/usr/bin/magnet-handler", nil); err == nil {
+		t.Error("Expected an error when no profiles exist")
+	}
+
+	roots := firefoxProfileRoots(tmpDir)
+	profile := filepath.Join(roots[0], "abcd.default-release")
+	if err := os.MkdirAll(profile, 0755); err != nil {
+		t.Fatalf("Failed to create profile: %v", err)
+	}
+	os.WriteFile(filepath.Join(profile, "prefs.js"), []byte("// prefs\n"), 0644)
+
+	if err := RegisterBrowser(BrowserFirefox, "/usr/bin/magnet-handler", nil); err != nil {
+		t.Fatalf("RegisterBrowser failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(profile, "handlers.json")); err != nil {
+		t.Errorf("handlers.json should be written: %v", err)
+	}
+}
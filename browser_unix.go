@@ -0,0 +1,106 @@
+//go:build !windows
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// linuxPolicyDirs lists the managed policy directories each browser reads on Linux
+var linuxPolicyDirs = map[string][]string{
+	BrowserChrome: {"/etc/opt/chrome/policies/managed", "/etc/chromium/policies/managed"},
+	BrowserEdge:   {"/etc/opt/edge/policies/managed"},
+}
+
+// macPolicyDomains maps each browser to its macOS preferences domain
+var macPolicyDomains = map[string]string{
+	BrowserChrome: "com.google.Chrome",
+	BrowserEdge:   "com.microsoft.Edge",
+}
+
+// policyFileName is the file written into each managed policy directory
+const policyFileName = "magnet-handler.json"
+
+// firefoxProfileRoots returns the directories that hold Firefox profiles
+func firefoxProfileRoots(homeDir string) []string {
+	if runtime.GOOS == "darwin" {
+		return []string{filepath.Join(homeDir, "Library", "Application Support", "Firefox", "Profiles")}
+	}
+	return []string{
+		filepath.Join(homeDir, ".mozilla", "firefox"),
+		filepath.Join(homeDir, "snap", "firefox", "common", ".mozilla", "firefox"),
+		filepath.Join(homeDir, ".var", "app", "org.mozilla.firefox", ".mozilla", "firefox"),
+	}
+}
+
+// registerChromiumPolicy installs the magnet link policy for Chrome or Edge.
+// Managed policy directories need root, so when they are not writable the
+// policy is staged in ~/.magnet-handler/policies with the command to install it.
+func registerChromiumPolicy(browser string, policy map[string]interface{}) error {
+	data, err := json.MarshalIndent(policy, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if runtime.GOOS == "darwin" {
+		fmt.Printf("To let %s open magnet links without asking, deploy this policy\n", browser)
+		fmt.Printf("to the %s domain with a configuration profile or MDM:\n\n", macPolicyDomains[browser])
+		fmt.Println(string(data))
+		return nil
+	}
+
+	var staged []string
+	for _, dir := range linuxPolicyDirs[browser] {
+		path := filepath.Join(dir, policyFileName)
+		if err := os.MkdirAll(dir, 0755); err == nil {
+			if err := os.WriteFile(path, data, 0644); err == nil {
+				fmt.Printf("✓ Installed %s policy: %s\n", browser, path)
+				continue
+			}
+		}
+		staged = append(staged, dir)
+	}
+	if len(staged) == 0 {
+		return nil
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return err
+	}
+	stagePath := filepath.Join(homeDir, ".magnet-handler", "policies", browser+".json")
+	if err := os.MkdirAll(filepath.Dir(stagePath), 0755); err != nil {
+		return fmt.Errorf("failed to create policy directory: %w", err)
+	}
+	if err := os.WriteFile(stagePath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write policy: %w", err)
+	}
+	fmt.Printf("Saved %s policy to %s; install it with:\n", browser, stagePath)
+	for _, dir := range staged {
+		fmt.Printf("  sudo install -D -m 644 %s %s\n", stagePath, filepath.Join(dir, policyFileName))
+	}
+	return nil
+}
+
+// unregisterChromiumPolicy removes the policy files registerChromiumPolicy wrote
+func unregisterChromiumPolicy(browser string) error {
+	if runtime.GOOS == "darwin" {
+		fmt.Printf("Remove the magnet policy from the %s configuration profile\n", macPolicyDomains[browser])
+		return nil
+	}
+	for _, dir := range linuxPolicyDirs[browser] {
+		path := filepath.Join(dir, policyFileName)
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			fmt.Printf("Remove %s policy with: sudo rm %s\n", browser, path)
+		}
+	}
+	if homeDir, err := os.UserHomeDir(); err == nil {
+		os.Remove(filepath.Join(homeDir, ".magnet-handler", "policies", browser+".json"))
+	}
+	fmt.Printf("✓ %s policy removed\n", browser)
+	return nil
+}
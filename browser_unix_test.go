@@ -0,0 +1,80 @@
+//go:build !windows
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// Test Chrome policy is installed where writable and staged otherwise
+func TestRegisterChromiumPolicyLinux(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("Skipping Linux-specific test on non-Linux platform")
+	}
+
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	t.Setenv("HOME", tmpDir)
+
+	// A regular file where the directory should be makes the second location unwritable
+	blocked := filepath.Join(tmpDir, "blocked")
+	os.WriteFile(blocked, nil, 0644)
+	writable := filepath.Join(tmpDir, "etc", "chrome", "managed")
+
+	original := linuxPolicyDirs[BrowserChrome]
+	linuxPolicyDirs[BrowserChrome] = []string{writable, filepath.Join(blocked, "managed")}
+	defer func() { linuxPolicyDirs[BrowserChrome] = original }()
+
+	if err := RegisterBrowser(BrowserChrome, "/usr/bin/magnet-handler", []string{"https://tracker.example"}); err != nil {
+		t.Fatalf("RegisterBrowser failed: %v", err)
+	}
+
+	type chromePolicy struct {
+		AutoLaunch []struct {
+			Protocols      []string `json:"protocols"`
+			AllowedOrigins []string `json:"allowed_origins"`
+		} `json:"AutoLaunchProtocolsFromOrigins"`
+		AlwaysOpen bool `json:"ExternalProtocolDialogShowAlwaysOpenCheckbox"`
+	}
+	var policy chromePolicy
+	data, err := os.ReadFile(filepath.Join(writable, policyFileName))
+	if err != nil {
+		t.Fatalf("Policy should be installed: %v", err)
+	}
+	if err := json.Unmarshal(data, &policy); err != nil {
+		t.Fatalf("Failed to parse policy: %v", err)
+	}
+	if len(policy.AutoLaunch) != 1 || policy.AutoLaunch[0].Protocols[0] != "magnet" ||
+		len(policy.AutoLaunch[0].AllowedOrigins) != 1 || policy.AutoLaunch[0].AllowedOrigins[0] != "https://tracker.example" {
+		t.Errorf("Unexpected policy: %s", data)
+	}
+
+	// Without origins only the "always allow" checkbox is installed
+	if err := RegisterBrowser(BrowserChrome, "/usr/bin/magnet-handler", nil); err != nil {
+		t.Fatalf("RegisterBrowser failed: %v", err)
+	}
+	policy = chromePolicy{}
+	data, _ = os.ReadFile(filepath.Join(writable, policyFileName))
+	if err := json.Unmarshal(data, &policy); err != nil || len(policy.AutoLaunch) != 0 || !policy.AlwaysOpen {
+		t.Errorf("Expected no auto-launch policy without origins, got %s", data)
+	}
+
+	staged := filepath.Join(tmpDir, ".magnet-handler", "policies", "chrome.json")
+	if _, err := os.Stat(staged); err != nil {
+		t.Errorf("Unwritable location should leave a staged policy: %v", err)
+	}
+
+	if err := UnregisterBrowser(BrowserChrome); err != nil {
+		t.Fatalf("UnregisterBrowser failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(writable, policyFileName)); !os.IsNotExist(err) {
+		t.Error("Policy should be removed")
+	}
+}
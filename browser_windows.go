@@ -0,0 +1,86 @@
+//go:build windows
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+// windowsPolicyKeys maps each browser to its per-user policy registry key
+var windowsPolicyKeys = map[string]string{
+	BrowserChrome: `Software\Policies\Google\Chrome`,
+	BrowserEdge:   `Software\Policies\Microsoft\Edge`,
+}
+
+// firefoxProfileRoots returns the directories that hold Firefox profiles
+func firefoxProfileRoots(homeDir string) []string {
+	appData := os.Getenv("APPDATA")
+	if appData == "" {
+		appData = filepath.Join(homeDir, "AppData", "Roaming")
+	}
+	return []string{filepath.Join(appData, "Mozilla", "Firefox", "Profiles")}
+}
+
+// registerChromiumPolicy writes the magnet link policy for Chrome or Edge
+// under HKEY_CURRENT_USER, removing policies it no longer sets. List and
+// dictionary policies are stored as JSON.
+func registerChromiumPolicy(browser string, policy map[string]interface{}) error {
+	k, _, err := registry.CreateKey(registry.CURRENT_USER, windowsPolicyKeys[browser], registry.SET_VALUE)
+	if err != nil {
+		return fmt.Errorf("failed to open %s policy key (run as administrator): %w", browser, err)
+	}
+	defer k.Close()
+
+	for _, name := range chromiumPolicyNames {
+		if _, ok := policy[name]; !ok {
+			if err := k.DeleteValue(name); err != nil && err != registry.ErrNotExist {
+				return err
+			}
+		}
+	}
+	for name, value := range policy {
+		if enabled, ok := value.(bool); ok {
+			var dword uint32
+			if enabled {
+				dword = 1
+			}
+			if err := k.SetDWordValue(name, dword); err != nil {
+				return err
+			}
+			continue
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		if err := k.SetStringValue(name, string(data)); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("✓ Installed %s policy: HKCU\\%s\n", browser, windowsPolicyKeys[browser])
+	return nil
+}
+
+// unregisterChromiumPolicy removes the values registerChromiumPolicy wrote
+func unregisterChromiumPolicy(browser string) error {
+	k, err := registry.OpenKey(registry.CURRENT_USER, windowsPolicyKeys[browser], registry.SET_VALUE)
+	if err != nil {
+		fmt.Printf("%s policy was not found (may already be unregistered)\n", browser)
+		return nil
+	}
+	defer k.Close()
+
+	for _, name := range chromiumPolicyNames {
+		if err := k.DeleteValue(name); err != nil && err != registry.ErrNotExist {
+			return err
+		}
+	}
+	fmt.Printf("✓ %s policy removed\n", browser)
+	return nil
+}
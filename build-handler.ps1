@@ -81,6 +81,6 @@ try {
 }
 
 Write-Host "`nNext steps:" -ForegroundColor Yellow
-Write-Host "1. Close and reopen PowerShell, then run: magnet-handler.exe --register" -ForegroundColor White
+Write-Host "1. Close and reopen PowerShell, then run: magnet-handler.exe register" -ForegroundColor White
 Write-Host "2. Click a magnet link in Chrome to test" -ForegroundColor White
 Write-Host "`nThis replaces the Python version with a secure compiled executable." -ForegroundColor Green
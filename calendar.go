@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// icsTimeFormat is the UTC date-time form used in iCalendar files
+const icsTimeFormat = "20060102T150405Z"
+
+// completedEntries returns entries with a completion time, most recent first.
+// Removed entries are included: their download finished before removal.
+func completedEntries(db *MagnetDatabase) []MagnetEntry {
+	var entries []MagnetEntry
+	for _, section := range []map[string]MagnetEntry{db.Added, db.Removed} {
+		for _, entry := range section {
+			if _, err := time.Parse(time.RFC3339, entry.CompletedAt); err == nil {
+				entries = append(entries, entry)
+			}
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].CompletedAt != entries[j].CompletedAt {
+			return entries[i].CompletedAt > entries[j].CompletedAt
+		}
+		return entries[i].Hash < entries[j].Hash
+	})
+	return entries
+}
+
+// icsEscape escapes a value for an iCalendar text property
+func icsEscape(value string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(value)
+}
+
+// icsLine writes one content line, folding it at 75 octets without
+// splitting a UTF-8 sequence
+func icsLine(w *bufio.Writer, line string) {
+	for len(line) > 75 {
+		cut := 75
+		for line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		w.WriteString(line[:cut] + "\r\n")
+		line = " " + line[cut:]
+	}
+	w.WriteString(line + "\r\n")
+}
+
+// WriteCalendar writes completed downloads as an iCalendar feed with one
+// event per completion, so they show up in calendar apps
+func WriteCalendar(w io.Writer, db *MagnetDatabase, config Config) error {
+	bw := bufio.NewWriter(w)
+	icsLine(bw, "BEGIN:VCALENDAR")
+	icsLine(bw, "VERSION:2.0")
+	icsLine(bw, "PRODID:-//magnet-handler//Completions//EN")
+	icsLine(bw, "CALSCALE:GREGORIAN")
+	icsLine(bw, "X-WR-CALNAME:Magnet Handler downloads")
+
+	for _, entry := range completedEntries(db) {
+		completed, _ := time.Parse(time.RFC3339, entry.CompletedAt)
+		stamp := completed.UTC().Format(icsTimeFormat)
+		uid := entry.UUID
+		if uid == "" {
+			uid = entry.Hash
+		}
+		title := entry.Title
+		if title == "" {
+			title = entry.TorrentName
+		}
+
+		details := []string{"Label: " + entryLabel(entry, config)}
+		if entry.Verified != nil {
+			if *entry.Verified {
+				details = append(details, "Verified")
+			} else {
+				details = append(details, "Failed verification")
+			}
+		}
+		if entry.SortedTo != "" {
+			details = append(details, "Sorted to: "+entry.SortedTo)
+		}
+		details = append(details, "Hash: "+entry.Hash)
+
+		icsLine(bw, "BEGIN:VEVENT")
+		icsLine(bw, "UID:"+icsEscape(uid)+"@magnet-handler")
+		icsLine(bw, "DTSTAMP:"+stamp)
+		icsLine(bw, "DTSTART:"+stamp)
+		icsLine(bw, "SUMMARY:"+icsEscape("Ready: "+title))
+		icsLine(bw, "DESCRIPTION:"+icsEscape(strings.Join(details, "\n")))
+		if label := entryLabel(entry, config); label != "" {
+			icsLine(bw, "CATEGORIES:"+icsEscape(label))
+		}
+		icsLine(bw, "TRANSP:TRANSPARENT")
+		icsLine(bw, "END:VEVENT")
+	}
+
+	icsLine(bw, "END:VCALENDAR")
+	return bw.Flush()
+}
+
+// handleCalendar serves the completion feed. Calendar apps cannot send a
+// bearer token, so the feed is authorized by calendar_secret in the URL
+// instead: /calendar.ics?secret=...
+func (s *apiServer) handleCalendar(w http.ResponseWriter, r *http.Request) {
+	if s.config.CalendarSecret == "" {
+		writeError(w, http.StatusNotFound, fmt.Errorf("the calendar feed is disabled: set calendar_secret in the config"))
+		return
+	}
+	secret := r.URL.Query().Get("secret")
+	if subtle.ConstantTimeCompare([]byte(secret), []byte(s.config.CalendarSecret)) != 1 {
+		writeError(w, http.StatusUnauthorized, fmt.Errorf("invalid or missing secret parameter"))
+		return
+	}
+
+	db, err := LoadJSONDatabase(s.config.JSONPath)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("failed to load database: %w", err))
+		return
+	}
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	WriteCalendar(w, db, s.config)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// Test WriteCalendar emits one event per completed download
+func TestWriteCalendar(t *testing.T) {
+	verified := true
+	db := &MagnetDatabase{
+		Added: map[string]MagnetEntry{
+			"hash1": {UUID: "uuid-1", Hash: "hash1", Title: "Book, Part 1; Unabridged", CompletedAt: "2024-03-01T10:00:00-05:00", Label: "audiobooks", Verified: &verified},
+			"hash2": {Hash: "hash2", Title: "Still Downloading"},
+		},
+		Removed: map[string]MagnetEntry{
+			"hash3": {Hash: "hash3", TorrentName: "Removed.Book", CompletedAt: "2024-02-01T00:00:00Z"},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteCalendar(&buf, db, Config{DelugeLabel: "books"}); err != nil {
+		t.Fatalf("WriteCalendar failed: %v", err)
+	}
+	output := buf.String()
+
+	if !strings.HasPrefix(output, "BEGIN:VCALENDAR\r\n") || !strings.HasSuffix(output, "END:VCALENDAR\r\n") {
+		t.Errorf("Calendar not wrapped in VCALENDAR:\n%s", output)
+	}
+	if got := strings.Count(output, "BEGIN:VEVENT"); got != 2 {
+		t.Errorf("Expected 2 events, got %d", got)
+	}
+	for _, want := range []string{
+		"UID:uuid-1@magnet-handler\r\n",
+		"DTSTART:20240301T150000Z\r\n",
+		`SUMMARY:Ready: Book\, Part 1\; Unabridged` + "\r\n",
+		"CATEGORIES:audiobooks\r\n",
+		"UID:hash3@magnet-handler\r\n",
+		"SUMMARY:Ready: Removed.Book\r\n",
+		"CATEGORIES:books\r\n",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected %q in calendar:\n%s", want, output)
+		}
+	}
+	if strings.Index(output, "uuid-1") > strings.Index(output, "hash3@") {
+		t.Error("Expected the most recent completion first")
+	}
+}
+
+// Test icsLine folds long lines without splitting characters
+func TestICSLineFolding(t *testing.T) {
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+	line := "SUMMARY:" + strings.Repeat("é", 60)
+	icsLine(w, line)
+	w.Flush()
+
+	parts := strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n")
+	if len(parts) < 2 {
+		t.Fatalf("Expected folded line, got %q", buf.String())
+	}
+	unfolded := parts[0]
+	for _, part := range parts {
+		if len(part) > 75 {
+			t.Errorf("Line longer than 75 octets: %q", part)
+		}
+	}
+	for _, part := range parts[1:] {
+		if !strings.HasPrefix(part, " ") {
+			t.Errorf("Continuation line without leading space: %q", part)
+		}
+		unfolded += part[1:]
+	}
+	if unfolded != line {
+		t.Errorf("Unfolded line = %q, want %q", unfolded, line)
+	}
+}
+
+// Test the calendar feed requires calendar_secret instead of a bearer token
+func TestHandleCalendar(t *testing.T) {
+	server, _ := newConfiguredAPIServer(t, func(config *Config) {
+		config.CalendarSecret = "cal-secret"
+	})
+	defer server.Close()
+
+	tests := []struct {
+		path string
+		want int
+	}{
+		{"/calendar.ics?secret=cal-secret", http.StatusOK},
+		{"/calendar.ics?secret=wrong", http.StatusUnauthorized},
+		{"/calendar.ics", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		resp, err := http.Get(server.URL + tt.path)
+		if err != nil {
+			t.Fatalf("GET %s failed: %v", tt.path, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != tt.want {
+			t.Errorf("GET %s = %d, want %d", tt.path, resp.StatusCode, tt.want)
+		}
+		if tt.want == http.StatusOK && !strings.HasPrefix(string(body), "BEGIN:VCALENDAR") {
+			t.Errorf("Expected a calendar, got %q", body)
+		}
+	}
+
+	disabled, _ := newTestAPIServer(t)
+	defer disabled.Close()
+	resp, err := http.Get(disabled.URL + "/calendar.ics?secret=")
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 with no calendar_secret, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// defaultPruneAge is how many days removed entries are kept by prune when
+// --older-than is not given
+const defaultPruneAge = 365
+
+// databaseCapWarnings reports each soft cap (max_entries, max_database_mb)
+// the database at path is over
+func databaseCapWarnings(path string, db *MagnetDatabase, config *Config) []string {
+	if config == nil {
+		return nil
+	}
+	var warnings []string
+	if config.MaxEntries > 0 {
+		if total := len(db.Added) + len(db.Retry) + len(db.Removed); total > config.MaxEntries {
+			warnings = append(warnings, fmt.Sprintf("database holds %s, over max_entries (%d)",
+				plural(total, "entry", "entries"), config.MaxEntries))
+		}
+	}
+	if config.MaxDatabaseMB > 0 {
+		if info, err := os.Stat(path); err == nil && info.Size() > int64(config.MaxDatabaseMB)<<20 {
+			warnings = append(warnings, fmt.Sprintf("%s is %.1f MiB, over max_database_mb (%d)",
+				path, float64(info.Size())/(1<<20), config.MaxDatabaseMB))
+		}
+	}
+	return warnings
+}
+
+// warnDatabaseCaps logs a warning for each soft cap the database is over,
+// with the prune command that would bring it back under
+func warnDatabaseCaps(path string, db *MagnetDatabase, config *Config) {
+	logger := config.logger()
+	warnings := databaseCapWarnings(path, db, config)
+	for _, warning := range warnings {
+		logger.Printf("⚠ %s; every save and merge slows down as it grows", warning)
+	}
+	if len(warnings) > 0 {
+		logger.Printf("  To archive and drop removed entries older than %d days: magnet-handler prune --archive removed-archive.json", defaultPruneAge)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Test soft caps warn only when the entry count or file size is over them
+func TestDatabaseCapWarnings(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	path := filepath.Join(tmpDir, "db.json")
+	db := buildListDatabase(10)
+	if err := SaveDatabaseLocal(path, db); err != nil {
+		t.Fatalf("Failed to save database: %v", err)
+	}
+
+	if warnings := databaseCapWarnings(path, db, &Config{}); len(warnings) != 0 {
+		t.Errorf("No caps configured should mean no warnings, got %v", warnings)
+	}
+	if warnings := databaseCapWarnings(path, db, &Config{MaxEntries: 20, MaxDatabaseMB: 1}); len(warnings) != 0 {
+		t.Errorf("A database at the caps should not warn, got %v", warnings)
+	}
+	warnings := databaseCapWarnings(path, db, &Config{MaxEntries: 19})
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "20 entries") {
+		t.Errorf("Expected an entry-count warning, got %v", warnings)
+	}
+
+	if err := os.WriteFile(path, make([]byte, 2<<20), 0644); err != nil {
+		t.Fatalf("Failed to grow database file: %v", err)
+	}
+	warnings = databaseCapWarnings(path, db, &Config{MaxDatabaseMB: 1})
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "2.0 MiB") {
+		t.Errorf("Expected a size warning, got %v", warnings)
+	}
+	if databaseCapWarnings(path, db, nil) != nil {
+		t.Error("A nil config should not warn")
+	}
+}
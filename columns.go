@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// defaultListColumns is the list output when neither --columns nor
+// list_columns is set
+var defaultListColumns = []string{"section", "hash", "added", "title"}
+
+// listColumns are the short column names, computed from several fields where
+// that reads better than the raw field. Every export column (the entry's
+// JSON field names) can be used as well.
+var listColumns = map[string]func(ListedEntry) string{
+	"hash": func(e ListedEntry) string { return e.Hash[:min(8, len(e.Hash))] },
+	"title": func(e ListedEntry) string {
+		if e.Title == "" {
+			return e.TorrentName
+		}
+		return e.Title
+	},
+	"section": func(e ListedEntry) string { return conflictMark(e, e.Section) },
+	"status": func(e ListedEntry) string {
+		if e.State != "" {
+			return conflictMark(e, e.State)
+		}
+		return conflictMark(e, e.Section)
+	},
+	"added":   func(e ListedEntry) string { return e.AddedDate },
+	"retries": func(e ListedEntry) string { return strconv.Itoa(e.RetryCount) },
+}
+
+// conflictMark appends a note to value when a merge flagged the entry, so
+// it stands out until the conflicts command clears it
+func conflictMark(e ListedEntry, value string) string {
+	if e.Conflict {
+		return value + " (conflict)"
+	}
+	return value
+}
+
+// parseColumns splits a comma-separated column list
+func parseColumns(value string) []string {
+	var columns []string
+	for _, column := range strings.Split(value, ",") {
+		if column = strings.ToLower(strings.TrimSpace(column)); column != "" {
+			columns = append(columns, column)
+		}
+	}
+	return columns
+}
+
+// validateColumns checks every column name is known
+func validateColumns(columns []string) error {
+	known := make(map[string]bool)
+	for name := range listColumns {
+		known[name] = true
+	}
+	for _, name := range exportColumns() {
+		known[name] = true
+	}
+	for _, column := range columns {
+		if !known[column] {
+			names := make([]string, 0, len(known))
+			for name := range known {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return fmt.Errorf("unknown column %q (available: %s)", column, strings.Join(names, ", "))
+		}
+	}
+	return nil
+}
+
+// writeColumns prints entries as an aligned table of the given columns
+func writeColumns(w io.Writer, entries []ListedEntry, columns []string) error {
+	index := make(map[string]int)
+	for i, name := range exportColumns() {
+		index[name] = i
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.ToUpper(strings.Join(columns, "\t")))
+	for _, entry := range entries {
+		row, err := exportRow(entry)
+		if err != nil {
+			return err
+		}
+		cells := make([]string, len(columns))
+		for i, column := range columns {
+			if value, ok := listColumns[column]; ok {
+				cells[i] = value(entry)
+			} else {
+				cells[i] = row[index[column]]
+			}
+			cells[i] = strings.ReplaceAll(cells[i], "\t", " ")
+		}
+		fmt.Fprintln(tw, strings.Join(cells, "\t"))
+	}
+	return tw.Flush()
+}
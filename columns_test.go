@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Test column lists are split, trimmed, and lowercased
+func TestParseColumns(t *testing.T) {
+	got := parseColumns(" Hash, title,,status ")
+	want := []string{"hash", "title", "status"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+// Test short names and entry field names are accepted, others rejected
+func TestValidateColumns(t *testing.T) {
+	if err := validateColumns([]string{"hash", "title", "status", "added", "save_path", "retry_count"}); err != nil {
+		t.Errorf("Expected columns to be valid: %v", err)
+	}
+	err := validateColumns([]string{"hash", "bogus"})
+	if err == nil || !strings.Contains(err.Error(), "bogus") {
+		t.Errorf("Expected error naming the unknown column, got %v", err)
+	}
+}
+
+// Test the table shows only the selected columns
+func TestWriteColumns(t *testing.T) {
+	entries := []ListedEntry{
+		{Section: SectionAdded, MagnetEntry: MagnetEntry{Hash: "abcdef0123456789", Title: "Book", State: "Seeding", SavePath: "/media"}},
+		{Section: SectionRetry, MagnetEntry: MagnetEntry{Hash: "9876543210fedcba", TorrentName: "Other"}},
+	}
+
+	var buf bytes.Buffer
+	if err := writeColumns(&buf, entries, []string{"hash", "title", "status", "save_path"}); err != nil {
+		t.Fatalf("writeColumns failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected header and 2 rows, got %q", buf.String())
+	}
+	if fields := strings.Fields(lines[0]); !reflect.DeepEqual(fields, []string{"HASH", "TITLE", "STATUS", "SAVE_PATH"}) {
+		t.Errorf("Unexpected header: %q", lines[0])
+	}
+	if fields := strings.Fields(lines[1]); !reflect.DeepEqual(fields, []string{"abcdef01", "Book", "Seeding", "/media"}) {
+		t.Errorf("Unexpected first row: %q", lines[1])
+	}
+	if fields := strings.Fields(lines[2]); !reflect.DeepEqual(fields, []string{"98765432", "Other", "retry"}) {
+		t.Errorf("Unexpected second row: %q", lines[2])
+	}
+}
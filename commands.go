@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// Command is a named operation invoked as `magnet-handler <name> [flags]`
+type Command struct {
+	Name    string
+	Usage   string
+	Summary string
+	Run     func(config Config, args []string) error
+
+	NoConfig bool // Runs before the config is loaded (and with an empty one)
+}
+
+// commands holds every registered command by name
+var commands = map[string]*Command{}
+
+// registerCommand makes a command available on the command line
+func registerCommand(cmd *Command) {
+	commands[cmd.Name] = cmd
+}
+
+// lookupCommand returns the command with the given name, if any
+func lookupCommand(name string) (*Command, bool) {
+	cmd, ok := commands[name]
+	return cmd, ok
+}
+
+// newCommandFlags creates a flag set whose usage text describes the command
+func newCommandFlags(cmd *Command) *flag.FlagSet {
+	fs := flag.NewFlagSet(cmd.Name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: magnet-handler %s\n\n%s\n\nFlags:\n", cmd.Usage, cmd.Summary)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// printCommands lists the available commands
+func printCommands() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(os.Stderr, "\nCommands:")
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-15s %s\n", name, commands[name].Summary)
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// LabelComparison counts one label's torrents in Deluge against the database
+type LabelComparison struct {
+	Label     string `json:"label"`
+	InDeluge  int    `json:"in_deluge"` // Torrents in Deluge with the label
+	Tracked   int    `json:"tracked"`   // ...that the database knows about
+	Untracked int    `json:"untracked"` // ...that it does not, e.g. added in the Web UI
+	Orphaned  int    `json:"orphaned"`  // Added entries with the label that Deluge no longer has
+}
+
+// compareLabels cross-tabulates Deluge's torrents and the database by label.
+// Database entries are counted under the label recorded on them, or the
+// configured label for entries that predate per-entry labels.
+func compareLabels(torrents map[string]map[string]interface{}, db *MagnetDatabase, config Config) []LabelComparison {
+	rows := make(map[string]*LabelComparison)
+	row := func(label string) *LabelComparison {
+		if rows[label] == nil {
+			rows[label] = &LabelComparison{Label: label}
+		}
+		return rows[label]
+	}
+
+	inDeluge := make(map[string]bool, len(torrents))
+	for id, torrent := range torrents {
+		hash, _ := torrent["hash"].(string)
+		if hash == "" {
+			hash = id
+		}
+		hash = strings.ToLower(hash)
+		inDeluge[hash] = true
+
+		label, _ := torrent["label"].(string)
+		r := row(label)
+		r.InDeluge++
+		_, added := db.Added[hash]
+		_, retry := db.Retry[hash]
+		if added || retry {
+			r.Tracked++
+		} else {
+			r.Untracked++
+		}
+	}
+
+	for hash, entry := range db.Added {
+		if !inDeluge[hash] {
+			row(entryLabel(entry, config)).Orphaned++
+		}
+	}
+
+	result := make([]LabelComparison, 0, len(rows))
+	for _, r := range rows {
+		result = append(result, *r)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Label < result[j].Label })
+	return result
+}
+
+// writeComparison prints the comparison as a table with totals and hints
+// about which command would reconcile the drift
+func writeComparison(w io.Writer, rows []LabelComparison, config Config) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "LABEL\tDELUGE\tTRACKED\tUNTRACKED\tORPHANED")
+	var total LabelComparison
+	for _, r := range rows {
+		label := r.Label
+		if label == "" {
+			label = "(none)"
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\n", label, r.InDeluge, r.Tracked, r.Untracked, r.Orphaned)
+		total.InDeluge += r.InDeluge
+		total.Tracked += r.Tracked
+		total.Untracked += r.Untracked
+		total.Orphaned += r.Orphaned
+	}
+	fmt.Fprintf(tw, "Total\t%d\t%d\t%d\t%d\n", total.InDeluge, total.Tracked, total.Untracked, total.Orphaned)
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	for _, r := range rows {
+		if r.Label != config.DelugeLabel {
+			continue
+		}
+		if r.Untracked > 0 {
+			fmt.Fprintf(w, "\n%s with label %q not in the database (adopt picks some, backfill imports all)\n",
+				plural(r.Untracked, "torrent", "torrents"), r.Label)
+		}
+		if r.Orphaned > 0 {
+			fmt.Fprintf(w, "\n%s with label %q no longer in Deluge (sync --dry-run lists them, sync removes them)\n",
+				plural(r.Orphaned, "entry", "entries"), r.Label)
+		}
+	}
+	return nil
+}
+
+// runCompare implements the compare command
+func runCompare(config Config, args []string) error {
+	fs := newCommandFlags(compareCommand)
+	asJSON := fs.Bool("json", false, "Print the comparison as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	client := NewDelugeClient(config.DelugeHost, config.DelugePort, config.DelugePassword)
+	if err := client.Authenticate(); err != nil {
+		return fmt.Errorf("authentication failed: %w", err)
+	}
+	if err := client.Connect(); err != nil {
+		return fmt.Errorf("connection failed: %w", err)
+	}
+	torrents, err := client.GetAllTorrents()
+	if err != nil {
+		return fmt.Errorf("failed to get torrents: %w", err)
+	}
+
+	db, err := LoadJSONDatabase(config.JSONPath)
+	if err != nil {
+		return fmt.Errorf("failed to load database: %w", err)
+	}
+	rows := compareLabels(torrents, db, config)
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(rows)
+	}
+	return writeComparison(os.Stdout, rows, config)
+}
+
+var compareCommand = &Command{
+	Name:    "compare",
+	Usage:   "compare [--json]",
+	Summary: "Count torrents per label in Deluge against the database, to spot drift before backfill or sync",
+}
+
+func init() {
+	compareCommand.Run = runCompare
+	registerCommand(compareCommand)
+}
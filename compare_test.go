@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// Test compareLabels counts tracked, untracked, and orphaned torrents per label
+func TestCompareLabels(t *testing.T) {
+	torrents := map[string]map[string]interface{}{
+		"aaa": {"hash": "AAA", "label": "audiobooks"},
+		"bbb": {"hash": "bbb", "label": "audiobooks"},
+		"ccc": {"hash": "ccc", "label": "podcasts"},
+		"ddd": {"hash": "ddd", "label": ""},
+	}
+	db := &MagnetDatabase{
+		Added: map[string]MagnetEntry{
+			"aaa": {Hash: "aaa"},                     // Tracked under the default label
+			"eee": {Hash: "eee"},                     // Orphaned, default label
+			"fff": {Hash: "fff", Label: "podcasts"},  // Orphaned podcast
+			"ggg": {Hash: "ggg", Label: "magazines"}, // Orphaned, label Deluge has none of
+		},
+		Retry: map[string]MagnetEntry{
+			"ccc": {Hash: "ccc", Label: "podcasts"},
+		},
+	}
+
+	rows := compareLabels(torrents, db, Config{DelugeLabel: "audiobooks"})
+	want := []LabelComparison{
+		{Label: "", InDeluge: 1, Untracked: 1},
+		{Label: "audiobooks", InDeluge: 2, Tracked: 1, Untracked: 1, Orphaned: 1},
+		{Label: "magazines", Orphaned: 1},
+		{Label: "podcasts", InDeluge: 1, Tracked: 1, Orphaned: 1},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("Expected %d rows, got %+v", len(want), rows)
+	}
+	for i := range want {
+		if rows[i] != want[i] {
+			t.Errorf("Row %d = %+v, want %+v", i, rows[i], want[i])
+		}
+	}
+}
+
+// Test writeComparison totals the table and suggests backfill and sync
+func TestWriteComparison(t *testing.T) {
+	rows := []LabelComparison{
+		{Label: "", InDeluge: 1, Untracked: 1},
+		{Label: "audiobooks", InDeluge: 3, Tracked: 1, Untracked: 2, Orphaned: 1},
+	}
+
+	var buf bytes.Buffer
+	if err := writeComparison(&buf, rows, Config{DelugeLabel: "audiobooks"}); err != nil {
+		t.Fatalf("writeComparison failed: %v", err)
+	}
+	output := buf.String()
+
+	for _, want := range []string{"(none)", "Total", "2 torrents with label \"audiobooks\" not in the database", "1 entry with label \"audiobooks\" no longer in Deluge"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected %q in output:\n%s", want, output)
+		}
+	}
+	totals := strings.Fields(output[strings.Index(output, "Total"):])
+	if strings.Join(totals[:5], " ") != "Total 4 1 3 1" {
+		t.Errorf("Unexpected totals: %v", totals[:5])
+	}
+}
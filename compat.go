@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// Deluge API generations with incompatible method signatures
+const (
+	DelugeAPIUnknown = 0
+	DelugeAPIv1      = 1 // Deluge 1.3.x
+	DelugeAPIv2      = 2 // Deluge 2.x
+)
+
+// parseDelugeMajor returns the API generation for a Deluge version string
+func parseDelugeMajor(version string) int {
+	major, _, _ := strings.Cut(strings.TrimPrefix(strings.TrimSpace(version), "v"), ".")
+	n, err := strconv.Atoi(major)
+	if err != nil {
+		return DelugeAPIUnknown
+	}
+	if n < 2 {
+		return DelugeAPIv1
+	}
+	return DelugeAPIv2
+}
+
+// parseHostStatus extracts the connection status and daemon version from a
+// web.get_host_status reply. Deluge 1.3 returns [id, host, port, status,
+// version] while 2.x returns [id, status, version].
+func parseHostStatus(reply []interface{}) (status, version string) {
+	switch len(reply) {
+	case 5:
+		status, _ = reply[3].(string)
+		version, _ = reply[4].(string)
+	case 3:
+		status, _ = reply[1].(string)
+		version, _ = reply[2].(string)
+	}
+	return status, version
+}
+
+// hostOnline reports whether a host status means the daemon is reachable
+func hostOnline(status string) bool {
+	return status == "Online" || status == "Connected"
+}
+
+// pickHost chooses the first online host from web.get_hosts, recording its
+// daemon version. Hosts whose status cannot be read are used as a fallback.
+func (c *DelugeClient) pickHost(hosts []delugeHost) (string, error) {
+	fallback := ""
+	for _, host := range hosts {
+		hostID := host.ID
+
+		var fields []interface{}
+		if err := c.rpc("web.get_host_status", []interface{}{hostID}, &fields); err != nil {
+			if fallback == "" {
+				fallback = hostID
+			}
+			continue
+		}
+		status, version := parseHostStatus(fields)
+		if hostOnline(status) {
+			if version != "" {
+				c.setDaemonVersion(version)
+			}
+			return hostID, nil
+		}
+		log.Printf("Deluge host %s is %s", hostID, strings.ToLower(status))
+	}
+
+	if fallback != "" {
+		return fallback, nil
+	}
+	return "", fmt.Errorf("no online Deluge hosts available")
+}
+
+// setDaemonVersion records the daemon version and its API generation
+func (c *DelugeClient) setDaemonVersion(version string) {
+	c.DaemonVersion = version
+	c.APIVersion = parseDelugeMajor(version)
+}
+
+// detectAPIVersion asks the connected daemon for its version when the host
+// status did not already report it. Unknown versions are treated as 2.x.
+func (c *DelugeClient) detectAPIVersion() {
+	if c.APIVersion != DelugeAPIUnknown {
+		return
+	}
+
+	var version string
+	if err := c.rpc("daemon.info", nil, &version); err == nil && version != "" {
+		c.setDaemonVersion(version)
+	}
+	if c.APIVersion == DelugeAPIUnknown {
+		log.Printf("Warning: Could not determine Deluge version, assuming 2.x")
+		return
+	}
+	log.Printf("Deluge daemon version: %s", c.DaemonVersion)
+}
+
+// torrentIDParam formats a single torrent ID for pause/resume, which take a
+// list of IDs on Deluge 1.3 but a single ID on 2.x
+func (c *DelugeClient) torrentIDParam(torrentID string) interface{} {
+	if c.APIVersion == DelugeAPIv1 {
+		return []interface{}{torrentID}
+	}
+	return torrentID
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"testing"
+)
+
+// Test parseDelugeMajor
+func TestParseDelugeMajor(t *testing.T) {
+	tests := []struct {
+		version  string
+		expected int
+	}{
+		{"1.3.15", DelugeAPIv1},
+		{"2.0.3", DelugeAPIv2},
+		{"2.1.1.dev0", DelugeAPIv2},
+		{"v2.1", DelugeAPIv2},
+		{"", DelugeAPIUnknown},
+		{"unknown", DelugeAPIUnknown},
+	}
+
+	for _, tt := range tests {
+		if got := parseDelugeMajor(tt.version); got != tt.expected {
+			t.Errorf("parseDelugeMajor(%q) = %d, want %d", tt.version, got, tt.expected)
+		}
+	}
+}
+
+// Test Connect detects the daemon version and adapts pause/resume arguments
+func TestConnectDetectsAPIVersion(t *testing.T) {
+	tests := []struct {
+		name       string
+		hostStatus []interface{}
+		expected   int
+		listParam  bool
+	}{
+		{"deluge 1.3", []interface{}{"abc", "127.0.0.1", 58846, "Online", "1.3.15"}, DelugeAPIv1, true},
+		{"deluge 2.x", []interface{}{"abc", "Online", "2.0.5"}, DelugeAPIv2, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var pauseParam interface{}
+			client := fakeDeluge(t, func(method string, params []interface{}) (interface{}, interface{}) {
+				switch method {
+				case "web.connected":
+					return false, nil
+				case "web.get_hosts":
+					return []interface{}{
+						[]interface{}{"offline", "10.0.0.1", 58846, ""},
+						[]interface{}{"abc", "127.0.0.1", 58846, ""},
+					}, nil
+				case "web.get_host_status":
+					if params[0] == "offline" {
+						return []interface{}{"offline", "Offline", ""}, nil
+					}
+					return tt.hostStatus, nil
+				case "web.connect":
+					if params[0] != "abc" {
+						t.Errorf("Connected to wrong host %v", params[0])
+					}
+				case "core.pause_torrent":
+					pauseParam = params[0]
+				}
+				return nil, nil
+			})
+
+			if err := client.Connect(); err != nil {
+				t.Fatalf("Connect failed: %v", err)
+			}
+			if client.APIVersion != tt.expected {
+				t.Errorf("Expected API version %d, got %d", tt.expected, client.APIVersion)
+			}
+
+			if err := client.PauseTorrent("hash1"); err != nil {
+				t.Fatalf("PauseTorrent failed: %v", err)
+			}
+			_, isList := pauseParam.([]interface{})
+			if isList != tt.listParam {
+				t.Errorf("Expected list parameter %v, got %#v", tt.listParam, pauseParam)
+			}
+		})
+	}
+}
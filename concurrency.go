@@ -0,0 +1,99 @@
+package main
+
+import (
+	"log"
+	"sort"
+)
+
+// CountDownloading returns how many torrents with the label Deluge is
+// actively downloading
+func (c *DelugeClient) CountDownloading(label string) (int, error) {
+	filter := map[string]interface{}{"label": label, "state": "Downloading"}
+	var statuses map[string]map[string]interface{}
+	if err := c.rpc("core.get_torrents_status", []interface{}{filter, []string{"state"}}, &statuses); err != nil {
+		return 0, err
+	}
+	return len(statuses), nil
+}
+
+// holdForLimit switches opts to add paused when the label already has
+// max_active torrents downloading, reporting whether the add is held
+func holdForLimit(client *DelugeClient, config Config, label string, opts *AddTorrentOptions) bool {
+	logger := config.logger()
+	limit := config.LabelOptions[label].MaxActive
+	if limit <= 0 || opts.AddPaused {
+		return false
+	}
+
+	active, err := client.CountDownloading(label)
+	if err != nil {
+		logger.Printf("Warning: Could not count downloading torrents for label %q: %v", label, err)
+		return false
+	}
+	if active < limit {
+		return false
+	}
+
+	logger.Printf("⚠ Label %q has %d of %d torrents downloading, adding paused", label, active, limit)
+	opts.AddPaused = true
+	return true
+}
+
+// releaseHeld resumes held torrents, oldest first, while their label has
+// free download slots. Released entries are written to dbUpdate.
+func releaseHeld(client *DelugeClient, config Config, db, dbUpdate *MagnetDatabase) int {
+	byLabel := make(map[string][]string)
+	for hash, entry := range db.Added {
+		if entry.Held {
+			label := entryLabel(entry, config)
+			byLabel[label] = append(byLabel[label], hash)
+		}
+	}
+
+	released := 0
+	for label, hashes := range byLabel {
+		sort.Slice(hashes, func(i, j int) bool {
+			return db.Added[hashes[i]].AddedToDeluge < db.Added[hashes[j]].AddedToDeluge
+		})
+
+		// Without a limit any more, everything held can start
+		free := len(hashes)
+		if limit := config.LabelOptions[label].MaxActive; limit > 0 {
+			active, err := client.CountDownloading(label)
+			if err != nil {
+				log.Printf("Warning: Could not count downloading torrents for label %q: %v", label, err)
+				continue
+			}
+			free = limit - active
+		}
+
+		for _, hash := range hashes {
+			if free <= 0 {
+				break
+			}
+			entry := dbUpdate.Added[hash]
+			if entry.Hash == "" {
+				entry = db.Added[hash]
+			}
+			if err := client.ResumeTorrent(hash); err != nil {
+				log.Printf("✗ Failed to release %s: %v", entry.Title, err)
+				continue
+			}
+			log.Printf("✓ Released held torrent: %s", entry.Title)
+			entry.Held = false
+			entry.recordHistory(HistoryReleased, "", "")
+			dbUpdate.Added[hash] = entry
+			released++
+			free--
+		}
+	}
+	return released
+}
+
+// holdDetail describes a held add for the entry history
+func holdDetail(held bool) string {
+	if held {
+		return "added paused by max_active"
+	}
+	return ""
+}
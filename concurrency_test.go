@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// downloadingDeluge fakes a Deluge server with n torrents downloading,
+// recording resumed torrent IDs
+func downloadingDeluge(t *testing.T, n int, resumed *[]string) *DelugeClient {
+	return fakeDeluge(t, func(method string, params []interface{}) (interface{}, interface{}) {
+		switch method {
+		case "core.get_torrents_status":
+			statuses := map[string]interface{}{}
+			for i := 0; i < n; i++ {
+				statuses[fmt.Sprintf("active%d", i)] = map[string]interface{}{"state": "Downloading"}
+			}
+			return statuses, nil
+		case "core.resume_torrent":
+			id, ok := params[0].(string)
+			if ids, isList := params[0].([]interface{}); !ok && isList {
+				id, _ = ids[0].(string)
+			}
+			*resumed = append(*resumed, id)
+			n++
+		}
+		return nil, nil
+	})
+}
+
+// Test adds are held paused only once the label reaches max_active
+func TestHoldForLimit(t *testing.T) {
+	config := Config{LabelOptions: map[string]LabelOptions{"audiobooks": {MaxActive: 2}}}
+
+	tests := []struct {
+		name   string
+		label  string
+		active int
+		held   bool
+	}{
+		{"below limit", "audiobooks", 1, false},
+		{"at limit", "audiobooks", 2, true},
+		{"no limit for label", "movies", 5, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resumed []string
+			client := downloadingDeluge(t, tt.active, &resumed)
+			opts := AddTorrentOptions{}
+			if held := holdForLimit(client, config, tt.label, &opts); held != tt.held {
+				t.Errorf("Expected held=%v, got %v", tt.held, held)
+			}
+			if opts.AddPaused != tt.held {
+				t.Errorf("Expected AddPaused=%v, got %v", tt.held, opts.AddPaused)
+			}
+		})
+	}
+}
+
+// Test held torrents are released oldest first while slots are free
+func TestReleaseHeld(t *testing.T) {
+	config := Config{
+		DelugeLabel:  "audiobooks",
+		LabelOptions: map[string]LabelOptions{"audiobooks": {MaxActive: 2}},
+	}
+	db := &MagnetDatabase{Added: map[string]MagnetEntry{
+		"newer":   {Hash: "newer", Title: "Newer", Held: true, AddedToDeluge: "2024-01-03T00:00:00Z"},
+		"oldest":  {Hash: "oldest", Title: "Oldest", Held: true, AddedToDeluge: "2024-01-01T00:00:00Z"},
+		"running": {Hash: "running", Title: "Running", AddedToDeluge: "2024-01-02T00:00:00Z"},
+	}}
+	dbUpdate := &MagnetDatabase{Added: make(map[string]MagnetEntry), Retry: make(map[string]MagnetEntry)}
+
+	var resumed []string
+	client := downloadingDeluge(t, 1, &resumed)
+	if released := releaseHeld(client, config, db, dbUpdate); released != 1 {
+		t.Fatalf("Expected 1 release, got %d", released)
+	}
+	if len(resumed) != 1 || resumed[0] != "oldest" {
+		t.Errorf("Expected oldest torrent resumed, got %v", resumed)
+	}
+	if entry, ok := dbUpdate.Added["oldest"]; !ok || entry.Held {
+		t.Errorf("Released entry should be saved without held flag: %+v", entry)
+	}
+	if _, ok := dbUpdate.Added["newer"]; ok {
+		t.Error("Entry still held should not be updated")
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// runConfig implements the config command
+func runConfig(config Config, args []string) error {
+	if len(args) == 0 {
+		newCommandFlags(configCommand).Usage()
+		return fmt.Errorf("expected a config subcommand")
+	}
+
+	switch args[0] {
+	case "set-password":
+		return runSetPassword(config, args[1:])
+	case "convert":
+		return runConvertConfig(args[1:])
+	default:
+		return fmt.Errorf("unknown config subcommand %q", args[0])
+	}
+}
+
+// runSetPassword prompts for a new Deluge password, checks it against the
+// server, saves it, and clears recorded authentication failures
+func runSetPassword(config Config, args []string) error {
+	fs := newCommandFlags(configCommand)
+	fromStdin := fs.Bool("stdin", false, "Read the password from standard input instead of prompting")
+	noVerify := fs.Bool("no-verify", false, "Save without a test login (e.g. when Deluge is offline)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	password, err := readPassword(os.Stdin, !*fromStdin)
+	if err != nil {
+		return err
+	}
+	return setPassword(config, password, !*noVerify)
+}
+
+// errSettingsRejected means Deluge answered but refused the password
+var errSettingsRejected = errors.New("password rejected")
+
+// validateDeluge logs in with the config's connection settings and checks
+// the Web UI can reach a daemon, so settings that cannot work are never saved
+func validateDeluge(config Config) error {
+	client := NewDelugeClient(config.DelugeHost, config.DelugePort, config.DelugePassword)
+	if err := client.Authenticate(); err != nil {
+		if errors.Is(err, errAuthRejected) {
+			return fmt.Errorf("Deluge at %s:%s rejected the password: %w", config.DelugeHost, config.DelugePort, errSettingsRejected)
+		}
+		return fmt.Errorf("could not log in to Deluge at %s:%s: %w", config.DelugeHost, config.DelugePort, err)
+	}
+	if err := client.Connect(); err != nil {
+		return fmt.Errorf("logged in to Deluge at %s:%s but could not reach a daemon: %w", config.DelugeHost, config.DelugePort, err)
+	}
+	log.Printf("✓ Test login to %s:%s succeeded and reached a daemon", config.DelugeHost, config.DelugePort)
+	return nil
+}
+
+// setPassword optionally verifies a password with a test login, then stores it
+func setPassword(config Config, password string, verify bool) error {
+	if verify {
+		config.DelugePassword = password
+		if err := validateDeluge(config); err != nil {
+			if errors.Is(err, errSettingsRejected) {
+				return fmt.Errorf("%w, not saved", err)
+			}
+			return fmt.Errorf("could not verify password (use --no-verify to save anyway): %w", err)
+		}
+	}
+
+	// Save into the stored config, not the one with command-line overrides
+	stored, err := LoadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if external, err := storeSecret(stored, "deluge_password", password); err != nil {
+		return fmt.Errorf("failed to save password: %w", err)
+	} else if !external {
+		stored.DelugePassword = password
+		if err := SaveConfig(stored); err != nil {
+			return fmt.Errorf("failed to save config: %w", err)
+		}
+	}
+
+	if err := ResetAuthBreaker(); err != nil {
+		log.Printf("Warning: Failed to reset auth breaker: %v", err)
+	}
+
+	log.Println("✓ Deluge password updated")
+	return nil
+}
+
+// readPassword reads a password, prompting without echo when r is a terminal
+func readPassword(r io.Reader, prompt bool) (string, error) {
+	if f, ok := r.(*os.File); ok && prompt && term.IsTerminal(int(f.Fd())) {
+		fmt.Fprint(os.Stderr, "New Deluge password: ")
+		data, err := term.ReadPassword(int(f.Fd()))
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", err
+		}
+		if len(data) == 0 {
+			return "", fmt.Errorf("password cannot be empty")
+		}
+		return string(data), nil
+	}
+
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", fmt.Errorf("password cannot be empty")
+	}
+	return password, nil
+}
+
+var configCommand = &Command{
+	Name:    "config",
+	Usage:   "config set-password [--stdin] [--no-verify] | config convert",
+	Summary: "Change stored settings (set-password: verify and save a new Deluge password; convert: rewrite the config file as YAML with sections)",
+}
+
+func init() {
+	configCommand.Run = runConfig
+	registerCommand(configCommand)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+)
+
+// Test readPassword from a non-terminal reader
+func TestReadPassword(t *testing.T) {
+	password, err := readPassword(strings.NewReader("s3cret\r\n"), true)
+	if err != nil || password != "s3cret" {
+		t.Errorf("Expected s3cret, got %q, %v", password, err)
+	}
+	if _, err := readPassword(strings.NewReader("\n"), true); err == nil {
+		t.Error("Empty password should be an error")
+	}
+}
+
+// Test setPassword only saves a password Deluge accepts and resets the breaker
+func TestSetPassword(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	t.Setenv("HOME", tmpDir)
+
+	client := fakeDeluge(t, func(method string, params []interface{}) (interface{}, interface{}) {
+		if method == "web.connected" {
+			return true, nil
+		}
+		return method == "auth.login" && params[0] == "right", nil
+	})
+	server, _ := url.Parse(client.BaseURL)
+
+	config := DefaultConfig()
+	config.DelugeHost = server.Hostname()
+	config.DelugePort = server.Port()
+	if err := SaveConfig(config); err != nil {
+		t.Fatalf("SaveConfig failed: %v", err)
+	}
+	breaker := &AuthBreaker{Failures: 3, Tripped: true, Fingerprint: authFingerprint(config)}
+	breaker.save()
+
+	if err := setPassword(config, "wrong", true); err == nil {
+		t.Error("Rejected password should not be saved")
+	}
+	if loaded, _ := LoadConfig(); loaded.DelugePassword == "wrong" {
+		t.Error("Rejected password was saved")
+	}
+
+	if err := setPassword(config, "right", true); err != nil {
+		t.Fatalf("setPassword failed: %v", err)
+	}
+	loaded, _ := LoadConfig()
+	if loaded.DelugePassword != "right" {
+		t.Errorf("Expected saved password, got %q", loaded.DelugePassword)
+	}
+	if AuthBreakerOpen(config) {
+		t.Error("Breaker should be reset after changing the password")
+	}
+}
+
+// Test validateDeluge needs both a login and a reachable daemon
+func TestValidateDeluge(t *testing.T) {
+	online := false
+	client := fakeDeluge(t, func(method string, params []interface{}) (interface{}, interface{}) {
+		switch method {
+		case "auth.login":
+			return params[0] == "right", nil
+		case "web.connected":
+			return online, nil
+		case "web.get_hosts":
+			return []interface{}{}, nil
+		}
+		return nil, nil
+	})
+	server, _ := url.Parse(client.BaseURL)
+	config := Config{DelugeHost: server.Hostname(), DelugePort: server.Port(), DelugePassword: "wrong"}
+
+	if err := validateDeluge(config); !errors.Is(err, errSettingsRejected) {
+		t.Errorf("Expected a wrong password to be rejected, got %v", err)
+	}
+	config.DelugePassword = "right"
+	if err := validateDeluge(config); err == nil || errors.Is(err, errSettingsRejected) {
+		t.Errorf("Expected an error when no daemon is reachable, got %v", err)
+	}
+	online = true
+	if err := validateDeluge(config); err != nil {
+		t.Errorf("Expected valid settings to pass, got %v", err)
+	}
+	config.DelugePort = "1"
+	if err := validateDeluge(config); err == nil {
+		t.Error("Expected an error when Deluge is unreachable")
+	}
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// configLayout places settings in the nested sections a YAML config groups
+// them under. Settings not listed here, and every setting in the legacy
+// flat JSON file, stay at the top level under their own names.
+var configLayout = []struct {
+	Path string // Dotted section path in the YAML file
+	Key  string // Flat config key
+}{
+	{"clients.deluge.host", "deluge_host"},
+	{"clients.deluge.port", "deluge_port"},
+	{"clients.deluge.password", "deluge_password"},
+	{"clients.deluge.label", "deluge_label"},
+	{"clients.deluge.download_location", "download_location"},
+	{"clients.deluge.move_completed_path", "move_completed_path"},
+	{"clients.deluge.max_download_speed", "max_download_speed"},
+	{"clients.deluge.add_paused", "add_paused"},
+	{"clients.deluge.metadata_timeout", "metadata_timeout"},
+	{"clients.deluge.auth_failure_limit", "auth_failure_limit"},
+	{"clients.deluge.webui_url", "webui_url"},
+	{"clients.deluge.webui_link", "webui_link"},
+	{"clients.deluge.http_max_idle_conns", "http_max_idle_conns"},
+	{"clients.deluge.http_idle_timeout", "http_idle_timeout"},
+	{"clients.deluge.disable_http2", "disable_http2"},
+	{"clients.targets", "targets"},
+
+	{"routing.tracker_labels", "tracker_labels"},
+	{"routing.label_options", "label_options"},
+
+	{"notifications.quiet_hours", "quiet_hours"},
+	{"notifications.disable_add", "disable_add_notifications"},
+
+	{"sync.json_path", "json_path"},
+	{"sync.remote_path", "remote_path"},
+	{"sync.remote_paths", "remote_paths"},
+	{"sync.refresh_before_add", "refresh_before_add"},
+	{"sync.interval", "sync_interval"},
+	{"sync.every", "sync_every"},
+	{"sync.merge_tie_break", "merge_tie_break"},
+	{"sync.read_fallbacks", "read_fallbacks"},
+	{"sync.oplog_retention", "oplog_retention"},
+	{"sync.device_id", "device_id"},
+	{"sync.checksum_algorithm", "checksum_algorithm"},
+	{"sync.signing_key", "signing_key"},
+	{"sync.sign_changes", "sign_changes"},
+	{"sync.device_keys", "device_keys"},
+
+	{"retry.interval", "retry_interval"},
+	{"retry.backoff", "retry_backoff"},
+	{"retry.max_retries", "max_retries"},
+	{"retry.dead_after_days", "dead_after_days"},
+	{"retry.raw_uri", "retry_raw_uri"},
+	{"retry.refresh_trackers", "refresh_trackers"},
+	{"retry.trackers", "trackers"},
+
+	{"serve.address", "serve_address"},
+	{"serve.api_token", "api_token"},
+	{"serve.paired_tokens", "paired_tokens"},
+	{"serve.webhook_secret", "webhook_secret"},
+	{"serve.calendar_secret", "calendar_secret"},
+	{"serve.tls_cert", "tls_cert"},
+	{"serve.tls_key", "tls_key"},
+	{"serve.home_assistant", "home_assistant"},
+	{"serve.max_queue_depth", "max_queue_depth"},
+	{"serve.max_deluge_latency", "max_deluge_latency"},
+}
+
+// configHeader starts a config file written as YAML
+const configHeader = "# magnet-handler settings. Saving settings rewrites this file, dropping comments.\n"
+
+// configIsJSON reports whether a config file holds JSON, the legacy format
+func configIsJSON(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(data), []byte("{"))
+}
+
+// decodeConfig reads a config file in either format: the legacy flat JSON
+// or YAML, with settings at the top level or in configLayout's sections
+func decodeConfig(data []byte) (Config, error) {
+	var config Config
+	if configIsJSON(data) {
+		err := json.Unmarshal(data, &config)
+		return config, err
+	}
+
+	tree, err := decodeYAML(data)
+	if err != nil {
+		return config, err
+	}
+	sections, ok := tree.(map[string]interface{})
+	if !ok {
+		return config, fmt.Errorf("config file does not hold a mapping of settings")
+	}
+	flat, err := flattenConfig(sections)
+	if err != nil {
+		return config, err
+	}
+	data, err = json.Marshal(coerceConfigTypes(flat, reflect.TypeOf(config)))
+	if err != nil {
+		return config, err
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return config, err
+	}
+	return config, nil
+}
+
+// flattenConfig moves settings out of their sections to the top level,
+// rejecting unknown section keys and settings given twice
+func flattenConfig(tree map[string]interface{}) (map[string]interface{}, error) {
+	keys := make(map[string]string)
+	sections := make(map[string]bool)
+	for _, setting := range configLayout {
+		keys[setting.Path] = setting.Key
+		for path := setting.Path; strings.Contains(path, "."); {
+			path = path[:strings.LastIndex(path, ".")]
+			sections[path] = true
+		}
+	}
+
+	flat := make(map[string]interface{})
+	set := func(key string, value interface{}) error {
+		if _, dup := flat[key]; dup {
+			return fmt.Errorf("%s is set twice, at the top level and in its section", key)
+		}
+		flat[key] = value
+		return nil
+	}
+	var walk func(prefix string, m map[string]interface{}) error
+	walk = func(prefix string, m map[string]interface{}) error {
+		for name, value := range m {
+			path := name
+			if prefix != "" {
+				path = prefix + "." + name
+			}
+			if key, ok := keys[path]; ok {
+				if err := set(key, value); err != nil {
+					return err
+				}
+				continue
+			}
+			if sections[path] {
+				section, ok := value.(map[string]interface{})
+				if !ok && value != nil {
+					return fmt.Errorf("%s must be a section of settings", path)
+				}
+				if err := walk(path, section); err != nil {
+					return err
+				}
+				continue
+			}
+			if prefix != "" {
+				return fmt.Errorf("unknown setting %s", path)
+			}
+			if err := set(name, value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk("", tree); err != nil {
+		return nil, err
+	}
+	return flat, nil
+}
+
+// nestConfig moves flat settings into their sections, the reverse of
+// flattenConfig
+func nestConfig(flat map[string]interface{}) map[string]interface{} {
+	paths := make(map[string]string)
+	for _, setting := range configLayout {
+		paths[setting.Key] = setting.Path
+	}
+	tree := make(map[string]interface{})
+	for key, value := range flat {
+		path, ok := paths[key]
+		if !ok {
+			tree[key] = value
+			continue
+		}
+		parts := strings.Split(path, ".")
+		section := tree
+		for _, part := range parts[:len(parts)-1] {
+			next, ok := section[part].(map[string]interface{})
+			if !ok {
+				next = make(map[string]interface{})
+				section[part] = next
+			}
+			section = next
+		}
+		section[parts[len(parts)-1]] = value
+	}
+	return tree
+}
+
+// coerceConfigTypes turns YAML numbers and booleans into strings where t
+// holds a string, so "port: 8112" or an all-digit password still loads
+func coerceConfigTypes(value interface{}, t reflect.Type) interface{} {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.String:
+		switch v := value.(type) {
+		case json.Number:
+			return v.String()
+		case bool:
+			return strconv.FormatBool(v)
+		}
+	case reflect.Struct:
+		if m, ok := value.(map[string]interface{}); ok {
+			for i := 0; i < t.NumField(); i++ {
+				name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+				if v, ok := m[name]; ok {
+					m[name] = coerceConfigTypes(v, t.Field(i).Type)
+				}
+			}
+		}
+	case reflect.Map:
+		if m, ok := value.(map[string]interface{}); ok {
+			for key, v := range m {
+				m[key] = coerceConfigTypes(v, t.Elem())
+			}
+		}
+	case reflect.Slice:
+		if list, ok := value.([]interface{}); ok {
+			for i, v := range list {
+				list[i] = coerceConfigTypes(v, t.Elem())
+			}
+		}
+	}
+	return value
+}
+
+// encodeConfigYAML renders config as YAML grouped into sections
+func encodeConfigYAML(config Config) ([]byte, error) {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var flat map[string]interface{}
+	if err := decoder.Decode(&flat); err != nil {
+		return nil, err
+	}
+	return []byte(configHeader + encodeYAML(nestConfig(flat))), nil
+}
+
+// encodeConfig renders config in the format the file at path already
+// uses: YAML if it was written as YAML, JSON otherwise
+func encodeConfig(path string, config Config) ([]byte, error) {
+	if data, err := os.ReadFile(path); err == nil && !configIsJSON(data) && len(bytes.TrimSpace(data)) > 0 {
+		return encodeConfigYAML(config)
+	}
+	return json.MarshalIndent(config, "", "  ")
+}
+
+// runConvertConfig rewrites the config file as YAML grouped into sections
+func runConvertConfig(args []string) error {
+	fs := newCommandFlags(configCommand)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	homeDir, err := getHomeDir()
+	if err != nil {
+		return err
+	}
+	if path, encrypted := encryptedConfigPath(homeDir); encrypted {
+		return fmt.Errorf("%s is encrypted with SOPS, which keeps it as JSON", path)
+	}
+
+	source := configFilePath(homeDir)
+	data, err := os.ReadFile(source)
+	if err != nil {
+		return fmt.Errorf("no config file to convert: %w", err)
+	}
+	config, err := decodeConfig(data)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", source, err)
+	}
+	converted, err := encodeConfigYAML(config)
+	if err != nil {
+		return err
+	}
+
+	target := filepath.Join(homeDir, ".magnet-handler", "mh.yaml")
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	if source == target {
+		if err := os.WriteFile(target+".bak", data, 0600); err != nil {
+			return fmt.Errorf("failed to back up %s: %w", target, err)
+		}
+		log.Printf("Previous config kept at %s", target+".bak")
+	}
+	if err := os.WriteFile(target, converted, 0644); err != nil {
+		return err
+	}
+	log.Printf("✓ Wrote %s as YAML", target)
+	if source != target {
+		log.Printf("  %s is no longer read and can be deleted", source)
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Test a YAML config with sections loads into the same settings as the
+// legacy flat JSON file
+func TestDecodeConfigSections(t *testing.T) {
+	config, err := decodeConfig([]byte(`
+clients:
+  deluge:
+    host: 192.168.1.100
+    port: 8112
+    password: 12345
+    label: audiobooks
+    add_paused: true
+  targets:
+    - name: seedbox
+      type: qbittorrent
+      url: https://seedbox.example
+routing:
+  tracker_labels:
+    tracker.example: books
+notifications:
+  quiet_hours:
+    start: "22:00"
+    end: "07:00"
+sync:
+  remote_path: W:\magnet-list-network.json
+  interval: 15
+retry:
+  max_retries: 5
+list_columns: [hash, title]
+`))
+	if err != nil {
+		t.Fatalf("decodeConfig failed: %v", err)
+	}
+	if config.DelugeHost != "192.168.1.100" || config.DelugePort != "8112" || config.DelugePassword != "12345" {
+		t.Errorf("Unexpected Deluge settings %q %q %q", config.DelugeHost, config.DelugePort, config.DelugePassword)
+	}
+	if config.DelugeLabel != "audiobooks" || !config.AddPaused || config.MaxRetries != 5 || config.SyncInterval != 15 {
+		t.Errorf("Unexpected settings %+v", config)
+	}
+	if len(config.Targets) != 1 || config.Targets[0].URL != "https://seedbox.example" {
+		t.Errorf("Unexpected targets %+v", config.Targets)
+	}
+	if config.TrackerLabels["tracker.example"] != "books" || config.QuietHours == nil || config.QuietHours.End != "07:00" {
+		t.Errorf("Unexpected routing or notifications %v %+v", config.TrackerLabels, config.QuietHours)
+	}
+	if config.RemotePath != `W:\magnet-list-network.json` || !reflect.DeepEqual(config.ListColumns, []string{"hash", "title"}) {
+		t.Errorf("Unexpected remote path %q or columns %v", config.RemotePath, config.ListColumns)
+	}
+
+	legacy, err := decodeConfig([]byte(`{"deluge_host": "192.168.1.100", "deluge_port": "8112"}`))
+	if err != nil || legacy.DelugeHost != "192.168.1.100" {
+		t.Errorf("Expected flat JSON still read, got %+v, %v", legacy, err)
+	}
+	if flat, err := decodeConfig([]byte("deluge_host: 10.0.0.1\nmax_retries: 2\n")); err != nil || flat.DelugeHost != "10.0.0.1" || flat.MaxRetries != 2 {
+		t.Errorf("Expected flat keys accepted in YAML, got %+v, %v", flat, err)
+	}
+
+	for _, bad := range []string{
+		"clients:\n  deluge:\n    hots: x\n",
+		"deluge_host: a\nclients:\n  deluge:\n    host: b\n",
+		"clients: oops\n",
+		"- a list\n",
+	} {
+		if _, err := decodeConfig([]byte(bad)); err == nil {
+			t.Errorf("Expected %q rejected", bad)
+		}
+	}
+}
+
+// Test saving keeps a YAML config in YAML and config convert rewrites a
+// JSON one
+func TestSaveConfigYAML(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	t.Setenv("HOME", tmpDir)
+
+	config := DefaultConfig()
+	config.DelugeHost = "192.168.1.100"
+	config.DelugePassword = "0042"
+	config.Targets = []TargetConfig{{Name: "seedbox", Type: "deluge", Port: "8112"}}
+	config.TrackerLabels = map[string]string{"tracker.example": "books"}
+	if err := SaveConfig(config); err != nil {
+		t.Fatalf("SaveConfig failed: %v", err)
+	}
+	path := filepath.Join(tmpDir, ".magnet-handler", "mh.yaml")
+	if data, _ := os.ReadFile(path); !configIsJSON(data) {
+		t.Fatalf("Expected a new config saved as JSON, got %s", data)
+	}
+
+	if err := runConvertConfig(nil); err != nil {
+		t.Fatalf("runConvertConfig failed: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	if configIsJSON(data) || !strings.Contains(string(data), "clients:\n  deluge:\n") {
+		t.Fatalf("Expected the config rewritten as YAML with sections, got %s", data)
+	}
+	if backup, err := os.ReadFile(path + ".bak"); err != nil || !configIsJSON(backup) {
+		t.Errorf("Expected the JSON config kept as a backup, got %v", err)
+	}
+	loaded, err := LoadConfig()
+	if err != nil || !reflect.DeepEqual(loaded, config) {
+		t.Errorf("Expected the converted config to load unchanged, got %+v, %v", loaded, err)
+	}
+
+	loaded.DelugeLabel = "changed"
+	if err := SaveConfig(loaded); err != nil {
+		t.Fatalf("SaveConfig failed: %v", err)
+	}
+	data, _ = os.ReadFile(path)
+	if !strings.HasPrefix(string(data), configHeader) || !strings.Contains(string(data), "label: changed") {
+		t.Errorf("Expected saving to keep the YAML format, got %s", data)
+	}
+}
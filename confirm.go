@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// confirmDialogs returns the Add/Cancel dialogs to try, in order, for this
+// platform. Each prints the chosen label, or exits non-zero on Cancel.
+var confirmDialogs = func(prompt string, labels []string) []dialogCommand {
+	switch runtime.GOOS {
+	case "darwin":
+		quoted := make([]string, len(labels))
+		for i, label := range labels {
+			quoted[i] = fmt.Sprintf("%q", label)
+		}
+		script := fmt.Sprintf(`set chosen to choose from list {%s} with title %q with prompt %q default items {%q} OK button name "Add" cancel button name "Cancel"
+if chosen is false then error number -128
+item 1 of chosen`, strings.Join(quoted, ", "), pasteTitle, prompt, labels[0])
+		return []dialogCommand{{"osascript", []string{"-e", script}}}
+	case "windows":
+		items := make([]string, len(labels))
+		for i, label := range labels {
+			items[i] = powershellQuote(label)
+		}
+		script := "Add-Type -AssemblyName System.Windows.Forms; " +
+			"$f = New-Object Windows.Forms.Form; $f.Text = " + powershellQuote(pasteTitle) + "; $f.Width = 520; $f.Height = 170; " +
+			"$f.StartPosition = 'CenterScreen'; $f.TopMost = $true; $f.FormBorderStyle = 'FixedDialog'; " +
+			"$t = New-Object Windows.Forms.Label; $t.Text = " + powershellQuote(prompt) + "; $t.Left = 10; $t.Top = 10; $t.Width = 480; " +
+			"$c = New-Object Windows.Forms.ComboBox; $c.Left = 10; $c.Top = 40; $c.Width = 480; " +
+			"[void]$c.Items.AddRange(@(" + strings.Join(items, ", ") + ")); $c.Text = " + powershellQuote(labels[0]) + "; " +
+			"$ok = New-Object Windows.Forms.Button; $ok.Text = 'Add'; $ok.Left = 320; $ok.Top = 85; $ok.DialogResult = 'OK'; " +
+			"$no = New-Object Windows.Forms.Button; $no.Text = 'Cancel'; $no.Left = 410; $no.Top = 85; $no.DialogResult = 'Cancel'; " +
+			"$f.Controls.AddRange(@($t, $c, $ok, $no)); $f.AcceptButton = $ok; $f.CancelButton = $no; " +
+			"if ($f.ShowDialog() -eq 'OK') { $c.Text } else { exit 1 }"
+		return []dialogCommand{{"powershell", []string{"-NoProfile", "-WindowStyle", "Hidden", "-Command", script}}}
+	default:
+		zenity := []string{"--list", "--radiolist", "--title", pasteTitle, "--text", prompt,
+			"--column", "", "--column", "Label", "--ok-label", "Add", "--cancel-label", "Cancel", "--width", "500", "--height", "300"}
+		for i, label := range labels {
+			zenity = append(zenity, fmt.Sprint(i == 0), label)
+		}
+		kdialog := append([]string{"--title", pasteTitle, "--ok-label", "Add", "--cancel-label", "Cancel", "--combobox", prompt}, labels...)
+		kdialog = append(kdialog, "--default", labels[0])
+		return []dialogCommand{{"zenity", zenity}, {"kdialog", kdialog}}
+	}
+}
+
+// confirmLabels returns the labels to offer: the one the magnet would get
+// first, then every other configured label, sorted
+func confirmLabels(config Config, magnetURI string) []string {
+	current := config.DelugeLabel
+	if !explicitLabel {
+		if label, _ := trackerLabel(config, magnetURI); label != "" {
+			current = label
+		}
+	}
+
+	seen := map[string]bool{current: true, "": true}
+	var others []string
+	add := func(label string) {
+		if !seen[label] {
+			seen[label] = true
+			others = append(others, label)
+		}
+	}
+	add(config.DelugeLabel)
+	for label := range config.LabelOptions {
+		add(label)
+	}
+	for _, label := range config.TrackerLabels {
+		add(label)
+	}
+	sort.Strings(others)
+	return append([]string{current}, others...)
+}
+
+// ConfirmAdd asks whether to add a clicked magnet, showing its name and a
+// label choice, and returns the label picked. It returns errDialogCancelled
+// when the user cancels, or errNoDialog when no dialog program is installed.
+func ConfirmAdd(rawURI string, config Config) (string, error) {
+	magnetURI, _, _ := resolveSource(rawURI)
+	name := ExtractMagnetName(magnetURI)
+	if name == "" {
+		name = ExtractMagnetHash(magnetURI)
+	}
+	prompt := fmt.Sprintf("Add %s to Deluge?", name)
+
+	label, err := runDialog(confirmDialogs(prompt, confirmLabels(config, magnetURI)))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(label), nil
+}
+
+// addWithLabel adds the magnet under label as if --label had chosen it, or
+// with the usual label when label is empty
+func addWithLabel(rawURI string, config Config, label string) error {
+	if label == "" {
+		return AddMagnetToDeluge(rawURI, config)
+	}
+	defer func(was bool) { explicitLabel = was }(explicitLabel)
+	explicitLabel = true
+	config.DelugeLabel = label
+	return AddMagnetToDeluge(rawURI, config)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// Test the label the magnet would get is offered first, then the others
+func TestConfirmLabels(t *testing.T) {
+	config := Config{
+		DelugeLabel:   "audiobooks",
+		LabelOptions:  map[string]LabelOptions{"books": {}, "audiobooks": {}},
+		TrackerLabels: map[string]string{"flac.example.org": "music"},
+	}
+	uri := "magnet:?xt=urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a&tr=udp://flac.example.org:80/announce"
+
+	if got := strings.Join(confirmLabels(config, uri), ","); got != "music,audiobooks,books" {
+		t.Errorf("Expected the routed label first, got %s", got)
+	}
+	explicitLabel = true
+	defer func() { explicitLabel = false }()
+	if got := strings.Join(confirmLabels(config, uri), ","); got != "audiobooks,books,music" {
+		t.Errorf("--label should be offered first, got %s", got)
+	}
+}
+
+// Test confirm_adds adds under the label picked in the dialog, and adds
+// nothing when it is cancelled
+func TestConfirmAdd(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Fake dialogs in this test need a Unix shell")
+	}
+
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	t.Setenv("HOME", tmpDir)
+
+	config := DefaultConfig()
+	enableSimulation(&config, tmpDir)
+	config.RemotePath = ""
+	config.MetadataTimeout = -1
+	config.ConfirmAdds = true
+	defer func() { simulateDeluge = false }()
+
+	var prompts []string
+	original := confirmDialogs
+	defer func() { confirmDialogs = original }()
+	answer := func(output string) {
+		confirmDialogs = func(prompt string, labels []string) []dialogCommand {
+			prompts = append(prompts, prompt)
+			return []dialogCommand{{"sh", []string{"-c", "printf '%s' \"$0\"", output}}}
+		}
+	}
+
+	cancelled := "magnet:?xt=urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a&dn=Not+Wanted"
+	answer("")
+	if err := runAdd(config, []string{cancelled}); err != nil {
+		t.Fatalf("Cancelled add should not fail: %v", err)
+	}
+
+	added := "magnet:?xt=urn:btih:d22fe1c06bba254a9dc9f519b335aa7c1367a88a&dn=Wanted+Book"
+	answer("books")
+	if err := runAdd(config, []string{added}); err != nil {
+		t.Fatalf("runAdd failed: %v", err)
+	}
+
+	db, err := LoadJSONDatabase(config.JSONPath)
+	if err != nil {
+		t.Fatalf("LoadJSONDatabase failed: %v", err)
+	}
+	if _, ok := db.Added["c12fe1c06bba254a9dc9f519b335aa7c1367a88a"]; ok {
+		t.Error("A cancelled magnet should not be added")
+	}
+	if entry, ok := db.Added["d22fe1c06bba254a9dc9f519b335aa7c1367a88a"]; !ok || entry.Label != "books" {
+		t.Errorf("Expected the magnet added with the chosen label, got %+v", entry)
+	}
+	if len(prompts) != 2 || prompts[1] != "Add Wanted Book to Deluge?" {
+		t.Errorf("Expected the decoded name in the prompt, got %v", prompts)
+	}
+	if explicitLabel {
+		t.Error("The chosen label should not stick after the add")
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"text/tabwriter"
+)
+
+// conflictedEntries returns the entries a merge flagged as diverged between
+// machines, sorted by title
+func conflictedEntries(db *MagnetDatabase) []ListedEntry {
+	var entries []ListedEntry
+	for _, section := range []struct {
+		name    string
+		entries map[string]MagnetEntry
+	}{
+		{SectionAdded, db.Added},
+		{SectionRetry, db.Retry},
+		{SectionRemoved, db.Removed},
+	} {
+		for hash, entry := range section.entries {
+			if !entry.Conflict {
+				continue
+			}
+			if entry.Hash == "" {
+				entry.Hash = hash
+			}
+			entries = append(entries, ListedEntry{Section: section.name, MagnetEntry: entry})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		ti, tj := listColumns["title"](entries[i]), listColumns["title"](entries[j])
+		if ti != tj {
+			return ti < tj
+		}
+		return entries[i].Hash < entries[j].Hash
+	})
+	return entries
+}
+
+// clearConflicts drops the conflict flag from the given entries, keeping
+// the copy the merge chose, and returns how many it cleared
+func clearConflicts(config Config, entries []ListedEntry) (int, error) {
+	update := &MagnetDatabase{
+		Added:   make(map[string]MagnetEntry),
+		Retry:   make(map[string]MagnetEntry),
+		Removed: make(map[string]MagnetEntry),
+	}
+	for _, listed := range entries {
+		entry := listed.MagnetEntry
+		entry.Conflict, entry.ConflictInfo = false, ""
+		switch listed.Section {
+		case SectionAdded:
+			update.Added[entry.Hash] = entry
+		case SectionRetry:
+			update.Retry[entry.Hash] = entry
+		case SectionRemoved:
+			update.Removed[entry.Hash] = entry
+		}
+	}
+	if len(entries) == 0 {
+		return 0, nil
+	}
+	if err := SaveJSONDatabase(config.JSONPath, update, &config); err != nil {
+		return 0, fmt.Errorf("failed to save database: %w", err)
+	}
+	return len(entries), nil
+}
+
+// runConflicts implements the conflicts command
+func runConflicts(config Config, args []string) error {
+	fs := newCommandFlags(conflictsCommand)
+	clearFlag := fs.Bool("clear", false, "Keep the merged copy of the given entries and drop their conflict flag")
+	all := fs.Bool("all", false, "With --clear, clear every flagged entry")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if !*clearFlag && (*all || fs.NArg() > 0) {
+		fs.Usage()
+		return fmt.Errorf("hashes and --all need --clear")
+	}
+	if *clearFlag && *all == (fs.NArg() > 0) {
+		fs.Usage()
+		return fmt.Errorf("--clear needs either hashes or --all")
+	}
+
+	db, err := LoadJSONDatabase(config.JSONPath)
+	if err != nil {
+		return fmt.Errorf("failed to load database: %w", err)
+	}
+	flagged := conflictedEntries(db)
+
+	if !*clearFlag {
+		if len(flagged) == 0 {
+			log.Println("✓ No entries are flagged as conflicting")
+			return nil
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "SECTION\tHASH\tTITLE\tCONFLICT")
+		for _, entry := range flagged {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", entry.Section, listColumns["hash"](entry), listColumns["title"](entry), entry.ConflictInfo)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		log.Printf("%s flagged; after checking each in Deluge, clear them with: magnet-handler conflicts --clear <hash>...",
+			plural(len(flagged), "entry", "entries"))
+		return nil
+	}
+
+	selected := flagged
+	if !*all {
+		selected = nil
+		for _, key := range fs.Args() {
+			hash, section, entry, ok := findEntry(db, key)
+			if !ok {
+				return fmt.Errorf("no entry found for %q", key)
+			}
+			if !entry.Conflict {
+				return fmt.Errorf("entry %s is not flagged as conflicting", hash[:min(8, len(hash))])
+			}
+			entry.Hash = hash
+			selected = append(selected, ListedEntry{Section: section, MagnetEntry: entry})
+		}
+	}
+	cleared, err := clearConflicts(config, selected)
+	if err != nil {
+		return err
+	}
+	log.Printf("✓ Cleared the conflict flag on %s", plural(cleared, "entry", "entries"))
+	return nil
+}
+
+var conflictsCommand = &Command{
+	Name:    "conflicts",
+	Usage:   "conflicts [--clear <hash|uuid>... | --clear --all]",
+	Summary: "List entries a merge flagged as diverged between machines, or clear the flag",
+}
+
+func init() {
+	conflictsCommand.Run = runConflicts
+	registerCommand(conflictsCommand)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Test the conflicts command lists flagged entries and clears the flag on
+// the local database and the remote
+func TestConflicts(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	t.Setenv("HOME", tmpDir)
+
+	config := DefaultConfig()
+	config.JSONPath = filepath.Join(tmpDir, "db.json")
+	config.RemotePath = filepath.Join(tmpDir, "remote.json")
+	db := &MagnetDatabase{
+		Added: map[string]MagnetEntry{
+			"hash1": {Hash: "hash1", Title: "Diverged", AddedDate: "2024-01-01T00:00:00Z", Conflict: true, ConflictInfo: "retry attempt #1"},
+			"hash2": {Hash: "hash2", Title: "Also diverged", AddedDate: "2024-01-01T00:00:00Z", Conflict: true, ConflictInfo: "retry attempt #2"},
+			"hash3": {Hash: "hash3", Title: "Fine", AddedDate: "2024-01-01T00:00:00Z"},
+		},
+		Retry: map[string]MagnetEntry{},
+	}
+	for _, path := range []string{config.JSONPath, config.RemotePath} {
+		if err := SaveDatabaseLocal(path, db); err != nil {
+			t.Fatalf("Failed to save database: %v", err)
+		}
+	}
+
+	flagged := conflictedEntries(db)
+	if len(flagged) != 2 || flagged[0].Hash != "hash2" || flagged[1].Hash != "hash1" {
+		t.Fatalf("Expected hash2 and hash1 flagged in title order, got %+v", flagged)
+	}
+	if got := listColumns["section"](flagged[0]); got != "added (conflict)" {
+		t.Errorf("Expected the section column to mark the conflict, got %q", got)
+	}
+
+	if err := runConflicts(config, []string{"hash1"}); err == nil {
+		t.Error("Hashes without --clear should be rejected")
+	}
+	if err := runConflicts(config, []string{"--clear", "hash3"}); err == nil {
+		t.Error("Clearing an entry without a conflict should fail")
+	}
+	if err := runConflicts(config, []string{"--clear", "hash1"}); err != nil {
+		t.Fatalf("conflicts --clear failed: %v", err)
+	}
+	for _, path := range []string{config.JSONPath, config.RemotePath} {
+		saved, err := LoadJSONDatabase(path)
+		if err != nil {
+			t.Fatalf("LoadJSONDatabase failed: %v", err)
+		}
+		if entry := saved.Added["hash1"]; entry.Conflict || entry.ConflictInfo != "" {
+			t.Errorf("%s: hash1 should no longer be flagged, got %+v", path, entry)
+		}
+		if !saved.Added["hash2"].Conflict {
+			t.Errorf("%s: hash2 should still be flagged", path)
+		}
+	}
+
+	if err := runConflicts(config, []string{"--clear", "--all"}); err != nil {
+		t.Fatalf("conflicts --clear --all failed: %v", err)
+	}
+	saved, err := LoadJSONDatabase(config.JSONPath)
+	if err != nil {
+		t.Fatalf("LoadJSONDatabase failed: %v", err)
+	}
+	if len(conflictedEntries(saved)) != 0 {
+		t.Errorf("Expected no flagged entries after --all, got %+v", conflictedEntries(saved))
+	}
+}
@@ -0,0 +1,546 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// runAdd implements the add command, which is also what runs when the
+// handler is given a magnet URI with no command
+func runAdd(config Config, args []string) error {
+	fs := newCommandFlags(addCommand)
+	dryRun := fs.Bool("dry-run", false, "Show what adding would do without adding the magnet or saving the database")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return fmt.Errorf("expected one magnet URI")
+	}
+
+	rawURI, err := ResolveMagnetArgument(fs.Args())
+	if err != nil {
+		return err
+	}
+	if *dryRun {
+		preview, err := previewAdd(rawURI, config, func() (*DelugeClient, error) { return connectDeluge(config) })
+		if err != nil {
+			return err
+		}
+		logAddPreview(preview)
+		return nil
+	}
+
+	// Show what was clicked and let the user pick the label or back out
+	label := ""
+	if config.ConfirmAdds {
+		label, err = ConfirmAdd(rawURI, config)
+		switch {
+		case errors.Is(err, errDialogCancelled):
+			log.Println("Add cancelled; magnet not added")
+			return nil
+		case err != nil:
+			log.Printf("Warning: Could not ask for confirmation, adding anyway: %v", err)
+			label = ""
+		}
+	}
+
+	// Tell whoever clicked the magnet how it went, since the console window
+	// is often hidden or gone
+	add := func(uri, label string) error { return addWithLabel(uri, config, label) }
+	if clickedMagnet && !config.DisableAddNotifications {
+		notifiers = append(notifiers, desktopNotifier{})
+		add = func(uri, label string) error {
+			return notifyAddResult(uri, config, func() error { return addWithLabel(uri, config, label) })
+		}
+	}
+
+	// Hand off to a running instance so rapid clicks are processed one at a
+	// time instead of racing on the database. Forwarded magnets use the
+	// running instance's settings.
+	var server *InstanceServer
+	if !simulateDeluge {
+		err := ForwardToInstance(rawURI, label)
+		if err == nil {
+			log.Println("✓ Forwarded to running magnet-handler instance")
+			return nil
+		}
+		if isForwardedError(err) {
+			return err
+		}
+		if server, err = StartInstanceServer(); err != nil {
+			log.Printf("Warning: Single-instance mode unavailable: %v", err)
+		}
+	}
+
+	// Process magnet
+	if err := add(rawURI, label); err != nil {
+		if server != nil {
+			server.Close()
+		}
+		return err
+	}
+
+	if simulateDeluge {
+		return nil
+	}
+
+	// Keep the app open for a moment so we can see output
+	// This is especially useful when launched from browsers
+	log.Println("\n=== Magnet Handler Complete ===")
+	log.Println("Keeping window open for 90 seconds to view results...")
+	log.Println("Press Ctrl+C to close earlier if needed")
+
+	if server == nil {
+		// Sleep for 90 seconds to allow viewing the output
+		time.Sleep(90 * time.Second)
+		return nil
+	}
+
+	// Process magnets clicked while this window is open, staying open for
+	// 90 seconds after the last one
+	server.Serve(90*time.Second, add)
+	return nil
+}
+
+// runRetry implements the retry command
+func runRetry(config Config, args []string) error {
+	fs := newCommandFlags(retryCommand)
+	hash := fs.String("hash", "", "Retry only the entry with this hash (or unique prefix, or UUID)")
+	match := fs.String("match", "", "Retry only entries whose title matches this glob (e.g. '*dune*')")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+
+	if err := ProcessRetryEntries(config, RetryFilter{Hash: *hash, Match: *match}); err != nil {
+		return fmt.Errorf("failed to process retry queue: %w", err)
+	}
+	if err := notifyViews(config); err != nil {
+		log.Printf("Warning: Could not check views: %v", err)
+	}
+	return nil
+}
+
+// runSync implements the sync command
+func runSync(config Config, args []string) error {
+	fs := newCommandFlags(syncCommand)
+	dryRun := fs.Bool("dry-run", false, "Show what would change without writing")
+	remote := fs.Bool("remote", false, "Merge with the remote databases instead of checking Deluge")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+
+	switch {
+	case *remote && *dryRun:
+		return SyncRemoteDryRun(config, os.Stdout)
+	case *remote:
+		if len(GetRemotePaths(&config)) == 0 {
+			return fmt.Errorf("no remote path configured")
+		}
+		if err := syncRemoteDatabase(config); err != nil {
+			return err
+		}
+		log.Println("✓ Synced with remote databases")
+		return nil
+	}
+	return SyncWithDeluge(config, *dryRun)
+}
+
+// runBackfill implements the backfill command
+func runBackfill(config Config, args []string) error {
+	fs := newCommandFlags(backfillCommand)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	return BackfillFromDeluge(config)
+}
+
+// runMigrate implements the migrate command
+func runMigrate(config Config, args []string) error {
+	fs := newCommandFlags(migrateCommand)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	log.Println("Migrating both local and remote databases...")
+
+	// Migrate local
+	if err := MigrateFileFormat(config.JSONPath); err != nil {
+		log.Printf("Error migrating local: %v", err)
+	}
+
+	// Migrate remotes
+	remotes := GetRemotePaths(&config)
+	for _, remotePath := range remotes {
+		if err := migrateRemoteDatabase(remotePath); err != nil {
+			log.Printf("Error migrating remote %s: %v", displayRemote(remotePath), err)
+		}
+	}
+	if len(remotes) == 0 {
+		log.Println("No remote path configured, skipping remote migration")
+	}
+
+	log.Println("✓ Migration complete")
+	return nil
+}
+
+// runCheckComplete implements the check-complete command
+func runCheckComplete(config Config, args []string) error {
+	fs := newCommandFlags(checkCompleteCommand)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := CheckCompletion(config); err != nil {
+		return err
+	}
+	if err := notifyViews(config); err != nil {
+		log.Printf("Warning: Could not check views: %v", err)
+	}
+	return nil
+}
+
+// pauseRunner implements the pause and resume commands
+func pauseRunner(cmd *Command, paused bool) func(Config, []string) error {
+	return func(config Config, args []string) error {
+		fs := newCommandFlags(cmd)
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		if fs.NArg() != 1 {
+			fs.Usage()
+			return fmt.Errorf("expected a hash or label")
+		}
+		return SetTorrentsPaused(config, fs.Arg(0), paused)
+	}
+}
+
+// runExport implements the export command
+func runExport(config Config, args []string) error {
+	fs := newCommandFlags(exportCommand)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		fs.Usage()
+		return fmt.Errorf("expected a format and an optional path")
+	}
+	return RunExport(config, fs.Arg(0), fs.Arg(1))
+}
+
+// runPaste implements the paste command
+func runPaste(config Config, args []string) error {
+	fs := newCommandFlags(pasteCommand)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	notifiers = append(notifiers, desktopNotifier{})
+	return RunPaste(config)
+}
+
+// runResetAuth implements the reset-auth command
+func runResetAuth(config Config, args []string) error {
+	fs := newCommandFlags(resetAuthCommand)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := ResetAuthBreaker(); err != nil {
+		return err
+	}
+	log.Println("✓ Authentication failures cleared")
+	return nil
+}
+
+// runRegister implements the register command
+func runRegister(config Config, args []string) error {
+	fs := newCommandFlags(registerHandlerCommand)
+	browserList := fs.String("browser", "", "Also configure firefox, chrome, or edge (comma-separated) to open magnet links without asking")
+	originList := fs.String("origins", strings.Join(config.BrowserOrigins, ","), "Sites Chrome and Edge may open magnet links from without asking (comma-separated, e.g. https://tracker.example)")
+	test := fs.Bool("test", false, "Launch the registered handler with a test magnet in simulation mode")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	browsers, err := ParseBrowsers(*browserList)
+	if err != nil {
+		return fmt.Errorf("invalid --browser: %w", err)
+	}
+	origins, err := ParseBrowserOrigins(*originList)
+	if err != nil {
+		return fmt.Errorf("invalid --origins: %w", err)
+	}
+
+	exePath, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to get executable path: %w", err)
+	}
+	if err := RegisterProtocolHandler(exePath); err != nil {
+		return fmt.Errorf("failed to register protocol handler: %w", err)
+	}
+	for _, browser := range browsers {
+		if err := RegisterBrowser(browser, exePath, origins); err != nil {
+			log.Printf("✗ Failed to configure %s: %v", browser, err)
+		}
+	}
+	if *test {
+		log.Println("Testing protocol handler...")
+		if err := RunHandlerSelfTest(); err != nil {
+			return fmt.Errorf("self-test failed: %w", err)
+		}
+		log.Println("✓ Protocol handler self-test passed")
+	}
+	return nil
+}
+
+// runUnregister implements the unregister command
+func runUnregister(config Config, args []string) error {
+	fs := newCommandFlags(unregisterHandlerCommand)
+	browserList := fs.String("browser", "", "Also stop firefox, chrome, or edge (comma-separated) opening magnet links without asking")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	browsers, err := ParseBrowsers(*browserList)
+	if err != nil {
+		return fmt.Errorf("invalid --browser: %w", err)
+	}
+
+	if err := UnregisterProtocolHandler(); err != nil {
+		return fmt.Errorf("failed to unregister protocol handler: %w", err)
+	}
+	for _, browser := range browsers {
+		if err := UnregisterBrowser(browser); err != nil {
+			log.Printf("✗ Failed to unconfigure %s: %v", browser, err)
+		}
+	}
+	return nil
+}
+
+// runVersion implements the version command
+func runVersion(config Config, args []string) error {
+	fmt.Printf("magnet-handler version %s\n", version)
+	return nil
+}
+
+// runHelp implements the help command
+func runHelp(config Config, args []string) error {
+	if len(args) == 0 {
+		flag.Usage()
+		return nil
+	}
+	cmd, ok := lookupCommand(args[0])
+	if !ok {
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+	return cmd.Run(config, []string{"-h"})
+}
+
+var (
+	addCommand = &Command{
+		Name:    "add",
+		Usage:   "add [--dry-run] <magnet-uri | @file>",
+		Summary: "Add a magnet link to Deluge and record it (the default when given a URI)",
+	}
+	retryCommand = &Command{
+		Name:    "retry",
+		Usage:   "retry [--hash hash] [--match glob]",
+		Summary: "Process the retry queue",
+	}
+	syncCommand = &Command{
+		Name:    "sync",
+		Usage:   "sync [--dry-run] [--remote]",
+		Summary: "Remove entries for torrents no longer in Deluge, or merge with the remotes",
+	}
+	backfillCommand = &Command{
+		Name:    "backfill",
+		Usage:   "backfill",
+		Summary: "Backfill the database from existing Deluge torrents",
+	}
+	migrateCommand = &Command{
+		Name:    "migrate",
+		Usage:   "migrate",
+		Summary: "Migrate JSON files to the current format with proper checksums",
+	}
+	checkCompleteCommand = &Command{
+		Name:    "check-complete",
+		Usage:   "check-complete",
+		Summary: "Record download state and progress from Deluge",
+	}
+	pauseCommand = &Command{
+		Name:    "pause",
+		Usage:   "pause <hash|label>",
+		Summary: "Pause a tracked torrent, or all tracked torrents with a label",
+	}
+	resumeCommand = &Command{
+		Name:    "resume",
+		Usage:   "resume <hash|label>",
+		Summary: "Resume a tracked torrent, or all tracked torrents with a label",
+	}
+	exportCommand = &Command{
+		Name:    "export",
+		Usage:   "export <csv|jsonl|ics> [path]",
+		Summary: "Export entries as csv or jsonl, or completions as ics (default stdout)",
+	}
+	pasteCommand = &Command{
+		Name:    "paste",
+		Usage:   "paste",
+		Summary: "Ask for a magnet link in a dialog and add it",
+	}
+	resetAuthCommand = &Command{
+		Name:    "reset-auth",
+		Usage:   "reset-auth",
+		Summary: "Clear recorded Deluge authentication failures",
+	}
+	registerHandlerCommand = &Command{
+		Name:     "register",
+		Usage:    "register [--browser list] [--origins list] [--test]",
+		Summary:  "Register as the magnet protocol handler",
+		NoConfig: true,
+	}
+	unregisterHandlerCommand = &Command{
+		Name:     "unregister",
+		Usage:    "unregister [--browser list]",
+		Summary:  "Unregister the magnet protocol handler",
+		NoConfig: true,
+	}
+	versionCommand = &Command{
+		Name:     "version",
+		Usage:    "version",
+		Summary:  "Show the version",
+		NoConfig: true,
+	}
+	helpCommand = &Command{
+		Name:     "help",
+		Usage:    "help [command]",
+		Summary:  "Show usage for the handler or one command",
+		NoConfig: true,
+	}
+)
+
+func init() {
+	addCommand.Run = runAdd
+	retryCommand.Run = runRetry
+	syncCommand.Run = runSync
+	backfillCommand.Run = runBackfill
+	migrateCommand.Run = runMigrate
+	checkCompleteCommand.Run = runCheckComplete
+	pauseCommand.Run = pauseRunner(pauseCommand, true)
+	resumeCommand.Run = pauseRunner(resumeCommand, false)
+	exportCommand.Run = runExport
+	pasteCommand.Run = runPaste
+	resetAuthCommand.Run = runResetAuth
+	registerHandlerCommand.Run = runRegister
+	unregisterHandlerCommand.Run = runUnregister
+	versionCommand.Run = runVersion
+	helpCommand.Run = runHelp
+	for _, cmd := range []*Command{addCommand, retryCommand, syncCommand, backfillCommand, migrateCommand,
+		checkCompleteCommand, pauseCommand, resumeCommand, exportCommand, pasteCommand, resetAuthCommand,
+		registerHandlerCommand, unregisterHandlerCommand, versionCommand, helpCommand} {
+		registerCommand(cmd)
+	}
+}
+
+// commandName matches arguments that look like a command rather than a
+// magnet URI or @file
+var commandName = regexp.MustCompile(`^[a-z][a-z-]*$`)
+
+// legacyFlag describes an old top-level flag and the command it became
+type legacyFlag struct {
+	command string   // Command the flag selects ("" for flags that only modify one)
+	args    []string // Command arguments it expands to
+	value   bool     // Takes a value, appended after args
+}
+
+// legacyFlags maps the flags used before commands existed to their commands,
+// so scheduled tasks and shortcuts written for them keep working
+var legacyFlags = map[string]legacyFlag{
+	"register":            {command: "register"},
+	"unregister":          {command: "unregister"},
+	"test":                {args: []string{"--test"}},
+	"browser":             {args: []string{"--browser"}, value: true},
+	"retry":               {command: "retry"},
+	"retry-hash":          {command: "retry", args: []string{"--hash"}, value: true},
+	"retry-match":         {command: "retry", args: []string{"--match"}, value: true},
+	"backfill":            {command: "backfill"},
+	"sync":                {command: "sync"},
+	"sync-dry-run":        {command: "sync", args: []string{"--dry-run"}},
+	"sync-remote-dry-run": {command: "sync", args: []string{"--remote", "--dry-run"}},
+	"migrate":             {command: "migrate"},
+	"check-complete":      {command: "check-complete"},
+	"pause":               {command: "pause", value: true},
+	"resume":              {command: "resume", value: true},
+	"version":             {command: "version"},
+	"reset-auth":          {command: "reset-auth"},
+	"export":              {command: "export", value: true},
+	"paste":               {command: "paste"},
+}
+
+// legacyArgs rewrites a command line that uses the old top-level flags
+// (--retry, --sync-dry-run, --export csv ...) into the command form, keeping
+// the global flags from fs in front. It returns the first legacy flag it
+// rewrote, or "" when the arguments needed no change.
+func legacyArgs(fs *flag.FlagSet, args []string) ([]string, string) {
+	var global, commandArgs, rest []string
+	command, used := "", ""
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" || !strings.HasPrefix(arg, "-") {
+			rest = args[i:]
+			break
+		}
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		legacy, ok := legacyFlags[name]
+		if !ok || fs.Lookup(name) != nil {
+			global = append(global, arg)
+			if f := fs.Lookup(name); f != nil && !hasValue && !isBoolFlag(f) && i+1 < len(args) {
+				i++
+				global = append(global, args[i])
+			}
+			continue
+		}
+
+		if legacy.value && !hasValue {
+			if i+1 == len(args) {
+				return args, "" // Leave the missing value for the parser to report
+			}
+			i++
+			value = args[i]
+		}
+		if legacy.command != "" {
+			if command != "" && command != legacy.command {
+				continue // The old flags were exclusive; the first one won
+			}
+			command = legacy.command
+		}
+		if used == "" {
+			used = "--" + name
+		}
+		commandArgs = append(commandArgs, legacy.args...)
+		if legacy.value {
+			commandArgs = append(commandArgs, value)
+		}
+	}
+	if command == "" {
+		return args, ""
+	}
+
+	rewritten := append(global, command)
+	rewritten = append(rewritten, commandArgs...)
+	return append(rewritten, rest...), used
+}
+
+// isBoolFlag reports whether a flag takes no value
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"flag"
+	"reflect"
+	"testing"
+)
+
+// Test the old top-level flags are rewritten into commands, with global
+// flags kept in front
+func TestLegacyArgs(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.String("host", "", "")
+	fs.Bool("paused", false, "")
+
+	tests := []struct {
+		args []string
+		want []string
+		used string
+	}{
+		{[]string{"--retry"}, []string{"retry"}, "--retry"},
+		{[]string{"--host", "10.0.0.2", "--retry-hash", "3f2a"}, []string{"--host", "10.0.0.2", "retry", "--hash", "3f2a"}, "--retry-hash"},
+		{[]string{"--retry", "--retry-match=*dune*"}, []string{"retry", "--match", "*dune*"}, "--retry"},
+		{[]string{"--sync-remote-dry-run"}, []string{"sync", "--remote", "--dry-run"}, "--sync-remote-dry-run"},
+		{[]string{"--export", "csv", "out.csv"}, []string{"export", "csv", "out.csv"}, "--export"},
+		{[]string{"--register", "--browser", "firefox", "--test"}, []string{"register", "--browser", "firefox", "--test"}, "--register"},
+		{[]string{"--paused", "-pause", "books"}, []string{"--paused", "pause", "books"}, "--pause"},
+		{[]string{"--host", "10.0.0.2", "retry", "--hash", "3f2a"}, []string{"--host", "10.0.0.2", "retry", "--hash", "3f2a"}, ""},
+		{[]string{"magnet:?xt=urn:btih:abc"}, []string{"magnet:?xt=urn:btih:abc"}, ""},
+		{[]string{"--export"}, []string{"--export"}, ""},
+	}
+
+	for _, tt := range tests {
+		got, used := legacyArgs(fs, tt.args)
+		if !reflect.DeepEqual(got, tt.want) || used != tt.used {
+			t.Errorf("legacyArgs(%q) = %q, %q; want %q, %q", tt.args, got, used, tt.want, tt.used)
+		}
+	}
+}
+
+// Test command names are told apart from magnet URIs and @files
+func TestCommandName(t *testing.T) {
+	for _, arg := range []string{"retry", "check-complete", "retyr"} {
+		if !commandName.MatchString(arg) {
+			t.Errorf("%q should look like a command", arg)
+		}
+	}
+	for _, arg := range []string{"magnet:?xt=urn:btih:abc", "magnet%3A%3Fxt", "@uri.txt", "MAGNET:?xt"} {
+		if commandName.MatchString(arg) {
+			t.Errorf("%q should not look like a command", arg)
+		}
+	}
+}
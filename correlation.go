@@ -0,0 +1,90 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"sync"
+)
+
+// correlationHeader carries the correlation ID on HTTP responses
+const correlationHeader = "X-Correlation-ID"
+
+// validCorrelationID limits caller-supplied IDs to characters safe in logs
+var validCorrelationID = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+var (
+	correlationMu sync.Mutex
+	correlationID string
+)
+
+// newCorrelationID returns a short random ID for one invocation or operation
+func newCorrelationID() string {
+	b := make([]byte, 3)
+	rand.Read(b)
+	return fmt.Sprintf("%x", b)
+}
+
+// CorrelationID returns the ID of the operation currently being logged
+func CorrelationID() string {
+	correlationMu.Lock()
+	defer correlationMu.Unlock()
+	return correlationID
+}
+
+// setCorrelationID tags every following log line with id and returns a
+// function that restores the previous ID
+func setCorrelationID(id string) func() {
+	correlationMu.Lock()
+	previous := correlationID
+	correlationID = id
+	correlationMu.Unlock()
+
+	log.SetFlags(log.Flags() | log.Lmsgprefix)
+	log.SetPrefix("[" + id + "] ")
+	return func() {
+		correlationMu.Lock()
+		correlationID = previous
+		correlationMu.Unlock()
+		if previous == "" {
+			log.SetPrefix("")
+		} else {
+			log.SetPrefix("[" + previous + "] ")
+		}
+	}
+}
+
+// withCorrelationID returns a copy of config whose operation logs every
+// line under id. The daemon runs requests and jobs side by side, so each
+// carries its own logger instead of changing the process-wide prefix.
+func (c Config) withCorrelationID(id string) Config {
+	if id != "" {
+		c.opLogger = log.New(log.Writer(), "["+id+"] ", log.Flags()|log.Lmsgprefix)
+	}
+	return c
+}
+
+// logger returns the logger for the operation config belongs to: its own
+// when tagged with withCorrelationID, otherwise the standard logger
+func (c Config) logger() *log.Logger {
+	if c.opLogger != nil {
+		return c.opLogger
+	}
+	return log.Default()
+}
+
+// withCorrelation assigns each HTTP request its own correlation ID, returned
+// in the X-Correlation-ID header and in error bodies. A caller-supplied ID is
+// kept so clients can trace their requests end to end.
+func withCorrelation(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(correlationHeader)
+		if !validCorrelationID.MatchString(id) {
+			id = newCorrelationID()
+		}
+		w.Header().Set(correlationHeader, id)
+		next.ServeHTTP(w, r)
+	})
+}
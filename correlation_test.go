@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Test setCorrelationID tags log lines and restores the previous ID
+func TestSetCorrelationID(t *testing.T) {
+	var buf bytes.Buffer
+	original := log.Writer()
+	log.SetOutput(&buf)
+	defer log.SetOutput(original)
+	defer setCorrelationID("")()
+
+	restoreOuter := setCorrelationID("outer1")
+	restoreInner := setCorrelationID("inner2")
+	log.Print("inside")
+	restoreInner()
+	log.Print("outside")
+	restoreOuter()
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], "[inner2] inside") || !strings.Contains(lines[1], "[outer1] outside") {
+		t.Errorf("Unexpected log output: %q", lines)
+	}
+	if CorrelationID() != "" {
+		t.Errorf("Expected ID to be restored to empty, got %q", CorrelationID())
+	}
+}
+
+// lockedBuffer is a log output safe for concurrent writers, as the log file
+// and console are
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// Test operations tagged with withCorrelationID log side by side under
+// their own IDs without touching the process-wide prefix
+func TestWithCorrelationID(t *testing.T) {
+	var buf lockedBuffer
+	original := log.Writer()
+	log.SetOutput(&buf)
+	defer log.SetOutput(original)
+	defer setCorrelationID("")()
+	setCorrelationID("process")
+
+	var wg sync.WaitGroup
+	for _, id := range []string{"first1", "second2"} {
+		wg.Add(1)
+		go func(config Config) {
+			defer wg.Done()
+			config.logger().Print("working")
+		}(Config{}.withCorrelationID(id))
+	}
+	wg.Wait()
+	Config{}.logger().Print("untagged")
+
+	out := buf.String()
+	for _, want := range []string{"[first1] working", "[second2] working", "[process] untagged"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %q in log output %q", want, out)
+		}
+	}
+	if CorrelationID() != "process" {
+		t.Errorf("Expected the process ID untouched, got %q", CorrelationID())
+	}
+}
+
+// Test withCorrelation keeps safe caller IDs and replaces unsafe ones
+func TestWithCorrelation(t *testing.T) {
+	handler := withCorrelation(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		name   string
+		header string
+		keep   bool
+	}{
+		{"generated", "", false},
+		{"caller supplied", "client-42", true},
+		{"log injection", "evil\nforged line", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set(correlationHeader, tt.header)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			id := rec.Header().Get(correlationHeader)
+			if !validCorrelationID.MatchString(id) {
+				t.Errorf("Invalid correlation ID %q", id)
+			}
+			if (id == tt.header) != tt.keep {
+				t.Errorf("Header %q: got ID %q, keep=%v", tt.header, id, tt.keep)
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// maxSyncBackoff caps the wait between syncs while the remote is unreachable
+const maxSyncBackoff = time.Hour
+
+// Sources for queued sync jobs
+const (
+	sourceSyncSchedule = "sync-schedule" // Periodic, or retrying after a failure
+	sourceSyncChanges  = "sync-changes"  // Triggered by sync_every changes
+)
+
+// syncBackoff returns how long to wait before the next sync. Failures double
+// the wait from a minute up to maxSyncBackoff, but never retry sooner than
+// the regular interval.
+func syncBackoff(interval time.Duration, failures int) time.Duration {
+	if failures == 0 {
+		return interval
+	}
+	delay := time.Minute
+	for i := 1; i < failures && delay < maxSyncBackoff; i++ {
+		delay *= 2
+	}
+	return max(min(delay, maxSyncBackoff), interval)
+}
+
+// syncRemoteDatabase merges the local database with every remote and writes
+// the result to all of them. It does nothing when the files already match
+// and fails when any remote cannot be read or written.
+func syncRemoteDatabase(config Config) error {
+	remotes := GetRemotePaths(&config)
+	if len(remotes) == 0 {
+		return nil
+	}
+
+	var errs []error
+	var reachable []string
+	inSync := true
+	localChecksum, localErr := ComputeFileChecksum(config.JSONPath)
+	for _, remote := range remotes {
+		checksum, err := ComputeFileChecksum(fetchRemote(remote))
+		if err != nil {
+			err = fmt.Errorf("remote %s unreachable: %w", displayRemote(remote), err)
+			recordRemoteSync(remote, err)
+			errs = append(errs, err)
+			continue
+		}
+		reachable = append(reachable, remote)
+		if localErr != nil || checksum != localChecksum {
+			inSync = false
+		}
+	}
+	if inSync {
+		for _, remote := range reachable {
+			recordRemoteSync(remote, nil)
+		}
+		return errors.Join(errs...)
+	}
+
+	databaseMu.Lock()
+	defer databaseMu.Unlock()
+	merged, err := syncLocalDatabase(config.JSONPath, reachable)
+	if err != nil {
+		return err
+	}
+	if err := saveRemotes(reachable, merged); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// syncLocalDatabase merges the remotes into the local database under its
+// lock, folding in the journal, and returns the saved database
+func syncLocalDatabase(localPath string, remotes []string) (*MagnetDatabase, error) {
+	localPath, release, err := lockLocalDatabase(localPath)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	merged, err := syncWithRemotes(localPath, remotes)
+	if err != nil {
+		return nil, err
+	}
+	reportInvalidSignatures(merged)
+	folded := replayJournal(localPath, merged)
+	if err := SaveDatabaseLocal(localPath, merged); err != nil {
+		return nil, fmt.Errorf("failed to save local: %w", err)
+	}
+	if err := recordOplog(localPath, merged, folded); err != nil {
+		log.Printf("Warning: Could not append to the operation log: %v", err)
+	}
+	if err := compactJournal(localPath, folded); err != nil {
+		log.Printf("Warning: Could not compact journal: %v", err)
+	}
+	return merged, nil
+}
+
+// syncRemote runs a sync for the daemon, tracking consecutive failures so
+// the next attempt backs off
+func (s *apiServer) syncRemote() error {
+	err := syncRemoteDatabase(s.config)
+
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+	if err != nil {
+		s.syncFailures++
+		delay := syncBackoff(time.Duration(s.config.SyncInterval)*time.Minute, s.syncFailures)
+		s.syncRetryAt = time.Now().Add(delay)
+		log.Printf("⚠ Remote sync failed (%s in a row), next attempt in %s: %v",
+			plural(s.syncFailures, "failure", "failures"), delay, err)
+		return err
+	}
+	if s.syncFailures > 0 {
+		log.Printf("✓ Remote reachable again after %s", plural(s.syncFailures, "failure", "failures"))
+	}
+	s.syncFailures = 0
+	s.syncRetryAt = time.Time{}
+	return nil
+}
+
+// scheduleSync queues the next periodic sync, or the retry after a failure,
+// unless one is already pending (other than the job identified by current)
+func (s *apiServer) scheduleSync(current string) {
+	s.syncMu.Lock()
+	delay := syncBackoff(time.Duration(s.config.SyncInterval)*time.Minute, s.syncFailures)
+	s.syncMu.Unlock()
+	if delay <= 0 || len(GetRemotePaths(&s.config)) == 0 || s.queue.hasScheduled(sourceSyncSchedule, current) {
+		return
+	}
+	due := time.Now().Add(delay)
+	job := workJob{Kind: jobSync, Source: sourceSyncSchedule, CorrelationID: newCorrelationID(), NotBefore: due.Format(time.RFC3339)}
+	if _, err := s.queue.Submit(job); err != nil {
+		log.Printf("Warning: Failed to schedule sync: %v", err)
+	}
+}
+
+// noteMutation counts a database change and queues a sync after every
+// sync_every of them. While the remote is unreachable the sync waits for
+// the backoff instead of hammering it.
+func (s *apiServer) noteMutation() {
+	if s.config.SyncEvery <= 0 || s.queue == nil || len(GetRemotePaths(&s.config)) == 0 {
+		return
+	}
+
+	s.syncMu.Lock()
+	s.mutations++
+	if s.mutations < s.config.SyncEvery {
+		s.syncMu.Unlock()
+		return
+	}
+	s.mutations = 0
+	retryAt := s.syncRetryAt
+	s.syncMu.Unlock()
+
+	if s.queue.hasScheduled(sourceSyncChanges, "") {
+		return
+	}
+	job := workJob{Kind: jobSync, Source: sourceSyncChanges, CorrelationID: newCorrelationID()}
+	if time.Now().Before(retryAt) {
+		job.NotBefore = retryAt.Format(time.RFC3339)
+	}
+	if _, err := s.queue.Submit(job); err != nil {
+		log.Printf("Warning: Failed to queue sync: %v", err)
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test syncBackoff doubles from a minute, caps at an hour, and respects the interval
+func TestSyncBackoff(t *testing.T) {
+	tests := []struct {
+		interval time.Duration
+		failures int
+		want     time.Duration
+	}{
+		{15 * time.Minute, 0, 15 * time.Minute},
+		{0, 0, 0},
+		{0, 1, time.Minute},
+		{0, 3, 4 * time.Minute},
+		{0, 20, time.Hour},
+		{15 * time.Minute, 2, 15 * time.Minute},
+		{15 * time.Minute, 6, 32 * time.Minute},
+		{2 * time.Hour, 3, 2 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := syncBackoff(tt.interval, tt.failures); got != tt.want {
+			t.Errorf("syncBackoff(%s, %d) = %s, want %s", tt.interval, tt.failures, got, tt.want)
+		}
+	}
+}
+
+// Test syncRemoteDatabase converges local and remote and fails when the remote is gone
+func TestSyncRemoteDatabase(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	t.Setenv("HOME", tmpDir)
+
+	config := Config{JSONPath: filepath.Join(tmpDir, "local.json"), RemotePath: filepath.Join(tmpDir, "remote.json")}
+	local := &MagnetDatabase{
+		Added: map[string]MagnetEntry{"hash1": {ID: 1, Hash: "hash1", Title: "Local"}},
+		Retry: map[string]MagnetEntry{},
+	}
+	remote := &MagnetDatabase{
+		Added: map[string]MagnetEntry{"hash2": {ID: 2, Hash: "hash2", Title: "Remote"}},
+		Retry: map[string]MagnetEntry{},
+	}
+	if err := SaveDatabaseLocal(config.JSONPath, local); err != nil {
+		t.Fatalf("Failed to save local: %v", err)
+	}
+	if err := SaveDatabaseLocal(config.RemotePath, remote); err != nil {
+		t.Fatalf("Failed to save remote: %v", err)
+	}
+
+	if err := syncRemoteDatabase(config); err != nil {
+		t.Fatalf("syncRemoteDatabase failed: %v", err)
+	}
+	for _, path := range []string{config.JSONPath, config.RemotePath} {
+		db, err := LoadJSONDatabase(path)
+		if err != nil {
+			t.Fatalf("Failed to load %s: %v", path, err)
+		}
+		if len(db.Added) != 2 {
+			t.Errorf("Expected both entries in %s, got %d", filepath.Base(path), len(db.Added))
+		}
+	}
+
+	// Already in sync: nothing is rewritten
+	before, _ := os.Stat(config.RemotePath)
+	if err := syncRemoteDatabase(config); err != nil {
+		t.Fatalf("Second sync failed: %v", err)
+	}
+	if after, _ := os.Stat(config.RemotePath); !after.ModTime().Equal(before.ModTime()) {
+		t.Error("Expected matching files to be left alone")
+	}
+
+	config.RemotePath = filepath.Join(tmpDir, "offline", "remote.json")
+	if err := syncRemoteDatabase(config); err == nil || !strings.Contains(err.Error(), "unreachable") {
+		t.Errorf("Expected unreachable error, got %v", err)
+	}
+}
+
+// Test failed syncs back off and sync_every changes queue a sync
+func TestDaemonSyncScheduling(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	t.Setenv("HOME", tmpDir)
+
+	api := newAPIServer(Config{
+		JSONPath:   filepath.Join(tmpDir, "local.json"),
+		RemotePath: filepath.Join(tmpDir, "offline", "remote.json"),
+		SyncEvery:  2,
+	})
+	api.queue, err = openWorkQueue(filepath.Join(tmpDir, "queue.json"), nil)
+	if err != nil {
+		t.Fatalf("Failed to open queue: %v", err)
+	}
+
+	// No interval and no failures: nothing to schedule
+	api.scheduleSync("")
+	if api.queue.Len() != 0 {
+		t.Fatalf("Expected no scheduled sync, got %d jobs", api.queue.Len())
+	}
+
+	if err := api.syncRemote(); err == nil {
+		t.Fatal("Expected sync to an offline remote to fail")
+	}
+	if api.syncFailures != 1 || !api.syncRetryAt.After(time.Now()) {
+		t.Errorf("Expected one failure and a retry time, got %d, %v", api.syncFailures, api.syncRetryAt)
+	}
+	api.scheduleSync("")
+	api.scheduleSync("")
+	if !api.queue.hasScheduled(sourceSyncSchedule, "") || api.queue.Len() != 1 {
+		t.Errorf("Expected one retry sync scheduled, got %d jobs", api.queue.Len())
+	}
+
+	api.noteMutation()
+	if api.queue.hasScheduled(sourceSyncChanges, "") {
+		t.Error("Expected no sync after one change")
+	}
+	api.noteMutation()
+	api.noteMutation()
+	api.noteMutation()
+	if api.queue.Len() != 2 {
+		t.Fatalf("Expected one change-triggered sync queued, got %d jobs", api.queue.Len())
+	}
+	job, _, _ := api.queue.next(time.Now().Add(time.Minute - time.Second))
+	if job.Source == sourceSyncChanges {
+		t.Error("Expected the change-triggered sync to wait for the backoff")
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+	"time"
+)
+
+// SectionDead lists retry entries presumed dead; they stay in the retry
+// section of the database but are left out of normal drains
+const SectionDead = "dead"
+
+// magnetTrackerCount returns how many trackers a magnet URI announces to
+func magnetTrackerCount(uri string) int {
+	query, ok := strings.CutPrefix(uri, "magnet:?")
+	if !ok {
+		return 0
+	}
+	params, err := url.ParseQuery(query)
+	if err != nil {
+		return 0
+	}
+	return len(params["tr"])
+}
+
+// firstFailure returns when an entry was first queued for retry
+func firstFailure(entry MagnetEntry) (time.Time, bool) {
+	for _, value := range []string{entry.FirstSeen, entry.AddedDate} {
+		if t, err := time.Parse(time.RFC3339, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// presumedDead reports whether a retry entry has no trackers and has been
+// failing for longer than the configured dead_after_days
+func presumedDead(entry MagnetEntry, config Config, now time.Time) bool {
+	if config.DeadAfterDays <= 0 || entry.RetryCount == 0 || magnetTrackerCount(entry.URI) > 0 {
+		return false
+	}
+	since, ok := firstFailure(entry)
+	if !ok {
+		return false
+	}
+	return now.Sub(since) > time.Duration(config.DeadAfterDays)*24*time.Hour
+}
+
+// deadFilter returns the EntryQuery.Dead check for config, or nil when the
+// heuristic is disabled
+func deadFilter(config Config) func(MagnetEntry) bool {
+	if config.DeadAfterDays <= 0 {
+		return nil
+	}
+	now := time.Now()
+	return func(entry MagnetEntry) bool {
+		return presumedDead(entry, config, now)
+	}
+}
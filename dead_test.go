@@ -0,0 +1,78 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// Test magnetTrackerCount counts tr parameters
+func TestMagnetTrackerCount(t *testing.T) {
+	tests := []struct {
+		uri  string
+		want int
+	}{
+		{"magnet:?xt=urn:btih:abc&dn=Book", 0},
+		{"magnet:?xt=urn:btih:abc&tr=udp%3A%2F%2Ftracker.example%3A80", 1},
+		{"magnet:?xt=urn:btih:abc&tr=udp://a:80&tr=udp://b:80", 2},
+		{"not a magnet", 0},
+	}
+
+	for _, tt := range tests {
+		if got := magnetTrackerCount(tt.uri); got != tt.want {
+			t.Errorf("magnetTrackerCount(%q) = %d, want %d", tt.uri, got, tt.want)
+		}
+	}
+}
+
+// Test presumedDead requires no trackers, failures, and age past the threshold
+func TestPresumedDead(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	old := now.AddDate(0, 0, -45).Format(time.RFC3339)
+	recent := now.AddDate(0, 0, -5).Format(time.RFC3339)
+	config := Config{DeadAfterDays: 30}
+
+	tests := []struct {
+		name   string
+		entry  MagnetEntry
+		config Config
+		want   bool
+	}{
+		{"old trackerless failure", MagnetEntry{URI: "magnet:?xt=urn:btih:abc", AddedDate: old, RetryCount: 4}, config, true},
+		{"first seen wins over added date", MagnetEntry{URI: "magnet:?xt=urn:btih:abc", FirstSeen: recent, AddedDate: old, RetryCount: 4}, config, false},
+		{"recent failure", MagnetEntry{URI: "magnet:?xt=urn:btih:abc", AddedDate: recent, RetryCount: 4}, config, false},
+		{"has trackers", MagnetEntry{URI: "magnet:?xt=urn:btih:abc&tr=udp://a:80", AddedDate: old, RetryCount: 4}, config, false},
+		{"never attempted", MagnetEntry{URI: "magnet:?xt=urn:btih:abc", AddedDate: old}, config, false},
+		{"disabled", MagnetEntry{URI: "magnet:?xt=urn:btih:abc", AddedDate: old, RetryCount: 4}, Config{}, false},
+	}
+
+	for _, tt := range tests {
+		if got := presumedDead(tt.entry, tt.config, now); got != tt.want {
+			t.Errorf("%s: presumedDead = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// Test QueryEntries lists presumed-dead retry entries separately
+func TestQueryEntriesDead(t *testing.T) {
+	db := buildListDatabase(3)
+	dead := func(entry MagnetEntry) bool { return entry.Title == "Retry Book 1" }
+
+	retry := QueryEntries(db, EntryQuery{Section: SectionRetry, Dead: dead})
+	if retry.Total != 2 {
+		t.Errorf("Expected 2 active retry entries, got %d", retry.Total)
+	}
+
+	page := QueryEntries(db, EntryQuery{Section: SectionDead, Dead: dead})
+	if page.Total != 1 || page.Entries[0].Title != "Retry Book 1" || page.Entries[0].Section != SectionDead {
+		t.Errorf("Expected only Retry Book 1 as dead, got %+v", page.Entries)
+	}
+
+	all := QueryEntries(db, EntryQuery{Dead: dead})
+	if all.Total != 6 {
+		t.Errorf("Unfiltered listing should still include dead entries, got %d", all.Total)
+	}
+
+	if none := QueryEntries(db, EntryQuery{Section: SectionDead}); none.Total != 0 {
+		t.Errorf("Without a Dead check nothing is dead, got %d", none.Total)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"strings"
+)
+
+// Classes of add failures, recorded as error_kind on entries
+const (
+	ErrorInvalidTorrent   = "invalid_torrent"   // The magnet or torrent can never be added
+	ErrorDuplicate        = "duplicate"         // Already in the client
+	ErrorPermissionDenied = "permission_denied" // The client cannot write the download location
+	ErrorNotConnected     = "not_connected"     // The Web UI has no daemon to pass the add to
+)
+
+// StatusFailedPermanent marks an entry whose last failure retrying cannot fix
+const StatusFailedPermanent = "failed_permanent"
+
+// errorPatterns maps fragments of client error messages to their class.
+// Deluge reports most add failures as a generic AddTorrentError, so the
+// message is all there is to go on.
+var errorPatterns = []struct {
+	kind      string
+	fragments []string
+}{
+	{ErrorDuplicate, []string{"already in session", "already being added"}},
+	{ErrorNotConnected, []string{"not connected", "no daemon", "daemon is not running"}},
+	{ErrorPermissionDenied, []string{"permission denied", "access is denied", "errno 13", "read-only file system"}},
+	{ErrorInvalidTorrent, []string{"invalid magnet", "invalid torrent", "not a valid", "unable to decode", "decoding filedump failed", "bad magnet"}},
+}
+
+// classifyError returns the class of an add failure, or "" when the error is
+// unrecognised or Deluge could not be reached at all
+func classifyError(err error) string {
+	if err == nil || isNetworkError(err) {
+		return ""
+	}
+	message := strings.ToLower(err.Error())
+	for _, pattern := range errorPatterns {
+		for _, fragment := range pattern.fragments {
+			if strings.Contains(message, fragment) {
+				return pattern.kind
+			}
+		}
+	}
+	return ""
+}
+
+// permanentError reports whether a failure class will fail the same way on
+// every retry
+func permanentError(kind string) bool {
+	return kind == ErrorInvalidTorrent || kind == ErrorPermissionDenied
+}
+
+// unreachable reports whether an add never got as far as the client's
+// torrent session, so the attempt should not count against the entry
+func unreachable(err error) bool {
+	return isNetworkError(err) || classifyError(err) == ErrorNotConnected
+}
+
+// failedPermanently reports whether an entry's last failure was permanent
+func failedPermanently(entry MagnetEntry) bool {
+	return entry.Status == StatusFailedPermanent
+}
+
+// markFailure records the class of a failed add on the entry
+func markFailure(entry *MagnetEntry, err error) {
+	entry.ErrorKind = classifyError(err)
+	entry.Status = RetryFailed
+	if permanentError(entry.ErrorKind) {
+		entry.Status = StatusFailedPermanent
+	}
+}
+
+// clearFailure resets the failure fields once an add goes through
+func clearFailure(entry *MagnetEntry) {
+	entry.ErrorKind = ""
+	entry.Status = RetrySucceeded
+}
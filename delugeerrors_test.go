@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Test Deluge error messages are sorted into classes
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{&DelugeError{Message: "Torrent already in session (c12fe1c0)."}, ErrorDuplicate},
+		{&DelugeError{Message: "Invalid magnet info"}, ErrorInvalidTorrent},
+		{&DelugeError{Message: "Unable to decode torrent file"}, ErrorInvalidTorrent},
+		{&DelugeError{Message: "[Errno 13] Permission denied: '/downloads/books'"}, ErrorPermissionDenied},
+		{&DelugeError{Message: "Not connected to a daemon"}, ErrorNotConnected},
+		{&DelugeError{Message: "Something odd happened"}, ""},
+		{&NonJSONError{Status: 502, Snippet: "Bad gateway: not connected"}, ""},
+		{errors.New("torrent already in session"), ErrorDuplicate},
+		{nil, ""},
+	}
+
+	for _, tt := range tests {
+		if got := classifyError(tt.err); got != tt.want {
+			t.Errorf("classifyError(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+	if !permanentError(ErrorInvalidTorrent) || permanentError(ErrorNotConnected) || permanentError("") {
+		t.Error("Only invalid torrents and permission errors are permanent")
+	}
+}
+
+// Test a permanent failure moves the entry to the failed section and an
+// unconnected daemon does not count as an attempt
+func TestRetryEntryPermanentFailure(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	t.Setenv("HOME", tmpDir)
+
+	message := "Not connected to a daemon"
+	client := fakeDeluge(t, func(method string, params []interface{}) (interface{}, interface{}) {
+		return nil, map[string]interface{}{"message": message, "code": 1}
+	})
+
+	config := DefaultConfig()
+	config.JSONPath = filepath.Join(tmpDir, "db.json")
+	config.RemotePath = ""
+	hash := "c12fe1c06bba254a9dc9f519b335aa7c1367a88a"
+	entry := MagnetEntry{Hash: hash, Title: "Book", URI: "magnet:?xt=urn:btih:" + hash, RetryCount: 2}
+
+	if outcome, _ := retryEntry(client, config, hash, entry); outcome != RetryFailed {
+		t.Fatalf("Expected a failed retry, got %s", outcome)
+	}
+	db, err := LoadJSONDatabase(config.JSONPath)
+	if err != nil {
+		t.Fatalf("LoadJSONDatabase failed: %v", err)
+	}
+	entry = db.Retry[hash]
+	if entry.RetryCount != 2 || entry.ErrorKind != ErrorNotConnected || failedPermanently(entry) {
+		t.Errorf("Unconnected daemon should not count or be permanent, got %+v", entry)
+	}
+
+	message = "Invalid magnet info"
+	retryEntry(client, config, hash, entry)
+	db, err = LoadJSONDatabase(config.JSONPath)
+	if err != nil {
+		t.Fatalf("LoadJSONDatabase failed: %v", err)
+	}
+	entry = db.Retry[hash]
+	if entry.Status != StatusFailedPermanent || entry.ErrorKind != ErrorInvalidTorrent || entry.NextAttempt != "" {
+		t.Errorf("Invalid magnet should fail permanently, got %+v", entry)
+	}
+	page := QueryEntries(db, EntryQuery{Section: SectionFailed, Failed: failedFilter(config)})
+	if page.Total != 1 {
+		t.Errorf("Permanent failure should be listed as failed, got %d", page.Total)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// deviceID identifies this installation in entry stamps, applied from config
+// at startup. Entries are left unstamped while it is empty.
+var deviceID string
+
+// newDeviceID returns a readable, unique ID for this installation
+func newDeviceID() string {
+	name := strings.ToLower(localHostname())
+	if name == "" {
+		name = "device"
+	}
+	return fmt.Sprintf("%s-%s", name, GenerateUUID()[:8])
+}
+
+// ensureDeviceID assigns this installation a device ID the first time it
+// runs and saves it, so every later change is stamped with the same ID
+func ensureDeviceID(config *Config) {
+	if config.DeviceID == "" {
+		config.DeviceID = newDeviceID()
+		if err := SaveConfig(*config); err != nil {
+			log.Printf("Warning: Failed to save device ID: %v", err)
+		} else {
+			log.Printf("✓ Registered this installation as device %s", config.DeviceID)
+		}
+	}
+	deviceID = config.DeviceID
+}
+
+// stampEntry records a change to an entry as the next counter value of this
+// device, advancing the database's clock. Clocks are per device, so two
+// machines changing entries offline never hand out the same stamp.
+func stampEntry(db *MagnetDatabase, entry *MagnetEntry) {
+	if deviceID == "" {
+		return
+	}
+	if db.Metadata.Clock == nil {
+		db.Metadata.Clock = make(map[string]int64)
+	}
+	db.Metadata.Clock[deviceID]++
+	entry.Device = deviceID
+	entry.Counter = db.Metadata.Clock[deviceID]
+	entry.Signature = signEntry(*entry)
+}
+
+// mergeClocks returns the highest counter seen from each device in either clock
+func mergeClocks(a, b map[string]int64) map[string]int64 {
+	if len(a) == 0 && len(b) == 0 {
+		return nil
+	}
+	merged := make(map[string]int64, len(a)+len(b))
+	for _, clock := range []map[string]int64{a, b} {
+		for device, counter := range clock {
+			merged[device] = max(merged[device], counter)
+		}
+	}
+	return merged
+}
+
+// seen reports whether a clock already includes an entry's stamp
+func seen(clock map[string]int64, entry MagnetEntry) bool {
+	return entry.Device != "" && clock[entry.Device] >= entry.Counter
+}
+
+// supersedes reports whether c was written with knowledge of w: a later
+// change on the same device, or a change made after the database holding c
+// had merged w. Copies that do not know about each other are concurrent and
+// are left to timestamps and the tie-break policy.
+func (c mergeCandidate) supersedes(w mergeCandidate) bool {
+	if c.entry.Device == "" || w.entry.Device == "" {
+		return false
+	}
+	if c.entry.Device == w.entry.Device {
+		return c.entry.Counter > w.entry.Counter
+	}
+	return seen(c.clock, w.entry) && !seen(w.clock, c.entry)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"testing"
+)
+
+// Test stampEntry advances this device's counter and leaves entries alone
+// without a device ID
+func TestStampEntry(t *testing.T) {
+	defer func() { deviceID = "" }()
+
+	db := &MagnetDatabase{}
+	var entry MagnetEntry
+	stampEntry(db, &entry)
+	if entry.Device != "" || db.Metadata.Clock != nil {
+		t.Fatalf("Entry stamped without a device ID: %+v", entry)
+	}
+
+	deviceID = "laptop-1"
+	db.Metadata.Clock = map[string]int64{"desktop-2": 7}
+	stampEntry(db, &entry)
+	stampEntry(db, &entry)
+	if entry.Device != "laptop-1" || entry.Counter != 2 {
+		t.Errorf("Expected stamp (laptop-1, 2), got (%s, %d)", entry.Device, entry.Counter)
+	}
+	if db.Metadata.Clock["laptop-1"] != 2 || db.Metadata.Clock["desktop-2"] != 7 {
+		t.Errorf("Unexpected clock: %v", db.Metadata.Clock)
+	}
+}
+
+// Test mergeClocks keeps the highest counter per device
+func TestMergeClocks(t *testing.T) {
+	merged := mergeClocks(map[string]int64{"a": 3, "b": 1}, map[string]int64{"b": 4, "c": 2})
+	want := map[string]int64{"a": 3, "b": 4, "c": 2}
+	if len(merged) != len(want) {
+		t.Fatalf("mergeClocks = %v, want %v", merged, want)
+	}
+	for device, counter := range want {
+		if merged[device] != counter {
+			t.Errorf("clock[%s] = %d, want %d", device, merged[device], counter)
+		}
+	}
+	if mergeClocks(nil, nil) != nil {
+		t.Error("Merging empty clocks should stay empty")
+	}
+}
+
+// Test merges follow device clocks before timestamps and sections
+func TestMergeDatabasesDeviceClocks(t *testing.T) {
+	newDB := func(clock map[string]int64) *MagnetDatabase {
+		return &MagnetDatabase{
+			Metadata: DatabaseMetadata{Clock: clock},
+			Added:    map[string]MagnetEntry{},
+			Retry:    map[string]MagnetEntry{},
+		}
+	}
+
+	// Laptop re-queued the entry after syncing the desktop's add, with a
+	// clock running behind: the re-queue still wins and is no conflict
+	local := newDB(map[string]int64{"laptop": 1, "desktop": 1})
+	local.Retry["hash1"] = MagnetEntry{Hash: "hash1", Title: "Requeued", LastAttempt: "2024-01-01T00:00:00Z",
+		RetryCount: 1, Device: "laptop", Counter: 1}
+	remote := newDB(map[string]int64{"desktop": 1})
+	remote.Added["hash1"] = MagnetEntry{Hash: "hash1", Title: "Added", AddedDate: "2024-01-05T00:00:00Z",
+		Device: "desktop", Counter: 1}
+
+	merged, conflicts := MergeDatabasesWithConflicts(local, remote)
+	if _, ok := merged.Retry["hash1"]; !ok || len(merged.Added) != 0 {
+		t.Errorf("Expected the later re-queue to win, got added=%v retry=%v", merged.Added, merged.Retry)
+	}
+	if len(conflicts) != 0 {
+		t.Errorf("A re-queue made after seeing the add is not a conflict, got %v", conflicts)
+	}
+	if merged.Metadata.Clock["laptop"] != 1 || merged.Metadata.Clock["desktop"] != 1 {
+		t.Errorf("Unexpected merged clock: %v", merged.Metadata.Clock)
+	}
+
+	// A later change on the same device wins over an older one
+	local = newDB(map[string]int64{"desktop": 3})
+	local.Added["hash1"] = MagnetEntry{Hash: "hash1", Title: "Newer", AddedDate: "2024-01-01T00:00:00Z", Device: "desktop", Counter: 3}
+	remote = newDB(map[string]int64{"desktop": 2})
+	remote.Added["hash1"] = MagnetEntry{Hash: "hash1", Title: "Older", AddedDate: "2024-02-01T00:00:00Z", Device: "desktop", Counter: 2}
+	if got := MergeDatabases(local, remote).Added["hash1"].Title; got != "Newer" {
+		t.Errorf("Expected the higher counter to win, got %q", got)
+	}
+
+	// Concurrent changes fall back to added-over-retry and timestamps, and
+	// a retry after the add is still flagged
+	local = newDB(map[string]int64{"laptop": 4})
+	local.Retry["hash1"] = MagnetEntry{Hash: "hash1", Title: "Retry", LastAttempt: "2024-01-06T00:00:00Z",
+		RetryCount: 2, Device: "laptop", Counter: 4}
+	remote = newDB(map[string]int64{"desktop": 2})
+	remote.Added["hash1"] = MagnetEntry{Hash: "hash1", Title: "Added", AddedDate: "2024-01-05T00:00:00Z", Device: "desktop", Counter: 2}
+	merged, conflicts = MergeDatabasesWithConflicts(local, remote)
+	if merged.Added["hash1"].Title != "Added" || len(conflicts) != 1 {
+		t.Errorf("Expected the added copy to win with a conflict, got %v, %v", merged.Added, conflicts)
+	}
+}
@@ -0,0 +1,464 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Signature states of an entry's last change
+const (
+	SignatureValid    = "valid"    // Signed by a trusted device key
+	SignatureUnsigned = "unsigned" // Written without a key
+	SignatureUnknown  = "unknown"  // Signed by a device with no trusted key
+	SignatureInvalid  = "invalid"  // Signature does not match the device's key
+)
+
+// Headers carrying a device's signature on an API request
+const (
+	deviceHeader          = "X-Magnet-Device"
+	deviceSignatureHeader = "X-Magnet-Signature"
+	deviceDateHeader      = "X-Magnet-Date"
+)
+
+// maxRequestSkew is how far a signed request's date may be from the daemon's
+// clock, limiting replays of a captured request
+const maxRequestSkew = 5 * time.Minute
+
+// DeviceTrust holds this device's signing key and the keys it trusts
+type DeviceTrust struct {
+	Private ed25519.PrivateKey           // nil when this device does not sign
+	Trusted map[string]ed25519.PublicKey // Public keys by device ID
+}
+
+// deviceTrust holds the active keys, applied from config at startup
+var deviceTrust DeviceTrust
+
+// ConfigureDeviceKeys loads the trusted device keys and, with sign_changes,
+// this device's own key, creating it the first time
+func ConfigureDeviceKeys(config Config) error {
+	trusted := make(map[string]ed25519.PublicKey, len(config.DeviceKeys))
+	for id, encoded := range config.DeviceKeys {
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			return fmt.Errorf("device_keys: %s is not a base64 Ed25519 public key", id)
+		}
+		trusted[id] = ed25519.PublicKey(key)
+	}
+
+	var private ed25519.PrivateKey
+	if config.SignChanges {
+		key, err := loadDeviceKey()
+		if err != nil {
+			return err
+		}
+		private = key
+		if deviceID != "" {
+			trusted[deviceID] = key.Public().(ed25519.PublicKey)
+		}
+	}
+	deviceTrust = DeviceTrust{Private: private, Trusted: trusted}
+	return nil
+}
+
+// deviceKeyPath returns where this installation's private key is kept
+func deviceKeyPath() (string, error) {
+	homeDir, err := getHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, ".magnet-handler", "device.key"), nil
+}
+
+// loadDeviceKey reads this device's private key, generating and saving one
+// if there is none yet
+func loadDeviceKey() (ed25519.PrivateKey, error) {
+	path, err := deviceKeyPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err == nil {
+		seed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil || len(seed) != ed25519.SeedSize {
+			return nil, fmt.Errorf("device key %s is corrupt", path)
+		}
+		return ed25519.NewKeyFromSeed(seed), nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read device key: %w", err)
+	}
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	encoded := base64.StdEncoding.EncodeToString(key.Seed()) + "\n"
+	if err := os.WriteFile(path, []byte(encoded), 0600); err != nil {
+		return nil, fmt.Errorf("failed to save device key: %w", err)
+	}
+	log.Printf("✓ Generated a signing key for this device in %s", path)
+	return key, nil
+}
+
+// entrySignaturePayload returns the bytes a device signs for an entry: the
+// entry as it stamped it, without what merges change afterwards (sequence
+// ID, combined history, conflict marks) or the signature itself
+func entrySignaturePayload(entry MagnetEntry) []byte {
+	entry.ID = 0
+	entry.History = nil
+	entry.Conflict, entry.ConflictInfo = false, ""
+	entry.Signature = ""
+	data, _ := json.Marshal(entry)
+	return data
+}
+
+// signEntry returns this device's signature over a stamped entry, or "" if
+// it does not sign
+func signEntry(entry MagnetEntry) string {
+	if deviceTrust.Private == nil {
+		return ""
+	}
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(deviceTrust.Private, entrySignaturePayload(entry)))
+}
+
+// entrySignatureStatus checks an entry's signature against the key of the
+// device that last changed it
+func entrySignatureStatus(entry MagnetEntry) string {
+	if entry.Signature == "" {
+		return SignatureUnsigned
+	}
+	key, ok := deviceTrust.Trusted[entry.Device]
+	if !ok {
+		return SignatureUnknown
+	}
+	signature, err := base64.StdEncoding.DecodeString(entry.Signature)
+	if err != nil || !ed25519.Verify(key, entrySignaturePayload(entry), signature) {
+		return SignatureInvalid
+	}
+	return SignatureValid
+}
+
+var (
+	reportedMu         sync.Mutex
+	reportedSignatures = make(map[string]bool)
+)
+
+// reportInvalidSignatures warns once about each entry whose last change
+// carries a signature its device's trusted key does not match
+func reportInvalidSignatures(db *MagnetDatabase) {
+	if len(deviceTrust.Trusted) == 0 {
+		return
+	}
+	reportedMu.Lock()
+	defer reportedMu.Unlock()
+
+	byDevice := make(map[string]int)
+	for _, entries := range []map[string]MagnetEntry{db.Added, db.Retry, db.Removed} {
+		for hash, entry := range entries {
+			key := hash + "/" + entry.Signature
+			if entry.Signature == "" || reportedSignatures[key] {
+				continue
+			}
+			if entrySignatureStatus(entry) == SignatureInvalid {
+				reportedSignatures[key] = true
+				byDevice[entry.Device]++
+			}
+		}
+	}
+	for device, count := range byDevice {
+		log.Printf("⚠ %d %s changed by device %s failed signature checks", count, plural(count, "entry", "entries"), device)
+		Notify(NotifyCritical, "Untrusted database changes",
+			fmt.Sprintf("%d %s last changed by device %s have a bad signature. Check that machine, then run: magnet-handler devices rollback %s --invalid",
+				count, plural(count, "entry", "entries"), device, device))
+	}
+}
+
+// requestSignaturePayload returns the bytes a device signs for an API
+// request: method, path with query, date, and the SHA-256 of the body
+func requestSignaturePayload(method, path, date string, body []byte) []byte {
+	sum := sha256.Sum256(body)
+	return []byte(method + "\n" + path + "\n" + date + "\n" + hex.EncodeToString(sum[:]))
+}
+
+// verifyRequest checks a device-signed API request and returns the device.
+// The body it hashes is capped at maxWebhookBody like webhook payloads.
+func verifyRequest(w http.ResponseWriter, r *http.Request) (string, error) {
+	device := r.Header.Get(deviceHeader)
+	key, ok := deviceTrust.Trusted[device]
+	if !ok {
+		return "", fmt.Errorf("device %s has no trusted key", device)
+	}
+	date := r.Header.Get(deviceDateHeader)
+	at, err := time.Parse(time.RFC3339, date)
+	if err != nil {
+		return "", fmt.Errorf("missing or invalid %s", deviceDateHeader)
+	}
+	if skew := time.Since(at); skew > maxRequestSkew || skew < -maxRequestSkew {
+		return "", fmt.Errorf("request date is %s off the daemon's clock", skew.Round(time.Second))
+	}
+	signature, err := base64.StdEncoding.DecodeString(r.Header.Get(deviceSignatureHeader))
+	if err != nil {
+		return "", fmt.Errorf("invalid %s", deviceSignatureHeader)
+	}
+
+	var body []byte
+	if r.Body != nil {
+		if body, err = io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBody)); err != nil {
+			return "", err
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	if !ed25519.Verify(key, requestSignaturePayload(r.Method, r.URL.RequestURI(), date, body), signature) {
+		return "", fmt.Errorf("signature does not match device %s", device)
+	}
+	return device, nil
+}
+
+// withDeviceSignature checks requests that name a device: a request claiming
+// to come from a device must carry that device's valid signature, and is
+// logged against it. Requests without the header pass through unchanged.
+func withDeviceSignature(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(deviceHeader) == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		device, err := verifyRequest(w, r)
+		if err != nil {
+			log.Printf("✗ Rejected %s %s: %v", r.Method, r.URL.Path, err)
+			status := http.StatusUnauthorized
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				status = http.StatusRequestEntityTooLarge
+			}
+			writeError(w, status, err)
+			return
+		}
+		log.Printf("API %s %s signed by device %s", r.Method, r.URL.Path, device)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// DeviceSummary counts the entries a device last changed by signature state
+type DeviceSummary struct {
+	Device     string         `json:"device"`
+	Trusted    bool           `json:"trusted"`
+	Entries    int            `json:"entries"`
+	Signatures map[string]int `json:"signatures"`
+}
+
+// summarizeDevices groups the database's entries by the device that last
+// changed them
+func summarizeDevices(db *MagnetDatabase) []DeviceSummary {
+	byDevice := make(map[string]*DeviceSummary)
+	for _, entries := range []map[string]MagnetEntry{db.Added, db.Retry, db.Removed} {
+		for _, entry := range entries {
+			summary, ok := byDevice[entry.Device]
+			if !ok {
+				_, trusted := deviceTrust.Trusted[entry.Device]
+				summary = &DeviceSummary{Device: entry.Device, Trusted: trusted, Signatures: make(map[string]int)}
+				byDevice[entry.Device] = summary
+			}
+			summary.Entries++
+			summary.Signatures[entrySignatureStatus(entry)]++
+		}
+	}
+
+	summaries := make([]DeviceSummary, 0, len(byDevice))
+	for _, summary := range byDevice {
+		summaries = append(summaries, *summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Device < summaries[j].Device })
+	return summaries
+}
+
+// planRollback returns the updates that undo a device's changes: each entry
+// it last changed goes back to the newest version in the operation log
+// written by another device, or to removed if there is none. With
+// onlyInvalid, entries carrying a valid signature are left alone.
+func planRollback(db *MagnetDatabase, ops []journalOp, device string, onlyInvalid bool) *MagnetDatabase {
+	previous := make(map[string]journalOp)
+	for _, op := range ops {
+		if op.Entry.Device != device {
+			previous[op.Hash] = op
+		}
+	}
+
+	now := time.Now().Format(time.RFC3339)
+	update := &MagnetDatabase{
+		Added:   make(map[string]MagnetEntry),
+		Retry:   make(map[string]MagnetEntry),
+		Removed: make(map[string]MagnetEntry),
+	}
+	for _, section := range []struct {
+		name    string
+		entries map[string]MagnetEntry
+	}{
+		{SectionAdded, db.Added},
+		{SectionRetry, db.Retry},
+		{SectionRemoved, db.Removed},
+	} {
+		for hash, entry := range section.entries {
+			if entry.Device != device || (onlyInvalid && entrySignatureStatus(entry) == SignatureValid) {
+				continue
+			}
+			detail := "changes by device " + device
+			if op, ok := previous[hash]; ok {
+				restored := op.Entry
+				restored.History = entry.History
+				restored.recordHistory(HistoryRolledBack, "", detail)
+				placeEntry(update, op.Section, hash, restored)
+				continue
+			}
+			if section.name == SectionRemoved {
+				continue // Nothing older to restore, and already gone
+			}
+			entry.RemovedAt = now
+			entry.recordHistory(HistoryRemoved, "", "rolled back "+detail)
+			update.Removed[hash] = entry
+		}
+	}
+	return update
+}
+
+// runDevices implements the devices command
+func runDevices(config Config, args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "key":
+			return runDevicesKey(config, args[1:])
+		case "rollback":
+			return runDevicesRollback(config, args[1:])
+		}
+	}
+
+	fs := newCommandFlags(devicesCommand)
+	asJSON := fs.Bool("json", false, "Print each device's counts as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	db, err := LoadJSONDatabase(config.JSONPath)
+	if err != nil {
+		return fmt.Errorf("failed to load database: %w", err)
+	}
+	summaries := summarizeDevices(db)
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(summaries)
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "DEVICE\tTRUSTED\tENTRIES\tVALID\tUNSIGNED\tUNKNOWN\tINVALID")
+	for _, s := range summaries {
+		name := s.Device
+		if name == "" {
+			name = "(none)"
+		} else if name == deviceID {
+			name += " (this device)"
+		}
+		fmt.Fprintf(tw, "%s\t%v\t%d\t%d\t%d\t%d\t%d\n", name, s.Trusted, s.Entries,
+			s.Signatures[SignatureValid], s.Signatures[SignatureUnsigned], s.Signatures[SignatureUnknown], s.Signatures[SignatureInvalid])
+	}
+	return tw.Flush()
+}
+
+// runDevicesKey prints this device's public key for other machines to trust
+func runDevicesKey(config Config, args []string) error {
+	key, err := loadDeviceKey()
+	if err != nil {
+		return err
+	}
+	public, _ := json.Marshal(map[string]string{deviceID: base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey))})
+	fmt.Printf("Add to device_keys in the config on every machine:\n  %s\n", strings.Trim(string(public), "{}"))
+	if !config.SignChanges {
+		fmt.Println(`Set "sign_changes": true on this machine to start signing.`)
+	}
+	return nil
+}
+
+// runDevicesRollback undoes the changes one device made
+func runDevicesRollback(config Config, args []string) error {
+	fs := newCommandFlags(devicesCommand)
+	onlyInvalid := fs.Bool("invalid", false, "Only roll back entries whose signature does not check out")
+	dryRun := fs.Bool("dry-run", false, "List what would be rolled back without changing anything")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected a device ID")
+	}
+	device := fs.Arg(0)
+	if device == deviceID {
+		return fmt.Errorf("%s is this device", device)
+	}
+
+	db, err := LoadJSONDatabase(config.JSONPath)
+	if err != nil {
+		return fmt.Errorf("failed to load database: %w", err)
+	}
+	ops, err := readOplog(config.JSONPath)
+	if err != nil {
+		return fmt.Errorf("failed to read operation log: %w", err)
+	}
+	update := planRollback(db, ops, device, *onlyInvalid)
+
+	count := len(update.Added) + len(update.Retry) + len(update.Removed)
+	for _, section := range []struct {
+		name    string
+		entries map[string]MagnetEntry
+	}{
+		{SectionAdded, update.Added},
+		{SectionRetry, update.Retry},
+		{SectionRemoved, update.Removed},
+	} {
+		for _, entry := range section.entries {
+			fmt.Printf("%s -> %s: %s\n", entry.Hash, section.name, entry.Title)
+		}
+	}
+	if count == 0 {
+		log.Printf("Nothing to roll back for device %s", device)
+		return nil
+	}
+	if *dryRun {
+		log.Printf("Dry run: would roll back %d %s", count, plural(count, "entry", "entries"))
+		return nil
+	}
+	if err := SaveJSONDatabase(config.JSONPath, update, &config); err != nil {
+		return fmt.Errorf("failed to save database: %w", err)
+	}
+	log.Printf("✓ Rolled back %d %s changed by device %s", count, plural(count, "entry", "entries"), device)
+	return nil
+}
+
+var devicesCommand = &Command{
+	Name:    "devices",
+	Usage:   "devices [--json] | devices key | devices rollback [--invalid] [--dry-run] <device>",
+	Summary: "Show which devices last changed entries and whether their signatures check out, or undo one device's changes",
+}
+
+func init() {
+	devicesCommand.Run = runDevices
+	registerCommand(devicesCommand)
+}
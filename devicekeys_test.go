@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// useDeviceKeys configures signing as device id with a fresh key in a
+// temporary home, restoring the globals afterwards
+func useDeviceKeys(t *testing.T, id string, config Config) {
+	t.Helper()
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(tmpDir) })
+	t.Setenv("HOME", tmpDir)
+
+	originalID, originalTrust := deviceID, deviceTrust
+	t.Cleanup(func() { deviceID, deviceTrust = originalID, originalTrust })
+	deviceID = id
+	config.SignChanges = true
+	if err := ConfigureDeviceKeys(config); err != nil {
+		t.Fatalf("ConfigureDeviceKeys failed: %v", err)
+	}
+}
+
+// signRequest signs an API request as this device
+func signRequest(r *http.Request, body []byte) {
+	date := time.Now().UTC().Format(time.RFC3339)
+	signature := ed25519.Sign(deviceTrust.Private, requestSignaturePayload(r.Method, r.URL.RequestURI(), date, body))
+	r.Header.Set(deviceHeader, deviceID)
+	r.Header.Set(deviceDateHeader, date)
+	r.Header.Set(deviceSignatureHeader, base64.StdEncoding.EncodeToString(signature))
+}
+
+// Test stamped entries are signed, survive what merges change, and show
+// tampering
+func TestEntrySignatures(t *testing.T) {
+	useDeviceKeys(t, "laptop", Config{})
+
+	path, _ := deviceKeyPath()
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("Expected a private device key file, got %v, %v", info, err)
+	}
+
+	db := &MagnetDatabase{}
+	entry := MagnetEntry{Hash: "hash1", Title: "Book", URI: "magnet:?xt=urn:btih:hash1"}
+	stampEntry(db, &entry)
+	if entry.Signature == "" || entrySignatureStatus(entry) != SignatureValid {
+		t.Fatalf("Expected a valid signature, got %q (%s)", entry.Signature, entrySignatureStatus(entry))
+	}
+
+	merged := entry
+	merged.ID = 42
+	merged.Conflict, merged.ConflictInfo = true, "retry on desktop"
+	merged.recordHistory(HistoryAdded, "deluge", "")
+	if entrySignatureStatus(merged) != SignatureValid {
+		t.Error("Merge bookkeeping should not break the signature")
+	}
+
+	tampered := entry
+	tampered.URI = "magnet:?xt=urn:btih:other"
+	if entrySignatureStatus(tampered) != SignatureInvalid {
+		t.Error("A changed URI should fail the signature check")
+	}
+	other := entry
+	other.Device = "desktop"
+	if entrySignatureStatus(other) != SignatureUnknown {
+		t.Error("A device without a trusted key should be unknown")
+	}
+	if entrySignatureStatus(MagnetEntry{Device: "laptop"}) != SignatureUnsigned {
+		t.Error("An entry without a signature should be unsigned")
+	}
+
+	if err := ConfigureDeviceKeys(Config{DeviceKeys: map[string]string{"desktop": "not-a-key"}}); err == nil {
+		t.Error("Expected an error for a malformed device key")
+	}
+}
+
+// Test rolling back a device restores the last version another device wrote
+// and removes entries only it ever wrote
+func TestPlanRollback(t *testing.T) {
+	useDeviceKeys(t, "laptop", Config{})
+
+	mine := MagnetEntry{Hash: "hash1", Title: "Book", Device: "laptop", Counter: 3}
+	db := &MagnetDatabase{
+		Added: map[string]MagnetEntry{
+			"hash2": {Hash: "hash2", Title: "Planted", Device: "rogue", Signature: "Zm9yZ2Vk"},
+			"hash3": {Hash: "hash3", Title: "Mine", Device: "laptop"},
+		},
+		Retry:   map[string]MagnetEntry{},
+		Removed: map[string]MagnetEntry{"hash1": {Hash: "hash1", Title: "Book", Device: "rogue", RemovedAt: "2024-06-01T00:00:00Z"}},
+	}
+	ops := []journalOp{
+		{Section: SectionRetry, Hash: "hash1", Entry: MagnetEntry{Hash: "hash1", Device: "laptop", Counter: 1}},
+		{Section: SectionAdded, Hash: "hash1", Entry: mine},
+		{Section: SectionRemoved, Hash: "hash1", Entry: MagnetEntry{Hash: "hash1", Device: "rogue"}},
+	}
+
+	update := planRollback(db, ops, "rogue", false)
+	restored, ok := update.Added["hash1"]
+	if !ok || restored.Counter != 3 || restored.History[len(restored.History)-1].Event != HistoryRolledBack {
+		t.Errorf("Expected hash1 restored to the laptop's added version, got %+v", update)
+	}
+	if removed, ok := update.Removed["hash2"]; !ok || removed.RemovedAt == "" {
+		t.Errorf("Expected the planted entry removed, got %+v", update.Removed)
+	}
+	if _, ok := update.Added["hash3"]; ok {
+		t.Error("Entries from other devices must be left alone")
+	}
+
+	if update := planRollback(db, ops, "laptop", true); len(update.Added)+len(update.Removed) != 1 {
+		t.Errorf("With --invalid only the unsigned laptop entry should roll back, got %+v", update)
+	}
+}
+
+// Test API requests naming a device must carry its valid signature
+func TestDeviceSignedRequests(t *testing.T) {
+	useDeviceKeys(t, "laptop", Config{})
+	called := false
+	handler := withDeviceSignature(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+
+	serve := func(r *http.Request) int {
+		called = false
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	body := []byte(`{"label":"books"}`)
+	req, _ := http.NewRequest(http.MethodPost, "/api/entries/hash1/label?x=1", bytes.NewReader(body))
+	signRequest(req, body)
+	if code := serve(req); code != http.StatusOK || !called {
+		t.Errorf("Signed request should pass, got %d", code)
+	}
+
+	req, _ = http.NewRequest(http.MethodPost, "/api/entries/hash1/label?x=1", bytes.NewReader([]byte(`{"label":"other"}`)))
+	signRequest(req, body)
+	if code := serve(req); code != http.StatusUnauthorized || called {
+		t.Errorf("Request with a changed body should be rejected, got %d", code)
+	}
+
+	huge := bytes.Repeat([]byte("x"), maxWebhookBody+1)
+	req, _ = http.NewRequest(http.MethodPost, "/api/entries/hash1/label", bytes.NewReader(huge))
+	signRequest(req, huge)
+	if code := serve(req); code != http.StatusRequestEntityTooLarge || called {
+		t.Errorf("Oversized signed body should be rejected with 413, got %d", code)
+	}
+
+	req, _ = http.NewRequest(http.MethodGet, "/api/entries", nil)
+	signRequest(req, nil)
+	req.Header.Set(deviceDateHeader, time.Now().Add(-time.Hour).UTC().Format(time.RFC3339))
+	if code := serve(req); code != http.StatusUnauthorized {
+		t.Errorf("Stale request should be rejected, got %d", code)
+	}
+
+	req, _ = http.NewRequest(http.MethodGet, "/api/entries", nil)
+	req.Header.Set(deviceHeader, "desktop")
+	if code := serve(req); code != http.StatusUnauthorized {
+		t.Errorf("Unknown device should be rejected, got %d", code)
+	}
+
+	req, _ = http.NewRequest(http.MethodGet, "/api/entries", nil)
+	if code := serve(req); code != http.StatusOK || !called {
+		t.Errorf("Unsigned request should pass through, got %d", code)
+	}
+}
+
+// Test devices rollback saves through the journal so the restored version
+// is stamped by this device
+func TestDevicesRollback(t *testing.T) {
+	useDeviceKeys(t, "laptop", Config{})
+	home, _ := os.UserHomeDir()
+
+	config := DefaultConfig()
+	config.JSONPath = filepath.Join(home, "db.json")
+	config.RemotePath = ""
+	db := &MagnetDatabase{
+		Added: map[string]MagnetEntry{"hash2": {Hash: "hash2", Title: "Planted", Device: "rogue"}},
+		Retry: map[string]MagnetEntry{},
+	}
+	if err := SaveDatabaseLocal(config.JSONPath, db); err != nil {
+		t.Fatalf("Failed to save database: %v", err)
+	}
+
+	if err := runDevices(config, []string{"rollback", "rogue"}); err != nil {
+		t.Fatalf("devices rollback failed: %v", err)
+	}
+	db, err := LoadJSONDatabase(config.JSONPath)
+	if err != nil {
+		t.Fatalf("LoadJSONDatabase failed: %v", err)
+	}
+	entry, ok := db.Removed["hash2"]
+	if !ok || entry.Device != "laptop" || entrySignatureStatus(entry) != SignatureValid {
+		t.Errorf("Expected the planted entry removed and signed by this device, got %+v", entry)
+	}
+	if err := runDevices(config, []string{"rollback", "laptop"}); err == nil {
+		t.Error("Rolling back this device should be refused")
+	}
+}
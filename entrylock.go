@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// databaseMu serializes read-merge-write saves within this process. Two
+// saves interleaving could each fold the journal and then overwrite the
+// other's result; across processes the journal covers that window.
+var databaseMu sync.Mutex
+
+// entryLocks serializes changes to individual entries, so the API and the
+// daemon's retry loop never interleave updates to one hash (and its status
+// and history) while different entries still proceed in parallel
+type entryLocks struct {
+	mu    sync.Mutex
+	locks map[string]*entryLock
+}
+
+// entryLock is one hash's mutex and how many callers hold or wait for it
+type entryLock struct {
+	mu    sync.Mutex
+	users int
+}
+
+// heldEntries is the process-wide set of entry locks
+var heldEntries = &entryLocks{locks: make(map[string]*entryLock)}
+
+// lock blocks until the caller holds hash's lock and returns the function
+// that releases it. Locks nobody is using are dropped.
+func (l *entryLocks) lock(hash string) func() {
+	l.mu.Lock()
+	lock, ok := l.locks[hash]
+	if !ok {
+		lock = &entryLock{}
+		l.locks[hash] = lock
+	}
+	lock.users++
+	l.mu.Unlock()
+
+	lock.mu.Lock()
+	return func() {
+		lock.mu.Unlock()
+		l.mu.Lock()
+		if lock.users--; lock.users == 0 {
+			delete(l.locks, hash)
+		}
+		l.mu.Unlock()
+	}
+}
+
+// lockEntry takes the lock for one info hash
+func lockEntry(hash string) func() {
+	return heldEntries.lock(strings.ToLower(hash))
+}
+
+// reloadEntry reads an entry's current state, for use once its lock is held:
+// the copy read before waiting may have been changed by the previous holder
+func reloadEntry(dbPath, hash string) (string, MagnetEntry, error) {
+	db, err := LoadJSONDatabase(dbPath)
+	if err != nil {
+		return "", MagnetEntry{}, fmt.Errorf("failed to load database: %w", err)
+	}
+	section, entry, ok := entrySection(db, hash)
+	if !ok {
+		return "", MagnetEntry{}, errNotFound
+	}
+	return section, entry, nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Test updates to one hash never overlap while other hashes go ahead
+func TestEntryLocks(t *testing.T) {
+	locks := &entryLocks{locks: make(map[string]*entryLock)}
+
+	var mu sync.Mutex
+	inside, maxInside := 0, 0
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			unlock := locks.lock("hash1")
+			defer unlock()
+			mu.Lock()
+			inside++
+			maxInside = max(maxInside, inside)
+			mu.Unlock()
+			time.Sleep(time.Millisecond)
+			mu.Lock()
+			inside--
+			mu.Unlock()
+		}()
+	}
+
+	// A different hash is not held up by the contention above
+	done := make(chan struct{})
+	go func() {
+		locks.lock("hash2")()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("Locking another hash should not wait")
+	}
+
+	wg.Wait()
+	if maxInside != 1 {
+		t.Errorf("Expected one holder at a time, saw %d", maxInside)
+	}
+	if len(locks.locks) != 0 {
+		t.Errorf("Released locks should be dropped, %d left", len(locks.locks))
+	}
+}
+
+// Test reloadEntry returns the entry as currently saved
+func TestReloadEntry(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	localPath := filepath.Join(tmpDir, "local.json")
+	config := Config{JSONPath: localPath}
+	if err := SaveJSONDatabase(localPath, entryUpdate(SectionRetry, "hash1", MagnetEntry{Hash: "hash1", Label: "books"}), &config); err != nil {
+		t.Fatalf("SaveJSONDatabase failed: %v", err)
+	}
+
+	section, entry, err := reloadEntry(localPath, "hash1")
+	if err != nil || section != SectionRetry || entry.Label != "books" {
+		t.Errorf("Expected the saved retry entry, got %s %+v (%v)", section, entry, err)
+	}
+	if _, _, err := reloadEntry(localPath, "missing"); err != errNotFound {
+		t.Errorf("Expected errNotFound, got %v", err)
+	}
+}
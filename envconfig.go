@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// envPrefix starts the environment variables that override config settings
+const envPrefix = "MAGNET_HANDLER_"
+
+// envSettings are the settings the environment can override, named like the
+// command-line flags. They sit between the config file and the flags, so
+// containers and CI can configure the handler without writing a config file
+// or putting the password on the command line.
+var envSettings = []struct {
+	Name  string                    // Variable name after MAGNET_HANDLER_
+	Field func(*Config) interface{} // Pointer to the setting
+}{
+	{"HOST", func(c *Config) interface{} { return &c.DelugeHost }},
+	{"PORT", func(c *Config) interface{} { return &c.DelugePort }},
+	{"PASSWORD", func(c *Config) interface{} { return &c.DelugePassword }},
+	{"LABEL", func(c *Config) interface{} { return &c.DelugeLabel }},
+	{"REMOTE_PATH", func(c *Config) interface{} { return &c.RemotePath }},
+	{"DOWNLOAD_LOCATION", func(c *Config) interface{} { return &c.DownloadLocation }},
+	{"MOVE_COMPLETED_PATH", func(c *Config) interface{} { return &c.MoveCompletedPath }},
+	{"MAX_DOWNLOAD_SPEED", func(c *Config) interface{} { return &c.MaxDownloadSpeed }},
+	{"PAUSED", func(c *Config) interface{} { return &c.AddPaused }},
+	{"REFRESH_REMOTE", func(c *Config) interface{} { return &c.RefreshBeforeAdd }},
+	{"JSON_PATH", func(c *Config) interface{} { return &c.JSONPath }},
+	{"API_TOKEN", func(c *Config) interface{} { return &c.APIToken }},
+	{"WEBHOOK_SECRET", func(c *Config) interface{} { return &c.WebhookSecret }},
+	{"CALENDAR_SECRET", func(c *Config) interface{} { return &c.CalendarSecret }},
+}
+
+// ApplyEnvOverrides sets config from the MAGNET_HANDLER_* variables that are
+// set and not empty, returning the names of the settings it changed
+func ApplyEnvOverrides(config *Config) ([]string, error) {
+	var applied []string
+	for _, setting := range envSettings {
+		value := os.Getenv(envPrefix + setting.Name)
+		if value == "" {
+			continue
+		}
+		switch field := setting.Field(config).(type) {
+		case *string:
+			*field = value
+		case *bool:
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s%s: %q is not true or false", envPrefix, setting.Name, value)
+			}
+			*field = b
+		case *float64:
+			f, err := strconv.ParseFloat(value, 64)
+			if err != nil || f < 0 {
+				return nil, fmt.Errorf("%s%s: %q is not a number of at least 0", envPrefix, setting.Name, value)
+			}
+			*field = f
+		}
+		applied = append(applied, setting.Name)
+	}
+	return applied, nil
+}
+
+// withoutEnvOverrides puts the settings taken from the environment back to
+// their stored values, except those a flag set too, so --save-settings never
+// writes a password that only came from the environment
+func withoutEnvOverrides(config, stored Config, applied []string, flagged map[string]bool) Config {
+	for _, setting := range envSettings {
+		if !slices.Contains(applied, setting.Name) || flagged[strings.ToLower(strings.ReplaceAll(setting.Name, "_", "-"))] {
+			continue
+		}
+		switch field := setting.Field(&config).(type) {
+		case *string:
+			*field = *setting.Field(&stored).(*string)
+		case *bool:
+			*field = *setting.Field(&stored).(*bool)
+		case *float64:
+			*field = *setting.Field(&stored).(*float64)
+		}
+	}
+	return config
+}
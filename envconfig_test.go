@@ -0,0 +1,61 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+// Test MAGNET_HANDLER_* variables override the config file
+func TestApplyEnvOverrides(t *testing.T) {
+	t.Setenv("MAGNET_HANDLER_HOST", "10.0.0.5")
+	t.Setenv("MAGNET_HANDLER_PASSWORD", "from-env")
+	t.Setenv("MAGNET_HANDLER_PAUSED", "true")
+	t.Setenv("MAGNET_HANDLER_MAX_DOWNLOAD_SPEED", "512")
+	t.Setenv("MAGNET_HANDLER_LABEL", "")
+
+	config := DefaultConfig()
+	config.DelugeLabel = "audiobooks"
+	applied, err := ApplyEnvOverrides(&config)
+	if err != nil {
+		t.Fatalf("ApplyEnvOverrides failed: %v", err)
+	}
+	if want := []string{"HOST", "PASSWORD", "MAX_DOWNLOAD_SPEED", "PAUSED"}; !slices.Equal(applied, want) {
+		t.Errorf("Expected %v applied, got %v", want, applied)
+	}
+	if config.DelugeHost != "10.0.0.5" || config.DelugePassword != "from-env" || !config.AddPaused || config.MaxDownloadSpeed != 512 {
+		t.Errorf("Unexpected config %+v", config)
+	}
+	if config.DelugeLabel != "audiobooks" {
+		t.Errorf("Expected an empty variable ignored, got label %q", config.DelugeLabel)
+	}
+
+	for name, value := range map[string]string{"MAGNET_HANDLER_PAUSED": "sometimes", "MAGNET_HANDLER_MAX_DOWNLOAD_SPEED": "fast"} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(name, value)
+			if _, err := ApplyEnvOverrides(&config); err == nil {
+				t.Errorf("Expected %s=%s rejected", name, value)
+			}
+		})
+	}
+}
+
+// Test --save-settings keeps environment values out of the config file
+// unless a flag set the same setting
+func TestWithoutEnvOverrides(t *testing.T) {
+	stored := DefaultConfig()
+	stored.DelugePassword = "stored"
+	stored.DelugeHost = "192.168.1.2"
+
+	config := stored
+	config.DelugePassword = "from-env"
+	config.DelugeHost = "10.0.0.5"
+	config.AddPaused = true
+
+	saved := withoutEnvOverrides(config, stored, []string{"HOST", "PASSWORD", "PAUSED"}, map[string]bool{"host": true})
+	if saved.DelugePassword != "stored" || saved.AddPaused {
+		t.Errorf("Expected environment-only settings restored, got %+v", saved)
+	}
+	if saved.DelugeHost != "10.0.0.5" {
+		t.Errorf("Expected the flagged host kept, got %q", saved.DelugeHost)
+	}
+}
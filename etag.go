@@ -0,0 +1,63 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// errEntryChanged is returned when an If-Match tag names an older version
+var errEntryChanged = errors.New("entry has changed since it was loaded; reload and try again")
+
+// entryETag identifies one stored version of an entry. Every save stamps
+// the entry with the writer's device clock, so any change, including a move
+// between sections, produces a new tag. The section is left out because
+// listings show dead and failed entries under sections of their own.
+func entryETag(entry MagnetEntry) string {
+	data, _ := json.Marshal(entry)
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// ifMatch reports whether a request's If-Match header allows changing the
+// entry: no header, "*", or a tag matching the current version. Otherwise
+// it answers 409 with the current tag so the client can reload.
+func ifMatch(w http.ResponseWriter, r *http.Request, entry MagnetEntry) bool {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return true
+	}
+	current := entryETag(entry)
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == current {
+			return true
+		}
+	}
+	w.Header().Set("ETag", current)
+	writeError(w, http.StatusConflict, errEntryChanged)
+	return false
+}
+
+// writeEntry responds with an entry as stored, tagged with its ETag
+func (s *apiServer) writeEntry(w http.ResponseWriter, hash, section string, entry MagnetEntry) {
+	if stored, saved, err := reloadEntry(s.config.JSONPath, hash); err == nil {
+		section, entry = stored, saved
+	}
+	tag := entryETag(entry)
+	w.Header().Set("ETag", tag)
+	writeJSON(w, http.StatusOK, ListedEntry{Section: section, ETag: tag, WebUI: entryWebUILink(section, hash), MagnetEntry: entry})
+}
+
+// handleEntry returns one entry with its ETag for a later If-Match
+func (s *apiServer) handleEntry(w http.ResponseWriter, r *http.Request) {
+	hash, section, entry, err := s.lookup(r)
+	if err != nil {
+		lookupError(w, err)
+		return
+	}
+	s.writeEntry(w, hash, section, entry)
+}
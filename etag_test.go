@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// Test a stale If-Match gets 409 while the current tag goes through
+func TestAPIIfMatch(t *testing.T) {
+	server, config := newTestAPIServer(t)
+
+	resp := apiRequest(t, http.MethodGet, server.URL+"/api/entries/uuid-2", nil)
+	var listed ListedEntry
+	json.NewDecoder(resp.Body).Decode(&listed)
+	resp.Body.Close()
+	tag := resp.Header.Get("ETag")
+	if resp.StatusCode != http.StatusOK || tag == "" || listed.ETag != tag {
+		t.Fatalf("Expected the entry with its ETag, got %d %q %+v", resp.StatusCode, tag, listed)
+	}
+
+	// First editor relabels with the tag they loaded
+	relabel := func(label, ifMatch string) *http.Response {
+		req, _ := http.NewRequest(http.MethodPost, server.URL+"/api/entries/uuid-2/label", strings.NewReader(`{"label": "`+label+`"}`))
+		req.Header.Set("Authorization", "Bearer "+testAPIToken)
+		req.Header.Set("If-Match", ifMatch)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Relabel failed: %v", err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+	first := relabel("podcasts", tag)
+	if first.StatusCode != http.StatusOK {
+		t.Fatalf("Matching tag: expected 200, got %d", first.StatusCode)
+	}
+	if first.Header.Get("ETag") == tag {
+		t.Error("A saved change should get a new ETag")
+	}
+
+	// Second editor still holds the old tag
+	second := relabel("music", tag)
+	if second.StatusCode != http.StatusConflict {
+		t.Errorf("Stale tag: expected 409, got %d", second.StatusCode)
+	}
+	if second.Header.Get("ETag") != first.Header.Get("ETag") {
+		t.Error("Conflict should report the current ETag")
+	}
+
+	db, err := LoadJSONDatabase(config.JSONPath)
+	if err != nil {
+		t.Fatalf("Failed to load database: %v", err)
+	}
+	if db.Retry["hash2"].Label != "podcasts" {
+		t.Errorf("Stale edit should not clobber the first, got %q", db.Retry["hash2"].Label)
+	}
+
+	if relabel("music", "*").StatusCode != http.StatusOK {
+		t.Error("If-Match: * should always apply")
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Export formats
+const (
+	ExportCSV   = "csv"
+	ExportJSONL = "jsonl"
+	ExportICS   = "ics" // Completed downloads as calendar events
+)
+
+// exportColumns returns the CSV header: the section followed by every
+// MagnetEntry field under its JSON name
+func exportColumns() []string {
+	columns := []string{"section"}
+	entryType := reflect.TypeOf(MagnetEntry{})
+	for i := 0; i < entryType.NumField(); i++ {
+		name, _, _ := strings.Cut(entryType.Field(i).Tag.Get("json"), ",")
+		columns = append(columns, name)
+	}
+	return columns
+}
+
+// exportRow formats one entry as CSV cells in exportColumns order
+func exportRow(entry ListedEntry) ([]string, error) {
+	row := []string{entry.Section}
+	value := reflect.ValueOf(entry.MagnetEntry)
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Field(i)
+		switch field.Kind() {
+		case reflect.String:
+			row = append(row, field.String())
+		case reflect.Int, reflect.Int64:
+			row = append(row, strconv.FormatInt(field.Int(), 10))
+		case reflect.Float64:
+			row = append(row, strconv.FormatFloat(field.Float(), 'f', -1, 64))
+		case reflect.Bool:
+			row = append(row, strconv.FormatBool(field.Bool()))
+		default:
+			// Nested values such as per-target status are embedded as JSON
+			if field.IsZero() {
+				row = append(row, "")
+				continue
+			}
+			data, err := json.Marshal(field.Interface())
+			if err != nil {
+				return nil, err
+			}
+			row = append(row, string(data))
+		}
+	}
+	return row, nil
+}
+
+// ExportEntries writes every added and retry entry, newest first
+func ExportEntries(w io.Writer, db *MagnetDatabase, format string) error {
+	entries := QueryEntries(db, EntryQuery{}).Entries
+
+	switch format {
+	case ExportJSONL:
+		encoder := json.NewEncoder(w)
+		for _, entry := range entries {
+			if err := encoder.Encode(entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	case ExportCSV:
+		writer := csv.NewWriter(w)
+		if err := writer.Write(exportColumns()); err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			row, err := exportRow(entry)
+			if err != nil {
+				return fmt.Errorf("failed to encode %s: %w", entry.Hash, err)
+			}
+			if err := writer.Write(row); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+	default:
+		return fmt.Errorf("unknown export format %q (use %s or %s)", format, ExportCSV, ExportJSONL)
+	}
+}
+
+// RunExport writes the database to path, or stdout when path is empty
+func RunExport(config Config, format, path string) error {
+	if format != ExportCSV && format != ExportJSONL && format != ExportICS {
+		return fmt.Errorf("unknown export format %q (use %s, %s, or %s)", format, ExportCSV, ExportJSONL, ExportICS)
+	}
+
+	db, err := LoadJSONDatabase(config.JSONPath)
+	if err != nil {
+		return fmt.Errorf("failed to load database: %w", err)
+	}
+
+	write := func(w io.Writer) error {
+		if format == ExportICS {
+			return WriteCalendar(w, db, config)
+		}
+		return ExportEntries(w, db, format)
+	}
+	if path == "" {
+		return write(os.Stdout)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create export file: %w", err)
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if format == ExportICS {
+		fmt.Fprintf(os.Stderr, "✓ Exported %d completions to %s\n", len(completedEntries(db)), path)
+		return nil
+	}
+	fmt.Fprintf(os.Stderr, "✓ Exported %d entries to %s\n", len(db.Added)+len(db.Retry), path)
+	return nil
+}
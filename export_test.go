@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// exportTestDatabase has one added entry with nested target status and one retry entry
+func exportTestDatabase() *MagnetDatabase {
+	return &MagnetDatabase{
+		Added: map[string]MagnetEntry{
+			"hash1": {UUID: "uuid-1", Hash: "hash1", Title: "Book, Part 1", AddedDate: "2024-02-01T00:00:00Z", Progress: 42.5,
+				Targets: map[string]TargetStatus{"seedbox": {Status: RetrySucceeded}}},
+		},
+		Retry: map[string]MagnetEntry{
+			"hash2": {UUID: "uuid-2", Hash: "hash2", Title: "Stuck Book", AddedDate: "2024-01-01T00:00:00Z", RetryCount: 3},
+		},
+		Removed: map[string]MagnetEntry{
+			"hash3": {Hash: "hash3", Title: "Gone Book"},
+		},
+	}
+}
+
+// Test CSV export has a column per field and quotes awkward values
+func TestExportEntriesCSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := ExportEntries(&buf, exportTestDatabase(), ExportCSV); err != nil {
+		t.Fatalf("ExportEntries failed: %v", err)
+	}
+
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("Export is not valid CSV: %v", err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("Expected header and 2 rows, got %d", len(rows))
+	}
+
+	column := map[string]int{}
+	for i, name := range rows[0] {
+		column[name] = i
+	}
+	for _, name := range []string{"section", "uuid", "title", "retry_count", "progress", "targets", "transfer_status"} {
+		if _, ok := column[name]; !ok {
+			t.Errorf("Missing column %q", name)
+		}
+	}
+
+	added := rows[1]
+	if added[column["section"]] != SectionAdded || added[column["title"]] != "Book, Part 1" {
+		t.Errorf("Unexpected first row: %v", added)
+	}
+	if added[column["progress"]] != "42.5" {
+		t.Errorf("Expected progress 42.5, got %q", added[column["progress"]])
+	}
+	if !strings.Contains(added[column["targets"]], `"seedbox"`) {
+		t.Errorf("Targets should be embedded as JSON, got %q", added[column["targets"]])
+	}
+	if rows[2][column["retry_count"]] != "3" {
+		t.Errorf("Expected retry_count 3, got %q", rows[2][column["retry_count"]])
+	}
+}
+
+// Test JSON Lines export writes one entry per line and skips removed entries
+func TestRunExportJSONL(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	config := Config{JSONPath: filepath.Join(tmpDir, "db.json")}
+	if err := SaveDatabaseLocal(config.JSONPath, exportTestDatabase()); err != nil {
+		t.Fatalf("Failed to save database: %v", err)
+	}
+
+	outPath := filepath.Join(tmpDir, "export.jsonl")
+	if err := RunExport(config, ExportJSONL, outPath); err != nil {
+		t.Fatalf("RunExport failed: %v", err)
+	}
+
+	data, err := os.ReadFile(outPath)
+	if err != nil {
+		t.Fatalf("Failed to read export: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 lines, got %d", len(lines))
+	}
+	var entry ListedEntry
+	if err := json.Unmarshal([]byte(lines[1]), &entry); err != nil {
+		t.Fatalf("Line is not JSON: %v", err)
+	}
+	if entry.Section != SectionRetry || entry.UUID != "uuid-2" || entry.RetryCount != 3 {
+		t.Errorf("Unexpected entry: %+v", entry)
+	}
+
+	if err := RunExport(config, "xml", outPath); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
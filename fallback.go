@@ -0,0 +1,242 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Named read_fallbacks sources; any other entry is a file or directory path
+const (
+	FallbackRemote  = "remote"  // Each configured remote, in order
+	FallbackBackups = "backups" // Backups kept beside the database, newest first
+	FallbackOplog   = "oplog"   // Rebuilt from the operation log and its base snapshot
+)
+
+// readFallbacks holds where a corrupt local database is recovered from,
+// applied from config at startup
+var readFallbacks struct {
+	Database string   // Database the fallbacks apply to
+	Sources  []string // read_fallbacks, in order
+	Remotes  []string // Remotes tried for FallbackRemote
+}
+
+// recoveryMu keeps two loads from recovering the database at once
+var recoveryMu sync.Mutex
+
+// recoveryRecord notes one recovery, kept beside the database
+type recoveryRecord struct {
+	At          string `json:"at"`
+	Source      string `json:"source"`                 // Where the copy came from
+	Entries     int    `json:"entries"`                // Entries in the recovered copy
+	CorruptCopy string `json:"corrupt_copy,omitempty"` // Where the unreadable file was moved
+	Cause       string `json:"cause"`                  // Why the database could not be read
+}
+
+// fallbackCopy is one candidate copy of the database
+type fallbackCopy struct {
+	Name  string
+	Load  func() (*MagnetDatabase, error)
+	Built bool // Rebuilt here, so there is no checksum or signature to verify
+}
+
+// recoveryLogPath returns the log of recoveries kept beside a database
+func recoveryLogPath(dbPath string) string {
+	return dbPath + ".recoveries"
+}
+
+// ConfigureReadFallbacks applies read_fallbacks from the config
+func ConfigureReadFallbacks(config Config) error {
+	readFallbacks.Database, readFallbacks.Sources, readFallbacks.Remotes = "", nil, nil
+	for i, source := range config.ReadFallbacks {
+		if source == "" {
+			return fmt.Errorf("read_fallbacks entry %d is empty", i+1)
+		}
+	}
+	if len(config.ReadFallbacks) == 0 || config.JSONPath == "" {
+		return nil
+	}
+	database, err := filepath.Abs(config.JSONPath)
+	if err != nil {
+		return err
+	}
+	readFallbacks.Database = database
+	readFallbacks.Sources = config.ReadFallbacks
+	readFallbacks.Remotes = GetRemotePaths(&config)
+	return nil
+}
+
+// fallbackCopies lists the copies a read_fallbacks source offers, best first
+func fallbackCopies(source, dbPath string, remotes []string) []fallbackCopy {
+	loadFile := func(path string) func() (*MagnetDatabase, error) {
+		return func() (*MagnetDatabase, error) {
+			if !fileExists(path) {
+				return nil, os.ErrNotExist
+			}
+			return LoadJSONDatabase(path)
+		}
+	}
+
+	var copies []fallbackCopy
+	switch source {
+	case FallbackRemote:
+		for _, remote := range remotes {
+			copies = append(copies, fallbackCopy{"remote " + displayRemote(remote), func() (*MagnetDatabase, error) {
+				return loadFile(fetchRemote(remote))()
+			}, false})
+		}
+	case FallbackBackups:
+		for _, path := range newestFirst(filepath.Glob(dbPath + ".*.bak")) {
+			copies = append(copies, fallbackCopy{path, loadFile(path), false})
+		}
+	case FallbackOplog:
+		copies = append(copies, fallbackCopy{"operation log " + oplogPath(dbPath), func() (*MagnetDatabase, error) {
+			return rebuildFromOplog(dbPath)
+		}, true})
+	default:
+		if info, err := os.Stat(source); err == nil && info.IsDir() {
+			for _, path := range newestFirst(filepath.Glob(filepath.Join(source, "*"))) {
+				copies = append(copies, fallbackCopy{path, loadFile(path), false})
+			}
+			break
+		}
+		copies = append(copies, fallbackCopy{source, loadFile(source), false})
+	}
+	return copies
+}
+
+// newestFirst sorts the regular files among paths by modification time,
+// newest first
+func newestFirst(paths []string, _ error) []string {
+	modified := make(map[string]time.Time, len(paths))
+	var files []string
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			modified[path] = info.ModTime()
+			files = append(files, path)
+		}
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		return modified[files[i]].After(modified[files[j]])
+	})
+	return files
+}
+
+// rebuildFromOplog replays the operation log, starting with its base
+// snapshot, into an empty database. Entries older than the log are lost.
+func rebuildFromOplog(dbPath string) (*MagnetDatabase, error) {
+	ops, err := readOplog(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	if len(ops) == 0 {
+		return nil, fmt.Errorf("operation log is empty")
+	}
+	db := &MagnetDatabase{
+		Added:   make(map[string]MagnetEntry),
+		Retry:   make(map[string]MagnetEntry),
+		Removed: make(map[string]MagnetEntry),
+	}
+	for _, op := range ops {
+		placeEntry(db, op.Section, op.Hash, op.Entry)
+		db.Metadata.LastSequence = max(db.Metadata.LastSequence, op.Entry.ID)
+	}
+	return db, nil
+}
+
+// recoverDatabase replaces an unreadable database with the first usable
+// copy from read_fallbacks. The unreadable file is kept beside it and the
+// source recorded. It reports false when path has no fallbacks or none of
+// them held a copy.
+func recoverDatabase(path string, cause error) (*MagnetDatabase, bool) {
+	if len(readFallbacks.Sources) == 0 {
+		return nil, false
+	}
+	if abs, err := filepath.Abs(path); err != nil || abs != readFallbacks.Database {
+		return nil, false
+	}
+
+	recoveryMu.Lock()
+	defer recoveryMu.Unlock()
+	// Another load may have recovered it while this one waited
+	if db, err := readDatabaseFile(path, nil); err == nil {
+		return db, true
+	}
+
+	log.Printf("⚠ Database %s is unreadable (%v); trying read fallbacks", path, cause)
+	for _, source := range readFallbacks.Sources {
+		for _, candidate := range fallbackCopies(source, path, readFallbacks.Remotes) {
+			db, err := candidate.Load()
+			if err == nil && !candidate.Built {
+				err = VerifyDatabase(db)
+			}
+			if err == nil && len(db.Added)+len(db.Retry)+len(db.Removed) == 0 {
+				err = errors.New("holds no entries")
+			}
+			if err != nil {
+				if !errors.Is(err, os.ErrNotExist) {
+					log.Printf("  Skipping %s: %v", candidate.Name, err)
+				}
+				continue
+			}
+			restoreDatabase(path, db, candidate.Name, cause)
+			return db, true
+		}
+	}
+	log.Printf("✗ No read fallback held a usable copy of %s", path)
+	return nil, false
+}
+
+// restoreDatabase writes a recovered copy over the unreadable database,
+// moving the unreadable file aside first, and records where it came from
+func restoreDatabase(path string, db *MagnetDatabase, source string, cause error) {
+	record := recoveryRecord{
+		At:      time.Now().Format(time.RFC3339),
+		Source:  source,
+		Entries: len(db.Added) + len(db.Retry) + len(db.Removed),
+		Cause:   cause.Error(),
+	}
+	corrupt := fmt.Sprintf("%s.corrupt-%s", path, time.Now().Format("20060102-150405"))
+	if err := os.Rename(path, corrupt); err != nil {
+		log.Printf("Warning: Could not move the unreadable database aside: %v", err)
+	} else {
+		record.CorruptCopy = corrupt
+	}
+	if err := SaveDatabaseLocal(path, db); err != nil {
+		log.Printf("Warning: Could not write the recovered database: %v", err)
+	}
+	if err := appendRecovery(path, record); err != nil {
+		log.Printf("Warning: Could not record the recovery: %v", err)
+	}
+
+	log.Printf("✓ Recovered %s from %s (%s)", path, source, plural(record.Entries, "entry", "entries"))
+	detail := fmt.Sprintf("%s could not be read and was restored from %s.", path, source)
+	if record.CorruptCopy != "" {
+		log.Printf("  Unreadable file kept at: %s", record.CorruptCopy)
+		detail += "\nThe unreadable file was kept at " + record.CorruptCopy
+	}
+	Notify(NotifyWarning, "Database recovered from a fallback copy", detail)
+}
+
+// appendRecovery adds a record to the recovery log
+func appendRecovery(dbPath string, record recoveryRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(recoveryLogPath(dbPath), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Test an unreadable database is recovered from the first read fallback
+// holding a usable copy, keeping the bad file and recording the source
+func TestReadFallbacks(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	t.Setenv("HOME", tmpDir)
+	defer ConfigureReadFallbacks(Config{})
+
+	path := filepath.Join(tmpDir, "db.json")
+	if err := os.WriteFile(path, []byte(`{"added": {"hash1": `), 0644); err != nil {
+		t.Fatalf("Failed to write database: %v", err)
+	}
+	if _, err := LoadJSONDatabase(path); err == nil {
+		t.Fatal("Expected an error loading a truncated database without fallbacks")
+	}
+
+	backups := filepath.Join(tmpDir, "backups")
+	if err := os.MkdirAll(backups, 0755); err != nil {
+		t.Fatalf("Failed to create backup dir: %v", err)
+	}
+	if err := SaveDatabaseLocal(filepath.Join(backups, "db-copy.json"), buildListDatabase(3)); err != nil {
+		t.Fatalf("Failed to save backup: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(backups, "notes.txt"), []byte("not a database"), 0644); err != nil {
+		t.Fatalf("Failed to write stray file: %v", err)
+	}
+
+	config := Config{JSONPath: path, ReadFallbacks: []string{FallbackRemote, FallbackBackups, backups}}
+	if err := ConfigureReadFallbacks(config); err != nil {
+		t.Fatalf("ConfigureReadFallbacks failed: %v", err)
+	}
+	db, err := LoadJSONDatabase(path)
+	if err != nil {
+		t.Fatalf("Expected the database recovered, got %v", err)
+	}
+	if len(db.Added)+len(db.Retry)+len(db.Removed) != 6 {
+		t.Errorf("Expected the backup's 6 entries, got %+v", db)
+	}
+
+	if reloaded, err := readDatabaseFile(path, nil); err != nil || len(reloaded.Added) != len(db.Added) {
+		t.Errorf("Expected the recovered copy written in place, got %v", err)
+	}
+	data, err := os.ReadFile(recoveryLogPath(path))
+	if err != nil {
+		t.Fatalf("Expected the recovery recorded: %v", err)
+	}
+	var record recoveryRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		t.Fatalf("Failed to parse recovery record: %v", err)
+	}
+	if record.Source != filepath.Join(backups, "db-copy.json") || record.Entries != 6 {
+		t.Errorf("Expected the backup recorded as the source, got %+v", record)
+	}
+	if bad, err := os.ReadFile(record.CorruptCopy); err != nil || !strings.HasPrefix(string(bad), `{"added"`) {
+		t.Errorf("Expected the unreadable file kept at %q, got %v", record.CorruptCopy, err)
+	}
+
+	// Fallbacks only apply to the configured database
+	other := filepath.Join(tmpDir, "other.json")
+	if err := os.WriteFile(other, []byte("{"), 0644); err != nil {
+		t.Fatalf("Failed to write database: %v", err)
+	}
+	if _, err := LoadJSONDatabase(other); err == nil {
+		t.Error("Expected another database to fail without recovery")
+	}
+
+	if err := ConfigureReadFallbacks(Config{JSONPath: path, ReadFallbacks: []string{""}}); err == nil {
+		t.Error("Expected an empty read_fallbacks entry to be rejected")
+	}
+}
+
+// Test the oplog fallback rebuilds the database from the base snapshot and
+// the logged operations, in order
+func TestRebuildFromOplog(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	path := filepath.Join(tmpDir, "db.json")
+	if _, err := rebuildFromOplog(path); err == nil {
+		t.Error("Expected an error without an operation log")
+	}
+
+	ops := []journalOp{
+		{ID: "1", At: "2024-01-01T00:00:00Z", Section: SectionRetry, Hash: "hash1", Entry: MagnetEntry{Hash: "hash1", ID: 1}},
+		{ID: "2", At: "2024-01-02T00:00:00Z", Section: SectionAdded, Hash: "hash2", Entry: MagnetEntry{Hash: "hash2", ID: 2}},
+		{ID: "3", At: "2024-01-03T00:00:00Z", Section: SectionAdded, Hash: "hash1", Entry: MagnetEntry{Hash: "hash1", ID: 1}},
+	}
+	if err := writeJournalLines(oplogPath(path), ops); err != nil {
+		t.Fatalf("Failed to write oplog: %v", err)
+	}
+	db, err := rebuildFromOplog(path)
+	if err != nil {
+		t.Fatalf("rebuildFromOplog failed: %v", err)
+	}
+	if len(db.Added) != 2 || len(db.Retry) != 0 || db.Metadata.LastSequence != 2 {
+		t.Errorf("Expected both entries added with last_sequence 2, got %+v", db)
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// FaultSet is the failures injected into remote syncs by the hidden --inject
+// flag, so the data-safety guarantees can be checked in simulation mode
+type FaultSet struct {
+	RemoteWriteFail bool          // Every remote write fails before touching the remote
+	SlowRemote      time.Duration // Every remote read and write waits this long first
+	TruncateRemote  bool          // Remote writes stop halfway, leaving a truncated file
+}
+
+// injectedFaults is set from --inject; the zero value injects nothing
+var injectedFaults FaultSet
+
+// hiddenFlags are left out of the usage message
+var hiddenFlags = map[string]bool{"inject": true}
+
+// Active reports whether any fault is injected
+func (f *FaultSet) Active() bool {
+	return *f != FaultSet{}
+}
+
+// String lists the injected faults in --inject syntax
+func (f *FaultSet) String() string {
+	var faults []string
+	if f.RemoteWriteFail {
+		faults = append(faults, "remote-write-fail")
+	}
+	if f.SlowRemote > 0 {
+		faults = append(faults, "slow-remote="+f.SlowRemote.String())
+	}
+	if f.TruncateRemote {
+		faults = append(faults, "truncate-remote")
+	}
+	return strings.Join(faults, ",")
+}
+
+// Set adds the comma-separated faults in value; the flag can be repeated
+func (f *FaultSet) Set(value string) error {
+	for _, fault := range strings.Split(value, ",") {
+		name, arg, hasArg := strings.Cut(strings.TrimSpace(fault), "=")
+		switch {
+		case name == "remote-write-fail" && !hasArg:
+			f.RemoteWriteFail = true
+		case name == "truncate-remote" && !hasArg:
+			f.TruncateRemote = true
+		case name == "slow-remote":
+			delay := time.Second
+			if hasArg {
+				var err error
+				if delay, err = time.ParseDuration(arg); err != nil || delay <= 0 {
+					return fmt.Errorf("slow-remote needs a positive duration, got %q", arg)
+				}
+			}
+			f.SlowRemote = delay
+		default:
+			return fmt.Errorf("unknown fault %q (use remote-write-fail, slow-remote=<duration>, or truncate-remote)", fault)
+		}
+	}
+	return nil
+}
+
+// delayRemote waits out the injected remote latency
+func delayRemote(remotePath string) {
+	if injectedFaults.SlowRemote > 0 {
+		log.Printf("⚠ Injected fault: delaying %s by %v", displayRemote(remotePath), injectedFaults.SlowRemote)
+		time.Sleep(injectedFaults.SlowRemote)
+	}
+}
+
+// injectRemoteWriteFault applies the injected faults to a remote write. A
+// non-nil error means the write failed and must not go ahead; with
+// truncate-remote the remote is left holding the first half of db, as if the
+// machine died mid-write on a share without atomic renames.
+func injectRemoteWriteFault(remotePath string, db *MagnetDatabase) error {
+	delayRemote(remotePath)
+	switch {
+	case injectedFaults.RemoteWriteFail:
+		log.Printf("⚠ Injected fault: failing the write to %s", displayRemote(remotePath))
+		return fmt.Errorf("injected fault: remote write failed")
+	case injectedFaults.TruncateRemote && remoteStoreFor(remotePath) != nil:
+		return fmt.Errorf("injected fault: remote write truncated")
+	case injectedFaults.TruncateRemote:
+		log.Printf("⚠ Injected fault: truncating the write to %s", displayRemote(remotePath))
+		var buf bytes.Buffer
+		if err := json.NewEncoder(&buf).Encode(db); err != nil {
+			return err
+		}
+		if err := os.WriteFile(remotePath, buf.Bytes()[:buf.Len()/2], 0644); err != nil {
+			return err
+		}
+		return fmt.Errorf("injected fault: remote write truncated")
+	}
+	return nil
+}
+
+// printFlagDefaults prints the usage of every flag in fs but the hidden ones
+func printFlagDefaults(fs *flag.FlagSet) {
+	visible := flag.NewFlagSet(fs.Name(), flag.ContinueOnError)
+	visible.SetOutput(fs.Output())
+	fs.VisitAll(func(f *flag.Flag) {
+		if !hiddenFlags[f.Name] {
+			visible.Var(f.Value, f.Name, f.Usage)
+			visible.Lookup(f.Name).DefValue = f.DefValue
+		}
+	})
+	visible.PrintDefaults()
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test --inject parses each fault, repeated or comma-separated
+func TestFaultSetParse(t *testing.T) {
+	var faults FaultSet
+	if faults.Active() {
+		t.Error("The zero value should inject nothing")
+	}
+	if err := faults.Set("remote-write-fail, slow-remote=250ms"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := faults.Set("truncate-remote"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	want := FaultSet{RemoteWriteFail: true, SlowRemote: 250 * time.Millisecond, TruncateRemote: true}
+	if faults != want || faults.String() != "remote-write-fail,slow-remote=250ms,truncate-remote" {
+		t.Errorf("Unexpected faults: %+v (%s)", faults, faults.String())
+	}
+
+	for _, value := range []string{"slow-remote=soon", "slow-remote=-1s", "truncate-remote=1", "disk-full"} {
+		if err := new(FaultSet).Set(value); err == nil {
+			t.Errorf("Expected an error for %q", value)
+		}
+	}
+}
+
+// Test a failed or truncated remote write leaves the local database intact
+// and the truncated remote is skipped until the next clean save repairs it
+func TestInjectRemoteWriteFault(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	t.Setenv("HOME", tmpDir)
+	defer func() { injectedFaults = FaultSet{} }()
+
+	remote := filepath.Join(tmpDir, "remote.json")
+	db := buildListDatabase(20)
+
+	injectedFaults = FaultSet{RemoteWriteFail: true}
+	if err := saveRemoteDatabase(remote, db); err == nil {
+		t.Fatal("Expected the injected write failure")
+	}
+	if _, err := os.Stat(remote); !os.IsNotExist(err) {
+		t.Error("A failed write must not touch the remote")
+	}
+
+	injectedFaults = FaultSet{TruncateRemote: true}
+	if err := saveRemoteDatabase(remote, db); err == nil {
+		t.Fatal("Expected the injected truncation to be reported")
+	}
+	local := buildListDatabase(1)
+	if merged := mergeRemoteInto(local, remote); len(merged.Added) != 1 || len(merged.Retry) != 1 {
+		t.Errorf("A truncated remote should be skipped, got %d added, %d retry", len(merged.Added), len(merged.Retry))
+	}
+
+	injectedFaults = FaultSet{}
+	if err := saveRemoteDatabase(remote, db); err != nil {
+		t.Fatalf("Clean save failed: %v", err)
+	}
+	if merged := mergeRemoteInto(local, remote); len(merged.Added) != 20 {
+		t.Errorf("Expected the repaired remote to merge, got %d added", len(merged.Added))
+	}
+}
+
+// Test hidden flags are left out of the usage message
+func TestPrintFlagDefaultsHidesFlags(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.String("host", "", "Deluge server host")
+	fs.Var(new(FaultSet), "inject", "Inject faults")
+	var out bytes.Buffer
+	fs.SetOutput(&out)
+
+	printFlagDefaults(fs)
+	if !strings.Contains(out.String(), "-host") || strings.Contains(out.String(), "-inject") {
+		t.Errorf("Unexpected usage:\n%s", out.String())
+	}
+}
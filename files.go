@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"path"
+	"strings"
+)
+
+// hasFileRules reports whether the label restricts which files download
+func (o LabelOptions) hasFileRules() bool {
+	return len(o.OnlyFiles) > 0 || len(o.SkipFiles) > 0
+}
+
+// TorrentFile is a file within a torrent as reported by Deluge
+type TorrentFile struct {
+	Index int
+	Path  string
+}
+
+// matchesAnyGlob reports whether a torrent file path matches one of the
+// patterns, compared case-insensitively against both the full path and
+// the file name
+func matchesAnyGlob(filePath string, patterns []string) bool {
+	filePath = strings.ToLower(filePath)
+	base := path.Base(filePath)
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+		if ok, _ := path.Match(pattern, filePath); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, base); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// selectFilePriorities applies label file rules to the current priorities,
+// setting skipped files to 0 and leaving wanted files unchanged. It returns
+// the new priorities and how many files were skipped.
+func selectFilePriorities(files []TorrentFile, current []int, rules LabelOptions) ([]int, int) {
+	priorities := make([]int, len(current))
+	copy(priorities, current)
+
+	skipped := 0
+	for _, f := range files {
+		if f.Index < 0 || f.Index >= len(priorities) {
+			continue
+		}
+		want := len(rules.OnlyFiles) == 0 || matchesAnyGlob(f.Path, rules.OnlyFiles)
+		if want && matchesAnyGlob(f.Path, rules.SkipFiles) {
+			want = false
+		}
+		if !want {
+			priorities[f.Index] = 0
+			skipped++
+		}
+	}
+	return priorities, skipped
+}
+
+// parseTorrentFiles reads the files and file_priorities fields of a torrent status
+func parseTorrentFiles(status map[string]interface{}) ([]TorrentFile, []int) {
+	var files []TorrentFile
+	rawFiles, _ := status["files"].([]interface{})
+	for i, raw := range rawFiles {
+		info, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		f := TorrentFile{Index: i}
+		if index, ok := info["index"].(float64); ok {
+			f.Index = int(index)
+		}
+		f.Path, _ = info["path"].(string)
+		files = append(files, f)
+	}
+
+	var priorities []int
+	rawPriorities, _ := status["file_priorities"].([]interface{})
+	for _, p := range rawPriorities {
+		value, _ := p.(float64)
+		priorities = append(priorities, int(value))
+	}
+	return files, priorities
+}
+
+// ApplyFileRules sets file priorities on a torrent whose metadata has
+// resolved so only the files wanted by the label's rules download
+func (c *DelugeClient) ApplyFileRules(torrentID string, rules LabelOptions) error {
+	status, err := c.GetTorrentStatus(torrentID, []string{"files", "file_priorities"})
+	if err != nil {
+		return err
+	}
+
+	files, current := parseTorrentFiles(status)
+	if len(files) == 0 || len(current) != len(files) {
+		return fmt.Errorf("file list not available yet")
+	}
+
+	priorities, skipped := selectFilePriorities(files, current, rules)
+	if skipped == 0 {
+		log.Printf("  File rules: all %d files wanted", len(files))
+		return nil
+	}
+	if skipped == len(files) {
+		return fmt.Errorf("file rules would skip all %d files, leaving priorities unchanged", len(files))
+	}
+
+	options := map[string]interface{}{"file_priorities": priorities}
+	if err := c.rpc("core.set_torrent_options", []interface{}{[]interface{}{torrentID}, options}, nil); err != nil {
+		return fmt.Errorf("failed to set file priorities: %w", err)
+	}
+	log.Printf("  File rules: skipping %d of %d files", skipped, len(files))
+	return nil
+}
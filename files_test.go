@@ -0,0 +1,85 @@
+package main
+
+import (
+	"testing"
+)
+
+// Test selectFilePriorities with only/skip globs
+func TestSelectFilePriorities(t *testing.T) {
+	files := []TorrentFile{
+		{0, "Book/01 - Chapter One.mp3"},
+		{1, "Book/02 - Chapter Two.mp3"},
+		{2, "Book/Sample/sample.mp3"},
+		{3, "Book/cover.jpg"},
+		{4, "Book/info.NFO"},
+	}
+	current := []int{4, 4, 4, 4, 4}
+
+	tests := []struct {
+		name     string
+		rules    LabelOptions
+		expected []int
+		skipped  int
+	}{
+		{"no rules", LabelOptions{}, []int{4, 4, 4, 4, 4}, 0},
+		{"skip samples and nfo", LabelOptions{SkipFiles: []string{"*sample*", "*.nfo"}}, []int{4, 4, 0, 4, 0}, 2},
+		{"skip sample directory", LabelOptions{SkipFiles: []string{"*/sample/*"}}, []int{4, 4, 0, 4, 4}, 1},
+		{"only audio", LabelOptions{OnlyFiles: []string{"*.mp3"}}, []int{4, 4, 4, 0, 0}, 2},
+		{"only audio without samples", LabelOptions{OnlyFiles: []string{"*.mp3"}, SkipFiles: []string{"sample.*"}}, []int{4, 4, 0, 0, 0}, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			priorities, skipped := selectFilePriorities(files, current, tt.rules)
+			if skipped != tt.skipped {
+				t.Errorf("Expected %d skipped, got %d", tt.skipped, skipped)
+			}
+			for i := range tt.expected {
+				if priorities[i] != tt.expected[i] {
+					t.Errorf("Expected priorities %v, got %v", tt.expected, priorities)
+					break
+				}
+			}
+		})
+	}
+
+	if current[3] != 4 {
+		t.Error("selectFilePriorities should not modify the current priorities")
+	}
+}
+
+// Test ApplyFileRules sends file priorities to Deluge
+func TestApplyFileRules(t *testing.T) {
+	var sent []interface{}
+	client := fakeDeluge(t, func(method string, params []interface{}) (interface{}, interface{}) {
+		switch method {
+		case "core.get_torrent_status":
+			return map[string]interface{}{
+				"files": []interface{}{
+					map[string]interface{}{"index": 0, "path": "Book/book.m4b"},
+					map[string]interface{}{"index": 1, "path": "Book/sample.m4b"},
+				},
+				"file_priorities": []interface{}{1, 1},
+			}, nil
+		case "core.set_torrent_options":
+			options := params[1].(map[string]interface{})
+			sent = options["file_priorities"].([]interface{})
+		}
+		return nil, nil
+	})
+
+	if err := client.ApplyFileRules("hash1", LabelOptions{SkipFiles: []string{"sample*"}}); err != nil {
+		t.Fatalf("ApplyFileRules failed: %v", err)
+	}
+	if len(sent) != 2 || sent[0] != 1.0 || sent[1] != 0.0 {
+		t.Errorf("Expected priorities [1 0], got %v", sent)
+	}
+
+	sent = nil
+	if err := client.ApplyFileRules("hash1", LabelOptions{OnlyFiles: []string{"*.mp3"}}); err == nil {
+		t.Error("Rules that skip every file should be an error")
+	}
+	if sent != nil {
+		t.Error("Priorities should not be sent when every file would be skipped")
+	}
+}
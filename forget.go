@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// runForget implements the forget command
+func runForget(config Config, args []string) error {
+	fs := newCommandFlags(forgetCommand)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("expected at least one hash or UUID")
+	}
+
+	db, err := LoadJSONDatabase(config.JSONPath)
+	if err != nil {
+		return fmt.Errorf("failed to load database: %w", err)
+	}
+
+	update := &MagnetDatabase{
+		Added:      make(map[string]MagnetEntry),
+		Retry:      make(map[string]MagnetEntry),
+		Tombstones: make(map[string]Tombstone),
+	}
+	now := time.Now()
+	var forgotten []string
+	for _, key := range fs.Args() {
+		hash, section, entry, ok := findEntry(db, key)
+		if !ok {
+			return fmt.Errorf("no entry found for %q", key)
+		}
+		update.Tombstones[hash] = newTombstone(hash, now)
+		forgotten = append(forgotten, fmt.Sprintf("%s entry %s: %s", section, hash[:min(8, len(hash))], entry.Title))
+	}
+
+	if err := SaveJSONDatabase(config.JSONPath, update, &config); err != nil {
+		return fmt.Errorf("failed to save database: %w", err)
+	}
+	for _, line := range forgotten {
+		log.Printf("✓ Forgot %s", line)
+	}
+	return nil
+}
+
+var forgetCommand = &Command{
+	Name:    "forget",
+	Usage:   "forget <hash|uuid>...",
+	Summary: "Delete entries from the database outright, leaving Deluge alone",
+}
+
+func init() {
+	forgetCommand.Run = runForget
+	registerCommand(forgetCommand)
+}
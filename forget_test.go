@@ -0,0 +1,62 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Test forget deletes entries with tombstones, keeps the database verifiable,
+// and the remote copy cannot bring them back
+func TestForget(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	t.Setenv("HOME", tmpDir)
+
+	config := DefaultConfig()
+	config.JSONPath = filepath.Join(tmpDir, "db.json")
+	config.RemotePath = filepath.Join(tmpDir, "remote.json")
+	db := &MagnetDatabase{
+		Added: map[string]MagnetEntry{
+			"hash1": {Hash: "hash1", UUID: "uuid-1", Title: "Mangled", AddedDate: "2024-01-01T00:00:00Z"},
+			"hash2": {Hash: "hash2", Title: "Keep", AddedDate: "2024-01-01T00:00:00Z"},
+		},
+		Retry: map[string]MagnetEntry{
+			"hash3": {Hash: "hash3", Title: "Bad quote", AddedDate: "2024-01-01T00:00:00Z"},
+		},
+	}
+	for _, path := range []string{config.JSONPath, config.RemotePath} {
+		if err := SaveDatabaseLocal(path, db); err != nil {
+			t.Fatalf("Failed to save database: %v", err)
+		}
+	}
+
+	if err := runForget(config, []string{"uuid-1", "hash3"}); err != nil {
+		t.Fatalf("forget failed: %v", err)
+	}
+	for _, path := range []string{config.JSONPath, config.RemotePath} {
+		saved, err := LoadJSONDatabase(path)
+		if err != nil {
+			t.Fatalf("LoadJSONDatabase failed: %v", err)
+		}
+		if _, ok := saved.Added["hash1"]; ok {
+			t.Errorf("%s: hash1 should be forgotten", path)
+		}
+		if _, ok := saved.Retry["hash3"]; ok {
+			t.Errorf("%s: hash3 should be forgotten", path)
+		}
+		if _, ok := saved.Added["hash2"]; !ok || len(saved.Tombstones) != 2 {
+			t.Errorf("%s: expected hash2 kept and two tombstones, got %+v", path, saved)
+		}
+		if err := VerifyDatabase(saved); err != nil {
+			t.Errorf("%s: database should verify after forget: %v", path, err)
+		}
+	}
+
+	if err := runForget(config, []string{"missing"}); err == nil {
+		t.Error("Expected an error for an unknown entry")
+	}
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var generateCommand = &Command{
+	Name:    "generate",
+	Usage:   "generate [--entries n] [--days n] [--seed n] [--force] <file>",
+	Summary: "Write a database of made-up entries for testing sync and merges",
+}
+
+func init() {
+	generateCommand.Run = runGenerate
+	registerCommand(generateCommand)
+}
+
+// Word lists titles, labels, and trackers are drawn from
+var (
+	generateAdjectives = []string{"Silent", "Broken", "Golden", "Last", "Hidden", "Iron", "Distant", "Crimson", "Forgotten", "Wandering", "Hollow", "Burning", "Midnight", "Glass", "Winter"}
+	generateNouns      = []string{"Kingdom", "River", "Empire", "Garden", "Machine", "Witness", "Harbor", "Signal", "Orchard", "Archive", "Voyage", "Crown", "Labyrinth", "Lantern", "Covenant"}
+	generatePlaces     = []string{"the North", "Avalon", "the Deep", "Ashford", "the Stars", "Carthage", "the Marsh", "Kells", "the Frontier", "Elsinore"}
+	generateAuthors    = []string{"A. Marlowe", "J. Okafor", "R. Lindqvist", "M. Tanaka", "S. Delacroix", "P. Novak", "L. Haddad", "C. Whitfield", "E. Moreau", "K. Brennan"}
+	generateEditions   = []string{"Unabridged", "Abridged", "MP3 64kbps", "M4B", "Dramatized", "Read by the Author"}
+	generateLabels     = []string{"audiobooks", "audiobooks", "audiobooks", "podcasts", "books", "courses"}
+	generateTrackers   = []string{
+		"udp://tracker.opentrackr.org:1337/announce",
+		"udp://open.demonii.com:1337/announce",
+		"udp://tracker.torrent.eu.org:451/announce",
+		"udp://exodus.desync.com:6969/announce",
+		"https://tracker.example.org/announce",
+	}
+	generateDevices = []string{"laptop", "desktop", "seedbox"}
+	generateStates  = []string{"Seeding", "Seeding", "Seeding", "Downloading", "Paused", "Queued"}
+	generateErrors  = []string{
+		"connection refused",
+		"request timed out",
+		"authentication failed",
+		"Deluge is not connected to a daemon",
+	}
+)
+
+func runGenerate(config Config, args []string) error {
+	fs := newCommandFlags(generateCommand)
+	entries := fs.Int("entries", 1000, "Number of entries to generate")
+	days := fs.Int("days", 730, "Spread added dates over this many days")
+	seed := fs.Uint64("seed", 0, "Random seed, to generate the same database again (0 = random)")
+	force := fs.Bool("force", false, "Overwrite the file if it exists")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected the file to write")
+	}
+	if *entries < 1 || *days < 1 {
+		return fmt.Errorf("--entries and --days must be at least 1")
+	}
+
+	path := fs.Arg(0)
+	if target, err := filepath.Abs(path); err == nil {
+		if configured, err := filepath.Abs(config.JSONPath); err == nil && target == configured {
+			return fmt.Errorf("refusing to overwrite the configured database %s", config.JSONPath)
+		}
+	}
+	if _, err := os.Stat(path); err == nil && !*force {
+		return fmt.Errorf("%s already exists (use --force to overwrite)", path)
+	}
+
+	if *seed == 0 {
+		*seed = rand.Uint64()
+	}
+	db := generateDatabase(rand.New(rand.NewPCG(*seed, *seed)), *entries, *days, time.Now())
+	if err := SaveDatabaseLocal(path, db); err != nil {
+		return fmt.Errorf("failed to write database: %w", err)
+	}
+
+	log.Printf("✓ Generated %s in %s (%d added, %d retry, %d removed; seed %d)",
+		plural(*entries, "entry", "entries"), path, len(db.Added), len(db.Retry), len(db.Removed), *seed)
+	return nil
+}
+
+// generateDatabase fabricates a database of entries added over the days
+// before now: mostly added, some waiting to retry, a few removed, each last
+// changed by one of a handful of devices
+func generateDatabase(rng *rand.Rand, entries, days int, now time.Time) *MagnetDatabase {
+	db := &MagnetDatabase{
+		Metadata: DatabaseMetadata{Clock: map[string]int64{}},
+		Added:    map[string]MagnetEntry{},
+		Retry:    map[string]MagnetEntry{},
+		Removed:  map[string]MagnetEntry{},
+	}
+
+	seen := map[string]bool{}
+	for i := 0; i < entries; i++ {
+		entry := generateEntry(rng, days, now)
+		if seen[entry.Hash] {
+			i--
+			continue
+		}
+		seen[entry.Hash] = true
+		entry.ID = int64(i + 1)
+		db.Metadata.Clock[entry.Device]++
+		entry.Counter = db.Metadata.Clock[entry.Device]
+
+		switch {
+		case entry.RemovedAt != "":
+			db.Removed[entry.Hash] = entry
+		case entry.Status == "success":
+			db.Added[entry.Hash] = entry
+		default:
+			db.Retry[entry.Hash] = entry
+		}
+	}
+	db.Metadata.LastSequence = int64(entries)
+	return db
+}
+
+// generateEntry fabricates one entry with a history matching its section
+func generateEntry(rng *rand.Rand, days int, now time.Time) MagnetEntry {
+	title := generateTitle(rng)
+	hash := generateHex(rng, 20)
+	added := now.Add(-time.Duration(rng.Int64N(int64(days) * int64(24*time.Hour))))
+	label := generateLabels[rng.IntN(len(generateLabels))]
+	host := generateDevices[rng.IntN(len(generateDevices))]
+
+	entry := MagnetEntry{
+		UUID:      generateUUID(rng),
+		Title:     title,
+		Hash:      hash,
+		URI:       generateURI(rng, hash, title),
+		AddedDate: added.Format(time.RFC3339),
+		FirstSeen: added.Format(time.RFC3339),
+		Label:     label,
+		Device:    host,
+	}
+	event := func(at time.Time, name, acceptedBy, detail string) {
+		entry.History = append(entry.History, HistoryEvent{At: at.Format(time.RFC3339), Event: name, Host: host, AcceptedBy: acceptedBy, Detail: detail})
+	}
+	// later returns a time after t, but never after now
+	later := func(t time.Time, max time.Duration) time.Time {
+		if next := t.Add(time.Duration(rng.Int64N(int64(max)))); next.Before(now) {
+			return next
+		}
+		return now
+	}
+
+	if rng.IntN(100) < 20 {
+		entry.Status = "failed"
+		entry.RetryCount = 1 + rng.IntN(8)
+		message := generateErrors[rng.IntN(len(generateErrors))]
+		if strings.Contains(message, "daemon") {
+			entry.ErrorKind = ErrorNotConnected
+		}
+		event(added, HistoryQueued, "", message)
+		attempt := added
+		for i := 1; i < entry.RetryCount && len(entry.History) < maxHistory; i++ {
+			attempt = later(attempt, 12*time.Hour)
+			event(attempt, HistoryRetryFailed, "", message)
+		}
+		entry.LastAttempt = attempt.Format(time.RFC3339)
+		if rng.IntN(2) == 0 {
+			entry.NextAttempt = attempt.Add(time.Duration(entry.RetryCount) * time.Hour).Format(time.RFC3339)
+		}
+		return entry
+	}
+
+	accepted := later(added, 5*time.Minute)
+	entry.Status = "success"
+	entry.TorrentID = hash
+	entry.AddedToDeluge = accepted.Format(time.RFC3339)
+	entry.LastAttempt = entry.AddedToDeluge
+	entry.SavePath = "/downloads/" + label
+	entry.TorrentName = title
+	event(accepted, HistoryAdded, "deluge", "")
+
+	entry.State = generateStates[rng.IntN(len(generateStates))]
+	if entry.State == "Seeding" {
+		completed := later(accepted, 48*time.Hour)
+		entry.Progress = 100
+		entry.CompletedAt = completed.Format(time.RFC3339)
+		event(completed, HistoryCompleted, "", "")
+	} else {
+		entry.Progress = float64(rng.IntN(1000)) / 10
+	}
+
+	if rng.IntN(100) < 12 {
+		removed := later(accepted, time.Duration(days)*24*time.Hour)
+		entry.RemovedAt = removed.Format(time.RFC3339)
+		event(removed, HistoryRemoved, "", "")
+	}
+	return entry
+}
+
+// generateTitle makes up an audiobook-style title with an author, and
+// sometimes a series number, year, or edition
+func generateTitle(rng *rand.Rand) string {
+	pick := func(words []string) string { return words[rng.IntN(len(words))] }
+
+	var title string
+	switch rng.IntN(3) {
+	case 0:
+		title = "The " + pick(generateAdjectives) + " " + pick(generateNouns)
+	case 1:
+		title = pick(generateNouns) + " of " + pick(generatePlaces)
+	default:
+		title = "The " + pick(generateNouns) + " of the " + pick(generateAdjectives) + " " + pick(generateNouns)
+	}
+	if rng.IntN(4) == 0 {
+		title += fmt.Sprintf(", Book %d", 1+rng.IntN(9))
+	}
+	title = pick(generateAuthors) + " - " + title
+	if rng.IntN(2) == 0 {
+		title += fmt.Sprintf(" (%d)", 1950+rng.IntN(76))
+	}
+	if rng.IntN(3) == 0 {
+		title += " [" + pick(generateEditions) + "]"
+	}
+	return title
+}
+
+// generateURI builds a magnet link with a display name and one to three trackers
+func generateURI(rng *rand.Rand, hash, title string) string {
+	params := url.Values{}
+	params.Set("dn", title)
+	for _, i := range rng.Perm(len(generateTrackers))[:1+rng.IntN(3)] {
+		params.Add("tr", generateTrackers[i])
+	}
+	return "magnet:?xt=urn:btih:" + hash + "&" + params.Encode()
+}
+
+// generateHex returns n random bytes from rng as lowercase hex
+func generateHex(rng *rand.Rand, n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(rng.UintN(256))
+	}
+	return fmt.Sprintf("%x", b)
+}
+
+// generateUUID is GenerateUUID drawing from rng, so a seed reproduces the
+// same database
+func generateUUID(rng *rand.Rand) string {
+	uuid := []byte(generateHex(rng, 16))
+	uuid[12] = '4'
+	uuid[16] = "89ab"[rng.IntN(4)]
+	s := string(uuid)
+	return s[0:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:]
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test generated databases have every section, unique hashes, and history
+// that never runs past now, and that a seed reproduces them
+func TestGenerateDatabase(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	db := generateDatabase(rand.New(rand.NewPCG(7, 7)), 2000, 365, now)
+
+	if total := len(db.Added) + len(db.Retry) + len(db.Removed); total != 2000 {
+		t.Fatalf("Expected 2000 entries, got %d", total)
+	}
+	if len(db.Added) == 0 || len(db.Retry) == 0 || len(db.Removed) == 0 {
+		t.Fatalf("Expected entries in every section, got %d added, %d retry, %d removed", len(db.Added), len(db.Retry), len(db.Removed))
+	}
+	if db.Metadata.LastSequence != 2000 || len(db.Metadata.Clock) != len(generateDevices) {
+		t.Errorf("Unexpected metadata: %+v", db.Metadata)
+	}
+
+	for _, section := range []map[string]MagnetEntry{db.Added, db.Retry, db.Removed} {
+		for hash, entry := range section {
+			if len(hash) != 40 || !strings.Contains(entry.URI, hash) || !strings.Contains(entry.URI, "&tr=") {
+				t.Fatalf("Malformed entry: %+v", entry)
+			}
+			for _, event := range entry.History {
+				if at, err := time.Parse(time.RFC3339, event.At); err != nil || at.After(now) {
+					t.Fatalf("History event %+v is not before now", event)
+				}
+			}
+		}
+	}
+	for _, entry := range db.Retry {
+		if entry.Status != "failed" || entry.RetryCount == 0 || entry.History[0].Event != HistoryQueued {
+			t.Fatalf("Unexpected retry entry: %+v", entry)
+		}
+	}
+
+	again := generateDatabase(rand.New(rand.NewPCG(7, 7)), 2000, 365, now)
+	if !reflect.DeepEqual(db, again) {
+		t.Error("The same seed should generate the same database")
+	}
+}
+
+// Test generate writes a loadable database and will not overwrite files
+// without --force, or the configured database at all
+func TestRunGenerate(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	t.Setenv("HOME", tmpDir)
+
+	config := DefaultConfig()
+	config.JSONPath = filepath.Join(tmpDir, "magnet-list-local.json")
+	path := filepath.Join(tmpDir, "generated.json")
+
+	if err := runGenerate(config, []string{"--entries", "50", "--seed", "3", path}); err != nil {
+		t.Fatalf("generate failed: %v", err)
+	}
+	db, err := LoadJSONDatabase(path)
+	if err != nil {
+		t.Fatalf("LoadJSONDatabase failed: %v", err)
+	}
+	if total := len(db.Added) + len(db.Retry) + len(db.Removed); total != 50 {
+		t.Errorf("Expected 50 entries, got %d", total)
+	}
+
+	if err := runGenerate(config, []string{"--entries", "5", path}); err == nil {
+		t.Error("Expected an existing file to be refused without --force")
+	}
+	if err := runGenerate(config, []string{"--entries", "5", "--force", path}); err != nil {
+		t.Errorf("Expected --force to overwrite: %v", err)
+	}
+	if err := runGenerate(config, []string{"--force", config.JSONPath}); err == nil {
+		t.Error("Expected the configured database to be refused")
+	}
+}
@@ -4,4 +4,7 @@ go 1.24.0
 
 toolchain go1.24.2
 
-require golang.org/x/sys v0.38.0
+require (
+	golang.org/x/sys v0.38.0
+	golang.org/x/term v0.37.0
+)
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Transfer outcomes recorded on entries by the handoff command
+const (
+	TransferDone   = "done"
+	TransferFailed = "failed"
+)
+
+// Handoff defaults
+const (
+	defaultTransferAttempts = 3
+	defaultTransferTimeout  = 4 * time.Hour
+)
+
+// HandoffConfig describes how completed downloads are pulled from a seedbox.
+// Command is run without a shell; placeholders are replaced within each
+// argument so names with spaces stay one argument:
+//
+//	{name} {title} {hash} {label} {save_path} {content_path}
+type HandoffConfig struct {
+	Target      string `json:"target"`                 // Target the torrents complete on (default deluge)
+	Command     string `json:"command"`                // e.g. rclone copy "seedbox:{content_path}" "/media/books/{name}"
+	Timeout     int    `json:"timeout,omitempty"`      // Seconds per transfer (0 = 4 hours)
+	MaxAttempts int    `json:"max_attempts,omitempty"` // Failed transfers are retried this many times (0 = 3)
+}
+
+// maxAttempts returns the configured attempt limit or the default
+func (h HandoffConfig) maxAttempts() int {
+	if h.MaxAttempts > 0 {
+		return h.MaxAttempts
+	}
+	return defaultTransferAttempts
+}
+
+// timeout returns the configured per-transfer timeout or the default
+func (h HandoffConfig) timeout() time.Duration {
+	if h.Timeout > 0 {
+		return time.Duration(h.Timeout) * time.Second
+	}
+	return defaultTransferTimeout
+}
+
+// needsTransfer reports whether an added entry is waiting for a handoff
+func needsTransfer(entry MagnetEntry, handoff HandoffConfig) bool {
+	if entry.TransferStatus == TransferDone {
+		return false
+	}
+	if entry.TransferStatus == TransferFailed && entry.TransferAttempts >= handoff.maxAttempts() {
+		return false
+	}
+	if handoff.Target != "" && handoff.Target != primaryTargetName {
+		return entry.Targets[handoff.Target].accepted()
+	}
+	return true
+}
+
+// expandTransferCommand builds the transfer command for one torrent
+func expandTransferCommand(command string, entry MagnetEntry, torrent remoteTorrent) []string {
+	replacer := strings.NewReplacer(
+		"{name}", torrent.Name,
+		"{title}", entry.Title,
+		"{hash}", entry.Hash,
+		"{label}", entry.Label,
+		"{save_path}", torrent.SavePath,
+		"{content_path}", torrent.ContentPath,
+	)
+	args := splitCommandLine(command)
+	for i, arg := range args {
+		args[i] = replacer.Replace(arg)
+	}
+	return args
+}
+
+// runTransfer runs the transfer command, returning a short error on failure
+func runTransfer(args []string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, args[0], args[1:]...).CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("timed out after %s", timeout)
+	}
+	if err != nil {
+		// Keep the last lines of output, where tools print the reason
+		lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+		if len(lines) > 3 {
+			lines = lines[len(lines)-3:]
+		}
+		if tail := strings.TrimSpace(strings.Join(lines, " | ")); tail != "" {
+			return fmt.Errorf("%w: %s", err, tail)
+		}
+		return err
+	}
+	return nil
+}
+
+// RunHandoff transfers every completed, not yet transferred torrent from the
+// handoff target and records the outcome on its entry
+func RunHandoff(config Config, dryRun bool) error {
+	if config.Handoff == nil || config.Handoff.Command == "" {
+		return fmt.Errorf("no handoff configured: set handoff.command in the config")
+	}
+	handoff := *config.Handoff
+	if len(splitCommandLine(handoff.Command)) == 0 {
+		return fmt.Errorf("handoff command is empty")
+	}
+
+	target, err := findTarget(config, handoff.Target)
+	if err != nil {
+		return err
+	}
+
+	db, err := LoadJSONDatabase(config.JSONPath)
+	if err != nil {
+		return fmt.Errorf("failed to load database: %w", err)
+	}
+
+	var hashes []string
+	for hash, entry := range db.Added {
+		if needsTransfer(entry, handoff) {
+			hashes = append(hashes, hash)
+		}
+	}
+	if len(hashes) == 0 {
+		log.Println("✓ Nothing waiting for transfer")
+		return nil
+	}
+	sort.Strings(hashes)
+
+	log.Printf("Checking %d torrent(s) on %s...", len(hashes), target.Name())
+	torrents, err := target.Torrents(hashes)
+	if err != nil {
+		return fmt.Errorf("failed to get torrents from %s: %w", target.Name(), err)
+	}
+
+	transferred, failed, pending := 0, 0, 0
+	for _, hash := range hashes {
+		entry := db.Added[hash]
+		torrent, ok := torrents[strings.ToLower(hash)]
+		if !ok || !torrent.Complete {
+			pending++
+			continue
+		}
+
+		args := expandTransferCommand(handoff.Command, entry, torrent)
+		if dryRun {
+			log.Printf("Would transfer %s: %s", entry.Title, strings.Join(args, " "))
+			continue
+		}
+
+		log.Printf("Transferring: %s", entry.Title)
+		entry.TransferAttempts++
+		if err := runTransfer(args, handoff.timeout()); err != nil {
+			log.Printf("  ✗ Transfer failed (attempt %d of %d): %v", entry.TransferAttempts, handoff.maxAttempts(), err)
+			entry.TransferStatus, entry.TransferError = TransferFailed, err.Error()
+			failed++
+		} else {
+			log.Printf("  ✓ Transferred")
+			entry.TransferStatus, entry.TransferError = TransferDone, ""
+			entry.TransferredAt = time.Now().Format(time.RFC3339)
+			transferred++
+		}
+
+		// Save after each transfer, they can take hours
+		if err := SaveJSONDatabase(config.JSONPath, entryUpdate(SectionAdded, hash, entry), &config); err != nil {
+			log.Printf("Warning: Failed to save database: %v", err)
+		}
+	}
+
+	log.Println(strings.Repeat("=", 60))
+	log.Println("Handoff Summary:")
+	log.Printf("  Transferred: %d", transferred)
+	log.Printf("  Failed: %d", failed)
+	log.Printf("  Still downloading: %d", pending)
+	log.Println(strings.Repeat("=", 60))
+
+	if failed > 0 {
+		return fmt.Errorf("%d transfer(s) failed", failed)
+	}
+	return nil
+}
+
+// runHandoffCommand implements the handoff command
+func runHandoffCommand(config Config, args []string) error {
+	fs := newCommandFlags(handoffCommand)
+	dryRun := fs.Bool("dry-run", false, "Show the transfer commands without running them")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	return RunHandoff(config, *dryRun)
+}
+
+var handoffCommand = &Command{
+	Name:    "handoff",
+	Usage:   "handoff [--dry-run]",
+	Summary: "Pull completed downloads from the seedbox with the configured transfer command",
+}
+
+func init() {
+	handoffCommand.Run = runHandoffCommand
+	registerCommand(handoffCommand)
+}
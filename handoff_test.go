@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+// Test expandTransferCommand keeps substituted names in one argument
+func TestExpandTransferCommand(t *testing.T) {
+	entry := MagnetEntry{Title: "My Book", Hash: "abc", Label: "books"}
+	torrent := remoteTorrent{Name: "My Book (2024)", SavePath: "/data", ContentPath: "/data/My Book (2024)"}
+
+	got := expandTransferCommand(`rclone copy "seedbox:{content_path}" "/media/{label}/{name}" --log-file /tmp/{hash}.log`, entry, torrent)
+	expected := []string{"rclone", "copy", "seedbox:/data/My Book (2024)", "/media/books/My Book (2024)", "--log-file", "/tmp/abc.log"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expandTransferCommand() = %q, want %q", got, expected)
+	}
+}
+
+// Test needsTransfer skips finished, exhausted, and unsent entries
+func TestNeedsTransfer(t *testing.T) {
+	seedbox := HandoffConfig{Target: "seedbox"}
+	tests := []struct {
+		name     string
+		entry    MagnetEntry
+		handoff  HandoffConfig
+		expected bool
+	}{
+		{"primary target", MagnetEntry{}, HandoffConfig{}, true},
+		{"already transferred", MagnetEntry{TransferStatus: TransferDone}, HandoffConfig{}, false},
+		{"failed, attempts left", MagnetEntry{TransferStatus: TransferFailed, TransferAttempts: 2}, HandoffConfig{}, true},
+		{"failed, attempts used", MagnetEntry{TransferStatus: TransferFailed, TransferAttempts: 3}, HandoffConfig{}, false},
+		{"on seedbox", MagnetEntry{Targets: map[string]TargetStatus{"seedbox": {Status: RetrySucceeded}}}, seedbox, true},
+		{"seedbox refused", MagnetEntry{Targets: map[string]TargetStatus{"seedbox": {Status: RetryFailed}}}, seedbox, false},
+		{"never sent to seedbox", MagnetEntry{}, seedbox, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := needsTransfer(tt.entry, tt.handoff); got != tt.expected {
+				t.Errorf("needsTransfer() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+// Test RunHandoff transfers completed seedbox torrents and records the result
+func TestRunHandoff(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Transfer commands in this test need a Unix shell environment")
+	}
+
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	t.Setenv("HOME", tmpDir)
+
+	seedbox := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v2/auth/login":
+			w.Write([]byte("Ok."))
+		case "/api/v2/torrents/info":
+			json.NewEncoder(w).Encode([]QBittorrentTorrent{
+				{Hash: "AAAA", Name: "Done Book", SavePath: "/data", ContentPath: "/data/Done Book", State: "stalledUP", Progress: 1},
+				{Hash: "bbbb", Name: "Partial Book", SavePath: "/data", State: "downloading", Progress: 0.4},
+			})
+		}
+	}))
+	defer seedbox.Close()
+
+	onSeedbox := map[string]TargetStatus{"seedbox": {Status: RetrySucceeded}}
+	config := DefaultConfig()
+	config.JSONPath = filepath.Join(tmpDir, "db.json")
+	config.RemotePath = ""
+	config.Targets = []TargetConfig{{Name: "seedbox", Type: TargetQBittorrent, URL: seedbox.URL}}
+	config.Handoff = &HandoffConfig{Target: "seedbox", Command: `touch "` + tmpDir + `/{name}.done"`}
+
+	db := &MagnetDatabase{
+		Added: map[string]MagnetEntry{
+			"aaaa": {UUID: "uuid-a", Hash: "aaaa", Title: "Done Book", Targets: onSeedbox},
+			"bbbb": {UUID: "uuid-b", Hash: "bbbb", Title: "Partial Book", Targets: onSeedbox},
+		},
+		Retry: map[string]MagnetEntry{},
+	}
+	if err := SaveDatabaseLocal(config.JSONPath, db); err != nil {
+		t.Fatalf("Failed to save database: %v", err)
+	}
+
+	if err := RunHandoff(config, false); err != nil {
+		t.Fatalf("RunHandoff failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "Done Book.done")); err != nil {
+		t.Errorf("Transfer command should have run: %v", err)
+	}
+
+	db, _ = LoadJSONDatabase(config.JSONPath)
+	if entry := db.Added["aaaa"]; entry.TransferStatus != TransferDone || entry.TransferredAt == "" || entry.TransferAttempts != 1 {
+		t.Errorf("Completed torrent should be marked transferred: %+v", entry)
+	}
+	if entry := db.Added["bbbb"]; entry.TransferStatus != "" {
+		t.Errorf("Downloading torrent should be left alone: %+v", entry)
+	}
+
+	// A failing command is recorded and retried on the next run
+	entry := db.Added["aaaa"]
+	entry.TransferStatus = ""
+	SaveJSONDatabase(config.JSONPath, entryUpdate(SectionAdded, "aaaa", entry), &config)
+	config.Handoff.Command = "false"
+	if err := RunHandoff(config, false); err == nil {
+		t.Error("RunHandoff should report failed transfers")
+	}
+	db, _ = LoadJSONDatabase(config.JSONPath)
+	if entry := db.Added["aaaa"]; entry.TransferStatus != TransferFailed || entry.TransferError == "" || entry.TransferAttempts != 2 {
+		t.Errorf("Failed transfer should be recorded: %+v", entry)
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// maxHistory is how many events each entry keeps; older ones are dropped
+const maxHistory = 20
+
+// History events recorded on entries
+const (
+	HistoryAdded       = "added"        // A client accepted the torrent
+	HistoryDuplicate   = "duplicate"    // Already present in the client
+	HistoryQueued      = "queued"       // First add failed, moved to the retry queue
+	HistoryRetryFailed = "retry_failed" // A retry attempt failed
+	HistoryGaveUp      = "gave_up"      // Used up max_retries, moved to the failed section
+	HistoryCompleted   = "completed"    // Download finished
+	HistoryVerifying   = "verifying"    // Recheck requested from the client
+	HistoryVerified    = "verified"     // Data matched the piece hashes
+	HistoryCorrupt     = "corrupt"      // Data failed verification
+	HistorySorted      = "sorted"       // Filed into its dated folder
+	HistoryReleased    = "released"     // Resumed after being held by max_active
+	HistoryRemoved     = "removed"      // Removed from the client or the dashboard
+	HistoryAdopted     = "adopted"      // Imported from the client by the adopt command
+	HistoryRolledBack  = "rolled_back"  // Restored by devices rollback
+)
+
+// HistoryEvent is one change in an entry's life
+type HistoryEvent struct {
+	At         string `json:"at"`
+	Event      string `json:"event"`
+	Host       string `json:"host,omitempty"`        // Machine that recorded the event
+	AcceptedBy string `json:"accepted_by,omitempty"` // Client that took the torrent
+	Detail     string `json:"detail,omitempty"`      // Error or other context
+}
+
+var (
+	hostnameOnce sync.Once
+	hostname     string
+)
+
+// localHostname returns this machine's name for history events
+func localHostname() string {
+	hostnameOnce.Do(func() {
+		hostname, _ = os.Hostname()
+	})
+	return hostname
+}
+
+// recordHistory appends an event to the entry, keeping only the newest
+// maxHistory events, and counts it for the run summary
+func (e *MagnetEntry) recordHistory(event, acceptedBy, detail string) {
+	countRun(event, 1)
+	e.History = append(e.History, HistoryEvent{
+		At:         time.Now().Format(time.RFC3339),
+		Event:      event,
+		Host:       localHostname(),
+		AcceptedBy: acceptedBy,
+		Detail:     detail,
+	})
+	if len(e.History) > maxHistory {
+		e.History = e.History[len(e.History)-maxHistory:]
+	}
+}
+
+// mergeHistory combines the histories recorded for one entry on different
+// machines, dropping duplicates and keeping the newest maxHistory events
+func mergeHistory(histories ...[]HistoryEvent) []HistoryEvent {
+	seen := make(map[HistoryEvent]bool)
+	var merged []HistoryEvent
+	for _, history := range histories {
+		for _, event := range history {
+			if !seen[event] {
+				seen[event] = true
+				merged = append(merged, event)
+			}
+		}
+	}
+	sort.SliceStable(merged, func(i, j int) bool {
+		return parseTimestamp(merged[i].At).Before(parseTimestamp(merged[j].At))
+	})
+	if len(merged) > maxHistory {
+		merged = merged[len(merged)-maxHistory:]
+	}
+	return merged
+}
+
+// runHistory implements the history command
+func runHistory(config Config, args []string) error {
+	fs := newCommandFlags(historyCommand)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected exactly one hash or UUID")
+	}
+
+	db, err := LoadJSONDatabase(config.JSONPath)
+	if err != nil {
+		return fmt.Errorf("failed to load database: %w", err)
+	}
+	_, section, entry, ok := findEntry(db, fs.Arg(0))
+	if !ok {
+		return fmt.Errorf("no entry found for %q", fs.Arg(0))
+	}
+
+	fmt.Printf("%s (%s, %s)\n", entry.Title, entry.Hash, section)
+	if link := entryWebUILink(section, entry.Hash); link != "" {
+		fmt.Printf("Web UI: %s\n", link)
+	}
+	if len(entry.History) == 0 {
+		fmt.Println("No history recorded")
+		return nil
+	}
+	for _, event := range entry.History {
+		line := fmt.Sprintf("%-25s %-12s %s", event.At, event.Event, event.Host)
+		if event.AcceptedBy != "" {
+			line += " -> " + event.AcceptedBy
+		}
+		if event.Detail != "" {
+			line += ": " + event.Detail
+		}
+		fmt.Println(line)
+	}
+	return nil
+}
+
+var historyCommand = &Command{
+	Name:    "history",
+	Usage:   "history <hash|uuid>",
+	Summary: "Show the recorded status changes for one entry",
+}
+
+func init() {
+	historyCommand.Run = runHistory
+	registerCommand(historyCommand)
+}
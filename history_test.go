@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// Test history is capped at the newest maxHistory events
+func TestRecordHistoryBounded(t *testing.T) {
+	entry := MagnetEntry{}
+	for i := 0; i < maxHistory+5; i++ {
+		entry.recordHistory(HistoryRetryFailed, "", fmt.Sprintf("attempt #%d", i))
+	}
+	if len(entry.History) != maxHistory {
+		t.Fatalf("Expected %d events, got %d", maxHistory, len(entry.History))
+	}
+	if last := entry.History[maxHistory-1].Detail; last != fmt.Sprintf("attempt #%d", maxHistory+4) {
+		t.Errorf("Newest event should be kept, got %q", last)
+	}
+	if entry.History[0].Host != localHostname() {
+		t.Errorf("Expected host %q, got %q", localHostname(), entry.History[0].Host)
+	}
+}
+
+// Test merges keep events recorded on both machines in time order
+func TestMergeKeepsHistoryFromBothSides(t *testing.T) {
+	queued := HistoryEvent{At: "2024-01-01T00:00:00Z", Event: HistoryQueued, Host: "laptop", Detail: "connection failed"}
+	retried := HistoryEvent{At: "2024-01-02T00:00:00Z", Event: HistoryRetryFailed, Host: "laptop"}
+	added := HistoryEvent{At: "2024-01-03T00:00:00Z", Event: HistoryAdded, Host: "desktop", AcceptedBy: "deluge.local"}
+
+	local := &MagnetDatabase{
+		Added: map[string]MagnetEntry{},
+		Retry: map[string]MagnetEntry{"abc": {ID: 1, Hash: "abc", History: []HistoryEvent{queued, retried}}},
+	}
+	remote := &MagnetDatabase{
+		Added: map[string]MagnetEntry{"abc": {ID: 1, Hash: "abc", History: []HistoryEvent{queued, added}}},
+		Retry: map[string]MagnetEntry{},
+	}
+
+	merged := MergeDatabases(local, remote)
+	history := merged.Added["abc"].History
+	if len(history) != 3 {
+		t.Fatalf("Expected 3 events, got %+v", history)
+	}
+	for i, want := range []HistoryEvent{queued, retried, added} {
+		if history[i] != want {
+			t.Errorf("Event %d: expected %+v, got %+v", i, want, history[i])
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+)
+
+// maxHASensorTitles caps how many titles the queue sensor lists, keeping
+// Home Assistant's state attributes small
+const maxHASensorTitles = 20
+
+// haState is a Home Assistant RESTful sensor payload: the state plus
+// attributes, read with value_template: "{{ value_json.state }}" and
+// json_attributes: [...]
+type haState struct {
+	State      interface{}            `json:"state"`
+	Attributes map[string]interface{} `json:"attributes"`
+}
+
+// haRoutes registers the Home Assistant endpoints when enabled in the config
+func (s *apiServer) haRoutes(mux *http.ServeMux) {
+	if !s.config.HomeAssistant {
+		return
+	}
+	mux.HandleFunc("GET /api/ha/retry_queue", s.handleHARetryQueue)
+	mux.HandleFunc("GET /api/ha/added", s.handleHAAdded)
+	mux.HandleFunc("POST /api/ha/add", s.handleHAAdd)
+	mux.HandleFunc("POST /api/ha/retry", s.handleHARetry)
+}
+
+// sortedTitles returns up to limit entry titles, newest first
+func sortedTitles(entries map[string]MagnetEntry, limit int) []string {
+	list := make([]MagnetEntry, 0, len(entries))
+	for _, entry := range entries {
+		list = append(list, entry)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].AddedDate > list[j].AddedDate })
+
+	titles := make([]string, 0, min(limit, len(list)))
+	for _, entry := range list[:min(limit, len(list))] {
+		titles = append(titles, entry.Title)
+	}
+	return titles
+}
+
+// handleHARetryQueue reports the retry queue depth as a sensor
+func (s *apiServer) handleHARetryQueue(w http.ResponseWriter, r *http.Request) {
+	db, err := LoadJSONDatabase(s.config.JSONPath)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("failed to load database: %w", err))
+		return
+	}
+
+	oldest := ""
+	for _, entry := range db.Retry {
+		if oldest == "" || entry.AddedDate < oldest {
+			oldest = entry.AddedDate
+		}
+	}
+	writeJSON(w, http.StatusOK, haState{
+		State: len(db.Retry),
+		Attributes: map[string]interface{}{
+			"friendly_name":       "Magnet retry queue",
+			"unit_of_measurement": "magnets",
+			"icon":                "mdi:magnet",
+			"titles":              sortedTitles(db.Retry, maxHASensorTitles),
+			"oldest":              oldest,
+		},
+	})
+}
+
+// handleHAAdded reports how many magnets have been added as a sensor
+func (s *apiServer) handleHAAdded(w http.ResponseWriter, r *http.Request) {
+	db, err := LoadJSONDatabase(s.config.JSONPath)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("failed to load database: %w", err))
+		return
+	}
+
+	latest := sortedTitles(db.Added, 1)
+	attributes := map[string]interface{}{
+		"friendly_name":       "Magnets added",
+		"unit_of_measurement": "magnets",
+		"icon":                "mdi:download",
+		"latest":              "",
+	}
+	if len(latest) > 0 {
+		attributes["latest"] = latest[0]
+	}
+	writeJSON(w, http.StatusOK, haState{State: len(db.Added), Attributes: attributes})
+}
+
+// handleHAAdd adds a magnet, for a rest_command service:
+//
+//	payload: '{"magnet": "{{ magnet }}"}'
+func (s *apiServer) handleHAAdd(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Magnet string `json:"magnet"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+	magnetURI := NormalizeMagnetURI(body.Magnet)
+	if !ValidateMagnetURI(magnetURI) {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid magnet URI"))
+		return
+	}
+
+	if !s.admit(w) {
+		return
+	}
+
+	job := workJob{Kind: jobAdd, URI: body.Magnet, Source: sourceHomeAssistant, CorrelationID: w.Header().Get(correlationHeader)}
+	if err := s.submit(r.Context(), job); err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, haState{
+		State:      "processed",
+		Attributes: map[string]interface{}{"hash": ExtractMagnetHash(magnetURI)},
+	})
+}
+
+// handleHARetry processes the retry queue, for a rest_command service
+func (s *apiServer) handleHARetry(w http.ResponseWriter, r *http.Request) {
+	job := workJob{Kind: jobRetry, Source: sourceHomeAssistant, CorrelationID: w.Header().Get(correlationHeader)}
+	if err := s.submit(r.Context(), job); err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+
+	db, err := LoadJSONDatabase(s.config.JSONPath)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("failed to load database: %w", err))
+		return
+	}
+	writeJSON(w, http.StatusOK, haState{State: len(db.Retry), Attributes: map[string]interface{}{}})
+}
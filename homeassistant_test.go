@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// Test the Home Assistant endpoints are only served when enabled
+func TestHAEndpointsOptional(t *testing.T) {
+	server, _ := newTestAPIServer(t)
+	resp := apiRequest(t, http.MethodGet, server.URL+"/api/ha/retry_queue", nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 when disabled, got %d", resp.StatusCode)
+	}
+}
+
+// Test the queue and added sensors follow Home Assistant's REST sensor shape
+func TestHASensors(t *testing.T) {
+	server, _ := newConfiguredAPIServer(t, func(config *Config) { config.HomeAssistant = true })
+
+	tests := []struct {
+		path  string
+		state float64
+		attr  string
+		value interface{}
+	}{
+		{"/api/ha/retry_queue", 1, "oldest", "2024-01-01T00:00:00Z"},
+		{"/api/ha/added", 1, "latest", "Added Book"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			resp := apiRequest(t, http.MethodGet, server.URL+tt.path, nil)
+			defer resp.Body.Close()
+			var sensor struct {
+				State      float64                `json:"state"`
+				Attributes map[string]interface{} `json:"attributes"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&sensor); err != nil {
+				t.Fatalf("Failed to decode sensor: %v", err)
+			}
+			if sensor.State != tt.state {
+				t.Errorf("State = %v, want %v", sensor.State, tt.state)
+			}
+			if sensor.Attributes[tt.attr] != tt.value {
+				t.Errorf("Attribute %s = %v, want %v", tt.attr, sensor.Attributes[tt.attr], tt.value)
+			}
+			if sensor.Attributes["unit_of_measurement"] != "magnets" {
+				t.Errorf("Missing unit_of_measurement: %v", sensor.Attributes)
+			}
+		})
+	}
+}
+
+// Test the add service validates and adds magnets
+func TestHAAdd(t *testing.T) {
+	server, config := newConfiguredAPIServer(t, func(config *Config) {
+		config.HomeAssistant = true
+		simulateDeluge = true
+		config.MetadataTimeout = -1
+	})
+	defer func() { simulateDeluge = false }()
+
+	resp := apiRequest(t, http.MethodPost, server.URL+"/api/ha/add", strings.NewReader(`{"magnet": "not a magnet"}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Invalid magnet: expected 400, got %d", resp.StatusCode)
+	}
+
+	hash := "fedcba9876543210fedcba9876543210fedcba98"
+	resp = apiRequest(t, http.MethodPost, server.URL+"/api/ha/add", strings.NewReader(`{"magnet": "magnet:?xt=urn:btih:`+hash+`&dn=HA+Book"}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Add: expected 200, got %d", resp.StatusCode)
+	}
+
+	db, err := LoadJSONDatabase(config.JSONPath)
+	if err != nil {
+		t.Fatalf("Failed to load database: %v", err)
+	}
+	if _, ok := db.Added[hash]; !ok {
+		t.Error("Magnet from Home Assistant should be added")
+	}
+}
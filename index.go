@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"hash/fnv"
+	"log"
+	"math"
+	"os"
+)
+
+// indexFalsePositiveRate is the target false positive rate of the bloom filter
+const indexFalsePositiveRate = 0.01
+
+// HashIndex is a compact bloom filter of every hash in a database, stored
+// next to it so the add path can rule out duplicates without parsing the JSON
+type HashIndex struct {
+	Version int    `json:"version"`
+	DBSize  int64  `json:"db_size"`  // Size of the database file the index describes
+	DBMtime int64  `json:"db_mtime"` // Modification time (UnixNano) of that file
+	K       int    `json:"k"`        // Number of hash functions
+	M       int    `json:"m"`        // Number of bits
+	Bits    string `json:"bits"`     // Base64-encoded bit array
+
+	bits []byte
+}
+
+// IndexPath returns the index file location for a database path
+func IndexPath(dbPath string) string {
+	return dbPath + ".idx"
+}
+
+// newHashIndex sizes a bloom filter for n entries
+func newHashIndex(n int) *HashIndex {
+	n = max(n, 1)
+	m := int(math.Ceil(-float64(n) * math.Log(indexFalsePositiveRate) / (math.Ln2 * math.Ln2)))
+	k := max(int(math.Round(float64(m)/float64(n)*math.Ln2)), 1)
+	return &HashIndex{Version: 1, K: k, M: m, bits: make([]byte, (m+7)/8)}
+}
+
+// positions returns the bit positions for a hash using double hashing
+func (idx *HashIndex) positions(hash string) []int {
+	h := fnv.New64a()
+	h.Write([]byte(hash))
+	sum := h.Sum64()
+	h1, h2 := uint32(sum), uint32(sum>>32)|1
+
+	positions := make([]int, idx.K)
+	for i := range positions {
+		positions[i] = int((uint64(h1) + uint64(i)*uint64(h2)) % uint64(idx.M))
+	}
+	return positions
+}
+
+// Add records a hash in the index
+func (idx *HashIndex) Add(hash string) {
+	for _, pos := range idx.positions(hash) {
+		idx.bits[pos/8] |= 1 << (pos % 8)
+	}
+}
+
+// MayContain reports whether the hash might be in the database. A false
+// result is definite; a true result must be confirmed against the database.
+func (idx *HashIndex) MayContain(hash string) bool {
+	for _, pos := range idx.positions(hash) {
+		if idx.bits[pos/8]&(1<<(pos%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// WriteIndex builds and saves the index for a database that was just written to dbPath
+func WriteIndex(dbPath string, db *MagnetDatabase) error {
+	info, err := os.Stat(dbPath)
+	if err != nil {
+		return err
+	}
+
+	idx := newHashIndex(len(db.Added) + len(db.Retry))
+	for hash := range db.Added {
+		idx.Add(hash)
+	}
+	for hash := range db.Retry {
+		idx.Add(hash)
+	}
+	idx.DBSize = info.Size()
+	idx.DBMtime = info.ModTime().UnixNano()
+	idx.Bits = base64.StdEncoding.EncodeToString(idx.bits)
+
+	data, err := json.Marshal(idx)
+	if err != nil {
+		return err
+	}
+
+	tempPath := IndexPath(dbPath) + ".tmp"
+	if err := os.WriteFile(tempPath, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tempPath, IndexPath(dbPath)); err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+	return nil
+}
+
+// LoadIndex loads the index for dbPath, returning nil if it is missing,
+// unreadable, or stale (the database changed after the index was written)
+func LoadIndex(dbPath string) *HashIndex {
+	info, err := os.Stat(dbPath)
+	if err != nil {
+		return nil
+	}
+	data, err := os.ReadFile(IndexPath(dbPath))
+	if err != nil {
+		return nil
+	}
+
+	var idx HashIndex
+	if err := json.Unmarshal(data, &idx); err != nil || idx.Version != 1 || idx.M <= 0 || idx.K <= 0 {
+		return nil
+	}
+	if idx.DBSize != info.Size() || idx.DBMtime != info.ModTime().UnixNano() {
+		return nil
+	}
+	idx.bits, err = base64.StdEncoding.DecodeString(idx.Bits)
+	if err != nil || len(idx.bits) != (idx.M+7)/8 {
+		return nil
+	}
+	return &idx
+}
+
+// IndexRulesOut reports whether the index proves the hash is not in the
+// database at dbPath, so the full load can be skipped. Entries still in the
+// journal are not in the index yet, so a non-empty journal rules nothing out.
+func IndexRulesOut(dbPath, hash string) bool {
+	if info, err := os.Stat(journalPath(dbPath)); err == nil && info.Size() > 0 {
+		return false
+	}
+	idx := LoadIndex(dbPath)
+	if idx == nil {
+		return false
+	}
+	return !idx.MayContain(hash)
+}
+
+// updateIndex rewrites the index after a save, logging rather than failing
+func updateIndex(dbPath string, db *MagnetDatabase) {
+	if err := WriteIndex(dbPath, db); err != nil {
+		log.Printf("Warning: Could not update index for %s: %v", dbPath, err)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Test the bloom filter never reports false negatives
+func TestHashIndexMayContain(t *testing.T) {
+	idx := newHashIndex(1000)
+	for i := 0; i < 1000; i++ {
+		idx.Add(fmt.Sprintf("%040x", i))
+	}
+	for i := 0; i < 1000; i++ {
+		if !idx.MayContain(fmt.Sprintf("%040x", i)) {
+			t.Fatalf("Index lost hash %d", i)
+		}
+	}
+
+	falsePositives := 0
+	for i := 1000; i < 11000; i++ {
+		if idx.MayContain(fmt.Sprintf("%040x", i)) {
+			falsePositives++
+		}
+	}
+	if rate := float64(falsePositives) / 10000; rate > 0.03 {
+		t.Errorf("False positive rate too high: %.3f", rate)
+	}
+}
+
+// Test the index is written on save and invalidated when the database changes
+func TestIndexRulesOut(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	dbPath := filepath.Join(tmpDir, "db.json")
+	known := "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	unknown := "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+
+	// No index yet: nothing can be ruled out
+	if IndexRulesOut(dbPath, unknown) {
+		t.Error("Missing index should not rule anything out")
+	}
+
+	db := &MagnetDatabase{
+		Added: map[string]MagnetEntry{known: {ID: 1, Hash: known}},
+		Retry: map[string]MagnetEntry{},
+	}
+	if err := SaveDatabaseLocal(dbPath, db); err != nil {
+		t.Fatalf("SaveDatabaseLocal failed: %v", err)
+	}
+
+	if IndexRulesOut(dbPath, known) {
+		t.Error("Index must not rule out a known hash")
+	}
+	if !IndexRulesOut(dbPath, unknown) {
+		t.Error("Index should rule out an unknown hash")
+	}
+
+	// Entries waiting in the journal are not in the index yet
+	if err := appendJournal(dbPath, []journalOp{{Section: SectionAdded, Hash: unknown, Entry: MagnetEntry{Hash: unknown}}}); err != nil {
+		t.Fatalf("appendJournal failed: %v", err)
+	}
+	if IndexRulesOut(dbPath, unknown) {
+		t.Error("Index must not rule out a hash while the journal is pending")
+	}
+	if err := os.Remove(journalPath(dbPath)); err != nil {
+		t.Fatalf("Remove journal failed: %v", err)
+	}
+
+	// Database modified behind the index's back: index is stale
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(dbPath, future, future); err != nil {
+		t.Fatalf("Chtimes failed: %v", err)
+	}
+	if IndexRulesOut(dbPath, unknown) {
+		t.Error("Stale index should not rule anything out")
+	}
+}
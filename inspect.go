@@ -0,0 +1,237 @@
+package main
+
+import (
+	"encoding/base32"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MagnetInspection is every part of a magnet URI, parsed without adding it
+type MagnetInspection struct {
+	Raw        string              `json:"raw"`
+	Normalized string              `json:"normalized"`
+	HashHex    string              `json:"hash_hex,omitempty"`
+	HashBase32 string              `json:"hash_base32,omitempty"`
+	HashNote   string              `json:"hash_note,omitempty"` // Why the hash could not be converted
+	Name       string              `json:"name,omitempty"`
+	Length     string              `json:"length,omitempty"` // xl, as given
+	Trackers   []string            `json:"trackers,omitempty"`
+	WebSeeds   []string            `json:"web_seeds,omitempty"`
+	SelectOnly []int               `json:"select_only,omitempty"` // File indices from so=
+	SelectNote string              `json:"select_note,omitempty"` // Parts of so= that are not indices or ranges
+	Other      map[string][]string `json:"other,omitempty"`       // Parameters not listed above
+	ParseNote  string              `json:"parse_note,omitempty"`  // Why some parameters could not be decoded
+	Accepted   bool                `json:"accepted"`
+	Reason     string              `json:"reason,omitempty"` // Why the handler would reject it
+}
+
+// magnetHashes returns a btih info hash in hex and base32, whichever form
+// it was given in, and why it could not be converted if it could not
+func magnetHashes(hash string) (string, string, string) {
+	switch len(hash) {
+	case 40:
+		raw, err := hex.DecodeString(hash)
+		if err != nil {
+			return "", "", "not valid hex"
+		}
+		return strings.ToLower(hash), base32.StdEncoding.EncodeToString(raw), ""
+	case 32:
+		raw, err := base32.StdEncoding.DecodeString(strings.ToUpper(hash))
+		if err != nil {
+			return "", strings.ToUpper(hash), "not valid base32 (Deluge will reject it)"
+		}
+		return hex.EncodeToString(raw), strings.ToUpper(hash), ""
+	}
+	return "", "", fmt.Sprintf("%d characters, expected 40 hex or 32 base32", len(hash))
+}
+
+// parseSelectOnly expands a so= value such as "0,2,4-6" into file indices,
+// returning the parts it could not read
+func parseSelectOnly(value string) ([]int, []string) {
+	var indices []int
+	var bad []string
+	for _, part := range strings.Split(value, ",") {
+		first, last, isRange := strings.Cut(strings.TrimSpace(part), "-")
+		from, err := strconv.Atoi(first)
+		to := from
+		if err == nil && isRange {
+			to, err = strconv.Atoi(last)
+		}
+		if err != nil || from < 0 || to < from || to-from > 10000 {
+			bad = append(bad, part)
+			continue
+		}
+		for i := from; i <= to; i++ {
+			indices = append(indices, i)
+		}
+	}
+	return indices, bad
+}
+
+// inspectMagnet parses a magnet URI the way the handler would, touching
+// neither Deluge nor the database
+func inspectMagnet(rawURI string) MagnetInspection {
+	magnetURI := NormalizeMagnetURI(rawURI)
+	in := MagnetInspection{Raw: rawURI, Normalized: magnetURI}
+
+	in.Reason = magnetURIProblem(magnetURI)
+	hash := ExtractMagnetHash(magnetURI)
+	switch {
+	case in.Reason != "":
+	case hash == "":
+		in.Reason = "could not extract hash from magnet URI (expected 40 hex or 32 base32 characters after xt=urn:btih:)"
+	case isBlocked(hash):
+		in.Reason = fmt.Sprintf("%s is on the blocklist", hash)
+	}
+	in.Accepted = in.Reason == ""
+
+	query, _ := strings.CutPrefix(magnetURI, "magnet:?")
+	params, err := url.ParseQuery(query)
+	if err != nil {
+		in.ParseNote = err.Error()
+	}
+	for _, xt := range params["xt"] {
+		if value, ok := strings.CutPrefix(xt, "urn:btih:"); ok && in.HashHex == "" && in.HashBase32 == "" {
+			in.HashHex, in.HashBase32, in.HashNote = magnetHashes(value)
+		}
+	}
+	if _, ok := params["dn"]; ok {
+		in.Name = ExtractMagnetName(magnetURI)
+	}
+	in.Length = params.Get("xl")
+	in.Trackers = params["tr"]
+	in.WebSeeds = params["ws"]
+	if so := params.Get("so"); so != "" {
+		var bad []string
+		in.SelectOnly, bad = parseSelectOnly(so)
+		if len(bad) > 0 {
+			in.SelectNote = "ignored " + strings.Join(bad, ", ")
+		}
+	}
+
+	for key, values := range params {
+		switch key {
+		case "dn", "xl", "tr", "ws", "so":
+			continue
+		case "xt":
+			var rest []string
+			for _, xt := range values {
+				if !strings.HasPrefix(xt, "urn:btih:") {
+					rest = append(rest, xt)
+				}
+			}
+			if values = rest; len(values) == 0 {
+				continue
+			}
+		}
+		if in.Other == nil {
+			in.Other = make(map[string][]string)
+		}
+		in.Other[key] = values
+	}
+	return in
+}
+
+// writeMagnetInspection prints an inspection, one component per line
+func writeMagnetInspection(w io.Writer, in MagnetInspection) {
+	field := func(name, value string) {
+		if value != "" {
+			fmt.Fprintf(w, "%-12s %s\n", name+":", value)
+		}
+	}
+	if in.Accepted {
+		field("Verdict", "valid")
+	} else {
+		field("Verdict", "rejected: "+in.Reason)
+	}
+	if in.Normalized != in.Raw {
+		field("Normalized", in.Normalized)
+	}
+	field("Hash (hex)", in.HashHex)
+	field("Hash (b32)", in.HashBase32)
+	field("Hash note", in.HashNote)
+	field("Name", in.Name)
+	field("Length", in.Length)
+
+	list := func(name string, values []string) {
+		if len(values) == 0 {
+			return
+		}
+		fmt.Fprintf(w, "%s (%d):\n", name, len(values))
+		for _, value := range values {
+			fmt.Fprintf(w, "  %s\n", value)
+		}
+	}
+	list("Trackers", in.Trackers)
+	list("Web seeds", in.WebSeeds)
+	if len(in.SelectOnly) > 0 || in.SelectNote != "" {
+		indices := make([]string, len(in.SelectOnly))
+		for i, index := range in.SelectOnly {
+			indices[i] = strconv.Itoa(index)
+		}
+		field("Select only", strings.Join(indices, ", "))
+		field("Select note", in.SelectNote)
+	}
+
+	keys := make([]string, 0, len(in.Other))
+	for key := range in.Other {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		list("Other "+key, in.Other[key])
+	}
+	field("Parse note", in.ParseNote)
+}
+
+// runInspect implements the inspect command
+func runInspect(config Config, args []string) error {
+	fs := newCommandFlags(inspectCommand)
+	asJSON := fs.Bool("json", false, "Print the components as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return fmt.Errorf("expected one magnet URI")
+	}
+	rawURI, err := ResolveMagnetArgument(fs.Args())
+	if err != nil {
+		return err
+	}
+
+	in := inspectMagnet(rawURI)
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(in); err != nil {
+			return err
+		}
+	} else {
+		writeMagnetInspection(os.Stdout, in)
+	}
+	if !in.Accepted {
+		return fmt.Errorf("magnet would be rejected: %s", in.Reason)
+	}
+	return nil
+}
+
+var inspectCommand = &Command{
+	Name:    "inspect",
+	Usage:   "inspect [--json] <magnet-uri | @file>",
+	Summary: "Show every part of a magnet link and whether it would be accepted, without adding it",
+
+	NoConfig: true,
+}
+
+func init() {
+	inspectCommand.Run = runInspect
+	registerCommand(inspectCommand)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Test inspectMagnet splits a magnet into its parts and converts the hash
+func TestInspectMagnet(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	t.Setenv("HOME", tmpDir)
+
+	in := inspectMagnet("magnet:?xt=urn:btih:C12FE1C06BBA254A9DC9F519B335AA7C1367A88A&dn=My+Book%21" +
+		"&tr=udp%3A%2F%2Fone.example%3A80&tr=https%3A%2F%2Ftwo.example%2Fannounce&ws=http%3A%2F%2Fseed.example%2Fbook" +
+		"&so=0,2,4-6,x&xl=1024&x.pe=10.0.0.1%3A6881")
+	if !in.Accepted || in.Reason != "" {
+		t.Errorf("Expected the magnet accepted, got %q", in.Reason)
+	}
+	if in.HashHex != "c12fe1c06bba254a9dc9f519b335aa7c1367a88a" || in.HashBase32 != "YEX6DQDLXISUVHOJ6UM3GNNKPQJWPKEK" {
+		t.Errorf("Unexpected hashes %s / %s", in.HashHex, in.HashBase32)
+	}
+	if in.Name != "My Book!" || in.Length != "1024" {
+		t.Errorf("Unexpected name %q or length %q", in.Name, in.Length)
+	}
+	if len(in.Trackers) != 2 || in.Trackers[1] != "https://two.example/announce" {
+		t.Errorf("Unexpected trackers %v", in.Trackers)
+	}
+	if len(in.WebSeeds) != 1 || in.WebSeeds[0] != "http://seed.example/book" {
+		t.Errorf("Unexpected web seeds %v", in.WebSeeds)
+	}
+	if !reflect.DeepEqual(in.SelectOnly, []int{0, 2, 4, 5, 6}) || in.SelectNote != "ignored x" {
+		t.Errorf("Unexpected select-only %v (%s)", in.SelectOnly, in.SelectNote)
+	}
+	if got := in.Other["x.pe"]; len(got) != 1 || got[0] != "10.0.0.1:6881" {
+		t.Errorf("Expected x.pe listed with the other parameters, got %v", in.Other)
+	}
+
+	base32 := inspectMagnet("magnet:?xt=urn:btih:yex6dqdlxisuvhoj6um3gnnkpqjwpkek")
+	if base32.HashHex != "c12fe1c06bba254a9dc9f519b335aa7c1367a88a" {
+		t.Errorf("Expected a base32 hash converted to hex, got %q", base32.HashHex)
+	}
+	if bad := inspectMagnet("magnet:?xt=urn:btih:0000000000000000000000000000000a"); bad.HashNote == "" || !bad.Accepted {
+		t.Errorf("Expected invalid base32 noted but accepted like the handler does, got %+v", bad)
+	}
+}
+
+// Test inspectMagnet gives the exact reason a magnet is rejected
+func TestInspectMagnetRejected(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	t.Setenv("HOME", tmpDir)
+
+	const blocked = "c12fe1c06bba254a9dc9f519b335aa7c1367a88a"
+	if err := updateBlocklist(func(hashes map[string]bool) bool { hashes[blocked] = true; return true }); err != nil {
+		t.Fatalf("Failed to block hash: %v", err)
+	}
+
+	tests := []struct {
+		uri    string
+		reason string
+	}{
+		{"http://example.com/file.torrent", `does not start with "magnet:?"`},
+		{"magnet:?", "has no parameters"},
+		{"magnet:?dn=Book", "has no xt=urn:btih: parameter"},
+		{"magnet:?xt=urn:btih:1234", "could not extract hash"},
+		{"magnet:?xt=urn:btih:" + blocked, "on the blocklist"},
+	}
+	for _, tt := range tests {
+		in := inspectMagnet(tt.uri)
+		if in.Accepted || !strings.Contains(in.Reason, tt.reason) {
+			t.Errorf("inspectMagnet(%q): expected rejection %q, got %q", tt.uri, tt.reason, in.Reason)
+		}
+	}
+	if problem := magnetURIProblem("magnet:?xt=urn:btih:abc<def"); !strings.Contains(problem, "'<' at position 23") {
+		t.Errorf("Expected the offending character named, got %q", problem)
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// forwardTimeout bounds how long a forwarding instance waits for the
+// running instance to process its magnet
+var forwardTimeout = 5 * time.Minute
+
+// instanceRequest is a magnet forwarded by another instance, answered on done
+type instanceRequest struct {
+	URI           string
+	Label         string // Label chosen in the forwarding instance, if any
+	CorrelationID string // ID of the forwarding instance
+	done          chan error
+}
+
+// InstanceServer accepts magnets from later instances so that only one
+// process reads and writes the database at a time
+type InstanceServer struct {
+	listener net.Listener
+	path     string
+	requests chan instanceRequest
+}
+
+// instanceSocketPath returns the address the running instance listens on.
+// On Windows the named pipe's name is derived from it.
+func instanceSocketPath() (string, error) {
+	homeDir, err := getHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, ".magnet-handler", "instance.sock"), nil
+}
+
+// ForwardToInstance hands a magnet to an already-running instance and waits
+// for it to be processed, under label unless that is empty. It fails if no
+// instance is running or the running instance exits before answering, in
+// which case the caller should process the magnet itself.
+func ForwardToInstance(uri, label string) error {
+	path, err := instanceSocketPath()
+	if err != nil {
+		return err
+	}
+	conn, err := dialInstance(path)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(forwardTimeout))
+	line := CorrelationID() + "\t" + uri
+	if label != "" {
+		line += "\t" + label
+	}
+	if _, err := fmt.Fprintln(conn, line); err != nil {
+		return err
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("running instance did not answer: %w", err)
+	}
+	reply = strings.TrimSpace(reply)
+	if reply != "ok" {
+		return &forwardedError{msg: strings.TrimPrefix(reply, "error ")}
+	}
+	return nil
+}
+
+// forwardedError is a failure reported by the running instance after it
+// processed the magnet, so the caller must not process it again
+type forwardedError struct{ msg string }
+
+func (e *forwardedError) Error() string { return e.msg }
+
+// isForwardedError reports whether the running instance handled the magnet
+// and reported a failure, as opposed to the hand-off itself failing
+func isForwardedError(err error) bool {
+	var fe *forwardedError
+	return errors.As(err, &fe)
+}
+
+// StartInstanceServer makes this process the running instance, replacing a
+// stale socket left behind by a crashed instance
+func StartInstanceServer() (*InstanceServer, error) {
+	path, err := instanceSocketPath()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	listener, err := listenInstance(path)
+	if err != nil {
+		// Another instance may have just started; otherwise the socket is stale
+		if conn, dialErr := dialInstance(path); dialErr == nil {
+			conn.Close()
+			return nil, fmt.Errorf("another instance is already running")
+		}
+		os.Remove(path)
+		if listener, err = listenInstance(path); err != nil {
+			return nil, err
+		}
+	}
+
+	s := &InstanceServer{
+		listener: listener,
+		path:     path,
+		requests: make(chan instanceRequest),
+	}
+	go s.acceptLoop()
+	return s, nil
+}
+
+// acceptLoop reads one magnet per connection and waits for it to be processed
+func (s *InstanceServer) acceptLoop() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *InstanceServer) handle(conn net.Conn) {
+	defer conn.Close()
+
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return
+	}
+	req := instanceRequest{done: make(chan error, 1)}
+	// Lines are "ID<tab>URI[<tab>label]"; the ID may be empty, and older
+	// instances send a bare URI
+	id, rest, ok := strings.Cut(strings.TrimRight(line, "\r\n"), "\t")
+	if !ok {
+		rest = id
+	} else if validCorrelationID.MatchString(id) {
+		req.CorrelationID = id
+	}
+	req.URI, req.Label, _ = strings.Cut(strings.TrimSpace(rest), "\t")
+	s.requests <- req
+
+	if err := <-req.done; err != nil {
+		fmt.Fprintf(conn, "error %s\n", strings.ReplaceAll(err.Error(), "\n", " "))
+		return
+	}
+	fmt.Fprintln(conn, "ok")
+}
+
+// Serve processes forwarded magnets, with the label chosen for each (or ""),
+// one at a time until no new magnet has arrived for idle, then stops
+// accepting
+func (s *InstanceServer) Serve(idle time.Duration, process func(uri, label string) error) {
+	timer := time.NewTimer(idle)
+	defer timer.Stop()
+
+	for {
+		select {
+		case req := <-s.requests:
+			// Forwarded magnets run one at a time, so the process-wide
+			// prefix can carry each one's ID
+			restore := func() {}
+			if req.CorrelationID != "" {
+				restore = setCorrelationID(req.CorrelationID)
+			}
+			log.Printf("\n=== Magnet forwarded from another instance ===")
+			req.done <- process(req.URI, req.Label)
+			restore()
+			timer.Reset(idle)
+		case <-timer.C:
+			s.Close()
+			return
+		}
+	}
+}
+
+// Close stops accepting magnets; instances still waiting fall back to
+// processing their magnet themselves
+func (s *InstanceServer) Close() {
+	s.listener.Close()
+	os.Remove(s.path)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Test magnets forwarded to the running instance are processed one at a time
+func TestInstanceForwarding(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "mh")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	t.Setenv("HOME", tmpDir)
+
+	if err := ForwardToInstance("magnet:?xt=urn:btih:none", ""); err == nil || isForwardedError(err) {
+		t.Fatalf("Forwarding with no running instance should fail to connect, got %v", err)
+	}
+
+	server, err := StartInstanceServer()
+	if err != nil {
+		t.Fatalf("StartInstanceServer failed: %v", err)
+	}
+	if _, err := StartInstanceServer(); err == nil {
+		t.Error("Second server should not start while the first is running")
+	}
+
+	var mu sync.Mutex
+	active, maxActive := 0, 0
+	var processed []string
+	done := make(chan struct{})
+	go func() {
+		server.Serve(200*time.Millisecond, func(uri, label string) error {
+			mu.Lock()
+			active++
+			if active > maxActive {
+				maxActive = active
+			}
+			mu.Unlock()
+			time.Sleep(10 * time.Millisecond)
+			mu.Lock()
+			active--
+			if label != "" {
+				uri += "@" + label
+			}
+			processed = append(processed, uri)
+			mu.Unlock()
+			if uri == "bad" {
+				return os.ErrInvalid
+			}
+			return nil
+		})
+		close(done)
+	}()
+
+	var wg sync.WaitGroup
+	for _, uri := range []string{"one", "two", "three"} {
+		wg.Add(1)
+		go func(uri string) {
+			defer wg.Done()
+			if err := ForwardToInstance(uri, ""); err != nil {
+				t.Errorf("ForwardToInstance(%s) failed: %v", uri, err)
+			}
+		}(uri)
+	}
+	wg.Wait()
+
+	if err := ForwardToInstance("bad", ""); !isForwardedError(err) {
+		t.Errorf("Processing failure should be reported back, got %v", err)
+	}
+	if err := ForwardToInstance("four", "books"); err != nil {
+		t.Errorf("ForwardToInstance with a label failed: %v", err)
+	}
+
+	<-done
+	if len(processed) != 5 || processed[4] != "four@books" {
+		t.Errorf("Expected 5 processed magnets, the last with its label, got %v", processed)
+	}
+	if maxActive != 1 {
+		t.Errorf("Magnets should be processed one at a time, saw %d concurrently", maxActive)
+	}
+
+	// The socket is released once the server goes idle
+	if _, err := os.Stat(server.path); !os.IsNotExist(err) {
+		t.Error("Socket should be removed after the server stops")
+	}
+}
+
+// Test a stale socket from a crashed instance is replaced
+func TestInstanceStaleSocket(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "mh")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	t.Setenv("HOME", tmpDir)
+
+	path, _ := instanceSocketPath()
+	os.MkdirAll(filepath.Dir(path), 0755)
+	if err := os.WriteFile(path, nil, 0600); err != nil {
+		t.Fatalf("Failed to create stale socket file: %v", err)
+	}
+
+	server, err := StartInstanceServer()
+	if err != nil {
+		t.Fatalf("StartInstanceServer should replace a stale socket: %v", err)
+	}
+	server.Close()
+}
@@ -0,0 +1,18 @@
+//go:build !windows
+
+package main
+
+import (
+	"net"
+	"time"
+)
+
+// listenInstance listens on a unix socket for forwarded magnets
+func listenInstance(path string) (net.Listener, error) {
+	return net.Listen("unix", path)
+}
+
+// dialInstance connects to the running instance's unix socket
+func dialInstance(path string) (net.Conn, error) {
+	return net.DialTimeout("unix", path, 2*time.Second)
+}
@@ -0,0 +1,316 @@
+//go:build windows
+
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// pipeBufferSize is the buffer size requested for each pipe instance
+const pipeBufferSize = 4096
+
+// instancePipeName returns the named pipe the running instance listens on.
+// It is derived from the socket path so each user, and each HOME in tests,
+// gets its own pipe.
+func instancePipeName(path string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(path)))
+	return fmt.Sprintf(`\\.\pipe\magnet-handler-%x`, sum[:8])
+}
+
+// listenInstance listens on a named pipe for forwarded magnets. Only the
+// current user can connect, and only from this machine.
+func listenInstance(path string) (net.Listener, error) {
+	sa, err := pipeSecurity()
+	if err != nil {
+		return nil, err
+	}
+	l := &pipeListener{name: instancePipeName(path), sa: sa}
+	// The first instance of the pipe fails if another process already owns
+	// the name, which is what tells a second handler to forward instead
+	if l.next, err = l.create(true); err != nil {
+		return nil, &net.OpError{Op: "listen", Net: "pipe", Addr: pipeAddr(l.name), Err: err}
+	}
+	if l.closing, err = windows.CreateEvent(nil, 1, 0, nil); err != nil {
+		windows.CloseHandle(l.next)
+		return nil, err
+	}
+	return l, nil
+}
+
+// dialInstance connects to the running instance's named pipe
+func dialInstance(path string) (net.Conn, error) {
+	return dialPipe(instancePipeName(path), 2*time.Second)
+}
+
+// pipeSecurity grants the current user, and nobody else, access to the pipe
+func pipeSecurity() (*windows.SecurityAttributes, error) {
+	user, err := windows.GetCurrentProcessToken().GetTokenUser()
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up the current user: %w", err)
+	}
+	sd, err := windows.SecurityDescriptorFromString("D:P(A;;GA;;;" + user.User.Sid.String() + ")")
+	if err != nil {
+		return nil, fmt.Errorf("failed to build the pipe's security descriptor: %w", err)
+	}
+	return &windows.SecurityAttributes{
+		Length:             uint32(unsafe.Sizeof(windows.SecurityAttributes{})),
+		SecurityDescriptor: sd,
+	}, nil
+}
+
+// dialPipe opens the client end of a named pipe, waiting up to timeout
+// while every instance of it is busy
+func dialPipe(name string, timeout time.Duration) (net.Conn, error) {
+	p, err := windows.UTF16PtrFromString(name)
+	if err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		// SECURITY_IDENTIFICATION keeps whoever owns the pipe from acting
+		// as this user
+		h, err := windows.CreateFile(p, windows.GENERIC_READ|windows.GENERIC_WRITE, 0, nil, windows.OPEN_EXISTING,
+			windows.FILE_FLAG_OVERLAPPED|windows.SECURITY_SQOS_PRESENT|windows.SECURITY_IDENTIFICATION, 0)
+		if err == nil {
+			return &pipeConn{handle: h, name: name}, nil
+		}
+		if err != windows.ERROR_PIPE_BUSY || time.Now().After(deadline) {
+			return nil, &net.OpError{Op: "dial", Net: "pipe", Addr: pipeAddr(name), Err: err}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// pipeAddr is a named pipe's address
+type pipeAddr string
+
+func (a pipeAddr) Network() string { return "pipe" }
+func (a pipeAddr) String() string  { return string(a) }
+
+// pipeListener accepts connections on a named pipe. One unconnected
+// instance of the pipe always waits for the next client.
+type pipeListener struct {
+	name string
+	sa   *windows.SecurityAttributes
+
+	closing windows.Handle // Set by Close to wake a blocked Accept
+
+	mu        sync.Mutex
+	next      windows.Handle // Instance waiting for the next client
+	accepting bool
+	closed    bool
+}
+
+// create makes a new server instance of the pipe
+func (l *pipeListener) create(first bool) (windows.Handle, error) {
+	p, err := windows.UTF16PtrFromString(l.name)
+	if err != nil {
+		return windows.InvalidHandle, err
+	}
+	flags := uint32(windows.PIPE_ACCESS_DUPLEX | windows.FILE_FLAG_OVERLAPPED)
+	if first {
+		flags |= windows.FILE_FLAG_FIRST_PIPE_INSTANCE
+	}
+	mode := uint32(windows.PIPE_TYPE_BYTE | windows.PIPE_READMODE_BYTE | windows.PIPE_WAIT | windows.PIPE_REJECT_REMOTE_CLIENTS)
+	return windows.CreateNamedPipe(p, flags, mode, windows.PIPE_UNLIMITED_INSTANCES, pipeBufferSize, pipeBufferSize, 0, l.sa)
+}
+
+// Accept waits for a client on the waiting instance, then makes a new one
+// for the client after it
+func (l *pipeListener) Accept() (net.Conn, error) {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return nil, net.ErrClosed
+	}
+	h := l.next
+	l.accepting = true
+	l.mu.Unlock()
+
+	_, err := pipeIO(h, time.Time{}, l.closing, func(o *windows.Overlapped) error {
+		return windows.ConnectNamedPipe(h, o)
+	})
+	// A client that connected before ConnectNamedPipe was called is
+	// reported as an error but is connected all the same
+	if err == windows.ERROR_PIPE_CONNECTED {
+		err = nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.accepting = false
+	if l.closed {
+		windows.CloseHandle(h)
+		windows.CloseHandle(l.closing)
+		return nil, net.ErrClosed
+	}
+	if err != nil {
+		return nil, &net.OpError{Op: "accept", Net: "pipe", Addr: pipeAddr(l.name), Err: err}
+	}
+	next, err := l.create(false)
+	if err != nil {
+		windows.CloseHandle(h)
+		windows.CloseHandle(l.closing)
+		l.closed = true
+		return nil, &net.OpError{Op: "accept", Net: "pipe", Addr: pipeAddr(l.name), Err: err}
+	}
+	l.next = next
+	return &pipeConn{handle: h, name: l.name, server: true}, nil
+}
+
+// Close stops accepting. A blocked Accept is woken and closes the waiting
+// instance itself; otherwise Close does.
+func (l *pipeListener) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return nil
+	}
+	l.closed = true
+	if l.accepting {
+		return windows.SetEvent(l.closing)
+	}
+	windows.CloseHandle(l.next)
+	return windows.CloseHandle(l.closing)
+}
+
+func (l *pipeListener) Addr() net.Addr { return pipeAddr(l.name) }
+
+// pipeConn is one end of a connected named pipe. Deadlines apply to reads
+// and writes alike.
+type pipeConn struct {
+	handle windows.Handle
+	name   string
+	server bool
+
+	mu       sync.Mutex
+	deadline time.Time
+	closed   bool
+}
+
+// pipeIO starts an overlapped operation on h and waits for it to finish,
+// cancelling it if deadline (zero = none) passes or the event stop (if
+// any) is set first
+func pipeIO(h windows.Handle, deadline time.Time, stop windows.Handle, start func(*windows.Overlapped) error) (uint32, error) {
+	event, err := windows.CreateEvent(nil, 1, 0, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer windows.CloseHandle(event)
+
+	o := &windows.Overlapped{HEvent: event}
+	if err := start(o); err != nil && err != windows.ERROR_IO_PENDING {
+		return 0, err
+	}
+
+	wait := uint32(windows.INFINITE)
+	if !deadline.IsZero() {
+		wait = uint32(max(time.Until(deadline), 0).Milliseconds())
+	}
+	events := []windows.Handle{event}
+	if stop != 0 {
+		events = append(events, stop)
+	}
+	result, _ := windows.WaitForMultipleObjects(events, false, wait)
+	if result != windows.WAIT_OBJECT_0 {
+		windows.CancelIoEx(h, o)
+	}
+
+	var n uint32
+	err = windows.GetOverlappedResult(h, o, &n, true)
+	if err == windows.ERROR_OPERATION_ABORTED && result == uint32(windows.WAIT_TIMEOUT) {
+		err = os.ErrDeadlineExceeded
+	}
+	return n, err
+}
+
+func (c *pipeConn) currentDeadline() (time.Time, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return time.Time{}, net.ErrClosed
+	}
+	if !c.deadline.IsZero() && time.Now().After(c.deadline) {
+		return time.Time{}, os.ErrDeadlineExceeded
+	}
+	return c.deadline, nil
+}
+
+func (c *pipeConn) Read(b []byte) (int, error) {
+	deadline, err := c.currentDeadline()
+	if err != nil {
+		return 0, err
+	}
+	if len(b) == 0 {
+		return 0, nil
+	}
+	n, err := pipeIO(c.handle, deadline, 0, func(o *windows.Overlapped) error {
+		var done uint32
+		return windows.ReadFile(c.handle, b, &done, o)
+	})
+	switch err {
+	case nil:
+		if n == 0 {
+			return 0, io.EOF
+		}
+	case windows.ERROR_BROKEN_PIPE, windows.ERROR_PIPE_NOT_CONNECTED:
+		return int(n), io.EOF
+	case windows.ERROR_MORE_DATA:
+		err = nil
+	}
+	return int(n), err
+}
+
+func (c *pipeConn) Write(b []byte) (int, error) {
+	deadline, err := c.currentDeadline()
+	if err != nil {
+		return 0, err
+	}
+	if len(b) == 0 {
+		return 0, nil
+	}
+	n, err := pipeIO(c.handle, deadline, 0, func(o *windows.Overlapped) error {
+		var done uint32
+		return windows.WriteFile(c.handle, b, &done, o)
+	})
+	return int(n), err
+}
+
+// Close closes the pipe. The server end waits for the client to read what
+// was written first, since closing it would otherwise discard the reply.
+func (c *pipeConn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	if c.server {
+		windows.FlushFileBuffers(c.handle)
+		windows.DisconnectNamedPipe(c.handle)
+	}
+	return windows.CloseHandle(c.handle)
+}
+
+func (c *pipeConn) LocalAddr() net.Addr  { return pipeAddr(c.name) }
+func (c *pipeConn) RemoteAddr() net.Addr { return pipeAddr(c.name) }
+
+func (c *pipeConn) SetDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deadline = t
+	return nil
+}
+
+func (c *pipeConn) SetReadDeadline(t time.Time) error  { return c.SetDeadline(t) }
+func (c *pipeConn) SetWriteDeadline(t time.Time) error { return c.SetDeadline(t) }
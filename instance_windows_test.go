@@ -0,0 +1,76 @@
+//go:build windows
+
+package main
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Test the named pipe honours read deadlines and Close wakes a blocked Accept
+func TestInstancePipe(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "mh")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	path := filepath.Join(tmpDir, "instance.sock")
+
+	listener, err := listenInstance(path)
+	if err != nil {
+		t.Fatalf("listenInstance failed: %v", err)
+	}
+	if _, err := listenInstance(path); err == nil {
+		t.Error("A second listener on the same pipe should fail")
+	}
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			t.Errorf("Accept failed: %v", err)
+		}
+		accepted <- conn
+	}()
+
+	client, err := dialInstance(path)
+	if err != nil {
+		t.Fatalf("dialInstance failed: %v", err)
+	}
+	defer client.Close()
+	server := <-accepted
+	defer server.Close()
+
+	client.SetDeadline(time.Now().Add(100 * time.Millisecond))
+	if _, err := client.Read(make([]byte, 16)); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("Expected the read to time out, got %v", err)
+	}
+	client.SetDeadline(time.Time{})
+	if _, err := server.Write([]byte("ok\n")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	buf := make([]byte, 16)
+	if n, err := client.Read(buf); err != nil || string(buf[:n]) != "ok\n" {
+		t.Errorf("Expected the reply after the timeout, got %q, %v", buf[:n], err)
+	}
+
+	closed := make(chan error, 1)
+	go func() {
+		_, err := listener.Accept()
+		closed <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	listener.Close()
+	select {
+	case err := <-closed:
+		if !errors.Is(err, net.ErrClosed) {
+			t.Errorf("Expected Accept to report the listener closed, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close did not wake Accept")
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+)
+
+const (
+	ChecksumSHA1   = "sha1"
+	ChecksumSHA256 = "sha256"
+)
+
+// IntegritySettings controls how database checksums and signatures are computed
+type IntegritySettings struct {
+	Algorithm  string // Checksum algorithm for new writes
+	SigningKey string // Shared HMAC key, empty disables signing
+}
+
+// integrity holds the active settings, applied from config at startup
+var integrity = IntegritySettings{Algorithm: ChecksumSHA1}
+
+// ConfigureIntegrity applies checksum and signing settings from the config
+func ConfigureIntegrity(config Config) error {
+	algorithm := config.ChecksumAlgorithm
+	if algorithm == "" {
+		algorithm = ChecksumSHA1
+	}
+	if algorithm != ChecksumSHA1 && algorithm != ChecksumSHA256 {
+		return fmt.Errorf("unsupported checksum algorithm %q (use %s or %s)",
+			algorithm, ChecksumSHA1, ChecksumSHA256)
+	}
+	integrity = IntegritySettings{Algorithm: algorithm, SigningKey: config.SigningKey}
+	return nil
+}
+
+// newHasher returns a hash for the named algorithm
+func newHasher(algorithm string) hash.Hash {
+	if algorithm == ChecksumSHA256 {
+		return sha256.New()
+	}
+	return sha1.New()
+}
+
+// hashFile hashes a file's contents without reading it into memory
+func hashFile(path, algorithm string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hasher := newHasher(algorithm)
+	if _, err := io.Copy(hasher, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// writeChecksumPayload streams the canonical bytes covered by checksums and
+// signatures: the whole database, compact, with checksum and signature cleared
+func writeChecksumPayload(w io.Writer, db *MagnetDatabase) error {
+	clone := *db
+	clone.Metadata.Checksum = ""
+	clone.Metadata.Signature = ""
+	return writeDatabaseJSON(w, &clone, false, nil)
+}
+
+// computeChecksumWith hashes database contents with a specific algorithm
+func computeChecksumWith(db *MagnetDatabase, algorithm string) string {
+	hasher := newHasher(algorithm)
+	if err := writeChecksumPayload(hasher, db); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(hasher.Sum(nil))
+}
+
+// SignDatabase returns the HMAC signature of the database, or "" if no key is configured
+func SignDatabase(db *MagnetDatabase) string {
+	if integrity.SigningKey == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, []byte(integrity.SigningKey))
+	if err := writeChecksumPayload(mac, db); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyDatabase checks the stored checksum and, when a signing key is
+// configured, the HMAC signature. Legacy files without a recorded checksum
+// algorithm are accepted since their checksums were not reproducible.
+func VerifyDatabase(db *MagnetDatabase) error {
+	meta := db.Metadata
+	if meta.ChecksumAlgorithm != "" {
+		expected := computeChecksumWith(db, meta.ChecksumAlgorithm)
+		if meta.Checksum != expected {
+			return fmt.Errorf("checksum mismatch (%s): file is corrupted or was edited by hand", meta.ChecksumAlgorithm)
+		}
+	}
+
+	if integrity.SigningKey == "" {
+		return nil
+	}
+	if meta.Signature == "" {
+		return fmt.Errorf("database is not signed but a signing key is configured")
+	}
+	if !hmac.Equal([]byte(meta.Signature), []byte(SignDatabase(db))) {
+		return fmt.Errorf("signature mismatch: database was written with a different signing key")
+	}
+	return nil
+}
+
+// notifyIntegrityFailure alerts the user that a database copy was rejected
+func notifyIntegrityFailure(path string, err error) {
+	Notify(NotifyCritical, "Database integrity check failed",
+		fmt.Sprintf("%s: %v\nThis copy was not merged. Check the file or the signing key on each machine.", displayRemote(path), err))
+}
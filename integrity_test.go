@@ -0,0 +1,102 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// withIntegrity applies integrity settings for the duration of a test
+func withIntegrity(t *testing.T, config Config) {
+	t.Helper()
+	original := integrity
+	if err := ConfigureIntegrity(config); err != nil {
+		t.Fatalf("ConfigureIntegrity failed: %v", err)
+	}
+	t.Cleanup(func() { integrity = original })
+}
+
+// Test ConfigureIntegrity rejects unknown algorithms
+func TestConfigureIntegrity(t *testing.T) {
+	original := integrity
+	defer func() { integrity = original }()
+
+	if err := ConfigureIntegrity(Config{}); err != nil {
+		t.Errorf("Empty algorithm should default to sha1, got error: %v", err)
+	}
+	if integrity.Algorithm != ChecksumSHA1 {
+		t.Errorf("Expected default algorithm %q, got %q", ChecksumSHA1, integrity.Algorithm)
+	}
+	if err := ConfigureIntegrity(Config{ChecksumAlgorithm: "md5"}); err == nil {
+		t.Error("Expected error for unsupported algorithm")
+	}
+}
+
+// Test checksums use the configured algorithm
+func TestComputeChecksumSHA256(t *testing.T) {
+	withIntegrity(t, Config{ChecksumAlgorithm: ChecksumSHA256})
+
+	db := &MagnetDatabase{
+		Added: map[string]MagnetEntry{"hash1": {Hash: "hash1", Title: "Test1"}},
+		Retry: map[string]MagnetEntry{},
+	}
+
+	checksum := ComputeChecksum(db)
+	if len(checksum) != 64 {
+		t.Errorf("Expected 64-char SHA-256 checksum, got %d chars", len(checksum))
+	}
+	if checksum != computeChecksumWith(db, ChecksumSHA256) {
+		t.Error("ComputeChecksum should match computeChecksumWith for the configured algorithm")
+	}
+	if len(computeChecksumWith(db, ChecksumSHA1)) != 40 {
+		t.Error("Expected 40-char SHA-1 checksum")
+	}
+}
+
+// Test VerifyDatabase detects corruption and foreign signatures
+func TestVerifyDatabase(t *testing.T) {
+	withIntegrity(t, Config{ChecksumAlgorithm: ChecksumSHA256, SigningKey: "shared-secret"})
+
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	db := &MagnetDatabase{
+		Added: map[string]MagnetEntry{"hash1": {ID: 1, Hash: "hash1", Title: "Test1"}},
+		Retry: map[string]MagnetEntry{},
+	}
+	dbPath := filepath.Join(tmpDir, "signed.json")
+	if err := SaveDatabaseLocal(dbPath, db); err != nil {
+		t.Fatalf("SaveDatabaseLocal failed: %v", err)
+	}
+
+	loaded, err := LoadJSONDatabase(dbPath)
+	if err != nil {
+		t.Fatalf("LoadJSONDatabase failed: %v", err)
+	}
+	if err := VerifyDatabase(loaded); err != nil {
+		t.Fatalf("Freshly saved database should verify: %v", err)
+	}
+
+	// Tampered content
+	tampered := *loaded
+	tampered.Added = map[string]MagnetEntry{"hash1": {ID: 1, Hash: "hash1", Title: "Edited"}}
+	if err := VerifyDatabase(&tampered); err == nil {
+		t.Error("Tampered database should fail verification")
+	}
+
+	// Different signing key
+	withIntegrity(t, Config{ChecksumAlgorithm: ChecksumSHA256, SigningKey: "other-secret"})
+	if err := VerifyDatabase(loaded); err == nil {
+		t.Error("Database signed with another key should fail verification")
+	}
+
+	// Legacy database without algorithm or signature passes when unsigned
+	withIntegrity(t, Config{})
+	legacy := &MagnetDatabase{Metadata: DatabaseMetadata{Checksum: "legacy"}}
+	if err := VerifyDatabase(legacy); err != nil {
+		t.Errorf("Legacy database should be accepted: %v", err)
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// journalOp is one database mutation recorded in the append-only journal
+type journalOp struct {
+	ID      string      `json:"id"`
+	At      string      `json:"at"`
+	Section string      `json:"section"` // added, retry, or removed
+	Hash    string      `json:"hash"`
+	Entry   MagnetEntry `json:"entry"`
+}
+
+// journalPath returns the journal kept beside a database file
+func journalPath(dbPath string) string {
+	return dbPath + ".journal"
+}
+
+// journalOps converts a database update into journal operations, in the
+// order SaveJSONDatabase has always applied them
+func journalOps(updates *MagnetDatabase) []journalOp {
+	now := time.Now().Format(time.RFC3339Nano)
+	var ops []journalOp
+	for _, section := range []struct {
+		name    string
+		entries map[string]MagnetEntry
+	}{
+		{SectionAdded, updates.Added},
+		{SectionRetry, updates.Retry},
+		{SectionRemoved, updates.Removed},
+	} {
+		for hash, entry := range section.entries {
+			ops = append(ops, journalOp{ID: GenerateUUID(), At: now, Section: section.name, Hash: hash, Entry: entry})
+		}
+	}
+	return ops
+}
+
+// appendJournal durably appends operations to the journal
+func appendJournal(dbPath string, ops []journalOp) error {
+	if len(ops) == 0 {
+		return nil
+	}
+	return writeJournalLines(journalPath(dbPath), ops)
+}
+
+// writeJournalLines appends operations to path as JSON lines and syncs them
+// to disk. All lines go out in one write, so concurrent writers never
+// interleave within a line.
+func writeJournalLines(path string, ops []journalOp) error {
+	var buf bytes.Buffer
+	for _, op := range ops {
+		line, err := json.Marshal(op)
+		if err != nil {
+			return fmt.Errorf("failed to encode journal entry: %w", err)
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// readJournal returns the operations in the journal, skipping a line torn by
+// a crash during an append
+func readJournal(dbPath string) ([]journalOp, error) {
+	return readJournalLines(journalPath(dbPath))
+}
+
+// readJournalLines reads operations written by writeJournalLines
+func readJournalLines(path string) ([]journalOp, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var ops []journalOp
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var op journalOp
+		if err := json.Unmarshal(scanner.Bytes(), &op); err != nil || op.Hash == "" {
+			log.Printf("Warning: Skipping unreadable journal line")
+			continue
+		}
+		ops = append(ops, op)
+	}
+	return ops, scanner.Err()
+}
+
+// applyEntryUpdate moves an entry into a section, assigning a sequence ID to
+// new added and retry entries and stamping the change with this device's clock
+func applyEntryUpdate(db *MagnetDatabase, section, hash string, entry MagnetEntry) {
+	if db.Removed == nil {
+		db.Removed = make(map[string]MagnetEntry)
+	}
+	if entry.ID == 0 && section != SectionRemoved {
+		db.Metadata.LastSequence++
+		entry.ID = db.Metadata.LastSequence
+	}
+	stampEntry(db, &entry)
+
+	switch section {
+	case SectionAdded:
+		db.Added[hash] = entry
+		delete(db.Retry, hash)
+		delete(db.Removed, hash)
+	case SectionRetry:
+		db.Retry[hash] = entry
+		delete(db.Removed, hash)
+	case SectionRemoved:
+		db.Removed[hash] = entry
+		delete(db.Added, hash)
+		delete(db.Retry, hash)
+	}
+}
+
+// replayJournal folds journaled operations into db in the order they were
+// written and returns them
+func replayJournal(dbPath string, db *MagnetDatabase) []journalOp {
+	ops, err := readJournal(dbPath)
+	if err != nil {
+		log.Printf("Warning: Could not read journal: %v", err)
+		return nil
+	}
+	for _, op := range ops {
+		applyEntryUpdate(db, op.Section, op.Hash, op.Entry)
+	}
+	return ops
+}
+
+// applyMissingOps applies the operations in ops that replay did not fold
+// in, such as when the journal could not be written, and returns them
+func applyMissingOps(db *MagnetDatabase, folded, ops []journalOp) []journalOp {
+	replayed := make(map[string]bool, len(folded))
+	for _, op := range folded {
+		replayed[op.ID] = true
+	}
+	var missing []journalOp
+	for _, op := range ops {
+		if !replayed[op.ID] {
+			applyEntryUpdate(db, op.Section, op.Hash, op.Entry)
+			missing = append(missing, op)
+		}
+	}
+	return missing
+}
+
+// compactJournal drops operations that are now part of the saved database,
+// keeping any appended since. Callers hold the lock beside the database,
+// which every writer takes before appending.
+func compactJournal(dbPath string, folded []journalOp) error {
+	if len(folded) == 0 {
+		return nil
+	}
+	done := make(map[string]bool, len(folded))
+	for _, op := range folded {
+		done[op.ID] = true
+	}
+
+	ops, err := readJournal(dbPath)
+	if err != nil {
+		return err
+	}
+	var remaining []journalOp
+	for _, op := range ops {
+		if !done[op.ID] {
+			remaining = append(remaining, op)
+		}
+	}
+
+	path := journalPath(dbPath)
+	if len(remaining) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	tmp := path + ".tmp"
+	os.Remove(tmp)
+	if err := writeJournalLines(tmp, remaining); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Test journaled operations from an interrupted save are replayed on load
+func TestJournalReplayAfterCrash(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	localPath := filepath.Join(tmpDir, "local.json")
+	config := Config{JSONPath: localPath}
+
+	// Journal an add but never write the database, as if the save crashed
+	ops := journalOps(entryUpdate(SectionRetry, "hash1", MagnetEntry{Hash: "hash1", Title: "Lost Book"}))
+	if err := appendJournal(localPath, ops); err != nil {
+		t.Fatalf("appendJournal failed: %v", err)
+	}
+
+	db, err := loadDatabaseForAdd(config)
+	if err != nil {
+		t.Fatalf("loadDatabaseForAdd failed: %v", err)
+	}
+	if _, exists := db.Retry["hash1"]; !exists {
+		t.Fatal("Journaled entry should be visible before it is saved")
+	}
+
+	// The next save folds it in and empties the journal
+	if err := SaveJSONDatabase(localPath, entryUpdate(SectionAdded, "hash2", MagnetEntry{Hash: "hash2"}), &config); err != nil {
+		t.Fatalf("SaveJSONDatabase failed: %v", err)
+	}
+	saved, err := LoadJSONDatabase(localPath)
+	if err != nil {
+		t.Fatalf("LoadJSONDatabase failed: %v", err)
+	}
+	if saved.Retry["hash1"].Title != "Lost Book" {
+		t.Error("Journaled entry should be folded into the saved database")
+	}
+	if _, exists := saved.Added["hash2"]; !exists {
+		t.Error("New update should be saved")
+	}
+	if saved.Retry["hash1"].ID == 0 || saved.Added["hash2"].ID == 0 {
+		t.Error("Folded entries should be assigned sequence IDs")
+	}
+	if _, err := os.Stat(journalPath(localPath)); !os.IsNotExist(err) {
+		t.Error("Journal should be removed once everything is folded in")
+	}
+}
+
+// Test journal operations apply in the order they were written
+func TestJournalReplayOrder(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	localPath := filepath.Join(tmpDir, "local.json")
+	steps := []string{SectionRetry, SectionAdded, SectionRemoved, SectionRetry}
+	for _, section := range steps {
+		ops := journalOps(entryUpdate(section, "hash1", MagnetEntry{Hash: "hash1", Title: section}))
+		if err := appendJournal(localPath, ops); err != nil {
+			t.Fatalf("appendJournal failed: %v", err)
+		}
+	}
+
+	db := &MagnetDatabase{Added: map[string]MagnetEntry{}, Retry: map[string]MagnetEntry{}}
+	folded := replayJournal(localPath, db)
+	if len(folded) != len(steps) {
+		t.Fatalf("Expected %d operations, got %d", len(steps), len(folded))
+	}
+	if _, exists := db.Retry["hash1"]; !exists {
+		t.Error("Last operation should win")
+	}
+	if _, exists := db.Added["hash1"]; exists {
+		t.Error("Earlier add should have been undone by the removal")
+	}
+	if _, exists := db.Removed["hash1"]; exists {
+		t.Error("Requeue should clear the removal")
+	}
+}
+
+// Test compaction keeps operations that were not folded and skips torn lines
+func TestCompactJournal(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	localPath := filepath.Join(tmpDir, "local.json")
+	first := journalOps(entryUpdate(SectionAdded, "hash1", MagnetEntry{Hash: "hash1"}))
+	second := journalOps(entryUpdate(SectionAdded, "hash2", MagnetEntry{Hash: "hash2"}))
+	appendJournal(localPath, first)
+	appendJournal(localPath, second)
+
+	// A crash mid-append leaves a partial last line
+	f, err := os.OpenFile(journalPath(localPath), os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("Failed to open journal: %v", err)
+	}
+	f.WriteString(`{"id":"torn","sec`)
+	f.Close()
+
+	if err := compactJournal(localPath, first); err != nil {
+		t.Fatalf("compactJournal failed: %v", err)
+	}
+	ops, err := readJournal(localPath)
+	if err != nil {
+		t.Fatalf("readJournal failed: %v", err)
+	}
+	if len(ops) != 1 || ops[0].Hash != "hash2" {
+		t.Errorf("Expected only the unfolded hash2 operation, got %+v", ops)
+	}
+}
+
+// Test a save waits for another process holding the database lock and
+// keeps its own update even when the journal no longer holds it
+func TestSaveJSONDatabaseLocked(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	localPath := filepath.Join(tmpDir, "local.json")
+	config := Config{JSONPath: localPath}
+	release, err := acquireFileLock(localPath, time.Second)
+	if err != nil {
+		t.Fatalf("acquireFileLock failed: %v", err)
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- SaveJSONDatabase(localPath, entryUpdate(SectionRetry, "hash1", MagnetEntry{Hash: "hash1"}), &config)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	if _, err := os.Stat(journalPath(localPath)); !os.IsNotExist(err) {
+		t.Error("Save should not touch the journal while another process holds the lock")
+	}
+	release()
+	if err := <-done; err != nil {
+		t.Fatalf("SaveJSONDatabase failed: %v", err)
+	}
+
+	db := &MagnetDatabase{Added: map[string]MagnetEntry{}, Retry: map[string]MagnetEntry{}}
+	ops := journalOps(entryUpdate(SectionAdded, "hash2", MagnetEntry{Hash: "hash2"}))
+	if missing := applyMissingOps(db, nil, ops); len(missing) != 1 || db.Added["hash2"].ID == 0 {
+		t.Errorf("Expected an update missing from the journal applied directly, got %+v", db.Added)
+	}
+	if missing := applyMissingOps(db, ops, ops); len(missing) != 0 {
+		t.Errorf("Expected replayed updates not applied twice, got %+v", missing)
+	}
+
+	saved, err := LoadJSONDatabase(localPath)
+	if err != nil || saved.Retry["hash1"].ID == 0 {
+		t.Errorf("Expected the update saved once the lock was free, got %+v, %v", saved, err)
+	}
+	if _, err := os.Stat(lockPath(localPath)); !os.IsNotExist(err) {
+		t.Error("Save should release the database lock")
+	}
+}
@@ -0,0 +1,46 @@
+//go:build !windows
+
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// keychainGet reads a secret from the macOS Keychain, or the Secret Service
+// (GNOME Keyring, KWallet) through secret-tool elsewhere
+func keychainGet(account string) (string, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "darwin" {
+		cmd = exec.Command("security", "find-generic-password", "-s", keychainService, "-a", account, "-w")
+	} else {
+		cmd = exec.Command("secret-tool", "lookup", "service", keychainService, "account", account)
+	}
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("no %s secret %q in the keychain (%s: %v)", keychainService, account, cmd.Args[0], err)
+	}
+	value := strings.TrimRight(string(output), "\r\n")
+	if value == "" {
+		return "", fmt.Errorf("keychain secret %q is empty", account)
+	}
+	return value, nil
+}
+
+// keychainSet stores a secret in the keychain, replacing any earlier value
+func keychainSet(account, value string) error {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "darwin" {
+		// security only takes the password as an argument
+		cmd = exec.Command("security", "add-generic-password", "-U", "-s", keychainService, "-a", account, "-w", value)
+	} else {
+		cmd = exec.Command("secret-tool", "store", "--label", keychainService+" "+account, "service", keychainService, "account", account)
+		cmd.Stdin = strings.NewReader(value)
+	}
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to store %q in the keychain: %v: %s", account, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// explicitLabel is set when --label chose the label for this run; tracker
+// routing never overrides it
+var explicitLabel bool
+
+// trackerHosts returns the lower-case host names of a magnet's trackers, in
+// the order they are listed
+func trackerHosts(magnetURI string) []string {
+	query, ok := strings.CutPrefix(magnetURI, "magnet:?")
+	if !ok {
+		return nil
+	}
+	params, err := url.ParseQuery(query)
+	if err != nil {
+		return nil
+	}
+	var hosts []string
+	for _, tracker := range params["tr"] {
+		if u, err := url.Parse(tracker); err == nil && u.Hostname() != "" {
+			hosts = append(hosts, strings.ToLower(u.Hostname()))
+		}
+	}
+	return hosts
+}
+
+// trackerLabel returns the label tracker_labels maps the magnet's first
+// known tracker to, and that tracker's host. A key matches its host and any
+// subdomain; the most specific key wins.
+func trackerLabel(config Config, magnetURI string) (string, string) {
+	if len(config.TrackerLabels) == 0 {
+		return "", ""
+	}
+	domains := make([]string, 0, len(config.TrackerLabels))
+	for domain := range config.TrackerLabels {
+		domains = append(domains, domain)
+	}
+	sort.Slice(domains, func(i, j int) bool {
+		if len(domains[i]) != len(domains[j]) {
+			return len(domains[i]) > len(domains[j])
+		}
+		return domains[i] < domains[j]
+	})
+
+	for _, host := range trackerHosts(magnetURI) {
+		for _, domain := range domains {
+			key := strings.ToLower(strings.TrimPrefix(domain, "."))
+			if host == key || strings.HasSuffix(host, "."+key) {
+				if label := config.TrackerLabels[domain]; label != "" {
+					return label, host
+				}
+			}
+		}
+	}
+	return "", ""
+}
+
+// routeByTracker switches config to the label its tracker_labels give the
+// magnet, unless --label chose one
+func routeByTracker(config *Config, magnetURI string) {
+	logger := config.logger()
+	if explicitLabel {
+		return
+	}
+	label, host := trackerLabel(*config, magnetURI)
+	if label == "" || label == config.DelugeLabel {
+		return
+	}
+	logger.Printf("Label %q from tracker %s", label, host)
+	config.DelugeLabel = label
+}
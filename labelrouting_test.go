@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+)
+
+// Test magnets are labelled by the most specific tracker_labels key matching
+// their first known tracker, and --label always wins
+func TestRouteByTracker(t *testing.T) {
+	config := Config{DelugeLabel: "default", TrackerLabels: map[string]string{
+		"abtracker.example":       "audiobooks",
+		"music.abtracker.example": "music",
+		"Flac.Example.org":        "music",
+		"public.example.net":      "",
+	}}
+
+	tests := []struct {
+		uri  string
+		want string
+	}{
+		{"magnet:?xt=urn:btih:abc&tr=udp%3A%2F%2Ftracker.abtracker.example%3A1337%2Fannounce", "audiobooks"},
+		{"magnet:?xt=urn:btih:abc&tr=https%3A%2F%2Fmusic.abtracker.example%2Fannounce", "music"},
+		{"magnet:?xt=urn:btih:abc&tr=udp%3A%2F%2Fopen.example.com%3A80&tr=http%3A%2F%2Fflac.example.org%2Fa", "music"},
+		{"magnet:?xt=urn:btih:abc&tr=udp%3A%2F%2Fpublic.example.net%3A80", "default"},
+		{"magnet:?xt=urn:btih:abc&tr=udp%3A%2F%2Fnotabtracker.example%3A80", "default"},
+		{"magnet:?xt=urn:btih:abc", "default"},
+	}
+	for _, tt := range tests {
+		routed := config
+		routeByTracker(&routed, tt.uri)
+		if routed.DelugeLabel != tt.want {
+			t.Errorf("%s: expected label %q, got %q", tt.uri, tt.want, routed.DelugeLabel)
+		}
+	}
+
+	explicitLabel = true
+	defer func() { explicitLabel = false }()
+	routed := config
+	routeByTracker(&routed, tests[0].uri)
+	if routed.DelugeLabel != "default" {
+		t.Errorf("--label should override tracker routing, got %q", routed.DelugeLabel)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"log"
+)
+
+// Label plugins the handler knows how to drive
+const (
+	LabelPluginNone      = ""
+	LabelPluginLabel     = "Label"
+	LabelPluginLabelPlus = "LabelPlus"
+)
+
+// LabelPlugin reports which label plugin is enabled on the server, preferring
+// Label when both are. The answer is cached for the life of the client.
+func (c *DelugeClient) LabelPlugin() (string, error) {
+	if c.labelPluginChecked {
+		return c.labelPlugin, nil
+	}
+
+	var plugins []string
+	if err := c.rpc("core.get_enabled_plugins", nil, &plugins); err != nil {
+		return LabelPluginNone, fmt.Errorf("failed to list plugins: %w", err)
+	}
+
+	enabled := map[string]bool{}
+	for _, name := range plugins {
+		enabled[name] = true
+	}
+
+	switch {
+	case enabled[LabelPluginLabel]:
+		c.labelPlugin = LabelPluginLabel
+	case enabled[LabelPluginLabelPlus]:
+		c.labelPlugin = LabelPluginLabelPlus
+	default:
+		c.labelPlugin = LabelPluginNone
+	}
+	c.labelPluginChecked = true
+
+	return c.labelPlugin, nil
+}
+
+// SetTorrentLabel applies a label using whichever label plugin is enabled
+func (c *DelugeClient) SetTorrentLabel(torrentID, label string) error {
+	plugin, err := c.LabelPlugin()
+	if err != nil {
+		return err
+	}
+
+	switch plugin {
+	case LabelPluginLabel:
+		// Ignore error if label already exists
+		_ = c.rpc("label.add", []interface{}{label}, nil)
+		return c.rpc("label.set_torrent", []interface{}{torrentID, label}, nil)
+	case LabelPluginLabelPlus:
+		labelID, err := c.labelPlusID(label)
+		if err != nil {
+			return err
+		}
+		return c.rpc("labelplus.set_torrent_labels", []interface{}{[]interface{}{torrentID}, labelID}, nil)
+	default:
+		return fmt.Errorf("neither the Label nor LabelPlus plugin is enabled")
+	}
+}
+
+// labelPlusID finds a top-level LabelPlus label by name, creating it if needed
+func (c *DelugeClient) labelPlusID(name string) (string, error) {
+	var bases map[string]interface{}
+	if err := c.rpc("labelplus.get_label_bases", nil, &bases); err != nil {
+		return "", fmt.Errorf("failed to list LabelPlus labels: %w", err)
+	}
+
+	for id, base := range bases {
+		if labelPlusName(base) == name {
+			return id, nil
+		}
+	}
+
+	log.Printf("Creating LabelPlus label: %s", name)
+	var id string
+	if err := c.rpc("labelplus.add_label", []interface{}{nil, name}, &id); err != nil {
+		return "", fmt.Errorf("failed to create LabelPlus label: %w", err)
+	}
+	if id == "" {
+		return "", fmt.Errorf("LabelPlus returned an empty label ID")
+	}
+	return id, nil
+}
+
+// labelPlusName extracts the label name from a get_label_bases value, which
+// is either the name itself or a map describing the label
+func labelPlusName(base interface{}) string {
+	switch v := base.(type) {
+	case string:
+		return v
+	case map[string]interface{}:
+		name, _ := v["name"].(string)
+		return name
+	}
+	return ""
+}
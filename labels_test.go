@@ -0,0 +1,81 @@
+package main
+
+import (
+	"testing"
+)
+
+// Test SetTorrentLabel uses the RPC namespace of the enabled plugin
+func TestSetTorrentLabel(t *testing.T) {
+	tests := []struct {
+		name     string
+		plugins  []interface{}
+		expected string
+		wantErr  bool
+	}{
+		{"label plugin", []interface{}{"Label", "Scheduler"}, "label.set_torrent", false},
+		{"labelplus plugin", []interface{}{"LabelPlus"}, "labelplus.set_torrent_labels", false},
+		{"both prefers label", []interface{}{"LabelPlus", "Label"}, "label.set_torrent", false},
+		{"no plugin", []interface{}{"Scheduler"}, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var setCalls []string
+			var labelArg interface{}
+			client := fakeDeluge(t, func(method string, params []interface{}) (interface{}, interface{}) {
+				switch method {
+				case "core.get_enabled_plugins":
+					return tt.plugins, nil
+				case "labelplus.get_label_bases":
+					return map[string]interface{}{"1": map[string]interface{}{"name": "tv"}}, nil
+				case "labelplus.add_label":
+					return "2", nil
+				case "label.set_torrent", "labelplus.set_torrent_labels":
+					setCalls = append(setCalls, method)
+					labelArg = params[1]
+				}
+				return nil, nil
+			})
+
+			err := client.SetTorrentLabel("hash1", "audiobooks")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SetTorrentLabel error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if len(setCalls) != 0 {
+					t.Errorf("No label should be set, got %v", setCalls)
+				}
+				return
+			}
+			if len(setCalls) != 1 || setCalls[0] != tt.expected {
+				t.Errorf("Expected %s, got %v", tt.expected, setCalls)
+			}
+			if tt.expected == "labelplus.set_torrent_labels" && labelArg != "2" {
+				t.Errorf("LabelPlus should use the created label ID, got %v", labelArg)
+			}
+		})
+	}
+}
+
+// Test labelPlusID reuses an existing LabelPlus label
+func TestLabelPlusIDExisting(t *testing.T) {
+	created := false
+	client := fakeDeluge(t, func(method string, params []interface{}) (interface{}, interface{}) {
+		switch method {
+		case "labelplus.get_label_bases":
+			return map[string]interface{}{"7": "audiobooks"}, nil
+		case "labelplus.add_label":
+			created = true
+			return "8", nil
+		}
+		return nil, nil
+	})
+
+	id, err := client.labelPlusID("audiobooks")
+	if err != nil {
+		t.Fatalf("labelPlusID failed: %v", err)
+	}
+	if id != "7" || created {
+		t.Errorf("Expected existing ID 7 without creating, got %q (created=%v)", id, created)
+	}
+}
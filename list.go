@@ -0,0 +1,241 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	SectionAdded   = "added"
+	SectionRetry   = "retry"
+	SectionRemoved = "removed"
+)
+
+// EntryQuery selects a page of database entries
+type EntryQuery struct {
+	Section string // added, retry, removed, dead, failed, or empty for all but removed
+	Match   string // Case-insensitive substring of title, torrent name, or hash
+	Offset  int
+	Limit   int                    // 0 = no limit
+	Dead    func(MagnetEntry) bool // Retry entries it matches are listed as dead (nil = none)
+	Failed  func(MagnetEntry) bool // Retry entries it matches are listed as failed, before dead (nil = none)
+	Keep    func(ListedEntry) bool // Further filter, such as a saved view (nil = all)
+}
+
+// ListedEntry is a database entry annotated with the section it lives in
+type ListedEntry struct {
+	Section string `json:"section"`
+	ETag    string `json:"etag,omitempty"`  // Version for If-Match, set by the API
+	WebUI   string `json:"webui,omitempty"` // Deluge Web UI link for entries in Deluge, set by the API
+	MagnetEntry
+}
+
+// EntryPage is one page of query results
+type EntryPage struct {
+	Total      int           `json:"total"`                 // Matches before pagination
+	Offset     int           `json:"offset"`                // Offset of the first entry
+	Limit      int           `json:"limit"`                 // Requested page size
+	NextOffset int           `json:"next_offset,omitempty"` // Offset of the next page, 0 if none
+	Entries    []ListedEntry `json:"entries"`
+}
+
+// matchesQuery reports whether an entry passes the query's filters
+func matchesQuery(entry MagnetEntry, match string) bool {
+	if match == "" {
+		return true
+	}
+	match = strings.ToLower(match)
+	return strings.Contains(strings.ToLower(entry.Title), match) ||
+		strings.Contains(strings.ToLower(entry.TorrentName), match) ||
+		strings.Contains(entry.Hash, match)
+}
+
+// QueryEntries filters, sorts (newest first), and paginates database entries
+func QueryEntries(db *MagnetDatabase, query EntryQuery) EntryPage {
+	var matched []ListedEntry
+	collect := func(section string, entries map[string]MagnetEntry) {
+		for _, entry := range entries {
+			listed := section
+			if section == SectionRetry && query.Failed != nil && query.Failed(entry) {
+				listed = SectionFailed
+			} else if section == SectionRetry && query.Dead != nil && query.Dead(entry) {
+				listed = SectionDead
+			}
+			if query.Section != listed && (query.Section != "" || listed == SectionRemoved) {
+				continue
+			}
+			if !matchesQuery(entry, query.Match) {
+				continue
+			}
+			item := ListedEntry{Section: listed, MagnetEntry: entry}
+			if query.Keep == nil || query.Keep(item) {
+				matched = append(matched, item)
+			}
+		}
+	}
+	collect(SectionAdded, db.Added)
+	collect(SectionRetry, db.Retry)
+	collect(SectionRemoved, db.Removed)
+
+	sort.Slice(matched, func(i, j int) bool {
+		if matched[i].AddedDate != matched[j].AddedDate {
+			return matched[i].AddedDate > matched[j].AddedDate
+		}
+		return matched[i].Hash < matched[j].Hash
+	})
+
+	page := EntryPage{Total: len(matched), Offset: query.Offset, Limit: query.Limit}
+	start := min(max(query.Offset, 0), len(matched))
+	end := len(matched)
+	if query.Limit > 0 {
+		end = min(start+query.Limit, len(matched))
+	}
+	page.Entries = matched[start:end]
+	if end < len(matched) {
+		page.NextOffset = end
+	}
+	return page
+}
+
+// findEntry locates an entry by info hash or UUID in any section
+func findEntry(db *MagnetDatabase, key string) (string, string, MagnetEntry, bool) {
+	key = strings.ToLower(strings.TrimSpace(key))
+	sections := []struct {
+		name    string
+		entries map[string]MagnetEntry
+	}{
+		{SectionAdded, db.Added},
+		{SectionRetry, db.Retry},
+		{SectionRemoved, db.Removed},
+	}
+	for _, section := range sections {
+		if entry, ok := section.entries[key]; ok {
+			return key, section.name, entry, true
+		}
+	}
+	for _, section := range sections {
+		for hash, entry := range section.entries {
+			if entry.UUID != "" && strings.EqualFold(entry.UUID, key) {
+				return hash, section.name, entry, true
+			}
+		}
+	}
+	return "", "", MagnetEntry{}, false
+}
+
+// entryUpdate builds a database update that stores an entry in a section
+func entryUpdate(section, hash string, entry MagnetEntry) *MagnetDatabase {
+	update := &MagnetDatabase{
+		Added:   make(map[string]MagnetEntry),
+		Retry:   make(map[string]MagnetEntry),
+		Removed: make(map[string]MagnetEntry),
+	}
+	switch section {
+	case SectionAdded:
+		update.Added[hash] = entry
+	case SectionRetry:
+		update.Retry[hash] = entry
+	case SectionRemoved:
+		update.Removed[hash] = entry
+	}
+	return update
+}
+
+// runList implements the list command
+func runList(config Config, args []string) error {
+	fs := newCommandFlags(listCommand)
+	view := fs.String("view", "", "Start from a saved view in the config (see the views command)")
+	section := fs.String("section", "", "Only show entries from this section (added, retry, dead, failed, or removed)")
+	match := fs.String("match", "", "Only show entries whose title or hash contains this text")
+	limit := fs.Int("limit", 50, "Maximum entries to show (0 = all)")
+	offset := fs.Int("offset", 0, "Number of entries to skip")
+	asJSON := fs.Bool("json", false, "Print the page as JSON")
+	columnList := fs.String("columns", "", "Comma-separated columns to show, e.g. hash,title,status,added (default list_columns)")
+	asOf := fs.String("as-of", "", "Show the database as it stood at this date (YYYY-MM-DD, end of day) or RFC 3339 time")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	columns := config.ListColumns
+	if *columnList != "" {
+		columns = parseColumns(*columnList)
+	}
+	if len(columns) == 0 {
+		columns = defaultListColumns
+	}
+	if err := validateColumns(columns); err != nil {
+		return err
+	}
+	query := EntryQuery{Dead: deadFilter(config), Failed: failedFilter(config)}
+	if *view != "" {
+		var err error
+		if query, err = viewQuery(config, *view, time.Now()); err != nil {
+			return err
+		}
+	}
+	switch *section {
+	case "", SectionAdded, SectionRetry, SectionDead, SectionFailed, SectionRemoved:
+	default:
+		return fmt.Errorf("unknown section %q (use %s, %s, %s, %s, or %s)", *section, SectionAdded, SectionRetry, SectionDead, SectionFailed, SectionRemoved)
+	}
+
+	db, err := LoadJSONDatabase(config.JSONPath)
+	if err != nil {
+		return fmt.Errorf("failed to load database: %w", err)
+	}
+	if *asOf != "" {
+		t, err := parseQueryTime(*asOf, true)
+		if err != nil {
+			return err
+		}
+		ops, err := readOplog(config.JSONPath)
+		if err != nil {
+			return fmt.Errorf("failed to read operation log: %w", err)
+		}
+		db = databaseAsOf(db, ops, t)
+	}
+
+	// Explicit flags narrow or override the view
+	if *section != "" {
+		query.Section = *section
+	}
+	if *match != "" {
+		query.Match = *match
+	}
+	query.Offset, query.Limit = *offset, *limit
+	page := QueryEntries(db, query)
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(page)
+	}
+
+	if page.Total == 0 {
+		fmt.Println("No matching entries")
+		return nil
+	}
+	if err := writeColumns(os.Stdout, page.Entries, columns); err != nil {
+		return err
+	}
+	fmt.Printf("\nShowing %d-%d of %d", page.Offset+1, page.Offset+len(page.Entries), page.Total)
+	if page.NextOffset > 0 {
+		fmt.Printf(" (next page: --offset %d)", page.NextOffset)
+	}
+	fmt.Println()
+	return nil
+}
+
+var listCommand = &Command{
+	Name:    "list",
+	Usage:   "list [--view name] [--section added|retry|dead|failed|removed] [--match text] [--limit N] [--offset N] [--columns a,b,...] [--as-of date] [--json]",
+	Summary: "List tracked entries, newest first, with filtering and pagination",
+}
+
+func init() {
+	listCommand.Run = runList
+	registerCommand(listCommand)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// buildListDatabase creates a database with n added and n retry entries
+func buildListDatabase(n int) *MagnetDatabase {
+	db := &MagnetDatabase{
+		Added: make(map[string]MagnetEntry),
+		Retry: make(map[string]MagnetEntry),
+	}
+	for i := 0; i < n; i++ {
+		added := fmt.Sprintf("a%039d", i)
+		db.Added[added] = MagnetEntry{Hash: added, Title: fmt.Sprintf("Added Book %d", i),
+			AddedDate: fmt.Sprintf("2024-01-%02dT00:00:00Z", i+1)}
+		retry := fmt.Sprintf("b%039d", i)
+		db.Retry[retry] = MagnetEntry{Hash: retry, Title: fmt.Sprintf("Retry Book %d", i),
+			AddedDate: fmt.Sprintf("2024-02-%02dT00:00:00Z", i+1)}
+	}
+	return db
+}
+
+// Test QueryEntries pagination
+func TestQueryEntriesPagination(t *testing.T) {
+	db := buildListDatabase(10)
+
+	page := QueryEntries(db, EntryQuery{Limit: 5})
+	if page.Total != 20 {
+		t.Errorf("Expected total 20, got %d", page.Total)
+	}
+	if len(page.Entries) != 5 {
+		t.Fatalf("Expected 5 entries, got %d", len(page.Entries))
+	}
+	if page.NextOffset != 5 {
+		t.Errorf("Expected next offset 5, got %d", page.NextOffset)
+	}
+	// Newest first: retry entries were added in February
+	if page.Entries[0].Section != SectionRetry || page.Entries[0].Title != "Retry Book 9" {
+		t.Errorf("Unexpected first entry: %s %q", page.Entries[0].Section, page.Entries[0].Title)
+	}
+
+	last := QueryEntries(db, EntryQuery{Offset: 15, Limit: 10})
+	if len(last.Entries) != 5 {
+		t.Errorf("Expected 5 entries on last page, got %d", len(last.Entries))
+	}
+	if last.NextOffset != 0 {
+		t.Errorf("Last page should have no next offset, got %d", last.NextOffset)
+	}
+
+	beyond := QueryEntries(db, EntryQuery{Offset: 100, Limit: 10})
+	if len(beyond.Entries) != 0 {
+		t.Errorf("Offset past the end should return no entries, got %d", len(beyond.Entries))
+	}
+}
+
+// Test QueryEntries filtering
+func TestQueryEntriesFilter(t *testing.T) {
+	db := buildListDatabase(10)
+
+	retryOnly := QueryEntries(db, EntryQuery{Section: SectionRetry})
+	if retryOnly.Total != 10 {
+		t.Errorf("Expected 10 retry entries, got %d", retryOnly.Total)
+	}
+
+	matched := QueryEntries(db, EntryQuery{Match: "added book 3"})
+	if matched.Total != 1 || matched.Entries[0].Title != "Added Book 3" {
+		t.Errorf("Expected single match for 'added book 3', got %d", matched.Total)
+	}
+
+	byHash := QueryEntries(db, EntryQuery{Match: "b000"})
+	if byHash.Total != 10 {
+		t.Errorf("Expected 10 hash matches, got %d", byHash.Total)
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// How long to wait for another machine's lock on the remote database, and
+// when an abandoned lock may be broken. A save takes seconds, so a lock
+// older than staleLockAge belongs to a writer that crashed.
+var (
+	remoteLockTimeout = 30 * time.Second
+	remoteLockPoll    = 250 * time.Millisecond
+	staleLockAge      = 2 * time.Minute
+)
+
+// fileLock is the content of a lock file, naming the writer holding it
+type fileLock struct {
+	Host   string `json:"host"`
+	PID    int    `json:"pid"`
+	Device string `json:"device,omitempty"`
+	At     string `json:"at"`
+	Token  string `json:"token,omitempty"` // Tells this holder apart from a later one in the same process
+}
+
+// lockPath returns the lock file kept beside a database file
+func lockPath(path string) string {
+	return path + ".lock"
+}
+
+// acquireFileLock takes the lock beside path, waiting up to timeout while
+// another writer holds it. The returned function releases the lock unless
+// it was broken as stale and is now another writer's.
+func acquireFileLock(path string, timeout time.Duration) (func(), error) {
+	lock := lockPath(path)
+	holder, _ := json.Marshal(fileLock{
+		Host:   localHostname(),
+		PID:    os.Getpid(),
+		Device: deviceID,
+		At:     time.Now().Format(time.RFC3339),
+		Token:  GenerateUUID(),
+	})
+
+	deadline := time.Now().Add(timeout)
+	for {
+		file, err := os.OpenFile(lock, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			_, err = file.Write(holder)
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				os.Remove(lock)
+				return nil, fmt.Errorf("failed to write lock: %w", err)
+			}
+			return func() { releaseFileLock(lock, holder) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("failed to create lock: %w", err)
+		}
+
+		info, statErr := os.Stat(lock)
+		if statErr == nil && time.Since(info.ModTime()) > staleLockAge {
+			log.Printf("⚠ Breaking stale lock on %s (%s)", path, describeLock(lock))
+			os.Remove(lock)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%s is locked by %s", path, describeLock(lock))
+		}
+		time.Sleep(remoteLockPoll)
+	}
+}
+
+// releaseFileLock removes a lock file if it still names holder
+func releaseFileLock(lock string, holder []byte) {
+	data, err := os.ReadFile(lock)
+	if err != nil {
+		return
+	}
+	if !bytes.Equal(data, holder) {
+		log.Printf("⚠ Lock %s was broken as stale and is now held by %s; leaving it", lock, describeLock(lock))
+		return
+	}
+	os.Remove(lock)
+}
+
+// describeLock names the writer holding a lock file, for messages
+func describeLock(lock string) string {
+	data, err := os.ReadFile(lock)
+	if err != nil {
+		return "another writer"
+	}
+	var holder fileLock
+	if err := json.Unmarshal(data, &holder); err != nil || holder.Host == "" {
+		return "another writer"
+	}
+	return fmt.Sprintf("%s (pid %d) since %s", holder.Host, holder.PID, holder.At)
+}
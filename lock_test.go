@@ -0,0 +1,121 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test acquireFileLock excludes a second writer until the first releases
+func TestAcquireFileLock(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	path := filepath.Join(tmpDir, "remote.json")
+	first, err := acquireFileLock(path, time.Second)
+	if err != nil {
+		t.Fatalf("acquireFileLock failed: %v", err)
+	}
+	if _, err := os.Stat(lockPath(path)); err != nil {
+		t.Fatalf("Expected lock file: %v", err)
+	}
+
+	_, err = acquireFileLock(path, 50*time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "locked by "+localHostname()) {
+		t.Errorf("Expected lock held error naming this host, got %v", err)
+	}
+
+	// A waiting writer gets the lock once the holder releases it
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		first()
+	}()
+	release, err := acquireFileLock(path, 5*time.Second)
+	if err != nil {
+		t.Fatalf("Expected lock after release, got %v", err)
+	}
+	release()
+	if _, err := os.Stat(lockPath(path)); !os.IsNotExist(err) {
+		t.Error("Expected lock file removed on release")
+	}
+}
+
+// Test acquireFileLock breaks a lock abandoned by a crashed writer
+func TestAcquireFileLockStale(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	path := filepath.Join(tmpDir, "remote.json")
+	if err := os.WriteFile(lockPath(path), []byte(`{"host":"nas-desktop","pid":1}`), 0644); err != nil {
+		t.Fatalf("Failed to write lock: %v", err)
+	}
+	old := time.Now().Add(-staleLockAge - time.Minute)
+	if err := os.Chtimes(lockPath(path), old, old); err != nil {
+		t.Fatalf("Failed to age lock: %v", err)
+	}
+
+	release, err := acquireFileLock(path, 50*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Expected stale lock to be broken, got %v", err)
+	}
+
+	// A holder whose lock was broken must not release its successor's
+	if err := os.Chtimes(lockPath(path), old, old); err != nil {
+		t.Fatalf("Failed to age lock: %v", err)
+	}
+	next, err := acquireFileLock(path, 50*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Expected stale lock to be broken, got %v", err)
+	}
+	release()
+	if _, err := os.Stat(lockPath(path)); err != nil {
+		t.Errorf("Expected the new holder's lock kept, got %v", err)
+	}
+	next()
+	if _, err := os.Stat(lockPath(path)); !os.IsNotExist(err) {
+		t.Error("Expected lock file removed on release")
+	}
+}
+
+// Test saveRemoteDatabase waits for another machine's lock and then writes
+func TestSaveRemoteDatabaseLocked(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	oldTimeout := remoteLockTimeout
+	remoteLockTimeout = 50 * time.Millisecond
+	defer func() { remoteLockTimeout = oldTimeout }()
+
+	path := filepath.Join(tmpDir, "remote.json")
+	db := &MagnetDatabase{Added: map[string]MagnetEntry{"hash1": {ID: 1, Hash: "hash1"}}, Retry: map[string]MagnetEntry{}}
+	if err := os.WriteFile(lockPath(path), []byte(`{"host":"other-desktop","pid":42}`), 0644); err != nil {
+		t.Fatalf("Failed to write lock: %v", err)
+	}
+
+	err = saveRemoteDatabase(path, db)
+	if err == nil || !strings.Contains(err.Error(), "other-desktop (pid 42)") {
+		t.Fatalf("Expected save to time out on the held lock, got %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("Expected remote not written while locked")
+	}
+
+	os.Remove(lockPath(path))
+	if err := saveRemoteDatabase(path, db); err != nil {
+		t.Fatalf("saveRemoteDatabase failed: %v", err)
+	}
+	if _, err := os.Stat(lockPath(path)); !os.IsNotExist(err) {
+		t.Error("Expected lock released after save")
+	}
+}
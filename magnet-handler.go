@@ -1,11 +1,8 @@
 package main
 
 import (
-	"bytes"
 	"crypto/rand"
-	"crypto/sha1"
-	"encoding/hex"
-	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
@@ -22,44 +19,177 @@ const version = "1.1.0"
 
 // Config represents the handler configuration
 type Config struct {
-	DelugeHost     string `json:"deluge_host"`
-	DelugePort     string `json:"deluge_port"`
-	DelugePassword string `json:"deluge_password"`
-	DelugeLabel    string `json:"deluge_label"`
-	JSONPath       string `json:"json_path"`
-	RemotePath     string `json:"remote_path,omitempty"` // Path to shared/network storage (optional)
+	DelugeHost     string   `json:"deluge_host"`
+	DelugePort     string   `json:"deluge_port"`
+	DelugePassword string   `json:"deluge_password"`
+	DelugeLabel    string   `json:"deluge_label"`
+	JSONPath       string   `json:"json_path"`
+	RemotePath     string   `json:"remote_path,omitempty"`  // Path to shared/network storage, an rclone remote:path, or a WebDAV or sftp:// URL (optional)
+	RemotePaths    []string `json:"remote_paths,omitempty"` // Further remotes kept in sync alongside remote_path
+
+	// Add-torrent options (optional, Deluge defaults used when empty)
+	DownloadLocation  string  `json:"download_location,omitempty"`
+	AddPaused         bool    `json:"add_paused,omitempty"`
+	MaxDownloadSpeed  float64 `json:"max_download_speed,omitempty"` // KiB/s, 0 = unlimited
+	MoveCompletedPath string  `json:"move_completed_path,omitempty"`
+
+	RefreshBeforeAdd bool `json:"refresh_before_add,omitempty"` // Read remote before duplicate check
+
+	// Database integrity (optional)
+	ChecksumAlgorithm string `json:"checksum_algorithm,omitempty"` // sha1 (default) or sha256
+	SigningKey        string `json:"signing_key,omitempty"`        // Shared HMAC key for all machines
+
+	DeviceID      string `json:"device_id,omitempty"`       // Identifies this installation in sync metadata (generated on first run)
+	MergeTieBreak string `json:"merge_tie_break,omitempty"` // Sync winner when two copies are equally recent: id (default), local, or remote
+
+	ReadFallbacks []string `json:"read_fallbacks,omitempty"` // Where to recover an unreadable database from, in order: remote, backups, oplog, or a file or directory
+
+	// Per-device signing (optional)
+	SignChanges bool              `json:"sign_changes,omitempty"` // Sign this device's entry changes with its own key (~/.magnet-handler/device.key)
+	DeviceKeys  map[string]string `json:"device_keys,omitempty"`  // Trusted public keys by device ID, as printed by devices key
+
+	MetadataTimeout  int `json:"metadata_timeout,omitempty"`   // Seconds to wait for torrent metadata (0 = default, <0 = skip)
+	AuthFailureLimit int `json:"auth_failure_limit,omitempty"` // Rejected logins before queuing without Deluge (0 = 3)
+	DeadAfterDays    int `json:"dead_after_days,omitempty"`    // Skip trackerless retry entries failing this long (0 = never)
+
+	Trackers        []string `json:"trackers,omitempty"`         // Announce URLs for refreshed retries
+	RefreshTrackers bool     `json:"refresh_trackers,omitempty"` // Retry with the stored hash and trackers instead of the original URI
+	RetryRawURI     bool     `json:"retry_raw_uri,omitempty"`    // Retry with the URI exactly as received instead of the normalized one
+	RetryInterval   int      `json:"retry_interval,omitempty"`   // Minutes between retry-queue drains in daemon mode (0 = off)
+	RetryBackoff    int      `json:"retry_backoff,omitempty"`    // Minutes before a failed entry is retried, doubling per attempt (0 = 5, <0 = retry every run)
+	MaxRetries      int      `json:"max_retries,omitempty"`      // Failed retries before an entry moves to the failed section (0 = never)
+	SyncInterval    int      `json:"sync_interval,omitempty"`    // Minutes between remote syncs in daemon mode (0 = off)
+	SyncEvery       int      `json:"sync_every,omitempty"`       // Also sync after this many database changes in daemon mode (0 = off)
+	OplogRetention  int      `json:"oplog_retention,omitempty"`  // Days of operations the daemon keeps in full before folding older ones into the oplog base (0 = keep all)
+
+	MaxEntries    int `json:"max_entries,omitempty"`     // Warn when the database holds more entries than this (0 = no limit)
+	MaxDatabaseMB int `json:"max_database_mb,omitempty"` // Warn when the database file grows past this many MiB (0 = no limit)
+
+	BlocklistSources  []string `json:"blocklist_sources,omitempty"`  // URLs or files listing info hashes to merge into ~/.magnet-handler/blocklist.txt
+	BlocklistInterval int      `json:"blocklist_interval,omitempty"` // Hours between blocklist imports in daemon mode (0 = off)
+
+	// Daemon backpressure (optional)
+	MaxQueueDepth    int `json:"max_queue_depth,omitempty"`    // Pending jobs before submissions get 429 (0 = 20)
+	MaxDelugeLatency int `json:"max_deluge_latency,omitempty"` // Milliseconds of Deluge latency before 429 (0 = 5000)
+
+	// Deluge connection pooling (optional)
+	HTTPMaxIdleConns int  `json:"http_max_idle_conns,omitempty"` // Idle keep-alive connections to keep (0 = 16)
+	HTTPIdleTimeout  int  `json:"http_idle_timeout,omitempty"`   // Seconds to keep idle connections (0 = 90, <0 = no keep-alive)
+	DisableHTTP2     bool `json:"disable_http2,omitempty"`       // Stay on HTTP/1.1 for HTTPS proxies
+
+	LabelOptions  map[string]LabelOptions `json:"label_options,omitempty"`  // Per-label add-torrent overrides
+	TrackerLabels map[string]string       `json:"tracker_labels,omitempty"` // Label for magnets announcing to a tracker host or its subdomains, unless --label is given
+	ConfirmAdds   bool                    `json:"confirm_adds,omitempty"`   // Ask with an Add/Cancel dialog and a label choice before adding a clicked magnet
+
+	BrowserOrigins []string `json:"browser_origins,omitempty"` // Sites Chrome and Edge may open magnet links from without asking, e.g. https://tracker.example (empty = always ask)
+
+	DisableAddNotifications bool `json:"disable_add_notifications,omitempty"` // No desktop notification with the result of adding a clicked magnet
+
+	ListColumns []string              `json:"list_columns,omitempty"` // Default columns for list output (e.g. hash, title, status, added)
+	Views       map[string]ViewConfig `json:"views,omitempty"`        // Saved filters for list --view, the dashboard, and notifications
+
+	Targets []TargetConfig `json:"targets,omitempty"` // Extra clients every magnet is also sent to
+	Handoff *HandoffConfig `json:"handoff,omitempty"` // Pull completed downloads from a seedbox
+
+	SortCompleted   *SortConfig   `json:"sort_completed,omitempty"`   // File completed downloads into {label}/{year}-{month} folders
+	VerifyCompleted *VerifyConfig `json:"verify_completed,omitempty"` // Check completed downloads for corruption before sorting
+
+	QuietHours *QuietHours `json:"quiet_hours,omitempty"` // Hold non-critical notifications overnight and send a summary after
+
+	ServeAddress   string        `json:"serve_address,omitempty"`   // Daemon listen address (default 127.0.0.1:8790)
+	WebhookSecret  string        `json:"webhook_secret,omitempty"`  // Shared secret for /api/webhook (empty = disabled)
+	CalendarSecret string        `json:"calendar_secret,omitempty"` // Secret for the /calendar.ics?secret= feed of completions (empty = disabled)
+	APIToken       string        `json:"api_token,omitempty"`       // Bearer token for the HTTP API (generated on first serve)
+	PairedTokens   []PairedToken `json:"paired_tokens,omitempty"`   // Extension tokens issued by the pair command
+	TLSCert        string        `json:"tls_cert,omitempty"`        // Certificate file to serve HTTPS (optional)
+	TLSKey         string        `json:"tls_key,omitempty"`         // Private key for tls_cert
+	HomeAssistant  bool          `json:"home_assistant,omitempty"`  // Serve /api/ha/ sensor and service endpoints
+
+	Secrets map[string]SecretSource `json:"secrets,omitempty"` // Where secrets come from instead of this file, e.g. {"deluge_password": {"backend": "keychain"}}
+
+	WebUIURL  string `json:"webui_url,omitempty"`  // Deluge Web UI address for links in output, notifications, and the dashboard (default http://deluge_host:deluge_port)
+	WebUILink string `json:"webui_link,omitempty"` // Link template with {url} and {hash} (default {url}/#torrent={hash})
+
+	opLogger *log.Logger // Logs one daemon request or job under its correlation ID (nil = standard logger)
+}
+
+// LabelOptions holds add-torrent overrides applied to torrents with a given label
+type LabelOptions struct {
+	StopAtRatio bool    `json:"stop_at_ratio,omitempty"`
+	StopRatio   float64 `json:"stop_ratio,omitempty"` // Implies stop_at_ratio when set
+
+	// File selection, applied once metadata resolves (globs match path or file name)
+	OnlyFiles []string `json:"only_files,omitempty"` // Download only matching files
+	SkipFiles []string `json:"skip_files,omitempty"` // Never download matching files
+
+	MaxActive int `json:"max_active,omitempty"` // Torrents downloading at once; extra adds start paused (0 = unlimited)
 }
 
 // MagnetEntry represents a tracked magnet link
 type MagnetEntry struct {
-	UUID          string `json:"uuid"`         // Unique UUID (preferred)
-	ID            int64  `json:"id,omitempty"` // Deprecated: old sequence ID for migration
-	Title         string `json:"title"`
-	Hash          string `json:"hash"`
-	URI           string `json:"uri"`
-	AddedDate     string `json:"added_date"`
-	FirstSeen     string `json:"first_seen,omitempty"`      // When first encountered
-	LastAttempt   string `json:"last_attempt,omitempty"`    // Last time we tried to add
-	Status        string `json:"status,omitempty"`          // success/failed
-	TorrentID     string `json:"torrent_id,omitempty"`      // Deluge's torrent ID
-	AddedToDeluge string `json:"added_to_deluge,omitempty"` // When Deluge accepted it
-	RetryCount    int    `json:"retry_count,omitempty"`
-	SavePath      string `json:"save_path,omitempty"`
-	TorrentName   string `json:"torrent_name,omitempty"`
+	UUID          string  `json:"uuid"`         // Unique UUID (preferred)
+	ID            int64   `json:"id,omitempty"` // Deprecated: old sequence ID for migration
+	Title         string  `json:"title"`
+	Hash          string  `json:"hash"`
+	URI           string  `json:"uri"`                // Normalized URI sent to clients
+	RawURI        string  `json:"raw_uri,omitempty"`  // Exact URI received, when normalizing changed it
+	SentURI       string  `json:"sent_uri,omitempty"` // Last URI sent on retry, when it differed from uri
+	AddedDate     string  `json:"added_date"`
+	FirstSeen     string  `json:"first_seen,omitempty"`      // When first encountered
+	LastAttempt   string  `json:"last_attempt,omitempty"`    // Last time we tried to add
+	Status        string  `json:"status,omitempty"`          // success, failed, or failed_permanent
+	ErrorKind     string  `json:"error_kind,omitempty"`      // Class of the last add failure (invalid_torrent, permission_denied, ...)
+	TorrentID     string  `json:"torrent_id,omitempty"`      // Deluge's torrent ID
+	AddedToDeluge string  `json:"added_to_deluge,omitempty"` // When Deluge accepted it
+	RetryCount    int     `json:"retry_count,omitempty"`
+	NextAttempt   string  `json:"next_attempt,omitempty"` // Retries skip the entry until then (backoff)
+	SavePath      string  `json:"save_path,omitempty"`
+	TorrentName   string  `json:"torrent_name,omitempty"`
+	Conflict      bool    `json:"conflict,omitempty"`      // Status diverged between machines
+	ConflictInfo  string  `json:"conflict_info,omitempty"` // What the other copy recorded
+	State         string  `json:"state,omitempty"`         // Deluge state (Downloading, Seeding, ...)
+	Progress      float64 `json:"progress,omitempty"`      // Download progress percentage
+	CompletedAt   string  `json:"completed_at,omitempty"`  // When the download was first seen complete
+	SortedTo      string  `json:"sorted_to,omitempty"`     // Dated folder the completed download was filed into
+	Verified      *bool   `json:"verified,omitempty"`      // Completed data passed verification (unset = not checked)
+	VerifiedAt    string  `json:"verified_at,omitempty"`
+	VerifyPending bool    `json:"verify_pending,omitempty"` // Recheck running in Deluge
+	RemovedAt     string  `json:"removed_at,omitempty"`     // When the torrent was removed from Deluge
+	Label         string  `json:"label,omitempty"`          // Deluge label applied (or to apply on retry)
+	Held          bool    `json:"held,omitempty"`           // Added paused by the label's max_active, resumed by check-complete
+
+	Targets map[string]TargetStatus `json:"targets,omitempty"` // Per-client outcome when fanning out
+
+	TransferStatus   string `json:"transfer_status,omitempty"` // Seedbox handoff: done or failed
+	TransferAttempts int    `json:"transfer_attempts,omitempty"`
+	TransferredAt    string `json:"transferred_at,omitempty"`
+	TransferError    string `json:"transfer_error,omitempty"`
+
+	History []HistoryEvent `json:"history,omitempty"` // Recent status changes, oldest first
+
+	Device    string `json:"device,omitempty"`    // Installation that last changed the entry
+	Counter   int64  `json:"counter,omitempty"`   // That device's change counter at the time
+	Signature string `json:"signature,omitempty"` // That device's Ed25519 signature over the change (sign_changes)
 }
 
 // DatabaseMetadata tracks sync state
 type DatabaseMetadata struct {
-	LastSequence int64  `json:"last_sequence"` // Highest ID assigned
-	LastModified string `json:"last_modified"` // Timestamp of last write
-	Checksum     string `json:"checksum"`      // Hash of added+retry for conflict detection
+	SchemaVersion     int              `json:"schema_version,omitempty"`     // On-disk layout version (0 = written before versioning)
+	LastSequence      int64            `json:"last_sequence"`                // Highest ID assigned
+	Clock             map[string]int64 `json:"clock,omitempty"`              // Highest change counter seen from each device
+	LastModified      string           `json:"last_modified"`                // Timestamp of last write
+	Checksum          string           `json:"checksum"`                     // Hash of added+retry for conflict detection
+	ChecksumAlgorithm string           `json:"checksum_algorithm,omitempty"` // sha1 or sha256 (empty = legacy)
+	Signature         string           `json:"signature,omitempty"`          // HMAC-SHA256 with shared signing key
 }
 
 // MagnetDatabase represents the JSON structure (current version)
 type MagnetDatabase struct {
-	Metadata DatabaseMetadata       `json:"metadata"`
-	Added    map[string]MagnetEntry `json:"added"` // Successfully added or duplicates
-	Retry    map[string]MagnetEntry `json:"retry"` // Failed, needs retry
+	Metadata   DatabaseMetadata       `json:"metadata"`
+	Added      map[string]MagnetEntry `json:"added"`                // Successfully added or duplicates
+	Retry      map[string]MagnetEntry `json:"retry"`                // Failed, needs retry
+	Removed    map[string]MagnetEntry `json:"removed,omitempty"`    // Removed from Deluge, kept for history
+	Tombstones map[string]Tombstone   `json:"tombstones,omitempty"` // Deleted outright; merges must not resurrect
 }
 
 // Legacy formats for migration
@@ -118,8 +248,8 @@ func DefaultConfig() Config {
 		DelugePort:     "8112",
 		DelugePassword: "deluge",
 		DelugeLabel:    "audiobooks",
-		JSONPath:       filepath.Join(homeDir, "magnet-list-local.json"), // Local by default
-		RemotePath:     GetDefaultRemotePath(),                           // Platform-specific default
+		JSONPath:       defaultDatabasePath(homeDir), // Local by default
+		RemotePath:     GetDefaultRemotePath(),       // Platform-specific default
 	}
 }
 
@@ -132,27 +262,14 @@ func GetRemotePath(config *Config) string {
 	return GetDefaultRemotePath()
 }
 
-// ComputeChecksum generates SHA1 hash of database contents
+// ComputeChecksum generates a hash of database contents using the configured algorithm
 func ComputeChecksum(db *MagnetDatabase) string {
-	// Marshal to JSON for consistent hashing
-	data, err := json.Marshal(db)
-	if err != nil {
-		return ""
-	}
-
-	// Compute SHA1 hash
-	hash := sha1.Sum(data)
-	return hex.EncodeToString(hash[:])
+	return computeChecksumWith(db, integrity.Algorithm)
 }
 
-// ComputeFileChecksum computes SHA1 hash of file contents on disk
+// ComputeFileChecksum computes a hash of file contents on disk
 func ComputeFileChecksum(path string) (string, error) {
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return "", err
-	}
-	hash := sha1.Sum(data)
-	return hex.EncodeToString(hash[:]), nil
+	return hashFile(path, integrity.Algorithm)
 }
 
 // GenerateUUID generates a RFC4122 v4 UUID
@@ -182,7 +299,9 @@ func MigrateFileFormat(path string) error {
 	}
 
 	// Load the file (will handle legacy format)
-	db, err := LoadJSONDatabase(path)
+	db, err := LoadJSONDatabaseWithProgress(path, func(entries int) {
+		log.Printf("  Read %d entries...", entries)
+	})
 	if err != nil {
 		return fmt.Errorf("failed to load database: %w", err)
 	}
@@ -212,21 +331,116 @@ func MigrateFileFormat(path string) error {
 		log.Printf("Generated %d UUIDs for existing entries", uuidsGenerated)
 	}
 
-	db.Metadata.LastModified = time.Now().Format(time.RFC3339)
+	// Snapshot the original before touching anything
+	backupPath := ""
+	if oldChecksum != "" {
+		backupPath, err = snapshotFile(path, oldChecksum)
+		if err != nil {
+			return fmt.Errorf("failed to back up original: %w", err)
+		}
+		log.Printf("Backed up original to: %s", backupPath)
+	}
+
+	// Write the new format under a temporary name
+	stampMetadata(db)
+	migratedPath := path + ".migrating"
+	total := len(db.Added) + len(db.Retry) + len(db.Removed)
+	err = writeDatabaseFile(migratedPath, db, func(entries int) {
+		log.Printf("  Wrote %d/%d entries (%d%%)...", entries, total, entries*100/total)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write migrated file: %w", err)
+	}
+
+	// Reload the new file and compare it against what we read before swapping
+	migrated, err := LoadJSONDatabase(migratedPath)
+	if err == nil {
+		err = verifyMigration(db, migrated)
+	}
+	if err == nil && oldChecksum != "" {
+		// Abort if another process wrote the original while we were migrating
+		if current, _ := ComputeFileChecksum(path); current != oldChecksum {
+			err = fmt.Errorf("original file changed during migration")
+		}
+	}
+	if err != nil {
+		os.Remove(migratedPath)
+		return fmt.Errorf("verification failed, original left intact: %w", err)
+	}
 
-	// Save with new format
-	if err := SaveDatabaseLocal(path, db); err != nil {
-		return fmt.Errorf("failed to save migrated file: %w", err)
+	if err := os.Rename(migratedPath, path); err != nil {
+		os.Remove(migratedPath)
+		return fmt.Errorf("failed to replace original: %w", err)
 	}
+	updateIndex(path, db)
 
 	// Compute new file checksum AFTER saving
 	newFileChecksum, _ := ComputeFileChecksum(path)
 
-	log.Printf("✓ Migrated successfully")
+	log.Printf("✓ Migrated successfully (verified %d entries)", total)
 	log.Printf("  Data checksum: %s", db.Metadata.Checksum)
 	log.Printf("  File checksum: %s", newFileChecksum)
 	log.Printf("  Last sequence: %d", db.Metadata.LastSequence)
+	if backupPath != "" {
+		log.Printf("  Original kept at: %s", backupPath)
+	}
+
+	return nil
+}
+
+// snapshotFile copies path to a timestamped backup next to it and verifies
+// the copy against the expected checksum
+func snapshotFile(path, expectedChecksum string) (string, error) {
+	backupPath := fmt.Sprintf("%s.pre-migrate-%s.bak", path, time.Now().Format("20060102-150405"))
+	if err := copyFile(path, backupPath); err != nil {
+		return "", err
+	}
+	if checksum, err := ComputeFileChecksum(backupPath); err != nil || checksum != expectedChecksum {
+		os.Remove(backupPath)
+		return "", fmt.Errorf("backup copy does not match original")
+	}
+	return backupPath, nil
+}
+
+// copyFile copies a file's contents to dst, replacing it if it exists
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
 
+// verifyMigration checks that a migrated database holds exactly the same
+// entries, in the same sections, as the database it was produced from
+func verifyMigration(original, migrated *MagnetDatabase) error {
+	originalSections := databaseSections(original)
+	migratedSections := databaseSections(migrated)
+	for i, section := range originalSections {
+		other := migratedSections[i].entries
+		if len(section.entries) != len(other) {
+			return fmt.Errorf("%s: expected %d entries, found %d", section.name, len(section.entries), len(other))
+		}
+		for hash, entry := range section.entries {
+			got, ok := other[hash]
+			if !ok {
+				return fmt.Errorf("%s: missing entry %s", section.name, hash)
+			}
+			if got.Hash != entry.Hash || got.URI != entry.URI || got.UUID != entry.UUID {
+				return fmt.Errorf("%s: entry %s differs after migration", section.name, hash)
+			}
+		}
+	}
 	return nil
 }
 
@@ -254,11 +468,7 @@ func LoadConfig() (Config, error) {
 	newConfigPath := filepath.Join(homeDir, ".magnet-handler", "mh.yaml")
 	data, err := os.ReadFile(newConfigPath)
 	if err == nil {
-		var config Config
-		if err := json.Unmarshal(data, &config); err != nil {
-			return DefaultConfig(), err
-		}
-		return config, nil
+		return parseConfig(newConfigPath, data)
 	}
 
 	// Fall back to old location: ~/.magnet-handler.conf
@@ -269,12 +479,7 @@ func LoadConfig() (Config, error) {
 		return DefaultConfig(), nil
 	}
 
-	var config Config
-	if err := json.Unmarshal(data, &config); err != nil {
-		return DefaultConfig(), err
-	}
-
-	return config, nil
+	return parseConfig(oldConfigPath, data)
 }
 
 // SaveConfig saves configuration to file in ~/.magnet-handler/mh.yaml
@@ -284,6 +489,11 @@ func SaveConfig(config Config) error {
 		return err
 	}
 
+	// Keep a SOPS-encrypted config encrypted, wherever LoadConfig found it
+	if path, encrypted := encryptedConfigPath(homeDir); encrypted {
+		return saveSOPSConfig(path, config)
+	}
+
 	// Create .magnet-handler directory if it doesn't exist
 	mhDir := filepath.Join(homeDir, ".magnet-handler")
 	if err := os.MkdirAll(mhDir, 0755); err != nil {
@@ -291,7 +501,7 @@ func SaveConfig(config Config) error {
 	}
 
 	configPath := filepath.Join(mhDir, "mh.yaml")
-	data, err := json.MarshalIndent(config, "", "  ")
+	data, err := encodeConfig(configPath, config)
 	if err != nil {
 		return err
 	}
@@ -301,25 +511,34 @@ func SaveConfig(config Config) error {
 
 // ValidateMagnetURI strictly validates a magnet URI to prevent injection
 func ValidateMagnetURI(uri string) bool {
+	return magnetURIProblem(uri) == ""
+}
+
+// magnetURIProblem returns why ValidateMagnetURI rejects uri, or "" if it
+// passes
+func magnetURIProblem(uri string) string {
 	// Must start with magnet:?
-	if !strings.HasPrefix(uri, "magnet:?") {
-		return false
+	query, ok := strings.CutPrefix(uri, "magnet:?")
+	if !ok {
+		return `does not start with "magnet:?"`
+	}
+	if query == "" {
+		return "has no parameters"
 	}
 
-	// Must contain xt parameter with btih hash
 	// Allow standard URL characters plus percent-encoding (% followed by hex digits)
 	// This covers all valid magnet URI characters including UTF-8 encoded sequences
-	validPattern := regexp.MustCompile(`^magnet:\?[a-zA-Z0-9:?&=%\-_.~+!,/()#\[\]]+$`)
-	if !validPattern.MatchString(uri) {
-		return false
+	for i := 0; i < len(query); i++ {
+		if !magnetSafeByte(query[i]) {
+			return fmt.Sprintf("character %q at position %d is not allowed", query[i], len("magnet:?")+i)
+		}
 	}
 
-	// Must have xt parameter
+	// Must have xt parameter with btih hash
 	if !strings.Contains(uri, "xt=urn:btih:") {
-		return false
+		return "has no xt=urn:btih: parameter"
 	}
-
-	return true
+	return ""
 }
 
 // ExtractMagnetHash extracts the info hash from a magnet URI
@@ -368,6 +587,26 @@ func ExtractMagnetName(uri string) string {
 
 // LoadJSONDatabase loads the JSON database file with retry logic
 func LoadJSONDatabase(path string) (*MagnetDatabase, error) {
+	return LoadJSONDatabaseWithProgress(path, nil)
+}
+
+// LoadJSONDatabaseWithProgress loads the database, streaming entries from
+// disk and calling progress periodically with the number of entries read.
+// An unreadable database is recovered from read_fallbacks when configured.
+func LoadJSONDatabaseWithProgress(path string, progress func(entries int)) (*MagnetDatabase, error) {
+	db, err := readDatabaseFile(path, progress)
+	var tooNew *SchemaTooNewError
+	if db == nil && !errors.As(err, &tooNew) {
+		if recovered, ok := recoverDatabase(path, err); ok {
+			return recovered, nil
+		}
+	}
+	return db, err
+}
+
+// readDatabaseFile reads and decodes one database file. A nil database
+// means the file was read but could not be parsed.
+func readDatabaseFile(path string, progress func(entries int)) (*MagnetDatabase, error) {
 	db := &MagnetDatabase{
 		Metadata: DatabaseMetadata{},
 		Added:    make(map[string]MagnetEntry),
@@ -379,124 +618,199 @@ func LoadJSONDatabase(path string) (*MagnetDatabase, error) {
 	}
 
 	// Try multiple times with backoff
+	var file *os.File
 	for attempt := 0; attempt < 5; attempt++ {
-		data, err := os.ReadFile(path)
-		if err != nil {
-			if attempt < 4 {
-				time.Sleep(time.Duration(attempt+1) * 500 * time.Millisecond)
-				continue
-			}
-			return db, err
-		}
-
-		// Try to parse as current format first
-		err = json.Unmarshal(data, db)
-		if err == nil && (len(db.Added) > 0 || len(db.Retry) > 0) {
-			// Successfully parsed and got entries
-			return db, nil
-		}
-
-		// Try V0 format (Python version - flat map of hash->entry)
-		dbV0 := make(map[string]MagnetEntryV0)
-		errV0 := json.Unmarshal(data, &dbV0)
-		if errV0 == nil && len(dbV0) > 0 {
-			nextID := int64(1)
-			for hash, v0 := range dbV0 {
-				// Use URI from V0 if present, otherwise construct from hash
-				uri := v0.URI
-				if uri == "" {
-					uri = fmt.Sprintf("magnet:?xt=urn:btih:%s", v0.Hash)
-				}
+		var err error
+		file, err = os.Open(path)
+		if err == nil {
+			break
+		}
+		if attempt < 4 {
+			time.Sleep(time.Duration(attempt+1) * 500 * time.Millisecond)
+			continue
+		}
+		return db, err
+	}
+	defer file.Close()
 
-				// All V0 entries go into "added" since Python version didn't track retry
-				db.Added[hash] = MagnetEntry{
-					UUID:          GenerateUUID(),
-					ID:            nextID,
-					Title:         v0.Title,
-					Hash:          v0.Hash,
-					URI:           uri,
-					AddedDate:     v0.FirstSeen,
-					FirstSeen:     v0.FirstSeen,
-					LastAttempt:   v0.LastAttempt,
-					Status:        v0.Status,
-					TorrentID:     v0.TorrentID,
-					AddedToDeluge: v0.AddedToDeluge,
-					SavePath:      v0.SavePath,
-					TorrentName:   v0.TorrentName,
-				}
-				nextID++
-			}
-			db.Metadata.LastSequence = nextID - 1
-			db.Metadata.Checksum = ComputeChecksum(db)
-
-			log.Printf("Loaded V0 format (Python): %d entries (use --migrate)", len(db.Added))
-			return db, nil
-		}
-
-		// Try V1 format (no metadata, no IDs, but has added/retry)
-		dbV1 := &MagnetDatabaseV1{
-			Added: make(map[string]MagnetEntryV1),
-			Retry: make(map[string]MagnetEntryV1),
-		}
-		errV1 := json.Unmarshal(data, dbV1)
-		if errV1 == nil && (len(dbV1.Added) > 0 || len(dbV1.Retry) > 0) {
-			nextID := int64(1)
-			for hash, v1 := range dbV1.Added {
-				db.Added[hash] = MagnetEntry{
-					UUID: GenerateUUID(), ID: nextID, Title: v1.Title, Hash: v1.Hash, URI: v1.URI,
-					AddedDate: v1.AddedDate, LastAttempt: v1.LastAttempt,
-					RetryCount: v1.RetryCount, SavePath: v1.SavePath, TorrentName: v1.TorrentName,
-				}
-				nextID++
-			}
-			for hash, v1 := range dbV1.Retry {
-				db.Retry[hash] = MagnetEntry{
-					UUID: GenerateUUID(), ID: nextID, Title: v1.Title, Hash: v1.Hash, URI: v1.URI,
-					AddedDate: v1.AddedDate, LastAttempt: v1.LastAttempt,
-					RetryCount: v1.RetryCount, SavePath: v1.SavePath, TorrentName: v1.TorrentName,
-				}
+	size := int64(0)
+	if info, err := file.Stat(); err == nil {
+		size = info.Size()
+	}
+
+	format, err := decodeDatabase(file, db, progress)
+	if schemaErr := checkSchema(path, db.Metadata); schemaErr != nil {
+		return nil, schemaErr
+	}
+	if err != nil {
+		log.Printf("ERROR: Could not parse database %s: %v", path, err)
+		log.Printf("File size: %d bytes", size)
+		return nil, fmt.Errorf("unrecognized format: %w", err)
+	}
+
+	// SAFETY CHECK: If file is large but we got 0 entries, something is very wrong
+	totalEntries := len(db.Added) + len(db.Retry) + len(db.Removed)
+	if size > 1024 && totalEntries == 0 {
+		log.Printf("CRITICAL: File is %d bytes but parsed 0 entries!", size)
+		return nil, fmt.Errorf("parsed 0 entries from %d bytes", size)
+	}
+
+	switch format {
+	case formatV0:
+		db.Metadata.Checksum = ComputeChecksum(db)
+		log.Printf("Loaded V0 format (Python): %d entries (use the migrate command)", len(db.Added))
+	case formatV1:
+		nextID := int64(1)
+		for _, entries := range []map[string]MagnetEntry{db.Added, db.Retry} {
+			for hash, entry := range entries {
+				entry.UUID = GenerateUUID()
+				entry.ID = nextID
+				entries[hash] = entry
 				nextID++
 			}
-			db.Metadata.LastSequence = nextID - 1
-			db.Metadata.Checksum = ComputeChecksum(db)
+		}
+		db.Metadata.LastSequence = nextID - 1
+		db.Metadata.Checksum = ComputeChecksum(db)
+		log.Printf("Loaded V1 format: %d entries (use the migrate command)", totalEntries)
+	}
 
-			totalEntries := len(db.Added) + len(db.Retry)
-			if len(data) > 1024 && totalEntries == 0 {
-				log.Printf("CRITICAL: V1 parsed 0 entries from %d bytes!", len(data))
-				return nil, fmt.Errorf("V1 parsing failed: 0 entries")
-			}
+	return db, nil
+}
 
-			log.Printf("Loaded V1 format: %d entries (use --migrate)", totalEntries)
-			return db, nil
-		}
+// MergeConflict describes a hash whose status diverged between two databases
+type MergeConflict struct {
+	Hash   string
+	Title  string
+	Detail string
+}
 
-		// SAFETY CHECK: If file is large but all parsers got 0 entries, something is very wrong
-		if len(data) > 1024 {
-			log.Printf("CRITICAL: File is %d bytes but all parsers got 0 entries!", len(data))
-			log.Printf("Preview: %s", string(data[:min(200, len(data))]))
+// parseTimestamp parses an RFC3339 timestamp, returning the zero time if invalid
+func parseTimestamp(value string) time.Time {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// latestTimestamp returns the most recent of the entry's activity timestamps
+func latestTimestamp(entry MagnetEntry) time.Time {
+	latest := time.Time{}
+	for _, value := range []string{entry.AddedDate, entry.LastAttempt, entry.AddedToDeluge, entry.CompletedAt} {
+		if t := parseTimestamp(value); t.After(latest) {
+			latest = t
 		}
+	}
+	return latest
+}
+
+// detectConflict reports whether a retry entry on one machine diverges from an
+// added entry on the other. A retry recorded before the success is just stale
+// and resolves itself; a retry attempted after the success is a true conflict.
+func detectConflict(added, retry MagnetEntry) bool {
+	retryTime := parseTimestamp(retry.LastAttempt)
+	if retryTime.IsZero() {
+		return false
+	}
+	return retryTime.After(latestTimestamp(added))
+}
+
+// Tie-break policies for merge_tie_break, deciding between two copies of an
+// entry in the same section whose latest timestamps are equal
+const (
+	TieBreakID     = "id"     // Higher sequence ID wins (default)
+	TieBreakLocal  = "local"  // This machine's copy wins
+	TieBreakRemote = "remote" // The remote copy wins
+)
+
+// mergeTieBreak is the active tie-break policy, applied from config at startup
+var mergeTieBreak = TieBreakID
+
+// ConfigureMerge applies the merge tie-break policy from the config
+func ConfigureMerge(config Config) error {
+	switch config.MergeTieBreak {
+	case "":
+		mergeTieBreak = TieBreakID
+	case TieBreakID, TieBreakLocal, TieBreakRemote:
+		mergeTieBreak = config.MergeTieBreak
+	default:
+		return fmt.Errorf("unknown merge_tie_break %q (use %s, %s, or %s)",
+			config.MergeTieBreak, TieBreakID, TieBreakLocal, TieBreakRemote)
+	}
+	return nil
+}
+
+// mergeCandidate is one database's copy of an entry
+type mergeCandidate struct {
+	entry   MagnetEntry
+	isAdded bool
+	isLocal bool
+	clock   map[string]int64 // Clock of the database the copy came from
+}
 
-		log.Printf("ERROR: All parsers failed")
-		log.Printf("  Current format: %v", err)
-		log.Printf("  V0 (Python flat map): %v, entries=%d", errV0, len(dbV0))
-		log.Printf("  V1 (added/retry): %v, entries=%d", errV1, len(dbV1.Added)+len(dbV1.Retry))
-		log.Printf("File size: %d bytes", len(data))
-		return nil, fmt.Errorf("unrecognized format")
+// beats reports whether c should win over w. A copy written with knowledge
+// of the other always wins. For concurrent copies an added copy beats a
+// retry copy, since the torrent reached a client (a retry attempted
+// afterwards is flagged as a conflict instead). Between copies in the same
+// section the most recent activity wins, and policy settles exact ties.
+func (c mergeCandidate) beats(w mergeCandidate, policy string) bool {
+	switch {
+	case c.supersedes(w):
+		return true
+	case w.supersedes(c):
+		return false
+	}
+	if c.isAdded != w.isAdded {
+		return c.isAdded
+	}
+	if ct, wt := latestTimestamp(c.entry), latestTimestamp(w.entry); !ct.Equal(wt) {
+		return ct.After(wt)
+	}
+	if c.isLocal != w.isLocal {
+		switch policy {
+		case TieBreakLocal:
+			return c.isLocal
+		case TieBreakRemote:
+			return !c.isLocal
+		}
 	}
+	return c.entry.ID > w.entry.ID
+}
 
-	return db, fmt.Errorf("failed to load JSON after retries")
+// newerRemoval returns the more recent of two removed entries (zero values
+// mean the hash was not removed on that side)
+func newerRemoval(a, b MagnetEntry) (MagnetEntry, bool) {
+	if a.RemovedAt == "" && b.RemovedAt == "" {
+		return MagnetEntry{}, false
+	}
+	if parseTimestamp(b.RemovedAt).After(parseTimestamp(a.RemovedAt)) {
+		return b, true
+	}
+	return a, true
 }
 
 // MergeDatabases intelligently merges two databases based on sequence numbers
 func MergeDatabases(local, remote *MagnetDatabase) *MagnetDatabase {
+	merged, _ := MergeDatabasesWithConflicts(local, remote)
+	return merged
+}
+
+// MergeDatabasesWithConflicts merges two databases and reports entries whose
+// status diverged between them. Conflicting entries are kept in Added but
+// flagged so they can be reviewed instead of silently resolved.
+func MergeDatabasesWithConflicts(local, remote *MagnetDatabase) (*MagnetDatabase, []MergeConflict) {
+	var conflicts []MergeConflict
 	merged := &MagnetDatabase{
-		Added: make(map[string]MagnetEntry),
-		Retry: make(map[string]MagnetEntry),
+		Added:   make(map[string]MagnetEntry),
+		Retry:   make(map[string]MagnetEntry),
+		Removed: make(map[string]MagnetEntry),
 	}
 
 	// Both databases will be fully merged based on IDs and timestamps
 
-	// Merge strategy: newer IDs win, for same ID take most recent timestamp
+	// Merge strategy: a copy that had seen the other wins (device clocks),
+	// then added beats retry, then the most recent activity wins, then
+	// merge_tie_break decides
 	allHashes := make(map[string]bool)
 	for hash := range local.Added {
 		allHashes[hash] = true
@@ -510,6 +824,18 @@ func MergeDatabases(local, remote *MagnetDatabase) *MagnetDatabase {
 	for hash := range remote.Retry {
 		allHashes[hash] = true
 	}
+	for hash := range local.Removed {
+		allHashes[hash] = true
+	}
+	for hash := range remote.Removed {
+		allHashes[hash] = true
+	}
+	for hash := range local.Tombstones {
+		allHashes[hash] = true
+	}
+	for hash := range remote.Tombstones {
+		allHashes[hash] = true
+	}
 
 	nextID := int64(1)
 	for hash := range allHashes {
@@ -519,34 +845,71 @@ func MergeDatabases(local, remote *MagnetDatabase) *MagnetDatabase {
 		remoteAdded, inRemoteAdded := remote.Added[hash]
 		remoteRetry, inRemoteRetry := remote.Retry[hash]
 
-		var winner MagnetEntry
-		var inAdded bool
-
-		// Priority: Added > Retry, Higher ID > Lower ID, Newer timestamp > Older
 		candidates := []struct {
-			entry   MagnetEntry
-			isAdded bool
-			exists  bool
+			mergeCandidate
+			exists bool
 		}{
-			{localAdded, true, inLocalAdded},
-			{localRetry, false, inLocalRetry},
-			{remoteAdded, true, inRemoteAdded},
-			{remoteRetry, false, inRemoteRetry},
+			{mergeCandidate{localAdded, true, true, local.Metadata.Clock}, inLocalAdded},
+			{mergeCandidate{localRetry, false, true, local.Metadata.Clock}, inLocalRetry},
+			{mergeCandidate{remoteAdded, true, false, remote.Metadata.Clock}, inRemoteAdded},
+			{mergeCandidate{remoteRetry, false, false, remote.Metadata.Clock}, inRemoteRetry},
 		}
 
+		var best mergeCandidate
 		winnerFound := false
 		for _, c := range candidates {
-			if !c.exists {
-				continue
+			if c.exists && (!winnerFound || c.beats(best, mergeTieBreak)) {
+				best, winnerFound = c.mergeCandidate, true
+			}
+		}
+		winner, inAdded := best.entry, best.isAdded
+
+		// Flag true conflicts: added on one machine, retried later on the
+		// other without having seen the add
+		var added, retry mergeCandidate
+		diverged := false
+		if inLocalAdded && inRemoteRetry && !inRemoteAdded {
+			added, retry, diverged = candidates[0].mergeCandidate, candidates[3].mergeCandidate, true
+		} else if inRemoteAdded && inLocalRetry && !inLocalAdded {
+			added, retry, diverged = candidates[2].mergeCandidate, candidates[1].mergeCandidate, true
+		}
+		if diverged && (added.supersedes(retry) || retry.supersedes(added)) {
+			diverged = false
+		}
+		if winnerFound && diverged && detectConflict(added.entry, retry.entry) {
+			detail := fmt.Sprintf("retry attempt #%d at %s after being added on %s",
+				retry.entry.RetryCount, retry.entry.LastAttempt, added.entry.AddedDate)
+			if !winner.Conflict || winner.ConflictInfo != detail {
+				conflicts = append(conflicts, MergeConflict{Hash: hash, Title: winner.Title, Detail: detail})
+			}
+			winner.Conflict = true
+			winner.ConflictInfo = detail
+		}
+
+		// A removal wins unless the hash was added or retried again afterwards
+		inRemoved := false
+		if removed, isRemoved := newerRemoval(local.Removed[hash], remote.Removed[hash]); isRemoved {
+			if !winnerFound || !latestTimestamp(winner).After(parseTimestamp(removed.RemovedAt)) {
+				winner, winnerFound, inRemoved = removed, true, true
 			}
-			if !winnerFound || c.isAdded && !inAdded || c.entry.ID > winner.ID {
-				winner = c.entry
-				inAdded = c.isAdded
-				winnerFound = true
+		}
+
+		// A tombstone drops the entry unless it came back after the deletion
+		if tomb, isBuried := newerTombstone(local.Tombstones[hash], remote.Tombstones[hash]); isBuried {
+			if !winnerFound || buriedBy(winner, tomb) {
+				if merged.Tombstones == nil {
+					merged.Tombstones = make(map[string]Tombstone)
+				}
+				merged.Tombstones[hash] = tomb
+				winnerFound = false
 			}
 		}
 
 		if winnerFound {
+			// Keep what every machine recorded about the entry
+			winner.History = mergeHistory(localAdded.History, localRetry.History, remoteAdded.History,
+				remoteRetry.History, local.Removed[hash].History, remote.Removed[hash].History)
+
 			// Assign new sequential ID if needed
 			if winner.ID == 0 {
 				winner.ID = nextID
@@ -557,9 +920,12 @@ func MergeDatabases(local, remote *MagnetDatabase) *MagnetDatabase {
 				}
 			}
 
-			if inAdded {
+			switch {
+			case inRemoved:
+				merged.Removed[hash] = winner
+			case inAdded:
 				merged.Added[hash] = winner
-			} else {
+			default:
 				merged.Retry[hash] = winner
 			}
 		}
@@ -567,17 +933,35 @@ func MergeDatabases(local, remote *MagnetDatabase) *MagnetDatabase {
 
 	// Update metadata
 	merged.Metadata.LastSequence = nextID - 1
+	merged.Metadata.Clock = mergeClocks(local.Metadata.Clock, remote.Metadata.Clock)
 	merged.Metadata.LastModified = time.Now().Format(time.RFC3339)
 	merged.Metadata.Checksum = ComputeChecksum(merged)
 
-	return merged
+	return merged, conflicts
+}
+
+// notifyConflicts surfaces merge conflicts to the user
+func notifyConflicts(conflicts []MergeConflict) {
+	if len(conflicts) == 0 {
+		return
+	}
+	lines := make([]string, 0, len(conflicts))
+	for _, c := range conflicts {
+		lines = append(lines, fmt.Sprintf("%s - %s: %s", c.Hash[:min(8, len(c.Hash))], c.Title, c.Detail))
+	}
+	lines = append(lines, "Review with: magnet-handler conflicts")
+	Notify(NotifyWarning, fmt.Sprintf("Sync conflict: %d entries diverged between machines", len(conflicts)),
+		strings.Join(lines, "\n"))
 }
 
 // SyncWithRemote syncs local database with remote, returns merged result
 func SyncWithRemote(localPath, remotePath string) (*MagnetDatabase, error) {
+	// rclone, WebDAV, and other remotes are read from a freshly fetched local copy
+	remoteFile := fetchRemote(remotePath)
+
 	// Compute file checksums BEFORE loading/parsing
 	localFileChecksum, localFileErr := ComputeFileChecksum(localPath)
-	remoteFileChecksum, remoteFileErr := ComputeFileChecksum(remotePath)
+	remoteFileChecksum, remoteFileErr := ComputeFileChecksum(remoteFile)
 
 	// If file checksums match, no need to merge
 	if localFileErr == nil && remoteFileErr == nil && localFileChecksum == remoteFileChecksum && len(localFileChecksum) >= 8 {
@@ -591,6 +975,10 @@ func SyncWithRemote(localPath, remotePath string) (*MagnetDatabase, error) {
 
 	// Load local
 	local, err := LoadJSONDatabase(localPath)
+	var tooNew *SchemaTooNewError
+	if errors.As(err, &tooNew) {
+		return nil, err
+	}
 	if err != nil {
 		log.Printf("Warning: Failed to load local DB: %v", err)
 		local = &MagnetDatabase{
@@ -600,12 +988,22 @@ func SyncWithRemote(localPath, remotePath string) (*MagnetDatabase, error) {
 	}
 
 	// Try to load remote
-	remote, err := LoadJSONDatabase(remotePath)
+	remote, err := LoadJSONDatabase(remoteFile)
+	if errors.As(err, &tooNew) {
+		log.Printf("⚠ %v", err)
+		return local, nil
+	}
 	if err != nil {
 		log.Printf("Remote DB not accessible, using local only")
 		return local, nil
 	}
 
+	// Refuse to merge a corrupted or foreign remote file
+	if err := VerifyDatabase(remote); err != nil {
+		notifyIntegrityFailure(remotePath, err)
+		return local, nil
+	}
+
 	// Files differ, need to merge
 	localPreview := "empty"
 	remotePreview := "empty"
@@ -618,7 +1016,8 @@ func SyncWithRemote(localPath, remotePath string) (*MagnetDatabase, error) {
 	log.Printf("Files differ: local=%s remote=%s", localPreview, remotePreview)
 
 	log.Printf("Merging: Local(seq=%d) + Remote(seq=%d)", local.Metadata.LastSequence, remote.Metadata.LastSequence)
-	merged := MergeDatabases(local, remote)
+	merged, conflicts := MergeDatabasesWithConflicts(local, remote)
+	notifyConflicts(conflicts)
 	log.Printf("Merged: %d added, %d retry (seq=%d)", len(merged.Added), len(merged.Retry), merged.Metadata.LastSequence)
 
 	return merged, nil
@@ -626,44 +1025,84 @@ func SyncWithRemote(localPath, remotePath string) (*MagnetDatabase, error) {
 
 // SaveDatabaseLocal saves database to local path only (fast)
 func SaveDatabaseLocal(path string, db *MagnetDatabase) error {
-	// Update metadata
-	db.Metadata.LastModified = time.Now().Format(time.RFC3339)
-	db.Metadata.Checksum = ComputeChecksum(db)
+	return saveDatabaseWithProgress(path, db, nil)
+}
 
-	// Write to temp file first, then rename (atomic)
-	tempPath := path + ".tmp"
-	data, err := json.MarshalIndent(db, "", "  ")
-	if err != nil {
-		return err
-	}
+// saveDatabaseWithProgress saves the database, calling progress periodically
+// with the number of entries written
+func saveDatabaseWithProgress(path string, db *MagnetDatabase, progress func(entries int)) error {
+	stampMetadata(db)
 
-	err = os.WriteFile(tempPath, data, 0644)
-	if err != nil {
+	// Stream to temp file first, then rename (atomic)
+	if err := writeDatabaseFile(path, db, progress); err != nil {
 		return err
 	}
 
-	// Atomic rename
-	err = os.Rename(tempPath, path)
+	updateIndex(path, db)
+
+	return nil
+}
+
+// stampMetadata updates the modification time, checksum, and signature before a write
+func stampMetadata(db *MagnetDatabase) {
+	db.Metadata.SchemaVersion = currentSchemaVersion
+	db.Metadata.LastModified = time.Now().Format(time.RFC3339)
+	db.Metadata.ChecksumAlgorithm = integrity.Algorithm
+	db.Metadata.Checksum = ComputeChecksum(db)
+	db.Metadata.Signature = SignDatabase(db)
+}
+
+// SaveJSONDatabase saves database locally with smart sync logic. Tombstones
+// in updates delete their entries outright.
+func SaveJSONDatabase(localPath string, updates *MagnetDatabase, config *Config) error {
+	databaseMu.Lock()
+	defer databaseMu.Unlock()
+	remotes := GetRemotePaths(config)
+
+	merged, err := saveLocalDatabase(localPath, updates, config)
 	if err != nil {
-		os.Remove(tempPath)
 		return err
 	}
 
+	// Try to copy to the remotes (best effort, don't fail if network issue)
+	saveRemotes(remotes, merged)
+
 	return nil
 }
 
-// SaveJSONDatabase saves database locally with smart sync logic
-func SaveJSONDatabase(localPath string, updates *MagnetDatabase, config *Config) error {
+// saveLocalDatabase merges updates into the local database and saves it,
+// holding the lock beside it so other processes' journaled updates are
+// neither overwritten nor compacted away unsaved. It returns the saved
+// database for the remotes.
+func saveLocalDatabase(localPath string, updates *MagnetDatabase, config *Config) (*MagnetDatabase, error) {
+	logger := config.logger()
+	localPath, release, err := lockLocalDatabase(localPath)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	remotes := GetRemotePaths(config)
 	remotePath := GetRemotePath(config)
 
-	// Load and sync with remote first
-	merged, err := SyncWithRemote(localPath, remotePath)
+	// Journal the updates first so a crash before the save completes cannot
+	// lose them; the next load or save replays whatever is left
+	ops := journalOps(updates)
+	if err := appendJournal(localPath, ops); err != nil {
+		logger.Printf("Warning: Could not write journal: %v", err)
+	}
+
+	// Load and sync with the remotes first
+	merged, err := syncWithRemotes(localPath, remotes)
 	if err != nil {
-		log.Printf("Warning: Sync failed: %v", err)
+		logger.Printf("Warning: Sync failed: %v", err)
 		// Try to at least load local
 		merged, err = LoadJSONDatabase(localPath)
+		var tooNew *SchemaTooNewError
+		if errors.As(err, &tooNew) {
+			return nil, err
+		}
 		if err != nil {
-			log.Printf("Warning: Could not load local either, starting fresh")
+			logger.Printf("Warning: Could not load local either, starting fresh")
 			merged = &MagnetDatabase{
 				Metadata: DatabaseMetadata{},
 				Added:    make(map[string]MagnetEntry),
@@ -674,51 +1113,37 @@ func SaveJSONDatabase(localPath string, updates *MagnetDatabase, config *Config)
 
 	// Safety check: if merged database is empty but remote has data, use remote
 	if len(merged.Added) == 0 && len(merged.Retry) == 0 && remotePath != "" {
-		log.Printf("Warning: Loaded database is empty, checking remote...")
-		remote, err := LoadJSONDatabase(remotePath)
+		logger.Printf("Warning: Loaded database is empty, checking remote...")
+		remote, err := LoadJSONDatabase(remoteReadPath(remotePath))
 		if err == nil && (len(remote.Added) > 0 || len(remote.Retry) > 0) {
-			log.Printf("Found %d entries in remote, using that instead", len(remote.Added)+len(remote.Retry))
-			merged = remote
+			if verifyErr := VerifyDatabase(remote); verifyErr != nil {
+				notifyIntegrityFailure(remotePath, verifyErr)
+			} else {
+				logger.Printf("Found %d entries in remote, using that instead", len(remote.Added)+len(remote.Retry))
+				merged = remote
+			}
 		}
 	}
 
-	// Apply updates to merged database
-	nextID := merged.Metadata.LastSequence + 1
-	for hash, entry := range updates.Added {
-		if entry.ID == 0 {
-			entry.ID = nextID
-			nextID++
-		}
-		merged.Added[hash] = entry
-		// Remove from retry if exists
-		delete(merged.Retry, hash)
-	}
-	for hash, entry := range updates.Retry {
-		if entry.ID == 0 {
-			entry.ID = nextID
-			nextID++
-		}
-		merged.Retry[hash] = entry
-	}
-	merged.Metadata.LastSequence = nextID - 1
+	// Fold in the journal: these updates plus any left by a save that never
+	// finished. Updates the journal does not hold are applied directly.
+	folded := replayJournal(localPath, merged)
+	applied := append(folded, applyMissingOps(merged, folded, ops)...)
+	buryEntries(merged, updates.Tombstones)
 
 	// Save locally (fast, no network)
 	if err := SaveDatabaseLocal(localPath, merged); err != nil {
-		return fmt.Errorf("failed to save local: %w", err)
+		return nil, fmt.Errorf("failed to save local: %w", err)
 	}
-	log.Printf("Saved to local: %s", localPath)
-
-	// Try to copy to remote (best effort, don't fail if network issue)
-	if remotePath != "" {
-		if err := SaveDatabaseLocal(remotePath, merged); err != nil {
-			log.Printf("Warning: Could not sync to remote: %v", err)
-			log.Printf("Changes saved locally, will sync on next operation")
-		} else {
-			log.Printf("Synced to remote: %s", remotePath)
-		}
+	logger.Printf("Saved to local: %s", localPath)
+	warnDatabaseCaps(localPath, merged, config)
+	if err := recordOplog(localPath, merged, applied); err != nil {
+		logger.Printf("Warning: Could not append to the operation log: %v", err)
 	}
-
-	return nil
+	if err := compactJournal(localPath, folded); err != nil {
+		logger.Printf("Warning: Could not compact journal: %v", err)
+	}
+	return merged, nil
 }
 
 // DelugeClient handles communication with Deluge Web API
@@ -729,168 +1154,311 @@ type DelugeClient struct {
 	BaseURL    string
 	HTTPClient *http.Client
 	Cookie     string
+	Simulate   bool // Answer requests locally without contacting Deluge
+
+	DaemonVersion string // Reported by the daemon on connect
+	APIVersion    int    // DelugeAPIv1 or DelugeAPIv2, detected on connect
+
+	labelPlugin        string // Cached result of LabelPlugin
+	labelPluginChecked bool
 }
 
 // NewDelugeClient creates a new Deluge client
 func NewDelugeClient(host, port, password string) *DelugeClient {
 	return &DelugeClient{
-		Host:     host,
-		Port:     port,
-		Password: password,
-		BaseURL:  fmt.Sprintf("http://%s:%s/json", host, port),
-		HTTPClient: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+		Host:       host,
+		Port:       port,
+		Password:   password,
+		BaseURL:    fmt.Sprintf("http://%s:%s/json", host, port),
+		HTTPClient: sharedHTTPClient(),
+		Simulate:   simulateDeluge,
 	}
 }
 
-// makeRequest makes a JSON-RPC request to Deluge
-func (c *DelugeClient) makeRequest(method string, params []interface{}) (map[string]interface{}, error) {
-	requestBody := map[string]interface{}{
-		"method": method,
-		"params": params,
-		"id":     1,
+// Authenticate logs into Deluge
+func (c *DelugeClient) Authenticate() error {
+	var success bool
+	if err := c.rpc("auth.login", []interface{}{c.Password}, &success); err != nil {
+		return err
 	}
 
-	jsonData, err := json.Marshal(requestBody)
-	if err != nil {
-		return nil, err
+	if !success {
+		return errAuthRejected
 	}
 
-	req, err := http.NewRequest("POST", c.BaseURL, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, err
+	return nil
+}
+
+// Connect connects to Deluge daemon
+func (c *DelugeClient) Connect() error {
+	// Check if already connected
+	var connected bool
+	if err := c.rpc("web.connected", nil, &connected); err != nil {
+		return err
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	if c.Cookie != "" {
-		req.Header.Set("Cookie", c.Cookie)
+	if connected {
+		c.detectAPIVersion()
+		return nil
 	}
 
-	resp, err := c.HTTPClient.Do(req)
-	if err != nil {
-		return nil, err
+	// Get hosts
+	var hosts []delugeHost
+	if err := c.rpc("web.get_hosts", nil, &hosts); err != nil {
+		return err
 	}
-	defer resp.Body.Close()
 
-	// Save cookie from response
-	if cookies := resp.Cookies(); len(cookies) > 0 {
-		c.Cookie = cookies[0].String()
+	if len(hosts) == 0 {
+		return fmt.Errorf("no Deluge hosts available")
 	}
 
-	body, err := io.ReadAll(resp.Body)
+	// Connect to the first online host
+	hostID, err := c.pickHost(hosts)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	var result map[string]interface{}
-	if err := json.Unmarshal(body, &result); err != nil {
-		return nil, err
+	if err := c.rpc("web.connect", []interface{}{hostID}, nil); err != nil {
+		return fmt.Errorf("failed to connect to Deluge host: %w", err)
 	}
+	c.detectAPIVersion()
+	return nil
+}
 
-	return result, nil
+// AddTorrentOptions holds the per-torrent options sent with core.add_torrent_magnet
+type AddTorrentOptions struct {
+	DownloadLocation  string
+	AddPaused         bool
+	MaxDownloadSpeed  float64 // KiB/s, 0 = unlimited
+	MoveCompletedPath string
+	StopAtRatio       bool
+	StopRatio         float64
 }
 
-// Authenticate logs into Deluge
-func (c *DelugeClient) Authenticate() error {
-	result, err := c.makeRequest("auth.login", []interface{}{c.Password})
-	if err != nil {
-		return err
+// AddOptionsFromConfig builds add-torrent options from the configuration
+// for the configured default label
+func AddOptionsFromConfig(config Config) AddTorrentOptions {
+	return AddOptionsForLabel(config, config.DelugeLabel)
+}
+
+// AddOptionsForLabel builds add-torrent options from the configuration,
+// applying any overrides defined for the label
+func AddOptionsForLabel(config Config, label string) AddTorrentOptions {
+	opts := AddTorrentOptions{
+		DownloadLocation:  config.DownloadLocation,
+		AddPaused:         config.AddPaused,
+		MaxDownloadSpeed:  config.MaxDownloadSpeed,
+		MoveCompletedPath: config.MoveCompletedPath,
+	}
+	if labelOpts, ok := config.LabelOptions[label]; ok {
+		opts.StopAtRatio = labelOpts.StopAtRatio
+		opts.StopRatio = labelOpts.StopRatio
 	}
+	return opts
+}
 
-	if success, ok := result["result"].(bool); !ok || !success {
-		return fmt.Errorf("authentication failed")
+// toMap converts options to the Deluge RPC options map, omitting unset values
+// so Deluge falls back to its own defaults
+func (o AddTorrentOptions) toMap() map[string]interface{} {
+	options := map[string]interface{}{}
+	if o.DownloadLocation != "" {
+		options["download_location"] = o.DownloadLocation
 	}
+	if o.AddPaused {
+		options["add_paused"] = true
+	}
+	if o.MaxDownloadSpeed > 0 {
+		options["max_download_speed"] = o.MaxDownloadSpeed
+	}
+	if o.MoveCompletedPath != "" {
+		options["move_completed"] = true
+		options["move_completed_path"] = o.MoveCompletedPath
+	}
+	if o.StopAtRatio || o.StopRatio > 0 {
+		options["stop_at_ratio"] = true
+	}
+	if o.StopRatio > 0 {
+		options["stop_ratio"] = o.StopRatio
+	}
+	return options
+}
 
-	return nil
+// AddMagnet adds a magnet URI to Deluge and returns the torrent ID
+func (c *DelugeClient) AddMagnet(magnetURI, label string, opts AddTorrentOptions) (string, error) {
+	return c.addTorrent("core.add_torrent_magnet", []interface{}{magnetURI, opts.toMap()}, label)
 }
 
-// Connect connects to Deluge daemon
-func (c *DelugeClient) Connect() error {
-	// Check if already connected
-	result, err := c.makeRequest("web.connected", []interface{}{})
+// addTorrent calls one of Deluge's add methods and labels the new torrent
+func (c *DelugeClient) addTorrent(method string, params []interface{}, label string) (string, error) {
+	// Add torrent, riding out brief network failures. If a timed-out attempt
+	// did reach Deluge, the next one reports it as already in session.
+	var hash string
+	err := c.rpc(method, params, &hash)
+	for attempt, delay := range transientRetryDelays {
+		if !isTransientError(err) {
+			break
+		}
+		log.Printf("  ⚠ %v; trying again in %s (%d/%d)", err, delay, attempt+1, len(transientRetryDelays))
+		time.Sleep(delay)
+		err = c.rpc(method, params, &hash)
+	}
 	if err != nil {
-		return err
+		return "", err
+	}
+	if hash == "" {
+		return "", &ResponseError{Method: method, Reason: "missing torrent hash"}
 	}
 
-	if connected, ok := result["result"].(bool); ok && connected {
-		return nil
+	// Set label if provided
+	if label != "" {
+		if err := c.SetTorrentLabel(hash, label); err != nil {
+			log.Printf("Warning: Failed to set label %q, torrent left unlabeled: %v", label, err)
+		}
 	}
 
-	// Get hosts
-	result, err = c.makeRequest("web.get_hosts", []interface{}{})
-	if err != nil {
-		return err
+	return hash, nil
+}
+
+// GetTorrentStatus retrieves selected status fields for a single torrent
+func (c *DelugeClient) GetTorrentStatus(torrentID string, keys []string) (map[string]interface{}, error) {
+	var status map[string]interface{}
+	if err := c.rpc("core.get_torrent_status", []interface{}{torrentID, keys}, &status); err != nil {
+		return nil, err
+	}
+	if status == nil {
+		return nil, &ResponseError{Method: "core.get_torrent_status", Reason: "missing torrent status"}
 	}
 
-	hosts, ok := result["result"].([]interface{})
-	if !ok || len(hosts) == 0 {
-		return fmt.Errorf("no Deluge hosts available")
+	return status, nil
+}
+
+// WaitForMetadata polls a newly added torrent until its metadata resolves
+// (the name is no longer just the info hash) or the timeout expires. The last
+// status seen is returned either way so callers can keep partial details.
+func (c *DelugeClient) WaitForMetadata(torrentID string, timeout time.Duration) (map[string]interface{}, error) {
+	keys := []string{"name", "save_path", "hash"}
+	deadline := time.Now().Add(timeout)
+	for {
+		status, err := c.GetTorrentStatus(torrentID, keys)
+		if err != nil {
+			return nil, err
+		}
+		name, _ := status["name"].(string)
+		if name != "" && !strings.EqualFold(name, torrentID) {
+			return status, nil
+		}
+		if time.Now().After(deadline) {
+			return status, fmt.Errorf("metadata not resolved after %s", timeout)
+		}
+		time.Sleep(metadataPollInterval)
 	}
+}
 
-	// Connect to first host
-	host := hosts[0].([]interface{})
-	hostID := host[0].(string)
+// metadataPollInterval is how often WaitForMetadata checks torrent status
+var metadataPollInterval = 2 * time.Second
 
-	_, err = c.makeRequest("web.connect", []interface{}{hostID})
-	return err
+// metadataTimeout returns how long to wait for metadata, or 0 to skip
+func metadataTimeout(config Config) time.Duration {
+	switch {
+	case config.MetadataTimeout < 0:
+		return 0
+	case config.MetadataTimeout == 0:
+		return 30 * time.Second
+	default:
+		return time.Duration(config.MetadataTimeout) * time.Second
+	}
 }
 
-// AddMagnet adds a magnet URI to Deluge
-func (c *DelugeClient) AddMagnet(magnetURI, label string) error {
-	// Add magnet
-	result, err := c.makeRequest("core.add_torrent_magnet", []interface{}{magnetURI, map[string]interface{}{}})
-	if err != nil {
-		return err
+// captureTorrentDetails records Deluge's view of a freshly added torrent in the entry
+func captureTorrentDetails(client *DelugeClient, torrentID string, entry *MagnetEntry, config Config) {
+	logger := config.logger()
+	entry.TorrentID = torrentID
+	entry.AddedToDeluge = time.Now().Format(time.RFC3339)
+
+	rules := config.LabelOptions[config.DelugeLabel]
+	timeout := metadataTimeout(config)
+	if timeout == 0 || torrentID == "" {
+		if rules.hasFileRules() {
+			logger.Printf("Warning: File rules for label %q need metadata, which is disabled", config.DelugeLabel)
+		}
+		return
 	}
 
-	// Check for error in result
-	if errInfo, ok := result["error"]; ok && errInfo != nil {
-		return fmt.Errorf("Deluge error: %v", errInfo)
+	logger.Printf("Waiting up to %s for torrent metadata...", timeout)
+	status, err := client.WaitForMetadata(torrentID, timeout)
+	if err != nil {
+		logger.Printf("Warning: %v", err)
+	}
+	if status == nil {
+		return
+	}
+	if name, _ := status["name"].(string); name != "" && !strings.EqualFold(name, torrentID) {
+		entry.TorrentName = name
+		logger.Printf("  Torrent name: %s", name)
+	}
+	if savePath, _ := status["save_path"].(string); savePath != "" {
+		entry.SavePath = savePath
+		logger.Printf("  Save path: %s", savePath)
 	}
 
-	hash, ok := result["result"].(string)
-	if !ok {
-		return fmt.Errorf("failed to get torrent hash from response")
+	if rules.hasFileRules() && entry.TorrentName != "" {
+		if err := client.ApplyFileRules(torrentID, rules); err != nil {
+			logger.Printf("Warning: Could not apply file rules: %v", err)
+		}
 	}
+}
 
-	// Set label if provided
-	if label != "" {
-		// Ensure label exists
-		_, _ = c.makeRequest("label.add", []interface{}{label})
-		// Ignore error if label already exists
+// RemoveTorrent removes a torrent from Deluge, optionally deleting its data
+func (c *DelugeClient) RemoveTorrent(torrentID string, removeData bool) error {
+	// Some versions return null rather than a boolean
+	var removed *bool
+	if err := c.rpc("core.remove_torrent", []interface{}{torrentID, removeData}, &removed); err != nil {
+		return err
+	}
 
-		// Set label on torrent
-		_, err = c.makeRequest("label.set_torrent", []interface{}{hash, label})
-		if err != nil {
-			log.Printf("Warning: Failed to set label: %v", err)
-		}
+	if removed != nil && !*removed {
+		return fmt.Errorf("Deluge did not remove torrent %s", torrentID)
 	}
 
 	return nil
 }
 
-// GetTorrentsByLabel retrieves all torrents with a specific label
-func (c *DelugeClient) GetTorrentsByLabel(label string) (map[string]map[string]interface{}, error) {
-	// Get all torrents with their info
+// PauseTorrent pauses a torrent in Deluge
+func (c *DelugeClient) PauseTorrent(torrentID string) error {
+	return c.torrentAction("core.pause_torrent", torrentID)
+}
+
+// ResumeTorrent resumes a paused torrent in Deluge
+func (c *DelugeClient) ResumeTorrent(torrentID string) error {
+	return c.torrentAction("core.resume_torrent", torrentID)
+}
+
+// torrentAction calls a single-torrent Deluge method and checks for errors
+func (c *DelugeClient) torrentAction(method, torrentID string) error {
+	return c.rpc(method, []interface{}{c.torrentIDParam(torrentID)}, nil)
+}
+
+// GetAllTorrents retrieves the name, hash, save path, and label of every
+// torrent in Deluge
+func (c *DelugeClient) GetAllTorrents() (map[string]map[string]interface{}, error) {
 	keys := []string{"name", "hash", "save_path", "label"}
-	result, err := c.makeRequest("core.get_torrents_status", []interface{}{map[string]interface{}{}, keys})
-	if err != nil {
+	var torrents map[string]map[string]interface{}
+	if err := c.rpc("core.get_torrents_status", []interface{}{map[string]interface{}{}, keys}, &torrents); err != nil {
 		return nil, err
 	}
+	return torrents, nil
+}
 
-	torrents, ok := result["result"].(map[string]interface{})
-	if !ok {
-		return nil, fmt.Errorf("unexpected response format")
+// GetTorrentsByLabel retrieves all torrents with a specific label
+func (c *DelugeClient) GetTorrentsByLabel(label string) (map[string]map[string]interface{}, error) {
+	torrents, err := c.GetAllTorrents()
+	if err != nil {
+		return nil, err
 	}
 
 	// Filter by label
 	filtered := make(map[string]map[string]interface{})
-	for hash, torrentData := range torrents {
-		torrentMap, ok := torrentData.(map[string]interface{})
-		if !ok {
-			continue
-		}
+	for hash, torrentMap := range torrents {
 		torrentLabel, _ := torrentMap["label"].(string)
 		if torrentLabel == label {
 			filtered[hash] = torrentMap
@@ -900,14 +1468,59 @@ func (c *DelugeClient) GetTorrentsByLabel(label string) (map[string]map[string]i
 	return filtered, nil
 }
 
+// loadDatabaseForAdd loads the database used for the duplicate check. When
+// RefreshBeforeAdd is set, the remote copy is merged in first so magnets added
+// moments ago on another machine are detected without waiting for a sync.
+func loadDatabaseForAdd(config Config) (*MagnetDatabase, error) {
+	remotes := GetRemotePaths(&config)
+	var db *MagnetDatabase
+	var err error
+	if !config.RefreshBeforeAdd || len(remotes) == 0 {
+		db, err = LoadJSONDatabase(config.JSONPath)
+	} else {
+		log.Printf("Refreshing from remote before duplicate check: %s", displayRemote(remotes[0]))
+		db, err = syncWithRemotes(config.JSONPath, remotes)
+		if err != nil {
+			log.Printf("Warning: Remote refresh failed, using local: %v", err)
+			db, err = LoadJSONDatabase(config.JSONPath)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Include journaled operations a crashed save has not folded in yet
+	replayJournal(config.JSONPath, db)
+	return db, nil
+}
+
+// GetTorrentsByID retrieves status fields for the given torrent IDs
+func (c *DelugeClient) GetTorrentsByID(ids []string, keys []string) (map[string]map[string]interface{}, error) {
+	filter := map[string]interface{}{"id": ids}
+	var statuses map[string]map[string]interface{}
+	if err := c.rpc("core.get_torrents_status", []interface{}{filter, keys}, &statuses); err != nil {
+		return nil, err
+	}
+
+	return statuses, nil
+}
+
 // AddMagnetToDeluge is the main handler function
-func AddMagnetToDeluge(magnetURI string, config Config) error {
+func AddMagnetToDeluge(rawURI string, config Config) error {
+	logger := config.logger()
+	// Clean up the URI as delivered, keeping the original for debugging. A
+	// .torrent file or URL is tracked by the magnet link for its info hash.
+	magnetURI, torrent, err := resolveSource(rawURI)
+	if err != nil {
+		return err
+	}
+
 	// Strict validation - no injection possible
 	if !ValidateMagnetURI(magnetURI) {
 		return fmt.Errorf("invalid magnet URI format")
 	}
 
-	log.Printf("Processing magnet link: %.100s...", magnetURI)
+	logger.Printf("Processing magnet link: %.100s...", magnetURI)
 
 	// Extract hash and name
 	hash := ExtractMagnetHash(magnetURI)
@@ -916,11 +1529,26 @@ func AddMagnetToDeluge(magnetURI string, config Config) error {
 	if hash == "" {
 		return fmt.Errorf("could not extract hash from magnet URI")
 	}
+	if isBlocked(hash) {
+		logger.Printf("✗ %s is on the blocklist, not adding (blocklist remove %s to allow it)", hash, hash)
+		return fmt.Errorf("%s is on the blocklist", hash)
+	}
+	defer lockEntry(hash)()
+	routeByTracker(&config, magnetURI)
 
-	// Load database
-	db, err := LoadJSONDatabase(config.JSONPath)
+	// Load database, unless the hash index proves this is a new magnet
+	var db *MagnetDatabase
+	if !config.RefreshBeforeAdd && IndexRulesOut(config.JSONPath, hash) {
+		logger.Printf("Not in hash index, skipping full database load")
+		db = &MagnetDatabase{
+			Added: make(map[string]MagnetEntry),
+			Retry: make(map[string]MagnetEntry),
+		}
+	} else {
+		db, err = loadDatabaseForAdd(config)
+	}
 	if err != nil {
-		log.Printf("Warning: Could not load database: %v", err)
+		logger.Printf("Warning: Could not load database: %v", err)
 		db = &MagnetDatabase{
 			Added: make(map[string]MagnetEntry),
 			Retry: make(map[string]MagnetEntry),
@@ -929,17 +1557,18 @@ func AddMagnetToDeluge(magnetURI string, config Config) error {
 
 	// Check if already successfully added
 	if _, exists := db.Added[hash]; exists {
-		log.Printf("✓ Already added: %s", name)
-		log.Printf("Retry queue: %d items", len(db.Retry))
+		logger.Printf("✓ Already added: %s", name)
+		logWebUILink(logger, hash)
+		logger.Printf("Retry queue: %d items", len(db.Retry))
 		return nil
 	}
 
 	// Check if already in retry queue (don't retry automatically)
 	if entry, exists := db.Retry[hash]; exists {
-		log.Printf("⚠ Already in retry queue: %s", name)
-		log.Printf("  Last attempt: %s (attempt #%d)", entry.LastAttempt, entry.RetryCount)
-		log.Printf("  Use --retry flag to process retry queue")
-		log.Printf("Retry queue: %d items", len(db.Retry))
+		logger.Printf("⚠ Already in retry queue: %s", name)
+		logger.Printf("  Last attempt: %s (attempt #%d)", entry.LastAttempt, entry.RetryCount)
+		logger.Printf("  Run the retry command to process the retry queue")
+		logger.Printf("Retry queue: %d items", len(db.Retry))
 		return nil
 	}
 
@@ -955,6 +1584,10 @@ func AddMagnetToDeluge(magnetURI string, config Config) error {
 		AddedDate:   time.Now().Format(time.RFC3339),
 		LastAttempt: time.Now().Format(time.RFC3339),
 		RetryCount:  1,
+		Label:       config.DelugeLabel,
+	}
+	if rawURI != magnetURI {
+		entry.RawURI = rawURI
 	}
 
 	// Prepare database update
@@ -963,59 +1596,117 @@ func AddMagnetToDeluge(magnetURI string, config Config) error {
 		Retry: make(map[string]MagnetEntry),
 	}
 
+	// Keep a .torrent that did not make it in, so retry can upload it
+	if torrent != nil {
+		defer func() {
+			if _, queued := dbUpdate.Retry[hash]; queued {
+				keepTorrentForRetry(torrent)
+			}
+		}()
+	}
+
+	// Send to every configured client at once
+	if len(config.Targets) > 0 {
+		logger.Printf("Sending to %d torrent clients...", len(config.Targets)+1)
+		if fanOutAdd(magnetURI, hash, &entry, config) {
+			logger.Printf("✓ Accepted by at least one client: %s", name)
+			entry.recordHistory(HistoryAdded, acceptingTargets(entry), "")
+			dbUpdate.Added[hash] = entry
+		} else {
+			logger.Printf("✗ No client accepted it, added to retry queue: %s", name)
+			entry.recordHistory(HistoryQueued, "", "no client accepted the torrent")
+			dbUpdate.Retry[hash] = entry
+		}
+		if err := SaveJSONDatabase(config.JSONPath, dbUpdate, &config); err != nil {
+			logger.Printf("Warning: Failed to save database: %v", err)
+		}
+		return nil
+	}
+
+	// Skip Deluge entirely while the password keeps being rejected
+	if AuthBreakerOpen(config) {
+		logger.Printf("⚠ Deluge authentication is failing, not contacting Deluge")
+		logger.Printf("  Added to retry queue: %s", name)
+		entry.recordHistory(HistoryQueued, "", "authentication breaker open")
+		dbUpdate.Retry[hash] = entry
+		if saveErr := SaveJSONDatabase(config.JSONPath, dbUpdate, &config); saveErr != nil {
+			logger.Printf("Warning: Failed to save database: %v", saveErr)
+		}
+		return fmt.Errorf("authentication breaker open: fix the Deluge password or run reset-auth")
+	}
+
 	// Authenticate
-	if err = client.Authenticate(); err != nil {
-		log.Printf("✗ Authentication failed: %v", err)
-		log.Printf("  Added to retry queue: %s", name)
+	if err = authenticate(client, config); err != nil {
+		logger.Printf("✗ Authentication failed: %v", err)
+		logger.Printf("  Added to retry queue: %s", name)
+		entry.recordHistory(HistoryQueued, "", fmt.Sprintf("authentication failed: %v", err))
 		dbUpdate.Retry[hash] = entry
 		if saveErr := SaveJSONDatabase(config.JSONPath, dbUpdate, &config); saveErr != nil {
-			log.Printf("Warning: Failed to save database: %v", saveErr)
+			logger.Printf("Warning: Failed to save database: %v", saveErr)
 		}
 		return fmt.Errorf("authentication failed: %w", err)
 	}
-	log.Println("Authenticated with Deluge")
+	logger.Println("Authenticated with Deluge")
 
 	// Connect to daemon
 	if err := client.Connect(); err != nil {
-		log.Printf("✗ Connection failed: %v", err)
-		log.Printf("  Added to retry queue: %s", name)
+		logger.Printf("✗ Connection failed: %v", err)
+		logger.Printf("  Added to retry queue: %s", name)
+		entry.recordHistory(HistoryQueued, "", fmt.Sprintf("connection failed: %v", err))
 		dbUpdate.Retry[hash] = entry
 		if saveErr := SaveJSONDatabase(config.JSONPath, dbUpdate, &config); saveErr != nil {
-			log.Printf("Warning: Failed to save database: %v", saveErr)
+			logger.Printf("Warning: Failed to save database: %v", saveErr)
 		}
 		return fmt.Errorf("connection failed: %w", err)
 	}
-	log.Println("Connected to Deluge daemon")
+	logger.Println("Connected to Deluge daemon")
 
 	// Add magnet
-	err = client.AddMagnet(magnetURI, config.DelugeLabel)
+	opts := AddOptionsFromConfig(config)
+	held := holdForLimit(client, config, config.DelugeLabel, &opts)
+	torrentID, err := addToDeluge(client, magnetURI, torrent, config.DelugeLabel, opts)
 
 	if err != nil {
 		// Check if it's a duplicate error
-		if strings.Contains(err.Error(), "already in session") {
-			log.Printf("⚠ Duplicate (already in Deluge): %s", name)
+		if classifyError(err) == ErrorDuplicate {
+			logger.Printf("⚠ Duplicate (already in Deluge): %s", name)
+			logWebUILink(logger, hash)
+			clearFailure(&entry)
+			entry.recordHistory(HistoryDuplicate, config.DelugeHost, "")
 			// Add to added section
 			dbUpdate.Added[hash] = entry
 		} else {
-			log.Printf("✗ Failed to add: %v", err)
-			log.Printf("  Added to retry queue")
+			logger.Printf("✗ Failed to add: %v", err)
+			markFailure(&entry, err)
+			entry.recordHistory(HistoryQueued, "", err.Error())
+			if failedPermanently(entry) {
+				logger.Printf("  Failed permanently (%s), it will not be retried; see list --section failed", entry.ErrorKind)
+				entry.recordHistory(HistoryGaveUp, "", "permanent failure: "+entry.ErrorKind)
+			} else {
+				logger.Printf("  Added to retry queue")
+			}
 			// Add to retry section
 			dbUpdate.Retry[hash] = entry
 		}
 	} else {
-		log.Printf("✓ Successfully added to Deluge: %s", name)
+		logger.Printf("✓ Successfully added to Deluge: %s", name)
+		logWebUILink(logger, hash)
+		clearFailure(&entry)
+		entry.Held = held
+		entry.recordHistory(HistoryAdded, config.DelugeHost, holdDetail(held))
+		captureTorrentDetails(client, torrentID, &entry, config)
 		// Add to added section
 		dbUpdate.Added[hash] = entry
 	}
 
 	// Save to database
 	if err := SaveJSONDatabase(config.JSONPath, dbUpdate, &config); err != nil {
-		log.Printf("Warning: Failed to save database: %v", err)
+		logger.Printf("Warning: Failed to save database: %v", err)
 	}
 
 	// Reload to show current retry count
 	db, _ = LoadJSONDatabase(config.JSONPath)
-	log.Printf("Retry queue: %d items", len(db.Retry))
+	logger.Printf("Retry queue: %d items", len(db.Retry))
 
 	return nil
 }
@@ -1058,11 +1749,14 @@ func SyncWithDeluge(config Config, dryRun bool) error {
 
 	// Find entries in database that are NOT in Deluge
 	orphaned := []string{}
+	progress := newBatchProgress("Sync", len(db.Added))
 	for hash := range db.Added {
+		progress.Step(1)
 		if _, exists := torrents[hash]; !exists {
 			orphaned = append(orphaned, hash)
 		}
 	}
+	progress.Finish()
 
 	log.Println(strings.Repeat("=", 60))
 	log.Println("Sync Results:")
@@ -1082,31 +1776,32 @@ func SyncWithDeluge(config Config, dryRun bool) error {
 					log.Printf("  ... (%d more) ...", len(orphaned)-20)
 				}
 			}
-			log.Println("\nRun with --sync to actually remove orphaned entries")
+			log.Println("\nRun sync without --dry-run to actually remove orphaned entries")
 		} else {
 			log.Printf("\nRemoving %d orphaned entries...", len(orphaned))
+			if db.Tombstones == nil {
+				db.Tombstones = make(map[string]Tombstone)
+			}
+			now := time.Now()
 			for _, hash := range orphaned {
 				delete(db.Added, hash)
+				db.Tombstones[hash] = newTombstone(hash, now)
 			}
 
 			// Save updated database
-			homeDir, _ := getHomeDir()
-			localPath := filepath.Join(homeDir, "magnet-list-local.json")
-
-			if err := SaveDatabaseLocal(localPath, db); err != nil {
+			localPath, release, err := lockLocalDatabase(config.JSONPath)
+			if err != nil {
+				return err
+			}
+			err = SaveDatabaseLocal(localPath, db)
+			release()
+			if err != nil {
 				return fmt.Errorf("failed to save: %w", err)
 			}
 			log.Printf("Saved to local: %s", localPath)
 
-			// Sync to remote
-			remotePath := GetRemotePath(&config)
-			if remotePath != "" {
-				if err := SaveDatabaseLocal(remotePath, db); err != nil {
-					log.Printf("Warning: Could not sync to remote: %v", err)
-				} else {
-					log.Printf("Synced to remote: %s", remotePath)
-				}
-			}
+			// Sync to the remotes
+			saveRemotes(GetRemotePaths(&config), db)
 
 			log.Printf("\n✓ Removed %d orphaned entries", len(orphaned))
 			log.Printf("Database now has %d entries", len(db.Added)+len(db.Retry))
@@ -1118,6 +1813,22 @@ func SyncWithDeluge(config Config, dryRun bool) error {
 	return nil
 }
 
+// torrentEntry builds a database entry for a torrent already in Deluge
+func torrentEntry(hash string, torrentData map[string]interface{}, label string) MagnetEntry {
+	name, _ := torrentData["name"].(string)
+	savePath, _ := torrentData["save_path"].(string)
+	return MagnetEntry{
+		UUID:        GenerateUUID(),
+		Title:       name,
+		Hash:        hash,
+		URI:         fmt.Sprintf("magnet:?xt=urn:btih:%s", hash),
+		AddedDate:   time.Now().Format(time.RFC3339),
+		SavePath:    savePath,
+		TorrentName: name,
+		Label:       label,
+	}
+}
+
 // BackfillFromDeluge backfills database from existing Deluge torrents
 func BackfillFromDeluge(config Config) error {
 	log.Println("Backfilling database from Deluge...")
@@ -1165,7 +1876,10 @@ func BackfillFromDeluge(config Config) error {
 	skipped := 0
 	nextID := db.Metadata.LastSequence + 1
 
+	progress := newBatchProgress("Backfill", len(torrents))
 	for hash, torrentData := range torrents {
+		progress.Step(1)
+
 		// Check if already exists
 		if _, exists := db.Added[hash]; exists {
 			skipped++
@@ -1175,6 +1889,7 @@ func BackfillFromDeluge(config Config) error {
 			// Move from retry to added
 			entry := db.Retry[hash]
 			delete(db.Retry, hash)
+			stampEntry(db, &entry)
 			db.Added[hash] = entry
 			log.Printf("Moved from retry to added: %s", entry.Title)
 			added++
@@ -1182,50 +1897,32 @@ func BackfillFromDeluge(config Config) error {
 		}
 
 		// Create new entry
-		name, _ := torrentData["name"].(string)
-		savePath, _ := torrentData["save_path"].(string)
-
-		entry := MagnetEntry{
-			UUID:        GenerateUUID(),
-			ID:          nextID,
-			Title:       name,
-			Hash:        hash,
-			URI:         fmt.Sprintf("magnet:?xt=urn:btih:%s", hash),
-			AddedDate:   time.Now().Format(time.RFC3339),
-			SavePath:    savePath,
-			TorrentName: name,
-		}
+		entry := torrentEntry(hash, torrentData, config.DelugeLabel)
+		entry.ID = nextID
+		stampEntry(db, &entry)
 
 		db.Added[hash] = entry
 		nextID++
 		added++
-
-		if added%100 == 0 {
-			log.Printf("Processed %d torrents...", added+skipped)
-		}
 	}
+	progress.Finish()
 
 	db.Metadata.LastSequence = nextID - 1
 
 	// Always save to local first (fast, reliable)
-	homeDir, _ := getHomeDir()
-	localPath := filepath.Join(homeDir, "magnet-list-local.json")
-
-	if err := SaveDatabaseLocal(localPath, db); err != nil {
+	localPath, release, err := lockLocalDatabase(config.JSONPath)
+	if err != nil {
+		return err
+	}
+	err = SaveDatabaseLocal(localPath, db)
+	release()
+	if err != nil {
 		return fmt.Errorf("failed to save to local: %w", err)
 	}
 	log.Printf("Saved to local: %s", localPath)
 
-	// Try to sync to remote (best effort)
-	remotePath := GetRemotePath(&config)
-	if remotePath != "" {
-		if err := SaveDatabaseLocal(remotePath, db); err != nil {
-			log.Printf("Warning: Could not sync to remote %s: %v", remotePath, err)
-			log.Println("Changes saved locally, will sync on next operation")
-		} else {
-			log.Printf("Synced to remote: %s", remotePath)
-		}
-	}
+	// Try to sync to the remotes (best effort)
+	saveRemotes(GetRemotePaths(&config), db)
 
 	// Check for duplicate IDs
 	idMap := make(map[int64][]string)
@@ -1259,23 +1956,37 @@ func BackfillFromDeluge(config Config) error {
 	return nil
 }
 
-// ProcessRetryQueue processes all items in the retry queue
-func ProcessRetryQueue(config Config) error {
-	log.Println("Processing retry queue...")
+// applyCompletionStatus updates an entry from Deluge's state/progress and
+// reports whether anything changed
+func applyCompletionStatus(entry *MagnetEntry, status map[string]interface{}, now time.Time) bool {
+	state, _ := status["state"].(string)
+	progress, _ := status["progress"].(float64)
+
+	changed := entry.State != state || entry.Progress != progress
+	entry.State = state
+	entry.Progress = progress
+
+	if entry.CompletedAt == "" && (progress >= 100 || state == "Seeding") {
+		entry.CompletedAt = now.Format(time.RFC3339)
+		changed = true
+	}
+	return changed
+}
+
+// CheckCompletion records download state and progress for every added entry
+func CheckCompletion(config Config) error {
+	log.Println("Checking completion status in Deluge...")
 
-	// Load database
 	db, err := LoadJSONDatabase(config.JSONPath)
 	if err != nil {
 		return fmt.Errorf("failed to load database: %w", err)
 	}
 
-	if len(db.Retry) == 0 {
-		log.Println("✓ Retry queue is empty")
+	if len(db.Added) == 0 {
+		log.Println("✓ No added entries to check")
 		return nil
 	}
 
-	log.Printf("Found %d items in retry queue", len(db.Retry))
-
 	// Create Deluge client
 	client := NewDelugeClient(config.DelugeHost, config.DelugePort, config.DelugePassword)
 
@@ -1291,78 +2002,459 @@ func ProcessRetryQueue(config Config) error {
 	}
 	log.Println("Connected to Deluge daemon")
 
-	// Process each retry item
-	success := 0
-	duplicate := 0
-	failed := 0
+	hashes := make([]string, 0, len(db.Added))
+	for hash := range db.Added {
+		hashes = append(hashes, hash)
+	}
 
-	for hash, entry := range db.Retry {
-		log.Printf("\nRetrying [%d/%d]: %s (attempt #%d)", success+duplicate+failed+1, len(db.Retry), entry.Title, entry.RetryCount+1)
+	statuses, err := client.GetTorrentsByID(hashes, []string{"state", "progress", "name", "save_path"})
+	if err != nil {
+		return fmt.Errorf("failed to get torrent status: %w", err)
+	}
 
-		err := client.AddMagnet(entry.URI, config.DelugeLabel)
+	dbUpdate := &MagnetDatabase{
+		Added: make(map[string]MagnetEntry),
+		Retry: make(map[string]MagnetEntry),
+	}
 
-		// Update entry
-		entry.LastAttempt = time.Now().Format(time.RFC3339)
-		entry.RetryCount++
+	now := time.Now()
+	completed := 0
+	newlyCompleted := 0
+	missing := 0
+	for hash, entry := range db.Added {
+		status, exists := statuses[hash]
+		if !exists {
+			missing++
+			continue
+		}
+		wasComplete := entry.CompletedAt != ""
+		if applyCompletionStatus(&entry, status, now) {
+			dbUpdate.Added[hash] = entry
+		}
+		if entry.CompletedAt != "" {
+			completed++
+			if !wasComplete {
+				newlyCompleted++
+				log.Printf("✓ Completed: %s", entry.Title)
+				entry.recordHistory(HistoryCompleted, "", "")
+				afterCompletion(client, config, &entry, status)
+				dbUpdate.Added[hash] = entry
+			} else if entry.VerifyPending && finishRecheck(&entry, status) {
+				sortIfVerified(client, config, &entry, status)
+				dbUpdate.Added[hash] = entry
+			}
+		}
+	}
 
-		dbUpdate := &MagnetDatabase{
-			Added: make(map[string]MagnetEntry),
-			Retry: make(map[string]MagnetEntry),
+	// Completed downloads free slots for torrents held by max_active
+	released := releaseHeld(client, config, db, dbUpdate)
+
+	if len(dbUpdate.Added) > 0 {
+		if err := SaveJSONDatabase(config.JSONPath, dbUpdate, &config); err != nil {
+			return fmt.Errorf("failed to save database: %w", err)
 		}
+	}
+
+	log.Println(strings.Repeat("=", 60))
+	log.Println("Completion Summary:")
+	log.Printf("  Checked: %d", len(db.Added)-missing)
+	log.Printf("  Completed: %d (%d new)", completed, newlyCompleted)
+	log.Printf("  In progress: %d", len(db.Added)-missing-completed)
+	log.Printf("  Not in Deluge: %d", missing)
+	if released > 0 {
+		log.Printf("  Released from hold: %d", released)
+	}
+	log.Printf("  Entries updated: %d", len(dbUpdate.Added))
+	log.Println(strings.Repeat("=", 60))
+
+	return nil
+}
 
+// SetTorrentsPaused pauses or resumes tracked torrents. The target is either
+// a hash/UUID of a tracked entry or a label, in which case every tracked
+// torrent carrying that label in Deluge is affected.
+func SetTorrentsPaused(config Config, target string, pause bool) error {
+	action, done := "Resuming", "Resumed"
+	if pause {
+		action, done = "Pausing", "Paused"
+	}
+
+	db, err := LoadJSONDatabase(config.JSONPath)
+	if err != nil {
+		return fmt.Errorf("failed to load database: %w", err)
+	}
+
+	// Create Deluge client
+	client := NewDelugeClient(config.DelugeHost, config.DelugePort, config.DelugePassword)
+
+	// Authenticate
+	if err := client.Authenticate(); err != nil {
+		return fmt.Errorf("authentication failed: %w", err)
+	}
+	log.Println("Authenticated with Deluge")
+
+	// Connect to daemon
+	if err := client.Connect(); err != nil {
+		return fmt.Errorf("connection failed: %w", err)
+	}
+	log.Println("Connected to Deluge daemon")
+
+	// Resolve target to torrent hashes
+	var hashes []string
+	if hash, section, _, ok := findEntry(db, target); ok && section == SectionAdded {
+		hashes = append(hashes, hash)
+	} else {
+		log.Printf("Looking up tracked torrents with label: %s", target)
+		torrents, err := client.GetTorrentsByLabel(target)
 		if err != nil {
-			if strings.Contains(err.Error(), "already in session") {
-				log.Printf("  ⚠ Duplicate (already in Deluge)")
-				dbUpdate.Added[hash] = entry
-				duplicate++
-			} else {
-				log.Printf("  ✗ Still failing: %v", err)
-				dbUpdate.Retry[hash] = entry
-				failed++
+			return fmt.Errorf("failed to get torrents: %w", err)
+		}
+		for hash := range torrents {
+			if _, tracked := db.Added[hash]; tracked {
+				hashes = append(hashes, hash)
 			}
+		}
+		if len(hashes) == 0 {
+			return fmt.Errorf("no tracked torrent or label matches %q", target)
+		}
+	}
+
+	log.Printf("%s %d torrent(s)...", action, len(hashes))
+	failed := 0
+	for _, hash := range hashes {
+		if pause {
+			err = client.PauseTorrent(hash)
 		} else {
-			log.Printf("  ✓ Success!")
+			err = client.ResumeTorrent(hash)
+		}
+		if err != nil {
+			log.Printf("  ✗ %s - %s: %v", hash[:min(8, len(hash))], db.Added[hash].Title, err)
+			failed++
+			continue
+		}
+		log.Printf("  ✓ %s - %s", hash[:min(8, len(hash))], db.Added[hash].Title)
+	}
+
+	log.Printf("%s %d of %d torrent(s)", done, len(hashes)-failed, len(hashes))
+	if failed > 0 {
+		return fmt.Errorf("%d torrent(s) failed", failed)
+	}
+	return nil
+}
+
+// Outcomes of a single retry attempt
+const (
+	RetrySucceeded = "success"
+	RetryDuplicate = "duplicate"
+	RetryFailed    = "failed"
+)
+
+// entryLabel returns the label recorded on an entry, or the configured default
+func entryLabel(entry MagnetEntry, config Config) string {
+	if entry.Label != "" {
+		return entry.Label
+	}
+	return config.DelugeLabel
+}
+
+// retryEntry re-adds one retry-queue entry to Deluge and saves the result,
+// returning the outcome and, for failures, the error from Deluge
+func retryEntry(client *DelugeClient, config Config, hash string, entry MagnetEntry) (string, error) {
+	logger := config.logger()
+	label := entryLabel(entry, config)
+	uri := retryURI(entry, config)
+	entry.SentURI = ""
+	if uri != entry.URI {
+		logger.Printf("  Retrying with %.100s...", uri)
+		entry.SentURI = uri
+	}
+	opts := AddOptionsForLabel(config, label)
+	held := holdForLimit(client, config, label, &opts)
+	torrent := retryTorrent(hash)
+	torrentID, err := addToDeluge(client, uri, torrent, label, opts)
+
+	// Update entry, without counting attempts that never reached Deluge
+	entry.LastAttempt = time.Now().Format(time.RFC3339)
+	if !unreachable(err) {
+		entry.RetryCount++
+	}
+	entry.Label = label
+
+	dbUpdate := &MagnetDatabase{
+		Added: make(map[string]MagnetEntry),
+		Retry: make(map[string]MagnetEntry),
+	}
+
+	outcome := RetrySucceeded
+	if err != nil {
+		if classifyError(err) == ErrorDuplicate {
+			logger.Printf("  ⚠ Duplicate (already in Deluge)")
+			logWebUILink(logger, hash)
+			entry.NextAttempt = ""
+			clearFailure(&entry)
+			entry.recordHistory(HistoryDuplicate, config.DelugeHost, "")
 			dbUpdate.Added[hash] = entry
+			outcome, err = RetryDuplicate, nil
+		} else {
+			logger.Printf("  ✗ Still failing: %v", err)
+			entry.recordHistory(HistoryRetryFailed, "", fmt.Sprintf("attempt #%d: %v", entry.RetryCount, err))
+			markFailure(&entry, err)
+			if failedPermanently(entry) {
+				logger.Printf("  ✗ Failed permanently (%s), it will not be retried; see list --section failed", entry.ErrorKind)
+				entry.NextAttempt = ""
+				entry.recordHistory(HistoryGaveUp, "", "permanent failure: "+entry.ErrorKind)
+			} else if !unreachable(err) {
+				scheduleNextAttempt(&entry, config, time.Now())
+				if retriesExhausted(entry, config) {
+					logger.Printf("  ✗ Giving up after %s; see list --section failed", plural(entry.RetryCount, "attempt", "attempts"))
+					entry.recordHistory(HistoryGaveUp, "", fmt.Sprintf("max_retries is %d", config.MaxRetries))
+				}
+			}
+			dbUpdate.Retry[hash] = entry
+			outcome = RetryFailed
+		}
+	} else {
+		entry.NextAttempt = ""
+		clearFailure(&entry)
+		logger.Printf("  ✓ Success!")
+		logWebUILink(logger, hash)
+		entry.Held = held
+		entry.recordHistory(HistoryAdded, config.DelugeHost, holdDetail(held))
+		captureTorrentDetails(client, torrentID, &entry, config)
+		dbUpdate.Added[hash] = entry
+	}
+
+	// Save after each attempt
+	if saveErr := SaveJSONDatabase(config.JSONPath, dbUpdate, &config); saveErr != nil {
+		logger.Printf("Warning: Failed to save database: %v", saveErr)
+	}
+	if _, added := dbUpdate.Added[hash]; added && torrent != nil {
+		forgetTorrent(hash)
+	}
+
+	return outcome, err
+}
+
+// connectDeluge creates a client and logs into Deluge for a single operation
+func connectDeluge(config Config) (*DelugeClient, error) {
+	if AuthBreakerOpen(config) {
+		return nil, fmt.Errorf("authentication breaker open: fix the Deluge password or run reset-auth")
+	}
+
+	client := NewDelugeClient(config.DelugeHost, config.DelugePort, config.DelugePassword)
+	if err := authenticate(client, config); err != nil {
+		return nil, fmt.Errorf("authentication failed: %w", err)
+	}
+	if err := client.Connect(); err != nil {
+		return nil, fmt.Errorf("connection failed: %w", err)
+	}
+	return client, nil
+}
+
+// ProcessRetryQueue processes all items in the retry queue
+func ProcessRetryQueue(config Config) error {
+	return ProcessRetryEntries(config, RetryFilter{})
+}
+
+// ProcessRetryEntries retries the entries the filter selects, or the whole
+// queue when it is empty. Chosen entries are retried even if presumed dead,
+// failed, or not yet due.
+func ProcessRetryEntries(config Config, filter RetryFilter) error {
+	logger := config.logger()
+	logger.Println("Processing retry queue...")
+
+	// Load database
+	db, err := LoadJSONDatabase(config.JSONPath)
+	if err != nil {
+		return fmt.Errorf("failed to load database: %w", err)
+	}
+
+	if len(db.Retry) == 0 {
+		logger.Println("✓ Retry queue is empty")
+		return nil
+	}
+
+	logger.Printf("Found %d items in retry queue", len(db.Retry))
+
+	var chosen map[string]MagnetEntry
+	if filter.isSet() {
+		if chosen, err = selectRetryEntries(db, filter); err != nil {
+			return err
+		}
+		logger.Printf("Retrying %s selected by %s", plural(len(chosen), "entry", "entries"), describeRetryFilter(filter))
+	}
+
+	if AuthBreakerOpen(config) {
+		return fmt.Errorf("authentication breaker open: fix the Deluge password or run reset-auth")
+	}
+
+	// Create Deluge client
+	client := NewDelugeClient(config.DelugeHost, config.DelugePort, config.DelugePassword)
+
+	// Authenticate
+	if err := authenticate(client, config); err != nil {
+		return fmt.Errorf("authentication failed: %w", err)
+	}
+	logger.Println("Authenticated with Deluge")
+
+	// Connect to daemon
+	if err := client.Connect(); err != nil {
+		return fmt.Errorf("connection failed: %w", err)
+	}
+	logger.Println("Connected to Deluge daemon")
+
+	// Leave presumed-dead and failed magnets out so they don't crowd the
+	// queue, and wait out each entry's backoff
+	queue := make(map[string]MagnetEntry, len(db.Retry))
+	dead, gaveUp, waiting, blocked := 0, 0, 0, 0
+	var nextDue time.Time
+	now := time.Now()
+	for hash, entry := range db.Retry {
+		if isBlocked(hash) {
+			blocked++
+			continue
+		}
+		if chosen != nil {
+			if _, ok := chosen[hash]; ok {
+				queue[hash] = entry
+			}
+			continue
+		}
+		if retryFailed(entry, config) {
+			gaveUp++
+			continue
+		}
+		if presumedDead(entry, config, now) {
+			dead++
+			continue
+		}
+		if !retryDue(entry, now) {
+			waiting++
+			if due := parseTimestamp(entry.NextAttempt); nextDue.IsZero() || due.Before(nextDue) {
+				nextDue = due
+			}
+			continue
+		}
+		queue[hash] = entry
+	}
+	if blocked > 0 {
+		logger.Printf("Skipping %d blocklisted entries; see blocklist list", blocked)
+	}
+	if gaveUp > 0 {
+		logger.Printf("Skipping %d failed entries (permanent errors or out of attempts); see list --section failed", gaveUp)
+	}
+	if dead > 0 {
+		logger.Printf("Skipping %d presumed-dead entries (no trackers, failing for over %d days); see list --section dead", dead, config.DeadAfterDays)
+	}
+	if waiting > 0 {
+		logger.Printf("Skipping %d entries backing off (next due %s)", waiting, nextDue.Format(time.RFC3339))
+	}
+
+	// Process each retry item
+	success := 0
+	duplicate := 0
+	failed := 0
+
+	progress := newBatchProgress("Retry", len(queue))
+	for hash, entry := range queue {
+		// The dashboard may have changed or retried the entry since the
+		// queue was read
+		unlock := lockEntry(hash)
+		section, current, err := reloadEntry(config.JSONPath, hash)
+		if err != nil && !errors.Is(err, errNotFound) {
+			unlock()
+			logger.Printf("\nSkipping %s: %v", entry.Title, err)
+			progress.Step(1)
+			continue
+		}
+		if section != SectionRetry {
+			unlock()
+			logger.Printf("\nSkipping %s: no longer in the retry queue", entry.Title)
+			progress.Step(1)
+			continue
+		}
+		entry = current
+
+		logger.Printf("\nRetrying [%d/%d]: %s (attempt #%d)", success+duplicate+failed+1, len(queue), entry.Title, entry.RetryCount+1)
+
+		outcome, err := retryEntry(client, config, hash, entry)
+		unlock()
+		switch outcome {
+		case RetrySucceeded:
 			success++
+		case RetryDuplicate:
+			duplicate++
+		default:
+			failed++
 		}
+		progress.Step(1)
 
-		// Save after each attempt
-		if err := SaveJSONDatabase(config.JSONPath, dbUpdate, &config); err != nil {
-			log.Printf("Warning: Failed to save database: %v", err)
+		// Stop early rather than failing every remaining entry
+		if unreachable(err) {
+			logger.Printf("✗ Deluge is unreachable or not connected to its daemon, leaving the rest of the queue for later")
+			break
 		}
 
 		// Small delay between attempts
 		time.Sleep(1 * time.Second)
 	}
+	progress.Finish()
 
-	log.Println("\n" + strings.Repeat("=", 60))
-	log.Println("Retry Summary:")
-	log.Printf("  Successfully added: %d", success)
-	log.Printf("  Duplicates: %d", duplicate)
-	log.Printf("  Still failing: %d", failed)
-	log.Println(strings.Repeat("=", 60))
+	logger.Println("\n" + strings.Repeat("=", 60))
+	logger.Println("Retry Summary:")
+	logger.Printf("  Successfully added: %d", success)
+	logger.Printf("  Duplicates: %d", duplicate)
+	logger.Printf("  Still failing: %d", failed)
+	if dead > 0 {
+		logger.Printf("  Presumed dead (skipped): %d", dead)
+	}
+	if gaveUp > 0 {
+		logger.Printf("  Failed (skipped): %d", gaveUp)
+	}
+	if waiting > 0 {
+		logger.Printf("  Not yet due (skipped): %d", waiting)
+	}
+	logger.Println(strings.Repeat("=", 60))
 
 	return nil
 }
 
 func main() {
-	registerFlag := flag.Bool("register", false, "Register as magnet protocol handler")
-	unregisterFlag := flag.Bool("unregister", false, "Unregister magnet protocol handler")
-	retryFlag := flag.Bool("retry", false, "Process all items in retry queue")
-	backfillFlag := flag.Bool("backfill", false, "Backfill database from existing Deluge torrents")
-	syncFlag := flag.Bool("sync", false, "Remove database entries for torrents no longer in Deluge")
-	syncDryRunFlag := flag.Bool("sync-dry-run", false, "Show what would be removed without actually removing")
-	migrateFlag := flag.Bool("migrate", false, "Migrate JSON files to new format with proper checksums")
-	versionFlag := flag.Bool("version", false, "Show version")
-
 	// Configuration flags
 	delugeHostFlag := flag.String("host", "", "Deluge server host (e.g., 192.168.1.100)")
 	delugePortFlag := flag.String("port", "", "Deluge server port (default: 8112)")
 	delugePasswordFlag := flag.String("password", "", "Deluge server password")
 	delugeLabelFlag := flag.String("label", "", "Deluge label for torrents (e.g., audiobooks)")
-	remotePathFlag := flag.String("remote-path", "", "Path to shared/network storage for syncing (e.g., /mnt/nas/magnet-list.json)")
+	remotePathFlag := flag.String("remote-path", "", "Path to shared/network storage for syncing (e.g., /mnt/nas/magnet-list.json or gdrive:magnet-list.json via rclone)")
+	downloadLocationFlag := flag.String("download-location", "", "Deluge download location for added torrents")
+	moveCompletedFlag := flag.String("move-completed-path", "", "Move torrents to this path when they complete")
+	maxDownloadSpeedFlag := flag.Float64("max-download-speed", 0, "Per-torrent download limit in KiB/s (0 = unlimited)")
+	pausedFlag := flag.Bool("paused", false, "Add torrents in paused state")
+	refreshRemoteFlag := flag.Bool("refresh-remote", false, "Merge the remote database before checking for duplicates")
 	saveSettingsFlag := flag.Bool("save-settings", false, "Save command-line settings to config file for future use")
-	flag.Parse()
+	skipValidationFlag := flag.Bool("skip-validation", false, "With --save-settings, save Deluge connection settings without a test login")
+	flag.Var(&injectedFaults, "inject", "Inject remote sync faults in simulation mode (remote-write-fail, slow-remote=<duration>, truncate-remote)")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: magnet-handler [flags] <magnet-uri | @file>\n       magnet-handler [flags] <command> [command flags]\n       magnet-handler help <command>\n\nFlags:\n")
+		printFlagDefaults(flag.CommandLine)
+		printCommands()
+	}
+	osArgs, legacyFlag := legacyArgs(flag.CommandLine, os.Args[1:])
+	flag.CommandLine.Parse(osArgs)
+
+	// Pick the command: a named one, or add for a magnet URI, @file, or MAGNET_URI
+	args := flag.Args()
+	cmd, cmdArgs := addCommand, args
+	if len(args) > 0 {
+		if named, ok := lookupCommand(args[0]); ok {
+			cmd, cmdArgs = named, args[1:]
+		} else if commandName.MatchString(args[0]) {
+			fmt.Fprintf(os.Stderr, "Unknown command %q\n", args[0])
+			flag.Usage()
+			os.Exit(2)
+		}
+	}
+	clickedMagnet = cmd == addCommand && (len(args) == 0 || args[0] != addCommand.Name)
 
 	// Setup logging - use platform-specific log directory
 	logDir := GetDefaultLogDir()
@@ -1370,46 +2462,75 @@ func main() {
 		logDir = "."
 	}
 	logFile := filepath.Join(logDir, fmt.Sprintf("magnet-handler-%d.log", os.Getpid()))
+	// Log lines echo to stdout unless stdout carries exported data
+	console := io.Writer(progressConsole{os.Stdout})
+	if cmd == exportCommand && len(cmdArgs) < 2 {
+		console = os.Stderr
+	}
 	f, err := os.OpenFile(logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
 	if err == nil {
 		defer f.Close()
-		log.SetOutput(io.MultiWriter(os.Stdout, f))
+		log.SetOutput(io.MultiWriter(console, f))
 	}
 
+	// Tag every log line from this invocation so concurrent runs sharing
+	// the log directory can be told apart
+	setCorrelationID(newCorrelationID())
+
 	// Log startup
 	log.Printf("=== magnet-handler started at %s ===", time.Now().Format(time.RFC3339))
 	log.Printf("Args: %v", os.Args)
 	log.Printf("Log file: %s", logFile)
-
-	if *versionFlag {
-		fmt.Printf("magnet-handler version %s\n", version)
-		return
+	if legacyFlag != "" {
+		log.Printf("Note: %s is deprecated, use the %s command instead (magnet-handler help %s)", legacyFlag, cmd.Name, cmd.Name)
 	}
 
-	if *registerFlag {
-		exePath, err := os.Executable()
-		if err != nil {
-			log.Fatalf("Failed to get executable path: %v", err)
+	if injectedFaults.Active() {
+		if os.Getenv(simulateEnv) == "" {
+			fatalRun(cmd.Name, errors.New("--inject outside simulation mode"), "Error: --inject only works in simulation mode (set %s to a scratch directory)", simulateEnv)
 		}
-		if err := RegisterProtocolHandler(exePath); err != nil {
-			log.Fatalf("Failed to register protocol handler: %v", err)
-		}
-		return
+		log.Printf("⚠ Injecting faults: %s", injectedFaults.String())
 	}
 
-	if *unregisterFlag {
-		if err := UnregisterProtocolHandler(); err != nil {
-			log.Fatalf("Failed to unregister protocol handler: %v", err)
-		}
+	if cmd.NoConfig {
+		runCommand(cmd, Config{}, cmdArgs)
 		return
 	}
 
-	// Load config (needed for retry, backfill, migrate, and magnet handling)
+	// Load config (needed for every command but register and version)
 	config, err := LoadConfig()
 	if err != nil {
 		log.Printf("Warning: Failed to load config, using defaults: %v", err)
 		config = DefaultConfig()
+	} else {
+		ensureDeviceID(&config)
 	}
+	followMovedDatabase(&config)
+
+	if err := ResolveSecrets(&config); err != nil {
+		fatalRun(cmd.Name, err, "Failed to read secrets: %v", err)
+	}
+	envApplied, err := ApplyEnvOverrides(&config)
+	if err != nil {
+		fatalRun(cmd.Name, err, "Invalid environment settings: %v", err)
+	}
+	if len(envApplied) > 0 {
+		log.Printf("Settings from the environment: %s%s", envPrefix, strings.Join(envApplied, ", "+envPrefix))
+	}
+	if err := ConfigureIntegrity(config); err != nil {
+		fatalRun(cmd.Name, err, "Invalid integrity settings: %v", err)
+	}
+	if err := ConfigureDeviceKeys(config); err != nil {
+		fatalRun(cmd.Name, err, "Invalid device keys: %v", err)
+	}
+	if err := ConfigureMerge(config); err != nil {
+		fatalRun(cmd.Name, err, "Invalid merge settings: %v", err)
+	}
+	if err := ConfigureQuietHours(config); err != nil {
+		fatalRun(cmd.Name, err, "Invalid quiet hours: %v", err)
+	}
+	deliverQuietSummary()
+	ConfigureHTTPTransport(config)
 
 	// Apply command-line overrides
 	hasOverrides := false
@@ -1427,29 +2548,81 @@ func main() {
 	}
 	if *delugeLabelFlag != "" {
 		config.DelugeLabel = *delugeLabelFlag
+		explicitLabel = true
 		hasOverrides = true
 	}
 	if *remotePathFlag != "" {
 		config.RemotePath = *remotePathFlag
 		hasOverrides = true
 	}
+	if *downloadLocationFlag != "" {
+		config.DownloadLocation = *downloadLocationFlag
+		hasOverrides = true
+	}
+	if *moveCompletedFlag != "" {
+		config.MoveCompletedPath = *moveCompletedFlag
+		hasOverrides = true
+	}
+	if *maxDownloadSpeedFlag > 0 {
+		config.MaxDownloadSpeed = *maxDownloadSpeedFlag
+		hasOverrides = true
+	}
+	if *pausedFlag {
+		config.AddPaused = true
+		hasOverrides = true
+	}
+	if *refreshRemoteFlag {
+		config.RefreshBeforeAdd = true
+		hasOverrides = true
+	}
+
+	if dir := os.Getenv(simulateEnv); dir != "" {
+		enableSimulation(&config, dir)
+	}
+	if err := ConfigureWebUI(config); err != nil {
+		fatalRun(cmd.Name, err, "Invalid Web UI settings: %v", err)
+	}
+	if err := ConfigureReadFallbacks(config); err != nil {
+		fatalRun(cmd.Name, err, "Invalid read fallbacks: %v", err)
+	}
 
 	// Save settings if requested
 	if *saveSettingsFlag {
 		if !hasOverrides {
-			log.Fatal("Error: --save-settings requires at least one setting flag (--host, --port, --password, --label, or --remote-path)")
+			fatalRun(cmd.Name, errors.New("--save-settings without settings"), "Error: --save-settings requires at least one setting flag (--host, --port, --password, --label, --remote-path, or an add-torrent option)")
+		}
+		// Refuse to save connection settings Deluge does not accept, so a
+		// mistyped password cannot leave every add queued for retry
+		connectionChanged := *delugeHostFlag != "" || *delugePortFlag != "" || *delugePasswordFlag != ""
+		if connectionChanged && !*skipValidationFlag {
+			if err := validateDeluge(config); err != nil {
+				fatalRun(cmd.Name, err, "Error: %v; settings not saved (use --skip-validation to save anyway)", err)
+			}
+		}
+		stored, err := LoadConfig()
+		if err != nil {
+			stored = DefaultConfig()
 		}
-		if err := SaveConfig(config); err != nil {
+		flagged := make(map[string]bool)
+		flag.Visit(func(f *flag.Flag) { flagged[f.Name] = true })
+		saved := withoutEnvOverrides(config, stored, envApplied, flagged)
+		if err := SaveConfig(unresolvedSecrets(saved, stored)); err != nil {
 			log.Printf("Warning: Failed to save config: %v", err)
 		} else {
 			log.Printf("Settings saved to config file:")
-			log.Printf("  Host: %s", config.DelugeHost)
-			log.Printf("  Port: %s", config.DelugePort)
-			log.Printf("  Label: %s", config.DelugeLabel)
-			log.Printf("  Remote path: %s", config.RemotePath)
+			log.Printf("  Host: %s", saved.DelugeHost)
+			log.Printf("  Port: %s", saved.DelugePort)
+			log.Printf("  Label: %s", saved.DelugeLabel)
+			log.Printf("  Remote path: %s", displayRemote(saved.RemotePath))
+			log.Printf("  Download location: %s", saved.DownloadLocation)
+			log.Printf("  Move completed path: %s", saved.MoveCompletedPath)
+			log.Printf("  Max download speed: %.0f KiB/s", saved.MaxDownloadSpeed)
+			log.Printf("  Add paused: %v", saved.AddPaused)
+			log.Printf("  Refresh remote before add: %v", saved.RefreshBeforeAdd)
 		}
-		// If only saving settings (no other operation or magnet URI), exit cleanly
-		if len(flag.Args()) == 0 && !*migrateFlag && !*backfillFlag && !*retryFlag && !*syncFlag && !*syncDryRunFlag {
+		// If only saving settings (no command or magnet URI), exit cleanly
+		if len(args) == 0 && os.Getenv(magnetURIEnv) == "" {
+			logRunSummary("save-settings", nil)
 			return
 		}
 	}
@@ -1460,77 +2633,19 @@ func main() {
 		log.Printf("         Set your actual Deluge server IP with: --host YOUR_IP --save-settings")
 	}
 
-	if *migrateFlag {
-		log.Println("Migrating both local and remote databases...")
-
-		// Migrate local
-		if err := MigrateFileFormat(config.JSONPath); err != nil {
-			log.Printf("Error migrating local: %v", err)
-		}
-
-		// Migrate remote
-		remotePath := GetRemotePath(&config)
-		if remotePath != "" {
-			if err := MigrateFileFormat(remotePath); err != nil {
-				log.Printf("Error migrating remote: %v", err)
-			}
-		} else {
-			log.Println("No remote path configured, skipping remote migration")
-		}
-
-		log.Println("✓ Migration complete")
-		return
-	}
-
-	if *backfillFlag {
-		if err := BackfillFromDeluge(config); err != nil {
-			log.Fatalf("Failed to backfill from Deluge: %v", err)
-		}
-		return
-	}
-
-	if *syncDryRunFlag {
-		if err := SyncWithDeluge(config, true); err != nil {
-			log.Fatalf("Sync dry run failed: %v", err)
-		}
-		return
-	}
-
-	if *syncFlag {
-		if err := SyncWithDeluge(config, false); err != nil {
-			log.Fatalf("Sync failed: %v", err)
-		}
-		return
-	}
-
-	if *retryFlag {
-		if err := ProcessRetryQueue(config); err != nil {
-			log.Fatalf("Failed to process retry queue: %v", err)
-		}
-		return
-	}
-
-	// Handle magnet URI
-	args := flag.Args()
-	if len(args) == 0 {
-		log.Fatal("No magnet URI provided")
-	}
-
-	magnetURI := args[0]
-	// Clean up URI (remove quotes that may be added by shell)
-	magnetURI = strings.Trim(magnetURI, `"'`)
+	runCommand(cmd, config, cmdArgs)
+}
 
-	// Process magnet
-	if err := AddMagnetToDeluge(magnetURI, config); err != nil {
-		log.Fatalf("Error: %v", err)
+// runCommand runs a command and logs the summary line, exiting non-zero
+// when it fails
+func runCommand(cmd *Command, config Config, args []string) {
+	err := cmd.Run(config, args)
+	switch {
+	case err == nil, errors.Is(err, flag.ErrHelp):
+		logRunSummary(cmd.Name, nil)
+	case cmd == addCommand:
+		fatalRun(cmd.Name, err, "Error: %v", err)
+	default:
+		fatalRun(cmd.Name, err, "%s failed: %v", cmd.Name, err)
 	}
-
-	// Keep the app open for a moment so we can see output
-	// This is especially useful when launched from browsers
-	log.Println("\n=== Magnet Handler Complete ===")
-	log.Println("Keeping window open for 90 seconds to view results...")
-	log.Println("Press Ctrl+C to close earlier if needed")
-
-	// Sleep for 90 seconds to allow viewing the output
-	time.Sleep(90 * time.Second)
 }
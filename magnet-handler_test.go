@@ -2,12 +2,39 @@ package main
 
 import (
 	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
+// fakeDeluge starts a test server answering Deluge JSON-RPC calls with the
+// given handler, and returns a client pointed at it
+func fakeDeluge(t *testing.T, handler func(method string, params []interface{}) (interface{}, interface{})) *DelugeClient {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method string        `json:"method"`
+			Params []interface{} `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Fake Deluge failed to decode request: %v", err)
+			return
+		}
+		result, rpcErr := handler(req.Method, req.Params)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"result": result, "error": rpcErr, "id": 1})
+	}))
+	t.Cleanup(server.Close)
+
+	client := NewDelugeClient("127.0.0.1", "0", "password")
+	client.BaseURL = server.URL + "/json"
+	return client
+}
+
 // Test ValidateMagnetURI
 func TestValidateMagnetURI(t *testing.T) {
 	tests := []struct {
@@ -688,3 +715,437 @@ func TestComputeFileChecksum(t *testing.T) {
 		t.Error("ComputeFileChecksum should error for non-existent file")
 	}
 }
+
+// Test MergeDatabasesWithConflicts flags diverging status
+func TestMergeDatabasesConflicts(t *testing.T) {
+	local := &MagnetDatabase{
+		Added: map[string]MagnetEntry{
+			"hash1": {ID: 1, Hash: "hash1", Title: "Stale", AddedDate: "2024-01-01T00:00:00Z"},
+			"hash2": {ID: 2, Hash: "hash2", Title: "Resolved", AddedDate: "2024-01-05T00:00:00Z"},
+		},
+		Retry: map[string]MagnetEntry{},
+	}
+
+	remote := &MagnetDatabase{
+		Added: map[string]MagnetEntry{},
+		Retry: map[string]MagnetEntry{
+			// Retried after local added it: true conflict
+			"hash1": {ID: 1, Hash: "hash1", Title: "Stale", LastAttempt: "2024-01-02T00:00:00Z", RetryCount: 2},
+			// Retried before local added it: stale retry, no conflict
+			"hash2": {ID: 2, Hash: "hash2", Title: "Resolved", LastAttempt: "2024-01-04T00:00:00Z", RetryCount: 1},
+		},
+	}
+
+	merged, conflicts := MergeDatabasesWithConflicts(local, remote)
+
+	if len(conflicts) != 1 {
+		t.Fatalf("Expected 1 conflict, got %d", len(conflicts))
+	}
+	if conflicts[0].Hash != "hash1" {
+		t.Errorf("Expected conflict on hash1, got %q", conflicts[0].Hash)
+	}
+
+	entry, exists := merged.Added["hash1"]
+	if !exists {
+		t.Fatal("Conflicting entry should remain in Added")
+	}
+	if !entry.Conflict || entry.ConflictInfo == "" {
+		t.Error("Conflicting entry should be flagged with conflict info")
+	}
+	if merged.Added["hash2"].Conflict {
+		t.Error("Stale retry should not be flagged as a conflict")
+	}
+
+	// Merging again should not report the same conflict twice
+	_, conflicts = MergeDatabasesWithConflicts(merged, remote)
+	if len(conflicts) != 0 {
+		t.Errorf("Already flagged conflict should not be reported again, got %d", len(conflicts))
+	}
+}
+
+// Test MergeDatabases picks the winning copy by section, timestamp, and tie-break
+func TestMergeDatabasesTimestamps(t *testing.T) {
+	defer func() { mergeTieBreak = TieBreakID }()
+
+	tests := []struct {
+		name      string
+		policy    string
+		local     MagnetEntry
+		localAdd  bool
+		remote    MagnetEntry
+		remoteAdd bool
+		want      string // Title of the winner
+		wantAdded bool
+	}{
+		{"newer added copy wins over higher ID", TieBreakID,
+			MagnetEntry{ID: 5, Title: "local", AddedDate: "2024-01-01T00:00:00Z"}, true,
+			MagnetEntry{ID: 2, Title: "remote", AddedDate: "2024-01-01T00:00:00Z", AddedToDeluge: "2024-01-03T00:00:00Z"}, true,
+			"remote", true},
+		{"offsets are compared as instants", TieBreakID,
+			MagnetEntry{ID: 1, Title: "local", LastAttempt: "2024-01-01T10:00:00+02:00"}, false,
+			MagnetEntry{ID: 1, Title: "remote", LastAttempt: "2024-01-01T09:00:00Z"}, false,
+			"remote", false},
+		{"added beats a newer retry with a higher ID", TieBreakID,
+			MagnetEntry{ID: 1, Title: "local", AddedDate: "2024-01-01T00:00:00Z"}, true,
+			MagnetEntry{ID: 9, Title: "remote", LastAttempt: "2024-02-01T00:00:00Z", RetryCount: 3}, false,
+			"local", true},
+		{"added beats a stale retry", TieBreakID,
+			MagnetEntry{ID: 9, Title: "local", LastAttempt: "2023-12-01T00:00:00Z", RetryCount: 1}, false,
+			MagnetEntry{ID: 1, Title: "remote", AddedDate: "2024-01-01T00:00:00Z"}, true,
+			"remote", true},
+		{"tie goes to the higher ID", TieBreakID,
+			MagnetEntry{ID: 1, Title: "local", AddedDate: "2024-01-01T00:00:00Z"}, true,
+			MagnetEntry{ID: 2, Title: "remote", AddedDate: "2024-01-01T00:00:00Z"}, true,
+			"remote", true},
+		{"tie goes to local", TieBreakLocal,
+			MagnetEntry{ID: 1, Title: "local", AddedDate: "2024-01-01T00:00:00Z"}, true,
+			MagnetEntry{ID: 2, Title: "remote", AddedDate: "2024-01-01T00:00:00Z"}, true,
+			"local", true},
+		{"tie goes to remote", TieBreakRemote,
+			MagnetEntry{ID: 2, Title: "local", RetryCount: 1}, false,
+			MagnetEntry{ID: 1, Title: "remote", RetryCount: 1}, false,
+			"remote", false},
+		{"policy does not override a newer copy", TieBreakRemote,
+			MagnetEntry{ID: 1, Title: "local", LastAttempt: "2024-01-02T00:00:00Z"}, false,
+			MagnetEntry{ID: 1, Title: "remote", LastAttempt: "2024-01-01T00:00:00Z"}, false,
+			"local", false},
+	}
+
+	for _, tt := range tests {
+		if err := ConfigureMerge(Config{MergeTieBreak: tt.policy}); err != nil {
+			t.Fatalf("ConfigureMerge(%q) failed: %v", tt.policy, err)
+		}
+		local := &MagnetDatabase{Added: map[string]MagnetEntry{}, Retry: map[string]MagnetEntry{}}
+		remote := &MagnetDatabase{Added: map[string]MagnetEntry{}, Retry: map[string]MagnetEntry{}}
+		tt.local.Hash, tt.remote.Hash = "hash1", "hash1"
+		if tt.localAdd {
+			local.Added["hash1"] = tt.local
+		} else {
+			local.Retry["hash1"] = tt.local
+		}
+		if tt.remoteAdd {
+			remote.Added["hash1"] = tt.remote
+		} else {
+			remote.Retry["hash1"] = tt.remote
+		}
+
+		merged := MergeDatabases(local, remote)
+		entry, inAdded := merged.Added["hash1"]
+		if !inAdded {
+			entry = merged.Retry["hash1"]
+		}
+		if entry.Title != tt.want || inAdded != tt.wantAdded {
+			t.Errorf("%s: winner %q (added=%v), want %q (added=%v)", tt.name, entry.Title, inAdded, tt.want, tt.wantAdded)
+		}
+		if len(merged.Added)+len(merged.Retry) != 1 {
+			t.Errorf("%s: expected the entry in exactly one section", tt.name)
+		}
+	}
+
+	if err := ConfigureMerge(Config{MergeTieBreak: "coin-flip"}); err == nil {
+		t.Error("Expected error for an unknown tie-break policy")
+	}
+}
+
+// Test AddTorrentOptions conversion to Deluge options map
+func TestAddTorrentOptionsToMap(t *testing.T) {
+	empty := AddTorrentOptions{}.toMap()
+	if len(empty) != 0 {
+		t.Errorf("Empty options should produce empty map, got %v", empty)
+	}
+
+	config := Config{
+		DownloadLocation:  "/downloads/audiobooks",
+		AddPaused:         true,
+		MaxDownloadSpeed:  512,
+		MoveCompletedPath: "/library/audiobooks",
+	}
+	options := AddOptionsFromConfig(config).toMap()
+
+	if options["download_location"] != "/downloads/audiobooks" {
+		t.Errorf("download_location: got %v", options["download_location"])
+	}
+	if options["add_paused"] != true {
+		t.Errorf("add_paused: got %v", options["add_paused"])
+	}
+	if options["max_download_speed"] != 512.0 {
+		t.Errorf("max_download_speed: got %v", options["max_download_speed"])
+	}
+	if options["move_completed"] != true || options["move_completed_path"] != "/library/audiobooks" {
+		t.Errorf("move_completed: got %v, %v", options["move_completed"], options["move_completed_path"])
+	}
+}
+
+// Test per-label ratio options only apply to their label
+func TestAddOptionsForLabel(t *testing.T) {
+	config := Config{
+		DelugeLabel: "audiobooks",
+		LabelOptions: map[string]LabelOptions{
+			"audiobooks": {StopRatio: 2.0},
+			"linux-isos": {StopAtRatio: true},
+		},
+	}
+
+	options := AddOptionsFromConfig(config).toMap()
+	if options["stop_at_ratio"] != true || options["stop_ratio"] != 2.0 {
+		t.Errorf("audiobooks: got stop_at_ratio=%v stop_ratio=%v", options["stop_at_ratio"], options["stop_ratio"])
+	}
+
+	options = AddOptionsForLabel(config, "linux-isos").toMap()
+	if options["stop_at_ratio"] != true {
+		t.Errorf("linux-isos: stop_at_ratio should be set, got %v", options["stop_at_ratio"])
+	}
+	if _, ok := options["stop_ratio"]; ok {
+		t.Error("linux-isos: stop_ratio should fall back to the Deluge default")
+	}
+
+	options = AddOptionsForLabel(config, "movies").toMap()
+	if len(options) != 0 {
+		t.Errorf("Unconfigured label should use defaults, got %v", options)
+	}
+}
+
+// Test loadDatabaseForAdd sees remote entries when refresh is enabled
+func TestLoadDatabaseForAddRefresh(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	localPath := filepath.Join(tmpDir, "local.json")
+	remotePath := filepath.Join(tmpDir, "remote.json")
+
+	remote := &MagnetDatabase{
+		Added: map[string]MagnetEntry{
+			"hash1": {ID: 1, Hash: "hash1", Title: "Added elsewhere"},
+		},
+		Retry: map[string]MagnetEntry{},
+	}
+	if err := SaveDatabaseLocal(remotePath, remote); err != nil {
+		t.Fatalf("Failed to write remote: %v", err)
+	}
+
+	config := Config{JSONPath: localPath, RemotePath: remotePath}
+
+	db, err := loadDatabaseForAdd(config)
+	if err != nil {
+		t.Fatalf("loadDatabaseForAdd failed: %v", err)
+	}
+	if _, exists := db.Added["hash1"]; exists {
+		t.Error("Without refresh, remote entries should not be visible")
+	}
+
+	config.RefreshBeforeAdd = true
+	db, err = loadDatabaseForAdd(config)
+	if err != nil {
+		t.Fatalf("loadDatabaseForAdd failed: %v", err)
+	}
+	if _, exists := db.Added["hash1"]; !exists {
+		t.Error("With refresh, remote entries should be visible")
+	}
+}
+
+// Test WaitForMetadata polls until the torrent name resolves
+func TestWaitForMetadata(t *testing.T) {
+	originalInterval := metadataPollInterval
+	metadataPollInterval = time.Millisecond
+	defer func() { metadataPollInterval = originalInterval }()
+
+	hash := "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	polls := 0
+	client := fakeDeluge(t, func(method string, params []interface{}) (interface{}, interface{}) {
+		if method != "core.get_torrent_status" {
+			t.Errorf("Unexpected method %q", method)
+		}
+		polls++
+		name := hash
+		if polls >= 3 {
+			name = "Resolved Name"
+		}
+		return map[string]interface{}{"name": name, "save_path": "/downloads", "hash": hash}, nil
+	})
+
+	entry := MagnetEntry{Hash: hash}
+	captureTorrentDetails(client, hash, &entry, Config{MetadataTimeout: 5})
+
+	if polls != 3 {
+		t.Errorf("Expected 3 polls, got %d", polls)
+	}
+	if entry.TorrentName != "Resolved Name" {
+		t.Errorf("TorrentName: got %q", entry.TorrentName)
+	}
+	if entry.SavePath != "/downloads" {
+		t.Errorf("SavePath: got %q", entry.SavePath)
+	}
+	if entry.TorrentID != hash || entry.AddedToDeluge == "" {
+		t.Error("TorrentID and AddedToDeluge should be recorded")
+	}
+}
+
+// Test applyCompletionStatus records state, progress and completion time
+func TestApplyCompletionStatus(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	entry := MagnetEntry{Hash: "hash1"}
+
+	changed := applyCompletionStatus(&entry, map[string]interface{}{"state": "Downloading", "progress": 42.5}, now)
+	if !changed {
+		t.Error("First status should be reported as a change")
+	}
+	if entry.State != "Downloading" || entry.Progress != 42.5 {
+		t.Errorf("Unexpected state/progress: %q %v", entry.State, entry.Progress)
+	}
+	if entry.CompletedAt != "" {
+		t.Error("Incomplete torrent should not have CompletedAt")
+	}
+
+	applyCompletionStatus(&entry, map[string]interface{}{"state": "Seeding", "progress": 100.0}, now)
+	if entry.CompletedAt != now.Format(time.RFC3339) {
+		t.Errorf("CompletedAt: got %q", entry.CompletedAt)
+	}
+
+	// Completion time is kept once set
+	later := now.Add(time.Hour)
+	if applyCompletionStatus(&entry, map[string]interface{}{"state": "Seeding", "progress": 100.0}, later) {
+		t.Error("Unchanged status should not be reported as a change")
+	}
+	if entry.CompletedAt != now.Format(time.RFC3339) {
+		t.Error("CompletedAt should not be overwritten")
+	}
+}
+
+// Test MergeDatabases honors removals unless re-added afterwards
+func TestMergeDatabasesRemoved(t *testing.T) {
+	local := &MagnetDatabase{
+		Added: map[string]MagnetEntry{},
+		Retry: map[string]MagnetEntry{},
+		Removed: map[string]MagnetEntry{
+			"hash1": {ID: 1, Hash: "hash1", AddedDate: "2024-01-01T00:00:00Z", RemovedAt: "2024-01-10T00:00:00Z"},
+			"hash2": {ID: 2, Hash: "hash2", AddedDate: "2024-01-01T00:00:00Z", RemovedAt: "2024-01-10T00:00:00Z"},
+		},
+	}
+
+	remote := &MagnetDatabase{
+		Added: map[string]MagnetEntry{
+			// Stale copy from before the removal
+			"hash1": {ID: 1, Hash: "hash1", AddedDate: "2024-01-01T00:00:00Z"},
+			// Re-added after the removal
+			"hash2": {ID: 3, Hash: "hash2", AddedDate: "2024-01-15T00:00:00Z"},
+		},
+		Retry: map[string]MagnetEntry{},
+	}
+
+	merged := MergeDatabases(local, remote)
+
+	if _, exists := merged.Removed["hash1"]; !exists {
+		t.Error("hash1 should stay removed")
+	}
+	if _, exists := merged.Added["hash1"]; exists {
+		t.Error("Stale remote copy should not resurrect hash1")
+	}
+	if _, exists := merged.Added["hash2"]; !exists {
+		t.Error("hash2 was re-added after removal and should be in Added")
+	}
+	if _, exists := merged.Removed["hash2"]; exists {
+		t.Error("hash2 should no longer be in Removed")
+	}
+}
+
+// Test PauseTorrent and ResumeTorrent call the right Deluge methods
+func TestPauseResumeTorrent(t *testing.T) {
+	var calls []string
+	client := fakeDeluge(t, func(method string, params []interface{}) (interface{}, interface{}) {
+		calls = append(calls, method+" "+params[0].(string))
+		if params[0] == "missing" {
+			return nil, map[string]interface{}{"message": "Torrent not found"}
+		}
+		return nil, nil
+	})
+
+	if err := client.PauseTorrent("hash1"); err != nil {
+		t.Errorf("PauseTorrent failed: %v", err)
+	}
+	if err := client.ResumeTorrent("hash1"); err != nil {
+		t.Errorf("ResumeTorrent failed: %v", err)
+	}
+	if err := client.PauseTorrent("missing"); err == nil {
+		t.Error("PauseTorrent should surface Deluge errors")
+	}
+
+	expected := []string{"core.pause_torrent hash1", "core.resume_torrent hash1", "core.pause_torrent missing"}
+	if strings.Join(calls, ",") != strings.Join(expected, ",") {
+		t.Errorf("Unexpected calls: %v", calls)
+	}
+}
+
+// Test MigrateFileFormat backs up the original and verifies the result
+func TestMigrateFileFormatBackup(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	original := []byte(`{"hash1": {"hash": "hash1", "title": "Python Entry", "first_seen": "2023-01-01T00:00:00Z"}}`)
+	dbPath := filepath.Join(tmpDir, "legacy.json")
+	if err := os.WriteFile(dbPath, original, 0644); err != nil {
+		t.Fatalf("Failed to write legacy file: %v", err)
+	}
+
+	if err := MigrateFileFormat(dbPath); err != nil {
+		t.Fatalf("MigrateFileFormat failed: %v", err)
+	}
+
+	backups, _ := filepath.Glob(dbPath + ".pre-migrate-*.bak")
+	if len(backups) != 1 {
+		t.Fatalf("Expected 1 backup, found %d", len(backups))
+	}
+	backup, _ := os.ReadFile(backups[0])
+	if string(backup) != string(original) {
+		t.Error("Backup should match the original file byte for byte")
+	}
+
+	if _, err := os.Stat(dbPath + ".migrating"); !os.IsNotExist(err) {
+		t.Error("Temporary migration file should not be left behind")
+	}
+
+	db, err := LoadJSONDatabase(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to load migrated file: %v", err)
+	}
+	if db.Added["hash1"].Title != "Python Entry" || db.Metadata.ChecksumAlgorithm == "" {
+		t.Error("Migrated file should be in the current format with the original entry")
+	}
+}
+
+// Test verifyMigration detects lost or altered entries
+func TestVerifyMigration(t *testing.T) {
+	original := &MagnetDatabase{
+		Added: map[string]MagnetEntry{"hash1": {Hash: "hash1", URI: "magnet:?xt=urn:btih:hash1"}},
+		Retry: map[string]MagnetEntry{"hash2": {Hash: "hash2"}},
+	}
+
+	same := &MagnetDatabase{
+		Added: map[string]MagnetEntry{"hash1": {Hash: "hash1", URI: "magnet:?xt=urn:btih:hash1"}},
+		Retry: map[string]MagnetEntry{"hash2": {Hash: "hash2"}},
+	}
+	if err := verifyMigration(original, same); err != nil {
+		t.Errorf("Identical databases should verify: %v", err)
+	}
+
+	lost := &MagnetDatabase{
+		Added: map[string]MagnetEntry{"hash1": {Hash: "hash1", URI: "magnet:?xt=urn:btih:hash1"}},
+		Retry: map[string]MagnetEntry{},
+	}
+	if err := verifyMigration(original, lost); err == nil {
+		t.Error("Lost entry should fail verification")
+	}
+
+	moved := &MagnetDatabase{
+		Added: map[string]MagnetEntry{"hash2": {Hash: "hash2"}},
+		Retry: map[string]MagnetEntry{"hash1": {Hash: "hash1", URI: "magnet:?xt=urn:btih:hash1"}},
+	}
+	if err := verifyMigration(original, moved); err == nil {
+		t.Error("Entries in the wrong section should fail verification")
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Kinds of change a merge makes to one side's database
+const (
+	ChangeAdded       = "added"       // Hash new to this side
+	ChangeOverwritten = "overwritten" // Same section, different fields
+	ChangeMoved       = "moved"       // Different section, e.g. retry to added
+	ChangeDropped     = "dropped"     // Removed by a tombstone
+)
+
+// MergeChange is one entry a merge would change in a database
+type MergeChange struct {
+	Hash   string
+	Title  string
+	Kind   string
+	From   string   // Section before the merge (empty when added)
+	To     string   // Section after the merge (empty when dropped)
+	Fields []string // Fields that changed, by JSON name
+}
+
+// entrySection returns the section holding hash and the entry stored there
+func entrySection(db *MagnetDatabase, hash string) (string, MagnetEntry, bool) {
+	for _, section := range []struct {
+		name    string
+		entries map[string]MagnetEntry
+	}{
+		{SectionAdded, db.Added},
+		{SectionRetry, db.Retry},
+		{SectionRemoved, db.Removed},
+	} {
+		if entry, ok := section.entries[hash]; ok {
+			return section.name, entry, true
+		}
+	}
+	return "", MagnetEntry{}, false
+}
+
+// changedFields lists the JSON names of fields that differ between entries
+func changedFields(before, after MagnetEntry) []string {
+	var fields []string
+	b, a := reflect.ValueOf(before), reflect.ValueOf(after)
+	for i := 0; i < b.NumField(); i++ {
+		if !reflect.DeepEqual(b.Field(i).Interface(), a.Field(i).Interface()) {
+			name, _, _ := strings.Cut(b.Type().Field(i).Tag.Get("json"), ",")
+			fields = append(fields, name)
+		}
+	}
+	return fields
+}
+
+// diffDatabases lists what changes between a database and the merge result
+func diffDatabases(before, after *MagnetDatabase) []MergeChange {
+	hashes := make(map[string]bool)
+	for _, db := range []*MagnetDatabase{before, after} {
+		for _, entries := range []map[string]MagnetEntry{db.Added, db.Retry, db.Removed} {
+			for hash := range entries {
+				hashes[hash] = true
+			}
+		}
+	}
+
+	var changes []MergeChange
+	for hash := range hashes {
+		from, old, hadEntry := entrySection(before, hash)
+		to, entry, hasEntry := entrySection(after, hash)
+		change := MergeChange{Hash: hash, Title: entry.Title, From: from, To: to}
+		switch {
+		case !hadEntry:
+			change.Kind = ChangeAdded
+		case !hasEntry:
+			change.Kind, change.Title = ChangeDropped, old.Title
+		case from != to:
+			change.Kind = ChangeMoved
+		default:
+			change.Fields = changedFields(old, entry)
+			if len(change.Fields) == 0 {
+				continue
+			}
+			change.Kind = ChangeOverwritten
+		}
+		changes = append(changes, change)
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Kind != changes[j].Kind {
+			return changes[i].Kind < changes[j].Kind
+		}
+		return changes[i].Hash < changes[j].Hash
+	})
+	return changes
+}
+
+// writeMergeChanges prints the changes to one side of a merge
+func writeMergeChanges(w io.Writer, heading string, changes []MergeChange) {
+	fmt.Fprintf(w, "%s: %s\n", heading, plural(len(changes), "change", "changes"))
+	for _, c := range changes {
+		var detail string
+		switch c.Kind {
+		case ChangeAdded:
+			detail = "into " + c.To
+		case ChangeDropped:
+			detail = "from " + c.From
+		case ChangeMoved:
+			detail = c.From + " → " + c.To
+		case ChangeOverwritten:
+			detail = strings.Join(c.Fields, ", ")
+		}
+		fmt.Fprintf(w, "  %-11s %s  %s (%s)\n", c.Kind, c.Hash[:min(8, len(c.Hash))], c.Title, detail)
+	}
+}
+
+// SyncRemoteDryRun shows what merging the local database with every remote
+// would change on each side, without writing any of them
+func SyncRemoteDryRun(config Config, w io.Writer) error {
+	remotes := GetRemotePaths(&config)
+	if len(remotes) == 0 {
+		return fmt.Errorf("no remote_path configured")
+	}
+
+	local, err := LoadJSONDatabase(config.JSONPath)
+	if err != nil {
+		return fmt.Errorf("failed to load local: %w", err)
+	}
+
+	type loadedRemote struct {
+		path string
+		db   *MagnetDatabase
+	}
+	var loaded []loadedRemote
+	var conflicts []MergeConflict
+	merged := local
+	for _, remotePath := range remotes {
+		remoteFile := fetchRemote(remotePath)
+		if _, err := os.Stat(remoteFile); os.IsNotExist(err) {
+			fmt.Fprintf(w, "Remote %s does not exist or could not be fetched; the next save would create it from the local database\n", displayRemote(remotePath))
+			continue
+		}
+		remote, err := LoadJSONDatabase(remoteFile)
+		if err != nil {
+			return fmt.Errorf("failed to load remote %s: %w", displayRemote(remotePath), err)
+		}
+		if err := VerifyDatabase(remote); err != nil {
+			return fmt.Errorf("remote %s failed integrity check, it would not be merged: %w", displayRemote(remotePath), err)
+		}
+		var found []MergeConflict
+		merged, found = MergeDatabasesWithConflicts(merged, remote)
+		conflicts = append(conflicts, found...)
+		loaded = append(loaded, loadedRemote{remotePath, remote})
+	}
+	if len(loaded) == 0 {
+		return nil
+	}
+
+	names := make([]string, len(loaded))
+	for i, remote := range loaded {
+		names[i] = displayRemote(remote.path)
+	}
+	fmt.Fprintf(w, "Merging %s with %s\n\n", config.JSONPath, strings.Join(names, ", "))
+	writeMergeChanges(w, "Local database", diffDatabases(local, merged))
+	for _, remote := range loaded {
+		heading := "Remote database"
+		if len(remotes) > 1 {
+			heading = "Remote " + displayRemote(remote.path)
+		}
+		fmt.Fprintln(w)
+		writeMergeChanges(w, heading, diffDatabases(remote.db, merged))
+	}
+	if len(conflicts) > 0 {
+		fmt.Fprintf(w, "\nConflicts that would be flagged: %d\n", len(conflicts))
+		for _, c := range conflicts {
+			fmt.Fprintf(w, "  %s  %s: %s\n", c.Hash[:min(8, len(c.Hash))], c.Title, c.Detail)
+		}
+	}
+	fmt.Fprintln(w, "\nDry run: nothing was written")
+	return nil
+}
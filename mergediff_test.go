@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Test diffDatabases classifies added, moved, overwritten, and dropped entries
+func TestDiffDatabases(t *testing.T) {
+	before := &MagnetDatabase{
+		Added: map[string]MagnetEntry{
+			"same": {ID: 1, Hash: "same", Title: "Unchanged"},
+			"edit": {ID: 2, Hash: "edit", Title: "Edited", Label: "books"},
+			"gone": {ID: 3, Hash: "gone", Title: "Buried"},
+		},
+		Retry: map[string]MagnetEntry{
+			"move": {ID: 4, Hash: "move", Title: "Moved", RetryCount: 2},
+		},
+	}
+	after := &MagnetDatabase{
+		Added: map[string]MagnetEntry{
+			"same": {ID: 1, Hash: "same", Title: "Unchanged"},
+			"edit": {ID: 2, Hash: "edit", Title: "Edited", Label: "podcasts", RetryCount: 1},
+			"move": {ID: 4, Hash: "move", Title: "Moved", RetryCount: 2},
+			"new":  {ID: 5, Hash: "new", Title: "New"},
+		},
+		Retry: map[string]MagnetEntry{},
+	}
+
+	changes := diffDatabases(before, after)
+	got := make(map[string]MergeChange)
+	for _, c := range changes {
+		got[c.Hash] = c
+	}
+	if len(changes) != 4 {
+		t.Fatalf("Expected 4 changes, got %+v", changes)
+	}
+	if c := got["new"]; c.Kind != ChangeAdded || c.To != SectionAdded {
+		t.Errorf("new: %+v", c)
+	}
+	if c := got["move"]; c.Kind != ChangeMoved || c.From != SectionRetry || c.To != SectionAdded {
+		t.Errorf("move: %+v", c)
+	}
+	if c := got["edit"]; c.Kind != ChangeOverwritten || strings.Join(c.Fields, ",") != "retry_count,label" {
+		t.Errorf("edit: %+v", c)
+	}
+	if c := got["gone"]; c.Kind != ChangeDropped || c.Title != "Buried" {
+		t.Errorf("gone: %+v", c)
+	}
+}
+
+// Test SyncRemoteDryRun reports both sides without touching either file
+func TestSyncRemoteDryRun(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	t.Setenv("HOME", tmpDir)
+
+	config := Config{JSONPath: filepath.Join(tmpDir, "local.json"), RemotePath: filepath.Join(tmpDir, "remote.json")}
+	local := &MagnetDatabase{
+		Added: map[string]MagnetEntry{"hash1": {ID: 1, Hash: "hash1", Title: "Local Book", AddedDate: "2024-01-02T00:00:00Z"}},
+		Retry: map[string]MagnetEntry{},
+	}
+	remote := &MagnetDatabase{
+		Added: map[string]MagnetEntry{},
+		Retry: map[string]MagnetEntry{
+			"hash1": {ID: 1, Hash: "hash1", Title: "Local Book", LastAttempt: "2024-01-03T00:00:00Z", RetryCount: 1},
+			"hash2": {ID: 2, Hash: "hash2", Title: "Remote Book", AddedDate: "2024-01-01T00:00:00Z"},
+		},
+	}
+	if err := SaveDatabaseLocal(config.JSONPath, local); err != nil {
+		t.Fatalf("Failed to save local: %v", err)
+	}
+	if err := SaveDatabaseLocal(config.RemotePath, remote); err != nil {
+		t.Fatalf("Failed to save remote: %v", err)
+	}
+	localBefore, _ := os.ReadFile(config.JSONPath)
+	remoteBefore, _ := os.ReadFile(config.RemotePath)
+
+	var buf bytes.Buffer
+	if err := SyncRemoteDryRun(config, &buf); err != nil {
+		t.Fatalf("SyncRemoteDryRun failed: %v", err)
+	}
+	output := buf.String()
+
+	for _, want := range []string{
+		"Local database: 2 changes",
+		"added       hash2  Remote Book (into retry)",
+		"overwritten hash1  Local Book (conflict, conflict_info)",
+		"Remote database: 1 change",
+		"moved       hash1  Local Book (retry → added)",
+		"Conflicts that would be flagged: 1",
+		"nothing was written",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected %q in output:\n%s", want, output)
+		}
+	}
+
+	localAfter, _ := os.ReadFile(config.JSONPath)
+	remoteAfter, _ := os.ReadFile(config.RemotePath)
+	if !bytes.Equal(localBefore, localAfter) || !bytes.Equal(remoteBefore, remoteAfter) {
+		t.Error("Dry run modified a database file")
+	}
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// movedPrefix starts the pointer file migrate-paths leaves where a file
+// used to be, so anything still using the old path finds the new one
+// instead of quietly starting a second database there
+const movedPrefix = "magnet-handler: moved to "
+
+var migratePathsCommand = &Command{
+	Name:    "migrate-paths",
+	Usage:   "migrate-paths [--database path] [--dry-run]",
+	Summary: "Move the config, database, and logs from old locations to the current defaults",
+}
+
+func init() {
+	migratePathsCommand.Run = runMigratePaths
+	registerCommand(migratePathsCommand)
+}
+
+// pathMove is one file migrate-paths moves
+type pathMove struct {
+	What string
+	From string
+	To   string
+}
+
+// xdgDir returns name under the XDG base directory in env, or "" when env
+// is unset or not absolute (which the spec says to ignore)
+func xdgDir(env, name string) string {
+	base := os.Getenv(env)
+	if base == "" || !filepath.IsAbs(base) {
+		return ""
+	}
+	return filepath.Join(base, name)
+}
+
+// legacyDatabasePath is where the database lived before XDG support
+func legacyDatabasePath(homeDir string) string {
+	return filepath.Join(homeDir, "magnet-list-local.json")
+}
+
+// preferredDatabasePath is where a new database goes: under
+// $XDG_DATA_HOME when it is set, otherwise the home directory
+func preferredDatabasePath(homeDir string) string {
+	if dir := xdgDir("XDG_DATA_HOME", "magnet-handler"); dir != "" {
+		return filepath.Join(dir, "magnet-list-local.json")
+	}
+	return legacyDatabasePath(homeDir)
+}
+
+// defaultDatabasePath keeps using a database in the legacy location until
+// migrate-paths moves it, so setting XDG_DATA_HOME never starts a second one
+func defaultDatabasePath(homeDir string) string {
+	legacy := legacyDatabasePath(homeDir)
+	if _, err := os.Stat(legacy); err == nil {
+		return legacy
+	}
+	return preferredDatabasePath(homeDir)
+}
+
+// legacyLogDir is where logs went before XDG support
+func legacyLogDir(homeDir string) string {
+	return filepath.Join(homeDir, ".magnet-handler", "logs")
+}
+
+// configFilePath returns the config file LoadConfig reads, or where
+// SaveConfig would create one
+func configFilePath(homeDir string) string {
+	current := filepath.Join(homeDir, ".magnet-handler", "mh.yaml")
+	if _, err := os.Stat(current); err == nil {
+		return current
+	}
+	legacy := filepath.Join(homeDir, ".magnet-handler.conf")
+	if _, err := os.Stat(legacy); err == nil && movedTo(legacy) == "" {
+		return legacy
+	}
+	return current
+}
+
+// movedTo returns where a pointer file at path says the file went, or ""
+// if path is not a pointer
+func movedTo(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	line, _ := bufio.NewReader(io.LimitReader(f, 4096)).ReadString('\n')
+	target, ok := strings.CutPrefix(strings.TrimSpace(line), movedPrefix)
+	if !ok {
+		return ""
+	}
+	return target
+}
+
+// followMovedDatabase points config at the new location when json_path is
+// a pointer left by migrate-paths, warning so the setting gets fixed
+func followMovedDatabase(config *Config) {
+	for i := 0; i < 5; i++ {
+		target := movedTo(config.JSONPath)
+		if target == "" {
+			return
+		}
+		log.Printf("⚠ Database %s was moved to %s; using the new location (update json_path to silence this)", config.JSONPath, target)
+		config.JSONPath = target
+	}
+}
+
+// lockLocalDatabase takes the lock every writer of the local database holds,
+// which migrate-paths also takes before moving it. A pointer left by the
+// move is followed, so a process started before it saves to the new
+// location instead of over the pointer. It returns the path to write and
+// the function releasing the lock.
+func lockLocalDatabase(path string) (string, func(), error) {
+	for i := 0; ; i++ {
+		release, err := acquireFileLock(path, remoteLockTimeout)
+		if err != nil {
+			return "", nil, fmt.Errorf("database is busy: %w", err)
+		}
+		target := movedTo(path)
+		if target == "" {
+			return path, release, nil
+		}
+		release()
+		if i == 5 {
+			return "", nil, fmt.Errorf("%s: too many moved-to pointers", path)
+		}
+		log.Printf("⚠ Database %s was moved to %s; saving there (update json_path to silence this)", path, target)
+		path = target
+	}
+}
+
+// databaseFiles returns the database and every file kept beside it
+func databaseFiles(dbPath string) []string {
+	return []string{dbPath, journalPath(dbPath), oplogPath(dbPath), oplogBasePath(dbPath), IndexPath(dbPath), recoveryLogPath(dbPath)}
+}
+
+// planPathMoves lists what needs to move to reach the current locations:
+// the legacy config file, the database and its sidecar files, and logs
+func planPathMoves(homeDir string, config Config, database string) ([]pathMove, error) {
+	var moves []pathMove
+
+	legacyConfig := filepath.Join(homeDir, ".magnet-handler.conf")
+	currentConfig := filepath.Join(homeDir, ".magnet-handler", "mh.yaml")
+	if fileExists(legacyConfig) && movedTo(legacyConfig) == "" && !fileExists(currentConfig) {
+		moves = append(moves, pathMove{"config", legacyConfig, currentConfig})
+	}
+
+	from, err := filepath.Abs(config.JSONPath)
+	if err != nil {
+		return nil, err
+	}
+	to, err := filepath.Abs(database)
+	if err != nil {
+		return nil, err
+	}
+	if from != to && fileExists(from) && movedTo(from) == "" {
+		if fileExists(to) {
+			return nil, fmt.Errorf("%s already exists; merge or remove it before moving %s there", to, from)
+		}
+		targets := databaseFiles(to)
+		for i, path := range databaseFiles(from) {
+			if fileExists(path) {
+				moves = append(moves, pathMove{"database", path, targets[i]})
+			}
+		}
+	}
+
+	oldLogs, newLogs := legacyLogDir(homeDir), GetDefaultLogDir()
+	if oldLogs != newLogs {
+		logs, _ := filepath.Glob(filepath.Join(oldLogs, "*.log"))
+		for _, path := range logs {
+			moves = append(moves, pathMove{"log", path, filepath.Join(newLogs, filepath.Base(path))})
+		}
+	}
+	return moves, nil
+}
+
+// fileExists reports whether path exists
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// moveFile renames from to to, copying across filesystems
+func moveFile(from, to string) error {
+	if err := os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(from, to); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(to, os.O_CREATE|os.O_EXCL|os.O_WRONLY, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(to)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(to)
+		return err
+	}
+	in.Close()
+	return os.Remove(from)
+}
+
+// writePointer leaves a pointer file at from naming to. Older versions fail
+// to parse it rather than treating it as an empty database.
+func writePointer(from, to string) error {
+	note := movedPrefix + to + "\n" +
+		"This file was moved by magnet-handler migrate-paths. Point scripts and\n" +
+		"settings at the new location, then delete this file.\n"
+	return os.WriteFile(from, []byte(note), 0644)
+}
+
+// applyPathMoves moves each file, leaving pointers for the config and the
+// database itself (sidecar files and logs just move)
+func applyPathMoves(moves []pathMove, database string) error {
+	for _, move := range moves {
+		if err := moveFile(move.From, move.To); err != nil {
+			return fmt.Errorf("failed to move %s %s: %w", move.What, move.From, err)
+		}
+		log.Printf("✓ Moved %s %s -> %s", move.What, move.From, move.To)
+		if move.What == "config" || (move.What == "database" && move.To == database) {
+			if err := writePointer(move.From, move.To); err != nil {
+				return fmt.Errorf("failed to leave a pointer at %s: %w", move.From, err)
+			}
+		}
+	}
+	return nil
+}
+
+// runMigratePaths implements the migrate-paths command
+func runMigratePaths(config Config, args []string) error {
+	fs := newCommandFlags(migratePathsCommand)
+	homeDir, err := getHomeDir()
+	if err != nil {
+		return err
+	}
+	database := fs.String("database", preferredDatabasePath(homeDir), "Where to move the database")
+	dryRun := fs.Bool("dry-run", false, "Show what would move without changing anything")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+	if *database, err = filepath.Abs(*database); err != nil {
+		return err
+	}
+
+	moves, err := planPathMoves(homeDir, config, *database)
+	if err != nil {
+		return err
+	}
+	if len(moves) == 0 {
+		log.Println("✓ Everything is already in its current location")
+		return nil
+	}
+	if *dryRun {
+		for _, move := range moves {
+			fmt.Printf("%-8s %s -> %s\n", move.What, move.From, move.To)
+		}
+		log.Printf("Would move %s", plural(len(moves), "file", "files"))
+		return nil
+	}
+
+	movesDatabase := false
+	for _, move := range moves {
+		movesDatabase = movesDatabase || move.What == "database"
+	}
+	if movesDatabase {
+		// Savers take this lock too, so none writes mid-move, and the next one
+		// follows the pointer left behind
+		release, err := acquireFileLock(config.JSONPath, 10*time.Second)
+		if err != nil {
+			return fmt.Errorf("database is busy: %w", err)
+		}
+		defer release()
+	}
+
+	if err := applyPathMoves(moves, *database); err != nil {
+		return err
+	}
+
+	if movesDatabase {
+		stored, err := LoadConfig()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+		stored.JSONPath = *database
+		if err := SaveConfig(stored); err != nil {
+			return fmt.Errorf("failed to save config: %w", err)
+		}
+		log.Printf("✓ Set json_path to %s", *database)
+	}
+	log.Printf("✓ Moved %s", plural(len(moves), "file", "files"))
+	return nil
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Test migrate-paths moves the legacy config, the database with its
+// journal, and logs to the XDG locations, leaving pointers that are followed
+func TestMigratePaths(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	t.Setenv("HOME", tmpDir)
+	t.Setenv("XDG_DATA_HOME", filepath.Join(tmpDir, "data"))
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(tmpDir, "cache"))
+
+	oldDB := legacyDatabasePath(tmpDir)
+	if got := DefaultConfig().JSONPath; got != filepath.Join(tmpDir, "data", "magnet-handler", "magnet-list-local.json") {
+		t.Errorf("Without a legacy database the default should be under XDG_DATA_HOME, got %s", got)
+	}
+	db := &MagnetDatabase{Added: map[string]MagnetEntry{"hash1": {Hash: "hash1", Title: "Book"}}, Retry: map[string]MagnetEntry{}}
+	if err := SaveDatabaseLocal(oldDB, db); err != nil {
+		t.Fatalf("Failed to save database: %v", err)
+	}
+	if got := DefaultConfig().JSONPath; got != oldDB {
+		t.Errorf("An existing legacy database should stay the default until moved, got %s", got)
+	}
+	if err := os.WriteFile(journalPath(oldDB), []byte("{}\n"), 0644); err != nil {
+		t.Fatalf("Failed to write journal: %v", err)
+	}
+	legacyConfig := filepath.Join(tmpDir, ".magnet-handler.conf")
+	if err := os.WriteFile(legacyConfig, []byte(`{"deluge_host": "10.0.0.2", "json_path": "`+oldDB+`"}`), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	if err := os.MkdirAll(legacyLogDir(tmpDir), 0755); err != nil {
+		t.Fatalf("Failed to create log dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(legacyLogDir(tmpDir), "magnet-handler-1.log"), []byte("log\n"), 0644); err != nil {
+		t.Fatalf("Failed to write log: %v", err)
+	}
+
+	config, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if err := runMigratePaths(config, []string{"--dry-run"}); err != nil {
+		t.Fatalf("migrate-paths --dry-run failed: %v", err)
+	}
+	if movedTo(oldDB) != "" || !fileExists(legacyConfig) {
+		t.Fatal("A dry run should not move anything")
+	}
+	if err := runMigratePaths(config, nil); err != nil {
+		t.Fatalf("migrate-paths failed: %v", err)
+	}
+
+	newDB := preferredDatabasePath(tmpDir)
+	if moved, err := LoadJSONDatabase(newDB); err != nil || len(moved.Added) != 1 {
+		t.Errorf("Expected the database at %s, got %v", newDB, err)
+	}
+	if !fileExists(journalPath(newDB)) || fileExists(journalPath(oldDB)) {
+		t.Error("Expected the journal moved with the database")
+	}
+	if movedTo(oldDB) != newDB || movedTo(legacyConfig) == "" {
+		t.Errorf("Expected pointers left at the old database and config")
+	}
+	if !fileExists(filepath.Join(tmpDir, "cache", "magnet-handler", "magnet-handler-1.log")) {
+		t.Error("Expected the log moved under XDG_CACHE_HOME")
+	}
+
+	config, err = LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig after migrating failed: %v", err)
+	}
+	if config.JSONPath != newDB || config.DelugeHost != "10.0.0.2" {
+		t.Errorf("Expected the moved config with json_path updated, got %+v", config)
+	}
+
+	stale := Config{JSONPath: oldDB}
+	followMovedDatabase(&stale)
+	if stale.JSONPath != newDB {
+		t.Errorf("Expected the old json_path followed to %s, got %s", newDB, stale.JSONPath)
+	}
+	if moves, err := planPathMoves(tmpDir, config, newDB); err != nil || len(moves) != 0 {
+		t.Errorf("Expected nothing left to move, got %v, %v", moves, err)
+	}
+}
+
+// Test migrate-paths refuses to move the database over an existing one
+func TestMigratePathsExistingTarget(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	t.Setenv("HOME", tmpDir)
+
+	from := filepath.Join(tmpDir, "old.json")
+	to := filepath.Join(tmpDir, "new.json")
+	for _, path := range []string{from, to} {
+		if err := os.WriteFile(path, []byte("{}"), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+	if _, err := planPathMoves(tmpDir, Config{JSONPath: from}, to); err == nil {
+		t.Error("Expected an error when the target database exists")
+	}
+}
+
+// Test a process still using the old json_path saves to the moved database
+// instead of writing over the pointer left behind
+func TestSaveFollowsMovedDatabase(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	oldDB := filepath.Join(tmpDir, "old.json")
+	newDB := filepath.Join(tmpDir, "new", "magnet-list-local.json")
+	db := &MagnetDatabase{Added: map[string]MagnetEntry{"hash1": {Hash: "hash1", Title: "Book"}}, Retry: map[string]MagnetEntry{}}
+	if err := SaveDatabaseLocal(oldDB, db); err != nil {
+		t.Fatalf("Failed to save database: %v", err)
+	}
+	moves := []pathMove{{"database", oldDB, newDB}}
+	if err := applyPathMoves(moves, newDB); err != nil {
+		t.Fatalf("applyPathMoves failed: %v", err)
+	}
+
+	config := Config{JSONPath: oldDB}
+	if err := SaveJSONDatabase(oldDB, entryUpdate(SectionRetry, "hash2", MagnetEntry{Hash: "hash2"}), &config); err != nil {
+		t.Fatalf("SaveJSONDatabase failed: %v", err)
+	}
+	if movedTo(oldDB) != newDB {
+		t.Error("The pointer should not be overwritten")
+	}
+	saved, err := LoadJSONDatabase(newDB)
+	if err != nil {
+		t.Fatalf("LoadJSONDatabase failed: %v", err)
+	}
+	if _, ok := saved.Added["hash1"]; !ok {
+		t.Error("Moved entries should be kept")
+	}
+	if _, ok := saved.Retry["hash2"]; !ok {
+		t.Error("The save should land in the moved database")
+	}
+	for _, path := range []string{lockPath(oldDB), lockPath(newDB)} {
+		if fileExists(path) {
+			t.Errorf("Expected %s released", path)
+		}
+	}
+}
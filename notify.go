@@ -0,0 +1,73 @@
+package main
+
+import (
+	"log"
+	"strings"
+)
+
+// NotifyLevel indicates how important a notification is
+type NotifyLevel string
+
+const (
+	NotifyInfo     NotifyLevel = "info"
+	NotifyWarning  NotifyLevel = "warning"
+	NotifyCritical NotifyLevel = "critical"
+)
+
+// Notification is a user-facing event raised by the handler
+type Notification struct {
+	Level         NotifyLevel
+	Title         string
+	Message       string
+	CorrelationID string // Operation that raised the notification
+}
+
+// Notifier delivers notifications to the user
+type Notifier interface {
+	Notify(n Notification) error
+}
+
+// logNotifier writes notifications to the log in a prominent format
+type logNotifier struct{}
+
+func (logNotifier) Notify(n Notification) error {
+	banner := strings.Repeat("!", 60)
+	if n.Level == NotifyInfo {
+		banner = strings.Repeat("-", 60)
+	}
+	log.Println(banner)
+	log.Printf("[%s] %s", strings.ToUpper(string(n.Level)), n.Title)
+	if n.CorrelationID != "" {
+		log.Printf("  Correlation ID: %s", n.CorrelationID)
+	}
+	for _, line := range strings.Split(n.Message, "\n") {
+		log.Printf("  %s", line)
+	}
+	log.Println(banner)
+	return nil
+}
+
+// notifiers holds the active notification backends
+var notifiers = []Notifier{logNotifier{}}
+
+// Notify sends a notification through every registered backend. During
+// quiet hours only critical notifications go out; the rest are held for the
+// summary sent when quiet hours end.
+func Notify(level NotifyLevel, title, message string) {
+	n := Notification{Level: level, Title: title, Message: message, CorrelationID: CorrelationID()}
+	if level != NotifyCritical && quietHours != nil && quietHours.active(quietNow()) {
+		holdNotification(n)
+		return
+	}
+	deliverQuietSummary()
+	deliver(n)
+}
+
+// deliver sends a notification through every registered backend
+func deliver(n Notification) {
+	for _, notifier := range notifiers {
+		if err := notifier.Notify(n); err != nil {
+			log.Printf("Warning: Notification failed: %v", err)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// oplogPath returns the operation log kept beside a database file. The
+// journal is emptied once a save lands; the oplog keeps every applied
+// operation so earlier states of the database can be reconstructed.
+func oplogPath(dbPath string) string {
+	return dbPath + ".oplog"
+}
+
+// recordOplog appends the operations a save applied, each with the entry as
+// stored (sequence ID assigned, clock stamped)
+func recordOplog(dbPath string, db *MagnetDatabase, ops []journalOp) error {
+	if len(ops) == 0 {
+		return nil
+	}
+	applied := make([]journalOp, 0, len(ops))
+	for _, op := range ops {
+		if section, entry, ok := entrySection(db, op.Hash); ok && section == op.Section {
+			op.Entry = entry
+		}
+		applied = append(applied, op)
+	}
+
+	// Compaction rewrites the log, so appends wait for it
+	release, err := acquireFileLock(oplogPath(dbPath), remoteLockTimeout)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return writeJournalLines(oplogPath(dbPath), applied)
+}
+
+// readOplog returns the logged operations in the order they were applied,
+// starting with those folded into the base. An operation folded by two
+// processes at once is logged twice; the duplicate is dropped.
+func readOplog(dbPath string) ([]journalOp, error) {
+	base, err := loadOplogBase(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	logged, err := readJournalLines(oplogPath(dbPath))
+	if err != nil {
+		return nil, err
+	}
+	ops := append(base.Ops, base.unfolded(logged)...)
+	seen := make(map[string]bool, len(ops))
+	unique := ops[:0]
+	for _, op := range ops {
+		if op.ID != "" && seen[op.ID] {
+			continue
+		}
+		seen[op.ID] = true
+		unique = append(unique, op)
+	}
+	return unique, nil
+}
+
+// placeEntry stores an entry in one section, removing it from the others
+func placeEntry(db *MagnetDatabase, section, hash string, entry MagnetEntry) {
+	delete(db.Added, hash)
+	delete(db.Retry, hash)
+	delete(db.Removed, hash)
+	switch section {
+	case SectionAdded:
+		db.Added[hash] = entry
+	case SectionRetry:
+		db.Retry[hash] = entry
+	case SectionRemoved:
+		db.Removed[hash] = entry
+	}
+}
+
+// historySection returns the section a history event leaves an entry in
+func historySection(event string) string {
+	switch event {
+	case HistoryQueued, HistoryRetryFailed, HistoryGaveUp:
+		return SectionRetry
+	case HistoryRemoved:
+		return SectionRemoved
+	default:
+		return SectionAdded
+	}
+}
+
+// entrySectionAt infers from an entry's own timestamps and history which
+// section it was in at t, for entries the oplog has nothing on before t. It
+// reports false if the entry did not exist yet.
+func entrySectionAt(section string, entry MagnetEntry, t time.Time) (string, bool) {
+	added := parseTimestamp(entry.AddedDate)
+	if added.IsZero() || added.After(t) {
+		return "", false
+	}
+	inferred := section
+	if section == SectionRemoved {
+		if removed := parseTimestamp(entry.RemovedAt); removed.IsZero() || removed.After(t) {
+			inferred = SectionAdded
+		}
+	}
+	earlier, later := false, false
+	for _, event := range entry.History {
+		at := parseTimestamp(event.At)
+		switch {
+		case at.IsZero():
+		case at.After(t):
+			later = true
+		default:
+			inferred = historySection(event.Event)
+			earlier = true
+		}
+	}
+	if later && !earlier {
+		// Everything recorded happened afterwards, so it was still as added
+		inferred = SectionAdded
+	}
+	return inferred, true
+}
+
+// databaseAsOf reconstructs the database as it stood at t. Entries with
+// operations logged by then are replayed from the oplog; the rest (older
+// than the log, or synced from other machines) are placed from their own
+// timestamps and history.
+func databaseAsOf(current *MagnetDatabase, ops []journalOp, t time.Time) *MagnetDatabase {
+	past := &MagnetDatabase{
+		Metadata: current.Metadata,
+		Added:    make(map[string]MagnetEntry),
+		Retry:    make(map[string]MagnetEntry),
+		Removed:  make(map[string]MagnetEntry),
+	}
+
+	replayed := make(map[string]bool)
+	for _, op := range ops {
+		if at := parseTimestamp(op.At); at.IsZero() || at.After(t) {
+			continue
+		}
+		placeEntry(past, op.Section, op.Hash, op.Entry)
+		replayed[op.Hash] = true
+	}
+
+	for _, section := range []struct {
+		name    string
+		entries map[string]MagnetEntry
+	}{
+		{SectionAdded, current.Added},
+		{SectionRetry, current.Retry},
+		{SectionRemoved, current.Removed},
+	} {
+		for hash, entry := range section.entries {
+			if replayed[hash] {
+				continue
+			}
+			if inferred, ok := entrySectionAt(section.name, entry, t); ok {
+				placeEntry(past, inferred, hash, entry)
+			}
+		}
+	}
+	return past
+}
+
+// parseQueryTime parses an RFC 3339 time or a YYYY-MM-DD date in local time.
+// With endOfDay, a bare date means the last moment of that day.
+func parseQueryTime(value string, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	day, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q (use YYYY-MM-DD or RFC 3339)", value)
+	}
+	if endOfDay {
+		return day.AddDate(0, 0, 1).Add(-time.Nanosecond), nil
+	}
+	return day, nil
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Test saves log the operations they fold so the oplog outlives the journal
+func TestSaveRecordsOplog(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	localPath := filepath.Join(tmpDir, "local.json")
+	config := Config{JSONPath: localPath}
+	for _, hash := range []string{"hash1", "hash2"} {
+		if err := SaveJSONDatabase(localPath, entryUpdate(SectionAdded, hash, MagnetEntry{Hash: hash}), &config); err != nil {
+			t.Fatalf("SaveJSONDatabase failed: %v", err)
+		}
+	}
+
+	ops, err := readOplog(localPath)
+	if err != nil {
+		t.Fatalf("readOplog failed: %v", err)
+	}
+	if len(ops) != 2 || ops[0].Hash != "hash1" || ops[1].Hash != "hash2" {
+		t.Fatalf("Expected both saves logged in order, got %+v", ops)
+	}
+	if ops[0].Entry.ID == 0 {
+		t.Error("Logged entries should carry their assigned sequence IDs")
+	}
+	if _, err := os.Stat(journalPath(localPath)); !os.IsNotExist(err) {
+		t.Error("Journal should still be emptied after the save")
+	}
+}
+
+// Test an operation logged twice is only read once
+func TestReadOplogDropsDuplicates(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	localPath := filepath.Join(tmpDir, "local.json")
+	op := journalOp{ID: "op1", At: time.Now().Format(time.RFC3339), Section: SectionAdded, Hash: "hash1"}
+	for i := 0; i < 2; i++ {
+		if err := writeJournalLines(oplogPath(localPath), []journalOp{op}); err != nil {
+			t.Fatalf("writeJournalLines failed: %v", err)
+		}
+	}
+
+	ops, err := readOplog(localPath)
+	if err != nil {
+		t.Fatalf("readOplog failed: %v", err)
+	}
+	if len(ops) != 1 {
+		t.Errorf("Expected 1 operation, got %d", len(ops))
+	}
+}
+
+// Test the database is rebuilt from the oplog, and from timestamps and
+// history for entries the oplog does not cover
+func TestDatabaseAsOf(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 3, d, 12, 0, 0, 0, time.UTC) }
+	stamp := func(d int) string { return day(d).Format(time.RFC3339) }
+
+	current := &MagnetDatabase{
+		Added: map[string]MagnetEntry{
+			// Logged: queued on the 2nd, added on the 5th
+			"logged": {Hash: "logged", Title: "Logged"},
+			// Not logged, added on the 3rd
+			"old": {Hash: "old", AddedDate: stamp(3)},
+		},
+		Retry: map[string]MagnetEntry{
+			// Not logged, added to Deluge on the 1st then requeued on the 6th
+			"requeued": {Hash: "requeued", AddedDate: stamp(1), History: []HistoryEvent{
+				{At: stamp(6), Event: HistoryQueued},
+			}},
+		},
+		Removed: map[string]MagnetEntry{
+			// Not logged, added on the 1st and removed on the 4th
+			"gone": {Hash: "gone", AddedDate: stamp(1), RemovedAt: stamp(4)},
+		},
+	}
+	ops := []journalOp{
+		{ID: "a", At: stamp(2), Section: SectionRetry, Hash: "logged", Entry: MagnetEntry{Hash: "logged", Title: "Queued"}},
+		{ID: "b", At: stamp(5), Section: SectionAdded, Hash: "logged", Entry: MagnetEntry{Hash: "logged", Title: "Logged"}},
+	}
+
+	past := databaseAsOf(current, ops, day(3).Add(time.Hour))
+	if past.Retry["logged"].Title != "Queued" {
+		t.Error("Logged entry should be replayed to its state on the 3rd")
+	}
+	if _, exists := past.Added["old"]; !exists {
+		t.Error("Entry added on the 3rd should exist")
+	}
+	if _, exists := past.Added["requeued"]; !exists {
+		t.Error("Entry requeued later should still be in added")
+	}
+	if _, exists := past.Added["gone"]; !exists {
+		t.Error("Entry removed later should still be in added")
+	}
+
+	past = databaseAsOf(current, ops, day(1).Add(-time.Hour))
+	if total := len(past.Added) + len(past.Retry) + len(past.Removed); total != 0 {
+		t.Errorf("Nothing existed before the 1st, got %d entries", total)
+	}
+
+	past = databaseAsOf(current, ops, day(7))
+	if len(past.Added) != 2 || len(past.Retry) != 1 || len(past.Removed) != 1 {
+		t.Errorf("Expected the current layout after the last change, got %d/%d/%d",
+			len(past.Added), len(past.Retry), len(past.Removed))
+	}
+}
+
+// Test period statistics count what happened between the two dates
+func TestPeriodStats(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 3, d, 12, 0, 0, 0, time.UTC) }
+	stamp := func(d int) string { return day(d).Format(time.RFC3339) }
+
+	current := &MagnetDatabase{
+		Added: map[string]MagnetEntry{
+			"before": {Hash: "before", AddedDate: stamp(1), CompletedAt: stamp(6)},
+			"during": {Hash: "during", AddedDate: stamp(5)},
+		},
+		Retry: map[string]MagnetEntry{},
+		Removed: map[string]MagnetEntry{
+			"gone": {Hash: "gone", AddedDate: stamp(1), RemovedAt: stamp(7)},
+		},
+	}
+	ops := []journalOp{{ID: "a", At: stamp(5), Section: SectionAdded, Hash: "during", Entry: current.Added["during"]}}
+
+	stats := periodStats(current, ops, day(4), day(8))
+	if stats.Start.Added != 2 || stats.Start.Removed != 0 {
+		t.Errorf("Expected 2 added and none removed at the start, got %+v", stats.Start)
+	}
+	if stats.End.Added != 2 || stats.End.Removed != 1 {
+		t.Errorf("Expected 2 added and 1 removed at the end, got %+v", stats.End)
+	}
+	if stats.Added != 1 || stats.Completed != 1 || stats.Removed != 1 || stats.Operations != 1 {
+		t.Errorf("Unexpected period counts: %+v", stats)
+	}
+}
+
+// Test query times accept dates and full timestamps
+func TestParseQueryTime(t *testing.T) {
+	start, err := parseQueryTime("2024-03-31", false)
+	if err != nil {
+		t.Fatalf("parseQueryTime failed: %v", err)
+	}
+	end, err := parseQueryTime("2024-03-31", true)
+	if err != nil {
+		t.Fatalf("parseQueryTime failed: %v", err)
+	}
+	if start.Hour() != 0 || end.Day() != 31 || end.Hour() != 23 {
+		t.Errorf("Expected the start and end of the day, got %v and %v", start, end)
+	}
+
+	exact, err := parseQueryTime("2024-03-31T08:30:00Z", true)
+	if err != nil || !exact.Equal(time.Date(2024, 3, 31, 8, 30, 0, 0, time.UTC)) {
+		t.Errorf("Expected the exact time, got %v (%v)", exact, err)
+	}
+
+	if _, err := parseQueryTime("last tuesday", false); err == nil {
+		t.Error("Expected an error for an unparseable date")
+	}
+}
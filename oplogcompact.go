@@ -0,0 +1,293 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"time"
+)
+
+// defaultOplogRetention is how many days of operations a manual compaction
+// keeps in full when oplog_retention is not set
+const defaultOplogRetention = 90
+
+// oplogCompactInterval is how often the daemon compacts the oplog
+const oplogCompactInterval = 24 * time.Hour
+
+// sourceCompactSchedule marks the daemon's periodic compaction jobs
+const sourceCompactSchedule = "compact-schedule"
+
+// oplogBase holds the operations folded out of the oplog: only the last one
+// for each entry, which is enough to replay any time after Before
+type oplogBase struct {
+	Before string      `json:"before"`           // Operations up to this time are folded in
+	Ops    []journalOp `json:"ops"`              // Last folded operation per entry, oldest first
+	Folded []string    `json:"folded,omitempty"` // IDs the last compaction removed from the log
+}
+
+// oplogCompaction reports what a compaction did, or would do
+type oplogCompaction struct {
+	Folded      int // Operations moved out of the log
+	Kept        int // Operations left in the log
+	BaseEntries int // Entries in the base afterwards
+}
+
+// oplogBasePath returns the base snapshot kept beside the oplog
+func oplogBasePath(dbPath string) string {
+	return oplogPath(dbPath) + ".base"
+}
+
+// loadOplogBase reads the base snapshot, which is empty until the first
+// compaction
+func loadOplogBase(dbPath string) (oplogBase, error) {
+	var base oplogBase
+	data, err := os.ReadFile(oplogBasePath(dbPath))
+	if os.IsNotExist(err) {
+		return base, nil
+	}
+	if err != nil {
+		return base, err
+	}
+	if err := json.Unmarshal(data, &base); err != nil {
+		return base, fmt.Errorf("failed to parse %s: %w", oplogBasePath(dbPath), err)
+	}
+	return base, nil
+}
+
+// unfolded drops logged operations the last compaction already folded into
+// the base. They are only still in the log if that compaction was
+// interrupted between writing the base and rewriting the log.
+func (b oplogBase) unfolded(logged []journalOp) []journalOp {
+	if len(b.Folded) == 0 {
+		return logged
+	}
+	folded := make(map[string]bool, len(b.Folded))
+	for _, id := range b.Folded {
+		folded[id] = true
+	}
+	var live []journalOp
+	for _, op := range logged {
+		if !folded[op.ID] {
+			live = append(live, op)
+		}
+	}
+	return live
+}
+
+// planOplogCompaction folds logged operations from before the cutoff into
+// the base, keeping the last per entry, and returns the new base with the
+// operations that stay in the log
+func planOplogCompaction(base oplogBase, logged []journalOp, before time.Time) (oplogBase, []journalOp) {
+	last := make(map[string]journalOp, len(base.Ops))
+	for _, op := range base.Ops {
+		last[op.Hash] = op
+	}
+
+	var kept []journalOp
+	var folded []string
+	for _, op := range base.unfolded(logged) {
+		if at := parseTimestamp(op.At); !at.IsZero() && at.After(before) {
+			kept = append(kept, op)
+			continue
+		}
+		last[op.Hash] = op
+		folded = append(folded, op.ID)
+	}
+
+	next := oplogBase{Before: base.Before, Folded: folded}
+	if previous := parseTimestamp(base.Before); before.After(previous) {
+		next.Before = before.Format(time.RFC3339)
+	}
+	for _, op := range last {
+		next.Ops = append(next.Ops, op)
+	}
+	sort.Slice(next.Ops, func(i, j int) bool {
+		ti, tj := parseTimestamp(next.Ops[i].At), parseTimestamp(next.Ops[j].At)
+		if !ti.Equal(tj) {
+			return ti.Before(tj)
+		}
+		return next.Ops[i].ID < next.Ops[j].ID
+	})
+	return next, kept
+}
+
+// compactOplog folds operations from before the cutoff into the base
+// snapshot. The base is written before the log is rewritten, so a crash in
+// between leaves both readable. Sync only ever reads the database files,
+// so compaction cannot change what other machines see.
+func compactOplog(dbPath string, before time.Time, dryRun bool) (oplogCompaction, error) {
+	release, err := acquireFileLock(oplogPath(dbPath), remoteLockTimeout)
+	if err != nil {
+		return oplogCompaction{}, err
+	}
+	defer release()
+
+	base, err := loadOplogBase(dbPath)
+	if err != nil {
+		return oplogCompaction{}, err
+	}
+	logged, err := readJournalLines(oplogPath(dbPath))
+	if err != nil {
+		return oplogCompaction{}, err
+	}
+	next, kept := planOplogCompaction(base, logged, before)
+	result := oplogCompaction{Folded: len(next.Folded), Kept: len(kept), BaseEntries: len(next.Ops)}
+	if dryRun || result.Folded == 0 {
+		return result, nil
+	}
+
+	data, err := json.MarshalIndent(next, "", "  ")
+	if err != nil {
+		return result, err
+	}
+	basePath := oplogBasePath(dbPath)
+	if err := os.WriteFile(basePath+".tmp", data, 0644); err != nil {
+		return result, err
+	}
+	if err := os.Rename(basePath+".tmp", basePath); err != nil {
+		return result, err
+	}
+
+	path := oplogPath(dbPath)
+	if len(kept) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return result, err
+		}
+		return result, nil
+	}
+	tmp := path + ".tmp"
+	os.Remove(tmp)
+	if err := writeJournalLines(tmp, kept); err != nil {
+		os.Remove(tmp)
+		return result, err
+	}
+	return result, os.Rename(tmp, path)
+}
+
+// compactOplogFor compacts the local oplog to the configured retention
+func compactOplogFor(config Config, days int, dryRun bool) (oplogCompaction, error) {
+	before := time.Now().AddDate(0, 0, -days)
+	result, err := compactOplog(config.JSONPath, before, dryRun)
+	if err != nil {
+		return result, fmt.Errorf("failed to compact operation log: %w", err)
+	}
+	return result, nil
+}
+
+// compactOplogJob runs the daemon's periodic compaction
+func (s *apiServer) compactOplogJob(config Config) error {
+	result, err := compactOplogFor(config, config.OplogRetention, false)
+	if err != nil {
+		return err
+	}
+	if result.Folded > 0 {
+		config.logger().Printf("✓ Folded %s into the oplog base (%d kept)",
+			plural(result.Folded, "operation", "operations"), result.Kept)
+	}
+	return nil
+}
+
+// scheduleCompact queues the next daily oplog compaction unless one is
+// already pending (other than the job identified by current)
+func (s *apiServer) scheduleCompact(current string) {
+	if s.config.OplogRetention <= 0 || s.queue.hasScheduled(sourceCompactSchedule, current) {
+		return
+	}
+	due := time.Now().Add(oplogCompactInterval)
+	job := workJob{Kind: jobCompact, Source: sourceCompactSchedule, CorrelationID: newCorrelationID(), NotBefore: due.Format(time.RFC3339)}
+	if _, err := s.queue.Submit(job); err != nil {
+		log.Printf("Warning: Failed to schedule oplog compaction: %v", err)
+	}
+}
+
+// runOplog implements the oplog command
+func runOplog(config Config, args []string) error {
+	if len(args) == 0 {
+		newCommandFlags(oplogCommand).Usage()
+		return fmt.Errorf("expected an oplog subcommand")
+	}
+
+	switch args[0] {
+	case "status":
+		return runOplogStatus(config, args[1:])
+	case "compact":
+		return runOplogCompact(config, args[1:])
+	default:
+		return fmt.Errorf("unknown oplog subcommand %q", args[0])
+	}
+}
+
+// runOplogStatus shows how much history the oplog holds
+func runOplogStatus(config Config, args []string) error {
+	fs := newCommandFlags(oplogCommand)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	base, err := loadOplogBase(config.JSONPath)
+	if err != nil {
+		return err
+	}
+	logged, err := readJournalLines(oplogPath(config.JSONPath))
+	if err != nil {
+		return err
+	}
+	logged = base.unfolded(logged)
+
+	fmt.Printf("Log:  %s\n", plural(len(logged), "operation", "operations"))
+	if len(logged) > 0 {
+		fmt.Printf("      %s to %s\n", logged[0].At, logged[len(logged)-1].At)
+	}
+	if base.Before == "" {
+		fmt.Println("Base: none (never compacted)")
+	} else {
+		fmt.Printf("Base: %s folded up to %s\n", plural(len(base.Ops), "entry", "entries"), base.Before)
+	}
+	if config.OplogRetention > 0 {
+		fmt.Printf("The daemon keeps %d days in full\n", config.OplogRetention)
+	}
+	return nil
+}
+
+// runOplogCompact folds old operations into the base on demand
+func runOplogCompact(config Config, args []string) error {
+	fs := newCommandFlags(oplogCommand)
+	keep := config.OplogRetention
+	if keep <= 0 {
+		keep = defaultOplogRetention
+	}
+	days := fs.Int("keep", keep, "Days of operations to keep in full")
+	dryRun := fs.Bool("dry-run", false, "Show what would be folded without changing anything")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *days < 0 {
+		return fmt.Errorf("--keep must not be negative")
+	}
+
+	result, err := compactOplogFor(config, *days, *dryRun)
+	if err != nil {
+		return err
+	}
+	verb := "Folded"
+	if *dryRun {
+		verb = "Would fold"
+	}
+	fmt.Printf("%s %s older than %d days into the base; %d kept, %s in the base\n",
+		verb, plural(result.Folded, "operation", "operations"), *days,
+		result.Kept, plural(result.BaseEntries, "entry", "entries"))
+	return nil
+}
+
+var oplogCommand = &Command{
+	Name:    "oplog",
+	Usage:   "oplog status | oplog compact [--keep days] [--dry-run]",
+	Summary: "Show the operation log, or fold old operations into its base snapshot",
+}
+
+func init() {
+	oplogCommand.Run = runOplog
+	registerCommand(oplogCommand)
+}
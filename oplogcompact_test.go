@@ -0,0 +1,104 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Test compaction folds old operations into one per entry and keeps the rest
+func TestPlanOplogCompaction(t *testing.T) {
+	day := func(d int) string { return time.Date(2024, 3, d, 12, 0, 0, 0, time.UTC).Format(time.RFC3339) }
+	logged := []journalOp{
+		{ID: "a", At: day(1), Section: SectionRetry, Hash: "hash1"},
+		{ID: "b", At: day(2), Section: SectionAdded, Hash: "hash1"},
+		{ID: "c", At: day(3), Section: SectionAdded, Hash: "hash2"},
+		{ID: "d", At: day(9), Section: SectionRemoved, Hash: "hash1"},
+	}
+
+	base, kept := planOplogCompaction(oplogBase{}, logged, time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC))
+	if len(kept) != 1 || kept[0].ID != "d" {
+		t.Errorf("Expected only the newest operation kept, got %+v", kept)
+	}
+	if len(base.Ops) != 2 || base.Ops[0].ID != "b" || base.Ops[1].ID != "c" {
+		t.Errorf("Expected the last operation per entry in the base, got %+v", base.Ops)
+	}
+	if !reflect.DeepEqual(base.Folded, []string{"a", "b", "c"}) {
+		t.Errorf("Expected folded IDs a, b, c, got %v", base.Folded)
+	}
+
+	// A second pass folds into the existing base
+	base, kept = planOplogCompaction(base, kept, time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC))
+	if len(kept) != 0 || len(base.Ops) != 2 || base.Ops[1].ID != "d" {
+		t.Errorf("Expected hash1's removal to replace its earlier add, got %+v", base.Ops)
+	}
+}
+
+// Test queries after the cutoff see the same database once compacted, even
+// if the compaction stopped before rewriting the log
+func TestCompactOplogKeepsRecentHistory(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	localPath := filepath.Join(tmpDir, "local.json")
+	day := func(d int) time.Time { return time.Date(2024, 3, d, 12, 0, 0, 0, time.UTC) }
+	logged := []journalOp{
+		{ID: "a", At: day(1).Format(time.RFC3339), Section: SectionRetry, Hash: "hash1"},
+		{ID: "b", At: day(2).Format(time.RFC3339), Section: SectionAdded, Hash: "hash1"},
+		{ID: "c", At: day(6).Format(time.RFC3339), Section: SectionRetry, Hash: "hash2"},
+	}
+	if err := writeJournalLines(oplogPath(localPath), logged); err != nil {
+		t.Fatalf("writeJournalLines failed: %v", err)
+	}
+	current := &MagnetDatabase{
+		Added: map[string]MagnetEntry{"hash1": {Hash: "hash1"}},
+		Retry: map[string]MagnetEntry{"hash2": {Hash: "hash2"}},
+	}
+	layout := func() [2]int {
+		ops, err := readOplog(localPath)
+		if err != nil {
+			t.Fatalf("readOplog failed: %v", err)
+		}
+		past := databaseAsOf(current, ops, day(7))
+		return [2]int{len(past.Added), len(past.Retry)}
+	}
+	want := layout()
+
+	result, err := compactOplog(localPath, day(4), true)
+	if err != nil {
+		t.Fatalf("compactOplog dry run failed: %v", err)
+	}
+	if result.Folded != 2 || result.Kept != 1 {
+		t.Errorf("Expected 2 folded and 1 kept, got %+v", result)
+	}
+	if _, err := os.Stat(oplogBasePath(localPath)); !os.IsNotExist(err) {
+		t.Error("Dry run should not write the base")
+	}
+
+	if _, err := compactOplog(localPath, day(4), false); err != nil {
+		t.Fatalf("compactOplog failed: %v", err)
+	}
+	remaining, err := readJournalLines(oplogPath(localPath))
+	if err != nil {
+		t.Fatalf("readJournalLines failed: %v", err)
+	}
+	if len(remaining) != 1 {
+		t.Errorf("Expected 1 operation left in the log, got %d", len(remaining))
+	}
+	if got := layout(); got != want {
+		t.Errorf("Compaction changed the reconstructed database: %v, want %v", got, want)
+	}
+
+	// Put the folded operations back, as if the log rewrite never happened
+	if err := writeJournalLines(oplogPath(localPath), logged[:2]); err != nil {
+		t.Fatalf("writeJournalLines failed: %v", err)
+	}
+	if got := layout(); got != want {
+		t.Errorf("Interrupted compaction changed the reconstructed database: %v, want %v", got, want)
+	}
+}
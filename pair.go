@@ -0,0 +1,262 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// pairingCodeAlphabet leaves out characters that are easy to misread (0/O, 1/I)
+const pairingCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// Pairing limits
+const (
+	defaultPairingTTL  = 5 * time.Minute
+	maxPairingAttempts = 5
+)
+
+// PairedToken is a long-lived API token issued to a paired extension. Only
+// a hash of the token is stored.
+type PairedToken struct {
+	Name      string `json:"name"`
+	Hash      string `json:"hash"`
+	CreatedAt string `json:"created_at"`
+}
+
+// pendingPairing is a one-time code waiting to be redeemed
+type pendingPairing struct {
+	CodeHash  string `json:"code_hash"`
+	ExpiresAt string `json:"expires_at"`
+	Attempts  int    `json:"attempts"` // Wrong codes tried so far
+}
+
+// errPairingRejected is returned for wrong, expired, or exhausted codes
+var errPairingRejected = errors.New("invalid or expired pairing code")
+
+// pairingPath returns where the pending pairing code is kept
+func pairingPath() (string, error) {
+	homeDir, err := getHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, ".magnet-handler", "pairing.json"), nil
+}
+
+// hashSecret returns the hex SHA-256 of a code or token
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return fmt.Sprintf("%x", sum)
+}
+
+// newPairingCode returns a random code formatted as XXXX-XXXX
+func newPairingCode() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	code := make([]byte, len(b))
+	for i, v := range b {
+		code[i] = pairingCodeAlphabet[int(v)%len(pairingCodeAlphabet)]
+	}
+	return string(code[:4]) + "-" + string(code[4:])
+}
+
+// normalizePairingCode accepts codes typed in lower case or without the dash
+func normalizePairingCode(code string) string {
+	code = strings.ToUpper(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+// loadPairing reads the pending pairing, if any
+func loadPairing() (*pendingPairing, error) {
+	path, err := pairingPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var pairing pendingPairing
+	if err := json.Unmarshal(data, &pairing); err != nil {
+		return nil, err
+	}
+	return &pairing, nil
+}
+
+// save writes the pending pairing readable only by the user
+func (p *pendingPairing) save() error {
+	path, err := pairingPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// startPairing creates a new one-time code, replacing any pending one
+func startPairing(ttl time.Duration) (string, error) {
+	code := newPairingCode()
+	pairing := &pendingPairing{
+		CodeHash:  hashSecret(normalizePairingCode(code)),
+		ExpiresAt: time.Now().Add(ttl).Format(time.RFC3339),
+	}
+	if err := pairing.save(); err != nil {
+		return "", fmt.Errorf("failed to save pairing code: %w", err)
+	}
+	return code, nil
+}
+
+// redeemPairing checks a code against the pending pairing, consuming it on
+// success. Wrong codes count towards a small attempt limit.
+func redeemPairing(code string, now time.Time) error {
+	pairing, err := loadPairing()
+	if err != nil {
+		return errPairingRejected
+	}
+	expires, err := time.Parse(time.RFC3339, pairing.ExpiresAt)
+	if err != nil || now.After(expires) || pairing.Attempts >= maxPairingAttempts {
+		return errPairingRejected
+	}
+
+	given := hashSecret(normalizePairingCode(code))
+	if subtle.ConstantTimeCompare([]byte(given), []byte(pairing.CodeHash)) != 1 {
+		pairing.Attempts++
+		if err := pairing.save(); err != nil {
+			log.Printf("Warning: Failed to record pairing attempt: %v", err)
+		}
+		return errPairingRejected
+	}
+
+	path, err := pairingPath()
+	if err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// pairedTokenValid reports whether token matches any paired token
+func pairedTokenValid(tokens []PairedToken, token string) bool {
+	given := []byte(hashSecret(token))
+	valid := false
+	for _, pt := range tokens {
+		if subtle.ConstantTimeCompare(given, []byte(pt.Hash)) == 1 {
+			valid = true
+		}
+	}
+	return valid
+}
+
+// isPairedToken checks a bearer token against the tokens paired so far
+func (s *apiServer) isPairedToken(token string) bool {
+	s.tokensMu.RLock()
+	defer s.tokensMu.RUnlock()
+	return pairedTokenValid(s.paired, token)
+}
+
+// handlePair exchanges a one-time pairing code for a long-lived API token
+func (s *apiServer) handlePair(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Code string `json:"code"`
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+	name := strings.TrimSpace(body.Name)
+	if name == "" {
+		name = "browser extension"
+	}
+
+	s.tokensMu.Lock()
+	defer s.tokensMu.Unlock()
+
+	if err := redeemPairing(body.Code, time.Now()); err != nil {
+		log.Printf("✗ Pairing attempt from %s rejected", r.RemoteAddr)
+		writeError(w, http.StatusForbidden, err)
+		return
+	}
+
+	token := newAPIToken()
+	paired := PairedToken{Name: name, Hash: hashSecret(token), CreatedAt: time.Now().Format(time.RFC3339)}
+
+	// Save into the stored config, not the one with command-line overrides
+	stored, err := LoadConfig()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("failed to load config: %w", err))
+		return
+	}
+	stored.PairedTokens = append(stored.PairedTokens, paired)
+	if err := SaveConfig(stored); err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("failed to save config: %w", err))
+		return
+	}
+	s.paired = append(s.paired, paired)
+
+	log.Printf("✓ Paired with %s", name)
+	writeJSON(w, http.StatusOK, map[string]string{"token": token, "name": name})
+}
+
+// runPair implements the pair command
+func runPair(config Config, args []string) error {
+	fs := newCommandFlags(pairCommand)
+	ttl := fs.Duration("ttl", defaultPairingTTL, "How long the code stays valid")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	code, err := startPairing(*ttl)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(strings.Repeat("=", 60))
+	fmt.Printf("  Pairing code: %s\n", code)
+	fmt.Println(strings.Repeat("=", 60))
+	fmt.Printf("Enter this code in the magnet-handler extension within %s.\n", *ttl)
+	fmt.Println("The daemon (magnet-handler serve) must be running.")
+
+	// Wait for the daemon to consume the code
+	deadline := time.Now().Add(*ttl)
+	for time.Now().Before(deadline) {
+		time.Sleep(time.Second)
+		pairing, err := loadPairing()
+		if errors.Is(err, os.ErrNotExist) {
+			log.Println("✓ Extension paired")
+			return nil
+		}
+		if err == nil && pairing.Attempts >= maxPairingAttempts {
+			return fmt.Errorf("too many wrong codes entered, run pair again")
+		}
+	}
+
+	if path, err := pairingPath(); err == nil {
+		os.Remove(path)
+	}
+	return fmt.Errorf("pairing code expired")
+}
+
+var pairCommand = &Command{
+	Name:    "pair",
+	Usage:   "pair [--ttl 5m]",
+	Summary: "Show a one-time code that pairs the browser extension with the daemon",
+}
+
+func init() {
+	pairCommand.Run = runPair
+	registerCommand(pairCommand)
+}
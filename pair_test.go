@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test newPairingCode uses the unambiguous alphabet
+func TestNewPairingCode(t *testing.T) {
+	format := regexp.MustCompile(`^[A-HJ-NP-Z2-9]{4}-[A-HJ-NP-Z2-9]{4}$`)
+	for i := 0; i < 20; i++ {
+		if code := newPairingCode(); !format.MatchString(code) {
+			t.Errorf("Unexpected pairing code %q", code)
+		}
+	}
+}
+
+// Test redeemPairing accepts the code once and limits wrong guesses
+func TestRedeemPairing(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	t.Setenv("HOME", tmpDir)
+
+	code, err := startPairing(time.Minute)
+	if err != nil {
+		t.Fatalf("startPairing failed: %v", err)
+	}
+
+	if err := redeemPairing(code, time.Now().Add(2*time.Minute)); err != errPairingRejected {
+		t.Errorf("Expired code should be rejected, got %v", err)
+	}
+	if err := redeemPairing("WRONG-CODE", time.Now()); err != errPairingRejected {
+		t.Errorf("Wrong code should be rejected, got %v", err)
+	}
+	if pairing, _ := loadPairing(); pairing == nil || pairing.Attempts != 1 {
+		t.Errorf("Wrong code should be counted, got %+v", pairing)
+	}
+
+	// Typed in lower case without the dash
+	typed := strings.ToLower(strings.ReplaceAll(code, "-", ""))
+	if err := redeemPairing(typed, time.Now()); err != nil {
+		t.Fatalf("Correct code should be accepted, got %v", err)
+	}
+	if err := redeemPairing(code, time.Now()); err != errPairingRejected {
+		t.Errorf("Code should only work once, got %v", err)
+	}
+
+	// Too many wrong guesses lock the code
+	code, _ = startPairing(time.Minute)
+	for i := 0; i < maxPairingAttempts; i++ {
+		redeemPairing("AAAA-AAAA", time.Now())
+	}
+	if err := redeemPairing(code, time.Now()); err != errPairingRejected {
+		t.Errorf("Code should be locked after %d wrong guesses, got %v", maxPairingAttempts, err)
+	}
+}
+
+// Test pairing through the API issues a working token
+func TestAPIPair(t *testing.T) {
+	server, _ := newTestAPIServer(t)
+
+	code, err := startPairing(time.Minute)
+	if err != nil {
+		t.Fatalf("startPairing failed: %v", err)
+	}
+
+	resp, err := http.Post(server.URL+"/api/pair", "application/json", strings.NewReader(`{"code": "NOPE-NOPE"}`))
+	if err != nil {
+		t.Fatalf("Pair request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Wrong code: expected 403, got %d", resp.StatusCode)
+	}
+
+	resp, err = http.Post(server.URL+"/api/pair", "application/json", strings.NewReader(`{"code": "`+code+`", "name": "Chrome"}`))
+	if err != nil {
+		t.Fatalf("Pair request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	var paired struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&paired); err != nil || paired.Token == "" {
+		t.Fatalf("Expected a token, got %v %v", paired, err)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/api/entries", nil)
+	req.Header.Set("Authorization", "Bearer "+paired.Token)
+	check, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Entries request failed: %v", err)
+	}
+	check.Body.Close()
+	if check.StatusCode != http.StatusOK {
+		t.Errorf("Paired token should be accepted, got %d", check.StatusCode)
+	}
+
+	stored, _ := LoadConfig()
+	if len(stored.PairedTokens) != 1 || stored.PairedTokens[0].Name != "Chrome" {
+		t.Fatalf("Paired token should be saved, got %+v", stored.PairedTokens)
+	}
+	if stored.PairedTokens[0].Hash == paired.Token {
+		t.Error("Only a hash of the token should be stored")
+	}
+}
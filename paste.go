@@ -0,0 +1,138 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// errPasteCancelled means the user closed the paste dialog without a link
+var errPasteCancelled = errors.New("paste cancelled")
+
+// errDialogCancelled means the user dismissed a dialog
+var errDialogCancelled = errors.New("dialog cancelled")
+
+// errNoDialog means none of the dialog programs is installed
+var errNoDialog = errors.New("no dialog program available")
+
+const (
+	pasteTitle  = "Magnet Handler"
+	pastePrompt = "Paste a magnet link"
+)
+
+// dialogCommand is a native program that can show a prompt or a notification
+type dialogCommand struct {
+	Name string
+	Args []string
+}
+
+// pasteDialogs returns the input dialogs to try, in order, for this platform
+var pasteDialogs = func() []dialogCommand {
+	switch runtime.GOOS {
+	case "darwin":
+		script := fmt.Sprintf(`text returned of (display dialog %q default answer "" with title %q)`, pastePrompt, pasteTitle)
+		return []dialogCommand{{"osascript", []string{"-e", script}}}
+	case "windows":
+		script := fmt.Sprintf("Add-Type -AssemblyName Microsoft.VisualBasic; [Microsoft.VisualBasic.Interaction]::InputBox('%s', '%s')", pastePrompt, pasteTitle)
+		return []dialogCommand{{"powershell", []string{"-NoProfile", "-Command", script}}}
+	default:
+		return []dialogCommand{
+			{"zenity", []string{"--entry", "--title", pasteTitle, "--text", pastePrompt, "--width", "600"}},
+			{"kdialog", []string{"--title", pasteTitle, "--inputbox", pastePrompt}},
+		}
+	}
+}
+
+// runDialog runs the first installed dialog and returns what it printed
+func runDialog(dialogs []dialogCommand) (string, error) {
+	for _, dialog := range dialogs {
+		if _, err := exec.LookPath(dialog.Name); err != nil {
+			continue
+		}
+		out, err := exec.Command(dialog.Name, dialog.Args...).Output()
+		result := strings.TrimSpace(string(out))
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) || (err == nil && result == "") {
+			// Cancel buttons exit non-zero or return nothing
+			return "", errDialogCancelled
+		}
+		if err != nil {
+			return "", fmt.Errorf("%s failed: %w", dialog.Name, err)
+		}
+		return result, nil
+	}
+	return "", errNoDialog
+}
+
+// PromptMagnetURI shows a native input dialog and returns what was pasted
+func PromptMagnetURI() (string, error) {
+	uri, err := runDialog(pasteDialogs())
+	switch {
+	case errors.Is(err, errDialogCancelled):
+		return "", errPasteCancelled
+	case errors.Is(err, errNoDialog):
+		return "", fmt.Errorf("no input dialog available (install zenity or kdialog)")
+	}
+	return uri, err
+}
+
+// powershellQuote returns s as a single-quoted PowerShell string. PowerShell
+// also ends such strings at the curly quotes U+2018 to U+201B, so each of
+// them is doubled like the ASCII quote.
+func powershellQuote(s string) string {
+	var b strings.Builder
+	b.WriteByte('\'')
+	for _, r := range s {
+		switch r {
+		case '\'', '\u2018', '\u2019', '\u201A', '\u201B':
+			b.WriteRune(r)
+		}
+		b.WriteRune(r)
+	}
+	b.WriteByte('\'')
+	return b.String()
+}
+
+// desktopNotifier shows notifications through the platform's notification center
+type desktopNotifier struct{}
+
+func (desktopNotifier) Notify(n Notification) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		script := fmt.Sprintf("display notification %q with title %q", n.Message, n.Title)
+		cmd = exec.Command("osascript", "-e", script)
+	case "windows":
+		script := "Add-Type -AssemblyName System.Windows.Forms; " +
+			"$n = New-Object System.Windows.Forms.NotifyIcon; " +
+			"$n.Icon = [System.Drawing.SystemIcons]::Information; $n.Visible = $true; " +
+			fmt.Sprintf("$n.ShowBalloonTip(5000, %s, %s, 'Info'); Start-Sleep 6; $n.Dispose()", powershellQuote(n.Title), powershellQuote(n.Message))
+		cmd = exec.Command("powershell", "-NoProfile", "-WindowStyle", "Hidden", "-Command", script)
+	default:
+		urgency := "normal"
+		if n.Level == NotifyCritical {
+			urgency = "critical"
+		}
+		cmd = exec.Command("notify-send", "--app-name", pasteTitle, "--urgency", urgency, n.Title, n.Message)
+	}
+	return cmd.Run()
+}
+
+// RunPaste asks for a magnet link in a dialog and adds it, reporting the
+// outcome through Notify since there may be no console to read
+func RunPaste(config Config) error {
+	uri, err := PromptMagnetURI()
+	if errors.Is(err, errPasteCancelled) {
+		log.Println("Paste cancelled")
+		return nil
+	}
+	if err != nil {
+		Notify(NotifyWarning, "Could not open paste dialog", err.Error())
+		return err
+	}
+
+	return notifyAddResult(uri, config, func() error { return AddMagnetToDeluge(uri, config) })
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"os"
+	"runtime"
+	"testing"
+)
+
+// recordingNotifier keeps notifications for inspection
+type recordingNotifier struct {
+	sent *[]Notification
+}
+
+func (r recordingNotifier) Notify(n Notification) error {
+	*r.sent = append(*r.sent, n)
+	return nil
+}
+
+// Test RunPaste adds the pasted magnet and reports the outcome
+func TestRunPaste(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Fake dialogs in this test need a Unix shell")
+	}
+
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	t.Setenv("HOME", tmpDir)
+
+	config := DefaultConfig()
+	enableSimulation(&config, tmpDir)
+	config.RemotePath = ""
+	config.MetadataTimeout = -1
+	defer func() { simulateDeluge = false }()
+
+	var sent []Notification
+	originalNotifiers, originalDialogs := notifiers, pasteDialogs
+	notifiers = []Notifier{recordingNotifier{&sent}}
+	defer func() { notifiers, pasteDialogs = originalNotifiers, originalDialogs }()
+
+	tests := []struct {
+		name      string
+		output    string
+		wantTitle string
+		wantErr   bool
+	}{
+		{"valid magnet", "magnet:?xt=urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a&dn=Pasted+Book", "Magnet added", false},
+		{"cancelled", "", "", false},
+		{"not a magnet", "https://example.com", "Magnet not added", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sent = nil
+			pasteDialogs = func() []dialogCommand {
+				return []dialogCommand{{"sh", []string{"-c", "printf '%s\\n' \"$0\"", tt.output}}}
+			}
+
+			err := RunPaste(config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RunPaste error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantTitle == "" {
+				if len(sent) != 0 {
+					t.Errorf("Expected no notification, got %+v", sent)
+				}
+				return
+			}
+			if len(sent) != 1 || sent[0].Title != tt.wantTitle {
+				t.Errorf("Expected %q notification, got %+v", tt.wantTitle, sent)
+			}
+		})
+	}
+
+	db, err := LoadJSONDatabase(config.JSONPath)
+	if err != nil {
+		t.Fatalf("LoadJSONDatabase failed: %v", err)
+	}
+	if _, ok := db.Added["c12fe1c06bba254a9dc9f519b335aa7c1367a88a"]; !ok {
+		t.Error("Pasted magnet should be in the database")
+	}
+}
+
+// Test PromptMagnetURI reports a missing dialog program
+func TestPromptMagnetURINoDialog(t *testing.T) {
+	original := pasteDialogs
+	pasteDialogs = func() []dialogCommand {
+		return []dialogCommand{{"magnet-handler-no-such-dialog", nil}}
+	}
+	defer func() { pasteDialogs = original }()
+
+	if _, err := PromptMagnetURI(); err == nil || err == errPasteCancelled {
+		t.Errorf("Expected a missing dialog error, got %v", err)
+	}
+}
+
+// Test powershellQuote doubles every quote PowerShell ends a string at
+func TestPowershellQuote(t *testing.T) {
+	tests := map[string]string{
+		"plain":                     "'plain'",
+		"it's":                      "'it''s'",
+		"a’); calc; ('":             "'a’’); calc; ('''",
+		"‘‚‛":                       "'‘‘‚‚‛‛'",
+		"$(Get-Process) \"double\"": "'$(Get-Process) \"double\"'",
+	}
+	for in, want := range tests {
+		if got := powershellQuote(in); got != want {
+			t.Errorf("powershellQuote(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
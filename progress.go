@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/term"
+)
+
+const (
+	// progressLogInterval is how often a batch logs its progress when
+	// stdout is not a terminal
+	progressLogInterval = 10 * time.Second
+
+	// progressRedrawInterval limits how often the bar is redrawn
+	progressRedrawInterval = 100 * time.Millisecond
+
+	// progressBarWidth is the number of cells in the bar
+	progressBarWidth = 30
+)
+
+var (
+	// progressOutput is where the bar is drawn; tests replace it
+	progressOutput io.Writer = os.Stdout
+
+	// progressTerminal reports whether stdout can show a bar; tests replace it
+	progressTerminal = func() bool { return term.IsTerminal(int(os.Stdout.Fd())) }
+
+	// progressNow returns the current time; tests replace it
+	progressNow = time.Now
+
+	// progressMu guards activeBar and writes to progressOutput
+	progressMu sync.Mutex
+
+	// activeBar is the bar currently drawn on the last console line
+	activeBar string
+)
+
+// batchProgress shows how far a backfill, retry, or sync has got, with its
+// rate and time left: a bar redrawn in place when stdout is a terminal,
+// otherwise a log line every progressLogInterval
+type batchProgress struct {
+	label    string
+	total    int
+	done     int
+	start    time.Time
+	shown    time.Time // When progress was last drawn or logged
+	terminal bool
+}
+
+// newBatchProgress starts reporting progress through total items
+func newBatchProgress(label string, total int) *batchProgress {
+	now := progressNow()
+	return &batchProgress{label: label, total: total, start: now, shown: now, terminal: progressTerminal()}
+}
+
+// Step records n more items done
+func (p *batchProgress) Step(n int) {
+	p.done += n
+	now := progressNow()
+	switch {
+	case p.terminal && (now.Sub(p.shown) >= progressRedrawInterval || p.done >= p.total):
+		p.shown = now
+		p.draw(p.bar(now))
+	case !p.terminal && now.Sub(p.shown) >= progressLogInterval && p.done < p.total:
+		p.shown = now
+		log.Printf("%s: %s", p.label, p.status(now))
+	}
+}
+
+// Finish clears the bar so the summary that follows starts on a clean line
+func (p *batchProgress) Finish() {
+	if p.terminal {
+		p.draw("")
+	}
+}
+
+// status describes the count, rate, and time left, e.g.
+// "120/500 (24%), 12.0/s, about 32s left"
+func (p *batchProgress) status(now time.Time) string {
+	percent := 100
+	if p.total > 0 {
+		percent = p.done * 100 / p.total
+	}
+	s := fmt.Sprintf("%d/%d (%d%%)", p.done, p.total, percent)
+	elapsed := now.Sub(p.start).Seconds()
+	if elapsed <= 0 || p.done == 0 {
+		return s
+	}
+	rate := float64(p.done) / elapsed
+	s += fmt.Sprintf(", %.1f/s", rate)
+	if left := p.total - p.done; left > 0 {
+		eta := time.Duration(float64(left) / rate * float64(time.Second))
+		s += fmt.Sprintf(", about %s left", eta.Round(time.Second))
+	}
+	return s
+}
+
+// bar renders the progress bar line
+func (p *batchProgress) bar(now time.Time) string {
+	filled := progressBarWidth
+	if p.total > 0 && p.done < p.total {
+		filled = p.done * progressBarWidth / p.total
+	}
+	return fmt.Sprintf("%s [%s%s] %s", p.label, strings.Repeat("#", filled), strings.Repeat(".", progressBarWidth-filled), p.status(now))
+}
+
+// draw replaces the bar on the last console line, or clears it
+func (p *batchProgress) draw(bar string) {
+	progressMu.Lock()
+	defer progressMu.Unlock()
+	fmt.Fprint(progressOutput, "\r\033[K"+bar)
+	activeBar = bar
+}
+
+// progressConsole is the console log output. It lifts a drawn bar out of
+// the way of each log line and redraws it below.
+type progressConsole struct {
+	io.Writer
+}
+
+func (c progressConsole) Write(b []byte) (int, error) {
+	progressMu.Lock()
+	defer progressMu.Unlock()
+	if activeBar == "" {
+		return c.Writer.Write(b)
+	}
+	fmt.Fprint(c.Writer, "\r\033[K")
+	n, err := c.Writer.Write(b)
+	fmt.Fprint(c.Writer, activeBar)
+	return n, err
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test a terminal gets a bar with the rate and time left, redrawn in place
+// and kept below log lines
+func TestBatchProgressTerminal(t *testing.T) {
+	var out bytes.Buffer
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	output, terminal := progressOutput, progressTerminal
+	defer func() { progressOutput, progressTerminal, progressNow = output, terminal, time.Now }()
+	progressOutput = &out
+	progressTerminal = func() bool { return true }
+	progressNow = func() time.Time { return now }
+
+	p := newBatchProgress("Retry", 4)
+	now = now.Add(2 * time.Second)
+	p.Step(1)
+	if got := out.String(); !strings.HasSuffix(got, "Retry [#######.......................] 1/4 (25%), 0.5/s, about 6s left") {
+		t.Errorf("Unexpected bar %q", got)
+	}
+
+	var console bytes.Buffer
+	progressConsole{&console}.Write([]byte("Retrying [2/4]: Book\n"))
+	if got := console.String(); !strings.HasPrefix(got, "\r\033[KRetrying [2/4]: Book\nRetry [") {
+		t.Errorf("Expected the log line written over the bar and the bar redrawn, got %q", got)
+	}
+
+	out.Reset()
+	p.Step(1)
+	if out.Len() != 0 {
+		t.Errorf("Expected no redraw within %s, got %q", progressRedrawInterval, out.String())
+	}
+	p.Step(2)
+	if !strings.Contains(out.String(), "4/4 (100%)") {
+		t.Errorf("Expected the finished count drawn, got %q", out.String())
+	}
+	p.Finish()
+	if activeBar != "" || !strings.HasSuffix(out.String(), "\r\033[K") {
+		t.Errorf("Expected the bar cleared, got %q", out.String())
+	}
+}
+
+// Test without a terminal progress is logged every progressLogInterval
+func TestBatchProgressLog(t *testing.T) {
+	var out bytes.Buffer
+	original := log.Writer()
+	log.SetOutput(&out)
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	terminal := progressTerminal
+	defer func() { log.SetOutput(original); progressTerminal, progressNow = terminal, time.Now }()
+	progressTerminal = func() bool { return false }
+	progressNow = func() time.Time { return now }
+
+	p := newBatchProgress("Backfill", 300)
+	p.Step(100)
+	if out.Len() != 0 {
+		t.Errorf("Expected nothing logged before the interval, got %q", out.String())
+	}
+	now = now.Add(progressLogInterval)
+	p.Step(100)
+	if !strings.Contains(out.String(), "Backfill: 200/300 (66%), 20.0/s, about 5s left") {
+		t.Errorf("Unexpected progress line %q", out.String())
+	}
+	p.Finish()
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+var pruneCommand = &Command{
+	Name:    "prune",
+	Usage:   "prune [--older-than days] [--archive file] [--dry-run]",
+	Summary: "Drop removed entries older than a cutoff, optionally archiving them to a file first",
+}
+
+func init() {
+	pruneCommand.Run = runPrune
+	registerCommand(pruneCommand)
+}
+
+// pruneCandidates returns the removed entries removed before cutoff. Entries
+// without a removal time are kept.
+func pruneCandidates(db *MagnetDatabase, cutoff time.Time) map[string]MagnetEntry {
+	candidates := make(map[string]MagnetEntry)
+	for hash, entry := range db.Removed {
+		if removed := parseTimestamp(entry.RemovedAt); !removed.IsZero() && removed.Before(cutoff) {
+			candidates[hash] = entry
+		}
+	}
+	return candidates
+}
+
+// archiveEntries adds entries to the removed section of the archive
+// database at path, creating it if needed
+func archiveEntries(path string, entries map[string]MagnetEntry) error {
+	archive := &MagnetDatabase{
+		Added:   make(map[string]MagnetEntry),
+		Retry:   make(map[string]MagnetEntry),
+		Removed: make(map[string]MagnetEntry),
+	}
+	if _, err := os.Stat(path); err == nil {
+		if archive, err = LoadJSONDatabase(path); err != nil {
+			return fmt.Errorf("failed to load archive: %w", err)
+		}
+		if archive.Removed == nil {
+			archive.Removed = make(map[string]MagnetEntry)
+		}
+	}
+	for hash, entry := range entries {
+		archive.Removed[hash] = entry
+	}
+	return SaveDatabaseLocal(path, archive)
+}
+
+// runPrune implements the prune command
+func runPrune(config Config, args []string) error {
+	fs := newCommandFlags(pruneCommand)
+	days := fs.Int("older-than", defaultPruneAge, "Drop entries removed more than this many days ago")
+	archive := fs.String("archive", "", "Also write the dropped entries to this file (added to it if it exists)")
+	dryRun := fs.Bool("dry-run", false, "Show how many entries would be dropped without changing anything")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+	if *days < 0 {
+		return fmt.Errorf("--older-than must not be negative")
+	}
+
+	db, err := LoadJSONDatabase(config.JSONPath)
+	if err != nil {
+		return fmt.Errorf("failed to load database: %w", err)
+	}
+	now := time.Now()
+	candidates := pruneCandidates(db, now.AddDate(0, 0, -*days))
+	if len(candidates) == 0 {
+		log.Printf("✓ No entries removed more than %d days ago", *days)
+		return nil
+	}
+	if *dryRun {
+		log.Printf("Would drop %s removed more than %d days ago (%d entries left)",
+			plural(len(candidates), "entry", "entries"), *days, len(db.Added)+len(db.Retry)+len(db.Removed)-len(candidates))
+		return nil
+	}
+
+	if *archive != "" {
+		if err := archiveEntries(*archive, candidates); err != nil {
+			return err
+		}
+		log.Printf("✓ Archived %s to %s", plural(len(candidates), "entry", "entries"), *archive)
+	}
+	update := &MagnetDatabase{
+		Added:      make(map[string]MagnetEntry),
+		Retry:      make(map[string]MagnetEntry),
+		Tombstones: make(map[string]Tombstone),
+	}
+	for hash := range candidates {
+		update.Tombstones[hash] = newTombstone(hash, now)
+	}
+	if err := SaveJSONDatabase(config.JSONPath, update, &config); err != nil {
+		return fmt.Errorf("failed to save database: %w", err)
+	}
+	log.Printf("✓ Dropped %s removed more than %d days ago", plural(len(candidates), "entry", "entries"), *days)
+	return nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Test prune archives and drops only entries removed before the cutoff
+func TestPrune(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	t.Setenv("HOME", tmpDir)
+
+	config := DefaultConfig()
+	config.JSONPath = filepath.Join(tmpDir, "db.json")
+	config.RemotePath = ""
+	old := time.Now().AddDate(-2, 0, 0).Format(time.RFC3339)
+	recent := time.Now().AddDate(0, -1, 0).Format(time.RFC3339)
+	db := &MagnetDatabase{
+		Added: map[string]MagnetEntry{"hash1": {Hash: "hash1", Title: "Kept"}},
+		Retry: map[string]MagnetEntry{},
+		Removed: map[string]MagnetEntry{
+			"hash2": {Hash: "hash2", Title: "Old", RemovedAt: old},
+			"hash3": {Hash: "hash3", Title: "Recent", RemovedAt: recent},
+		},
+	}
+	if err := SaveDatabaseLocal(config.JSONPath, db); err != nil {
+		t.Fatalf("Failed to save database: %v", err)
+	}
+
+	if err := runPrune(config, []string{"--dry-run"}); err != nil {
+		t.Fatalf("prune --dry-run failed: %v", err)
+	}
+	if db, _ := LoadJSONDatabase(config.JSONPath); len(db.Removed) != 2 {
+		t.Fatal("A dry run should not drop anything")
+	}
+
+	archive := filepath.Join(tmpDir, "archive.json")
+	if err := runPrune(config, []string{"--archive", archive}); err != nil {
+		t.Fatalf("prune failed: %v", err)
+	}
+	db, err = LoadJSONDatabase(config.JSONPath)
+	if err != nil {
+		t.Fatalf("LoadJSONDatabase failed: %v", err)
+	}
+	if _, ok := db.Removed["hash2"]; ok || len(db.Removed) != 1 || len(db.Added) != 1 {
+		t.Errorf("Expected only the old removed entry dropped, got %+v", db.Removed)
+	}
+	saved, err := LoadJSONDatabase(archive)
+	if err != nil {
+		t.Fatalf("Failed to load archive: %v", err)
+	}
+	if entry, ok := saved.Removed["hash2"]; !ok || entry.Title != "Old" {
+		t.Errorf("Expected the dropped entry in the archive, got %+v", saved.Removed)
+	}
+
+	if err := runPrune(config, []string{"--older-than", "7"}); err != nil {
+		t.Fatalf("prune --older-than 7 failed: %v", err)
+	}
+	if db, _ := LoadJSONDatabase(config.JSONPath); len(db.Removed) != 0 || len(db.Added) != 1 {
+		t.Errorf("Expected every removed entry dropped, got %+v", db.Removed)
+	}
+}
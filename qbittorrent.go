@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+)
+
+// QBittorrentClient talks to the qBittorrent Web API (v2)
+type QBittorrentClient struct {
+	BaseURL    string
+	Username   string
+	Password   string
+	HTTPClient *http.Client
+	Simulate   bool // Answer requests locally instead of contacting qBittorrent
+}
+
+// NewQBittorrentClient creates a client for the Web UI at baseURL
+func NewQBittorrentClient(baseURL, username, password string) *QBittorrentClient {
+	jar, _ := cookiejar.New(nil)
+	shared := sharedHTTPClient()
+	return &QBittorrentClient{
+		BaseURL:  strings.TrimRight(baseURL, "/"),
+		Username: username,
+		Password: password,
+		// Own cookie jar for the session, pooled connections from the shared transport
+		HTTPClient: &http.Client{Timeout: shared.Timeout, Transport: shared.Transport, Jar: jar},
+		Simulate:   simulateDeluge,
+	}
+}
+
+// post sends a form to a Web API method and returns the response text
+func (c *QBittorrentClient) post(method string, form url.Values) (string, error) {
+	if c.Simulate {
+		return simulatedQBittorrentResponse(method), nil
+	}
+
+	req, err := http.NewRequest("POST", c.BaseURL+"/api/v2/"+method, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	// qBittorrent rejects requests whose Referer does not match its host
+	req.Header.Set("Referer", c.BaseURL)
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case resp.StatusCode == http.StatusForbidden:
+		return "", errAuthRejected
+	case resp.StatusCode != http.StatusOK:
+		return "", fmt.Errorf("qBittorrent %s returned status %d: %s", method, resp.StatusCode, bodySnippet(body))
+	}
+	return strings.TrimSpace(string(body)), nil
+}
+
+// Authenticate logs into qBittorrent
+func (c *QBittorrentClient) Authenticate() error {
+	reply, err := c.post("auth/login", url.Values{"username": {c.Username}, "password": {c.Password}})
+	if err != nil {
+		return err
+	}
+	if reply != "Ok." {
+		return errAuthRejected
+	}
+	return nil
+}
+
+// HasTorrent reports whether a torrent with the given info hash exists
+func (c *QBittorrentClient) HasTorrent(hash string) (bool, error) {
+	reply, err := c.post("torrents/info", url.Values{"hashes": {strings.ToLower(hash)}})
+	if err != nil {
+		return false, err
+	}
+	return reply != "" && reply != "[]", nil
+}
+
+// AddMagnet adds a magnet link in the given category
+func (c *QBittorrentClient) AddMagnet(magnetURI, category string) error {
+	form := url.Values{"urls": {magnetURI}}
+	if category != "" {
+		form.Set("category", category)
+	}
+	reply, err := c.post("torrents/add", form)
+	if err != nil {
+		return err
+	}
+	if reply != "Ok." {
+		return fmt.Errorf("qBittorrent refused the magnet: %s", reply)
+	}
+	return nil
+}
+
+// QBittorrentTorrent is the subset of torrents/info fields the handler uses
+type QBittorrentTorrent struct {
+	Hash        string  `json:"hash"`
+	Name        string  `json:"name"`
+	SavePath    string  `json:"save_path"`
+	ContentPath string  `json:"content_path"`
+	State       string  `json:"state"`
+	Progress    float64 `json:"progress"` // 0 to 1
+}
+
+// TorrentsInfo returns the torrents with the given info hashes
+func (c *QBittorrentClient) TorrentsInfo(hashes []string) ([]QBittorrentTorrent, error) {
+	lower := make([]string, len(hashes))
+	for i, hash := range hashes {
+		lower[i] = strings.ToLower(hash)
+	}
+	reply, err := c.post("torrents/info", url.Values{"hashes": {strings.Join(lower, "|")}})
+	if err != nil {
+		return nil, err
+	}
+	var torrents []QBittorrentTorrent
+	if err := json.Unmarshal([]byte(reply), &torrents); err != nil {
+		return nil, &ResponseError{Method: "torrents/info", Reason: err.Error()}
+	}
+	return torrents, nil
+}
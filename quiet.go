@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// QuietHours holds back non-critical notifications overnight. Held
+// notifications are delivered as one summary once quiet hours end; critical
+// ones, such as a rejected Deluge password, are always sent at once.
+type QuietHours struct {
+	Start    string `json:"start"`              // Local time quiet hours begin, e.g. "22:00"
+	End      string `json:"end"`                // Local time they end, e.g. "07:00"
+	Suppress bool   `json:"suppress,omitempty"` // Drop held notifications instead of summarizing them
+}
+
+// quietHours is the active schedule, applied from config at startup (nil = off)
+var quietHours *QuietHours
+
+// quietNow returns the current time; tests replace it
+var quietNow = time.Now
+
+// heldNotification is a notification waiting for quiet hours to end
+type heldNotification struct {
+	At    string      `json:"at"`
+	Level NotifyLevel `json:"level"`
+	Title string      `json:"title"`
+}
+
+// parseClock parses an HH:MM time of day into minutes after midnight
+func parseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q (use HH:MM)", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// ConfigureQuietHours applies the quiet hours schedule from the config
+func ConfigureQuietHours(config Config) error {
+	quietHours = nil
+	if config.QuietHours == nil {
+		return nil
+	}
+	start, err := parseClock(config.QuietHours.Start)
+	if err != nil {
+		return fmt.Errorf("quiet_hours start: %w", err)
+	}
+	end, err := parseClock(config.QuietHours.End)
+	if err != nil {
+		return fmt.Errorf("quiet_hours end: %w", err)
+	}
+	if start == end {
+		return fmt.Errorf("quiet_hours start and end are both %s", config.QuietHours.Start)
+	}
+	quietHours = config.QuietHours
+	return nil
+}
+
+// active reports whether t falls within quiet hours. Schedules may wrap past
+// midnight (22:00-07:00).
+func (q QuietHours) active(t time.Time) bool {
+	start, err := parseClock(q.Start)
+	if err != nil {
+		return false
+	}
+	end, err := parseClock(q.End)
+	if err != nil {
+		return false
+	}
+	now := t.Hour()*60 + t.Minute()
+	if start < end {
+		return now >= start && now < end
+	}
+	return now >= start || now < end
+}
+
+// heldNotificationsPath returns where notifications held during quiet hours
+// are kept until the summary is sent
+func heldNotificationsPath() (string, error) {
+	homeDir, err := getHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, ".magnet-handler", "quiet-notifications.json"), nil
+}
+
+// loadHeldNotifications reads the notifications held so far
+func loadHeldNotifications(path string) []heldNotification {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var held []heldNotification
+	if err := json.Unmarshal(data, &held); err != nil {
+		log.Printf("Warning: Ignoring unreadable %s: %v", path, err)
+		return nil
+	}
+	return held
+}
+
+// holdNotification keeps a notification raised during quiet hours for the
+// summary, or drops it when quiet hours suppress notifications
+func holdNotification(n Notification) {
+	if quietHours.Suppress {
+		log.Printf("Quiet hours: dropped notification %q", n.Title)
+		return
+	}
+	log.Printf("Quiet hours: holding notification %q for the summary", n.Title)
+
+	path, err := heldNotificationsPath()
+	if err != nil {
+		log.Printf("Warning: Could not hold notification: %v", err)
+		return
+	}
+	held := append(loadHeldNotifications(path), heldNotification{
+		At:    quietNow().Format(time.RFC3339),
+		Level: n.Level,
+		Title: n.Title,
+	})
+	data, err := json.MarshalIndent(held, "", "  ")
+	if err == nil {
+		if err = os.MkdirAll(filepath.Dir(path), 0700); err == nil {
+			err = os.WriteFile(path, data, 0600)
+		}
+	}
+	if err != nil {
+		log.Printf("Warning: Could not hold notification: %v", err)
+	}
+}
+
+// deliverQuietSummary sends one notification listing everything held during
+// quiet hours, once they are over
+func deliverQuietSummary() {
+	if quietHours != nil && quietHours.active(quietNow()) {
+		return
+	}
+	path, err := heldNotificationsPath()
+	if err != nil {
+		return
+	}
+	held := loadHeldNotifications(path)
+	if len(held) == 0 {
+		return
+	}
+	if err := os.Remove(path); err != nil {
+		log.Printf("Warning: Could not clear held notifications: %v", err)
+		return
+	}
+
+	level := NotifyInfo
+	lines := make([]string, 0, len(held))
+	for _, h := range held {
+		if h.Level == NotifyWarning {
+			level = NotifyWarning
+		}
+		at := h.At
+		if t, err := time.Parse(time.RFC3339, h.At); err == nil {
+			at = t.Local().Format("15:04")
+		}
+		lines = append(lines, fmt.Sprintf("%s %s", at, h.Title))
+	}
+	deliver(Notification{
+		Level:   level,
+		Title:   fmt.Sprintf("During quiet hours: %s", plural(len(held), "notification", "notifications")),
+		Message: strings.Join(lines, "\n"),
+	})
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test QuietHours.active with and without wrapping past midnight
+func TestQuietHoursActive(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2024, 6, 1, hour, minute, 0, 0, time.Local)
+	}
+	overnight := QuietHours{Start: "22:00", End: "07:00"}
+	daytime := QuietHours{Start: "09:30", End: "17:00"}
+
+	tests := []struct {
+		hours QuietHours
+		time  time.Time
+		want  bool
+	}{
+		{overnight, at(23, 0), true},
+		{overnight, at(3, 0), true},
+		{overnight, at(22, 0), true},
+		{overnight, at(7, 0), false},
+		{overnight, at(12, 0), false},
+		{daytime, at(9, 29), false},
+		{daytime, at(9, 30), true},
+		{daytime, at(16, 59), true},
+		{daytime, at(17, 0), false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.hours.active(tt.time); got != tt.want {
+			t.Errorf("%s-%s at %s: active = %v, want %v", tt.hours.Start, tt.hours.End, tt.time.Format("15:04"), got, tt.want)
+		}
+	}
+}
+
+// Test ConfigureQuietHours validates the schedule
+func TestConfigureQuietHours(t *testing.T) {
+	defer func() { quietHours = nil }()
+
+	tests := []struct {
+		hours   *QuietHours
+		wantErr bool
+	}{
+		{nil, false},
+		{&QuietHours{Start: "22:00", End: "07:00"}, false},
+		{&QuietHours{Start: "10pm", End: "07:00"}, true},
+		{&QuietHours{Start: "22:00", End: ""}, true},
+		{&QuietHours{Start: "07:00", End: "07:00"}, true},
+	}
+
+	for _, tt := range tests {
+		err := ConfigureQuietHours(Config{QuietHours: tt.hours})
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ConfigureQuietHours(%+v) error = %v, wantErr %v", tt.hours, err, tt.wantErr)
+		}
+	}
+}
+
+// Test notifications are held during quiet hours and summarized afterwards
+func TestNotifyQuietHours(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	t.Setenv("HOME", tmpDir)
+
+	var sent []Notification
+	original := notifiers
+	notifiers = []Notifier{recordingNotifier{&sent}}
+	defer func() { notifiers, quietHours, quietNow = original, nil, time.Now }()
+
+	if err := ConfigureQuietHours(Config{QuietHours: &QuietHours{Start: "22:00", End: "07:00"}}); err != nil {
+		t.Fatalf("ConfigureQuietHours failed: %v", err)
+	}
+	quietNow = func() time.Time { return time.Date(2024, 6, 1, 23, 15, 0, 0, time.Local) }
+
+	Notify(NotifyInfo, "Magnet added", "Book One")
+	Notify(NotifyWarning, "Magnet queued for retry", "Book Two")
+	Notify(NotifyCritical, "Deluge rejected the password", "Check the config")
+	if len(sent) != 1 || sent[0].Level != NotifyCritical {
+		t.Fatalf("Expected only the critical notification during quiet hours, got %+v", sent)
+	}
+
+	// Still quiet: nothing is summarized yet
+	deliverQuietSummary()
+	if len(sent) != 1 {
+		t.Fatalf("Summary sent during quiet hours: %+v", sent)
+	}
+
+	quietNow = func() time.Time { return time.Date(2024, 6, 2, 7, 30, 0, 0, time.Local) }
+	deliverQuietSummary()
+	if len(sent) != 2 {
+		t.Fatalf("Expected a summary after quiet hours, got %+v", sent)
+	}
+	summary := sent[1]
+	if summary.Level != NotifyWarning || !strings.Contains(summary.Title, "2 notifications") {
+		t.Errorf("Unexpected summary: %+v", summary)
+	}
+	if !strings.Contains(summary.Message, "23:15 Magnet added") || !strings.Contains(summary.Message, "Magnet queued for retry") {
+		t.Errorf("Summary should list held notifications, got %q", summary.Message)
+	}
+
+	// The summary is only sent once
+	Notify(NotifyInfo, "Magnet added", "Book Three")
+	if len(sent) != 3 || sent[2].Title != "Magnet added" {
+		t.Errorf("Expected only the new notification, got %+v", sent[2:])
+	}
+}
+
+// Test suppress mode drops held notifications
+func TestNotifyQuietHoursSuppress(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	t.Setenv("HOME", tmpDir)
+
+	var sent []Notification
+	original := notifiers
+	notifiers = []Notifier{recordingNotifier{&sent}}
+	defer func() { notifiers, quietHours, quietNow = original, nil, time.Now }()
+
+	ConfigureQuietHours(Config{QuietHours: &QuietHours{Start: "22:00", End: "07:00", Suppress: true}})
+	quietNow = func() time.Time { return time.Date(2024, 6, 1, 23, 0, 0, 0, time.Local) }
+	Notify(NotifyWarning, "Magnet queued for retry", "Book")
+
+	quietNow = func() time.Time { return time.Date(2024, 6, 2, 8, 0, 0, 0, time.Local) }
+	deliverQuietSummary()
+	if len(sent) != 0 {
+		t.Errorf("Suppressed notifications should not be summarized, got %+v", sent)
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// rcloneBinary is the rclone executable used for remote: paths
+var rcloneBinary = "rclone"
+
+// rcloneRemotePattern matches rclone's remote:path syntax
+var rcloneRemotePattern = regexp.MustCompile(`^[A-Za-z0-9_.][A-Za-z0-9_. -]*:`)
+
+// isRcloneRemote reports whether a remote path names an rclone remote
+// (gdrive:magnet/list.json) rather than a file system path. Single letters
+// are drive letters on Windows.
+func isRcloneRemote(path string) bool {
+	if !rcloneRemotePattern.MatchString(path) {
+		return false
+	}
+	name, _, _ := strings.Cut(path, ":")
+	return len(name) > 1 || runtime.GOOS != "windows"
+}
+
+// rcloneCopy copies a single file between rclone locations
+func rcloneCopy(src, dst string) error {
+	output, err := exec.Command(rcloneBinary, "copyto", src, dst).CombinedOutput()
+	if err != nil {
+		if msg := strings.TrimSpace(string(output)); msg != "" {
+			return fmt.Errorf("rclone copyto failed: %w: %s", err, bodySnippet(output))
+		}
+		return fmt.Errorf("rclone copyto failed: %w", err)
+	}
+	return nil
+}
+
+// rcloneStore reaches an rclone remote by copying the whole file
+type rcloneStore struct {
+	remote string
+}
+
+func (r rcloneStore) Fetch(cache string) error  { return rcloneCopy(r.remote, cache) }
+func (r rcloneStore) Upload(cache string) error { return rcloneCopy(cache, r.remote) }
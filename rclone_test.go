@@ -0,0 +1,129 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// Test isRcloneRemote tells rclone remotes from file system paths
+func TestIsRcloneRemote(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected bool
+	}{
+		{"gdrive:magnet/list.json", true},
+		{"my-dropbox:list.json", true},
+		{"onedrive:", true},
+		{"/mnt/nas/list.json", false},
+		{"list.json", false},
+		{"./gdrive:list.json", false},
+		{`\\server\share\list.json`, false},
+		{`W:\magnet-list-network.json`, runtime.GOOS != "windows"},
+	}
+
+	for _, tt := range tests {
+		if got := isRcloneRemote(tt.path); got != tt.expected {
+			t.Errorf("isRcloneRemote(%q) = %v, want %v", tt.path, got, tt.expected)
+		}
+	}
+}
+
+// fakeRclone installs a stand-in rclone whose "fake:" remote is a directory
+func fakeRclone(t *testing.T, tmpDir string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("Fake rclone is a shell script")
+	}
+	remoteDir := filepath.Join(tmpDir, "remote")
+	script := `#!/bin/sh
+[ "$1" = copyto ] || exit 2
+map() { case "$1" in fake:*) echo "` + remoteDir + `/${1#fake:}";; *) echo "$1";; esac; }
+src=$(map "$2"); dst=$(map "$3")
+[ -f "$src" ] || { echo "ERROR : object not found" >&2; exit 3; }
+mkdir -p "$(dirname "$dst")" && cp "$src" "$dst"
+`
+	binary := filepath.Join(tmpDir, "rclone")
+	if err := os.WriteFile(binary, []byte(script), 0755); err != nil {
+		t.Fatalf("Failed to write fake rclone: %v", err)
+	}
+	original := rcloneBinary
+	rcloneBinary = binary
+	t.Cleanup(func() { rcloneBinary = original })
+	return remoteDir
+}
+
+// Test the database syncs through an rclone remote
+func TestRcloneRemoteSync(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	t.Setenv("HOME", tmpDir)
+	remoteDir := fakeRclone(t, tmpDir)
+
+	config := DefaultConfig()
+	config.JSONPath = filepath.Join(tmpDir, "local.json")
+	config.RemotePath = "fake:shared/list.json"
+
+	// First save uploads even though the remote does not exist yet
+	update := &MagnetDatabase{
+		Added: map[string]MagnetEntry{"hash1": {UUID: "uuid-1", Hash: "hash1", Title: "Local Book"}},
+		Retry: map[string]MagnetEntry{},
+	}
+	if err := SaveJSONDatabase(config.JSONPath, update, &config); err != nil {
+		t.Fatalf("SaveJSONDatabase failed: %v", err)
+	}
+	remoteFile := filepath.Join(remoteDir, "shared", "list.json")
+	remote, err := LoadJSONDatabase(remoteFile)
+	if err != nil {
+		t.Fatalf("Remote should have been uploaded: %v", err)
+	}
+	if _, ok := remote.Added["hash1"]; !ok {
+		t.Error("Uploaded remote is missing the local entry")
+	}
+
+	// Another machine adds an entry to the remote
+	remote.Added["hash2"] = MagnetEntry{UUID: "uuid-2", Hash: "hash2", Title: "Remote Book", ID: 2}
+	if err := SaveDatabaseLocal(remoteFile, remote); err != nil {
+		t.Fatalf("Failed to update remote: %v", err)
+	}
+
+	merged, err := SyncWithRemote(config.JSONPath, config.RemotePath)
+	if err != nil {
+		t.Fatalf("SyncWithRemote failed: %v", err)
+	}
+	if _, ok := merged.Added["hash2"]; !ok {
+		t.Error("Entry added on the remote should be merged")
+	}
+}
+
+// Test an unreachable rclone remote falls back to the local database
+func TestRcloneRemoteUnavailable(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	t.Setenv("HOME", tmpDir)
+	fakeRclone(t, tmpDir)
+
+	localPath := filepath.Join(tmpDir, "local.json")
+	local := &MagnetDatabase{
+		Added: map[string]MagnetEntry{"hash1": {UUID: "uuid-1", Hash: "hash1", Title: "Local Book"}},
+		Retry: map[string]MagnetEntry{},
+	}
+	if err := SaveDatabaseLocal(localPath, local); err != nil {
+		t.Fatalf("Failed to save local: %v", err)
+	}
+
+	merged, err := SyncWithRemote(localPath, "fake:missing/list.json")
+	if err != nil {
+		t.Fatalf("SyncWithRemote failed: %v", err)
+	}
+	if len(merged.Added) != 1 {
+		t.Errorf("Expected local database, got %d entries", len(merged.Added))
+	}
+}
@@ -3,10 +3,12 @@
 package main
 
 import (
+	"bufio"
 	"fmt"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 )
 
 // RegisterProtocolHandler registers the magnet protocol handler on Unix systems
@@ -20,7 +22,7 @@ func RegisterProtocolHandler(exePath string) error {
 		return err
 	}
 
-	configPath := filepath.Join(homeDir, ".magnet-handler.conf")
+	configPath := configFilePath(homeDir)
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
 		if err := SaveConfig(config); err != nil {
 			return err
@@ -74,6 +76,7 @@ Categories=Network;
 	fmt.Println("  xdg-mime default magnet-handler.desktop x-scheme-handler/magnet")
 	fmt.Println("\n✓ Magnet protocol handler registered for Linux!")
 	fmt.Println("You can now click magnet links in your browser and they will be added to Deluge")
+	printAlternateDelivery(exePath)
 
 	return nil
 }
@@ -197,8 +200,11 @@ exec "%s" "$1"
 	fmt.Println("  2. When prompted, select 'Magnet Handler' to open it")
 	fmt.Println("  3. Check 'Always open these types of links' to remember your choice")
 	fmt.Println("")
-	fmt.Println("Logs are saved to: ~/.cache/magnet-handler/")
-	fmt.Println("Config file: ~/.magnet-handler.conf")
+	fmt.Printf("Logs are saved to: %s\n", GetDefaultLogDir())
+	if homeDir, err := getHomeDir(); err == nil {
+		fmt.Printf("Config file: %s\n", configFilePath(homeDir))
+	}
+	printAlternateDelivery(exePath)
 
 	return nil
 }
@@ -242,11 +248,58 @@ func unregisterMacOS() error {
 	return nil
 }
 
-// GetDefaultLogDir returns the default log directory for Unix systems
+// RegisteredHandlerCommand returns the command line the OS runs for magnet links
+func RegisteredHandlerCommand() (string, error) {
+	if runtime.GOOS == "linux" {
+		return registeredLinuxCommand()
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	launchPath := filepath.Join(homeDir, "Applications", "Magnet Handler.app", "Contents", "MacOS", "launch")
+	if _, err := os.Stat(launchPath); err != nil {
+		return "", fmt.Errorf("handler is not registered (%w)", err)
+	}
+	return fmt.Sprintf(`"%s" "$1"`, launchPath), nil
+}
+
+// registeredLinuxCommand reads the Exec line from the installed desktop entry
+func registeredLinuxCommand() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	desktopPath := filepath.Join(homeDir, ".local", "share", "applications", "magnet-handler.desktop")
+	f, err := os.Open(desktopPath)
+	if err != nil {
+		return "", fmt.Errorf("handler is not registered (%w)", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if command, ok := strings.CutPrefix(scanner.Text(), "Exec="); ok {
+			return command, nil
+		}
+	}
+	return "", fmt.Errorf("no Exec line in %s", desktopPath)
+}
+
+// GetDefaultLogDir returns the default log directory for Unix systems:
+// $XDG_CACHE_HOME/magnet-handler when XDG_CACHE_HOME is set, otherwise
+// ~/.magnet-handler/logs (migrate-paths moves logs between them)
 func GetDefaultLogDir() string {
+	if logDir := xdgDir("XDG_CACHE_HOME", "magnet-handler"); logDir != "" {
+		if err := os.MkdirAll(logDir, 0755); err == nil {
+			return logDir
+		}
+	}
 	homeDir, err := os.UserHomeDir()
 	if err == nil {
-		logDir := filepath.Join(homeDir, ".magnet-handler", "logs")
+		logDir := legacyLogDir(homeDir)
 		if err := os.MkdirAll(logDir, 0755); err == nil {
 			return logDir
 		}
@@ -5,7 +5,6 @@ package main
 import (
 	"fmt"
 	"os"
-	"path/filepath"
 
 	"golang.org/x/sys/windows/registry"
 )
@@ -19,7 +18,7 @@ func RegisterProtocolHandler(exePath string) error {
 		return err
 	}
 
-	configPath := filepath.Join(homeDir, ".magnet-handler.conf")
+	configPath := configFilePath(homeDir)
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
 		if err := SaveConfig(config); err != nil {
 			return err
@@ -66,9 +65,25 @@ func RegisterProtocolHandler(exePath string) error {
 
 	fmt.Println("✓ Magnet protocol handler registered successfully!")
 	fmt.Println("You can now click magnet links in Chrome and they will be added to Deluge")
+	printAlternateDelivery(exePath)
 	return nil
 }
 
+// RegisteredHandlerCommand returns the command line Windows runs for magnet links
+func RegisteredHandlerCommand() (string, error) {
+	k, err := registry.OpenKey(registry.CLASSES_ROOT, `magnet\shell\open\command`, registry.QUERY_VALUE)
+	if err != nil {
+		return "", fmt.Errorf("handler is not registered (%w)", err)
+	}
+	defer k.Close()
+
+	command, _, err := k.GetStringValue("")
+	if err != nil {
+		return "", err
+	}
+	return command, nil
+}
+
 // UnregisterProtocolHandler removes the magnet protocol handler
 func UnregisterProtocolHandler() error {
 	if err := registry.DeleteKey(registry.CLASSES_ROOT, `magnet\shell\open\command`); err != nil {
@@ -97,7 +112,7 @@ func GetDefaultLogDir() string {
 	if err != nil {
 		return "."
 	}
-	logDir := filepath.Join(homeDir, ".magnet-handler", "logs")
+	logDir := legacyLogDir(homeDir)
 	if err := os.MkdirAll(logDir, 0755); err == nil {
 		return logDir
 	}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// RelabelChange is one torrent whose Deluge label differs from the label the
+// database records for it
+type RelabelChange struct {
+	Hash      string
+	TorrentID string
+	Title     string
+	From      string // Label in Deluge ("" = none)
+	To        string // Label in the database
+}
+
+// planRelabel compares the labels of the added entries still in Deluge with
+// Deluge's, returning the torrents to relabel sorted by title and how many
+// entries Deluge no longer has. With only set, just entries the database
+// labels only are considered.
+func planRelabel(torrents map[string]map[string]interface{}, db *MagnetDatabase, config Config, only string) ([]RelabelChange, int) {
+	labels := make(map[string]string, len(torrents))
+	ids := make(map[string]string, len(torrents))
+	for id, torrent := range torrents {
+		hash, _ := torrent["hash"].(string)
+		if hash == "" {
+			hash = id
+		}
+		hash = strings.ToLower(hash)
+		labels[hash], _ = torrent["label"].(string)
+		ids[hash] = id
+	}
+
+	var changes []RelabelChange
+	missing := 0
+	for hash, entry := range db.Added {
+		want := entryLabel(entry, config)
+		if want == "" || (only != "" && want != only) {
+			continue
+		}
+		id, ok := ids[hash]
+		if !ok {
+			missing++
+			continue
+		}
+		// The Label plugin stores labels in lower case
+		if strings.EqualFold(labels[hash], want) {
+			continue
+		}
+		changes = append(changes, RelabelChange{Hash: hash, TorrentID: id, Title: listColumns["title"](ListedEntry{MagnetEntry: entry}), From: labels[hash], To: want})
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Title != changes[j].Title {
+			return changes[i].Title < changes[j].Title
+		}
+		return changes[i].Hash < changes[j].Hash
+	})
+	return changes, missing
+}
+
+// relabelTorrents sets the database's label on every tracked torrent whose
+// Deluge label differs, returning the changes made (or that would be, with
+// dryRun)
+func relabelTorrents(client *DelugeClient, db *MagnetDatabase, config Config, only string, dryRun bool) ([]RelabelChange, error) {
+	torrents, err := client.GetAllTorrents()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get torrents: %w", err)
+	}
+	changes, missing := planRelabel(torrents, db, config, only)
+	if missing > 0 {
+		log.Printf("Skipping %s no longer in Deluge (sync removes them)", plural(missing, "entry", "entries"))
+	}
+
+	var applied []RelabelChange
+	failed := 0
+	for _, change := range changes {
+		from := change.From
+		if from == "" {
+			from = "(none)"
+		}
+		if dryRun {
+			log.Printf("Would relabel %s: %s -> %s", change.Title, from, change.To)
+			applied = append(applied, change)
+			continue
+		}
+		if err := client.SetTorrentLabel(change.TorrentID, change.To); err != nil {
+			log.Printf("✗ Failed to relabel %s: %v", change.Title, err)
+			failed++
+			continue
+		}
+		log.Printf("✓ Relabelled %s: %s -> %s", change.Title, from, change.To)
+		applied = append(applied, change)
+	}
+
+	if failed > 0 {
+		return applied, fmt.Errorf("%s could not be relabelled", plural(failed, "torrent", "torrents"))
+	}
+	return applied, nil
+}
+
+// runRelabel implements the relabel command
+func runRelabel(config Config, args []string) error {
+	fs := newCommandFlags(relabelCommand)
+	dryRun := fs.Bool("dry-run", false, "Show the torrents that would be relabelled without changing Deluge")
+	only := fs.String("label", "", "Only relabel torrents the database gives this label")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+
+	db, err := LoadJSONDatabase(config.JSONPath)
+	if err != nil {
+		return fmt.Errorf("failed to load database: %w", err)
+	}
+	client, err := connectDeluge(config)
+	if err != nil {
+		return err
+	}
+
+	changes, err := relabelTorrents(client, db, config, *only, *dryRun)
+	switch {
+	case *dryRun:
+		log.Printf("%s would be relabelled", plural(len(changes), "torrent", "torrents"))
+	case len(changes) == 0 && err == nil:
+		log.Println("✓ Deluge's labels already match the database")
+	default:
+		log.Printf("Relabelled %s", plural(len(changes), "torrent", "torrents"))
+	}
+	return err
+}
+
+var relabelCommand = &Command{
+	Name:    "relabel",
+	Usage:   "relabel [--label name] [--dry-run]",
+	Summary: "Set each tracked torrent's Deluge label to the one in the database",
+}
+
+func init() {
+	relabelCommand.Run = runRelabel
+	registerCommand(relabelCommand)
+}
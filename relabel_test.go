@@ -0,0 +1,66 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// Test relabel sets the database's label only on torrents in Deluge whose
+// label differs, ignoring case
+func TestRelabelTorrents(t *testing.T) {
+	torrents := map[string]interface{}{
+		"hash1": map[string]interface{}{"hash": "hash1", "label": "books"},
+		"hash2": map[string]interface{}{"hash": "hash2", "label": "Audiobooks"},
+		"hash3": map[string]interface{}{"hash": "hash3", "label": ""},
+		"hash5": map[string]interface{}{"hash": "hash5", "label": "tv"},
+	}
+	set := map[string]string{}
+	client := fakeDeluge(t, func(method string, params []interface{}) (interface{}, interface{}) {
+		switch method {
+		case "core.get_torrents_status":
+			return torrents, nil
+		case "core.get_enabled_plugins":
+			return []interface{}{"Label"}, nil
+		case "label.set_torrent":
+			set[params[0].(string)] = params[1].(string)
+		}
+		return nil, nil
+	})
+
+	db := &MagnetDatabase{
+		Added: map[string]MagnetEntry{
+			"hash1": {Hash: "hash1", Title: "Dune", Label: "audiobooks"},
+			"hash2": {Hash: "hash2", Title: "Emma", Label: "audiobooks"},
+			"hash3": {Hash: "hash3", Title: "Legacy"},
+			"hash4": {Hash: "hash4", Title: "Gone", Label: "audiobooks"},
+			"hash5": {Hash: "hash5", Title: "Lectures", Label: "courses"},
+		},
+		Retry: map[string]MagnetEntry{},
+	}
+	config := Config{DelugeLabel: "podcasts"}
+
+	changes, err := relabelTorrents(client, db, config, "", true)
+	if err != nil || len(changes) != 3 || len(set) != 0 {
+		t.Fatalf("Expected 3 planned changes and no calls, got %+v, %v, %v", changes, set, err)
+	}
+	if changes[0].Title != "Dune" || changes[0].From != "books" || changes[1].To != "courses" || changes[2].To != "podcasts" {
+		t.Errorf("Unexpected plan: %+v", changes)
+	}
+
+	if _, err := relabelTorrents(client, db, config, "courses", false); err != nil {
+		t.Fatalf("relabel failed: %v", err)
+	}
+	if len(set) != 1 || set["hash5"] != "courses" {
+		t.Errorf("--label should limit relabelling to courses, got %v", set)
+	}
+
+	failing := fakeDeluge(t, func(method string, params []interface{}) (interface{}, interface{}) {
+		if method == "core.get_torrents_status" {
+			return torrents, nil
+		}
+		return nil, map[string]interface{}{"message": "plugin disabled"}
+	})
+	if _, err := relabelTorrents(failing, db, config, "", false); err == nil || !strings.Contains(err.Error(), "3 torrents") {
+		t.Errorf("Expected the failed relabels to be reported, got %v", err)
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxRemoteSaveAttempts bounds how often a save is merged and retried when
+// another machine writes the remote database at the same time
+const maxRemoteSaveAttempts = 3
+
+// errRemoteChanged means the remote database was modified after it was
+// fetched, so uploading would overwrite another machine's changes
+var errRemoteChanged = errors.New("remote database changed since it was fetched")
+
+// remoteStore moves the remote database between its remote location and a
+// local cache file. Plain file system paths need no store.
+type remoteStore interface {
+	Fetch(cache string) error  // Download to cache; a missing remote removes cache
+	Upload(cache string) error // Upload cache, or errRemoteChanged if the remote moved on
+}
+
+// remoteStoreFor returns the store for a remote path, or nil for file
+// system paths
+func remoteStoreFor(remotePath string) remoteStore {
+	switch {
+	case isWebDAVRemote(remotePath):
+		return newWebDAVStore(remotePath)
+	case isSFTPRemote(remotePath):
+		return sftpStore{remote: remotePath}
+	case isRcloneRemote(remotePath):
+		return rcloneStore{remote: remotePath}
+	}
+	return nil
+}
+
+// displayRemote returns the remote path with any password removed, for logs
+func displayRemote(remotePath string) string {
+	if !strings.Contains(remotePath, "://") {
+		return remotePath
+	}
+	u, err := url.Parse(remotePath)
+	if err != nil {
+		return remotePath
+	}
+	return u.Redacted()
+}
+
+// remoteCachePath returns where the local copy of a remote database is kept
+func remoteCachePath(remote string) (string, error) {
+	homeDir, err := getHomeDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(remote))
+	return filepath.Join(homeDir, ".magnet-handler", "remote-cache", fmt.Sprintf("%x.json", sum[:8])), nil
+}
+
+// fetchRemote returns a local path holding the current remote database. For
+// remotes reached through a store the file is downloaded to a cache first;
+// if that fails the cache is cleared so the remote reads as inaccessible.
+func fetchRemote(remotePath string) string {
+	delayRemote(remotePath)
+	store := remoteStoreFor(remotePath)
+	if store == nil {
+		return remotePath
+	}
+	cache, err := remoteCachePath(remotePath)
+	if err != nil {
+		log.Printf("Warning: No cache location for %s: %v", displayRemote(remotePath), err)
+		return remotePath
+	}
+	if err := os.MkdirAll(filepath.Dir(cache), 0700); err != nil {
+		log.Printf("Warning: Failed to create remote cache: %v", err)
+	}
+	if err := store.Fetch(cache); err != nil {
+		log.Printf("Warning: Could not fetch %s: %v", displayRemote(remotePath), err)
+		os.Remove(cache)
+	}
+	return cache
+}
+
+// remoteReadPath returns the local path of the remote database as last
+// fetched, without contacting the remote again
+func remoteReadPath(remotePath string) string {
+	if remoteStoreFor(remotePath) == nil {
+		return remotePath
+	}
+	if cache, err := remoteCachePath(remotePath); err == nil {
+		return cache
+	}
+	return remotePath
+}
+
+// saveRemoteDatabase writes the database to the remote path. File system
+// paths are written under a lock file. Stores that detect concurrent writers
+// get the newer remote merged in and the upload retried, so neither
+// machine's changes are lost.
+func saveRemoteDatabase(remotePath string, db *MagnetDatabase) error {
+	if err := injectRemoteWriteFault(remotePath, db); err != nil {
+		return err
+	}
+	store := remoteStoreFor(remotePath)
+	if store == nil {
+		// Other machines may write the same share; take turns so their
+		// temp-file writes and renames never interleave
+		release, err := acquireFileLock(remotePath, remoteLockTimeout)
+		if err != nil {
+			return err
+		}
+		defer release()
+		return SaveDatabaseLocal(remotePath, db)
+	}
+	cache, err := remoteCachePath(remotePath)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(cache), 0700); err != nil {
+		return err
+	}
+
+	for attempt := 1; ; attempt++ {
+		if err := SaveDatabaseLocal(cache, db); err != nil {
+			return err
+		}
+		err := store.Upload(cache)
+		if !errors.Is(err, errRemoteChanged) || attempt == maxRemoteSaveAttempts {
+			return err
+		}
+
+		log.Printf("Remote changed while saving, merging and retrying...")
+		if err := store.Fetch(cache); err != nil {
+			return fmt.Errorf("failed to refetch remote: %w", err)
+		}
+		remote, err := LoadJSONDatabase(cache)
+		if err != nil {
+			return fmt.Errorf("failed to load refetched remote: %w", err)
+		}
+		if err := VerifyDatabase(remote); err != nil {
+			notifyIntegrityFailure(remotePath, err)
+			return err
+		}
+		merged, conflicts := MergeDatabasesWithConflicts(db, remote)
+		notifyConflicts(conflicts)
+		db = merged
+	}
+}
+
+// migrateRemoteDatabase migrates the remote database in place, going through
+// the local cache for remotes reached through a store
+func migrateRemoteDatabase(remotePath string) error {
+	store := remoteStoreFor(remotePath)
+	if store == nil {
+		return MigrateFileFormat(remotePath)
+	}
+	cache := fetchRemote(remotePath)
+	if _, err := os.Stat(cache); err != nil {
+		return fmt.Errorf("could not fetch %s", displayRemote(remotePath))
+	}
+	if err := MigrateFileFormat(cache); err != nil {
+		return err
+	}
+	return store.Upload(cache)
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+)
+
+// RemoteState is what the last syncs with one remote did
+type RemoteState struct {
+	LastSync    string `json:"last_sync,omitempty"`     // Last successful sync
+	LastError   string `json:"last_error,omitempty"`    // Why the last failed sync failed
+	LastErrorAt string `json:"last_error_at,omitempty"` // When it failed
+	Failures    int    `json:"failures,omitempty"`      // Consecutive failed syncs
+}
+
+// GetRemotePaths returns every remote to keep in sync: remote_path (or the
+// platform default) first, then remote_paths, without duplicates
+func GetRemotePaths(config *Config) []string {
+	var remotes []string
+	seen := make(map[string]bool)
+	candidates := []string{GetRemotePath(config)}
+	if config != nil {
+		candidates = append(candidates, config.RemotePaths...)
+	}
+	for _, remote := range candidates {
+		if remote != "" && !seen[remote] {
+			seen[remote] = true
+			remotes = append(remotes, remote)
+		}
+	}
+	return remotes
+}
+
+// remoteStatePath returns where per-remote sync state is kept
+func remoteStatePath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, ".magnet-handler", "remote-state.json"), nil
+}
+
+// loadRemoteStates returns the recorded state of each remote, keyed by its
+// display form so credentials in URLs are never written
+func loadRemoteStates() map[string]RemoteState {
+	states := make(map[string]RemoteState)
+	path, err := remoteStatePath()
+	if err != nil {
+		return states
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return states
+	}
+	if err := json.Unmarshal(data, &states); err != nil {
+		log.Printf("Warning: Ignoring unreadable remote state: %v", err)
+		return make(map[string]RemoteState)
+	}
+	return states
+}
+
+// recordRemoteSync updates a remote's state after syncing with it
+func recordRemoteSync(remotePath string, syncErr error) {
+	path, err := remoteStatePath()
+	if err != nil {
+		return
+	}
+	states := loadRemoteStates()
+	key := displayRemote(remotePath)
+	state := states[key]
+	now := time.Now().Format(time.RFC3339)
+	if syncErr != nil {
+		state.LastError = syncErr.Error()
+		state.LastErrorAt = now
+		state.Failures++
+	} else {
+		state.LastSync = now
+		state.Failures = 0
+	}
+	states[key] = state
+
+	data, err := json.MarshalIndent(states, "", "  ")
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		log.Printf("Warning: Could not save remote state: %v", err)
+		return
+	}
+	os.Rename(tmp, path)
+}
+
+// mergeRemoteInto merges another remote's copy into db. A remote that cannot
+// be read or fails its integrity check is skipped.
+func mergeRemoteInto(db *MagnetDatabase, remotePath string) *MagnetDatabase {
+	remote, err := LoadJSONDatabase(fetchRemote(remotePath))
+	if err != nil {
+		log.Printf("Remote %s not accessible, skipping it", displayRemote(remotePath))
+		return db
+	}
+	if err := VerifyDatabase(remote); err != nil {
+		notifyIntegrityFailure(remotePath, err)
+		return db
+	}
+	merged, conflicts := MergeDatabasesWithConflicts(db, remote)
+	notifyConflicts(conflicts)
+	log.Printf("Merged %s: %d added, %d retry", displayRemote(remotePath), len(merged.Added), len(merged.Retry))
+	return merged
+}
+
+// syncWithRemotes syncs the local database with the first remote, then
+// merges in each of the others
+func syncWithRemotes(localPath string, remotes []string) (*MagnetDatabase, error) {
+	var primary string
+	if len(remotes) > 0 {
+		primary = remotes[0]
+	}
+	merged, err := SyncWithRemote(localPath, primary)
+	if err != nil {
+		return nil, err
+	}
+	for _, remote := range remotes[min(1, len(remotes)):] {
+		merged = mergeRemoteInto(merged, remote)
+	}
+	return merged, nil
+}
+
+// saveRemotes writes the database to every remote (best effort), recording
+// each outcome, and returns the failures
+func saveRemotes(remotes []string, db *MagnetDatabase) error {
+	var errs []error
+	for _, remote := range remotes {
+		err := saveRemoteDatabase(remote, db)
+		recordRemoteSync(remote, err)
+		if err != nil {
+			countRun("remote_failed", 1)
+			log.Printf("Warning: Could not sync to remote %s: %v", displayRemote(remote), err)
+			errs = append(errs, fmt.Errorf("failed to write remote %s: %w", displayRemote(remote), err))
+			continue
+		}
+		log.Printf("Synced to remote: %s", displayRemote(remote))
+	}
+	if len(errs) > 0 {
+		log.Printf("Changes saved locally, will sync on next operation")
+	}
+	return errors.Join(errs...)
+}
+
+// runRemotes implements the remotes command
+func runRemotes(config Config, args []string) error {
+	fs := newCommandFlags(remotesCommand)
+	asJSON := fs.Bool("json", false, "Print each remote's state as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	remotes := GetRemotePaths(&config)
+	if len(remotes) == 0 {
+		fmt.Println("No remotes configured (set remote_path, and remote_paths for more)")
+		return nil
+	}
+	states := loadRemoteStates()
+
+	if *asJSON {
+		type remoteJSON struct {
+			Remote string `json:"remote"`
+			RemoteState
+		}
+		result := make([]remoteJSON, 0, len(remotes))
+		for _, remote := range remotes {
+			result = append(result, remoteJSON{displayRemote(remote), states[displayRemote(remote)]})
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(result)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "REMOTE\tLAST SYNC\tFAILURES\tLAST ERROR")
+	for _, remote := range remotes {
+		state := states[displayRemote(remote)]
+		lastSync := state.LastSync
+		if lastSync == "" {
+			lastSync = "never"
+		}
+		lastError := "-"
+		if state.LastError != "" {
+			lastError = state.LastErrorAt + " " + state.LastError
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\n", displayRemote(remote), lastSync, state.Failures, lastError)
+	}
+	return tw.Flush()
+}
+
+var remotesCommand = &Command{
+	Name:    "remotes",
+	Usage:   "remotes [--json]",
+	Summary: "List the configured remotes with when each last synced and why it last failed",
+}
+
+func init() {
+	remotesCommand.Run = runRemotes
+	registerCommand(remotesCommand)
+}
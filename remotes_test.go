@@ -0,0 +1,64 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Test GetRemotePaths puts remote_path first and drops duplicates
+func TestGetRemotePaths(t *testing.T) {
+	config := &Config{RemotePath: "/mnt/nas/list.json", RemotePaths: []string{"sftp://seedbox/list.json", "", "/mnt/nas/list.json"}}
+	got := GetRemotePaths(config)
+	if len(got) != 2 || got[0] != "/mnt/nas/list.json" || got[1] != "sftp://seedbox/list.json" {
+		t.Errorf("GetRemotePaths = %v", got)
+	}
+}
+
+// Test SaveJSONDatabase merges and writes every remote and records each outcome
+func TestSaveJSONDatabaseMultipleRemotes(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	t.Setenv("HOME", tmpDir)
+
+	nas := filepath.Join(tmpDir, "nas.json")
+	seedbox := filepath.Join(tmpDir, "seedbox.json")
+	offline := filepath.Join(tmpDir, "offline", "list.json")
+	config := Config{JSONPath: filepath.Join(tmpDir, "local.json"), RemotePath: nas, RemotePaths: []string{seedbox, offline}}
+
+	seedboxDB := &MagnetDatabase{
+		Added: map[string]MagnetEntry{"hash2": {ID: 1, Hash: "hash2", Title: "From Seedbox"}},
+		Retry: map[string]MagnetEntry{},
+	}
+	if err := SaveDatabaseLocal(seedbox, seedboxDB); err != nil {
+		t.Fatalf("Failed to save seedbox copy: %v", err)
+	}
+
+	if err := SaveJSONDatabase(config.JSONPath, entryUpdate(SectionAdded, "hash1", MagnetEntry{Hash: "hash1", Title: "New"}), &config); err != nil {
+		t.Fatalf("SaveJSONDatabase failed: %v", err)
+	}
+
+	for _, path := range []string{config.JSONPath, nas, seedbox} {
+		db, err := LoadJSONDatabase(path)
+		if err != nil {
+			t.Fatalf("Failed to load %s: %v", filepath.Base(path), err)
+		}
+		if _, ok := db.Added["hash1"]; !ok {
+			t.Errorf("Expected hash1 in %s", filepath.Base(path))
+		}
+		if _, ok := db.Added["hash2"]; !ok {
+			t.Errorf("Expected hash2 from the seedbox in %s", filepath.Base(path))
+		}
+	}
+
+	states := loadRemoteStates()
+	if states[nas].LastSync == "" || states[seedbox].LastSync == "" {
+		t.Errorf("Expected successful syncs recorded, got %+v", states)
+	}
+	if offlineState := states[offline]; offlineState.Failures != 1 || offlineState.LastError == "" {
+		t.Errorf("Expected a failure recorded for the offline remote, got %+v", offlineState)
+	}
+}
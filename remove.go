@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// runRemove implements the remove command
+func runRemove(config Config, args []string) error {
+	fs := newCommandFlags(removeCommand)
+	withData := fs.Bool("with-data", false, "Also delete downloaded data")
+	dbOnly := fs.Bool("db-only", false, "Only update the database, don't contact Deluge")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected exactly one hash or UUID")
+	}
+
+	db, err := LoadJSONDatabase(config.JSONPath)
+	if err != nil {
+		return fmt.Errorf("failed to load database: %w", err)
+	}
+
+	hash, section, entry, ok := findEntry(db, fs.Arg(0))
+	if !ok {
+		return fmt.Errorf("no entry found for %q", fs.Arg(0))
+	}
+	if section == SectionRemoved {
+		log.Printf("Already removed on %s: %s", entry.RemovedAt, entry.Title)
+		return nil
+	}
+
+	if !*dbOnly {
+		client := NewDelugeClient(config.DelugeHost, config.DelugePort, config.DelugePassword)
+		if err := client.Authenticate(); err != nil {
+			return fmt.Errorf("authentication failed: %w", err)
+		}
+		if err := client.Connect(); err != nil {
+			return fmt.Errorf("connection failed: %w", err)
+		}
+		if err := client.RemoveTorrent(hash, *withData); err != nil {
+			return fmt.Errorf("failed to remove torrent: %w", err)
+		}
+		if *withData {
+			log.Printf("✓ Removed from Deluge with data: %s", entry.Title)
+		} else {
+			log.Printf("✓ Removed from Deluge: %s", entry.Title)
+		}
+	}
+
+	entry.RemovedAt = time.Now().Format(time.RFC3339)
+	entry.recordHistory(HistoryRemoved, "", "")
+	dbUpdate := &MagnetDatabase{
+		Added:   make(map[string]MagnetEntry),
+		Retry:   make(map[string]MagnetEntry),
+		Removed: map[string]MagnetEntry{hash: entry},
+	}
+	if err := SaveJSONDatabase(config.JSONPath, dbUpdate, &config); err != nil {
+		return fmt.Errorf("failed to save database: %w", err)
+	}
+
+	log.Printf("Moved %s entry to removed: %s", section, entry.Title)
+	return nil
+}
+
+var removeCommand = &Command{
+	Name:    "remove",
+	Usage:   "remove [--with-data] [--db-only] <hash|uuid>",
+	Summary: "Remove a torrent from Deluge and move its entry to the removed section",
+}
+
+func init() {
+	removeCommand.Run = runRemove
+	registerCommand(removeCommand)
+}
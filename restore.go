@@ -0,0 +1,302 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// restoreItem is one torrent to re-add during a disaster-recovery restore
+type restoreItem struct {
+	Hash     string
+	Name     string
+	Label    string
+	SavePath string
+	URI      string
+	Entry    *MagnetEntry // Set when the database does not track it yet
+}
+
+// restoreCheckpoint records which torrents a restore has already re-added,
+// so an interrupted run picks up where it stopped
+type restoreCheckpoint struct {
+	Source   string            `json:"source"`   // Snapshot path, or "database"
+	Restored map[string]string `json:"restored"` // Hash to when it was re-added
+}
+
+// restoreFromDatabase is the checkpoint source for restores of the database
+const restoreFromDatabase = "database"
+
+// restoreCheckpointPath returns where restore progress is kept
+func restoreCheckpointPath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, ".magnet-handler", "restore-checkpoint.json"), nil
+}
+
+// loadRestoreCheckpoint returns the progress of an earlier restore from the
+// same source, or an empty checkpoint
+func loadRestoreCheckpoint(path, source string) *restoreCheckpoint {
+	fresh := &restoreCheckpoint{Source: source, Restored: make(map[string]string)}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fresh
+	}
+	var checkpoint restoreCheckpoint
+	if err := json.Unmarshal(data, &checkpoint); err != nil || checkpoint.Source != source {
+		return fresh
+	}
+	if checkpoint.Restored == nil {
+		checkpoint.Restored = make(map[string]string)
+	}
+	return &checkpoint
+}
+
+// save writes the checkpoint atomically
+func (c *restoreCheckpoint) save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// snapshotItems lists a snapshot's torrents, with entries for those the
+// database does not track
+func snapshotItems(snapshot *SessionSnapshot, db *MagnetDatabase) []restoreItem {
+	items := make([]restoreItem, 0, len(snapshot.Torrents))
+	for _, t := range snapshot.Torrents {
+		item := restoreItem{
+			Hash:     t.Hash,
+			Name:     t.Name,
+			Label:    t.Label,
+			SavePath: t.SavePath,
+			URI:      rebuildMagnetURI(t.Hash, t.Name, t.Trackers),
+		}
+		_, added := db.Added[t.Hash]
+		_, retry := db.Retry[t.Hash]
+		if !added && !retry {
+			entry := t.entry()
+			item.Entry = &entry
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// databaseItems lists the torrents in the database's added section, sorted
+// by ID so they return in the order they were first added
+func databaseItems(db *MagnetDatabase, config Config) []restoreItem {
+	entries := make([]MagnetEntry, 0, len(db.Added))
+	for _, entry := range db.Added {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ID < entries[j].ID })
+
+	items := make([]restoreItem, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.TorrentName
+		if name == "" {
+			name = entry.Title
+		}
+		uri := entry.URI
+		if uri == "" {
+			uri = rebuildMagnetURI(entry.Hash, name, nil)
+		}
+		items = append(items, restoreItem{
+			Hash:     entry.Hash,
+			Name:     name,
+			Label:    entryLabel(entry, config),
+			SavePath: entry.SavePath,
+			URI:      uri,
+		})
+	}
+	return items
+}
+
+// pendingRestores returns the items neither in Deluge nor restored by an
+// earlier run
+func pendingRestores(items []restoreItem, inDeluge map[string]bool, checkpoint *restoreCheckpoint) []restoreItem {
+	var pending []restoreItem
+	for _, item := range items {
+		if checkpoint.Restored[item.Hash] == "" && !inDeluge[item.Hash] {
+			pending = append(pending, item)
+		}
+	}
+	return pending
+}
+
+// restoreItems adds the pending items, one every interval, saving the
+// checkpoint after each so an interrupted restore can resume. It returns
+// how many were added and how many failed.
+func restoreItems(pending []restoreItem, checkpoint *restoreCheckpoint, checkpointPath string,
+	interval time.Duration, add func(restoreItem) error) (restored, failed int) {
+	started := time.Now()
+	for i, item := range pending {
+		if i > 0 {
+			time.Sleep(interval)
+		}
+		progress := fmt.Sprintf("[%d/%d]", i+1, len(pending))
+		if err := add(item); err != nil {
+			log.Printf("%s ✗ %s: %v", progress, item.Name, err)
+			failed++
+			continue
+		}
+		restored++
+		checkpoint.Restored[item.Hash] = time.Now().Format(time.RFC3339)
+		if err := checkpoint.save(checkpointPath); err != nil {
+			log.Printf("Warning: Could not save restore checkpoint: %v", err)
+		}
+
+		remaining := time.Duration(0)
+		if left := len(pending) - i - 1; left > 0 {
+			remaining = time.Since(started) / time.Duration(i+1) * time.Duration(left)
+		}
+		log.Printf("%s ✓ %s (about %s left)", progress, item.Name, remaining.Round(time.Second))
+	}
+	return restored, failed
+}
+
+// runRestore implements the restore command
+func runRestore(config Config, args []string) error {
+	fs := newCommandFlags(restoreCommand)
+	from := fs.String("from", "", "Session snapshot to restore (default: the database's added entries)")
+	start := fs.Bool("start", false, "Start torrents right away instead of adding them paused")
+	interval := fs.Duration("interval", 2*time.Second, "Wait between adds, to spare a freshly installed Deluge")
+	fresh := fs.Bool("restart", false, "Ignore the checkpoint from an interrupted restore and start over")
+	dryRun := fs.Bool("dry-run", false, "List what would be restored without changing anything")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	db, err := LoadJSONDatabase(config.JSONPath)
+	if err != nil {
+		if *from == "" {
+			return fmt.Errorf("failed to load database: %w", err)
+		}
+		db = &MagnetDatabase{Added: make(map[string]MagnetEntry), Retry: make(map[string]MagnetEntry)}
+	}
+
+	source := restoreFromDatabase
+	var items []restoreItem
+	if *from != "" {
+		snapshot, err := loadSnapshot(*from)
+		if err != nil {
+			return err
+		}
+		if source, err = filepath.Abs(*from); err != nil {
+			return err
+		}
+		log.Printf("Restoring snapshot of %s taken %s", snapshot.Source, snapshot.Created)
+		items = snapshotItems(snapshot, db)
+	} else {
+		log.Printf("Restoring the database's added entries from %s", config.JSONPath)
+		items = databaseItems(db, config)
+	}
+
+	checkpointPath, err := restoreCheckpointPath()
+	if err != nil {
+		return err
+	}
+	checkpoint := loadRestoreCheckpoint(checkpointPath, source)
+	if *fresh {
+		checkpoint.Restored = make(map[string]string)
+	}
+	if n := len(checkpoint.Restored); n > 0 {
+		log.Printf("Resuming: %s restored by an earlier run", plural(n, "torrent", "torrents"))
+	}
+
+	client, err := connectDeluge(config)
+	if err != nil {
+		return err
+	}
+	torrents, err := client.GetAllTorrents()
+	if err != nil {
+		return fmt.Errorf("failed to get torrents: %w", err)
+	}
+	inDeluge := make(map[string]bool, len(torrents))
+	for id, torrent := range torrents {
+		hash, _ := torrent["hash"].(string)
+		if hash == "" {
+			hash = id
+		}
+		inDeluge[strings.ToLower(hash)] = true
+	}
+
+	pending := pendingRestores(items, inDeluge, checkpoint)
+	if skipped := len(items) - len(pending); skipped > 0 {
+		log.Printf("Skipping %s already in Deluge or restored by an earlier run", plural(skipped, "torrent", "torrents"))
+	}
+	if *dryRun {
+		for _, item := range pending {
+			log.Printf("  Would add %s → %s", item.Name, item.SavePath)
+		}
+		log.Printf("Dry run: %s would be re-added", plural(len(pending), "torrent", "torrents"))
+		return nil
+	}
+
+	add := func(item restoreItem) error {
+		opts := AddOptionsForLabel(config, item.Label)
+		opts.AddPaused = !*start
+		if item.SavePath != "" {
+			// Point at the old data so Deluge finds it instead of downloading again
+			opts.DownloadLocation = item.SavePath
+		}
+		_, err := client.AddMagnet(item.URI, item.Label, opts)
+		return err
+	}
+	restored, failed := restoreItems(pending, checkpoint, checkpointPath, *interval, add)
+
+	// Track snapshot torrents the database does not know about, whether
+	// re-added now or already present
+	update := &MagnetDatabase{
+		Added:   make(map[string]MagnetEntry),
+		Retry:   make(map[string]MagnetEntry),
+		Removed: make(map[string]MagnetEntry),
+	}
+	for _, item := range items {
+		if item.Entry != nil && (inDeluge[item.Hash] || checkpoint.Restored[item.Hash] != "") {
+			update.Added[item.Hash] = *item.Entry
+		}
+	}
+	if len(update.Added) > 0 {
+		if err := SaveJSONDatabase(config.JSONPath, update, &config); err != nil {
+			return fmt.Errorf("failed to save database: %w", err)
+		}
+		log.Printf("✓ Tracked %s in the database", plural(len(update.Added), "torrent", "torrents"))
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%s restored, %d failed; run restore again to retry them", plural(restored, "torrent", "torrents"), failed)
+	}
+	os.Remove(checkpointPath)
+	log.Printf("✓ Restore complete: %s re-added", plural(restored, "torrent", "torrents"))
+	if !*start && restored > 0 {
+		log.Println("  Torrents were added paused; recheck them in Deluge, then resume")
+	}
+	return nil
+}
+
+var restoreCommand = &Command{
+	Name:    "restore",
+	Usage:   "restore [--from snapshot.json] [--start] [--interval 2s] [--restart] [--dry-run]",
+	Summary: "Re-add every torrent from a snapshot or the database to a fresh Deluge, paused, resuming if interrupted",
+}
+
+func init() {
+	restoreCommand.Run = runRestore
+	registerCommand(restoreCommand)
+}
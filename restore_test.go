@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Test snapshotItems and databaseItems build what restore re-adds
+func TestRestoreSources(t *testing.T) {
+	db := &MagnetDatabase{
+		Added: map[string]MagnetEntry{
+			"bbb": {ID: 2, Hash: "bbb", Title: "Second", URI: "magnet:?xt=urn:btih:bbb&tr=udp%3A%2F%2Ft"},
+			"aaa": {ID: 1, Hash: "aaa", Title: "First", TorrentName: "First.Torrent", SavePath: "/data", Label: "podcasts"},
+		},
+		Retry: map[string]MagnetEntry{},
+	}
+
+	items := databaseItems(db, Config{DelugeLabel: "audiobooks"})
+	if len(items) != 2 || items[0].Hash != "aaa" || items[1].Hash != "bbb" {
+		t.Fatalf("Expected items in ID order, got %+v", items)
+	}
+	if items[0].Name != "First.Torrent" || items[0].Label != "podcasts" || items[0].SavePath != "/data" {
+		t.Errorf("Unexpected first item: %+v", items[0])
+	}
+	if items[1].Label != "audiobooks" || items[1].URI != db.Added["bbb"].URI {
+		t.Errorf("Unexpected second item: %+v", items[1])
+	}
+
+	snapshot := &SessionSnapshot{Torrents: []SessionTorrent{
+		{Hash: "aaa", Name: "First"},
+		{Hash: "ccc", Name: "New", Trackers: []string{"udp://t"}},
+	}}
+	items = snapshotItems(snapshot, db)
+	if items[0].Entry != nil {
+		t.Error("Expected no entry for a tracked torrent")
+	}
+	if items[1].Entry == nil || items[1].Entry.Hash != "ccc" || !strings.Contains(items[1].URI, "tr=udp") {
+		t.Errorf("Expected an entry and trackers for the untracked torrent, got %+v", items[1])
+	}
+}
+
+// Test restoreItems checkpoints each add so a rerun skips them
+func TestRestoreItemsCheckpoint(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	path := filepath.Join(tmpDir, "restore-checkpoint.json")
+	items := []restoreItem{{Hash: "aaa", Name: "A"}, {Hash: "bbb", Name: "B"}, {Hash: "ccc", Name: "C"}, {Hash: "ddd", Name: "D"}}
+	inDeluge := map[string]bool{"ddd": true}
+
+	checkpoint := loadRestoreCheckpoint(path, "/backups/session.json")
+	pending := pendingRestores(items, inDeluge, checkpoint)
+	if len(pending) != 3 {
+		t.Fatalf("Expected 3 pending, got %+v", pending)
+	}
+
+	var added []string
+	restored, failed := restoreItems(pending, checkpoint, path, 0, func(item restoreItem) error {
+		if item.Hash == "bbb" {
+			return errors.New("deluge busy")
+		}
+		added = append(added, item.Hash)
+		return nil
+	})
+	if restored != 2 || failed != 1 || strings.Join(added, ",") != "aaa,ccc" {
+		t.Errorf("Expected aaa and ccc restored and bbb failed, got %d/%d %v", restored, failed, added)
+	}
+
+	// A rerun from the same source only retries the failure
+	resumed := loadRestoreCheckpoint(path, "/backups/session.json")
+	pending = pendingRestores(items, inDeluge, resumed)
+	if len(pending) != 1 || pending[0].Hash != "bbb" {
+		t.Errorf("Expected only bbb pending after resume, got %+v", pending)
+	}
+
+	// A different source starts over
+	if other := loadRestoreCheckpoint(path, restoreFromDatabase); len(other.Restored) != 0 {
+		t.Errorf("Expected an empty checkpoint for another source, got %+v", other.Restored)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// SectionFailed lists retry entries that failed permanently or used up
+// max_retries; like dead entries they stay in the retry section of the
+// database, so machines running older versions still sync them, but are
+// never retried on their own
+const SectionFailed = "failed"
+
+// Retry backoff: the first retry waits retry_backoff minutes (default
+// defaultRetryBackoff), each later one twice as long up to maxRetryBackoff,
+// give or take retryJitter so a batch that failed together spreads out
+const (
+	defaultRetryBackoff = 5
+	maxRetryBackoff     = 24 * time.Hour
+	retryJitter         = 0.2
+)
+
+// retryDelay returns how long to wait after an entry's attempts-th failed
+// attempt. jitter in [0, 1) picks a point within ±retryJitter of the delay.
+func retryDelay(base time.Duration, attempts int, jitter float64) time.Duration {
+	if base <= 0 || attempts <= 0 {
+		return 0
+	}
+	delay := base
+	for i := 1; i < attempts && delay < maxRetryBackoff; i++ {
+		delay *= 2
+	}
+	delay = min(delay, maxRetryBackoff)
+	return time.Duration(float64(delay) * (1 - retryJitter + 2*retryJitter*jitter))
+}
+
+// retryBackoffBase returns the configured first-retry delay (0 = no backoff)
+func retryBackoffBase(config Config) time.Duration {
+	switch {
+	case config.RetryBackoff < 0:
+		return 0
+	case config.RetryBackoff == 0:
+		return defaultRetryBackoff * time.Minute
+	default:
+		return time.Duration(config.RetryBackoff) * time.Minute
+	}
+}
+
+// scheduleNextAttempt records when a failed entry is next due, or clears it
+// when backoff is off or the entry has given up
+func scheduleNextAttempt(entry *MagnetEntry, config Config, now time.Time) {
+	entry.NextAttempt = ""
+	if retriesExhausted(*entry, config) {
+		return
+	}
+	if delay := retryDelay(retryBackoffBase(config), entry.RetryCount, rand.Float64()); delay > 0 {
+		entry.NextAttempt = now.Add(delay).Format(time.RFC3339)
+	}
+}
+
+// retryDue reports whether a retry entry's backoff has elapsed
+func retryDue(entry MagnetEntry, now time.Time) bool {
+	next := parseTimestamp(entry.NextAttempt)
+	return next.IsZero() || !now.Before(next)
+}
+
+// retriesExhausted reports whether an entry has failed max_retries times
+func retriesExhausted(entry MagnetEntry, config Config) bool {
+	return config.MaxRetries > 0 && entry.RetryCount >= config.MaxRetries
+}
+
+// retryFailed reports whether a retry entry is in the failed section
+func retryFailed(entry MagnetEntry, config Config) bool {
+	return failedPermanently(entry) || retriesExhausted(entry, config)
+}
+
+// failedFilter returns the EntryQuery.Failed check for config
+func failedFilter(config Config) func(MagnetEntry) bool {
+	return func(entry MagnetEntry) bool {
+		return retryFailed(entry, config)
+	}
+}
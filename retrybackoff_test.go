@@ -0,0 +1,86 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// Test retry delays double per attempt, cap out, and stay within the jitter
+func TestRetryDelay(t *testing.T) {
+	base := 5 * time.Minute
+	tests := []struct {
+		attempts int
+		jitter   float64
+		want     time.Duration
+	}{
+		{0, 0.5, 0},
+		{1, 0.5, 5 * time.Minute},
+		{2, 0.5, 10 * time.Minute},
+		{4, 0.5, 40 * time.Minute},
+		{30, 0.5, maxRetryBackoff},
+		{1, 0, 4 * time.Minute},
+		{1, 0.999999, 6 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		got := retryDelay(base, tt.attempts, tt.jitter)
+		if diff := got - tt.want; diff < -time.Second || diff > time.Second {
+			t.Errorf("retryDelay(%s, %d, %v) = %s, want %s", base, tt.attempts, tt.jitter, got, tt.want)
+		}
+	}
+	if got := retryDelay(0, 3, 0.5); got != 0 {
+		t.Errorf("Disabled backoff should not delay, got %s", got)
+	}
+}
+
+// Test failed entries get a next attempt unless they have given up
+func TestScheduleNextAttempt(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	config := Config{RetryBackoff: 10, MaxRetries: 3}
+
+	entry := MagnetEntry{RetryCount: 2}
+	scheduleNextAttempt(&entry, config, now)
+	next := parseTimestamp(entry.NextAttempt)
+	if next.Before(now.Add(16*time.Minute)) || next.After(now.Add(24*time.Minute)) {
+		t.Errorf("Expected the next attempt about 20 minutes out, got %s", entry.NextAttempt)
+	}
+	if retryDue(entry, now) || !retryDue(entry, now.Add(time.Hour)) {
+		t.Error("Entry should only be due once its backoff has elapsed")
+	}
+
+	entry.RetryCount = 3
+	scheduleNextAttempt(&entry, config, now)
+	if entry.NextAttempt != "" || !retriesExhausted(entry, config) {
+		t.Errorf("Entry at max_retries should give up, got next attempt %q", entry.NextAttempt)
+	}
+
+	entry = MagnetEntry{RetryCount: 2}
+	scheduleNextAttempt(&entry, Config{RetryBackoff: -1}, now)
+	if entry.NextAttempt != "" {
+		t.Error("Negative retry_backoff should retry every run")
+	}
+}
+
+// Test QueryEntries lists retry entries past max_retries as failed
+func TestQueryEntriesFailed(t *testing.T) {
+	db := buildListDatabase(3)
+	for hash, entry := range db.Retry {
+		if entry.Title == "Retry Book 2" {
+			entry.RetryCount = 5
+			db.Retry[hash] = entry
+		}
+	}
+	config := Config{MaxRetries: 5}
+	dead := func(entry MagnetEntry) bool { return entry.Title == "Retry Book 2" }
+
+	page := QueryEntries(db, EntryQuery{Section: SectionFailed, Dead: dead, Failed: failedFilter(config)})
+	if page.Total != 1 || page.Entries[0].Title != "Retry Book 2" || page.Entries[0].Section != SectionFailed {
+		t.Errorf("Expected only Retry Book 2 as failed, got %+v", page.Entries)
+	}
+	if retry := QueryEntries(db, EntryQuery{Section: SectionRetry, Failed: failedFilter(config)}); retry.Total != 2 {
+		t.Errorf("Expected 2 active retry entries, got %d", retry.Total)
+	}
+	if failedFilter(Config{})(MagnetEntry{RetryCount: 5}) {
+		t.Error("Without max_retries only permanent failures are failed")
+	}
+}
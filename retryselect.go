@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// RetryFilter narrows a retry run to chosen entries
+type RetryFilter struct {
+	Hash  string // Hash, unique hash prefix, or UUID of one entry
+	Match string // Glob matched against titles and torrent names, ignoring case
+}
+
+// isSet reports whether the filter selects anything less than the whole queue
+func (f RetryFilter) isSet() bool {
+	return f.Hash != "" || f.Match != ""
+}
+
+// selectRetryEntries returns the retry entries the filter picks: the one
+// named by Hash plus every one matching Match
+func selectRetryEntries(db *MagnetDatabase, filter RetryFilter) (map[string]MagnetEntry, error) {
+	selected := make(map[string]MagnetEntry)
+
+	if filter.Hash != "" {
+		key := strings.ToLower(strings.TrimSpace(filter.Hash))
+		hash, section, entry, ok := findEntry(db, key)
+		if !ok {
+			var matches []string
+			for candidate := range db.Retry {
+				if strings.HasPrefix(candidate, key) {
+					matches = append(matches, candidate)
+				}
+			}
+			switch len(matches) {
+			case 0:
+				return nil, fmt.Errorf("no retry entry matches %q", filter.Hash)
+			case 1:
+				hash, section, entry, ok = matches[0], SectionRetry, db.Retry[matches[0]], true
+			default:
+				return nil, fmt.Errorf("%q matches %d retry entries", filter.Hash, len(matches))
+			}
+		}
+		if section != SectionRetry {
+			return nil, fmt.Errorf("%s is in %s, not the retry queue", entry.Title, section)
+		}
+		selected[hash] = entry
+	}
+
+	if filter.Match != "" {
+		pattern := strings.ToLower(filter.Match)
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", filter.Match, err)
+		}
+		matches := 0
+		for hash, entry := range db.Retry {
+			for _, name := range []string{entry.Title, entry.TorrentName} {
+				if matched, _ := path.Match(pattern, strings.ToLower(name)); matched && name != "" {
+					selected[hash] = entry
+					matches++
+					break
+				}
+			}
+		}
+		if matches == 0 {
+			return nil, fmt.Errorf("no retry entry matches %q", filter.Match)
+		}
+	}
+	return selected, nil
+}
+
+// describeRetryFilter names the filter for log messages
+func describeRetryFilter(filter RetryFilter) string {
+	var parts []string
+	if filter.Hash != "" {
+		parts = append(parts, "hash "+filter.Hash)
+	}
+	if filter.Match != "" {
+		parts = append(parts, fmt.Sprintf("pattern %q", filter.Match))
+	}
+	return strings.Join(parts, " and ")
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// Test selectRetryEntries picks entries by hash, prefix, UUID, and glob
+func TestSelectRetryEntries(t *testing.T) {
+	db := &MagnetDatabase{
+		Added: map[string]MagnetEntry{
+			"added1": {Hash: "added1", Title: "Already Here"},
+		},
+		Retry: map[string]MagnetEntry{
+			"abc123": {Hash: "abc123", Title: "Dune", UUID: "u-dune"},
+			"abd456": {Hash: "abd456", Title: "Foundation", TorrentName: "Foundation.Unabridged"},
+			"fff789": {Hash: "fff789", Title: "Dune Messiah"},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		filter  RetryFilter
+		want    string
+		wantErr string
+	}{
+		{"exact hash", RetryFilter{Hash: "ABC123"}, "abc123", ""},
+		{"unique prefix", RetryFilter{Hash: "abd"}, "abd456", ""},
+		{"uuid", RetryFilter{Hash: "u-dune"}, "abc123", ""},
+		{"ambiguous prefix", RetryFilter{Hash: "ab"}, "", "matches 2"},
+		{"not in retry", RetryFilter{Hash: "added1"}, "", "not the retry queue"},
+		{"unknown hash", RetryFilter{Hash: "zzz"}, "", "no retry entry"},
+		{"glob on title", RetryFilter{Match: "dune*"}, "abc123,fff789", ""},
+		{"glob on torrent name", RetryFilter{Match: "*unabridged"}, "abd456", ""},
+		{"hash and glob", RetryFilter{Hash: "abd", Match: "*messiah"}, "abd456,fff789", ""},
+		{"no glob match", RetryFilter{Match: "*hobbit*"}, "", "no retry entry"},
+		{"bad glob", RetryFilter{Match: "[dune"}, "", "invalid pattern"},
+	}
+
+	for _, tt := range tests {
+		selected, err := selectRetryEntries(db, tt.filter)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: expected error containing %q, got %v", tt.name, tt.wantErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
+		}
+		var hashes []string
+		for _, hash := range []string{"abc123", "abd456", "fff789"} {
+			if _, ok := selected[hash]; ok {
+				hashes = append(hashes, hash)
+			}
+		}
+		if got := strings.Join(hashes, ","); got != tt.want || len(selected) != len(hashes) {
+			t.Errorf("%s: selected %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// DelugeError is an error reported by Deluge in a JSON-RPC response
+type DelugeError struct {
+	Method  string
+	Message string
+	Code    int
+}
+
+func (e *DelugeError) Error() string {
+	return fmt.Sprintf("Deluge error: %s", e.Message)
+}
+
+// ResponseError describes a reply that is not the JSON-RPC response a
+// method should return, such as a proxy error page or a version quirk
+type ResponseError struct {
+	Method string
+	Reason string
+}
+
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("unexpected response to %s: %s", e.Method, e.Reason)
+}
+
+// NonJSONError is returned when something other than Deluge answered, such
+// as a reverse proxy error page or a captive portal login form
+type NonJSONError struct {
+	Method  string
+	Status  int
+	Snippet string // Short plain-text excerpt of the body
+}
+
+func (e *NonJSONError) Error() string {
+	if e.Snippet == "" {
+		return fmt.Sprintf("Deluge endpoint returned non-JSON (status %d)", e.Status)
+	}
+	return fmt.Sprintf("Deluge endpoint returned non-JSON (status %d): %s", e.Status, e.Snippet)
+}
+
+// maxSnippetLength caps how much of a non-JSON body ends up in errors and logs
+const maxSnippetLength = 120
+
+var (
+	htmlTitle  = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+	htmlTag    = regexp.MustCompile(`(?s)<[^>]*>`)
+	whitespace = regexp.MustCompile(`\s+`)
+)
+
+// bodySnippet summarises a non-JSON body, preferring the HTML title and
+// otherwise the text with markup stripped
+func bodySnippet(body []byte) string {
+	text := string(body)
+	if m := htmlTitle.FindStringSubmatch(text); m != nil && strings.TrimSpace(m[1]) != "" {
+		text = m[1]
+	} else {
+		text = htmlTag.ReplaceAllString(text, " ")
+	}
+	text = strings.TrimSpace(whitespace.ReplaceAllString(text, " "))
+	if len(text) > maxSnippetLength {
+		text = strings.ToValidUTF8(text[:maxSnippetLength], "") + "..."
+	}
+	return text
+}
+
+// isNetworkError reports whether err means Deluge could not be reached, as
+// opposed to Deluge answering with an error. Such failures stay in the retry
+// queue without counting against the entry.
+func isNetworkError(err error) bool {
+	var nonJSON *NonJSONError
+	var netErr net.Error
+	return errors.As(err, &nonJSON) || errors.As(err, &netErr)
+}
+
+// transientRetryDelays are the waits before each extra attempt at adding a
+// magnet after a timeout or refused connection, so a brief network blip does
+// not send the link to the retry queue
+var transientRetryDelays = []time.Duration{time.Second, 2 * time.Second, 4 * time.Second}
+
+// isTransientError reports whether err looks like a momentary network
+// failure worth retrying at once: a timeout, or a connection that was
+// refused, reset, or could not be dialed
+func isTransientError(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET)
+}
+
+// rpcResponse is the JSON-RPC envelope returned by the Deluge Web API
+type rpcResponse struct {
+	ID     json.RawMessage `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  json.RawMessage `json:"error"`
+}
+
+// hasValue reports whether a raw JSON field is present and not null
+func hasValue(raw json.RawMessage) bool {
+	return len(raw) > 0 && !bytes.Equal(raw, []byte("null"))
+}
+
+// parseDelugeError decodes the error member of a response, which is an
+// object with message and code but may be a bare string on odd servers
+func parseDelugeError(method string, raw json.RawMessage) *DelugeError {
+	var obj struct {
+		Message string `json:"message"`
+		Code    int    `json:"code"`
+	}
+	if err := json.Unmarshal(raw, &obj); err == nil && obj.Message != "" {
+		return &DelugeError{Method: method, Message: obj.Message, Code: obj.Code}
+	}
+	var message string
+	if err := json.Unmarshal(raw, &message); err == nil && message != "" {
+		return &DelugeError{Method: method, Message: message}
+	}
+	return &DelugeError{Method: method, Message: string(raw)}
+}
+
+// post sends a JSON-RPC request to the Deluge Web API and returns the raw
+// reply with its HTTP status
+func (c *DelugeClient) post(method string, params []interface{}) ([]byte, int, error) {
+	if c.Simulate {
+		body, err := json.Marshal(simulatedResponse(method, params))
+		return body, http.StatusOK, err
+	}
+
+	requestBody := map[string]interface{}{
+		"method": method,
+		"params": params,
+		"id":     1,
+	}
+
+	jsonData, err := json.Marshal(requestBody)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	req, err := http.NewRequest("POST", c.BaseURL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if c.Cookie != "" {
+		req.Header.Set("Cookie", c.Cookie)
+	}
+
+	start := time.Now()
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	recordDelugeLatency(time.Since(start))
+
+	// Save cookie from response
+	if cookies := resp.Cookies(); len(cookies) > 0 {
+		c.Cookie = cookies[0].String()
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	return body, resp.StatusCode, err
+}
+
+// rpc calls a Deluge method and decodes its result into out (which may be
+// nil when the result is not needed). Errors reported by Deluge are returned
+// as *DelugeError and malformed replies as *ResponseError. A null result
+// leaves out unchanged, so callers check for required values themselves.
+func (c *DelugeClient) rpc(method string, params []interface{}, out interface{}) error {
+	if params == nil {
+		params = []interface{}{}
+	}
+	body, status, err := c.post(method, params)
+	if err != nil {
+		return err
+	}
+
+	if !json.Valid(body) {
+		return &NonJSONError{Method: method, Status: status, Snippet: bodySnippet(body)}
+	}
+	var resp rpcResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return &ResponseError{Method: method, Reason: fmt.Sprintf("not a JSON-RPC response: %v", err)}
+	}
+	if hasValue(resp.Error) {
+		return parseDelugeError(method, resp.Error)
+	}
+	if out == nil || !hasValue(resp.Result) {
+		return nil
+	}
+	if err := json.Unmarshal(resp.Result, out); err != nil {
+		return &ResponseError{Method: method, Reason: fmt.Sprintf("result has the wrong shape: %v", err)}
+	}
+	return nil
+}
+
+// delugeHost is one entry of web.get_hosts: [id, host, port, ...]
+type delugeHost struct {
+	ID   string
+	Host string
+	Port int
+}
+
+func (h *delugeHost) UnmarshalJSON(data []byte) error {
+	var fields []json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return fmt.Errorf("host entry is not a list")
+	}
+	if len(fields) < 1 {
+		return fmt.Errorf("host entry is empty")
+	}
+	if err := json.Unmarshal(fields[0], &h.ID); err != nil || h.ID == "" {
+		return fmt.Errorf("host entry has no ID")
+	}
+	if len(fields) > 1 {
+		json.Unmarshal(fields[1], &h.Host)
+	}
+	if len(fields) > 2 {
+		json.Unmarshal(fields[2], &h.Port)
+	}
+	return nil
+}
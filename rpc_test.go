@@ -0,0 +1,186 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Test errors reported by Deluge are surfaced with their message
+func TestRPCDelugeError(t *testing.T) {
+	client := fakeDeluge(t, func(method string, params []interface{}) (interface{}, interface{}) {
+		return nil, map[string]interface{}{"message": "Torrent already in session (abc).", "code": 4}
+	})
+
+	_, err := client.AddMagnet("magnet:?xt=urn:btih:abc", "", AddTorrentOptions{})
+	var delugeErr *DelugeError
+	if !errors.As(err, &delugeErr) {
+		t.Fatalf("Expected DelugeError, got %v", err)
+	}
+	if delugeErr.Method != "core.add_torrent_magnet" || delugeErr.Code != 4 {
+		t.Errorf("Unexpected error details: %+v", delugeErr)
+	}
+	if !strings.Contains(err.Error(), "already in session") {
+		t.Errorf("Error should keep Deluge's message, got %q", err.Error())
+	}
+}
+
+// Test malformed results produce descriptive errors instead of panics
+func TestRPCMalformedResult(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		result interface{}
+		call   func(c *DelugeClient) error
+	}{
+		{"host without ID", "web.get_hosts", []interface{}{[]interface{}{42, "127.0.0.1"}}, func(c *DelugeClient) error {
+			return c.Connect()
+		}},
+		{"host not a list", "web.get_hosts", []interface{}{"abc"}, func(c *DelugeClient) error {
+			return c.Connect()
+		}},
+		{"status not an object", "core.get_torrent_status", "oops", func(c *DelugeClient) error {
+			_, err := c.GetTorrentStatus("abc", []string{"name"})
+			return err
+		}},
+		{"missing hash", "core.add_torrent_magnet", nil, func(c *DelugeClient) error {
+			_, err := c.AddMagnet("magnet:?xt=urn:btih:abc", "", AddTorrentOptions{})
+			return err
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fakeDeluge(t, func(method string, params []interface{}) (interface{}, interface{}) {
+				if method == "web.connected" {
+					return false, nil
+				}
+				if method == tt.method {
+					return tt.result, nil
+				}
+				return nil, nil
+			})
+
+			err := tt.call(client)
+			var respErr *ResponseError
+			if !errors.As(err, &respErr) {
+				t.Fatalf("Expected ResponseError, got %v", err)
+			}
+			if respErr.Method != tt.method {
+				t.Errorf("Expected error for %s, got %s", tt.method, respErr.Method)
+			}
+		})
+	}
+}
+
+// Test a non-JSON reply such as a proxy error page is reported with a snippet
+func TestRPCNonJSONResponse(t *testing.T) {
+	page := "<html><head><title>502 Bad Gateway</title></head><body>" + strings.Repeat("<p>nginx</p>", 500) + "</body></html>"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(http.StatusBadGateway)
+		w.Write([]byte(page))
+	}))
+	defer server.Close()
+
+	client := NewDelugeClient("127.0.0.1", "0", "password")
+	client.BaseURL = server.URL + "/json"
+
+	err := client.Authenticate()
+	if errors.Is(err, errAuthRejected) {
+		t.Fatal("A proxy error page should not count as a rejected password")
+	}
+	var nonJSON *NonJSONError
+	if !errors.As(err, &nonJSON) {
+		t.Fatalf("Expected NonJSONError, got %v", err)
+	}
+	if nonJSON.Status != http.StatusBadGateway {
+		t.Errorf("Expected status 502, got %d", nonJSON.Status)
+	}
+	expected := "Deluge endpoint returned non-JSON (status 502): 502 Bad Gateway"
+	if err.Error() != expected {
+		t.Errorf("Expected %q, got %q", expected, err.Error())
+	}
+	if !isNetworkError(err) {
+		t.Error("Non-JSON responses should be treated as network failures")
+	}
+}
+
+// Test bodySnippet keeps error messages short
+func TestBodySnippet(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		expected string
+	}{
+		{"title", "<html><title> Portal\n Login </title><body>big</body></html>", "Portal Login"},
+		{"no title", "<html><body><h1>Service Unavailable</h1></body></html>", "Service Unavailable"},
+		{"plain text", "upstream connect error", "upstream connect error"},
+		{"empty", "", ""},
+		{"long", strings.Repeat("a", 200), strings.Repeat("a", maxSnippetLength) + "..."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := bodySnippet([]byte(tt.body)); got != tt.expected {
+				t.Errorf("bodySnippet() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+// Test isNetworkError separates unreachable servers from Deluge errors
+func TestIsNetworkError(t *testing.T) {
+	client := NewDelugeClient("127.0.0.1", "1", "password")
+	if err := client.Authenticate(); !isNetworkError(err) {
+		t.Errorf("Connection refused should be a network error, got %v", err)
+	}
+	if isNetworkError(&DelugeError{Message: "Torrent already in session"}) {
+		t.Error("Deluge errors are not network errors")
+	}
+	if isNetworkError(errAuthRejected) {
+		t.Error("Rejected logins are not network errors")
+	}
+}
+
+// Test a timed-out add is tried again in the same run and then succeeds
+func TestAddMagnetRetriesTransientErrors(t *testing.T) {
+	saved := transientRetryDelays
+	transientRetryDelays = []time.Duration{time.Millisecond, time.Millisecond}
+	defer func() { transientRetryDelays = saved }()
+
+	var calls atomic.Int32
+	client := fakeDeluge(t, func(method string, params []interface{}) (interface{}, interface{}) {
+		if calls.Add(1) == 1 {
+			time.Sleep(200 * time.Millisecond)
+		}
+		return "abc", nil
+	})
+	client.HTTPClient = &http.Client{Timeout: 50 * time.Millisecond}
+
+	hash, err := client.AddMagnet("magnet:?xt=urn:btih:abc", "", AddTorrentOptions{})
+	if err != nil || hash != "abc" {
+		t.Fatalf("Expected the second attempt to succeed, got %q (%v)", hash, err)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("Expected 2 attempts, got %d", n)
+	}
+}
+
+// Test only timeouts and failed connections count as transient
+func TestIsTransientError(t *testing.T) {
+	client := NewDelugeClient("127.0.0.1", "1", "password")
+	if err := client.Authenticate(); !isTransientError(err) {
+		t.Errorf("Connection refused should be transient, got %v", err)
+	}
+	if isTransientError(&DelugeError{Message: "Torrent already in session"}) {
+		t.Error("Deluge errors are not transient")
+	}
+	if isTransientError(&NonJSONError{Status: 502}) {
+		t.Error("Proxy error pages are not transient")
+	}
+}
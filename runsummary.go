@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// processStarted is when this invocation began, for the summary's duration
+var processStarted = time.Now()
+
+var (
+	runCountsMu sync.Mutex
+	runCounts   = make(map[string]int)
+)
+
+// countRun adds n to one of the counts the summary line reports
+func countRun(name string, n int) {
+	runCountsMu.Lock()
+	defer runCountsMu.Unlock()
+	runCounts[name] += n
+}
+
+// runSummary formats the line that ends every invocation, for log watchers:
+// fixed fields first, then the non-zero counts by name, then any error,
+// quoted. The fields and their order only ever grow at the end.
+func runSummary(command string, err error, duration time.Duration, counts map[string]int, id string) string {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	fields := []string{
+		"summary",
+		"result=" + result,
+		"command=" + command,
+		fmt.Sprintf("duration_ms=%d", duration.Milliseconds()),
+		"correlation_id=" + id,
+	}
+
+	names := make([]string, 0, len(counts))
+	for name, n := range counts {
+		if n != 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fields = append(fields, fmt.Sprintf("%s=%d", name, counts[name]))
+	}
+	if err != nil {
+		fields = append(fields, "error="+strconv.Quote(err.Error()))
+	}
+	return strings.Join(fields, " ")
+}
+
+// logRunSummary logs the summary line for this invocation
+func logRunSummary(command string, err error) {
+	runCountsMu.Lock()
+	counts := make(map[string]int, len(runCounts))
+	for name, n := range runCounts {
+		counts[name] = n
+	}
+	runCountsMu.Unlock()
+	log.Print(runSummary(command, err, time.Since(processStarted), counts, CorrelationID()))
+}
+
+// fatalRun logs a fatal error and the summary line after it, then exits
+func fatalRun(command string, err error, format string, args ...interface{}) {
+	log.Printf(format, args...)
+	logRunSummary(command, err)
+	os.Exit(1)
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// Test the summary line keeps its fields in a fixed order, skips zero
+// counts, and quotes the error
+func TestRunSummary(t *testing.T) {
+	counts := map[string]int{"queued": 2, "added": 1, "duplicate": 0}
+	got := runSummary("retry", nil, 1520*time.Millisecond, counts, "ab12cd")
+	want := "summary result=ok command=retry duration_ms=1520 correlation_id=ab12cd added=1 queued=2"
+	if got != want {
+		t.Errorf("Unexpected summary:\n got %s\nwant %s", got, want)
+	}
+
+	got = runSummary("add", errors.New(`connection failed: "refused"`), 0, nil, "ab12cd")
+	want = `summary result=error command=add duration_ms=0 correlation_id=ab12cd error="connection failed: \"refused\""`
+	if got != want {
+		t.Errorf("Unexpected summary:\n got %s\nwant %s", got, want)
+	}
+}
+
+// Test recorded history events are counted for the summary
+func TestRunCountsHistory(t *testing.T) {
+	runCountsMu.Lock()
+	before := runCounts[HistoryRetryFailed]
+	runCountsMu.Unlock()
+
+	var entry MagnetEntry
+	entry.recordHistory(HistoryRetryFailed, "", "timeout")
+	entry.recordHistory(HistoryRetryFailed, "", "timeout")
+
+	runCountsMu.Lock()
+	defer runCountsMu.Unlock()
+	if runCounts[HistoryRetryFailed]-before != 2 {
+		t.Errorf("Expected 2 more retry_failed, got %d", runCounts[HistoryRetryFailed]-before)
+	}
+}
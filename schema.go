@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// currentSchemaVersion is the database layout this binary reads and writes.
+// Bump it whenever the on-disk format changes in a way an older binary would
+// misread or silently drop on its next save.
+//
+//	1: schema_version introduced
+//	2: tombstones section
+//	3: metadata clock; per-entry device, counter, signature, history,
+//	   verified, verified_at, next_attempt, error_kind, raw_uri, and sent_uri
+const currentSchemaVersion = 3
+
+// SchemaTooNewError means a database was written by a newer magnet-handler
+type SchemaTooNewError struct {
+	Path    string
+	Version int
+}
+
+func (e *SchemaTooNewError) Error() string {
+	return fmt.Sprintf("%s was written by a newer magnet-handler (schema_version %d, this version supports %d): upgrade before modifying it",
+		e.Path, e.Version, currentSchemaVersion)
+}
+
+// checkSchema returns a SchemaTooNewError for a database whose metadata
+// declares a newer schema than this binary supports
+func checkSchema(path string, meta DatabaseMetadata) error {
+	if meta.SchemaVersion > currentSchemaVersion {
+		return &SchemaTooNewError{Path: path, Version: meta.SchemaVersion}
+	}
+	return nil
+}
+
+// guardOverwrite refuses to replace a database file written with a newer
+// schema. Only the leading metadata object is read; files that are missing
+// or unreadable are left to the normal load path.
+func guardOverwrite(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(f)
+	if expectDelim(dec, '{') != nil {
+		return nil
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil
+		}
+		if key, _ := tok.(string); key == "metadata" {
+			var meta DatabaseMetadata
+			if dec.Decode(&meta) != nil {
+				return nil
+			}
+			return checkSchema(path, meta)
+		}
+		// Metadata is written first, so anything else means there is none
+		return nil
+	}
+	return nil
+}
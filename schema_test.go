@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newerDatabase is a file written by a future version with an unknown section
+const newerDatabase = `{
+  "metadata": {"schema_version": 99, "last_sequence": 1},
+  "added": {"hash1": {"uuid": "uuid-1", "hash": "hash1", "title": "Future Book"}},
+  "retry": {},
+  "tombstones": {"hash9": {"deleted_at": "2030-01-01T00:00:00Z"}}
+}`
+
+// Test databases from a newer version are refused rather than misparsed
+func TestLoadNewerSchema(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	path := filepath.Join(tmpDir, "db.json")
+	os.WriteFile(path, []byte(newerDatabase), 0644)
+
+	_, err = LoadJSONDatabase(path)
+	var tooNew *SchemaTooNewError
+	if !errors.As(err, &tooNew) || tooNew.Version != 99 {
+		t.Fatalf("Expected SchemaTooNewError for version 99, got %v", err)
+	}
+}
+
+// Test saves stamp the schema version and never overwrite a newer file
+func TestSaveRefusesNewerSchema(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	current := filepath.Join(tmpDir, "current.json")
+	if err := SaveDatabaseLocal(current, &MagnetDatabase{Added: map[string]MagnetEntry{}, Retry: map[string]MagnetEntry{}}); err != nil {
+		t.Fatalf("SaveDatabaseLocal failed: %v", err)
+	}
+	db, err := LoadJSONDatabase(current)
+	if err != nil {
+		t.Fatalf("LoadJSONDatabase failed: %v", err)
+	}
+	if db.Metadata.SchemaVersion != currentSchemaVersion {
+		t.Errorf("Expected schema_version %d, got %d", currentSchemaVersion, db.Metadata.SchemaVersion)
+	}
+
+	localPath := filepath.Join(tmpDir, "local.json")
+	os.WriteFile(localPath, []byte(newerDatabase), 0644)
+	config := Config{JSONPath: localPath}
+	update := entryUpdate(SectionAdded, "hash2", MagnetEntry{Hash: "hash2", Title: "New Book"})
+
+	err = SaveJSONDatabase(localPath, update, &config)
+	var tooNew *SchemaTooNewError
+	if !errors.As(err, &tooNew) {
+		t.Errorf("Expected SchemaTooNewError, got %v", err)
+	}
+	if err := SaveDatabaseLocal(localPath, db); !errors.As(err, &tooNew) {
+		t.Errorf("Direct saves should also be refused, got %v", err)
+	}
+	data, _ := os.ReadFile(localPath)
+	if string(data) != newerDatabase {
+		t.Error("Newer database should be left untouched")
+	}
+}
+
+// Test a newer remote is not overwritten while local saves carry on
+func TestSaveKeepsNewerRemote(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	localPath := filepath.Join(tmpDir, "local.json")
+	remotePath := filepath.Join(tmpDir, "remote.json")
+	os.WriteFile(remotePath, []byte(newerDatabase), 0644)
+	config := Config{JSONPath: localPath, RemotePath: remotePath}
+
+	update := entryUpdate(SectionAdded, "hash2", MagnetEntry{Hash: "hash2", Title: "New Book"})
+	if err := SaveJSONDatabase(localPath, update, &config); err != nil {
+		t.Fatalf("SaveJSONDatabase failed: %v", err)
+	}
+
+	local, err := LoadJSONDatabase(localPath)
+	if err != nil {
+		t.Fatalf("LoadJSONDatabase failed: %v", err)
+	}
+	if _, ok := local.Added["hash2"]; !ok {
+		t.Error("Local save should still succeed")
+	}
+	data, _ := os.ReadFile(remotePath)
+	if string(data) != newerDatabase {
+		t.Error("Newer remote should be left untouched")
+	}
+}
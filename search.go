@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var searchCommand = &Command{
+	Name:    "search",
+	Usage:   "search [--regex] [--limit N] [--json] <query>",
+	Summary: "Find entries in every section by title, torrent name, or hash",
+}
+
+func init() {
+	searchCommand.Run = runSearch
+	registerCommand(searchCommand)
+}
+
+// searchMatcher returns a filter matching entries whose title, torrent name,
+// or hash contains query, or matches it as a regular expression, ignoring case
+func searchMatcher(query string, regex bool) (func(MagnetEntry) bool, error) {
+	if !regex {
+		return func(entry MagnetEntry) bool { return matchesQuery(entry, query) }, nil
+	}
+	re, err := regexp.Compile("(?i)" + query)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regular expression: %w", err)
+	}
+	return func(entry MagnetEntry) bool {
+		return re.MatchString(entry.Title) || re.MatchString(entry.TorrentName) || re.MatchString(entry.Hash)
+	}, nil
+}
+
+// searchEntries returns the entries in every section, removed included, that
+// match, newest first
+func searchEntries(db *MagnetDatabase, config Config, matches func(MagnetEntry) bool) []ListedEntry {
+	keep := func(entry ListedEntry) bool { return matches(entry.MagnetEntry) }
+	query := EntryQuery{Dead: deadFilter(config), Failed: failedFilter(config), Keep: keep}
+	found := QueryEntries(db, query).Entries
+	query.Section = SectionRemoved
+	found = append(found, QueryEntries(db, query).Entries...)
+
+	sort.SliceStable(found, func(i, j int) bool {
+		if found[i].AddedDate != found[j].AddedDate {
+			return found[i].AddedDate > found[j].AddedDate
+		}
+		return found[i].Hash < found[j].Hash
+	})
+	return found
+}
+
+// runSearch implements the search command
+func runSearch(config Config, args []string) error {
+	fs := newCommandFlags(searchCommand)
+	regex := fs.Bool("regex", false, "Treat the query as a regular expression")
+	limit := fs.Int("limit", 50, "Maximum matches to show (0 = all)")
+	asJSON := fs.Bool("json", false, "Print matches as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("expected a query")
+	}
+	matches, err := searchMatcher(strings.Join(fs.Args(), " "), *regex)
+	if err != nil {
+		return err
+	}
+
+	db, err := LoadJSONDatabase(config.JSONPath)
+	if err != nil {
+		return fmt.Errorf("failed to load database: %w", err)
+	}
+	found := searchEntries(db, config, matches)
+	total := len(found)
+	if *limit > 0 && len(found) > *limit {
+		found = found[:*limit]
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(EntryPage{Total: total, Limit: *limit, Entries: found})
+	}
+	if total == 0 {
+		fmt.Println("No matching entries")
+		return nil
+	}
+	if err := writeColumns(os.Stdout, found, defaultListColumns); err != nil {
+		return err
+	}
+	fmt.Printf("\n%s", plural(total, "match", "matches"))
+	if len(found) < total {
+		fmt.Printf(" (showing %d; use --limit 0 for all)", len(found))
+	}
+	fmt.Println()
+	return nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+)
+
+// Test search finds entries in every section by title, torrent name, or
+// hash, ignoring case, with substring or regex queries
+func TestSearchEntries(t *testing.T) {
+	db := buildListDatabase(3)
+	db.Removed = map[string]MagnetEntry{
+		"c1": {Hash: "c1", Title: "Removed Book", AddedDate: "2024-03-01T00:00:00Z"},
+	}
+	db.Added["a000000000000000000000000000000000000000"] = MagnetEntry{
+		Hash: "a000000000000000000000000000000000000000", TorrentName: "Dune.Unabridged.m4b", AddedDate: "2023-12-01T00:00:00Z",
+	}
+
+	tests := []struct {
+		query string
+		regex bool
+		want  []string // Sections, newest first
+	}{
+		{"BOOK", false, []string{SectionRemoved, SectionRetry, SectionRetry, SectionRetry, SectionAdded, SectionAdded}},
+		{"dune", false, []string{SectionAdded}},
+		{"b0000", false, []string{SectionRetry, SectionRetry, SectionRetry}},
+		{`^(retry|removed) book( 2)?$`, true, []string{SectionRemoved, SectionRetry}},
+		{"nothing", false, nil},
+	}
+	for _, tt := range tests {
+		matches, err := searchMatcher(tt.query, tt.regex)
+		if err != nil {
+			t.Fatalf("searchMatcher(%q) failed: %v", tt.query, err)
+		}
+		found := searchEntries(db, Config{}, matches)
+		var sections []string
+		for _, entry := range found {
+			sections = append(sections, entry.Section)
+		}
+		if len(sections) != len(tt.want) {
+			t.Errorf("search %q: expected %v, got %v", tt.query, tt.want, sections)
+			continue
+		}
+		for i := range sections {
+			if sections[i] != tt.want[i] {
+				t.Errorf("search %q: expected %v, got %v", tt.query, tt.want, sections)
+				break
+			}
+		}
+	}
+
+	if _, err := searchMatcher("(unclosed", true); err == nil {
+		t.Error("Expected an error for an invalid regular expression")
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// keychainService is the service name secrets are filed under in the OS
+// keychain (macOS Keychain, Secret Service, Windows Credential Manager)
+const keychainService = "magnet-handler"
+
+// secretCommandTimeout bounds how long a secret command may run
+const secretCommandTimeout = 10 * time.Second
+
+// SecretSource says where one secret comes from instead of the config file
+type SecretSource struct {
+	Backend string `json:"backend"`           // config (default), env, command, keychain, or vault
+	Name    string `json:"name,omitempty"`    // Environment variable or keychain account (default: the secret's name)
+	Command string `json:"command,omitempty"` // Prints the secret on its first line, e.g. "pass show deluge"
+	Path    string `json:"path,omitempty"`    // Vault secret path, e.g. secret/data/magnet-handler
+	Key     string `json:"key,omitempty"`     // Key within the Vault secret (default: the secret's name)
+}
+
+// SecretBackend retrieves secrets from one kind of store. plaintext is the
+// value written in the config file, for the backend that uses it.
+type SecretBackend interface {
+	Get(name string, source SecretSource, plaintext string) (string, error)
+}
+
+// secretStore is a backend that can also save a secret, so commands such as
+// config set-password can write to it
+type secretStore interface {
+	SecretBackend
+	Set(name string, source SecretSource, value string) error
+}
+
+// configSecrets reads secrets straight from the config file
+type configSecrets struct{}
+
+func (configSecrets) Get(name string, source SecretSource, plaintext string) (string, error) {
+	return plaintext, nil
+}
+
+// envSecrets reads secrets from environment variables
+type envSecrets struct{}
+
+func (envSecrets) Get(name string, source SecretSource, plaintext string) (string, error) {
+	variable := source.Name
+	if variable == "" {
+		return "", fmt.Errorf("the env backend needs a name (the environment variable)")
+	}
+	value, ok := os.LookupEnv(variable)
+	if !ok || value == "" {
+		return "", fmt.Errorf("environment variable %s is not set", variable)
+	}
+	return value, nil
+}
+
+// commandSecrets runs a command, such as a password manager's CLI, and uses
+// the first line it prints
+type commandSecrets struct{}
+
+func (commandSecrets) Get(name string, source SecretSource, plaintext string) (string, error) {
+	args := splitCommandLine(source.Command)
+	if len(args) == 0 {
+		return "", fmt.Errorf("the command backend needs a command")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), secretCommandTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stderr = os.Stderr // Let prompts such as a GPG pinentry reach the user
+	output, err := cmd.Output()
+	if ctx.Err() == context.DeadlineExceeded {
+		return "", fmt.Errorf("%s timed out after %s", args[0], secretCommandTimeout)
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s failed: %w", args[0], err)
+	}
+	line, _, _ := strings.Cut(string(output), "\n")
+	if line = strings.TrimRight(line, "\r"); line == "" {
+		return "", fmt.Errorf("%s printed nothing", args[0])
+	}
+	return line, nil
+}
+
+// keychainSecrets reads and writes secrets in the OS keychain, under
+// keychainService with the secret's name as the account
+type keychainSecrets struct{}
+
+func (keychainSecrets) Get(name string, source SecretSource, plaintext string) (string, error) {
+	return keychainGet(keychainAccount(name, source))
+}
+
+func (keychainSecrets) Set(name string, source SecretSource, value string) error {
+	return keychainSet(keychainAccount(name, source), value)
+}
+
+// keychainAccount returns the keychain account a secret is stored under
+func keychainAccount(name string, source SecretSource) string {
+	if source.Name != "" {
+		return source.Name
+	}
+	return name
+}
+
+// secretBackends are the available backends by name
+var secretBackends = map[string]SecretBackend{
+	"config":   configSecrets{},
+	"env":      envSecrets{},
+	"command":  commandSecrets{},
+	"keychain": keychainSecrets{},
+	"vault":    vaultSecrets{},
+}
+
+// secretBackend returns the backend a source names
+func secretBackend(source SecretSource) (SecretBackend, error) {
+	name := source.Backend
+	if name == "" {
+		name = "config"
+	}
+	backend, ok := secretBackends[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown secret backend %q (use config, env, command, keychain, or vault)", source.Backend)
+	}
+	return backend, nil
+}
+
+// secretFields returns the config's secrets by the name used in the secrets
+// map: top-level fields by their JSON name, target passwords as
+// targets.<name>.password
+func secretFields(config *Config) map[string]*string {
+	fields := map[string]*string{
+		"deluge_password": &config.DelugePassword,
+		"api_token":       &config.APIToken,
+		"webhook_secret":  &config.WebhookSecret,
+		"calendar_secret": &config.CalendarSecret,
+		"signing_key":     &config.SigningKey,
+	}
+	for i := range config.Targets {
+		fields["targets."+config.Targets[i].Name+".password"] = &config.Targets[i].Password
+	}
+	return fields
+}
+
+// ResolveSecrets replaces each secret that has a source in the secrets map
+// with the value from its backend. Only the in-memory config changes; the
+// file keeps whatever it had.
+func ResolveSecrets(config *Config) error {
+	fields := secretFields(config)
+	names := make([]string, 0, len(config.Secrets))
+	for name := range config.Secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		source := config.Secrets[name]
+		field, ok := fields[name]
+		if !ok {
+			return fmt.Errorf("secrets: unknown secret %q", name)
+		}
+		backend, err := secretBackend(source)
+		if err != nil {
+			return fmt.Errorf("secrets: %s: %w", name, err)
+		}
+		value, err := backend.Get(name, source, *field)
+		if err != nil {
+			return fmt.Errorf("secrets: %s: %w", name, err)
+		}
+		*field = value
+	}
+	return nil
+}
+
+// unresolvedSecrets returns a copy of config for saving, with each secret
+// that comes from another backend put back to its stored value so a resolved
+// secret is never written into the file
+func unresolvedSecrets(config, stored Config) Config {
+	config.Targets = slices.Clone(config.Targets)
+	fields, storedFields := secretFields(&config), secretFields(&stored)
+	for name, source := range config.Secrets {
+		field, ok := fields[name]
+		if !ok || source.Backend == "" || source.Backend == "config" {
+			continue
+		}
+		*field = ""
+		if value, ok := storedFields[name]; ok {
+			*field = *value
+		}
+	}
+	return config
+}
+
+// storeSecret saves a new value for a secret in its backend. It reports
+// false when the secret lives in the config file, which the caller saves.
+func storeSecret(config Config, name, value string) (bool, error) {
+	source, ok := config.Secrets[name]
+	if !ok {
+		return false, nil
+	}
+	backend, err := secretBackend(source)
+	if err != nil {
+		return false, err
+	}
+	if _, plain := backend.(configSecrets); plain {
+		return false, nil
+	}
+	store, ok := backend.(secretStore)
+	if !ok {
+		return false, fmt.Errorf("%s comes from the %s backend; update it there", name, source.Backend)
+	}
+	return true, store.Set(name, source, value)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// Test secrets are read from the environment and commands, and left alone
+// when they come from the config
+func TestResolveSecrets(t *testing.T) {
+	t.Setenv("MAGNET_HANDLER_TEST_TOKEN", "from-env")
+
+	config := DefaultConfig()
+	config.DelugePassword = "in-file"
+	config.WebhookSecret = "webhook-in-file"
+	config.Targets = []TargetConfig{{Name: "seedbox", Password: "unused"}}
+	config.Secrets = map[string]SecretSource{
+		"webhook_secret":           {Backend: "config"},
+		"api_token":                {Backend: "env", Name: "MAGNET_HANDLER_TEST_TOKEN"},
+		"targets.seedbox.password": {Backend: "command", Command: `printf "s3cret\nsecond line"`},
+		"deluge_password":          {},
+	}
+
+	if err := ResolveSecrets(&config); err != nil {
+		t.Fatalf("ResolveSecrets failed: %v", err)
+	}
+	if config.DelugePassword != "in-file" || config.WebhookSecret != "webhook-in-file" {
+		t.Errorf("Config-backed secrets should be unchanged, got %q and %q", config.DelugePassword, config.WebhookSecret)
+	}
+	if config.APIToken != "from-env" {
+		t.Errorf("Expected api_token from the environment, got %q", config.APIToken)
+	}
+	if config.Targets[0].Password != "s3cret" {
+		t.Errorf("Expected the command's first line, got %q", config.Targets[0].Password)
+	}
+}
+
+// Test misconfigured secrets fail with the secret's name
+func TestResolveSecretsErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		secrets map[string]SecretSource
+		want    string
+	}{
+		{"unknown secret", map[string]SecretSource{"smtp_password": {Backend: "env", Name: "X"}}, `unknown secret "smtp_password"`},
+		{"unknown backend", map[string]SecretSource{"deluge_password": {Backend: "gpg"}}, `unknown secret backend "gpg"`},
+		{"unset variable", map[string]SecretSource{"deluge_password": {Backend: "env", Name: "MAGNET_HANDLER_TEST_UNSET"}}, "MAGNET_HANDLER_TEST_UNSET is not set"},
+		{"missing variable name", map[string]SecretSource{"deluge_password": {Backend: "env"}}, "needs a name"},
+		{"failing command", map[string]SecretSource{"deluge_password": {Backend: "command", Command: "false"}}, "false failed"},
+		{"silent command", map[string]SecretSource{"deluge_password": {Backend: "command", Command: "true"}}, "printed nothing"},
+	}
+
+	for _, tt := range tests {
+		config := DefaultConfig()
+		config.Secrets = tt.secrets
+		err := ResolveSecrets(&config)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.name, tt.want, err)
+		}
+	}
+}
+
+// Test storeSecret leaves config-backed secrets to the caller and refuses
+// backends it cannot write to
+func TestStoreSecret(t *testing.T) {
+	config := DefaultConfig()
+	if external, err := storeSecret(config, "deluge_password", "pw"); external || err != nil {
+		t.Errorf("Secret without a source should be saved in the config, got %v, %v", external, err)
+	}
+
+	config.Secrets = map[string]SecretSource{"deluge_password": {Backend: "env", Name: "DELUGE_PASSWORD"}}
+	if _, err := storeSecret(config, "deluge_password", "pw"); err == nil || !strings.Contains(err.Error(), "update it there") {
+		t.Errorf("Expected env-backed secret to be refused, got %v", err)
+	}
+}
+
+// Test saving puts secrets from other backends back to their stored values
+func TestUnresolvedSecrets(t *testing.T) {
+	stored := DefaultConfig()
+	stored.DelugePassword = ""
+	stored.Targets = []TargetConfig{{Name: "seedbox", Password: "in-file"}}
+	stored.Secrets = map[string]SecretSource{
+		"deluge_password":          {Backend: "vault", Path: "secret/data/magnet-handler"},
+		"targets.seedbox.password": {Backend: "config"},
+	}
+
+	config := stored
+	config.Targets = []TargetConfig{{Name: "seedbox", Password: "in-file"}}
+	config.DelugePassword = "from-vault"
+	config.DelugeLabel = "books"
+
+	saved := unresolvedSecrets(config, stored)
+	if saved.DelugePassword != "" || saved.DelugeLabel != "books" || saved.Targets[0].Password != "in-file" {
+		t.Errorf("Expected only the vault secret reverted, got %+v", saved)
+	}
+	if config.DelugePassword != "from-vault" {
+		t.Error("The running config must keep its resolved secrets")
+	}
+}
@@ -0,0 +1,374 @@
+package main
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// defaultServeAddress is where the daemon listens when not configured
+const defaultServeAddress = "127.0.0.1:8790"
+
+//go:embed dashboard.html
+var dashboardHTML []byte
+
+// apiServer serves the dashboard and JSON API in daemon mode
+type apiServer struct {
+	config Config
+
+	tokensMu sync.RWMutex
+	paired   []PairedToken // Tokens issued through pairing
+
+	queue *workQueue // Persisted submissions (nil = run them inline)
+
+	throttleMu sync.Mutex
+	throttled  bool // Submissions are being refused with 429
+
+	syncMu       sync.Mutex
+	mutations    int       // Database changes since the last sync_every sync
+	syncFailures int       // Consecutive failed remote syncs
+	syncRetryAt  time.Time // No sync before this while backing off
+}
+
+// errNotFound is returned when an API request names an unknown entry
+var errNotFound = errors.New("entry not found")
+
+// newAPIServer creates the daemon's HTTP handlers
+func newAPIServer(config Config) *apiServer {
+	return &apiServer{config: config, paired: config.PairedTokens}
+}
+
+// routes registers the dashboard and API endpoints
+func (s *apiServer) routes() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", s.handleDashboard)
+	mux.HandleFunc("GET /api/entries", s.handleEntries)
+	mux.HandleFunc("GET /api/views", s.handleViews)
+	mux.HandleFunc("GET /api/entries/{key}", s.handleEntry)
+	mux.HandleFunc("POST /api/entries/{key}/retry", s.handleRetry)
+	mux.HandleFunc("POST /api/entries/{key}/label", s.handleLabel)
+	mux.HandleFunc("DELETE /api/entries/{key}", s.handleDelete)
+	mux.HandleFunc("POST /api/webhook", s.handleWebhook)
+	mux.HandleFunc("POST /api/pair", s.handlePair)
+	mux.HandleFunc("GET /status/summary", s.handleStatusSummary)
+	mux.HandleFunc("GET /calendar.ics", s.handleCalendar)
+	s.haRoutes(mux)
+	return mux
+}
+
+// handler returns the server's handler with per-request correlation IDs
+// and bearer-token authentication
+func (s *apiServer) handler() http.Handler {
+	return withCorrelation(withToken(s.config.APIToken, s.isPairedToken, withDeviceSignature(s.routes())))
+}
+
+// writeJSON writes v as a JSON response
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes an error as a JSON response
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error(), "correlation_id": w.Header().Get(correlationHeader)})
+}
+
+func (s *apiServer) handleDashboard(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(dashboardHTML)
+}
+
+// handleEntries lists entries with the same filters as the list command
+func (s *apiServer) handleEntries(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	query := EntryQuery{Section: q.Get("section"), Match: q.Get("match"), Limit: 50, Dead: deadFilter(s.config), Failed: failedFilter(s.config)}
+	if name := q.Get("view"); name != "" {
+		view, err := viewQuery(s.config, name, time.Now())
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		// Explicit section and match narrow or override the view
+		if query.Section != "" {
+			view.Section = query.Section
+		}
+		if query.Match != "" {
+			view.Match = query.Match
+		}
+		view.Limit = query.Limit
+		query = view
+	}
+	if v := q.Get("limit"); v != "" {
+		query.Limit, _ = strconv.Atoi(v)
+	}
+	if v := q.Get("offset"); v != "" {
+		query.Offset, _ = strconv.Atoi(v)
+	}
+
+	db, err := LoadJSONDatabase(s.config.JSONPath)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("failed to load database: %w", err))
+		return
+	}
+	page := QueryEntries(db, query)
+	for i, entry := range page.Entries {
+		page.Entries[i].ETag = entryETag(entry.MagnetEntry)
+		page.Entries[i].WebUI = entryWebUILink(entry.Section, entry.Hash)
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+// handleViews lists the saved views with their current counts
+func (s *apiServer) handleViews(w http.ResponseWriter, r *http.Request) {
+	db, err := LoadJSONDatabase(s.config.JSONPath)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("failed to load database: %w", err))
+		return
+	}
+	writeJSON(w, http.StatusOK, countViews(db, s.config, time.Now()))
+}
+
+// lookup finds the entry named in the request path
+func (s *apiServer) lookup(r *http.Request) (string, string, MagnetEntry, error) {
+	db, err := LoadJSONDatabase(s.config.JSONPath)
+	if err != nil {
+		return "", "", MagnetEntry{}, fmt.Errorf("failed to load database: %w", err)
+	}
+	hash, section, entry, ok := findEntry(db, r.PathValue("key"))
+	if !ok {
+		return "", "", MagnetEntry{}, errNotFound
+	}
+	return hash, section, entry, nil
+}
+
+// lockedLookup finds the entry a request names and takes its lock. The
+// entry is read again once the lock is held, and the returned function
+// releases it.
+func (s *apiServer) lockedLookup(r *http.Request) (string, string, MagnetEntry, func(), error) {
+	hash, _, _, err := s.lookup(r)
+	if err != nil {
+		return "", "", MagnetEntry{}, nil, err
+	}
+	unlock := lockEntry(hash)
+	section, entry, err := reloadEntry(s.config.JSONPath, hash)
+	if err != nil {
+		unlock()
+		return "", "", MagnetEntry{}, nil, err
+	}
+	return hash, section, entry, unlock, nil
+}
+
+// lookupError writes the response for a failed lookup
+func lookupError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errNotFound) {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	writeError(w, http.StatusInternalServerError, err)
+}
+
+// handleRetry retries a single retry-queue entry now
+func (s *apiServer) handleRetry(w http.ResponseWriter, r *http.Request) {
+	config := s.config.withCorrelationID(w.Header().Get(correlationHeader))
+
+	hash, section, entry, unlock, err := s.lockedLookup(r)
+	if err != nil {
+		lookupError(w, err)
+		return
+	}
+	defer unlock()
+	if !ifMatch(w, r, entry) {
+		return
+	}
+	if section != SectionRetry {
+		writeError(w, http.StatusConflict, fmt.Errorf("entry is in %s, not the retry queue", section))
+		return
+	}
+
+	client, err := connectDeluge(config)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	config.logger().Printf("Retrying from dashboard: %s", entry.Title)
+	outcome, err := retryEntry(client, config, hash, entry)
+	s.noteMutation()
+	if _, saved, lookupErr := reloadEntry(s.config.JSONPath, hash); lookupErr == nil {
+		w.Header().Set("ETag", entryETag(saved))
+	}
+	response := map[string]string{"hash": hash, "outcome": outcome, "correlation_id": w.Header().Get(correlationHeader)}
+	if err != nil {
+		response["error"] = err.Error()
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// handleLabel changes an entry's label, relabelling the torrent in Deluge
+// when it has already been added
+func (s *apiServer) handleLabel(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Label string `json:"label"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+	label := strings.TrimSpace(body.Label)
+	if label == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("label is required"))
+		return
+	}
+
+	config := s.config.withCorrelationID(w.Header().Get(correlationHeader))
+
+	hash, section, entry, unlock, err := s.lockedLookup(r)
+	if err != nil {
+		lookupError(w, err)
+		return
+	}
+	defer unlock()
+	if !ifMatch(w, r, entry) {
+		return
+	}
+	if section == SectionRemoved {
+		writeError(w, http.StatusConflict, fmt.Errorf("entry has been removed"))
+		return
+	}
+
+	if section == SectionAdded {
+		client, err := connectDeluge(config)
+		if err != nil {
+			writeError(w, http.StatusBadGateway, err)
+			return
+		}
+		torrentID := entry.TorrentID
+		if torrentID == "" {
+			torrentID = hash
+		}
+		if err := client.SetTorrentLabel(torrentID, label); err != nil {
+			writeError(w, http.StatusBadGateway, fmt.Errorf("failed to relabel in Deluge: %w", err))
+			return
+		}
+	}
+
+	entry.Label = label
+	if err := SaveJSONDatabase(s.config.JSONPath, entryUpdate(section, hash, entry), &config); err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("failed to save database: %w", err))
+		return
+	}
+	s.noteMutation()
+	config.logger().Printf("Relabelled from dashboard: %s -> %s", entry.Title, label)
+	s.writeEntry(w, hash, section, entry)
+}
+
+// handleDelete moves an entry to the removed section without touching Deluge
+func (s *apiServer) handleDelete(w http.ResponseWriter, r *http.Request) {
+	config := s.config.withCorrelationID(w.Header().Get(correlationHeader))
+
+	hash, section, entry, unlock, err := s.lockedLookup(r)
+	if err != nil {
+		lookupError(w, err)
+		return
+	}
+	defer unlock()
+	if !ifMatch(w, r, entry) {
+		return
+	}
+	if section != SectionRemoved {
+		entry.RemovedAt = time.Now().Format(time.RFC3339)
+		entry.recordHistory(HistoryRemoved, "", "deleted from dashboard")
+		if err := SaveJSONDatabase(s.config.JSONPath, entryUpdate(SectionRemoved, hash, entry), &config); err != nil {
+			writeError(w, http.StatusInternalServerError, fmt.Errorf("failed to save database: %w", err))
+			return
+		}
+		s.noteMutation()
+		config.logger().Printf("Deleted from dashboard: %s", entry.Title)
+	}
+	s.writeEntry(w, hash, SectionRemoved, entry)
+}
+
+// runServe implements the serve command
+func runServe(config Config, args []string) error {
+	fs := newCommandFlags(serveCommand)
+	listen := fs.String("listen", "", "Address to listen on (default "+defaultServeAddress+")")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	addr := config.ServeAddress
+	if *listen != "" {
+		addr = *listen
+	}
+	if addr == "" {
+		addr = defaultServeAddress
+	}
+
+	cert, key, err := tlsFiles(config)
+	if err != nil {
+		return err
+	}
+	if err := ensureAPIToken(&config); err != nil {
+		return err
+	}
+	if cert == "" && !isLoopback(addr) {
+		log.Printf("⚠ Serving on %s without TLS: the API token is sent in clear text", addr)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	api := newAPIServer(config)
+	if err := api.startQueue(ctx); err != nil {
+		return err
+	}
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           api.handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errs := make(chan error, 1)
+	scheme := "http"
+	if cert != "" {
+		scheme = "https"
+		go func() { errs <- server.ListenAndServeTLS(cert, key) }()
+	} else {
+		go func() { errs <- server.ListenAndServe() }()
+	}
+	log.Printf("✓ Dashboard running at %s://%s/", scheme, addr)
+	log.Printf("  Open %s://%s/#token=<api_token> to sign in", scheme, addr)
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Println("Shutting down...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return server.Shutdown(shutdownCtx)
+}
+
+var serveCommand = &Command{
+	Name:    "serve",
+	Usage:   "serve [--listen host:port]",
+	Summary: "Run in daemon mode, serving the web dashboard and JSON API",
+}
+
+func init() {
+	serveCommand.Run = runServe
+	registerCommand(serveCommand)
+}
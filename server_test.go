@@ -0,0 +1,229 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testAPIToken is the bearer token used by API tests
+const testAPIToken = "test-token"
+
+// apiRequest sends an authenticated API request
+func apiRequest(t *testing.T, method, url string, body io.Reader) *http.Response {
+	t.Helper()
+	req, _ := http.NewRequest(method, url, body)
+	req.Header.Set("Authorization", "Bearer "+testAPIToken)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s failed: %v", method, url, err)
+	}
+	return resp
+}
+
+// newTestAPIServer creates an API server over a database with one added
+// and one retry entry
+func newTestAPIServer(t *testing.T) (*httptest.Server, Config) {
+	t.Helper()
+	return newConfiguredAPIServer(t, nil)
+}
+
+// newConfiguredAPIServer is newTestAPIServer with config adjustments
+func newConfiguredAPIServer(t *testing.T, configure func(config *Config)) (*httptest.Server, Config) {
+	t.Helper()
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(tmpDir) })
+	t.Setenv("HOME", tmpDir)
+
+	config := DefaultConfig()
+	config.JSONPath = filepath.Join(tmpDir, "db.json")
+	config.RemotePath = ""
+	config.APIToken = testAPIToken
+	if configure != nil {
+		configure(&config)
+	}
+
+	db := &MagnetDatabase{
+		Added: map[string]MagnetEntry{"hash1": {UUID: "uuid-1", Hash: "hash1", Title: "Added Book", AddedDate: "2024-01-02T00:00:00Z"}},
+		Retry: map[string]MagnetEntry{"hash2": {UUID: "uuid-2", Hash: "hash2", Title: "Stuck Book", AddedDate: "2024-01-01T00:00:00Z", RetryCount: 3}},
+	}
+	if err := SaveDatabaseLocal(config.JSONPath, db); err != nil {
+		t.Fatalf("Failed to save database: %v", err)
+	}
+
+	server := httptest.NewServer(newAPIServer(config).handler())
+	t.Cleanup(server.Close)
+	return server, config
+}
+
+// Test the dashboard and entry listing endpoints
+func TestAPIListEntries(t *testing.T) {
+	server, _ := newTestAPIServer(t)
+
+	resp, err := http.Get(server.URL + "/")
+	if err != nil {
+		t.Fatalf("GET / failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		t.Errorf("Dashboard: got %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	resp = apiRequest(t, http.MethodGet, server.URL+"/api/entries?section=retry", nil)
+	defer resp.Body.Close()
+	var page EntryPage
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		t.Fatalf("Failed to decode page: %v", err)
+	}
+	if page.Total != 1 || page.Entries[0].Hash != "hash2" || page.Entries[0].RetryCount != 3 {
+		t.Errorf("Unexpected retry page: %+v", page)
+	}
+}
+
+// Test deleting and relabelling entries through the API
+func TestAPIDeleteAndLabel(t *testing.T) {
+	server, config := newTestAPIServer(t)
+
+	resp := apiRequest(t, http.MethodPost, server.URL+"/api/entries/uuid-2/label", strings.NewReader(`{"label": "podcasts"}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Label: expected 200, got %d", resp.StatusCode)
+	}
+
+	resp = apiRequest(t, http.MethodDelete, server.URL+"/api/entries/hash1", nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Delete: expected 200, got %d", resp.StatusCode)
+	}
+
+	resp = apiRequest(t, http.MethodDelete, server.URL+"/api/entries/missing", nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Unknown entry: expected 404, got %d", resp.StatusCode)
+	}
+	if resp.Header.Get(correlationHeader) == "" {
+		t.Error("Responses should carry a correlation ID")
+	}
+
+	db, err := LoadJSONDatabase(config.JSONPath)
+	if err != nil {
+		t.Fatalf("Failed to load database: %v", err)
+	}
+	if db.Retry["hash2"].Label != "podcasts" {
+		t.Errorf("Retry entry should be relabelled, got %q", db.Retry["hash2"].Label)
+	}
+	if _, ok := db.Added["hash1"]; ok {
+		t.Error("Deleted entry should leave the added section")
+	}
+	if db.Removed["hash1"].RemovedAt == "" {
+		t.Error("Deleted entry should be in the removed section")
+	}
+}
+
+// Test the API requires the bearer token except on public paths
+func TestAPITokenAuth(t *testing.T) {
+	server, _ := newTestAPIServer(t)
+
+	tests := []struct {
+		name     string
+		path     string
+		auth     string
+		expected int
+	}{
+		{"dashboard is public", "/", "", http.StatusOK},
+		{"missing token", "/api/entries", "", http.StatusUnauthorized},
+		{"wrong token", "/api/entries", "Bearer nope", http.StatusUnauthorized},
+		{"wrong scheme", "/api/entries", "Basic " + testAPIToken, http.StatusUnauthorized},
+		{"valid token", "/api/entries", "Bearer " + testAPIToken, http.StatusOK},
+		{"case-insensitive scheme", "/api/entries", "bearer " + testAPIToken, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, server.URL+tt.path, nil)
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.expected {
+				t.Errorf("Expected %d, got %d", tt.expected, resp.StatusCode)
+			}
+		})
+	}
+
+	// Without a configured token nothing protected is served
+	open := httptest.NewServer(newAPIServer(DefaultConfig()).handler())
+	defer open.Close()
+	resp, err := http.Get(open.URL + "/api/entries")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("No token configured: expected 503, got %d", resp.StatusCode)
+	}
+}
+
+// Test ensureAPIToken generates a token once and stores it
+func TestEnsureAPIToken(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	t.Setenv("HOME", tmpDir)
+
+	config := DefaultConfig()
+	if err := ensureAPIToken(&config); err != nil {
+		t.Fatalf("ensureAPIToken failed: %v", err)
+	}
+	if len(config.APIToken) != 48 {
+		t.Fatalf("Expected a generated token, got %q", config.APIToken)
+	}
+
+	stored, _ := LoadConfig()
+	if stored.APIToken != config.APIToken {
+		t.Errorf("Token should be saved, got %q", stored.APIToken)
+	}
+
+	again := DefaultConfig()
+	ensureAPIToken(&again)
+	if again.APIToken != config.APIToken {
+		t.Error("A second run should reuse the stored token")
+	}
+}
+
+// Test tlsFiles and isLoopback
+func TestServeTLSSettings(t *testing.T) {
+	if _, _, err := tlsFiles(Config{TLSCert: "cert.pem"}); err == nil {
+		t.Error("A certificate without a key should be rejected")
+	}
+	if cert, key, err := tlsFiles(Config{TLSCert: "cert.pem", TLSKey: "key.pem"}); err != nil || cert != "cert.pem" || key != "key.pem" {
+		t.Errorf("Unexpected TLS files: %q %q %v", cert, key, err)
+	}
+
+	for addr, expected := range map[string]bool{
+		"127.0.0.1:8790": true,
+		"localhost:8790": true,
+		"[::1]:8790":     true,
+		"0.0.0.0:8790":   false,
+		":8790":          false,
+		"10.0.0.5:8790":  false,
+	} {
+		if got := isLoopback(addr); got != expected {
+			t.Errorf("isLoopback(%q) = %v, want %v", addr, got, expected)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// sftpBinary is the OpenSSH sftp client used for sftp:// remotes
+var sftpBinary = "sftp"
+
+// isSFTPRemote reports whether a remote path is an sftp:// URL
+func isSFTPRemote(path string) bool {
+	return len(path) > 7 && strings.EqualFold(path[:7], "sftp://")
+}
+
+// sftpStore reaches a database file over SFTP with the system sftp client,
+// so host keys, agents, and ~/.ssh/config work as they do for ssh. Only
+// key-based authentication is supported; the client runs in batch mode and
+// never prompts. A key other than the default can be named with ?key=.
+//
+//	sftp://user@seedbox.example.com/home/user/magnet-list.json
+//	sftp://user@seedbox.example.com:2222/~/magnet-list.json?key=~/.ssh/seedbox
+type sftpStore struct {
+	remote string
+}
+
+// connection parses the URL into sftp arguments and the remote file path
+func (s sftpStore) connection() ([]string, string, error) {
+	u, err := url.Parse(s.remote)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid SFTP URL: %w", err)
+	}
+	if _, hasPassword := u.User.Password(); hasPassword {
+		return nil, "", fmt.Errorf("SFTP remotes use key-based authentication; remove the password from the URL")
+	}
+	if u.Hostname() == "" || u.Path == "" || strings.HasSuffix(u.Path, "/") {
+		return nil, "", fmt.Errorf("SFTP URL needs a host and a file path")
+	}
+	// sftp would read a leading dash as an option, such as -oProxyCommand
+	if strings.HasPrefix(u.Hostname(), "-") || strings.HasPrefix(u.User.Username(), "-") {
+		return nil, "", fmt.Errorf("SFTP host and user cannot start with -")
+	}
+
+	args := []string{"-b", "-", "-o", "BatchMode=yes"}
+	if port := u.Port(); port != "" {
+		args = append(args, "-P", port)
+	}
+	if key := u.Query().Get("key"); key != "" {
+		if rest, ok := strings.CutPrefix(key, "~/"); ok {
+			if homeDir, err := getHomeDir(); err == nil {
+				key = filepath.Join(homeDir, rest)
+			}
+		}
+		args = append(args, "-i", key)
+	}
+	target := u.Hostname()
+	if u.User != nil && u.User.Username() != "" {
+		target = u.User.Username() + "@" + target
+	}
+	args = append(args, "--", target)
+
+	// Paths under /~/ are relative to the login directory
+	remotePath := u.Path
+	if rest, ok := strings.CutPrefix(remotePath, "/~/"); ok {
+		remotePath = rest
+	}
+	return args, remotePath, nil
+}
+
+// run executes sftp batch commands against the remote host
+func (s sftpStore) run(commands ...string) error {
+	args, _, err := s.connection()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(sftpBinary, args...)
+	cmd.Stdin = strings.NewReader(strings.Join(commands, "\n") + "\n")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		if msg := bytes.TrimSpace(output); len(msg) > 0 {
+			return fmt.Errorf("sftp failed: %w: %s", err, bodySnippet(msg))
+		}
+		return fmt.Errorf("sftp failed: %w", err)
+	}
+	return nil
+}
+
+// sftpQuote quotes a path for an sftp batch command
+func sftpQuote(path string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(path) + `"`
+}
+
+// Fetch downloads the remote database into cache
+func (s sftpStore) Fetch(cache string) error {
+	_, remotePath, err := s.connection()
+	if err != nil {
+		return err
+	}
+	return s.run("get " + sftpQuote(remotePath) + " " + sftpQuote(cache))
+}
+
+// Upload writes cache to a temporary file beside the remote database and
+// renames it into place, so readers never see a partial file
+func (s sftpStore) Upload(cache string) error {
+	_, remotePath, err := s.connection()
+	if err != nil {
+		return err
+	}
+	tmp := remotePath + ".tmp"
+	return s.run(
+		"put "+sftpQuote(cache)+" "+sftpQuote(tmp),
+		"rename "+sftpQuote(tmp)+" "+sftpQuote(remotePath),
+	)
+}
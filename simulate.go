@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// simulateEnv names a scratch directory; when set, the handler runs in
+// simulation mode: Deluge and qBittorrent calls are answered locally and the databases are
+// written under that directory instead of the configured paths
+const simulateEnv = "MAGNET_HANDLER_SIMULATE"
+
+// simulateDeluge makes new Deluge and qBittorrent clients answer requests locally
+var simulateDeluge bool
+
+// selfTestTimeout bounds how long the spawned handler may run during a self-test
+var selfTestTimeout = 60 * time.Second
+
+// enableSimulation switches the process into simulation mode, redirecting
+// the local and remote databases into dir. Further remotes and read
+// fallbacks are dropped so nothing outside dir is read or written.
+func enableSimulation(config *Config, dir string) {
+	simulateDeluge = true
+	config.JSONPath = filepath.Join(dir, "magnet-list-local.json")
+	config.RemotePath = filepath.Join(dir, "magnet-list-remote.json")
+	config.RemotePaths = nil
+	config.ReadFallbacks = nil
+	config.MetadataTimeout = -1
+	log.Printf("SIMULATION MODE: torrent clients are not contacted, databases in %s", dir)
+}
+
+// simulatedResponse answers a Deluge RPC call the way a healthy server would
+func simulatedResponse(method string, params []interface{}) map[string]interface{} {
+	var result interface{}
+	switch method {
+	case "auth.login", "web.connected":
+		result = true
+	case "daemon.info":
+		result = "2.1.1"
+	case "core.get_enabled_plugins":
+		result = []interface{}{LabelPluginLabel}
+	case "core.add_torrent_magnet":
+		if len(params) > 0 {
+			uri, _ := params[0].(string)
+			result = ExtractMagnetHash(uri)
+		}
+	case "core.add_torrent_file":
+		if len(params) > 1 {
+			filedump, _ := params[1].(string)
+			data, _ := base64.StdEncoding.DecodeString(filedump)
+			if torrent, err := parseTorrent(data); err == nil {
+				result = torrent.Hash
+			}
+		}
+	}
+	return map[string]interface{}{"result": result, "error": nil, "id": 1}
+}
+
+// simulatedQBittorrentResponse answers a qBittorrent Web API call the way a
+// healthy server without the torrent would
+func simulatedQBittorrentResponse(method string) string {
+	if method == "torrents/info" {
+		return "[]"
+	}
+	return "Ok."
+}
+
+// selfTestMagnet builds a harmless magnet URI with a random info hash
+func selfTestMagnet() (string, string) {
+	b := make([]byte, 20)
+	rand.Read(b)
+	hash := fmt.Sprintf("%x", b)
+	return fmt.Sprintf("magnet:?xt=urn:btih:%s&dn=magnet-handler+self-test", hash), hash
+}
+
+// expandHandlerCommand splits a registered handler command line and
+// substitutes the URI for its placeholder (%u, %U, %1, or $1)
+func expandHandlerCommand(command, uri string) []string {
+	args := splitCommandLine(command)
+	replacer := strings.NewReplacer("%u", uri, "%U", uri, "%1", uri, "$1", uri)
+	for i, arg := range args {
+		args[i] = replacer.Replace(arg)
+	}
+	return args
+}
+
+// splitCommandLine splits a command line on whitespace, keeping double-quoted
+// arguments together
+func splitCommandLine(command string) []string {
+	var args []string
+	var current strings.Builder
+	inQuotes, hasArg := false, false
+	for _, r := range command {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+			hasArg = true
+		case (r == ' ' || r == '\t') && !inQuotes:
+			if hasArg {
+				args = append(args, current.String())
+				current.Reset()
+				hasArg = false
+			}
+		default:
+			current.WriteRune(r)
+			hasArg = true
+		}
+	}
+	if hasArg {
+		args = append(args, current.String())
+	}
+	return args
+}
+
+// RunHandlerSelfTest launches the registered handler command with a
+// synthesized magnet URI, the way the browser would, in simulation mode and
+// verifies the magnet reaches the database
+func RunHandlerSelfTest() error {
+	command, err := RegisteredHandlerCommand()
+	if err != nil {
+		return err
+	}
+
+	uri, hash := selfTestMagnet()
+	args := expandHandlerCommand(command, uri)
+	if len(args) == 0 {
+		return fmt.Errorf("registered command is empty")
+	}
+	log.Printf("Registered command: %s", command)
+
+	dir, err := os.MkdirTemp("", "magnet-handler-selftest")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	ctx, cancel := context.WithTimeout(context.Background(), selfTestTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Env = append(os.Environ(), simulateEnv+"="+dir)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		log.Printf("Handler output:\n%s", output)
+		return fmt.Errorf("handler did not run cleanly: %w", err)
+	}
+	log.Printf("✓ Handler launched with test magnet")
+
+	db, err := LoadJSONDatabase(filepath.Join(dir, "magnet-list-local.json"))
+	if err != nil {
+		return fmt.Errorf("failed to read simulated database: %w", err)
+	}
+	if _, ok := db.Added[hash]; !ok {
+		log.Printf("Handler output:\n%s", output)
+		return fmt.Errorf("test magnet %s was not recorded in the database", hash)
+	}
+	log.Printf("✓ Test magnet recorded in database")
+
+	return nil
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Test expandHandlerCommand for each platform's registered command format
+func TestExpandHandlerCommand(t *testing.T) {
+	uri := "magnet:?xt=urn:btih:abc&dn=Test"
+	tests := []struct {
+		name     string
+		command  string
+		expected []string
+	}{
+		{"linux desktop entry", "/usr/bin/magnet-handler %u", []string{"/usr/bin/magnet-handler", uri}},
+		{"windows registry", `"C:\Program Files\magnet-handler.exe" "%1"`, []string{`C:\Program Files\magnet-handler.exe`, uri}},
+		{"macos launcher", `"/Users/me/Applications/Magnet Handler.app/Contents/MacOS/launch" "$1"`, []string{"/Users/me/Applications/Magnet Handler.app/Contents/MacOS/launch", uri}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := expandHandlerCommand(tt.command, uri)
+			if strings.Join(args, "|") != strings.Join(tt.expected, "|") {
+				t.Errorf("expandHandlerCommand(%q) = %q, want %q", tt.command, args, tt.expected)
+			}
+		})
+	}
+}
+
+// Test simulation mode records a magnet without contacting Deluge
+func TestSimulatedAdd(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	defer func() { simulateDeluge = false }()
+
+	config := DefaultConfig()
+	config.DelugeHost = "192.0.2.1" // Unroutable, must never be contacted
+	enableSimulation(&config, tmpDir)
+
+	uri, hash := selfTestMagnet()
+	if err := AddMagnetToDeluge(uri, config); err != nil {
+		t.Fatalf("AddMagnetToDeluge failed: %v", err)
+	}
+
+	db, err := LoadJSONDatabase(config.JSONPath)
+	if err != nil {
+		t.Fatalf("Failed to load simulated database: %v", err)
+	}
+	entry, ok := db.Added[hash]
+	if !ok {
+		t.Fatal("Test magnet should be recorded as added")
+	}
+	if entry.TorrentID != hash {
+		t.Errorf("Expected simulated torrent ID %s, got %s", hash, entry.TorrentID)
+	}
+}
+
+// Test simulation mode keeps every database it touches inside its directory
+func TestEnableSimulationDropsOtherRemotes(t *testing.T) {
+	defer func() { simulateDeluge = false }()
+
+	config := DefaultConfig()
+	config.RemotePaths = []string{"/mnt/share/magnets.json", "sftp://nas/magnets.json"}
+	config.ReadFallbacks = []string{"remote", "/mnt/backup"}
+	enableSimulation(&config, "/tmp/mh-sim")
+
+	remotes := GetRemotePaths(&config)
+	if len(remotes) != 1 || remotes[0] != filepath.Join("/tmp/mh-sim", "magnet-list-remote.json") {
+		t.Errorf("Expected only the simulated remote, got %v", remotes)
+	}
+	if len(config.ReadFallbacks) != 0 {
+		t.Errorf("Expected no read fallbacks, got %v", config.ReadFallbacks)
+	}
+}
+
+// Test simulation mode answers for qBittorrent targets too, so a fanned-out
+// add never reaches a real client
+func TestSimulatedAddWithQBittorrentTarget(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	defer func() { simulateDeluge = false }()
+
+	config := DefaultConfig()
+	config.Targets = []TargetConfig{{Name: "seedbox", Type: TargetQBittorrent, URL: "http://192.0.2.1:8080"}}
+	enableSimulation(&config, tmpDir)
+
+	uri, hash := selfTestMagnet()
+	if err := AddMagnetToDeluge(uri, config); err != nil {
+		t.Fatalf("AddMagnetToDeluge failed: %v", err)
+	}
+
+	db, err := LoadJSONDatabase(config.JSONPath)
+	if err != nil {
+		t.Fatalf("Failed to load simulated database: %v", err)
+	}
+	if status := db.Added[hash].Targets["seedbox"]; status.Status != RetrySucceeded {
+		t.Errorf("Simulated qBittorrent target should accept the magnet, got %+v", status)
+	}
+}
+
+// Test the URI as received is kept alongside the normalized one
+func TestSimulatedAddKeepsRawURI(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	defer func() { simulateDeluge = false }()
+
+	config := DefaultConfig()
+	enableSimulation(&config, tmpDir)
+
+	uri, hash := selfTestMagnet()
+	raw := `"` + strings.Replace(uri, "magnet:?", "magnet://?", 1) + `"`
+	if err := AddMagnetToDeluge(raw, config); err != nil {
+		t.Fatalf("AddMagnetToDeluge failed: %v", err)
+	}
+
+	db, err := LoadJSONDatabase(config.JSONPath)
+	if err != nil {
+		t.Fatalf("Failed to load simulated database: %v", err)
+	}
+	entry := db.Added[hash]
+	if entry.URI != uri {
+		t.Errorf("Expected normalized URI %s, got %s", uri, entry.URI)
+	}
+	if entry.RawURI != raw {
+		t.Errorf("Expected raw URI %s, got %s", raw, entry.RawURI)
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// snapshotVersion is the format written by snapshot export
+const snapshotVersion = 1
+
+// SessionTorrent is one torrent's state in a session snapshot
+type SessionTorrent struct {
+	Hash      string   `json:"hash"`
+	Name      string   `json:"name"`
+	Label     string   `json:"label,omitempty"`
+	SavePath  string   `json:"save_path,omitempty"`
+	TotalSize int64    `json:"total_size"`
+	Progress  float64  `json:"progress"` // Percent downloaded when exported
+	State     string   `json:"state,omitempty"`
+	Trackers  []string `json:"trackers,omitempty"`
+	TimeAdded string   `json:"time_added,omitempty"`
+}
+
+// SessionSnapshot is a portable copy of Deluge's torrents for a label
+type SessionSnapshot struct {
+	Version  int              `json:"version"`
+	Created  string           `json:"created"`
+	Source   string           `json:"source"`          // Deluge host:port exported from
+	Label    string           `json:"label,omitempty"` // Empty when every label was exported
+	Torrents []SessionTorrent `json:"torrents"`
+}
+
+// sessionKeys are the status fields a snapshot records
+var sessionKeys = []string{"name", "hash", "save_path", "label", "total_size", "progress", "state", "trackers", "time_added"}
+
+// GetSessionTorrents retrieves the snapshot fields for every torrent
+func (c *DelugeClient) GetSessionTorrents() (map[string]map[string]interface{}, error) {
+	var torrents map[string]map[string]interface{}
+	if err := c.rpc("core.get_torrents_status", []interface{}{map[string]interface{}{}, sessionKeys}, &torrents); err != nil {
+		return nil, err
+	}
+	return torrents, nil
+}
+
+// sessionTorrents converts Deluge status maps to snapshot torrents, keeping
+// those with the label (all of them when allLabels is set), sorted by name
+func sessionTorrents(torrents map[string]map[string]interface{}, label string, allLabels bool) []SessionTorrent {
+	result := make([]SessionTorrent, 0, len(torrents))
+	for id, status := range torrents {
+		t := SessionTorrent{Hash: id}
+		if hash, _ := status["hash"].(string); hash != "" {
+			t.Hash = hash
+		}
+		t.Hash = strings.ToLower(t.Hash)
+		t.Name, _ = status["name"].(string)
+		t.Label, _ = status["label"].(string)
+		if !allLabels && t.Label != label {
+			continue
+		}
+		t.SavePath, _ = status["save_path"].(string)
+		t.State, _ = status["state"].(string)
+		t.Progress, _ = status["progress"].(float64)
+		if size, ok := status["total_size"].(float64); ok {
+			t.TotalSize = int64(size)
+		}
+		if added, ok := status["time_added"].(float64); ok && added > 0 {
+			t.TimeAdded = time.Unix(int64(added), 0).UTC().Format(time.RFC3339)
+		}
+		trackers, _ := status["trackers"].([]interface{})
+		for _, tracker := range trackers {
+			if info, ok := tracker.(map[string]interface{}); ok {
+				if url, _ := info["url"].(string); url != "" {
+					t.Trackers = append(t.Trackers, url)
+				}
+			}
+		}
+		result = append(result, t)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Name != result[j].Name {
+			return result[i].Name < result[j].Name
+		}
+		return result[i].Hash < result[j].Hash
+	})
+	return result
+}
+
+// entry builds the database entry for a snapshot torrent
+func (t SessionTorrent) entry() MagnetEntry {
+	return MagnetEntry{
+		UUID:        GenerateUUID(),
+		Title:       t.Name,
+		Hash:        t.Hash,
+		URI:         rebuildMagnetURI(t.Hash, t.Name, t.Trackers),
+		AddedDate:   time.Now().Format(time.RFC3339),
+		TorrentID:   t.Hash,
+		SavePath:    t.SavePath,
+		TorrentName: t.Name,
+		Label:       t.Label,
+	}
+}
+
+// loadSnapshot reads a snapshot file, rejecting formats newer than this build
+func loadSnapshot(path string) (*SessionSnapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var snapshot SessionSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot: %w", err)
+	}
+	if snapshot.Version > snapshotVersion {
+		return nil, fmt.Errorf("snapshot version %d is newer than this build supports (%d)", snapshot.Version, snapshotVersion)
+	}
+	return &snapshot, nil
+}
+
+// runSnapshot implements the snapshot command
+func runSnapshot(config Config, args []string) error {
+	if len(args) == 0 {
+		newCommandFlags(snapshotCommand).Usage()
+		return fmt.Errorf("expected a snapshot subcommand")
+	}
+
+	switch args[0] {
+	case "export":
+		return runSnapshotExport(config, args[1:])
+	default:
+		return fmt.Errorf("unknown snapshot subcommand %q", args[0])
+	}
+}
+
+// runSnapshotExport writes Deluge's torrents for the label to a file
+func runSnapshotExport(config Config, args []string) error {
+	fs := newCommandFlags(snapshotCommand)
+	allLabels := fs.Bool("all-labels", false, "Export every torrent, not just the configured label")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected the file to write")
+	}
+
+	client, err := connectDeluge(config)
+	if err != nil {
+		return err
+	}
+	torrents, err := client.GetSessionTorrents()
+	if err != nil {
+		return fmt.Errorf("failed to get torrents: %w", err)
+	}
+
+	snapshot := SessionSnapshot{
+		Version:  snapshotVersion,
+		Created:  time.Now().Format(time.RFC3339),
+		Source:   config.DelugeHost + ":" + config.DelugePort,
+		Torrents: sessionTorrents(torrents, config.DelugeLabel, *allLabels),
+	}
+	if !*allLabels {
+		snapshot.Label = config.DelugeLabel
+	}
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(fs.Arg(0), data, 0644); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+
+	log.Printf("✓ Exported %s to %s", plural(len(snapshot.Torrents), "torrent", "torrents"), fs.Arg(0))
+	return nil
+}
+
+var snapshotCommand = &Command{
+	Name:    "snapshot",
+	Usage:   "snapshot export [--all-labels] <file>",
+	Summary: "Export Deluge's torrents for the label to a portable file (restore --from re-adds them)",
+}
+
+func init() {
+	snapshotCommand.Run = runSnapshot
+	registerCommand(snapshotCommand)
+}
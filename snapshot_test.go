@@ -0,0 +1,72 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Test sessionTorrents converts Deluge's status fields and filters by label
+func TestSessionTorrents(t *testing.T) {
+	torrents := map[string]map[string]interface{}{
+		"aaa": {
+			"hash": "AAA", "name": "Book", "label": "audiobooks", "save_path": "/data/books",
+			"total_size": float64(1 << 30), "progress": float64(100), "state": "Seeding",
+			"time_added": float64(1700000000),
+			"trackers": []interface{}{
+				map[string]interface{}{"url": "udp://tracker.example:1337", "tier": float64(0)},
+				map[string]interface{}{"url": "", "tier": float64(1)},
+			},
+		},
+		"bbb": {"hash": "bbb", "name": "Episode", "label": "podcasts"},
+	}
+
+	got := sessionTorrents(torrents, "audiobooks", false)
+	if len(got) != 1 {
+		t.Fatalf("Expected 1 torrent for the label, got %+v", got)
+	}
+	book := got[0]
+	if book.Hash != "aaa" || book.SavePath != "/data/books" || book.TotalSize != 1<<30 || book.State != "Seeding" {
+		t.Errorf("Unexpected torrent: %+v", book)
+	}
+	if book.TimeAdded != "2023-11-14T22:13:20Z" {
+		t.Errorf("TimeAdded = %q", book.TimeAdded)
+	}
+	if len(book.Trackers) != 1 || book.Trackers[0] != "udp://tracker.example:1337" {
+		t.Errorf("Trackers = %v", book.Trackers)
+	}
+
+	if all := sessionTorrents(torrents, "audiobooks", true); len(all) != 2 || all[0].Name != "Book" {
+		t.Errorf("Expected both torrents sorted by name, got %+v", all)
+	}
+
+	entry := book.entry()
+	if entry.Hash != "aaa" || entry.Label != "audiobooks" || !strings.Contains(entry.URI, "tr=udp%3A%2F%2Ftracker.example%3A1337") {
+		t.Errorf("Unexpected entry: %+v", entry)
+	}
+}
+
+// Test loadSnapshot rejects snapshots written by a newer format
+func TestLoadSnapshot(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	path := filepath.Join(tmpDir, "snapshot.json")
+	os.WriteFile(path, []byte(`{"version":1,"source":"nas:8112","torrents":[{"hash":"aaa","name":"Book"}]}`), 0644)
+	snapshot, err := loadSnapshot(path)
+	if err != nil {
+		t.Fatalf("loadSnapshot failed: %v", err)
+	}
+	if len(snapshot.Torrents) != 1 || snapshot.Source != "nas:8112" {
+		t.Errorf("Unexpected snapshot: %+v", snapshot)
+	}
+
+	os.WriteFile(path, []byte(`{"version":99,"torrents":[]}`), 0644)
+	if _, err := loadSnapshot(path); err == nil || !strings.Contains(err.Error(), "newer") {
+		t.Errorf("Expected newer version error, got %v", err)
+	}
+}
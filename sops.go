@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// sopsBinary is the sops binary that decrypts and updates an encrypted config
+var sopsBinary = "sops"
+
+// sopsEncrypted reports whether a config file was encrypted by SOPS, which
+// adds a top-level sops object holding the keys and MAC
+func sopsEncrypted(data []byte) bool {
+	var doc struct {
+		Sops *struct {
+			MAC     string `json:"mac"`
+			Version string `json:"version"`
+		} `json:"sops"`
+	}
+	return json.Unmarshal(data, &doc) == nil && doc.Sops != nil && (doc.Sops.MAC != "" || doc.Sops.Version != "")
+}
+
+// runSops runs sops on a JSON config file (whatever its extension) and
+// returns its output
+func runSops(args ...string) ([]byte, error) {
+	args = append([]string{args[0], "--input-type", "json", "--output-type", "json"}, args[1:]...)
+	var stderr bytes.Buffer
+	cmd := exec.Command(sopsBinary, args...)
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("sops %s failed: %v: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return output, nil
+}
+
+// decryptConfig returns the plaintext of a SOPS-encrypted config file
+func decryptConfig(path string) ([]byte, error) {
+	return runSops("--decrypt", path)
+}
+
+// parseConfig parses a config file, decrypting it first when SOPS encrypted it
+func parseConfig(path string, data []byte) (Config, error) {
+	if sopsEncrypted(data) {
+		plain, err := decryptConfig(path)
+		if err != nil {
+			return DefaultConfig(), err
+		}
+		data = plain
+	}
+	config, err := decodeConfig(data)
+	if err != nil {
+		return DefaultConfig(), err
+	}
+	return config, nil
+}
+
+// encryptedConfigPath returns the config file LoadConfig reads when SOPS
+// encrypted it, so saves can keep it encrypted
+func encryptedConfigPath(homeDir string) (string, bool) {
+	for _, path := range []string{
+		filepath.Join(homeDir, ".magnet-handler", "mh.yaml"),
+		filepath.Join(homeDir, ".magnet-handler.conf"),
+	} {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		return path, sopsEncrypted(data)
+	}
+	return "", false
+}
+
+// sopsChange is one top-level config key to set (or unset, when Value is
+// nil) in an encrypted file
+type sopsChange struct {
+	Key   string
+	Value json.RawMessage
+}
+
+// sopsChanges compares the decrypted config with the one being saved and
+// returns the top-level keys that differ, in order
+func sopsChanges(current, next []byte) ([]sopsChange, error) {
+	var before, after map[string]json.RawMessage
+	if err := json.Unmarshal(current, &before); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(next, &after); err != nil {
+		return nil, err
+	}
+	delete(before, "sops")
+
+	var changes []sopsChange
+	for key, value := range after {
+		if old, ok := before[key]; !ok || !jsonEqual(old, value) {
+			changes = append(changes, sopsChange{Key: key, Value: value})
+		}
+	}
+	for key := range before {
+		if _, ok := after[key]; !ok {
+			changes = append(changes, sopsChange{Key: key})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	return changes, nil
+}
+
+// jsonEqual reports whether two JSON values are the same ignoring whitespace
+func jsonEqual(a, b json.RawMessage) bool {
+	var ca, cb bytes.Buffer
+	if json.Compact(&ca, a) != nil || json.Compact(&cb, b) != nil {
+		return bytes.Equal(a, b)
+	}
+	return bytes.Equal(ca.Bytes(), cb.Bytes())
+}
+
+// saveSOPSConfig saves a config into a SOPS-encrypted file key by key with
+// sops set and unset, so it stays encrypted with the keys it already has
+func saveSOPSConfig(path string, config Config) error {
+	current, err := decryptConfig(path)
+	if err != nil {
+		return err
+	}
+	next, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+	changes, err := sopsChanges(current, next)
+	if err != nil {
+		return fmt.Errorf("failed to compare configs: %w", err)
+	}
+
+	for _, change := range changes {
+		key, _ := json.Marshal(change.Key)
+		index := "[" + string(key) + "]"
+		if change.Value == nil {
+			_, err = runSops("unset", path, index)
+		} else {
+			_, err = runSops("set", path, index, string(change.Value))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
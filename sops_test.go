@@ -0,0 +1,102 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// fakeSops installs a stand-in sops that "decrypts" a file by printing the
+// file beside it with a .plain suffix and logs every set and unset
+func fakeSops(t *testing.T, tmpDir string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("Fake sops is a shell script")
+	}
+	logPath := filepath.Join(tmpDir, "sops.log")
+	script := `#!/bin/sh
+op=$1; shift 5
+case "$op" in
+--decrypt) cat "$1.plain";;
+set|unset) echo "$op $*" >> "` + logPath + `";;
+*) exit 2;;
+esac
+`
+	binary := filepath.Join(tmpDir, "sops")
+	if err := os.WriteFile(binary, []byte(script), 0755); err != nil {
+		t.Fatalf("Failed to write fake sops: %v", err)
+	}
+	original := sopsBinary
+	sopsBinary = binary
+	t.Cleanup(func() { sopsBinary = original })
+	return logPath
+}
+
+// Test an encrypted config is decrypted on load and updated key by key on
+// save instead of being overwritten in plaintext
+func TestSOPSConfig(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	t.Setenv("HOME", tmpDir)
+	logPath := fakeSops(t, tmpDir)
+
+	configPath := filepath.Join(tmpDir, ".magnet-handler.conf")
+	encrypted := `{"deluge_password": "ENC[AES256_GCM,data:x]", "deluge_label": "ENC[AES256_GCM,data:y]", "sops": {"mac": "ENC[...]", "version": "3.9.0"}}`
+	plain := `{"deluge_host": "10.0.0.2", "deluge_port": "8112", "deluge_password": "s3cret", "deluge_label": "books", "json_path": "db.json"}`
+	if err := os.WriteFile(configPath, []byte(encrypted), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	if err := os.WriteFile(configPath+".plain", []byte(plain), 0600); err != nil {
+		t.Fatalf("Failed to write plaintext: %v", err)
+	}
+
+	config, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if config.DelugePassword != "s3cret" || config.DelugeLabel != "books" {
+		t.Fatalf("Expected the decrypted config, got %+v", config)
+	}
+
+	config.DeviceID = "laptop"
+	config.DelugeLabel = ""
+	if err := SaveConfig(config); err != nil {
+		t.Fatalf("SaveConfig failed: %v", err)
+	}
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("Expected sops to be called: %v", err)
+	}
+	want := `set ` + configPath + ` ["deluge_label"] ""` + "\n" + `set ` + configPath + ` ["device_id"] "laptop"` + "\n"
+	if string(data) != want {
+		t.Errorf("Expected only changed keys to be set, got:\n%s\nwant:\n%s", data, want)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, ".magnet-handler", "mh.yaml")); !os.IsNotExist(err) {
+		t.Error("Saving must not write a plaintext mh.yaml beside the encrypted config")
+	}
+}
+
+// Test the keys to update are found by comparing values, not formatting
+func TestSOPSChanges(t *testing.T) {
+	current := `{"a": 1, "b": {"x": [1, 2]}, "gone": true, "sops": {"mac": "m"}}`
+	next := `{"a":1,"b":{"x":[1,2,3]},"new":"v"}`
+	changes, err := sopsChanges([]byte(current), []byte(next))
+	if err != nil {
+		t.Fatalf("sopsChanges failed: %v", err)
+	}
+	var got []string
+	for _, change := range changes {
+		got = append(got, change.Key+"="+string(change.Value))
+	}
+	if strings.Join(got, " ") != `b={"x":[1,2,3]} gone= new="v"` {
+		t.Errorf("Unexpected changes: %v", got)
+	}
+	if !sopsEncrypted([]byte(current)) || sopsEncrypted([]byte(next)) {
+		t.Error("Only files with a sops object are encrypted")
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Completion sorting modes
+const (
+	SortMove     = "move"     // Deluge moves the data; the torrent keeps seeding from the new folder
+	SortHardlink = "hardlink" // Files are hardlinked locally; the torrent keeps its original folder
+)
+
+// defaultSortLayout is the folder layout used when none is configured
+const defaultSortLayout = "{label}/{year}-{month}"
+
+// SortConfig describes how completed downloads are filed into dated folders.
+// Layout placeholders are {label}, {year}, and {month}, taken from the
+// entry's label and the time the download completed.
+type SortConfig struct {
+	Root   string `json:"root"`             // Folder the layout is created under, as Deluge sees it
+	Layout string `json:"layout,omitempty"` // Default {label}/{year}-{month}
+	Mode   string `json:"mode,omitempty"`   // move (default) or hardlink
+}
+
+// mode returns the configured sorting mode or the default
+func (s SortConfig) mode() string {
+	if s.Mode == "" {
+		return SortMove
+	}
+	return s.Mode
+}
+
+// destination returns the folder a download with label, completed at the
+// given time, is sorted into
+func (s SortConfig) destination(label string, completed time.Time) string {
+	layout := s.Layout
+	if layout == "" {
+		layout = defaultSortLayout
+	}
+	if label == "" {
+		label = "unlabeled"
+	}
+	replacer := strings.NewReplacer(
+		"{label}", strings.NewReplacer("/", "_", "\\", "_").Replace(label),
+		"{year}", completed.Format("2006"),
+		"{month}", completed.Format("01"),
+	)
+	return path.Join(s.Root, replacer.Replace(layout))
+}
+
+// MoveStorage asks Deluge to move a torrent's data to dest
+func (c *DelugeClient) MoveStorage(torrentID, dest string) error {
+	return c.rpc("core.move_storage", []interface{}{[]string{torrentID}, dest}, nil)
+}
+
+// sortCompleted files a newly completed download into its dated folder.
+// status must hold the torrent's name and save_path.
+func sortCompleted(client *DelugeClient, config Config, entry *MagnetEntry, status map[string]interface{}) error {
+	sorting := *config.SortCompleted
+	name, _ := status["name"].(string)
+	savePath, _ := status["save_path"].(string)
+	if name == "" || savePath == "" {
+		return fmt.Errorf("Deluge did not report the name and save path")
+	}
+
+	completed := parseTimestamp(entry.CompletedAt)
+	if completed.IsZero() {
+		completed = time.Now()
+	}
+	dest := sorting.destination(entryLabel(*entry, config), completed)
+
+	switch sorting.mode() {
+	case SortMove:
+		if path.Clean(savePath) == dest {
+			return nil
+		}
+		torrentID := entry.TorrentID
+		if torrentID == "" {
+			torrentID = entry.Hash
+		}
+		if err := client.MoveStorage(torrentID, dest); err != nil {
+			return fmt.Errorf("failed to move storage: %w", err)
+		}
+		entry.SavePath = dest
+	case SortHardlink:
+		if err := hardlinkTree(filepath.Join(savePath, name), filepath.Join(dest, name)); err != nil {
+			return fmt.Errorf("failed to hardlink: %w", err)
+		}
+	default:
+		return fmt.Errorf("unknown sort mode %q (use %s or %s)", sorting.Mode, SortMove, SortHardlink)
+	}
+
+	entry.SortedTo = dest
+	entry.recordHistory(HistorySorted, "", dest)
+	log.Printf("✓ Sorted into %s: %s", dest, entry.Title)
+	return nil
+}
+
+// hardlinkTree recreates src at dest with every file hardlinked rather than
+// copied. Files already present at dest are left alone.
+func hardlinkTree(src, dest string) error {
+	return filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dest, rel)
+		if d.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err := os.Link(p, target); err != nil && !os.IsExist(err) {
+			return err
+		}
+		return nil
+	})
+}
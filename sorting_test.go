@@ -0,0 +1,89 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Test destination folders follow the layout
+func TestSortDestination(t *testing.T) {
+	completed := time.Date(2024, 3, 9, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		sorting  SortConfig
+		label    string
+		expected string
+	}{
+		{"default layout", SortConfig{Root: "/media"}, "audiobooks", "/media/audiobooks/2024-03"},
+		{"custom layout", SortConfig{Root: "/media", Layout: "{year}/{label}"}, "movies", "/media/2024/movies"},
+		{"no label", SortConfig{Root: "/media"}, "", "/media/unlabeled/2024-03"},
+		{"label with slash", SortConfig{Root: "/media"}, "a/b", "/media/a_b/2024-03"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.sorting.destination(tt.label, completed); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+// Test move mode calls move_storage and updates the save path
+func TestSortCompletedMove(t *testing.T) {
+	var moved []interface{}
+	client := fakeDeluge(t, func(method string, params []interface{}) (interface{}, interface{}) {
+		if method == "core.move_storage" {
+			moved = params
+		}
+		return nil, nil
+	})
+
+	config := Config{DelugeLabel: "audiobooks", SortCompleted: &SortConfig{Root: "/media"}}
+	entry := MagnetEntry{Hash: "abc", Title: "Book", CompletedAt: "2024-03-09T12:00:00Z"}
+	status := map[string]interface{}{"name": "Book", "save_path": "/downloads"}
+	if err := sortCompleted(client, config, &entry, status); err != nil {
+		t.Fatalf("sortCompleted failed: %v", err)
+	}
+
+	if len(moved) != 2 || moved[1] != "/media/audiobooks/2024-03" {
+		t.Errorf("Unexpected move_storage params: %v", moved)
+	}
+	if entry.SavePath != "/media/audiobooks/2024-03" || entry.SortedTo != entry.SavePath {
+		t.Errorf("Entry not updated: save_path=%q sorted_to=%q", entry.SavePath, entry.SortedTo)
+	}
+}
+
+// Test hardlink mode links files without moving the torrent
+func TestSortCompletedHardlink(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	downloads := filepath.Join(tmpDir, "downloads")
+	os.MkdirAll(filepath.Join(downloads, "Book", "disc1"), 0755)
+	os.WriteFile(filepath.Join(downloads, "Book", "disc1", "01.mp3"), []byte("audio"), 0644)
+
+	client := fakeDeluge(t, func(method string, params []interface{}) (interface{}, interface{}) {
+		t.Errorf("Hardlink mode should not call Deluge, got %s", method)
+		return nil, nil
+	})
+	config := Config{SortCompleted: &SortConfig{Root: filepath.ToSlash(filepath.Join(tmpDir, "media")), Mode: SortHardlink}}
+	entry := MagnetEntry{Hash: "abc", Title: "Book", Label: "audiobooks", SavePath: downloads, CompletedAt: "2024-03-09T12:00:00Z"}
+	status := map[string]interface{}{"name": "Book", "save_path": downloads}
+	if err := sortCompleted(client, config, &entry, status); err != nil {
+		t.Fatalf("sortCompleted failed: %v", err)
+	}
+
+	linked := filepath.Join(tmpDir, "media", "audiobooks", "2024-03", "Book", "disc1", "01.mp3")
+	if data, err := os.ReadFile(linked); err != nil || string(data) != "audio" {
+		t.Errorf("Expected hardlinked file at %s: %v", linked, err)
+	}
+	if entry.SavePath != downloads {
+		t.Errorf("Hardlink mode should keep the save path, got %q", entry.SavePath)
+	}
+}
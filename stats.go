@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// SectionCounts is how many entries each section held at one moment
+type SectionCounts struct {
+	Added   int `json:"added"`
+	Retry   int `json:"retry"`
+	Removed int `json:"removed"`
+}
+
+// PeriodStats summarizes what happened to the database between two times
+type PeriodStats struct {
+	From       string        `json:"from"`
+	To         string        `json:"to"`
+	Start      SectionCounts `json:"start"`      // Sections as of From
+	End        SectionCounts `json:"end"`        // Sections as of To
+	Added      int           `json:"added"`      // Entries first added in the period
+	Completed  int           `json:"completed"`  // Downloads finished in the period
+	Removed    int           `json:"removed"`    // Entries removed in the period
+	Operations int           `json:"operations"` // Oplog operations recorded in the period
+}
+
+// countSections counts the entries in each section
+func countSections(db *MagnetDatabase) SectionCounts {
+	return SectionCounts{Added: len(db.Added), Retry: len(db.Retry), Removed: len(db.Removed)}
+}
+
+// periodStats reconstructs the database at both ends of the period and
+// counts what changed in between
+func periodStats(current *MagnetDatabase, ops []journalOp, from, to time.Time) PeriodStats {
+	stats := PeriodStats{From: from.Format(time.RFC3339), To: to.Format(time.RFC3339)}
+	stats.Start = countSections(databaseAsOf(current, ops, from))
+	end := databaseAsOf(current, ops, to)
+	stats.End = countSections(end)
+
+	within := func(value string) bool {
+		t := parseTimestamp(value)
+		return !t.IsZero() && !t.Before(from) && !t.After(to)
+	}
+	for _, entries := range []map[string]MagnetEntry{end.Added, end.Retry, end.Removed} {
+		for _, entry := range entries {
+			if within(entry.AddedDate) {
+				stats.Added++
+			}
+			if within(entry.CompletedAt) {
+				stats.Completed++
+			}
+		}
+	}
+	for _, entry := range end.Removed {
+		if within(entry.RemovedAt) {
+			stats.Removed++
+		}
+	}
+	for _, op := range ops {
+		if within(op.At) {
+			stats.Operations++
+		}
+	}
+	return stats
+}
+
+// writePeriodStats prints the period's activity and how each section changed
+func writePeriodStats(w io.Writer, stats PeriodStats) error {
+	fmt.Fprintf(w, "Between %s and %s:\n", stats.From, stats.To)
+	fmt.Fprintf(w, "  Added:      %d\n", stats.Added)
+	fmt.Fprintf(w, "  Completed:  %d\n", stats.Completed)
+	fmt.Fprintf(w, "  Removed:    %d\n", stats.Removed)
+	fmt.Fprintf(w, "  Operations: %d logged\n\n", stats.Operations)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SECTION\tSTART\tEND\tCHANGE")
+	for _, row := range []struct {
+		name       string
+		start, end int
+	}{
+		{SectionAdded, stats.Start.Added, stats.End.Added},
+		{SectionRetry, stats.Start.Retry, stats.End.Retry},
+		{SectionRemoved, stats.Start.Removed, stats.End.Removed},
+	} {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%+d\n", row.name, row.start, row.end, row.end-row.start)
+	}
+	return tw.Flush()
+}
+
+// runStats implements the stats command
+func runStats(config Config, args []string) error {
+	fs := newCommandFlags(statsCommand)
+	between := fs.String("between", "", "Period start; the end follows as an argument, or use FROM..TO (default: the last 30 days)")
+	asJSON := fs.Bool("json", false, "Print the statistics as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	to := time.Now()
+	from := to.AddDate(0, 0, -30)
+	if *between != "" {
+		first, last, ok := strings.Cut(*between, "..")
+		if !ok {
+			if fs.NArg() != 1 {
+				fs.Usage()
+				return fmt.Errorf("expected --between FROM TO")
+			}
+			last = fs.Arg(0)
+		}
+		var err error
+		if from, err = parseQueryTime(first, false); err != nil {
+			return err
+		}
+		if to, err = parseQueryTime(last, true); err != nil {
+			return err
+		}
+		if to.Before(from) {
+			return fmt.Errorf("period ends before it starts")
+		}
+	}
+
+	db, err := LoadJSONDatabase(config.JSONPath)
+	if err != nil {
+		return fmt.Errorf("failed to load database: %w", err)
+	}
+	ops, err := readOplog(config.JSONPath)
+	if err != nil {
+		return fmt.Errorf("failed to read operation log: %w", err)
+	}
+	stats := periodStats(db, ops, from, to)
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(stats)
+	}
+	return writePeriodStats(os.Stdout, stats)
+}
+
+var statsCommand = &Command{
+	Name:    "stats",
+	Usage:   "stats [--between FROM TO] [--json]",
+	Summary: "Count what was added, completed, and removed in a period, reconstructing the database at both ends",
+}
+
+func init() {
+	statsCommand.Run = runStats
+	registerCommand(statsCommand)
+}
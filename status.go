@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// plural formats a count with a singular or plural noun
+func plural(n int, singular, pluralForm string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, singular)
+	}
+	return fmt.Sprintf("%d %s", n, pluralForm)
+}
+
+// recentFailures counts retry entries whose last attempt was within window
+func recentFailures(db *MagnetDatabase, now time.Time, window time.Duration) int {
+	failures := 0
+	for _, entry := range db.Retry {
+		attempted, err := time.Parse(time.RFC3339, entry.LastAttempt)
+		if err == nil && now.Sub(attempted) <= window {
+			failures++
+		}
+	}
+	return failures
+}
+
+// delugeReachability describes whether Deluge is accepting logins, without
+// counting a failure against the auth breaker
+func delugeReachability(config Config) string {
+	if AuthBreakerOpen(config) {
+		return "Deluge logins paused after repeated password failures"
+	}
+	client := NewDelugeClient(config.DelugeHost, config.DelugePort, config.DelugePassword)
+	err := client.Authenticate()
+	switch {
+	case err == nil:
+		return "Deluge reachable"
+	case errors.Is(err, errAuthRejected):
+		return "Deluge rejecting the password"
+	default:
+		return "Deluge unreachable"
+	}
+}
+
+// statusSummary builds the one-sentence status read out by voice assistants
+func statusSummary(db *MagnetDatabase, reachability string, now time.Time) string {
+	queued := "nothing queued"
+	if len(db.Retry) > 0 {
+		queued = plural(len(db.Retry), "item", "items") + " queued"
+	}
+	failures := plural(recentFailures(db, now, 24*time.Hour), "failure", "failures") + " in the last day"
+	sentence := fmt.Sprintf("%s, %s, %s.", queued, failures, reachability)
+	return strings.ToUpper(sentence[:1]) + sentence[1:]
+}
+
+// handleStatusSummary returns a plain-text status sentence for TTS routines
+func (s *apiServer) handleStatusSummary(w http.ResponseWriter, r *http.Request) {
+	db, err := LoadJSONDatabase(s.config.JSONPath)
+	if err != nil {
+		http.Error(w, "The magnet database could not be read.", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintln(w, statusSummary(db, delugeReachability(s.config), time.Now()))
+}
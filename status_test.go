@@ -0,0 +1,52 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// Test statusSummary reads naturally
+func TestStatusSummary(t *testing.T) {
+	now := time.Date(2024, 3, 2, 12, 0, 0, 0, time.UTC)
+	recent := now.Add(-2 * time.Hour).Format(time.RFC3339)
+	old := now.Add(-48 * time.Hour).Format(time.RFC3339)
+
+	tests := []struct {
+		name     string
+		retry    map[string]MagnetEntry
+		expected string
+	}{
+		{"empty", map[string]MagnetEntry{}, "Nothing queued, 0 failures in the last day, Deluge reachable."},
+		{"one recent", map[string]MagnetEntry{"a": {LastAttempt: recent}}, "1 item queued, 1 failure in the last day, Deluge reachable."},
+		{"mixed", map[string]MagnetEntry{"a": {LastAttempt: recent}, "b": {LastAttempt: recent}, "c": {LastAttempt: old}}, "3 items queued, 2 failures in the last day, Deluge reachable."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &MagnetDatabase{Added: map[string]MagnetEntry{}, Retry: tt.retry}
+			if got := statusSummary(db, "Deluge reachable", now); got != tt.expected {
+				t.Errorf("statusSummary() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+// Test the summary endpoint returns plain text
+func TestStatusSummaryEndpoint(t *testing.T) {
+	server, _ := newConfiguredAPIServer(t, func(config *Config) {
+		config.DelugeHost, config.DelugePort = "127.0.0.1", "1"
+	})
+
+	resp := apiRequest(t, http.MethodGet, server.URL+"/status/summary", nil)
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.Header.Get("Content-Type") != "text/plain; charset=utf-8" {
+		t.Errorf("Expected plain text, got %s", resp.Header.Get("Content-Type"))
+	}
+	expected := "1 item queued, 0 failures in the last day, Deluge unreachable.\n"
+	if string(body) != expected {
+		t.Errorf("Expected %q, got %q", expected, body)
+	}
+}
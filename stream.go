@@ -0,0 +1,326 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Detected on-disk database formats
+const (
+	formatCurrent = "current"
+	formatV0      = "v0" // Python version: flat map of hash -> entry
+	formatV1      = "v1" // First Go version: added/retry without metadata or IDs
+)
+
+// streamBufferSize is the read/write buffer used for database files
+const streamBufferSize = 1 << 20
+
+// progressInterval is how many entries pass between progress callbacks
+const progressInterval = 10000
+
+// sectionTombstones is the on-disk key for deletion tombstones
+const sectionTombstones = "tombstones"
+
+// databaseSection is a named map of entries in the on-disk layout
+type databaseSection struct {
+	name      string
+	entries   map[string]MagnetEntry
+	omitEmpty bool
+}
+
+// databaseSections lists the entry sections in the order they are serialized,
+// matching the field order of MagnetDatabase
+func databaseSections(db *MagnetDatabase) []databaseSection {
+	return []databaseSection{
+		{SectionAdded, db.Added, false},
+		{SectionRetry, db.Retry, false},
+		{SectionRemoved, db.Removed, true},
+	}
+}
+
+// convertV0 converts a Python-era entry to the current format
+func convertV0(hash string, v0 MagnetEntryV0, id int64) MagnetEntry {
+	// Use URI from V0 if present, otherwise construct from hash
+	uri := v0.URI
+	if uri == "" {
+		uri = fmt.Sprintf("magnet:?xt=urn:btih:%s", v0.Hash)
+	}
+
+	return MagnetEntry{
+		UUID:          GenerateUUID(),
+		ID:            id,
+		Title:         v0.Title,
+		Hash:          v0.Hash,
+		URI:           uri,
+		AddedDate:     v0.FirstSeen,
+		FirstSeen:     v0.FirstSeen,
+		LastAttempt:   v0.LastAttempt,
+		Status:        v0.Status,
+		TorrentID:     v0.TorrentID,
+		AddedToDeluge: v0.AddedToDeluge,
+		SavePath:      v0.SavePath,
+		TorrentName:   v0.TorrentName,
+		State:         v0.State,
+		Progress:      v0.Progress,
+	}
+}
+
+// expectDelim reads the next token and checks it is the given delimiter
+func expectDelim(dec *json.Decoder, want json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != want {
+		return fmt.Errorf("expected %q, got %v", want, tok)
+	}
+	return nil
+}
+
+// decodeDatabase streams a database of any supported format into db. Entries
+// are decoded and converted one at a time, so neither the raw file nor an
+// intermediate legacy structure is held in memory alongside the result.
+func decodeDatabase(r io.Reader, db *MagnetDatabase, progress func(entries int)) (string, error) {
+	dec := json.NewDecoder(bufio.NewReaderSize(r, streamBufferSize))
+	if err := expectDelim(dec, '{'); err != nil {
+		return "", err
+	}
+
+	count := 0
+	report := func() {
+		count++
+		if progress != nil && count%progressInterval == 0 {
+			progress(count)
+		}
+	}
+
+	hasMetadata := false
+	hasSections := false
+	nextV0ID := int64(1)
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return "", err
+		}
+		key, _ := tok.(string)
+
+		var target map[string]MagnetEntry
+		switch key {
+		case "metadata":
+			hasMetadata = true
+			if err := dec.Decode(&db.Metadata); err != nil {
+				return "", fmt.Errorf("metadata: %w", err)
+			}
+			continue
+		case SectionAdded:
+			target = db.Added
+		case SectionRetry:
+			target = db.Retry
+		case SectionRemoved:
+			if db.Removed == nil {
+				db.Removed = make(map[string]MagnetEntry)
+			}
+			target = db.Removed
+		case sectionTombstones:
+			hasSections = true
+			if err := dec.Decode(&db.Tombstones); err != nil {
+				return "", fmt.Errorf("%s: %w", key, err)
+			}
+			continue
+		default:
+			if db.Metadata.SchemaVersion > currentSchemaVersion {
+				// Sections from a newer version; the caller refuses the file
+				var skip json.RawMessage
+				if err := dec.Decode(&skip); err != nil {
+					return "", err
+				}
+				continue
+			}
+			// V0: the key is an info hash
+			var v0 MagnetEntryV0
+			if err := dec.Decode(&v0); err != nil {
+				return "", fmt.Errorf("entry %s: %w", key, err)
+			}
+			db.Added[key] = convertV0(key, v0, nextV0ID)
+			nextV0ID++
+			report()
+			continue
+		}
+
+		hasSections = true
+		if err := decodeSection(dec, target, report); err != nil {
+			return "", fmt.Errorf("%s: %w", key, err)
+		}
+	}
+	if err := expectDelim(dec, '}'); err != nil {
+		return "", err
+	}
+
+	switch {
+	case nextV0ID > 1 && (hasSections || hasMetadata):
+		return "", fmt.Errorf("mixed legacy and current keys")
+	case nextV0ID > 1:
+		db.Metadata.LastSequence = nextV0ID - 1
+		return formatV0, nil
+	case !hasMetadata && (len(db.Added) > 0 || len(db.Retry) > 0):
+		return formatV1, nil
+	default:
+		return formatCurrent, nil
+	}
+}
+
+// decodeSection streams one hash -> entry object into target
+func decodeSection(dec *json.Decoder, target map[string]MagnetEntry, report func()) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok == nil {
+		return nil // null section
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '{' {
+		return fmt.Errorf("expected object, got %v", tok)
+	}
+	for dec.More() {
+		keyTok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		hash, _ := keyTok.(string)
+		var entry MagnetEntry
+		if err := dec.Decode(&entry); err != nil {
+			return fmt.Errorf("entry %s: %w", hash, err)
+		}
+		target[hash] = entry
+		report()
+	}
+	return expectDelim(dec, '}')
+}
+
+// writeDatabaseJSON streams the database as JSON one entry at a time. The
+// output is byte-for-byte identical to json.Marshal, or json.MarshalIndent
+// with a two-space indent, without ever buffering the whole document.
+func writeDatabaseJSON(w io.Writer, db *MagnetDatabase, indent bool, progress func(entries int)) error {
+	bw := bufio.NewWriterSize(w, streamBufferSize)
+
+	newline := func(depth int) string {
+		if !indent {
+			return ""
+		}
+		return "\n" + strings.Repeat("  ", depth)
+	}
+	colon := ":"
+	if indent {
+		colon = ": "
+	}
+	marshal := func(v interface{}, depth int) ([]byte, error) {
+		if indent {
+			return json.MarshalIndent(v, strings.Repeat("  ", depth), "  ")
+		}
+		return json.Marshal(v)
+	}
+
+	metadata, err := marshal(db.Metadata, 1)
+	if err != nil {
+		return err
+	}
+	bw.WriteString("{" + newline(1) + `"metadata"` + colon)
+	bw.Write(metadata)
+
+	count := 0
+	for _, section := range databaseSections(db) {
+		if section.omitEmpty && len(section.entries) == 0 {
+			continue
+		}
+		bw.WriteString("," + newline(1) + `"` + section.name + `"` + colon)
+		if section.entries == nil {
+			bw.WriteString("null")
+			continue
+		}
+		if len(section.entries) == 0 {
+			bw.WriteString("{}")
+			continue
+		}
+
+		hashes := make([]string, 0, len(section.entries))
+		for hash := range section.entries {
+			hashes = append(hashes, hash)
+		}
+		sort.Strings(hashes)
+
+		bw.WriteString("{")
+		for i, hash := range hashes {
+			key, err := json.Marshal(hash)
+			if err != nil {
+				return err
+			}
+			value, err := marshal(section.entries[hash], 2)
+			if err != nil {
+				return err
+			}
+			if i > 0 {
+				bw.WriteString(",")
+			}
+			bw.WriteString(newline(2))
+			bw.Write(key)
+			bw.WriteString(colon)
+			if _, err := bw.Write(value); err != nil {
+				return err
+			}
+
+			count++
+			if progress != nil && count%progressInterval == 0 {
+				progress(count)
+			}
+		}
+		bw.WriteString(newline(1) + "}")
+	}
+
+	// Tombstones are small, so they are written in one piece
+	if len(db.Tombstones) > 0 {
+		tombstones, err := marshal(db.Tombstones, 1)
+		if err != nil {
+			return err
+		}
+		bw.WriteString("," + newline(1) + `"` + sectionTombstones + `"` + colon)
+		bw.Write(tombstones)
+	}
+	bw.WriteString(newline(0) + "}")
+
+	return bw.Flush()
+}
+
+// writeDatabaseFile streams the database to path via a temp file and atomic rename
+func writeDatabaseFile(path string, db *MagnetDatabase, progress func(entries int)) error {
+	if err := guardOverwrite(path); err != nil {
+		return err
+	}
+
+	tempPath := path + ".tmp"
+	file, err := os.OpenFile(tempPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	if err := writeDatabaseJSON(file, db, true, progress); err != nil {
+		file.Close()
+		os.Remove(tempPath)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+
+	// Atomic rename
+	if err := os.Rename(tempPath, path); err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+	return nil
+}
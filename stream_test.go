@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// populatedDatabase returns a database with every map field holding entries,
+// so serialization tests notice sections the streaming writer doesn't know about
+func populatedDatabase() *MagnetDatabase {
+	db := &MagnetDatabase{
+		Metadata: DatabaseMetadata{LastSequence: 3, LastModified: "2024-01-01T00:00:00Z", Checksum: "abc"},
+	}
+	v := reflect.ValueOf(db).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		if field.Kind() != reflect.Map {
+			continue
+		}
+		field.Set(reflect.MakeMap(field.Type()))
+		for _, key := range []string{"b<hash>", "a&hash"} {
+			value := reflect.New(field.Type().Elem()).Elem()
+			if entry, ok := value.Addr().Interface().(*MagnetEntry); ok {
+				*entry = MagnetEntry{ID: 1, Hash: key, Title: "Title \"quoted\" <b>", Progress: 12.5}
+			}
+			field.SetMapIndex(reflect.ValueOf(key), value)
+		}
+	}
+	return db
+}
+
+// Test the streaming writer matches encoding/json output exactly
+func TestWriteDatabaseJSONMatchesEncodingJSON(t *testing.T) {
+	databases := map[string]*MagnetDatabase{
+		"populated": populatedDatabase(),
+		"empty":     {Added: map[string]MagnetEntry{}, Retry: map[string]MagnetEntry{}},
+		"nil maps":  {},
+	}
+
+	for name, db := range databases {
+		t.Run(name, func(t *testing.T) {
+			var compact, indented bytes.Buffer
+			if err := writeDatabaseJSON(&compact, db, false, nil); err != nil {
+				t.Fatalf("writeDatabaseJSON compact failed: %v", err)
+			}
+			if err := writeDatabaseJSON(&indented, db, true, nil); err != nil {
+				t.Fatalf("writeDatabaseJSON indented failed: %v", err)
+			}
+
+			wantCompact, _ := json.Marshal(db)
+			wantIndented, _ := json.MarshalIndent(db, "", "  ")
+			if compact.String() != string(wantCompact) {
+				t.Errorf("Compact output differs:\ngot:  %s\nwant: %s", compact.String(), wantCompact)
+			}
+			if indented.String() != string(wantIndented) {
+				t.Errorf("Indented output differs:\ngot:\n%s\nwant:\n%s", indented.String(), wantIndented)
+			}
+		})
+	}
+}
+
+// Test streaming load converts V0 databases and reports progress
+func TestLoadJSONDatabaseV0Streaming(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	n := progressInterval + 5
+	v0 := make(map[string]MagnetEntryV0, n)
+	for i := 0; i < n; i++ {
+		hash := fmt.Sprintf("%040x", i)
+		v0[hash] = MagnetEntryV0{Hash: hash, Title: fmt.Sprintf("Book %d", i), State: "Seeding", Progress: 100}
+	}
+	data, _ := json.Marshal(v0)
+	dbPath := filepath.Join(tmpDir, "v0.json")
+	if err := os.WriteFile(dbPath, data, 0644); err != nil {
+		t.Fatalf("Failed to write V0 file: %v", err)
+	}
+
+	progressCalls := 0
+	db, err := LoadJSONDatabaseWithProgress(dbPath, func(int) { progressCalls++ })
+	if err != nil {
+		t.Fatalf("LoadJSONDatabaseWithProgress failed: %v", err)
+	}
+	if len(db.Added) != n {
+		t.Errorf("Expected %d entries, got %d", n, len(db.Added))
+	}
+	if progressCalls != 1 {
+		t.Errorf("Expected 1 progress call, got %d", progressCalls)
+	}
+	if db.Metadata.LastSequence != int64(n) {
+		t.Errorf("Expected last sequence %d, got %d", n, db.Metadata.LastSequence)
+	}
+
+	entry := db.Added[fmt.Sprintf("%040x", 7)]
+	if entry.UUID == "" || entry.URI == "" || entry.State != "Seeding" || entry.Progress != 100 {
+		t.Errorf("V0 entry not converted correctly: %+v", entry)
+	}
+}
+
+// Test streaming load of V1 databases assigns IDs and UUIDs
+func TestLoadJSONDatabaseV1Streaming(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	v1 := MagnetDatabaseV1{
+		Added: map[string]MagnetEntryV1{"hash1": {Hash: "hash1", Title: "One", RetryCount: 1}},
+		Retry: map[string]MagnetEntryV1{"hash2": {Hash: "hash2", Title: "Two", RetryCount: 3}},
+	}
+	data, _ := json.Marshal(v1)
+	dbPath := filepath.Join(tmpDir, "v1.json")
+	if err := os.WriteFile(dbPath, data, 0644); err != nil {
+		t.Fatalf("Failed to write V1 file: %v", err)
+	}
+
+	db, err := LoadJSONDatabase(dbPath)
+	if err != nil {
+		t.Fatalf("LoadJSONDatabase failed: %v", err)
+	}
+	if db.Added["hash1"].UUID == "" || db.Added["hash1"].ID == 0 {
+		t.Error("V1 added entry should get a UUID and ID")
+	}
+	if db.Retry["hash2"].RetryCount != 3 {
+		t.Errorf("V1 retry count not preserved: %d", db.Retry["hash2"].RetryCount)
+	}
+	if db.Metadata.LastSequence != 2 {
+		t.Errorf("Expected last sequence 2, got %d", db.Metadata.LastSequence)
+	}
+}
+
+// Test unparseable files are rejected rather than loaded as empty
+func TestLoadJSONDatabaseCorrupt(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	dbPath := filepath.Join(tmpDir, "corrupt.json")
+	if err := os.WriteFile(dbPath, []byte(`{"added": {"hash1": {"title": `), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	if db, err := LoadJSONDatabase(dbPath); err == nil || db != nil {
+		t.Error("Truncated database should fail to load")
+	}
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Torrent client types that can receive magnets
+const (
+	TargetDeluge      = "deluge"
+	TargetQBittorrent = "qbittorrent"
+)
+
+// primaryTargetName names the Deluge server from the main settings
+const primaryTargetName = "deluge"
+
+// TargetConfig describes an additional torrent client magnets are sent to
+type TargetConfig struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`           // deluge or qbittorrent
+	URL      string `json:"url,omitempty"`  // Web UI base URL (qBittorrent)
+	Host     string `json:"host,omitempty"` // Deluge host
+	Port     string `json:"port,omitempty"` // Deluge web port
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	Label    string `json:"label,omitempty"` // Label or category (default deluge_label)
+}
+
+// TargetStatus records the outcome of sending an entry to one target
+type TargetStatus struct {
+	Status    string `json:"status"` // success, duplicate, or failed
+	TorrentID string `json:"torrent_id,omitempty"`
+	Error     string `json:"error,omitempty"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+// accepted reports whether the target has the torrent
+func (s TargetStatus) accepted() bool {
+	return s.Status == RetrySucceeded || s.Status == RetryDuplicate
+}
+
+// torrentTarget is a torrent client that can accept a magnet
+type torrentTarget interface {
+	Name() string
+	// Add sends the magnet, returning the client's torrent ID when known
+	Add(magnetURI, hash, label string) (string, error)
+}
+
+// delugeTarget sends magnets to a Deluge server
+type delugeTarget struct {
+	name    string
+	config  Config // Connection and add options
+	primary bool   // Uses the main settings and the auth breaker
+	client  *DelugeClient
+}
+
+func (t *delugeTarget) Name() string { return t.name }
+
+// connect logs into the server, once per target
+func (t *delugeTarget) connect() error {
+	if t.client != nil {
+		return nil
+	}
+	if t.primary {
+		client, err := connectDeluge(t.config)
+		if err != nil {
+			return err
+		}
+		t.client = client
+		return nil
+	}
+	client := NewDelugeClient(t.config.DelugeHost, t.config.DelugePort, t.config.DelugePassword)
+	if err := client.Authenticate(); err != nil {
+		return fmt.Errorf("authentication failed: %w", err)
+	}
+	if err := client.Connect(); err != nil {
+		return fmt.Errorf("connection failed: %w", err)
+	}
+	t.client = client
+	return nil
+}
+
+func (t *delugeTarget) Add(magnetURI, hash, label string) (string, error) {
+	if err := t.connect(); err != nil {
+		return "", err
+	}
+
+	opts := AddTorrentOptions{}
+	if t.primary {
+		opts = AddOptionsForLabel(t.config, label)
+	}
+	return t.client.AddMagnet(magnetURI, label, opts)
+}
+
+func (t *delugeTarget) Torrents(hashes []string) (map[string]remoteTorrent, error) {
+	if err := t.connect(); err != nil {
+		return nil, err
+	}
+	statuses, err := t.client.GetTorrentsByID(hashes, []string{"name", "save_path", "state", "progress"})
+	if err != nil {
+		return nil, err
+	}
+
+	torrents := make(map[string]remoteTorrent, len(statuses))
+	for hash, status := range statuses {
+		torrent := remoteTorrent{}
+		torrent.Name, _ = status["name"].(string)
+		torrent.SavePath, _ = status["save_path"].(string)
+		torrent.State, _ = status["state"].(string)
+		torrent.Progress, _ = status["progress"].(float64)
+		torrent.ContentPath = path.Join(torrent.SavePath, torrent.Name)
+		torrent.Complete = torrent.Progress >= 100 || torrent.State == "Seeding"
+		torrents[strings.ToLower(hash)] = torrent
+	}
+	return torrents, nil
+}
+
+// qbittorrentTarget sends magnets to a qBittorrent Web UI
+type qbittorrentTarget struct {
+	name   string
+	client *QBittorrentClient
+}
+
+func (t *qbittorrentTarget) Name() string { return t.name }
+
+func (t *qbittorrentTarget) Add(magnetURI, hash, label string) (string, error) {
+	if err := t.client.Authenticate(); err != nil {
+		return "", fmt.Errorf("authentication failed: %w", err)
+	}
+	if exists, err := t.client.HasTorrent(hash); err == nil && exists {
+		return "", fmt.Errorf("torrent already in session")
+	}
+	if err := t.client.AddMagnet(magnetURI, label); err != nil {
+		return "", err
+	}
+	return strings.ToLower(hash), nil
+}
+
+// qBittorrent states for torrents that have finished downloading
+var qbittorrentDoneStates = map[string]bool{
+	"uploading": true, "stalledUP": true, "pausedUP": true, "stoppedUP": true,
+	"queuedUP": true, "forcedUP": true, "checkingUP": true,
+}
+
+func (t *qbittorrentTarget) Torrents(hashes []string) (map[string]remoteTorrent, error) {
+	if err := t.client.Authenticate(); err != nil {
+		return nil, fmt.Errorf("authentication failed: %w", err)
+	}
+	info, err := t.client.TorrentsInfo(hashes)
+	if err != nil {
+		return nil, err
+	}
+
+	torrents := make(map[string]remoteTorrent, len(info))
+	for _, qt := range info {
+		torrents[strings.ToLower(qt.Hash)] = remoteTorrent{
+			Name:        qt.Name,
+			SavePath:    qt.SavePath,
+			ContentPath: qt.ContentPath,
+			State:       qt.State,
+			Progress:    qt.Progress * 100,
+			Complete:    qt.Progress >= 1 || qbittorrentDoneStates[qt.State],
+		}
+	}
+	return torrents, nil
+}
+
+// remoteTorrent is a torrent as reported by a target client
+type remoteTorrent struct {
+	Name        string
+	SavePath    string
+	ContentPath string // Downloaded file or directory on the client's machine
+	State       string
+	Progress    float64 // Percent
+	Complete    bool
+}
+
+// statusTarget is a target that can report on its torrents
+type statusTarget interface {
+	torrentTarget
+	// Torrents returns the known torrents among hashes, keyed by lowercase hash
+	Torrents(hashes []string) (map[string]remoteTorrent, error)
+}
+
+// findTarget returns the target with the given name; the primary Deluge
+// server is "deluge"
+func findTarget(config Config, name string) (statusTarget, error) {
+	if name == "" || name == primaryTargetName {
+		return &delugeTarget{name: primaryTargetName, config: config, primary: true}, nil
+	}
+	for _, tc := range config.Targets {
+		if tc.Name != name {
+			continue
+		}
+		return newTarget(tc)
+	}
+	return nil, fmt.Errorf("no target named %q in the config", name)
+}
+
+// newTarget creates the client for an additional target
+func newTarget(tc TargetConfig) (statusTarget, error) {
+	switch strings.ToLower(tc.Type) {
+	case TargetDeluge, "":
+		config := Config{DelugeHost: tc.Host, DelugePort: tc.Port, DelugePassword: tc.Password}
+		return &delugeTarget{name: tc.Name, config: config}, nil
+	case TargetQBittorrent:
+		if tc.URL == "" {
+			return nil, fmt.Errorf("target %q: url is required for qbittorrent", tc.Name)
+		}
+		return &qbittorrentTarget{name: tc.Name, client: NewQBittorrentClient(tc.URL, tc.Username, tc.Password)}, nil
+	default:
+		return nil, fmt.Errorf("target %q: unknown type %q", tc.Name, tc.Type)
+	}
+}
+
+// targetLabel returns the label a target applies, defaulting to the entry's
+func targetLabel(tc TargetConfig, label string) string {
+	if tc.Label != "" {
+		return tc.Label
+	}
+	return label
+}
+
+// acceptingTargets lists the targets that took the torrent, for history
+func acceptingTargets(entry MagnetEntry) string {
+	var names []string
+	for name, status := range entry.Targets {
+		if status.accepted() {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+// fanOutAdd sends a magnet to the primary Deluge server and every configured
+// target at once, recording each outcome on the entry. The add counts as a
+// success when any target accepts the torrent.
+func fanOutAdd(magnetURI, hash string, entry *MagnetEntry, config Config) bool {
+	logger := config.logger()
+	primary := &delugeTarget{name: primaryTargetName, config: config, primary: true}
+	targets := []torrentTarget{primary}
+	labels := []string{entry.Label}
+	statuses := make([]TargetStatus, 1, len(config.Targets)+1)
+
+	for _, tc := range config.Targets {
+		target, err := newTarget(tc)
+		if err != nil {
+			logger.Printf("Warning: %v", err)
+			continue
+		}
+		targets = append(targets, target)
+		labels = append(labels, targetLabel(tc, entry.Label))
+		statuses = append(statuses, TargetStatus{})
+	}
+
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		go func(i int, target torrentTarget) {
+			defer wg.Done()
+			torrentID, err := target.Add(magnetURI, hash, labels[i])
+			status := TargetStatus{Status: RetrySucceeded, TorrentID: torrentID, UpdatedAt: time.Now().Format(time.RFC3339)}
+			switch {
+			case classifyError(err) == ErrorDuplicate:
+				status.Status = RetryDuplicate
+			case err != nil:
+				status.Status, status.Error = RetryFailed, err.Error()
+			}
+			statuses[i] = status
+		}(i, target)
+	}
+	wg.Wait()
+
+	entry.Targets = make(map[string]TargetStatus, len(targets))
+	accepted := false
+	for i, target := range targets {
+		status := statuses[i]
+		entry.Targets[target.Name()] = status
+		switch status.Status {
+		case RetrySucceeded:
+			logger.Printf("  ✓ %s: added", target.Name())
+		case RetryDuplicate:
+			logger.Printf("  ⚠ %s: already present", target.Name())
+		default:
+			logger.Printf("  ✗ %s: %s", target.Name(), status.Error)
+		}
+		accepted = accepted || status.accepted()
+	}
+
+	// Metadata and file rules only apply to the primary Deluge server
+	if entry.Targets[primaryTargetName].Status == RetrySucceeded {
+		captureTorrentDetails(primary.client, statuses[0].TorrentID, entry, config)
+	}
+	return accepted
+}
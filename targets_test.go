@@ -0,0 +1,153 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeQBittorrent starts a qBittorrent Web API stand-in that accepts adds
+// when accept is true, recording the category of each add
+func fakeQBittorrent(t *testing.T, accept bool, categories *[]string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		switch r.URL.Path {
+		case "/api/v2/auth/login":
+			if r.FormValue("password") != "secret" {
+				w.Write([]byte("Fails."))
+				return
+			}
+			http.SetCookie(w, &http.Cookie{Name: "SID", Value: "session", Path: "/"})
+			w.Write([]byte("Ok."))
+		case "/api/v2/torrents/info":
+			w.Write([]byte("[]"))
+		case "/api/v2/torrents/add":
+			if _, err := r.Cookie("SID"); err != nil {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			*categories = append(*categories, r.FormValue("category"))
+			if accept {
+				w.Write([]byte("Ok."))
+			} else {
+				w.Write([]byte("Fails."))
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// Test fanOutAdd succeeds when any target accepts and records each outcome
+func TestFanOutAdd(t *testing.T) {
+	magnet := "magnet:?xt=urn:btih:0123456789abcdef0123456789abcdef01234567&dn=Book"
+	hash := "0123456789abcdef0123456789abcdef01234567"
+
+	tests := []struct {
+		name          string
+		delugeAccepts bool
+		qbitAccepts   bool
+		section       string
+		deluge        string
+		seedbox       string
+	}{
+		{"deluge down, seedbox accepts", false, true, SectionAdded, RetryFailed, RetrySucceeded},
+		{"both accept", true, true, SectionAdded, RetrySucceeded, RetrySucceeded},
+		{"both fail", false, false, SectionRetry, RetryFailed, RetryFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+			if err != nil {
+				t.Fatalf("Failed to create temp dir: %v", err)
+			}
+			defer os.RemoveAll(tmpDir)
+			t.Setenv("HOME", tmpDir)
+
+			deluge := fakeDeluge(t, func(method string, params []interface{}) (interface{}, interface{}) {
+				switch method {
+				case "auth.login", "web.connected":
+					return true, nil
+				case "core.get_enabled_plugins":
+					return []interface{}{}, nil
+				case "core.add_torrent_magnet":
+					if !tt.delugeAccepts {
+						return nil, map[string]interface{}{"message": "disk full", "code": 1}
+					}
+					return hash, nil
+				}
+				return nil, nil
+			})
+			delugeURL, _ := url.Parse(deluge.BaseURL)
+
+			var categories []string
+			qbit := fakeQBittorrent(t, tt.qbitAccepts, &categories)
+
+			config := DefaultConfig()
+			config.DelugeHost, config.DelugePort = delugeURL.Hostname(), delugeURL.Port()
+			config.JSONPath = filepath.Join(tmpDir, "db.json")
+			config.RemotePath = ""
+			config.MetadataTimeout = -1
+			config.Targets = []TargetConfig{{Name: "seedbox", Type: TargetQBittorrent, URL: qbit.URL, Username: "admin", Password: "secret", Label: "books"}}
+
+			if err := AddMagnetToDeluge(magnet, config); err != nil {
+				t.Fatalf("AddMagnetToDeluge failed: %v", err)
+			}
+
+			db, err := LoadJSONDatabase(config.JSONPath)
+			if err != nil {
+				t.Fatalf("Failed to load database: %v", err)
+			}
+			entry, ok := db.Added[hash]
+			if tt.section == SectionRetry {
+				entry, ok = db.Retry[hash]
+			}
+			if !ok {
+				t.Fatalf("Expected entry in %s section", tt.section)
+			}
+			if got := entry.Targets[primaryTargetName].Status; got != tt.deluge {
+				t.Errorf("Deluge status = %q, want %q", got, tt.deluge)
+			}
+			if got := entry.Targets["seedbox"].Status; got != tt.seedbox {
+				t.Errorf("Seedbox status = %q, want %q", got, tt.seedbox)
+			}
+			if len(categories) != 1 || categories[0] != "books" {
+				t.Errorf("Seedbox should get one add in category books, got %v", categories)
+			}
+		})
+	}
+}
+
+// Test the qBittorrent client reports rejected logins
+func TestQBittorrentAuthenticate(t *testing.T) {
+	var categories []string
+	server := fakeQBittorrent(t, true, &categories)
+
+	if err := NewQBittorrentClient(server.URL, "admin", "wrong").Authenticate(); err != errAuthRejected {
+		t.Errorf("Expected errAuthRejected, got %v", err)
+	}
+	if err := NewQBittorrentClient(server.URL+"/", "admin", "secret").Authenticate(); err != nil {
+		t.Errorf("Expected login to succeed, got %v", err)
+	}
+}
+
+// Test newTarget validates target settings
+func TestNewTarget(t *testing.T) {
+	if _, err := newTarget(TargetConfig{Name: "qb", Type: TargetQBittorrent}); err == nil {
+		t.Error("qbittorrent target without url should be rejected")
+	}
+	if _, err := newTarget(TargetConfig{Name: "x", Type: "transmission"}); err == nil {
+		t.Error("Unknown target type should be rejected")
+	}
+	target, err := newTarget(TargetConfig{Name: "seedbox", Host: "10.0.0.2", Port: "8112"})
+	if err != nil || target.Name() != "seedbox" {
+		t.Errorf("Deluge target should be the default type, got %v %v", target, err)
+	}
+}
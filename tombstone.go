@@ -0,0 +1,52 @@
+package main
+
+import (
+	"time"
+)
+
+// Tombstone records that an entry was deliberately deleted from the
+// database, so a stale copy elsewhere cannot bring it back on merge
+type Tombstone struct {
+	Hash      string `json:"hash"`
+	DeletedAt string `json:"deleted_at"`
+}
+
+// newTombstone records the deletion of hash at now
+func newTombstone(hash string, now time.Time) Tombstone {
+	return Tombstone{Hash: hash, DeletedAt: now.Format(time.RFC3339)}
+}
+
+// newerTombstone returns the later of two tombstones for the same hash
+func newerTombstone(a, b Tombstone) (Tombstone, bool) {
+	if a.DeletedAt == "" && b.DeletedAt == "" {
+		return Tombstone{}, false
+	}
+	if parseTimestamp(b.DeletedAt).After(parseTimestamp(a.DeletedAt)) {
+		return b, true
+	}
+	return a, true
+}
+
+// buryEntries deletes the entries the tombstones name from every section
+// and keeps the tombstones, so merges cannot bring the entries back
+func buryEntries(db *MagnetDatabase, tombs map[string]Tombstone) {
+	for hash, tomb := range tombs {
+		delete(db.Added, hash)
+		delete(db.Retry, hash)
+		delete(db.Removed, hash)
+		if db.Tombstones == nil {
+			db.Tombstones = make(map[string]Tombstone)
+		}
+		db.Tombstones[hash] = tomb
+	}
+}
+
+// buriedBy reports whether an entry predates the tombstone. An entry added,
+// retried, or removed again after the deletion survives it.
+func buriedBy(entry MagnetEntry, tomb Tombstone) bool {
+	latest := latestTimestamp(entry)
+	if removed := parseTimestamp(entry.RemovedAt); removed.After(latest) {
+		latest = removed
+	}
+	return !latest.After(parseTimestamp(tomb.DeletedAt))
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Test merges keep deleted entries deleted unless they came back later
+func TestMergeHonorsTombstones(t *testing.T) {
+	local := &MagnetDatabase{
+		Added: map[string]MagnetEntry{
+			"readded": {ID: 3, Hash: "readded", Title: "Re-added", AddedDate: "2024-03-01T00:00:00Z"},
+		},
+		Retry: map[string]MagnetEntry{},
+		Tombstones: map[string]Tombstone{
+			"deleted": {Hash: "deleted", DeletedAt: "2024-02-01T00:00:00Z"},
+			"readded": {Hash: "readded", DeletedAt: "2024-02-01T00:00:00Z"},
+		},
+	}
+	remote := &MagnetDatabase{
+		Added: map[string]MagnetEntry{
+			"deleted": {ID: 1, Hash: "deleted", Title: "Deleted", AddedDate: "2024-01-01T00:00:00Z"},
+			"readded": {ID: 2, Hash: "readded", Title: "Re-added", AddedDate: "2024-01-01T00:00:00Z"},
+		},
+		Retry: map[string]MagnetEntry{},
+	}
+
+	merged := MergeDatabases(local, remote)
+	if _, ok := merged.Added["deleted"]; ok {
+		t.Error("Stale remote entry should not be resurrected")
+	}
+	if _, ok := merged.Tombstones["deleted"]; !ok {
+		t.Error("Tombstone should be kept")
+	}
+	if _, ok := merged.Added["readded"]; !ok {
+		t.Error("Entry added after deletion should survive")
+	}
+	if _, ok := merged.Tombstones["readded"]; ok {
+		t.Error("Tombstone superseded by a later add should be dropped")
+	}
+
+	// The result is the same whichever side holds the tombstone
+	swapped := MergeDatabases(remote, local)
+	if _, ok := swapped.Added["deleted"]; ok {
+		t.Error("Stale local entry should not be resurrected")
+	}
+}
+
+// Test tombstones survive a save and load
+func TestTombstonesRoundTrip(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	path := filepath.Join(tmpDir, "db.json")
+	db := &MagnetDatabase{
+		Added:      map[string]MagnetEntry{},
+		Retry:      map[string]MagnetEntry{},
+		Tombstones: map[string]Tombstone{"hash1": {Hash: "hash1", DeletedAt: "2024-02-01T00:00:00Z"}},
+	}
+	if err := SaveDatabaseLocal(path, db); err != nil {
+		t.Fatalf("SaveDatabaseLocal failed: %v", err)
+	}
+
+	loaded, err := LoadJSONDatabase(path)
+	if err != nil {
+		t.Fatalf("LoadJSONDatabase failed: %v", err)
+	}
+	if loaded.Tombstones["hash1"].DeletedAt != "2024-02-01T00:00:00Z" {
+		t.Errorf("Tombstone not preserved: %+v", loaded.Tombstones)
+	}
+}
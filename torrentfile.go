@@ -0,0 +1,304 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxTorrentSize caps the .torrent files read from disk or downloaded
+const maxTorrentSize = 10 << 20
+
+// torrentFile is a .torrent given in place of a magnet link
+type torrentFile struct {
+	Name     string
+	Hash     string   // Hex SHA-1 of the info dictionary
+	Trackers []string // announce, then announce-list
+	Data     []byte
+}
+
+// isTorrentSource reports whether s names a .torrent file or a torrent URL
+// rather than a magnet link
+func isTorrentSource(s string) bool {
+	if isMagnetLink(s) {
+		return false
+	}
+	return isTorrentURL(s) || strings.HasSuffix(strings.ToLower(strings.TrimSpace(s)), ".torrent")
+}
+
+// parseTorrent reads the info hash, name, and trackers from .torrent data
+func parseTorrent(data []byte) (*torrentFile, error) {
+	info, err := bencodeDictRaw(data, "info")
+	if err != nil {
+		return nil, fmt.Errorf("not a .torrent file: %w", err)
+	}
+	value, err := decodeBencode(data)
+	if err != nil {
+		return nil, fmt.Errorf("not a .torrent file: %w", err)
+	}
+	meta, _ := value.(map[string]interface{})
+	infoDict, ok := meta["info"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("not a .torrent file: info is not a dictionary")
+	}
+
+	sum := sha1.Sum(info)
+	torrent := &torrentFile{Hash: hex.EncodeToString(sum[:]), Data: data}
+	torrent.Name, _ = infoDict["name"].(string)
+	seen := make(map[string]bool)
+	addTracker := func(value interface{}) {
+		if tracker, ok := value.(string); ok && tracker != "" && !seen[tracker] {
+			seen[tracker] = true
+			torrent.Trackers = append(torrent.Trackers, tracker)
+		}
+	}
+	addTracker(meta["announce"])
+	tiers, _ := meta["announce-list"].([]interface{})
+	for _, tier := range tiers {
+		trackers, _ := tier.([]interface{})
+		for _, tracker := range trackers {
+			addTracker(tracker)
+		}
+	}
+	return torrent, nil
+}
+
+// MagnetURI is the magnet link the torrent is tracked by in the database
+func (t *torrentFile) MagnetURI() string {
+	return rebuildMagnetURI(t.Hash, t.Name, t.Trackers)
+}
+
+// FileName is the name the torrent is uploaded to Deluge under
+func (t *torrentFile) FileName() string {
+	if t.Name == "" {
+		return t.Hash + ".torrent"
+	}
+	return filepath.Base(t.Name) + ".torrent"
+}
+
+// fetchTorrentURL downloads an indexer link, returning the .torrent it
+// serves or the magnet URI it redirects to. Indexers and Prowlarr answer
+// magnet-backed releases with a redirect to the magnet.
+func fetchTorrentURL(link string) ([]byte, string, error) {
+	client := &http.Client{
+		Timeout: 30 * time.Second,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	for hops := 0; hops < 5; hops++ {
+		resp, err := client.Get(link)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to fetch torrent URL: %w", err)
+		}
+		data, err := io.ReadAll(io.LimitReader(resp.Body, maxTorrentSize+1))
+		resp.Body.Close()
+
+		location := resp.Header.Get("Location")
+		switch {
+		case resp.StatusCode >= 300 && resp.StatusCode < 400 && isMagnetLink(location):
+			return nil, location, nil
+		case resp.StatusCode >= 300 && resp.StatusCode < 400 && location != "":
+			next, err := resp.Request.URL.Parse(location)
+			if err != nil {
+				return nil, "", fmt.Errorf("invalid redirect from torrent URL: %w", err)
+			}
+			link = next.String()
+		case resp.StatusCode != http.StatusOK:
+			return nil, "", fmt.Errorf("torrent URL returned status %d", resp.StatusCode)
+		case err != nil:
+			return nil, "", fmt.Errorf("failed to download torrent: %w", err)
+		case len(data) > maxTorrentSize:
+			return nil, "", fmt.Errorf("torrent URL serves more than %d MB", maxTorrentSize>>20)
+		default:
+			return data, "", nil
+		}
+	}
+	return nil, "", fmt.Errorf("too many redirects from torrent URL")
+}
+
+// fetchedTorrent is what a torrent URL gave: a .torrent or a magnet URI
+type fetchedTorrent struct {
+	torrent   *torrentFile
+	magnetURI string
+	at        time.Time
+}
+
+// fetchedTorrents keeps the torrent URLs this process has downloaded, so
+// confirming, adding, and notifying about one link fetch it once. A daemon
+// runs for weeks, so entries expire and only the newest few are kept.
+var (
+	fetchedTorrents   = make(map[string]fetchedTorrent)
+	fetchedTorrentsMu sync.Mutex
+)
+
+const (
+	fetchedTorrentTTL  = 10 * time.Minute
+	maxFetchedTorrents = 16
+)
+
+// cachedTorrent returns what source gave when it was fetched recently
+func cachedTorrent(source string) (fetchedTorrent, bool) {
+	fetchedTorrentsMu.Lock()
+	defer fetchedTorrentsMu.Unlock()
+	fetched, ok := fetchedTorrents[source]
+	if ok && time.Since(fetched.at) > fetchedTorrentTTL {
+		delete(fetchedTorrents, source)
+		return fetchedTorrent{}, false
+	}
+	return fetched, ok
+}
+
+// rememberTorrent caches what source gave, dropping expired entries and
+// then the oldest to stay within maxFetchedTorrents
+func rememberTorrent(source string, fetched fetchedTorrent) {
+	fetchedTorrentsMu.Lock()
+	defer fetchedTorrentsMu.Unlock()
+	for key, cached := range fetchedTorrents {
+		if time.Since(cached.at) > fetchedTorrentTTL {
+			delete(fetchedTorrents, key)
+		}
+	}
+	for len(fetchedTorrents) >= maxFetchedTorrents {
+		oldest := ""
+		for key, cached := range fetchedTorrents {
+			if oldest == "" || cached.at.Before(fetchedTorrents[oldest].at) {
+				oldest = key
+			}
+		}
+		delete(fetchedTorrents, oldest)
+	}
+	fetchedTorrents[source] = fetched
+}
+
+// loadTorrentSource reads a .torrent file or downloads a torrent URL. A URL
+// that redirects to a magnet link returns that link and no torrent.
+func loadTorrentSource(source string) (*torrentFile, string, error) {
+	source = strings.TrimSpace(source)
+	if !isTorrentURL(source) {
+		info, err := os.Stat(source)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to read torrent file: %w", err)
+		}
+		if info.Size() > maxTorrentSize {
+			return nil, "", fmt.Errorf("%s is larger than %d MB", source, maxTorrentSize>>20)
+		}
+		data, err := os.ReadFile(source)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to read torrent file: %w", err)
+		}
+		torrent, err := parseTorrent(data)
+		if err != nil {
+			return nil, "", fmt.Errorf("%s: %w", source, err)
+		}
+		return torrent, "", nil
+	}
+
+	if fetched, ok := cachedTorrent(source); ok {
+		return fetched.torrent, fetched.magnetURI, nil
+	}
+	// Fetched without the lock, so a slow server holds up only this add
+	data, magnetURI, err := fetchTorrentURL(source)
+	if err != nil {
+		return nil, "", err
+	}
+	var torrent *torrentFile
+	if magnetURI == "" {
+		if torrent, err = parseTorrent(data); err != nil {
+			return nil, "", fmt.Errorf("torrent URL: %w", err)
+		}
+	}
+	rememberTorrent(source, fetchedTorrent{torrent, magnetURI, time.Now()})
+	return torrent, magnetURI, nil
+}
+
+// resolveSource returns the magnet URI to track rawURI by and, for a
+// .torrent file or URL, the torrent to upload. On error the magnet URI is
+// rawURI normalized, as for any other input.
+func resolveSource(rawURI string) (string, *torrentFile, error) {
+	if !isTorrentSource(rawURI) {
+		return NormalizeMagnetURI(rawURI), nil, nil
+	}
+	torrent, magnetURI, err := loadTorrentSource(rawURI)
+	switch {
+	case err != nil:
+		return NormalizeMagnetURI(rawURI), nil, err
+	case torrent == nil:
+		return NormalizeMagnetURI(magnetURI), nil, nil
+	}
+	return torrent.MagnetURI(), torrent, nil
+}
+
+// AddTorrentFile uploads .torrent data to Deluge, labelled like AddMagnet
+func (c *DelugeClient) AddTorrentFile(filename string, data []byte, label string, opts AddTorrentOptions) (string, error) {
+	filedump := base64.StdEncoding.EncodeToString(data)
+	return c.addTorrent("core.add_torrent_file", []interface{}{filename, filedump, opts.toMap()}, label)
+}
+
+// addToDeluge uploads torrent when there is one, and otherwise adds the
+// magnet link
+func addToDeluge(client *DelugeClient, magnetURI string, torrent *torrentFile, label string, opts AddTorrentOptions) (string, error) {
+	if torrent != nil {
+		return client.AddTorrentFile(torrent.FileName(), torrent.Data, label, opts)
+	}
+	return client.AddMagnet(magnetURI, label, opts)
+}
+
+// torrentCachePath is where the .torrent for a retry-queue entry is kept,
+// so the retry can upload the file itself
+func torrentCachePath(hash string) (string, error) {
+	homeDir, err := getHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, ".magnet-handler", "torrents", hash+".torrent"), nil
+}
+
+// keepTorrentForRetry saves a torrent that could not be added for retry
+func keepTorrentForRetry(torrent *torrentFile) {
+	path, err := torrentCachePath(torrent.Hash)
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(path), 0700)
+	}
+	if err == nil {
+		err = os.WriteFile(path, torrent.Data, 0600)
+	}
+	if err != nil {
+		log.Printf("Warning: Could not keep the .torrent, it will be retried as a magnet link: %v", err)
+	}
+}
+
+// retryTorrent returns the .torrent kept for hash, or nil when the entry
+// was added as a magnet link
+func retryTorrent(hash string) *torrentFile {
+	path, err := torrentCachePath(hash)
+	if err != nil {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	torrent, err := parseTorrent(data)
+	if err != nil || torrent.Hash != hash {
+		return nil
+	}
+	return torrent
+}
+
+// forgetTorrent removes the .torrent kept for hash once Deluge has it
+func forgetTorrent(hash string) {
+	if path, err := torrentCachePath(hash); err == nil {
+		os.Remove(path)
+	}
+}
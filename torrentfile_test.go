@@ -0,0 +1,178 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testTorrent is a minimal single-file .torrent and its info dictionary
+const (
+	testTorrentInfo = "d6:lengthi42e4:name4:book12:piece lengthi16384e6:pieces20:aaaaaaaaaaaaaaaaaaaae"
+	testTorrent     = "d8:announce20:udp://one.example:8013:announce-listll20:udp://one.example:80el20:http://two.example/aee4:info" + testTorrentInfo + "e"
+)
+
+// testTorrentHash is the v1 info hash of testTorrent
+func testTorrentHash() string {
+	sum := sha1.Sum([]byte(testTorrentInfo))
+	return hex.EncodeToString(sum[:])
+}
+
+// Test parseTorrent hashes the info dictionary exactly as encoded
+func TestParseTorrent(t *testing.T) {
+	torrent, err := parseTorrent([]byte(testTorrent))
+	if err != nil {
+		t.Fatalf("parseTorrent failed: %v", err)
+	}
+	if torrent.Hash != testTorrentHash() || torrent.Name != "book" {
+		t.Errorf("Unexpected hash %s or name %q", torrent.Hash, torrent.Name)
+	}
+	if want := []string{"udp://one.example:80", "http://two.example/a"}; !reflect.DeepEqual(torrent.Trackers, want) {
+		t.Errorf("Expected trackers %v, got %v", want, torrent.Trackers)
+	}
+	if uri := torrent.MagnetURI(); ExtractMagnetHash(uri) != torrent.Hash || !ValidateMagnetURI(uri) {
+		t.Errorf("Expected a valid magnet for the torrent, got %q", uri)
+	}
+
+	for _, bad := range []string{"", "le", "d4:name4:booke", "d4:infoi1ee"} {
+		if _, err := parseTorrent([]byte(bad)); err == nil {
+			t.Errorf("Expected %q rejected", bad)
+		}
+	}
+}
+
+// Test a .torrent file is uploaded with add_torrent_file and tracked by its
+// hash, kept while it waits to retry, and uploaded again by the retry
+func TestAddTorrentFile(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	t.Setenv("HOME", tmpDir)
+
+	path := filepath.Join(tmpDir, "book.torrent")
+	if err := os.WriteFile(path, []byte(testTorrent), 0644); err != nil {
+		t.Fatalf("Failed to write torrent: %v", err)
+	}
+
+	failing := true
+	var uploads []string
+	client := fakeDeluge(t, func(method string, params []interface{}) (interface{}, interface{}) {
+		switch method {
+		case "auth.login", "web.connected":
+			return true, nil
+		case "daemon.info":
+			return "2.1.1", nil
+		case "core.add_torrent_magnet":
+			t.Errorf("Expected the .torrent uploaded, not added as a magnet")
+		case "core.add_torrent_file":
+			filedump, _ := params[1].(string)
+			data, _ := base64.StdEncoding.DecodeString(filedump)
+			uploads = append(uploads, string(data))
+			if failing {
+				return nil, map[string]interface{}{"message": "disk quota exceeded", "code": 4}
+			}
+			return testTorrentHash(), nil
+		}
+		return nil, nil
+	})
+	server, _ := url.Parse(client.BaseURL)
+	config := DefaultConfig()
+	config.DelugeHost = server.Hostname()
+	config.DelugePort = server.Port()
+	config.RemotePath = ""
+	config.JSONPath = filepath.Join(tmpDir, "db.json")
+	config.MetadataTimeout = -1
+
+	hash := testTorrentHash()
+	if err := AddMagnetToDeluge(path, config); err != nil {
+		t.Fatalf("AddMagnetToDeluge failed: %v", err)
+	}
+	db, _ := LoadJSONDatabase(config.JSONPath)
+	entry, queued := db.Retry[hash]
+	if !queued || entry.Title != "book" || entry.RawURI != path {
+		t.Fatalf("Expected the torrent queued by hash with its path kept, got %+v", db.Retry)
+	}
+	if retryTorrent(hash) == nil {
+		t.Fatal("Expected the .torrent kept for retry")
+	}
+
+	failing = false
+	if err := ProcessRetryEntries(config, RetryFilter{Hash: hash}); err != nil {
+		t.Fatalf("ProcessRetryEntries failed: %v", err)
+	}
+	db, _ = LoadJSONDatabase(config.JSONPath)
+	if _, added := db.Added[hash]; !added {
+		t.Errorf("Expected the retry to add the torrent, got %+v", db)
+	}
+	if len(uploads) != 2 || uploads[1] != testTorrent {
+		t.Errorf("Expected the file uploaded on both attempts, got %d uploads", len(uploads))
+	}
+	if retryTorrent(hash) != nil {
+		t.Error("Expected the kept .torrent removed once added")
+	}
+}
+
+// Test torrent URLs give either the .torrent they serve or the magnet they
+// redirect to
+func TestLoadTorrentSourceURL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/file":
+			w.Write([]byte(testTorrent))
+		case "/magnet":
+			http.Redirect(w, r, "magnet:?xt=urn:btih:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", http.StatusFound)
+		default:
+			w.Write([]byte("<html>not a torrent</html>"))
+		}
+	}))
+	defer server.Close()
+
+	magnetURI, torrent, err := resolveSource(server.URL + "/file")
+	if err != nil || torrent == nil || ExtractMagnetHash(magnetURI) != testTorrentHash() {
+		t.Errorf("Expected the served .torrent, got %q, %v", magnetURI, err)
+	}
+	magnetURI, torrent, err = resolveSource(server.URL + "/magnet")
+	if err != nil || torrent != nil || ExtractMagnetHash(magnetURI) != "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa" {
+		t.Errorf("Expected the redirected magnet, got %q, %v", magnetURI, err)
+	}
+	if _, _, err := resolveSource(server.URL + "/page"); err == nil {
+		t.Error("Expected a page that is not a .torrent rejected")
+	}
+}
+
+// Test the fetched-torrent cache forgets expired URLs and keeps only the
+// newest maxFetchedTorrents
+func TestFetchedTorrentCache(t *testing.T) {
+	defer func() { fetchedTorrents = make(map[string]fetchedTorrent) }()
+	fetchedTorrents = make(map[string]fetchedTorrent)
+
+	rememberTorrent("https://example.com/stale", fetchedTorrent{magnetURI: "stale", at: time.Now().Add(-fetchedTorrentTTL - time.Minute)})
+	if _, ok := cachedTorrent("https://example.com/stale"); ok {
+		t.Error("Expired URL should not be served from the cache")
+	}
+
+	start := time.Now()
+	for i := 0; i <= maxFetchedTorrents; i++ {
+		rememberTorrent(fmt.Sprintf("https://example.com/%d", i), fetchedTorrent{at: start.Add(time.Duration(i) * time.Second)})
+	}
+	if len(fetchedTorrents) != maxFetchedTorrents {
+		t.Errorf("Expected the cache capped at %d, got %d", maxFetchedTorrents, len(fetchedTorrents))
+	}
+	if _, ok := cachedTorrent("https://example.com/0"); ok {
+		t.Error("Oldest URL should have been evicted")
+	}
+	if _, ok := cachedTorrent(fmt.Sprintf("https://example.com/%d", maxFetchedTorrents)); !ok {
+		t.Error("Newest URL should be cached")
+	}
+}
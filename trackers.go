@@ -0,0 +1,52 @@
+package main
+
+import (
+	"log"
+	"net/url"
+	"strings"
+)
+
+// rebuildMagnetURI builds a magnet URI from an info hash, display name, and
+// tracker list
+func rebuildMagnetURI(hash, name string, trackers []string) string {
+	// Base32 hashes are conventionally upper case; hex is fine either way
+	if len(hash) == 32 {
+		hash = strings.ToUpper(hash)
+	}
+	var b strings.Builder
+	b.WriteString("magnet:?xt=urn:btih:")
+	b.WriteString(hash)
+	if name != "" {
+		b.WriteString("&dn=")
+		b.WriteString(url.QueryEscape(name))
+	}
+	for _, tracker := range trackers {
+		b.WriteString("&tr=")
+		b.WriteString(url.QueryEscape(tracker))
+	}
+	return b.String()
+}
+
+// retryURI returns the magnet URI to send when retrying entry. With
+// refresh_trackers set, the stored hash is re-announced with the configured
+// trackers in place of the original, possibly stale, ones; retry_raw_uri
+// resends the URI exactly as it was received, unless it was a .torrent or
+// fails the validation every added magnet passes.
+func retryURI(entry MagnetEntry, config Config) string {
+	if !config.RefreshTrackers || len(config.Trackers) == 0 || entry.Hash == "" {
+		if config.RetryRawURI && entry.RawURI != "" && !isTorrentSource(entry.RawURI) {
+			if ValidateMagnetURI(entry.RawURI) {
+				return entry.RawURI
+			}
+			log.Printf("  Raw URI fails validation (%s); retrying with the normalized one", magnetURIProblem(entry.RawURI))
+		}
+		return entry.URI
+	}
+	name := entry.Title
+	if query, ok := strings.CutPrefix(entry.URI, "magnet:?"); ok {
+		if params, err := url.ParseQuery(query); err == nil && params.Get("dn") != "" {
+			name = params.Get("dn")
+		}
+	}
+	return rebuildMagnetURI(entry.Hash, name, config.Trackers)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Test retryURI rebuilds the magnet only when refresh is configured
+func TestRetryURI(t *testing.T) {
+	original := "magnet:?xt=urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a&dn=Old+Book&tr=udp%3A%2F%2Fdead.example%3A80"
+	entry := MagnetEntry{Hash: "c12fe1c06bba254a9dc9f519b335aa7c1367a88a", Title: "Stored Title", URI: original}
+	trackers := []string{"udp://tracker.example:1337/announce", "https://t.example/announce?k=1"}
+
+	tests := []struct {
+		name   string
+		entry  MagnetEntry
+		config Config
+		want   string
+	}{
+		{"disabled", entry, Config{Trackers: trackers}, original},
+		{"no trackers", entry, Config{RefreshTrackers: true}, original},
+		{"refreshed", entry, Config{RefreshTrackers: true, Trackers: trackers},
+			"magnet:?xt=urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a&dn=Old+Book" +
+				"&tr=udp%3A%2F%2Ftracker.example%3A1337%2Fannounce&tr=https%3A%2F%2Ft.example%2Fannounce%3Fk%3D1"},
+		{"raw form", MagnetEntry{URI: original, RawURI: "magnet:?dn=Old+Book&xt=urn:btih:C12FE1C06BBA254A9DC9F519B335AA7C1367A88A"},
+			Config{RetryRawURI: true}, "magnet:?dn=Old+Book&xt=urn:btih:C12FE1C06BBA254A9DC9F519B335AA7C1367A88A"},
+		{"invalid raw form", MagnetEntry{URI: original, RawURI: "magnet://?xt=raw"}, Config{RetryRawURI: true}, original},
+		{"injected raw form", MagnetEntry{URI: original, RawURI: original + "\"; rm -rf ~"}, Config{RetryRawURI: true}, original},
+		{"raw form without raw", entry, Config{RetryRawURI: true}, original},
+		{"title fallback and base32", MagnetEntry{Hash: "mfrggzdfmztwq2lknnwg23tpobyxe43u", Title: "Stored Title"},
+			Config{RefreshTrackers: true, Trackers: trackers[:1]},
+			"magnet:?xt=urn:btih:MFRGGZDFMZTWQ2LKNNWG23TPOBYXE43U&dn=Stored+Title&tr=udp%3A%2F%2Ftracker.example%3A1337%2Fannounce"},
+	}
+
+	for _, tt := range tests {
+		if got := retryURI(tt.entry, tt.config); got != tt.want {
+			t.Errorf("%s: retryURI =\n  %s\nwant\n  %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+// Test retryEntry sends the refreshed URI but keeps the original in the database
+func TestRetryEntryRefreshedTrackers(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	t.Setenv("HOME", tmpDir)
+
+	var sent string
+	client := fakeDeluge(t, func(method string, params []interface{}) (interface{}, interface{}) {
+		if method == "core.add_torrent_magnet" {
+			sent, _ = params[0].(string)
+			return "c12fe1c06bba254a9dc9f519b335aa7c1367a88a", nil
+		}
+		return nil, nil
+	})
+
+	config := DefaultConfig()
+	config.JSONPath = filepath.Join(tmpDir, "db.json")
+	config.RemotePath = ""
+	config.MetadataTimeout = -1
+	config.RefreshTrackers = true
+	config.Trackers = []string{"udp://tracker.example:1337/announce"}
+
+	hash := "c12fe1c06bba254a9dc9f519b335aa7c1367a88a"
+	original := "magnet:?xt=urn:btih:" + hash + "&dn=Book&tr=udp%3A%2F%2Fdead.example%3A80"
+	outcome, err := retryEntry(client, config, hash, MagnetEntry{Hash: hash, Title: "Book", URI: original, RetryCount: 2})
+	if err != nil || outcome != RetrySucceeded {
+		t.Fatalf("retryEntry = %s, %v", outcome, err)
+	}
+	if !strings.Contains(sent, "tracker.example") || strings.Contains(sent, "dead.example") {
+		t.Errorf("Expected refreshed trackers to be sent, got %s", sent)
+	}
+
+	db, err := LoadJSONDatabase(config.JSONPath)
+	if err != nil {
+		t.Fatalf("LoadJSONDatabase failed: %v", err)
+	}
+	if db.Added[hash].URI != original {
+		t.Errorf("Stored URI should stay the original, got %s", db.Added[hash].URI)
+	}
+	if db.Added[hash].SentURI != sent {
+		t.Errorf("Sent URI should be recorded, got %s", db.Added[hash].SentURI)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"crypto/tls"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Connection pool defaults for the Deluge Web API. Go's default keeps only
+// two idle connections per host, so bursts of adds and long retry drains
+// would otherwise open (and leave in TIME_WAIT) a connection per request.
+const (
+	defaultMaxIdleConns    = 16
+	defaultIdleConnTimeout = 90 * time.Second
+)
+
+var (
+	httpClientMu sync.Mutex
+	httpClient   *http.Client
+)
+
+// newHTTPClient builds the client used for Deluge requests from the config
+func newHTTPClient(config Config) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	transport.MaxIdleConns = defaultMaxIdleConns
+	if config.HTTPMaxIdleConns > 0 {
+		transport.MaxIdleConns = config.HTTPMaxIdleConns
+	}
+	// Every request goes to the same Deluge host
+	transport.MaxIdleConnsPerHost = transport.MaxIdleConns
+
+	transport.IdleConnTimeout = defaultIdleConnTimeout
+	switch {
+	case config.HTTPIdleTimeout > 0:
+		transport.IdleConnTimeout = time.Duration(config.HTTPIdleTimeout) * time.Second
+	case config.HTTPIdleTimeout < 0:
+		transport.DisableKeepAlives = true
+	}
+
+	if config.DisableHTTP2 {
+		transport.ForceAttemptHTTP2 = false
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+
+	return &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: transport,
+	}
+}
+
+// ConfigureHTTPTransport applies the connection settings from the config to
+// the client shared by all Deluge connections
+func ConfigureHTTPTransport(config Config) {
+	httpClientMu.Lock()
+	defer httpClientMu.Unlock()
+	if httpClient != nil {
+		httpClient.CloseIdleConnections()
+	}
+	httpClient = newHTTPClient(config)
+}
+
+// sharedHTTPClient returns the client shared by all Deluge connections, so
+// batch and retry operations reuse pooled connections and TLS sessions
+func sharedHTTPClient() *http.Client {
+	httpClientMu.Lock()
+	defer httpClientMu.Unlock()
+	if httpClient == nil {
+		httpClient = newHTTPClient(Config{})
+	}
+	return httpClient
+}
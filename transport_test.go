@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Test newHTTPClient applies the pooling settings from the config
+func TestNewHTTPClient(t *testing.T) {
+	tests := []struct {
+		name         string
+		config       Config
+		maxIdle      int
+		idleTimeout  time.Duration
+		noKeepAlive  bool
+		attemptHTTP2 bool
+	}{
+		{"defaults", Config{}, defaultMaxIdleConns, defaultIdleConnTimeout, false, true},
+		{"custom pool", Config{HTTPMaxIdleConns: 4, HTTPIdleTimeout: 30}, 4, 30 * time.Second, false, true},
+		{"keep-alive off", Config{HTTPIdleTimeout: -1}, defaultMaxIdleConns, defaultIdleConnTimeout, true, true},
+		{"http/1.1 only", Config{DisableHTTP2: true}, defaultMaxIdleConns, defaultIdleConnTimeout, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := newHTTPClient(tt.config).Transport.(*http.Transport)
+			if transport.MaxIdleConns != tt.maxIdle || transport.MaxIdleConnsPerHost != tt.maxIdle {
+				t.Errorf("Idle conns = %d/%d, want %d", transport.MaxIdleConns, transport.MaxIdleConnsPerHost, tt.maxIdle)
+			}
+			if transport.IdleConnTimeout != tt.idleTimeout {
+				t.Errorf("IdleConnTimeout = %v, want %v", transport.IdleConnTimeout, tt.idleTimeout)
+			}
+			if transport.DisableKeepAlives != tt.noKeepAlive {
+				t.Errorf("DisableKeepAlives = %v, want %v", transport.DisableKeepAlives, tt.noKeepAlive)
+			}
+			if transport.ForceAttemptHTTP2 != tt.attemptHTTP2 {
+				t.Errorf("ForceAttemptHTTP2 = %v, want %v", transport.ForceAttemptHTTP2, tt.attemptHTTP2)
+			}
+		})
+	}
+}
+
+// Test separate Deluge clients reuse pooled connections
+func TestDelugeClientsShareConnections(t *testing.T) {
+	var conns int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"result": true, "error": nil, "id": 1})
+	}))
+	server.Config.ConnState = func(c net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	ConfigureHTTPTransport(Config{})
+	for i := 0; i < 10; i++ {
+		client := NewDelugeClient("127.0.0.1", "0", "password")
+		client.BaseURL = server.URL + "/json"
+		if err := client.Authenticate(); err != nil {
+			t.Fatalf("Authenticate failed: %v", err)
+		}
+	}
+
+	if n := atomic.LoadInt32(&conns); n != 1 {
+		t.Errorf("Expected 1 pooled connection for 10 logins, got %d", n)
+	}
+}
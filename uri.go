@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// magnetURIEnv is read when no magnet URI is passed on the command line
+const magnetURIEnv = "MAGNET_URI"
+
+var (
+	percentEscape       = regexp.MustCompile(`%[0-9A-Fa-f]{2}`)
+	doubleEncodedEscape = regexp.MustCompile(`%25([0-9A-Fa-f]{2})`)
+	encodedBtih         = regexp.MustCompile(`(?i)xt=urn(?:%3A|:)btih(?:%3A|:)`)
+)
+
+// NormalizeMagnetURI cleans up a magnet URI as delivered by browsers and
+// launchers before validation. It handles surrounding quotes and whitespace,
+// "magnet://" prefixes, a fully percent-encoded URI, double-encoded escapes
+// (%2520), HTML-escaped ampersands, and unescaped characters such as spaces.
+func NormalizeMagnetURI(raw string) string {
+	uri := strings.TrimSpace(raw)
+	for len(uri) > 0 && strings.ContainsRune(`"'`, rune(uri[0])) {
+		uri = strings.Trim(uri, `"'`)
+		uri = strings.TrimSpace(uri)
+	}
+
+	// Whole URI percent-encoded (magnet%3A%3Fxt%3D...)
+	if strings.HasPrefix(strings.ToLower(uri), "magnet%3a") {
+		if decoded, err := url.QueryUnescape(uri); err == nil {
+			uri = decoded
+		}
+	}
+
+	// Scheme variants: MAGNET:?, magnet://?, magnet:///?
+	if len(uri) >= 7 && strings.EqualFold(uri[:7], "magnet:") {
+		rest := strings.TrimLeft(uri[7:], "/")
+		uri = "magnet:" + rest
+	}
+
+	// Every escape encoded twice, e.g. dn=My%2520Book
+	escapes := percentEscape.FindAllString(uri, -1)
+	if len(escapes) > 0 && len(doubleEncodedEscape.FindAllString(uri, -1)) == len(escapes) {
+		uri = doubleEncodedEscape.ReplaceAllString(uri, "%$1")
+	}
+
+	uri = strings.ReplaceAll(uri, "&amp;", "&")
+	uri = encodedBtih.ReplaceAllString(uri, "xt=urn:btih:")
+
+	return escapeUnsafeRunes(uri)
+}
+
+// escapeUnsafeRunes percent-encodes bytes that ValidateMagnetURI rejects but
+// browsers sometimes pass through unescaped (spaces, quotes, UTF-8)
+func escapeUnsafeRunes(uri string) string {
+	var b strings.Builder
+	for i := 0; i < len(uri); i++ {
+		c := uri[i]
+		if magnetSafeByte(c) {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+// magnetSafeByte reports whether c is allowed unescaped in a magnet URI
+func magnetSafeByte(c byte) bool {
+	switch {
+	case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		return true
+	}
+	return strings.IndexByte(`:?&=%-_.~+!,/()#[]`, c) >= 0
+}
+
+// ResolveMagnetArgument returns the raw magnet URI from the command line,
+// an @file argument, or the MAGNET_URI environment variable, for launchers
+// that cannot pass long URIs on argv reliably
+func ResolveMagnetArgument(args []string) (string, error) {
+	if len(args) == 0 {
+		if uri := os.Getenv(magnetURIEnv); uri != "" {
+			return uri, nil
+		}
+		return "", fmt.Errorf("no magnet URI provided")
+	}
+
+	path, ok := strings.CutPrefix(args[0], "@")
+	if !ok {
+		// A running instance reading a .torrent has its own working directory
+		if isTorrentSource(args[0]) && !isTorrentURL(args[0]) {
+			if abs, err := filepath.Abs(args[0]); err == nil {
+				return abs, nil
+			}
+		}
+		return args[0], nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read magnet URI file: %w", err)
+	}
+	uri := strings.TrimSpace(string(data))
+	if uri == "" {
+		return "", fmt.Errorf("magnet URI file %s is empty", path)
+	}
+	return uri, nil
+}
+
+// printAlternateDelivery describes the fallbacks for launchers that cannot
+// pass the magnet URI as an argument
+func printAlternateDelivery(exePath string) {
+	fmt.Println("\nIf your launcher cannot pass long URIs as an argument, use:")
+	fmt.Printf("  Set %s=<magnet-uri> and run %s with no arguments\n", magnetURIEnv, exePath)
+	fmt.Printf("  %s @/path/to/file-containing-uri\n", exePath)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Test NormalizeMagnetURI with URIs as delivered by known browsers and launchers
+func TestNormalizeMagnetURI(t *testing.T) {
+	const canonical = "magnet:?xt=urn:btih:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa&dn=My%20Book"
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"already clean", canonical, canonical},
+		{"windows quoted %1", `"` + canonical + `"`, canonical},
+		{"shell single quotes", "'" + canonical + "'", canonical},
+		{"nested quotes and whitespace", ` "'` + canonical + `'" `, canonical},
+		{"trailing newline from argv file", canonical + "\n", canonical},
+		{"uppercase scheme", "MAGNET:?xt=urn:btih:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa&dn=My%20Book", canonical},
+		{"scheme with slashes", "magnet://?xt=urn:btih:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa&dn=My%20Book", canonical},
+		{"fully encoded", "magnet%3A%3Fxt%3Durn%3Abtih%3Aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa%26dn%3DMy%2520Book", canonical},
+		{"double encoded", "magnet:?xt=urn:btih:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa&dn=My%2520Book", canonical},
+		{"encoded btih colons", "magnet:?xt=urn%3Abtih%3Aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa&dn=My%20Book", canonical},
+		{"html escaped ampersand", "magnet:?xt=urn:btih:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa&amp;dn=My%20Book", canonical},
+		{"unescaped space", "magnet:?xt=urn:btih:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa&dn=My Book", canonical},
+		{
+			"literal percent kept",
+			"magnet:?xt=urn:btih:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa&dn=100%25%20Done",
+			"magnet:?xt=urn:btih:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa&dn=100%25%20Done",
+		},
+		{
+			"unescaped utf-8",
+			"magnet:?xt=urn:btih:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa&dn=Café",
+			"magnet:?xt=urn:btih:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa&dn=Caf%C3%A9",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NormalizeMagnetURI(tt.input)
+			if got != tt.expected {
+				t.Errorf("NormalizeMagnetURI(%q) = %q, want %q", tt.input, got, tt.expected)
+			}
+			if !ValidateMagnetURI(got) {
+				t.Errorf("Normalized URI %q should pass validation", got)
+			}
+		})
+	}
+}
+
+// Test NormalizeMagnetURI does not turn bad input into a usable magnet
+func TestNormalizeMagnetURIRejectsNonMagnet(t *testing.T) {
+	inputs := []string{
+		"",
+		"http://example.com/file.torrent",
+		`"magnet:?dn=NoHash"`,
+		"magnet:?xt=urn:btih:aaaa;rm -rf /",
+	}
+	for _, input := range inputs {
+		got := NormalizeMagnetURI(input)
+		if ValidateMagnetURI(got) && ExtractMagnetHash(got) != "" {
+			t.Errorf("NormalizeMagnetURI(%q) = %q should not yield a usable magnet", input, got)
+		}
+	}
+}
+
+// Test ResolveMagnetArgument reads argv, @file, and MAGNET_URI
+func TestResolveMagnetArgument(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	const uri = "magnet:?xt=urn:btih:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	uriFile := filepath.Join(tmpDir, "uri.txt")
+	if err := os.WriteFile(uriFile, []byte(uri+"\r\n"), 0644); err != nil {
+		t.Fatalf("Failed to write URI file: %v", err)
+	}
+	emptyFile := filepath.Join(tmpDir, "empty.txt")
+	if err := os.WriteFile(emptyFile, nil, 0644); err != nil {
+		t.Fatalf("Failed to write empty file: %v", err)
+	}
+
+	t.Setenv(magnetURIEnv, "")
+	if got, err := ResolveMagnetArgument([]string{uri}); err != nil || got != uri {
+		t.Errorf("argv: got %q, %v", got, err)
+	}
+	if got, err := ResolveMagnetArgument([]string{"@" + uriFile}); err != nil || got != uri {
+		t.Errorf("@file: got %q, %v", got, err)
+	}
+	if _, err := ResolveMagnetArgument([]string{"@" + emptyFile}); err == nil {
+		t.Error("Empty @file should be an error")
+	}
+	if _, err := ResolveMagnetArgument([]string{"@" + filepath.Join(tmpDir, "missing.txt")}); err == nil {
+		t.Error("Missing @file should be an error")
+	}
+	if _, err := ResolveMagnetArgument(nil); err == nil {
+		t.Error("No argument and no environment variable should be an error")
+	}
+
+	t.Setenv(magnetURIEnv, uri)
+	if got, err := ResolveMagnetArgument(nil); err != nil || got != uri {
+		t.Errorf("env: got %q, %v", got, err)
+	}
+	if got, _ := ResolveMagnetArgument([]string{"magnet:?xt=urn:btih:other"}); got != "magnet:?xt=urn:btih:other" {
+		t.Error("Command line argument should take precedence over the environment")
+	}
+}
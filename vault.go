@@ -0,0 +1,143 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// vaultTimeout bounds each request to Vault
+const vaultTimeout = 10 * time.Second
+
+// vaultSecrets reads secrets from a HashiCorp Vault KV engine (version 1 or
+// 2), configured by the standard VAULT_ADDR, VAULT_TOKEN (or ~/.vault-token),
+// VAULT_NAMESPACE, and VAULT_CACERT environment variables
+type vaultSecrets struct{}
+
+func (vaultSecrets) Get(name string, source SecretSource, plaintext string) (string, error) {
+	if source.Path == "" {
+		return "", fmt.Errorf("the vault backend needs a path (e.g. secret/data/magnet-handler)")
+	}
+	key := source.Key
+	if key == "" {
+		key = name
+	}
+
+	data, err := readVault(source.Path)
+	if err != nil {
+		return "", err
+	}
+	value, ok := data[key]
+	if !ok {
+		return "", fmt.Errorf("vault secret %s has no key %q", source.Path, key)
+	}
+	text, ok := value.(string)
+	if !ok || text == "" {
+		return "", fmt.Errorf("vault secret %s key %q is not a non-empty string", source.Path, key)
+	}
+	return text, nil
+}
+
+// readVault reads the key/value pairs stored at a Vault path, unwrapping the
+// KV version 2 envelope
+func readVault(path string) (map[string]interface{}, error) {
+	addr := os.Getenv("VAULT_ADDR")
+	if addr == "" {
+		return nil, fmt.Errorf("VAULT_ADDR is not set")
+	}
+	token, err := vaultToken()
+	if err != nil {
+		return nil, err
+	}
+	client, err := vaultClient()
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, strings.TrimRight(addr, "/")+"/v1/"+strings.TrimLeft(path, "/"), nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid VAULT_ADDR: %w", err)
+	}
+	req.Header.Set("X-Vault-Token", token)
+	if namespace := os.Getenv("VAULT_NAMESPACE"); namespace != "" {
+		req.Header.Set("X-Vault-Namespace", namespace)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("vault request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vault response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var failure struct {
+			Errors []string `json:"errors"`
+		}
+		if json.Unmarshal(body, &failure) == nil && len(failure.Errors) > 0 {
+			return nil, fmt.Errorf("vault returned %s for %s: %s", resp.Status, path, strings.Join(failure.Errors, "; "))
+		}
+		return nil, fmt.Errorf("vault returned %s for %s", resp.Status, path)
+	}
+
+	var secret struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &secret); err != nil {
+		return nil, fmt.Errorf("invalid vault response: %w", err)
+	}
+	// KV version 2 nests the pairs under data.data beside data.metadata
+	if inner, ok := secret.Data["data"].(map[string]interface{}); ok {
+		if _, ok := secret.Data["metadata"]; ok {
+			return inner, nil
+		}
+	}
+	return secret.Data, nil
+}
+
+// vaultToken returns VAULT_TOKEN, or the token the vault CLI saved at login
+func vaultToken() (string, error) {
+	if token := os.Getenv("VAULT_TOKEN"); token != "" {
+		return token, nil
+	}
+	homeDir, err := getHomeDir()
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(filepath.Join(homeDir, ".vault-token"))
+	if err != nil || strings.TrimSpace(string(data)) == "" {
+		return "", fmt.Errorf("no vault token (set VAULT_TOKEN or run vault login)")
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// vaultClient returns an HTTP client trusting VAULT_CACERT when it is set
+func vaultClient() (*http.Client, error) {
+	client := &http.Client{Timeout: vaultTimeout}
+	caFile := os.Getenv("VAULT_CACERT")
+	if caFile == "" {
+		return client, nil
+	}
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read VAULT_CACERT: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates in VAULT_CACERT %s", caFile)
+	}
+	client.Transport = &http.Transport{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: &tls.Config{RootCAs: pool},
+	}
+	return client, nil
+}
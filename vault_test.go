@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Test secrets are read from KV version 1 and 2 paths with the token and
+// namespace headers
+func TestVaultSecrets(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "root-token" || r.Header.Get("X-Vault-Namespace") != "team" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/magnet-handler":
+			w.Write([]byte(`{"data":{"data":{"deluge_password":"kv2-pass","port":8112},"metadata":{"version":3}}}`))
+		case "/v1/kv/magnet-handler":
+			w.Write([]byte(`{"data":{"password":"kv1-pass"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":[]}`))
+		}
+	}))
+	defer server.Close()
+	t.Setenv("VAULT_ADDR", server.URL+"/")
+	t.Setenv("VAULT_TOKEN", "root-token")
+	t.Setenv("VAULT_NAMESPACE", "team")
+
+	backend := vaultSecrets{}
+	if got, err := backend.Get("deluge_password", SecretSource{Path: "secret/data/magnet-handler"}, ""); err != nil || got != "kv2-pass" {
+		t.Errorf("Expected the KV v2 value, got %q, %v", got, err)
+	}
+	if got, err := backend.Get("api_token", SecretSource{Path: "/kv/magnet-handler", Key: "password"}, ""); err != nil || got != "kv1-pass" {
+		t.Errorf("Expected the KV v1 value, got %q, %v", got, err)
+	}
+
+	tests := []struct {
+		source SecretSource
+		want   string
+	}{
+		{SecretSource{Path: "secret/data/magnet-handler", Key: "missing"}, `no key "missing"`},
+		{SecretSource{Path: "secret/data/magnet-handler", Key: "port"}, "not a non-empty string"},
+		{SecretSource{Path: "secret/data/other"}, "404 Not Found"},
+		{SecretSource{}, "needs a path"},
+	}
+	for _, tt := range tests {
+		if _, err := backend.Get("deluge_password", tt.source, ""); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Get(%+v): expected error containing %q, got %v", tt.source, tt.want, err)
+		}
+	}
+
+	t.Setenv("VAULT_NAMESPACE", "")
+	if _, err := backend.Get("deluge_password", SecretSource{Path: "secret/data/magnet-handler"}, ""); err == nil || !strings.Contains(err.Error(), "permission denied") {
+		t.Errorf("Expected Vault's error message, got %v", err)
+	}
+}
+
+// Test the token the vault CLI saved is used when VAULT_TOKEN is unset
+func TestVaultToken(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	t.Setenv("HOME", tmpDir)
+	t.Setenv("VAULT_TOKEN", "")
+
+	if _, err := vaultToken(); err == nil {
+		t.Error("Expected an error without any token")
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, ".vault-token"), []byte("hvs.saved\n"), 0600); err != nil {
+		t.Fatalf("Failed to write token: %v", err)
+	}
+	if token, err := vaultToken(); err != nil || token != "hvs.saved" {
+		t.Errorf("Expected the saved token, got %q, %v", token, err)
+	}
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Verification modes
+const (
+	VerifyRecheck = "recheck" // Deluge force-rechecks the torrent
+	VerifyLocal   = "local"   // Pieces are hashed here against the .torrent file
+)
+
+// VerifyConfig describes how completed downloads are checked for corruption
+type VerifyConfig struct {
+	Mode       string `json:"mode,omitempty"`        // recheck (default) or local
+	TorrentDir string `json:"torrent_dir,omitempty"` // Folder with <hash>.torrent files, e.g. Deluge's state folder (local mode)
+	RemoteRoot string `json:"remote_root,omitempty"` // Save path prefix as Deluge reports it (local mode)
+	LocalRoot  string `json:"local_root,omitempty"`  // Where remote_root is mounted on this machine (local mode)
+}
+
+// mode returns the configured verification mode or the default
+func (v VerifyConfig) mode() string {
+	if v.Mode == "" {
+		return VerifyRecheck
+	}
+	return v.Mode
+}
+
+// localPath maps a save path reported by Deluge to this machine
+func (v VerifyConfig) localPath(savePath string) string {
+	if v.RemoteRoot != "" && strings.HasPrefix(savePath, v.RemoteRoot) {
+		savePath = v.LocalRoot + strings.TrimPrefix(savePath, v.RemoteRoot)
+	}
+	return filepath.FromSlash(savePath)
+}
+
+// ForceRecheck asks Deluge to re-hash a torrent's data
+func (c *DelugeClient) ForceRecheck(torrentID string) error {
+	return c.rpc("core.force_recheck", []interface{}{[]string{torrentID}}, nil)
+}
+
+// afterCompletion runs the configured steps for a newly completed download:
+// verification first, then sorting of data that did not fail it
+func afterCompletion(client *DelugeClient, config Config, entry *MagnetEntry, status map[string]interface{}) {
+	if config.VerifyCompleted != nil {
+		finished, err := startVerify(client, config, entry, status)
+		if err != nil {
+			log.Printf("Warning: Could not verify %s: %v", entry.Title, err)
+		}
+		if !finished {
+			return
+		}
+	}
+	sortIfVerified(client, config, entry, status)
+}
+
+// sortIfVerified sorts a completed download unless verification failed
+func sortIfVerified(client *DelugeClient, config Config, entry *MagnetEntry, status map[string]interface{}) {
+	if config.SortCompleted == nil {
+		return
+	}
+	if entry.Verified != nil && !*entry.Verified {
+		log.Printf("⚠ Not sorting %s: verification failed", entry.Title)
+		return
+	}
+	if err := sortCompleted(client, config, entry, status); err != nil {
+		log.Printf("Warning: Could not sort %s: %v", entry.Title, err)
+	}
+}
+
+// startVerify begins verification of a completed download, reporting
+// whether a result is already recorded. Rechecks finish in Deluge and are
+// picked up by finishRecheck on a later check-complete.
+func startVerify(client *DelugeClient, config Config, entry *MagnetEntry, status map[string]interface{}) (bool, error) {
+	verify := *config.VerifyCompleted
+	switch verify.mode() {
+	case VerifyRecheck:
+		torrentID := entry.TorrentID
+		if torrentID == "" {
+			torrentID = entry.Hash
+		}
+		if err := client.ForceRecheck(torrentID); err != nil {
+			return true, fmt.Errorf("failed to start recheck: %w", err)
+		}
+		log.Printf("Rechecking %s", entry.Title)
+		entry.VerifyPending = true
+		entry.recordHistory(HistoryVerifying, "", "")
+		return false, nil
+	case VerifyLocal:
+		savePath, _ := status["save_path"].(string)
+		torrentFile := filepath.Join(verify.TorrentDir, strings.ToLower(entry.Hash)+".torrent")
+		bad, err := verifyPieces(torrentFile, verify.localPath(savePath))
+		if err != nil {
+			return true, err
+		}
+		detail := ""
+		if bad > 0 {
+			detail = fmt.Sprintf("%d corrupt piece(s)", bad)
+		}
+		recordVerification(entry, bad == 0, detail)
+		return true, nil
+	default:
+		return true, fmt.Errorf("unknown verify mode %q (use %s or %s)", verify.Mode, VerifyRecheck, VerifyLocal)
+	}
+}
+
+// finishRecheck records the outcome of a recheck started by startVerify,
+// reporting false while Deluge is still checking
+func finishRecheck(entry *MagnetEntry, status map[string]interface{}) bool {
+	state, _ := status["state"].(string)
+	if state == "Checking" || state == "Allocating" {
+		return false
+	}
+	progress, _ := status["progress"].(float64)
+	detail := ""
+	if progress < 100 {
+		detail = fmt.Sprintf("%.1f%% intact after recheck", progress)
+	}
+	if state == "Error" {
+		detail = "Deluge reported an error after recheck"
+	}
+	recordVerification(entry, progress >= 100 && state != "Error", detail)
+	return true
+}
+
+// recordVerification stores a verification result, warning about corruption
+func recordVerification(entry *MagnetEntry, ok bool, detail string) {
+	entry.Verified = &ok
+	entry.VerifiedAt = time.Now().Format(time.RFC3339)
+	entry.VerifyPending = false
+	if ok {
+		log.Printf("✓ Verified: %s", entry.Title)
+		entry.recordHistory(HistoryVerified, "", "")
+		return
+	}
+	log.Printf("✗ Verification failed: %s (%s)", entry.Title, detail)
+	entry.recordHistory(HistoryCorrupt, "", detail)
+	Notify(NotifyWarning, "Completed download failed verification",
+		withWebUILink(fmt.Sprintf("%s\n%s\nThe data may be corrupt; recheck it in Deluge before using it.", entry.Title, detail), entry.Hash))
+}
+
+// torrentFileSpan is one file of a torrent, in piece order
+type torrentFileSpan struct {
+	path   string
+	length int64
+}
+
+// verifyPieces hashes the data under saveDir against the piece hashes in a
+// .torrent file and returns the number of pieces that do not match. Pieces
+// touching files that are absent, such as files skipped by file rules, are
+// not counted.
+func verifyPieces(torrentFile, saveDir string) (int, error) {
+	data, err := os.ReadFile(torrentFile)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read torrent file: %w", err)
+	}
+	decoded, err := decodeBencode(data)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse %s: %w", torrentFile, err)
+	}
+	root, _ := decoded.(map[string]interface{})
+	info, _ := root["info"].(map[string]interface{})
+	pieceLength, _ := info["piece length"].(int64)
+	pieces, _ := info["pieces"].(string)
+	name, _ := info["name"].(string)
+	if info == nil || pieceLength <= 0 || len(pieces)%sha1.Size != 0 || name == "" {
+		return 0, fmt.Errorf("%s is not a v1 torrent file", torrentFile)
+	}
+
+	var files []torrentFileSpan
+	if length, ok := info["length"].(int64); ok {
+		files = append(files, torrentFileSpan{filepath.Join(saveDir, name), length})
+	} else {
+		list, _ := info["files"].([]interface{})
+		for _, item := range list {
+			file, _ := item.(map[string]interface{})
+			length, _ := file["length"].(int64)
+			parts := []string{saveDir, name}
+			components, _ := file["path"].([]interface{})
+			for _, component := range components {
+				part, _ := component.(string)
+				parts = append(parts, part)
+			}
+			files = append(files, torrentFileSpan{filepath.Join(parts...), length})
+		}
+	}
+
+	reader := newSpanReader(files)
+	defer reader.Close()
+
+	bad, checked := 0, 0
+	buf := make([]byte, pieceLength)
+	for i := 0; i*sha1.Size < len(pieces); i++ {
+		n, complete := reader.ReadPiece(int64(i)*pieceLength, buf)
+		if !complete {
+			continue
+		}
+		checked++
+		sum := sha1.Sum(buf[:n])
+		if !bytes.Equal(sum[:], []byte(pieces[i*sha1.Size:(i+1)*sha1.Size])) {
+			bad++
+		}
+	}
+	if checked == 0 {
+		return 0, fmt.Errorf("no data found under %s", saveDir)
+	}
+	return bad, nil
+}
+
+// spanReader reads byte ranges across the files of a torrent
+type spanReader struct {
+	files []torrentFileSpan
+	open  map[string]*os.File
+}
+
+func newSpanReader(files []torrentFileSpan) *spanReader {
+	return &spanReader{files: files, open: make(map[string]*os.File)}
+}
+
+// ReadPiece fills buf from offset, returning the bytes read and whether
+// every file the piece touches could be read in full
+func (r *spanReader) ReadPiece(offset int64, buf []byte) (int, bool) {
+	n := 0
+	start := int64(0)
+	for _, file := range r.files {
+		end := start + file.length
+		if n < len(buf) && offset+int64(n) < end && offset+int64(n) >= start {
+			f, err := r.file(file.path)
+			if err != nil {
+				return n, false
+			}
+			want := min(int64(len(buf)-n), end-(offset+int64(n)))
+			read, err := f.ReadAt(buf[n:n+int(want)], offset+int64(n)-start)
+			if int64(read) != want {
+				return n, false
+			}
+			n += read
+		}
+		start = end
+	}
+	return n, n > 0
+}
+
+// file returns an open handle for path, opening it on first use
+func (r *spanReader) file(path string) (*os.File, error) {
+	if f, ok := r.open[path]; ok {
+		return f, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	r.open[path] = f
+	return f, nil
+}
+
+// Close closes every file opened by the reader
+func (r *spanReader) Close() {
+	for _, f := range r.open {
+		f.Close()
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// bencode encodes strings, ints, lists, and dictionaries for test torrents
+func bencode(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return fmt.Sprintf("%d:%s", len(v), v)
+	case int:
+		return fmt.Sprintf("i%de", v)
+	case []interface{}:
+		var b strings.Builder
+		b.WriteString("l")
+		for _, item := range v {
+			b.WriteString(bencode(item))
+		}
+		return b.String() + "e"
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		var b strings.Builder
+		b.WriteString("d")
+		for _, key := range keys {
+			b.WriteString(bencode(key) + bencode(v[key]))
+		}
+		return b.String() + "e"
+	}
+	panic(fmt.Sprintf("cannot bencode %T", v))
+}
+
+// writeTestTorrent writes a two-file torrent with 4-byte pieces for
+// "abcdefghij" split as abcdef + ghij, returning the .torrent path
+func writeTestTorrent(t *testing.T, dir string) string {
+	t.Helper()
+	content := "abcdefghij"
+	var pieces strings.Builder
+	for i := 0; i < len(content); i += 4 {
+		sum := sha1.Sum([]byte(content[i:min(i+4, len(content))]))
+		pieces.Write(sum[:])
+	}
+	info := map[string]interface{}{
+		"name":         "Book",
+		"piece length": 4,
+		"pieces":       pieces.String(),
+		"files": []interface{}{
+			map[string]interface{}{"length": 6, "path": []interface{}{"part1.mp3"}},
+			map[string]interface{}{"length": 4, "path": []interface{}{"disc2", "part2.mp3"}},
+		},
+	}
+	path := filepath.Join(dir, "abc.torrent")
+	if err := os.WriteFile(path, []byte(bencode(map[string]interface{}{"info": info})), 0644); err != nil {
+		t.Fatalf("Failed to write torrent: %v", err)
+	}
+	return path
+}
+
+// Test local verification finds corrupt pieces and skips missing files
+func TestVerifyPieces(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	torrentFile := writeTestTorrent(t, tmpDir)
+	data := filepath.Join(tmpDir, "data")
+	part1 := filepath.Join(data, "Book", "part1.mp3")
+	part2 := filepath.Join(data, "Book", "disc2", "part2.mp3")
+	os.MkdirAll(filepath.Dir(part2), 0755)
+
+	tests := []struct {
+		name  string
+		part1 string
+		part2 string
+		bad   int
+	}{
+		{"intact", "abcdef", "ghij", 0},
+		{"corrupt across files", "abcdef", "Xhij", 1},
+		{"corrupt first piece", "Xbcdef", "ghij", 1},
+		{"second file skipped", "abcdef", "", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.WriteFile(part1, []byte(tt.part1), 0644)
+			os.Remove(part2)
+			if tt.part2 != "" {
+				os.WriteFile(part2, []byte(tt.part2), 0644)
+			}
+			bad, err := verifyPieces(torrentFile, data)
+			if err != nil {
+				t.Fatalf("verifyPieces failed: %v", err)
+			}
+			if bad != tt.bad {
+				t.Errorf("Expected %d bad pieces, got %d", tt.bad, bad)
+			}
+		})
+	}
+
+	if _, err := verifyPieces(torrentFile, filepath.Join(tmpDir, "missing")); err == nil {
+		t.Error("Expected an error when no data is present")
+	}
+}
+
+// Test a recheck is requested on completion and its result recorded later
+func TestRecheckVerification(t *testing.T) {
+	var rechecked []interface{}
+	client := fakeDeluge(t, func(method string, params []interface{}) (interface{}, interface{}) {
+		if method == "core.force_recheck" {
+			rechecked = params
+		}
+		return nil, nil
+	})
+	config := Config{VerifyCompleted: &VerifyConfig{}}
+
+	entry := MagnetEntry{Hash: "abc", Title: "Book"}
+	if finished, err := startVerify(client, config, &entry, nil); err != nil || finished {
+		t.Fatalf("Expected a pending recheck, got finished=%v err=%v", finished, err)
+	}
+	if len(rechecked) != 1 || !entry.VerifyPending {
+		t.Fatalf("Recheck not requested: params=%v pending=%v", rechecked, entry.VerifyPending)
+	}
+
+	if finishRecheck(&entry, map[string]interface{}{"state": "Checking", "progress": 40.0}) {
+		t.Error("Recheck should still be running")
+	}
+
+	tests := []struct {
+		name     string
+		state    string
+		progress float64
+		verified bool
+	}{
+		{"intact", "Seeding", 100, true},
+		{"pieces lost", "Downloading", 97.5, false},
+		{"error", "Error", 100, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checked := entry
+			if !finishRecheck(&checked, map[string]interface{}{"state": tt.state, "progress": tt.progress}) {
+				t.Fatal("Recheck should be finished")
+			}
+			if checked.Verified == nil || *checked.Verified != tt.verified || checked.VerifyPending {
+				t.Errorf("Expected verified=%v, got %v (pending %v)", tt.verified, checked.Verified, checked.VerifyPending)
+			}
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// ViewConfig is a named filter over the database, e.g. "stuck" for retry
+// entries older than a week:
+//
+//	"views": {"stuck": {"section": "retry", "min_age_days": 7, "notify": true}}
+type ViewConfig struct {
+	Description string `json:"description,omitempty"`  // Shown by the views command and dashboard
+	Section     string `json:"section,omitempty"`      // added, retry, dead, removed, or empty for all but removed
+	Match       string `json:"match,omitempty"`        // Case-insensitive substring of title, torrent name, or hash
+	Label       string `json:"label,omitempty"`        // Only entries with this Deluge label
+	MinAgeDays  int    `json:"min_age_days,omitempty"` // Only entries first seen at least this many days ago
+	MaxAgeDays  int    `json:"max_age_days,omitempty"` // Only entries first seen at most this many days ago
+	MinRetries  int    `json:"min_retries,omitempty"`  // Only entries with at least this many failed attempts
+	Notify      bool   `json:"notify,omitempty"`       // Notify when entries enter the view after retry or check-complete
+}
+
+// ViewCount is a view with the number of entries it currently matches
+type ViewCount struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Count       int    `json:"count"`
+}
+
+// viewNames returns the configured view names in order
+func viewNames(config Config) []string {
+	names := make([]string, 0, len(config.Views))
+	for name := range config.Views {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// keep reports whether an entry passes the view's filters beyond section
+// and match, which EntryQuery handles itself
+func (v ViewConfig) keep(entry ListedEntry, config Config, now time.Time) bool {
+	if v.Label != "" && !strings.EqualFold(entryLabel(entry.MagnetEntry, config), v.Label) {
+		return false
+	}
+	if entry.RetryCount < v.MinRetries {
+		return false
+	}
+	if v.MinAgeDays > 0 || v.MaxAgeDays > 0 {
+		since, ok := firstFailure(entry.MagnetEntry)
+		if !ok {
+			return false
+		}
+		age := now.Sub(since)
+		if v.MinAgeDays > 0 && age < time.Duration(v.MinAgeDays)*24*time.Hour {
+			return false
+		}
+		if v.MaxAgeDays > 0 && age > time.Duration(v.MaxAgeDays)*24*time.Hour {
+			return false
+		}
+	}
+	return true
+}
+
+// viewQuery builds the query for a named view
+func viewQuery(config Config, name string, now time.Time) (EntryQuery, error) {
+	view, ok := config.Views[name]
+	if !ok {
+		if len(config.Views) == 0 {
+			return EntryQuery{}, fmt.Errorf("unknown view %q (no views are configured)", name)
+		}
+		return EntryQuery{}, fmt.Errorf("unknown view %q (available: %s)", name, strings.Join(viewNames(config), ", "))
+	}
+	switch view.Section {
+	case "", SectionAdded, SectionRetry, SectionDead, SectionFailed, SectionRemoved:
+	default:
+		return EntryQuery{}, fmt.Errorf("view %q has unknown section %q", name, view.Section)
+	}
+	return EntryQuery{
+		Section: view.Section,
+		Match:   view.Match,
+		Dead:    deadFilter(config),
+		Failed:  failedFilter(config),
+		Keep: func(entry ListedEntry) bool {
+			return view.keep(entry, config, now)
+		},
+	}, nil
+}
+
+// countViews returns how many entries each configured view matches
+func countViews(db *MagnetDatabase, config Config, now time.Time) []ViewCount {
+	counts := make([]ViewCount, 0, len(config.Views))
+	for _, name := range viewNames(config) {
+		count := ViewCount{Name: name, Description: config.Views[name].Description}
+		if query, err := viewQuery(config, name, now); err == nil {
+			count.Count = QueryEntries(db, query).Total
+		}
+		counts = append(counts, count)
+	}
+	return counts
+}
+
+// viewAlertsPath returns where the entries already notified per view are kept
+func viewAlertsPath() (string, error) {
+	homeDir, err := getHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, ".magnet-handler", "view-alerts.json"), nil
+}
+
+// notifyViews sends a notification for each notify view that gained
+// entries since the last check. Entries that leave a view and come back
+// are announced again.
+func notifyViews(config Config) error {
+	var watched []string
+	for _, name := range viewNames(config) {
+		if config.Views[name].Notify {
+			watched = append(watched, name)
+		}
+	}
+	if len(watched) == 0 {
+		return nil
+	}
+
+	db, err := LoadJSONDatabase(config.JSONPath)
+	if err != nil {
+		return fmt.Errorf("failed to load database: %w", err)
+	}
+	path, err := viewAlertsPath()
+	if err != nil {
+		return err
+	}
+	seen := make(map[string][]string)
+	if data, err := os.ReadFile(path); err == nil {
+		json.Unmarshal(data, &seen)
+	}
+
+	now := time.Now()
+	current := make(map[string][]string)
+	for _, name := range watched {
+		query, err := viewQuery(config, name, now)
+		if err != nil {
+			log.Printf("Warning: %v", err)
+			continue
+		}
+		known := make(map[string]bool)
+		for _, hash := range seen[name] {
+			known[hash] = true
+		}
+
+		var entered []string
+		for _, entry := range QueryEntries(db, query).Entries {
+			current[name] = append(current[name], entry.Hash)
+			if !known[entry.Hash] {
+				entered = append(entered, listColumns["title"](entry))
+			}
+		}
+		if len(entered) == 0 {
+			continue
+		}
+
+		shown := entered[:min(len(entered), 5)]
+		message := strings.Join(shown, "\n")
+		if more := len(entered) - len(shown); more > 0 {
+			message += fmt.Sprintf("\n...and %d more", more)
+		}
+		Notify(NotifyWarning, fmt.Sprintf("%s entered view %q", plural(len(entered), "entry", "entries"), name), message)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(current, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// runViews implements the views command
+func runViews(config Config, args []string) error {
+	fs := newCommandFlags(viewsCommand)
+	asJSON := fs.Bool("json", false, "Print the counts as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	db, err := LoadJSONDatabase(config.JSONPath)
+	if err != nil {
+		return fmt.Errorf("failed to load database: %w", err)
+	}
+	counts := countViews(db, config, time.Now())
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(counts)
+	}
+	if len(counts) == 0 {
+		fmt.Println("No views configured (add them under \"views\" in the config file)")
+		return nil
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "VIEW\tENTRIES\tDESCRIPTION")
+	for _, count := range counts {
+		fmt.Fprintf(tw, "%s\t%d\t%s\n", count.Name, count.Count, count.Description)
+	}
+	return tw.Flush()
+}
+
+var viewsCommand = &Command{
+	Name:    "views",
+	Usage:   "views [--json]",
+	Summary: "Show how many entries each saved view matches",
+}
+
+func init() {
+	viewsCommand.Run = runViews
+	registerCommand(viewsCommand)
+}
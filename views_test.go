@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test viewQuery combines section, label, age, and retry filters
+func TestViewQuery(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	old := now.AddDate(0, 0, -10).Format(time.RFC3339)
+	recent := now.AddDate(0, 0, -2).Format(time.RFC3339)
+	db := &MagnetDatabase{
+		Added: map[string]MagnetEntry{
+			"a1": {Hash: "a1", Title: "Old Added", AddedDate: old, Label: "books"},
+		},
+		Retry: map[string]MagnetEntry{
+			"r1": {Hash: "r1", Title: "Old Retry", AddedDate: old, RetryCount: 5},
+			"r2": {Hash: "r2", Title: "Recent Retry", AddedDate: recent, RetryCount: 1},
+			"r3": {Hash: "r3", Title: "Old Podcast", AddedDate: old, RetryCount: 2, Label: "podcasts"},
+		},
+	}
+	config := Config{DelugeLabel: "books", Views: map[string]ViewConfig{
+		"stuck":     {Section: SectionRetry, MinAgeDays: 7},
+		"fresh":     {MaxAgeDays: 7},
+		"books":     {Label: "Books"},
+		"hammered":  {MinRetries: 3},
+		"old-books": {Section: SectionRetry, Label: "books", MinAgeDays: 7},
+	}}
+
+	tests := []struct {
+		view string
+		want []string
+	}{
+		{"stuck", []string{"r1", "r3"}},
+		{"fresh", []string{"r2"}},
+		{"books", []string{"a1", "r1", "r2"}},
+		{"hammered", []string{"r1"}},
+		{"old-books", []string{"r1"}},
+	}
+
+	for _, tt := range tests {
+		query, err := viewQuery(config, tt.view, now)
+		if err != nil {
+			t.Fatalf("viewQuery(%q) failed: %v", tt.view, err)
+		}
+		var got []string
+		for _, entry := range QueryEntries(db, query).Entries {
+			got = append(got, entry.Hash)
+		}
+		sort.Strings(got)
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("view %s matched %v, want %v", tt.view, got, tt.want)
+		}
+	}
+
+	if _, err := viewQuery(config, "missing", now); err == nil || !strings.Contains(err.Error(), "stuck") {
+		t.Errorf("Expected unknown view error listing views, got %v", err)
+	}
+	config.Views["broken"] = ViewConfig{Section: "later"}
+	if _, err := viewQuery(config, "broken", now); err == nil {
+		t.Error("Expected error for a view with an unknown section")
+	}
+}
+
+// Test notifyViews announces entries only when they enter a view
+func TestNotifyViews(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	t.Setenv("HOME", tmpDir)
+
+	var sent []Notification
+	original := notifiers
+	notifiers = []Notifier{recordingNotifier{&sent}}
+	defer func() { notifiers = original }()
+
+	config := Config{
+		JSONPath: filepath.Join(tmpDir, "db.json"),
+		Views: map[string]ViewConfig{
+			"failing": {Section: SectionRetry, MinRetries: 2, Notify: true},
+			"quiet":   {Section: SectionRetry},
+		},
+	}
+	db := &MagnetDatabase{Retry: map[string]MagnetEntry{
+		"r1": {Hash: "r1", Title: "Stuck Book", RetryCount: 3},
+		"r2": {Hash: "r2", Title: "New Book", RetryCount: 1},
+	}}
+	if err := SaveDatabaseLocal(config.JSONPath, db); err != nil {
+		t.Fatalf("Failed to save database: %v", err)
+	}
+
+	if err := notifyViews(config); err != nil {
+		t.Fatalf("notifyViews failed: %v", err)
+	}
+	if len(sent) != 1 || !strings.Contains(sent[0].Title, `"failing"`) || sent[0].Message != "Stuck Book" {
+		t.Fatalf("Expected one notification for Stuck Book, got %+v", sent)
+	}
+
+	// Nothing new: no second notification
+	if err := notifyViews(config); err != nil {
+		t.Fatalf("notifyViews failed: %v", err)
+	}
+	if len(sent) != 1 {
+		t.Fatalf("Expected no repeat notification, got %+v", sent)
+	}
+
+	// Another entry crosses the threshold
+	db.Retry["r2"] = MagnetEntry{Hash: "r2", Title: "New Book", RetryCount: 2}
+	if err := SaveDatabaseLocal(config.JSONPath, db); err != nil {
+		t.Fatalf("Failed to save database: %v", err)
+	}
+	if err := notifyViews(config); err != nil {
+		t.Fatalf("notifyViews failed: %v", err)
+	}
+	if len(sent) != 2 || sent[1].Message != "New Book" {
+		t.Fatalf("Expected a notification for New Book only, got %+v", sent)
+	}
+}
+
+// Test the entries API accepts a view and the views API reports counts
+func TestHandleEntriesView(t *testing.T) {
+	server, _ := newConfiguredAPIServer(t, func(config *Config) {
+		config.Views = map[string]ViewConfig{"stuck": {Section: SectionRetry, MinRetries: 2, Description: "Failing repeatedly"}}
+	})
+	defer server.Close()
+
+	resp := apiRequest(t, http.MethodGet, server.URL+"/api/entries?view=stuck", nil)
+	var page EntryPage
+	json.NewDecoder(resp.Body).Decode(&page)
+	resp.Body.Close()
+	if page.Total != 1 || page.Entries[0].Hash != "hash2" {
+		t.Errorf("Expected only the retry entry, got %+v", page)
+	}
+
+	resp = apiRequest(t, http.MethodGet, server.URL+"/api/entries?view=missing", nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected 400 for unknown view, got %d", resp.StatusCode)
+	}
+
+	resp = apiRequest(t, http.MethodGet, server.URL+"/api/views", nil)
+	var counts []ViewCount
+	json.NewDecoder(resp.Body).Decode(&counts)
+	resp.Body.Close()
+	if len(counts) != 1 || counts[0].Name != "stuck" || counts[0].Count != 1 || counts[0].Description != "Failing repeatedly" {
+		t.Errorf("Unexpected view counts: %+v", counts)
+	}
+}
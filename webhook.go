@@ -0,0 +1,142 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// webhookSecretHeader carries the shared secret on webhook requests
+const webhookSecretHeader = "X-Webhook-Secret"
+
+// maxWebhookBody caps the size of a webhook payload
+const maxWebhookBody = 1 << 20
+
+// webhookURLKeys are payload fields that hold download links in Sonarr,
+// Radarr and Prowlarr style payloads. Other URLs (info pages, posters) are
+// ignored; magnet URIs are picked up from any field.
+var webhookURLKeys = map[string]bool{
+	"magneturl":   true,
+	"magnet":      true,
+	"downloadurl": true,
+	"torrenturl":  true,
+	"link":        true,
+	"url":         true,
+}
+
+// WebhookResult reports what happened to one link from a webhook payload
+type WebhookResult struct {
+	Link   string `json:"link"`
+	Status string `json:"status"` // processed or error
+	Error  string `json:"error,omitempty"`
+}
+
+// extractWebhookLinks finds magnet URIs and torrent URLs in a webhook body,
+// which is either JSON or plain text with one link per line
+func extractWebhookLinks(body []byte) []string {
+	var links []string
+	seen := map[string]bool{}
+	add := func(link string) {
+		link = strings.TrimSpace(link)
+		if link != "" && !seen[link] {
+			seen[link] = true
+			links = append(links, link)
+		}
+	}
+
+	var payload interface{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		for _, line := range strings.Split(string(body), "\n") {
+			if line = strings.TrimSpace(line); isMagnetLink(line) || isTorrentURL(line) {
+				add(line)
+			}
+		}
+		return links
+	}
+
+	var walk func(key string, v interface{})
+	walk = func(key string, v interface{}) {
+		switch v := v.(type) {
+		case string:
+			if isMagnetLink(v) || (webhookURLKeys[strings.ToLower(key)] && isTorrentURL(v)) {
+				add(v)
+			}
+		case map[string]interface{}:
+			for k, child := range v {
+				walk(k, child)
+			}
+		case []interface{}:
+			for _, child := range v {
+				walk(key, child)
+			}
+		}
+	}
+	walk("", payload)
+	return links
+}
+
+// isMagnetLink reports whether s looks like a magnet URI
+func isMagnetLink(s string) bool {
+	return strings.HasPrefix(strings.ToLower(strings.TrimSpace(s)), "magnet:")
+}
+
+// isTorrentURL reports whether s is an HTTP(S) download link
+func isTorrentURL(s string) bool {
+	lower := strings.ToLower(strings.TrimSpace(s))
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+}
+
+// handleWebhook accepts payloads from *arr apps and adds each magnet URI or
+// torrent URL they contain through the normal add path
+func (s *apiServer) handleWebhook(w http.ResponseWriter, r *http.Request) {
+	if s.config.WebhookSecret == "" {
+		writeError(w, http.StatusNotFound, fmt.Errorf("webhooks are disabled: set webhook_secret in the config"))
+		return
+	}
+	secret := r.Header.Get(webhookSecretHeader)
+	if subtle.ConstantTimeCompare([]byte(secret), []byte(s.config.WebhookSecret)) != 1 {
+		writeError(w, http.StatusUnauthorized, fmt.Errorf("invalid or missing %s header", webhookSecretHeader))
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBody))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("failed to read request body: %w", err))
+		return
+	}
+
+	links := extractWebhookLinks(body)
+	if len(links) > 0 && !s.admit(w) {
+		return
+	}
+	if len(links) == 0 {
+		// *arr apps send a Test event with no release when saving the webhook
+		var event struct {
+			EventType string `json:"eventType"`
+		}
+		if json.Unmarshal(body, &event) == nil && strings.EqualFold(event.EventType, "Test") {
+			writeJSON(w, http.StatusOK, map[string]interface{}{"results": []WebhookResult{}})
+			return
+		}
+		writeError(w, http.StatusUnprocessableEntity, fmt.Errorf("no magnet URIs or torrent URLs in payload"))
+		return
+	}
+
+	correlationID := w.Header().Get(correlationHeader)
+	log.Printf("[%s] Webhook from %s with %d link(s)", correlationID, r.RemoteAddr, len(links))
+	results := make([]WebhookResult, 0, len(links))
+	for _, link := range links {
+		result := WebhookResult{Link: link, Status: "processed"}
+		job := workJob{Kind: jobAdd, URI: link, Source: sourceWebhook, CorrelationID: correlationID}
+		if err := s.submit(r.Context(), job); err != nil {
+			log.Printf("✗ Webhook link failed: %v", err)
+			result.Status, result.Error = "error", err.Error()
+		}
+		results = append(results, result)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"results": results, "correlation_id": w.Header().Get(correlationHeader)})
+}
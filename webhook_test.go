@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Test extractWebhookLinks on *arr style and plain-text payloads
+func TestExtractWebhookLinks(t *testing.T) {
+	magnet := "magnet:?xt=urn:btih:0123456789abcdef0123456789abcdef01234567"
+	tests := []struct {
+		name     string
+		body     string
+		expected []string
+	}{
+		{
+			name:     "prowlarr grab",
+			body:     `{"eventType":"Grab","release":{"title":"Book","magnetUrl":"` + magnet + `","infoUrl":"https://indexer.example/info/1"}}`,
+			expected: []string{magnet},
+		},
+		{
+			name:     "download url",
+			body:     `{"eventType":"Grab","release":{"downloadUrl":"https://indexer.example/dl/1","infoUrl":"https://indexer.example/info/1"}}`,
+			expected: []string{"https://indexer.example/dl/1"},
+		},
+		{
+			name:     "duplicates collapsed",
+			body:     `{"magnetUrl":"` + magnet + `","releases":[{"guid":"` + magnet + `"}]}`,
+			expected: []string{magnet},
+		},
+		{
+			name:     "plain text",
+			body:     magnet + "\nnot a link\nhttps://indexer.example/dl/2\n",
+			expected: []string{magnet, "https://indexer.example/dl/2"},
+		},
+		{
+			name:     "test event",
+			body:     `{"eventType":"Test"}`,
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := extractWebhookLinks([]byte(tt.body)); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("extractWebhookLinks() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+// newWebhookServer starts a simulated daemon with the given webhook secret
+func newWebhookServer(t *testing.T, secret string) (*httptest.Server, Config) {
+	t.Helper()
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(tmpDir) })
+	t.Setenv("HOME", tmpDir)
+
+	config := DefaultConfig()
+	config.WebhookSecret = secret
+	enableSimulation(&config, tmpDir)
+	config.RemotePath = ""
+	t.Cleanup(func() { simulateDeluge = false })
+
+	server := httptest.NewServer(newAPIServer(config).handler())
+	t.Cleanup(server.Close)
+	return server, config
+}
+
+// postWebhook sends a webhook payload with an optional secret
+func postWebhook(t *testing.T, url, secret, body string) *http.Response {
+	t.Helper()
+	req, _ := http.NewRequest("POST", url+"/api/webhook", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if secret != "" {
+		req.Header.Set(webhookSecretHeader, secret)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST /api/webhook failed: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+// Test the webhook rejects requests without the shared secret
+func TestWebhookAuth(t *testing.T) {
+	disabled, _ := newWebhookServer(t, "")
+	if resp := postWebhook(t, disabled.URL, "anything", `{}`); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Disabled webhook: expected 404, got %d", resp.StatusCode)
+	}
+
+	server, _ := newWebhookServer(t, "s3cret")
+	if resp := postWebhook(t, server.URL, "", `{}`); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Missing secret: expected 401, got %d", resp.StatusCode)
+	}
+	if resp := postWebhook(t, server.URL, "wrong", `{}`); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Wrong secret: expected 401, got %d", resp.StatusCode)
+	}
+	if resp := postWebhook(t, server.URL, "s3cret", `{"eventType":"Test"}`); resp.StatusCode != http.StatusOK {
+		t.Errorf("Test event: expected 200, got %d", resp.StatusCode)
+	}
+	if resp := postWebhook(t, server.URL, "s3cret", `{"eventType":"Grab"}`); resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("Payload without links: expected 422, got %d", resp.StatusCode)
+	}
+}
+
+// Test webhook links are added, following indexer redirects to magnets
+func TestWebhookAddsLinks(t *testing.T) {
+	server, config := newWebhookServer(t, "s3cret")
+	direct := "magnet:?xt=urn:btih:0123456789abcdef0123456789abcdef01234567&dn=Direct"
+	redirected := "magnet:?xt=urn:btih:89abcdef0123456789abcdef0123456789abcdef&dn=Redirected"
+
+	indexer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/dl/magnet":
+			http.Redirect(w, r, "/dl/final", http.StatusFound)
+		case "/dl/final":
+			http.Redirect(w, r, redirected, http.StatusFound)
+		default:
+			w.Header().Set("Content-Type", "application/x-bittorrent")
+			w.Write([]byte("d8:announce0:e"))
+		}
+	}))
+	defer indexer.Close()
+
+	body := `[{"release":{"magnetUrl":"` + direct + `"}},{"release":{"downloadUrl":"` + indexer.URL + `/dl/magnet"}},{"release":{"downloadUrl":"` + indexer.URL + `/dl/file.torrent"}}]`
+	resp := postWebhook(t, server.URL, "s3cret", body)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", resp.StatusCode)
+	}
+	var response struct {
+		Results []WebhookResult `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(response.Results) != 3 {
+		t.Fatalf("Expected 3 results, got %+v", response.Results)
+	}
+	if response.Results[0].Status != "processed" || response.Results[1].Status != "processed" {
+		t.Errorf("Magnet links should be processed: %+v", response.Results)
+	}
+	if response.Results[2].Status != "error" || !strings.Contains(response.Results[2].Error, ".torrent file") {
+		t.Errorf("Torrent file link should be rejected: %+v", response.Results[2])
+	}
+
+	db, err := LoadJSONDatabase(config.JSONPath)
+	if err != nil {
+		t.Fatalf("Failed to load database: %v", err)
+	}
+	for _, hash := range []string{"0123456789abcdef0123456789abcdef01234567", "89abcdef0123456789abcdef0123456789abcdef"} {
+		if _, ok := db.Added[hash]; !ok {
+			t.Errorf("Expected %s in added section", hash)
+		}
+	}
+}
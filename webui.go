@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+)
+
+// defaultWebUILink opens the Web UI with the torrent's hash in the fragment.
+// Deluge's own UI shows its torrent list; proxies and other front ends can
+// use webui_link to jump straight to the torrent.
+const defaultWebUILink = "{url}/#torrent={hash}"
+
+// webUI holds the Web UI link settings from the config
+var webUI struct {
+	URL  string
+	Link string
+}
+
+// ConfigureWebUI applies the Web UI link settings. Without webui_url links
+// point at the Deluge host and port the handler talks to.
+func ConfigureWebUI(config Config) error {
+	webUI.URL, webUI.Link = "", ""
+	base := config.WebUIURL
+	if base == "" {
+		if config.DelugeHost == "" || config.DelugePort == "" {
+			return nil
+		}
+		base = fmt.Sprintf("http://%s:%s", config.DelugeHost, config.DelugePort)
+	} else if u, err := url.Parse(base); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("webui_url %q is not an http or https URL", base)
+	}
+	link := config.WebUILink
+	if link == "" {
+		link = defaultWebUILink
+	} else if !strings.Contains(link, "{hash}") {
+		return fmt.Errorf("webui_link %q has no {hash} placeholder", link)
+	}
+	webUI.URL, webUI.Link = strings.TrimRight(base, "/"), link
+	if u, err := url.Parse(webUILink("0")); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		webUI.URL, webUI.Link = "", ""
+		return fmt.Errorf("webui_link %q does not make http or https links", link)
+	}
+	return nil
+}
+
+// webUILink returns the Web UI link for a torrent, or "" when there is no
+// hash or no Web UI configured
+func webUILink(hash string) string {
+	if hash == "" || webUI.Link == "" {
+		return ""
+	}
+	return strings.NewReplacer("{url}", webUI.URL, "{hash}", url.PathEscape(strings.ToLower(hash))).Replace(webUI.Link)
+}
+
+// entryWebUILink returns the Web UI link for an entry in a section, or ""
+// unless the entry was added to Deluge
+func entryWebUILink(section, hash string) string {
+	if section != SectionAdded {
+		return ""
+	}
+	return webUILink(hash)
+}
+
+// withWebUILink appends the Web UI link for a torrent to a notification
+// message, if there is one
+func withWebUILink(message, hash string) string {
+	if link := webUILink(hash); link != "" {
+		return message + "\n" + link
+	}
+	return message
+}
+
+// logWebUILink logs the Web UI link for a torrent in Deluge, if there is one
+func logWebUILink(logger *log.Logger, hash string) {
+	if link := webUILink(hash); link != "" {
+		logger.Printf("  Web UI: %s", link)
+	}
+}
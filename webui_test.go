@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+)
+
+// Test Web UI links default to the Deluge address and follow webui_url and
+// webui_link
+func TestWebUILink(t *testing.T) {
+	defer ConfigureWebUI(Config{})
+	hash := "0123456789ABCDEF0123456789ABCDEF01234567"
+
+	tests := []struct {
+		config Config
+		want   string
+	}{
+		{Config{DelugeHost: "10.0.0.2", DelugePort: "8112"}, "http://10.0.0.2:8112/#torrent=0123456789abcdef0123456789abcdef01234567"},
+		{Config{DelugeHost: "10.0.0.2", DelugePort: "8112", WebUIURL: "https://deluge.example.com/"}, "https://deluge.example.com/#torrent=0123456789abcdef0123456789abcdef01234567"},
+		{Config{WebUIURL: "https://torrents.example.com", WebUILink: "{url}/details/{hash}"}, "https://torrents.example.com/details/0123456789abcdef0123456789abcdef01234567"},
+		{Config{}, ""},
+	}
+	for _, tt := range tests {
+		if err := ConfigureWebUI(tt.config); err != nil {
+			t.Fatalf("ConfigureWebUI(%+v) failed: %v", tt.config, err)
+		}
+		if got := webUILink(hash); got != tt.want {
+			t.Errorf("ConfigureWebUI(%+v): expected %q, got %q", tt.config, tt.want, got)
+		}
+	}
+
+	ConfigureWebUI(Config{DelugeHost: "10.0.0.2", DelugePort: "8112"})
+	if webUILink("") != "" || entryWebUILink(SectionRetry, hash) != "" || entryWebUILink(SectionAdded, hash) == "" {
+		t.Error("Only entries added to Deluge should get links")
+	}
+	if got := withWebUILink("Dune", hash); got != "Dune\nhttp://10.0.0.2:8112/#torrent=0123456789abcdef0123456789abcdef01234567" {
+		t.Errorf("Unexpected notification message: %q", got)
+	}
+
+	for _, config := range []Config{
+		{WebUIURL: "deluge.local:8112"},
+		{WebUIURL: "https://deluge.example.com", WebUILink: "{url}/torrents"},
+		{WebUIURL: "https://deluge.example.com", WebUILink: "javascript:alert('{hash}')"},
+	} {
+		if err := ConfigureWebUI(config); err == nil {
+			t.Errorf("Expected an error for %+v", config)
+		}
+	}
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Work queue job kinds
+const (
+	jobAdd       = "add"       // Add a magnet URI
+	jobRetry     = "retry"     // Drain the retry queue
+	jobSync      = "sync"      // Merge with the remote database
+	jobCompact   = "compact"   // Fold old oplog operations into its base
+	jobBlocklist = "blocklist" // Import hashes from the blocklist sources
+)
+
+// workJob is a unit of daemon work that has been accepted but not finished
+type workJob struct {
+	ID            string `json:"id"`
+	Kind          string `json:"kind"`
+	URI           string `json:"uri,omitempty"`
+	Source        string `json:"source,omitempty"` // Where it was submitted from, for logs
+	CorrelationID string `json:"correlation_id,omitempty"`
+	AcceptedAt    string `json:"accepted_at"`
+	NotBefore     string `json:"not_before,omitempty"` // Scheduled jobs wait until this time
+}
+
+// workQueue is the daemon's persisted job queue. Jobs are written to disk
+// before they are acknowledged and removed only after they run, so a
+// restart replays anything accepted but unfinished (at least once).
+type workQueue struct {
+	path    string
+	process func(workJob) error
+
+	mu      sync.Mutex
+	jobs    []workJob
+	waiters map[string]chan error
+	wake    chan struct{}
+}
+
+// workQueuePath returns where the daemon keeps its pending jobs
+func workQueuePath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, ".magnet-handler", "daemon-queue.json"), nil
+}
+
+// openWorkQueue loads any jobs left from a previous run
+func openWorkQueue(path string, process func(workJob) error) (*workQueue, error) {
+	q := &workQueue{path: path, process: process, waiters: make(map[string]chan error), wake: make(chan struct{}, 1)}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read work queue: %w", err)
+	}
+	if len(data) > 0 {
+		var stored struct {
+			Jobs []workJob `json:"jobs"`
+		}
+		if err := json.Unmarshal(data, &stored); err != nil {
+			return nil, fmt.Errorf("failed to parse work queue: %w", err)
+		}
+		q.jobs = stored.Jobs
+	}
+	return q, nil
+}
+
+// save writes the pending jobs atomically; callers hold q.mu
+func (q *workQueue) save() error {
+	data, err := json.MarshalIndent(struct {
+		Jobs []workJob `json:"jobs"`
+	}{q.jobs}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(q.path), 0755); err != nil {
+		return err
+	}
+	tmp := q.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, q.path)
+}
+
+// Submit persists a job and returns a channel that receives its result
+func (q *workQueue) Submit(job workJob) (<-chan error, error) {
+	if job.ID == "" {
+		job.ID = GenerateUUID()
+	}
+	if job.AcceptedAt == "" {
+		job.AcceptedAt = time.Now().Format(time.RFC3339)
+	}
+
+	q.mu.Lock()
+	q.jobs = append(q.jobs, job)
+	if err := q.save(); err != nil {
+		q.jobs = q.jobs[:len(q.jobs)-1]
+		q.mu.Unlock()
+		return nil, fmt.Errorf("failed to persist job: %w", err)
+	}
+	done := make(chan error, 1)
+	q.waiters[job.ID] = done
+	q.mu.Unlock()
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return done, nil
+}
+
+// Len returns the number of pending jobs, including scheduled ones
+func (q *workQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.jobs)
+}
+
+// Pending returns the number of jobs waiting to run now, not counting
+// scheduled ones
+func (q *workQueue) Pending() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	pending := 0
+	for _, job := range q.jobs {
+		if job.NotBefore == "" {
+			pending++
+		}
+	}
+	return pending
+}
+
+// hasScheduled reports whether a job from source is pending, ignoring the
+// job with ID except
+func (q *workQueue) hasScheduled(source, except string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, job := range q.jobs {
+		if job.Source == source && job.ID != except {
+			return true
+		}
+	}
+	return false
+}
+
+// next returns the oldest job that is due, or how long until one will be
+func (q *workQueue) next(now time.Time) (workJob, time.Duration, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	wait := time.Hour
+	for _, job := range q.jobs {
+		if job.NotBefore == "" {
+			return job, 0, true
+		}
+		due, err := time.Parse(time.RFC3339, job.NotBefore)
+		if err != nil || !due.After(now) {
+			return job, 0, true
+		}
+		wait = min(wait, due.Sub(now))
+	}
+	return workJob{}, wait, false
+}
+
+// finish removes a completed job and reports its result to the submitter
+func (q *workQueue) finish(id string, result error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for i, job := range q.jobs {
+		if job.ID == id {
+			q.jobs = append(q.jobs[:i], q.jobs[i+1:]...)
+			break
+		}
+	}
+	if err := q.save(); err != nil {
+		log.Printf("Warning: Failed to save work queue: %v", err)
+	}
+	if done, ok := q.waiters[id]; ok {
+		done <- result
+		delete(q.waiters, id)
+	}
+}
+
+// Run processes jobs one at a time until ctx is cancelled
+func (q *workQueue) Run(ctx context.Context) {
+	if n := q.Len(); n > 0 {
+		log.Printf("Resuming %d queued job(s) from the last run", n)
+	}
+	for {
+		job, wait, ok := q.next(time.Now())
+		if ok {
+			q.finish(job.ID, q.process(job))
+			continue
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-q.wake:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// Sources for queued jobs
+const (
+	sourceWebhook       = "webhook"
+	sourceHomeAssistant = "home-assistant"
+	sourceSchedule      = "schedule"
+)
+
+// startQueue opens the persisted work queue, resumes any unfinished jobs,
+// and processes submissions until ctx is cancelled
+func (s *apiServer) startQueue(ctx context.Context) error {
+	path, err := workQueuePath()
+	if err != nil {
+		return err
+	}
+	queue, err := openWorkQueue(path, s.runJob)
+	if err != nil {
+		return err
+	}
+	s.queue = queue
+	s.scheduleRetry("")
+	s.scheduleSync("")
+	s.scheduleCompact("")
+	s.scheduleBlocklist("")
+	go queue.Run(ctx)
+	return nil
+}
+
+// scheduleRetry queues the next periodic retry-queue drain unless one is
+// already pending (other than the job identified by current)
+func (s *apiServer) scheduleRetry(current string) {
+	if s.config.RetryInterval <= 0 || s.queue.hasScheduled(sourceSchedule, current) {
+		return
+	}
+	due := time.Now().Add(time.Duration(s.config.RetryInterval) * time.Minute)
+	job := workJob{Kind: jobRetry, Source: sourceSchedule, CorrelationID: newCorrelationID(), NotBefore: due.Format(time.RFC3339)}
+	if _, err := s.queue.Submit(job); err != nil {
+		log.Printf("Warning: Failed to schedule retry: %v", err)
+	}
+}
+
+// submit runs a job through the work queue and waits for its result. Without
+// a queue (as in tests and one-off handlers) the job runs immediately.
+func (s *apiServer) submit(ctx context.Context, job workJob) error {
+	if s.queue == nil {
+		return s.runJob(job)
+	}
+	done, err := s.queue.Submit(job)
+	if err != nil {
+		return err
+	}
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("request ended before the job finished; it will still run")
+	}
+}
+
+// runJob performs one queued job
+func (s *apiServer) runJob(job workJob) error {
+	config := s.config.withCorrelationID(job.CorrelationID)
+	config.logger().Printf("Running %s job from %s", job.Kind, job.Source)
+	switch job.Kind {
+	case jobAdd:
+		defer s.noteMutation()
+		return AddMagnetToDeluge(job.URI, config)
+	case jobRetry:
+		if job.Source == sourceSchedule && s.queue != nil {
+			defer s.scheduleRetry(job.ID)
+		}
+		defer s.noteMutation()
+		return ProcessRetryQueue(config)
+	case jobSync:
+		if s.queue != nil {
+			defer s.scheduleSync(job.ID)
+		}
+		return s.syncRemote()
+	case jobCompact:
+		if s.queue != nil {
+			defer s.scheduleCompact(job.ID)
+		}
+		return s.compactOplogJob(config)
+	case jobBlocklist:
+		if s.queue != nil {
+			defer s.scheduleBlocklist(job.ID)
+		}
+		return s.importBlocklistJob()
+	default:
+		return fmt.Errorf("unknown job kind %q", job.Kind)
+	}
+}
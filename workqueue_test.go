@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Test jobs accepted before a restart are replayed by the next run
+func TestWorkQueueReplay(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	path := filepath.Join(tmpDir, "daemon-queue.json")
+
+	// Accept two jobs, then "crash" before the worker runs
+	first, err := openWorkQueue(path, func(workJob) error { return nil })
+	if err != nil {
+		t.Fatalf("openWorkQueue failed: %v", err)
+	}
+	for _, uri := range []string{"magnet:?one", "magnet:?two"} {
+		if _, err := first.Submit(workJob{Kind: jobAdd, URI: uri}); err != nil {
+			t.Fatalf("Submit failed: %v", err)
+		}
+	}
+
+	processed := make(chan string, 2)
+	second, err := openWorkQueue(path, func(job workJob) error {
+		processed <- job.URI
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("openWorkQueue failed: %v", err)
+	}
+	if second.Len() != 2 {
+		t.Fatalf("Expected 2 pending jobs after restart, got %d", second.Len())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go second.Run(ctx)
+
+	for _, want := range []string{"magnet:?one", "magnet:?two"} {
+		select {
+		case got := <-processed:
+			if got != want {
+				t.Errorf("Expected %s, got %s", want, got)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for replayed job")
+		}
+	}
+
+	// Finishing is recorded after processing; wait for the file to catch up
+	deadline := time.Now().Add(5 * time.Second)
+	for second.Len() > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	reopened, err := openWorkQueue(path, nil)
+	if err != nil {
+		t.Fatalf("openWorkQueue failed: %v", err)
+	}
+	if reopened.Len() != 0 {
+		t.Errorf("Finished jobs should be removed from disk, %d left", reopened.Len())
+	}
+}
+
+// Test scheduled jobs wait for their time while immediate jobs run
+func TestWorkQueueNext(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	q := &workQueue{jobs: []workJob{
+		{ID: "later", NotBefore: now.Add(10 * time.Minute).Format(time.RFC3339)},
+		{ID: "soon", NotBefore: now.Add(2 * time.Minute).Format(time.RFC3339)},
+	}}
+
+	if _, wait, ok := q.next(now); ok || wait != 2*time.Minute {
+		t.Errorf("Expected to wait 2m, got ok=%v wait=%v", ok, wait)
+	}
+
+	q.jobs = append(q.jobs, workJob{ID: "now"})
+	if job, _, ok := q.next(now); !ok || job.ID != "now" {
+		t.Errorf("Expected the unscheduled job, got %+v", job)
+	}
+	if job, _, ok := q.next(now.Add(5 * time.Minute)); !ok || job.ID != "soon" {
+		t.Errorf("Expected the due job first, got %+v", job)
+	}
+}
+
+// Test daemon submissions go through the persisted queue and report results
+func TestServerQueuedSubmit(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "magnet-handler-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	t.Setenv("HOME", tmpDir)
+
+	config := DefaultConfig()
+	config.WebhookSecret = "s3cret"
+	config.RetryInterval = 60
+	enableSimulation(&config, tmpDir)
+	config.RemotePath = ""
+	defer func() { simulateDeluge = false }()
+
+	api := newAPIServer(config)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := api.startQueue(ctx); err != nil {
+		t.Fatalf("startQueue failed: %v", err)
+	}
+	if !api.queue.hasScheduled(sourceSchedule, "") {
+		t.Error("A periodic retry should be scheduled")
+	}
+	server := httptest.NewServer(api.handler())
+	defer server.Close()
+
+	hash := "0123456789abcdef0123456789abcdef01234567"
+	body := fmt.Sprintf(`{"release":{"magnetUrl":"magnet:?xt=urn:btih:%s&dn=Queued"}}`, hash)
+	if resp := postWebhook(t, server.URL, "s3cret", body); resp.StatusCode != 200 {
+		t.Fatalf("Expected 200, got %d", resp.StatusCode)
+	}
+
+	db, err := LoadJSONDatabase(config.JSONPath)
+	if err != nil {
+		t.Fatalf("LoadJSONDatabase failed: %v", err)
+	}
+	if _, ok := db.Added[hash]; !ok {
+		t.Error("Queued magnet should be added before the response")
+	}
+	if n := api.queue.Len(); n != 1 {
+		t.Errorf("Only the scheduled retry should remain queued, got %d jobs", n)
+	}
+}
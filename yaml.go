@@ -0,0 +1,371 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The config file accepts the subset of YAML a hand-written config needs:
+// block mappings and sequences nested by indentation, plain, single-, and
+// double-quoted scalars, flow sequences of scalars, and # comments. Anchors,
+// tags, block scalars, and multiple documents are rejected rather than
+// misread. Mappings become map[string]interface{}, sequences
+// []interface{}, and numbers json.Number so the result converts to JSON
+// without losing precision.
+
+// yamlLine is one non-blank line of a YAML document
+type yamlLine struct {
+	number int // 1-based, for errors
+	indent int
+	text   string
+}
+
+// decodeYAML parses a YAML document
+func decodeYAML(data []byte) (interface{}, error) {
+	var lines []yamlLine
+	for i, raw := range strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n") {
+		text := stripYAMLComment(raw)
+		trimmed := strings.TrimLeft(text, " ")
+		if strings.TrimSpace(trimmed) == "" {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "\t") {
+			return nil, fmt.Errorf("yaml: line %d: tabs cannot indent", i+1)
+		}
+		if trimmed == "---" && len(lines) == 0 {
+			continue
+		}
+		if trimmed == "---" || trimmed == "..." {
+			return nil, fmt.Errorf("yaml: line %d: only one document is supported", i+1)
+		}
+		lines = append(lines, yamlLine{i + 1, len(text) - len(trimmed), strings.TrimRight(trimmed, " \t")})
+	}
+	if len(lines) == 0 {
+		return nil, nil
+	}
+
+	value, next, err := decodeYAMLBlock(lines, 0, lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if next < len(lines) {
+		return nil, fmt.Errorf("yaml: line %d: unexpected indentation", lines[next].number)
+	}
+	return value, nil
+}
+
+// stripYAMLComment removes a # comment that is not inside quotes
+func stripYAMLComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			if i == 0 || line[i-1] == ' ' || line[i-1] == '[' || line[i-1] == ',' || line[i-1] == ':' {
+				quote = c
+			}
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// isYAMLSequenceItem reports whether text starts a "- " sequence item
+func isYAMLSequenceItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// decodeYAMLBlock parses the mapping or sequence starting at lines[i],
+// whose lines are indented by indent, returning the index after it
+func decodeYAMLBlock(lines []yamlLine, i, indent int) (interface{}, int, error) {
+	if isYAMLSequenceItem(lines[i].text) {
+		return decodeYAMLSequence(lines, i, indent)
+	}
+	return decodeYAMLMapping(lines, i, indent)
+}
+
+// decodeYAMLSequence parses "- item" lines at indent
+func decodeYAMLSequence(lines []yamlLine, i, indent int) (interface{}, int, error) {
+	list := []interface{}{}
+	for i < len(lines) && lines[i].indent == indent && isYAMLSequenceItem(lines[i].text) {
+		rest := strings.TrimLeft(strings.TrimPrefix(lines[i].text, "-"), " ")
+		var item interface{}
+		var err error
+		switch {
+		case rest == "":
+			// The item is the block on the following, deeper lines
+			if i+1 < len(lines) && lines[i+1].indent > indent {
+				item, i, err = decodeYAMLBlock(lines, i+1, lines[i+1].indent)
+			} else {
+				i++
+			}
+		case isYAMLSequenceItem(rest) || yamlKey(rest) != "":
+			// "- key: value" starts a mapping whose keys line up after the dash
+			lines[i].indent += len(lines[i].text) - len(rest)
+			lines[i].text = rest
+			item, i, err = decodeYAMLBlock(lines, i, lines[i].indent)
+		default:
+			item, err = decodeYAMLScalar(rest, lines[i].number)
+			i++
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		list = append(list, item)
+	}
+	return list, i, nil
+}
+
+// decodeYAMLMapping parses "key: value" lines at indent
+func decodeYAMLMapping(lines []yamlLine, i, indent int) (interface{}, int, error) {
+	mapping := map[string]interface{}{}
+	for i < len(lines) && lines[i].indent == indent {
+		line := lines[i]
+		if isYAMLSequenceItem(line.text) {
+			return nil, 0, fmt.Errorf("yaml: line %d: sequence item where a key was expected", line.number)
+		}
+		rawKey := yamlKey(line.text)
+		if rawKey == "" {
+			return nil, 0, fmt.Errorf("yaml: line %d: expected \"key: value\"", line.number)
+		}
+		key, err := decodeYAMLScalar(rawKey, line.number)
+		if err != nil {
+			return nil, 0, err
+		}
+		name := fmt.Sprint(key)
+		if _, dup := mapping[name]; dup {
+			return nil, 0, fmt.Errorf("yaml: line %d: %s is set twice", line.number, name)
+		}
+		rest := strings.TrimSpace(line.text[len(rawKey)+1:])
+		i++
+
+		var value interface{}
+		switch {
+		case rest != "":
+			value, err = decodeYAMLScalar(rest, line.number)
+		case i < len(lines) && lines[i].indent > indent:
+			value, i, err = decodeYAMLBlock(lines, i, lines[i].indent)
+		case i < len(lines) && lines[i].indent == indent && isYAMLSequenceItem(lines[i].text):
+			// Sequences may line up with their key
+			value, i, err = decodeYAMLSequence(lines, i, indent)
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		mapping[name] = value
+	}
+	if i < len(lines) && lines[i].indent > indent {
+		return nil, 0, fmt.Errorf("yaml: line %d: unexpected indentation", lines[i].number)
+	}
+	return mapping, i, nil
+}
+
+// yamlKey returns the key part of a "key: value" line, or "" if text is
+// not one
+func yamlKey(text string) string {
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case i == 0 && (c == '"' || c == '\''):
+			quote = c
+		case c == ':' && (i+1 == len(text) || text[i+1] == ' '):
+			return text[:i]
+		case c == '[' || c == '{':
+			if i == 0 {
+				return ""
+			}
+		}
+	}
+	return ""
+}
+
+// decodeYAMLScalar parses a scalar or a flow sequence of scalars
+func decodeYAMLScalar(text string, number int) (interface{}, error) {
+	switch {
+	case text == "{}":
+		return map[string]interface{}{}, nil
+	case strings.HasPrefix(text, "["):
+		if !strings.HasSuffix(text, "]") {
+			return nil, fmt.Errorf("yaml: line %d: unterminated [", number)
+		}
+		list := []interface{}{}
+		for _, part := range splitYAMLFlow(text[1 : len(text)-1]) {
+			item, err := decodeYAMLScalar(part, number)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, item)
+		}
+		return list, nil
+	case strings.HasPrefix(text, "\""):
+		s, err := strconv.Unquote(text)
+		if err != nil {
+			return nil, fmt.Errorf("yaml: line %d: bad double-quoted string %s", number, text)
+		}
+		return s, nil
+	case strings.HasPrefix(text, "'"):
+		if len(text) < 2 || !strings.HasSuffix(text, "'") {
+			return nil, fmt.Errorf("yaml: line %d: unterminated single-quoted string", number)
+		}
+		return strings.ReplaceAll(text[1:len(text)-1], "''", "'"), nil
+	case strings.ContainsAny(text[:1], "{&*!|>%@`"):
+		return nil, fmt.Errorf("yaml: line %d: %q is not supported in the config file", number, text[:1])
+	}
+
+	switch text {
+	case "~", "null", "Null", "NULL":
+		return nil, nil
+	case "true", "True", "TRUE":
+		return true, nil
+	case "false", "False", "FALSE":
+		return false, nil
+	}
+	if _, err := strconv.ParseFloat(text, 64); err == nil && json.Valid([]byte(text)) {
+		return json.Number(text), nil
+	}
+	return text, nil
+}
+
+// splitYAMLFlow splits the inside of a flow sequence at commas outside
+// quotes
+func splitYAMLFlow(text string) []string {
+	var parts []string
+	var quote byte
+	start := 0
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ',':
+			parts = append(parts, strings.TrimSpace(text[start:i]))
+			start = i + 1
+		}
+	}
+	if last := strings.TrimSpace(text[start:]); last != "" || len(parts) > 0 {
+		parts = append(parts, last)
+	}
+	return parts
+}
+
+// encodeYAML writes value as block YAML with sorted keys. It takes what
+// json.Unmarshal produces with UseNumber.
+func encodeYAML(value interface{}) string {
+	var b strings.Builder
+	writeYAMLBlock(&b, value, 0)
+	return b.String()
+}
+
+// writeYAMLBlock writes a mapping or sequence at indent
+func writeYAMLBlock(b *strings.Builder, value interface{}, indent int) {
+	pad := strings.Repeat(" ", indent)
+	switch v := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			b.WriteString(pad + yamlScalar(key) + ":")
+			writeYAMLValue(b, v[key], indent)
+		}
+	case []interface{}:
+		for _, item := range v {
+			if isYAMLBlock(item) {
+				// Start the item's first line after the dash
+				var inner strings.Builder
+				writeYAMLBlock(&inner, item, indent+2)
+				b.WriteString(pad + "- " + strings.TrimPrefix(inner.String(), pad+"  "))
+				continue
+			}
+			b.WriteString(pad + "-")
+			writeYAMLValue(b, item, indent)
+		}
+	}
+}
+
+// writeYAMLValue finishes a "key:" or "-" line with value
+func writeYAMLValue(b *strings.Builder, value interface{}, indent int) {
+	if !isYAMLBlock(value) {
+		b.WriteString(" " + yamlScalar(value) + "\n")
+		return
+	}
+	b.WriteString("\n")
+	writeYAMLBlock(b, value, indent+2)
+}
+
+// isYAMLBlock reports whether value is a non-empty mapping or sequence
+func isYAMLBlock(value interface{}) bool {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return len(v) > 0
+	case []interface{}:
+		return len(v) > 0
+	}
+	return false
+}
+
+// yamlScalar formats a scalar, quoting strings that would read back as
+// anything else
+func yamlScalar(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(v)
+	case json.Number:
+		return v.String()
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case map[string]interface{}:
+		return "{}"
+	case []interface{}:
+		return "[]"
+	case string:
+		if !yamlPlainSafe(v) {
+			return strconv.Quote(v)
+		}
+		if plain, err := decodeYAMLScalar(v, 0); err == nil && plain == v {
+			return v
+		}
+		return strconv.Quote(v)
+	}
+	return strconv.Quote(fmt.Sprint(value))
+}
+
+// yamlPlainSafe reports whether s can be written unquoted
+func yamlPlainSafe(s string) bool {
+	if s == "" || s[0] == '-' || s[0] == '.' {
+		return false
+	}
+	for _, c := range s {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune("_./-", c)) {
+			return false
+		}
+	}
+	return true
+}
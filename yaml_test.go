@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// Test the YAML subset the config file uses decodes to JSON-ready values
+func TestDecodeYAML(t *testing.T) {
+	got, err := decodeYAML([]byte(`---
+# Deluge
+name: plain value   # trailing comment
+quoted: "a # not a comment\t"
+single: 'it''s'
+count: 42
+ratio: 1.5
+on: true
+none: ~
+version: 1.2.3
+flow: [one, "two, three", 4]
+empty: []
+nested:
+  inner:
+    deep: yes
+list:
+- first
+-   second
+items:
+  - name: seedbox
+    port: 8112
+  - name: other
+    tags:
+      - a
+`))
+	if err != nil {
+		t.Fatalf("decodeYAML failed: %v", err)
+	}
+	want := map[string]interface{}{
+		"name":    "plain value",
+		"quoted":  "a # not a comment\t",
+		"single":  "it's",
+		"count":   json.Number("42"),
+		"ratio":   json.Number("1.5"),
+		"on":      true,
+		"none":    nil,
+		"version": "1.2.3",
+		"flow":    []interface{}{"one", "two, three", json.Number("4")},
+		"empty":   []interface{}{},
+		"nested":  map[string]interface{}{"inner": map[string]interface{}{"deep": "yes"}},
+		"list":    []interface{}{"first", "second"},
+		"items": []interface{}{
+			map[string]interface{}{"name": "seedbox", "port": json.Number("8112")},
+			map[string]interface{}{"name": "other", "tags": []interface{}{"a"}},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %#v, got %#v", want, got)
+	}
+}
+
+// Test YAML the decoder does not handle is rejected rather than misread
+func TestDecodeYAMLUnsupported(t *testing.T) {
+	for _, input := range []string{
+		"key: value\n  stray: indent",
+		"key: &anchor value",
+		"key: |\n  block",
+		"key: {a: b}",
+		"a: 1\na: 2",
+		"just a line",
+		"a: 1\n---\nb: 2",
+		"key: \"unterminated",
+		"\tkey: value",
+	} {
+		if _, err := decodeYAML([]byte(input)); err == nil {
+			t.Errorf("Expected %q rejected", input)
+		}
+	}
+}
+
+// Test encodeYAML output decodes back to the same values
+func TestEncodeYAMLRoundTrip(t *testing.T) {
+	value := map[string]interface{}{
+		"port":    "8112",
+		"url":     "http://host:8112/#x",
+		"empty":   "",
+		"flag":    "true",
+		"number":  json.Number("2.5"),
+		"enabled": false,
+		"list":    []interface{}{"a", map[string]interface{}{"name": "b", "tags": []interface{}{"c", "d"}}},
+		"nested":  map[string]interface{}{"deep": map[string]interface{}{"key": "it's \"quoted\"\n"}},
+		"none":    []interface{}{},
+	}
+	got, err := decodeYAML([]byte(encodeYAML(value)))
+	if err != nil {
+		t.Fatalf("Failed to decode %q: %v", encodeYAML(value), err)
+	}
+	if !reflect.DeepEqual(got, value) {
+		t.Errorf("Round trip changed the values:\n%s\ngot %#v", encodeYAML(value), got)
+	}
+}